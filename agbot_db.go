@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	agbotPersistence "github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/config"
+	"os"
+	"strings"
+)
+
+// Commands that run instead of the anax runtime, selected by the arguments after the flags, for example:
+//
+//	anax -config /etc/horizon/anax.json agbot db migrate -from bolt -to postgresql
+//	anax -config /etc/horizon/anax.json agbot db schema -version 3
+//
+// The agbot must be stopped while its database is migrated. Both databases are taken from the agbot section of the
+// config file, so it has to contain the bolt DBPath and the Postgresql settings.
+func runCommand(cfg *config.HorizonConfig, args []string) error {
+	if len(args) >= 3 && args[0] == "agbot" && args[1] == "db" && args[2] == "migrate" {
+		return agbotDBMigrate(cfg, args[3:])
+	} else if len(args) >= 3 && args[0] == "agbot" && args[1] == "db" && args[2] == "schema" {
+		return agbotDBSchema(cfg, args[3:])
+	}
+	return errors.New(fmt.Sprintf("unknown command: %v", strings.Join(args, " ")))
+}
+
+func agbotDBMigrate(cfg *config.HorizonConfig, args []string) error {
+	flags := flag.NewFlagSet("agbot db migrate", flag.ContinueOnError)
+	from := flags.String("from", "bolt", "The database to copy from.")
+	to := flags.String("to", "postgresql", "The database to copy to.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Only the bolt DB can be read in full by a single agbot, a postgresql agbot only reads the partitions it owns.
+	if *from != "bolt" {
+		return errors.New(fmt.Sprintf("migrating from %v is not supported, only bolt can be migrated", *from))
+	} else if *from == *to {
+		return errors.New(fmt.Sprintf("the source and target databases are both %v", *from))
+	} else if *to != "postgresql" {
+		return errors.New(fmt.Sprintf("unknown target database %v", *to))
+	} else if !cfg.IsBoltDBConfigured() {
+		return errors.New(fmt.Sprintf("the agbot DBPath of the bolt database is not set in the config file"))
+	} else if !cfg.IsPostgresqlConfigured() {
+		return errors.New(fmt.Sprintf("the agbot Postgresql database is not set in the config file"))
+	}
+
+	source := agbotPersistence.DatabaseProviders[*from]
+	target := agbotPersistence.DatabaseProviders[*to]
+	if err := source.Initialize(cfg); err != nil {
+		return errors.New(fmt.Sprintf("unable to open the %v database, error: %v", *from, err))
+	}
+	defer source.Close()
+	if err := target.Initialize(cfg); err != nil {
+		return errors.New(fmt.Sprintf("unable to open the %v database, error: %v", *to, err))
+	}
+	defer target.Close()
+
+	migrations, err := agbotPersistence.MigrateDatabase(source, target)
+
+	fmt.Printf("%-16s %8s %8s %8s %8s %8s %8s  %s\n", "TABLE", "SOURCE", "INSERTED", "UPDATED", "SKIPPED", "BEFORE", "AFTER", "VERIFIED")
+	for _, m := range migrations {
+		fmt.Printf("%-16s %8d %8d %8d %8d %8d %8d  %v\n", m.Table, m.Source, m.Inserted, m.Updated, m.Skipped, m.TargetBefore, m.TargetAfter, m.Verified())
+	}
+
+	// Give up the partition holding the copied records, so that a running agbot will take it over.
+	if qerr := target.QuiescePartition(); qerr != nil && err == nil {
+		err = qerr
+	}
+	return err
+}
+
+// Databases that can move their schema to a given version.
+type schemaMigrator interface {
+	MigrateSchema(target int, undo bool) error
+}
+
+// Move the postgresql schema to an older version, so that the database can be used by an older agbot. Starting any
+// agbot upgrades the schema to the version it supports, so this is normally done with the newer agbot's anax binary
+// before rolling back to the older agbot.
+func agbotDBSchema(cfg *config.HorizonConfig, args []string) error {
+	flags := flag.NewFlagSet("agbot db schema", flag.ContinueOnError)
+	version := flags.Int("version", -1, "The schema version to move the database to.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if *version < 0 {
+		return errors.New(fmt.Sprintf("the -version flag is required"))
+	} else if !cfg.IsPostgresqlConfigured() {
+		return errors.New(fmt.Sprintf("the agbot Postgresql database is not set in the config file"))
+	}
+
+	db := agbotPersistence.DatabaseProviders["postgresql"]
+	if err := db.Initialize(cfg); err != nil {
+		return errors.New(fmt.Sprintf("unable to open the postgresql database, error: %v", err))
+	}
+	defer db.Close()
+	defer db.QuiescePartition()
+
+	if err := db.(schemaMigrator).MigrateSchema(*version, true); err != nil {
+		return err
+	}
+
+	status, err := db.GetSchemaStatus()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version: %v, %v\n", status.Version, status.Description)
+	return nil
+}
+
+// Run the command and exit with a non-zero status if it fails.
+func runCommandAndExit(cfg *config.HorizonConfig, args []string) {
+	if err := runCommand(cfg, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
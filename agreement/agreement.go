@@ -1028,46 +1028,25 @@ func deleteProducerAgreement(httpClient *http.Client, url string, deviceId strin
 }
 
 func (w *AgreementWorker) deleteMessage(msg *exchange.DeviceMessage) error {
-	var resp interface{}
-	resp = new(exchange.PostDeviceResponse)
-	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msg.MsgId)
-	for {
-		if err, tpErr := exchange.InvokeExchange(w.GetHTTPFactory().NewHTTPClient(nil), "DELETE", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
-			glog.Errorf(err.Error())
-			return err
-		} else if tpErr != nil {
-			glog.Warningf(tpErr.Error())
-			time.Sleep(10 * time.Second)
-			continue
-		} else {
-			glog.V(3).Infof(logString(fmt.Sprintf("deleted message %v", msg.MsgId)))
-			return nil
-		}
+	t, err := exchange.GetNodeMessageTransport(w.Config, w.GetExchangeId(), w.GetExchangeToken(), exchange.RetryForeverHTTPFactory(w.GetHTTPFactory()))
+	if err == nil {
+		err = t.DeleteMessage(msg.MsgId)
+	}
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to delete message %v, error %v", msg.MsgId, err)))
+		return err
 	}
+	glog.V(3).Infof(logString(fmt.Sprintf("deleted message %v", msg.MsgId)))
+	return nil
 }
 
 func (w *AgreementWorker) messageInExchange(msgId int) (bool, error) {
-	var resp interface{}
-	resp = new(exchange.GetDeviceMessageResponse)
-	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msgId)
-	for {
-		if err, tpErr := exchange.InvokeExchange(w.GetHTTPFactory().NewHTTPClient(nil), "GET", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
-			glog.Errorf(err.Error())
-			return false, err
-		} else if tpErr != nil {
-			glog.Warningf(tpErr.Error())
-			time.Sleep(10 * time.Second)
-			continue
-		} else {
-			msgs := resp.(*exchange.GetDeviceMessageResponse).Messages
-			for _, msg := range msgs {
-				if msg.MsgId == msgId {
-					return true, nil
-				}
-			}
-			return false, nil
-		}
+	t, err := exchange.GetNodeMessageTransport(w.Config, w.GetExchangeId(), w.GetExchangeToken(), exchange.RetryForeverHTTPFactory(w.GetHTTPFactory()))
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to check for message %v, error %v", msgId, err)))
+		return false, err
 	}
+	return t.HasMessage(msgId)
 }
 
 var logString = func(v interface{}) string {
@@ -17,7 +17,6 @@ import (
 	"github.com/open-horizon/anax/worker"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -60,9 +59,11 @@ type AgreementBotWorker struct {
 	nodeSearch           *NodeSearch // The object that controls node searches and the state of search sessions.
 	secretProvider       secrets.AgbotSecrets
 	secretUpdateManager  *SecretUpdateManager
+	dispatchedMessages   *exchange.DispatchedMessages
+	rolloutMgr           *RolloutManager // The staged rollouts of workload upgrades, shared with the API.
 }
 
-func NewAgreementBotWorker(name string, cfg *config.HorizonConfig, db persistence.AgbotDatabase, s secrets.AgbotSecrets) *AgreementBotWorker {
+func NewAgreementBotWorker(name string, cfg *config.HorizonConfig, db persistence.AgbotDatabase, s secrets.AgbotSecrets, rm *RolloutManager) *AgreementBotWorker {
 
 	ec := worker.NewExchangeContext(cfg.AgreementBot.ExchangeId, cfg.AgreementBot.ExchangeToken, cfg.AgreementBot.ExchangeURL, cfg.AgreementBot.CSSURL, cfg.Collaborators.HTTPClientFactory)
 
@@ -81,10 +82,20 @@ func NewAgreementBotWorker(name string, cfg *config.HorizonConfig, db persistenc
 		nodeSearch:           NewNodeSearch(),
 		secretProvider:       s,
 		secretUpdateManager:  NewSecretUpdateManager(),
+		dispatchedMessages:   exchange.NewDispatchedMessages(),
+		rolloutMgr:           rm,
 	}
 
+	// Transports other than the exchange mailbox push messages to the agbot, so the agbot is told about them the same
+	// way the exchange changes API tells it about new messages in its mailbox.
+	exchange.SetMessageNotify(exchange.TRANSPORT_PARTY_AGBOT, func() {
+		worker.Commands <- NewMessageCommand(events.NewExchangeChangeMessage(events.CHANGE_AGBOT_MESSAGE_TYPE))
+	})
+
 	patternManager = NewPatternManager()
 
+	registerWorkerMetrics(db, worker.consumerPH, s)
+
 	glog.Info("Starting AgreementBot worker")
 	worker.Start(worker, int(cfg.AgreementBot.NewContractIntervalS))
 	return worker
@@ -386,7 +397,7 @@ func (w *AgreementBotWorker) Initialize() bool {
 	// to initiate the protocol.
 	for protocolName, _ := range w.pm.GetAllAgreementProtocols() {
 		if policy.SupportedAgreementProtocol(protocolName) {
-			cph := CreateConsumerPH(protocolName, w.BaseWorker.Manager.Config, w.db, w.pm, w.BaseWorker.Manager.Messages, w.MMSObjectPM, w.secretProvider)
+			cph := CreateConsumerPH(protocolName, w.BaseWorker.Manager.Config, w.db, w.pm, w.BaseWorker.Manager.Messages, w.MMSObjectPM, w.secretProvider, w.rolloutMgr)
 			cph.Initialize()
 			w.consumerPH.Add(protocolName, cph)
 		} else {
@@ -481,7 +492,7 @@ func (w *AgreementBotWorker) CommandHandler(command worker.Command) bool {
 				// Update the protocol handler map and make sure there are workers available if the policy has a new protocol in it.
 				if !w.consumerPH.Has(agp.Name) {
 					glog.V(3).Infof("AgreementBotWorker creating worker pool for new agreement protocol %v", agp.Name)
-					cph := CreateConsumerPH(agp.Name, w.BaseWorker.Manager.Config, w.db, w.pm, w.BaseWorker.Manager.Messages, w.MMSObjectPM, w.secretProvider)
+					cph := CreateConsumerPH(agp.Name, w.BaseWorker.Manager.Config, w.db, w.pm, w.BaseWorker.Manager.Messages, w.MMSObjectPM, w.secretProvider, w.rolloutMgr)
 					cph.Initialize()
 					w.consumerPH.Add(agp.Name, cph)
 				}
@@ -721,7 +732,7 @@ func (w *AgreementBotWorker) processProtocolMessage(limit int) {
 			} else if !w.consumerPH.Has(msgProtocol) {
 				glog.Infof(fmt.Sprintf("AgreementBotWorker unable to direct exchange message %v to a protocol handler, deleting it.", protocolMessage))
 				deleteMessage = false
-				DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+				DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.Config)
 			} else {
 				// The message seems to be good, so don't delete it yet, the protocol worker that handles the message will delete it.
 				deleteMessage = false
@@ -730,9 +741,9 @@ func (w *AgreementBotWorker) processProtocolMessage(limit int) {
 				cmd := NewNewProtocolMessageCommand(protocolMessage, msg.MsgId, msg.DeviceId, msg.DevicePubKey)
 				if !w.consumerPH.Get(msgProtocol).AcceptCommand(cmd) {
 					glog.Infof(fmt.Sprintf("AgreementBotWorker protocol handler for %v not accepting exchange messages, deleting msg.", msgProtocol))
-					DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+					DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.Config)
 				} else if err := w.consumerPH.Get(msgProtocol).DispatchProtocolMessage(cmd, w.consumerPH.Get(msgProtocol)); err != nil {
-					DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+					DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.Config)
 				}
 
 			}
@@ -740,7 +751,7 @@ func (w *AgreementBotWorker) processProtocolMessage(limit int) {
 			// If anything went wrong trying to decrypt the message or verify its origin, etc, just delete it. These errors aren't
 			// expected to be retryable.
 			if deleteMessage {
-				DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.GetExchangeURL(), w.httpClient)
+				DeleteMessage(msg.MsgId, w.GetExchangeId(), w.GetExchangeToken(), w.Config)
 			}
 
 		}
@@ -911,23 +922,33 @@ func (w *AgreementBotWorker) errorPolicy(org string, fileName string, err error)
 }
 
 func (w *AgreementBotWorker) getMessages(limit int) ([]exchange.AgbotMessage, error) {
-	var resp interface{}
-	resp = new(exchange.GetAgbotMessageResponse)
-	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/agbots/" + exchange.GetId(w.GetExchangeId()) + "/msgs?maxmsgs=" + strconv.Itoa(limit)
-	for {
-		if err, tpErr := exchange.InvokeExchange(w.httpClient, "GET", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
-			glog.Errorf(err.Error())
-			return nil, err
-		} else if tpErr != nil {
-			glog.Warningf(tpErr.Error())
-			time.Sleep(10 * time.Second)
-			continue
-		} else {
-			glog.V(3).Infof(fmt.Sprintf("AgreementBotWorker retrieved %v messages", len(resp.(*exchange.GetAgbotMessageResponse).Messages)))
-			msgs := resp.(*exchange.GetAgbotMessageResponse).Messages
-			return msgs, nil
-		}
+	t, err := exchange.GetAgbotMessageTransport(w.Config, w.GetExchangeId(), w.GetExchangeToken(), exchange.RetryForeverHTTPFactory(w.Config.Collaborators.HTTPClientFactory))
+	if err != nil {
+		return nil, err
+	}
+
+	// The agbot is told about each message that arrives on transports other than the exchange mailbox, so the messages
+	// that have already been sent to a protocol worker are skipped until the protocol worker deletes them.
+	tracked := t.Name() != exchange.EXCHANGE_MAILBOX_TRANSPORT
+	max := limit
+	if tracked && limit > 0 {
+		max += w.dispatchedMessages.Count()
+	}
+
+	tMsgs, err := t.GetMessages(max)
+	if err != nil {
+		glog.Errorf(err.Error())
+		return nil, err
+	} else if tracked {
+		tMsgs = w.dispatchedMessages.Undispatched(t, tMsgs, limit)
+	}
+
+	msgs := make([]exchange.AgbotMessage, 0, len(tMsgs))
+	for _, m := range tMsgs {
+		msgs = append(msgs, m.AgbotMessage())
 	}
+	glog.V(3).Infof(fmt.Sprintf("AgreementBotWorker retrieved %v messages", len(msgs)))
+	return msgs, nil
 }
 
 func DeleteConsumerAgreement(httpClient *http.Client, url string, agbotId string, token string, agreementId string) error {
@@ -953,23 +974,17 @@ func DeleteConsumerAgreement(httpClient *http.Client, url string, agbotId string
 
 }
 
-func DeleteMessage(msgId int, agbotId, agbotToken, exchangeURL string, httpClient *http.Client) error {
-	var resp interface{}
-	resp = new(exchange.PostDeviceResponse)
-	targetURL := exchangeURL + "orgs/" + exchange.GetOrg(agbotId) + "/agbots/" + exchange.GetId(agbotId) + "/msgs/" + strconv.Itoa(msgId)
-	for {
-		if err, tpErr := exchange.InvokeExchange(httpClient, "DELETE", targetURL, agbotId, agbotToken, nil, &resp); err != nil {
-			glog.Errorf(err.Error())
-			return err
-		} else if tpErr != nil {
-			glog.Warningf(tpErr.Error())
-			time.Sleep(10 * time.Second)
-			continue
-		} else {
-			glog.V(3).Infof("Deleted exchange message %v", msgId)
-			return nil
-		}
+func DeleteMessage(msgId int, agbotId string, agbotToken string, cfg *config.HorizonConfig) error {
+	t, err := exchange.GetAgbotMessageTransport(cfg, agbotId, agbotToken, exchange.RetryForeverHTTPFactory(cfg.Collaborators.HTTPClientFactory))
+	if err == nil {
+		err = t.DeleteMessage(msgId)
+	}
+	if err != nil {
+		glog.Errorf(err.Error())
+		return err
 	}
+	glog.V(3).Infof("Deleted exchange message %v", msgId)
+	return nil
 }
 
 func (w *AgreementBotWorker) syncOnInit() error {
@@ -790,6 +790,8 @@ func (b *BaseAgreementWorker) InitiateNewAgreement(cph ConsumerProtocolHandler,
 		// Update the agreement in the DB with the proposal and policy
 	} else if err := cph.PersistAgreement(wi, proposal, workerId); err != nil {
 		glog.Errorf(err.Error())
+	} else {
+		proposalCounter.Inc(cph.Name(), PROPOSAL_SENT)
 	}
 
 }
@@ -960,6 +962,9 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 	ackReplyAsValid := false
 	sendReply := true
 
+	// Set when the agreement is made with a lower priority workload than the node was using before.
+	var rollback *events.ABServiceRollbackMessage
+
 	if reply.ProposalAccepted() {
 
 		// Find the saved agreement in the database. The returned agreement might be archived. If it's archived, then it is our agreement
@@ -1000,6 +1005,7 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 		} else {
 			// Done handling the response successfully
 			ackReplyAsValid = true
+			proposalCounter.Inc(cph.Name(), PROPOSAL_ACCEPTED)
 
 			// If we dont have a workload usage record for this device, then we need to create one. If there is already a
 			// workload usage record and workload rollback retry counting is enabled, then check to see if the workload priority
@@ -1028,6 +1034,10 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 				}
 
 				if !wlUsage.DisableRetry {
+					if pol.Workloads[0].Priority.PriorityValue > wlUsage.Priority {
+						// A larger priority value is a lower priority, so the node has been rolled back to an older version of the service.
+						rollback = events.NewABServiceRollbackMessage(events.AB_SERVICE_ROLLBACK, reply.AgreementId(), wi.SenderId, consumerPolicy.Header.Name, pol.Workloads[0].WorkloadURL, pol.Workloads[0].Org, pol.Workloads[0].Version, pol.Workloads[0].Priority.PriorityValue, wlUsage.Priority)
+					}
 					if pol.Workloads[0].Priority.PriorityValue != wlUsage.Priority {
 						if _, err := b.db.UpdatePriority(wi.SenderId, consumerPolicy.Header.Name, pol.Workloads[0].Priority.PriorityValue, pol.Workloads[0].Priority.RetryDurationS, pol.Workloads[0].Priority.VerifiedDurationS, reply.AgreementId()); err != nil {
 							glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error updating workload usage prioroty for device %v with policy %v, error: %v", wi.SenderId, consumerPolicy.Header.Name, err)))
@@ -1107,6 +1117,11 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 					glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error trying to record agreement in blockchain, %v", err)))
 					b.CancelAgreementWithLock(cph, reply.AgreementId(), cph.GetTerminationCode(TERM_REASON_CANCEL_BC_WRITE_FAILED), workerId)
 					ackReplyAsValid = false
+				} else {
+					cph.SendEventMessage(events.NewABAgreementMessage(events.AB_AGREEMENT_MADE, cph.Name(), agreement.CurrentAgreementId, agreement.DeviceId, agreement.PolicyName, agreement.Pattern, agreement.ServiceId, 0, ""))
+					if rollback != nil {
+						cph.SendEventMessage(rollback)
+					}
 				}
 
 			}
@@ -1123,6 +1138,7 @@ func (b *BaseAgreementWorker) HandleAgreementReply(cph ConsumerProtocolHandler,
 
 	} else {
 		glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("received rejection from producer %v", reply)))
+		proposalCounter.Inc(cph.Name(), PROPOSAL_REJECTED)
 
 		// Returns true if the protocol msg can be deleted.
 		ok := b.CancelAgreement(cph, reply.AgreementId(), cph.GetTerminationCode(TERM_REASON_NEGATIVE_REPLY), workerId)
@@ -1291,6 +1307,18 @@ func (b *BaseAgreementWorker) CancelAgreement(cph ConsumerProtocolHandler, agree
 		if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
 			glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error deleting workload usage record for device %v and policyName %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
 		}
+
+	} else if wlUsage != nil && wlUsage.HeldUpgrade != nil {
+		// If the workload usage record is holding a workload upgrade, the upgrade was held until the agreement ends, so the next
+		// agreement should be made with the newest workload. Clear the held upgrade but keep the workload priority and retry state.
+		// A record that only exists to hold the upgrade has no other state, so it is removed.
+		if wlUsage.Priority == 0 {
+			if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
+				glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error deleting workload usage record for device %v and policyName %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+			}
+		} else if _, err := b.db.UpdateHeldUpgrade(ag.DeviceId, ag.PolicyName, nil); err != nil {
+			glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error clearing held upgrade in workload usage record for device %v and policyName %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		}
 	}
 
 	// Remove the long blockchain cancel from the worker thread. It is important to give the protocol handler a chance to
@@ -1321,9 +1349,26 @@ func (b *BaseAgreementWorker) CancelAgreement(cph ConsumerProtocolHandler, agree
 		glog.Errorf(BAWlogstring(workerId, fmt.Sprintf("error archiving terminated agreement: %v, error: %v", ag.CurrentAgreementId, err)))
 	}
 
+	// Let the other workers know that the agreement has ended.
+	eventId := events.AB_AGREEMENT_CANCELLED
+	if isTimeoutTermination(cph, reason) {
+		eventId = events.AB_AGREEMENT_TIMEDOUT
+	}
+	cph.SendEventMessage(events.NewABAgreementMessage(eventId, cph.Name(), ag.CurrentAgreementId, ag.DeviceId, ag.PolicyName, ag.Pattern, ag.ServiceId, reason, cph.GetTerminationReason(reason)))
+
 	return true
 }
 
+// Returns true if the agreement was terminated because the node did not respond in time.
+func isTimeoutTermination(cph ConsumerProtocolHandler, reason uint) bool {
+	for _, termReason := range []string{TERM_REASON_NO_REPLY, TERM_REASON_NOT_FINALIZED_TIMEOUT, TERM_REASON_NO_DATA_RECEIVED} {
+		if cph.GetTerminationCode(termReason) == reason {
+			return true
+		}
+	}
+	return false
+}
+
 // This function is only called when the cancel is deferred due to blockchain unavailability.
 func (b *BaseAgreementWorker) ExternalCancel(cph ConsumerProtocolHandler, agreementId string, reason uint, workerId string) {
 
@@ -9,6 +9,7 @@ import (
 	"github.com/open-horizon/anax/agreementbot/persistence"
 	"github.com/open-horizon/anax/agreementbot/secrets"
 	"github.com/open-horizon/anax/apicommon"
+	"github.com/open-horizon/anax/basicprotocol"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
@@ -17,6 +18,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -33,9 +35,11 @@ type API struct {
 	shutdownError  string
 	configFile     string
 	secretProvider secrets.AgbotSecrets
+	cph            ConsumerProtocolHandler // Used to tell the state of agreements, it does not run the agreement protocol
+	rolloutMgr     *RolloutManager
 }
 
-func NewAPIListener(name string, config *config.HorizonConfig, db persistence.AgbotDatabase, configFile string, s secrets.AgbotSecrets) *API {
+func NewAPIListener(name string, config *config.HorizonConfig, db persistence.AgbotDatabase, configFile string, s secrets.AgbotSecrets, rm *RolloutManager) *API {
 	messages := make(chan events.Message)
 
 	listener := &API{
@@ -50,6 +54,8 @@ func NewAPIListener(name string, config *config.HorizonConfig, db persistence.Ag
 		em:             events.NewEventStateManager(),
 		configFile:     configFile,
 		secretProvider: s,
+		cph:            CreateConsumerPH(policy.BasicProtocol, config, db, nil, messages, nil, s, rm),
+		rolloutMgr:     rm,
 	}
 
 	listener.listen(config.AgreementBot.APIListen)
@@ -327,6 +333,8 @@ func (a *API) listen(apiListen string) {
 		router := mux.NewRouter()
 
 		router.HandleFunc("/agreement", a.agreement).Methods("GET", "OPTIONS")
+		router.HandleFunc("/agreement/archived", a.archivedAgreements).Methods("GET", "OPTIONS")
+		router.HandleFunc("/agreement/archived/terminations", a.terminations).Methods("GET", "OPTIONS")
 		router.HandleFunc("/agreement/{id}", a.agreement).Methods("GET", "DELETE", "OPTIONS")
 		router.HandleFunc("/partition", a.partition).Methods("GET", "OPTIONS")
 		router.HandleFunc("/policy", a.policy).Methods("GET", "OPTIONS")
@@ -334,8 +342,14 @@ func (a *API) listen(apiListen string) {
 		router.HandleFunc("/policy/{org}/{name}", a.policy).Methods("GET", "OPTIONS")
 		router.HandleFunc("/policy/{name}/upgrade", a.policy).Methods("POST", "OPTIONS")
 		router.HandleFunc("/workloadusage", a.workloadusage).Methods("GET", "OPTIONS")
+		router.HandleFunc("/deploymentstatus", a.deploymentstatus).Methods("GET", "OPTIONS")
+		router.HandleFunc("/deploymentstatus/{type}/{org}/{name}", a.deploymentstatus).Methods("GET", "OPTIONS")
+		router.HandleFunc("/rollout", a.rollout).Methods("GET", "OPTIONS")
+		router.HandleFunc("/rollout/{org}/{name}", a.rollout).Methods("GET", "OPTIONS")
+		router.HandleFunc("/rollout/{org}/{name}/{action}", a.rollout).Methods("POST", "OPTIONS")
 		router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 		router.HandleFunc("/health", a.health).Methods("GET", "OPTIONS")
+		router.Handle("/metrics", agbotMetrics).Methods("GET", "OPTIONS")
 		router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
 		router.HandleFunc("/node", a.node).Methods("GET", "DELETE", "OPTIONS")
 		router.HandleFunc("/config", a.config).Methods("GET", "OPTIONS")
@@ -452,6 +466,251 @@ func (a *API) agreement(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Parse the query parameters that select archived agreements. Times are either seconds since the epoch or RFC3339.
+func archivedAgreementQuery(r *http.Request) (*persistence.ArchivedAgreementQuery, *APIUserInputError) {
+	params := r.URL.Query()
+	query := &persistence.ArchivedAgreementQuery{
+		NodeId:     params.Get("node"),
+		PolicyName: params.Get("policy"),
+		Pattern:    params.Get("pattern"),
+		ServiceURL: params.Get("service"),
+		Cursor:     params.Get("cursor"),
+	}
+
+	if reason := params.Get("reason"); reason != "" {
+		if code, err := strconv.ParseUint(reason, 10, 32); err != nil {
+			return nil, &APIUserInputError{Input: "reason", Error: "must be a termination reason code"}
+		} else {
+			query.Reason = uint(code)
+		}
+	}
+
+	parseTime := func(input string, value string) (uint64, *APIUserInputError) {
+		if value == "" {
+			return 0, nil
+		} else if secs, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return secs, nil
+		} else if t, err := time.Parse(time.RFC3339, value); err == nil && t.Unix() >= 0 {
+			return uint64(t.Unix()), nil
+		}
+		return 0, &APIUserInputError{Input: input, Error: "must be seconds since the epoch or an RFC3339 time"}
+	}
+
+	var inputErr *APIUserInputError
+	if query.Since, inputErr = parseTime("since", params.Get("since")); inputErr != nil {
+		return nil, inputErr
+	} else if query.Until, inputErr = parseTime("until", params.Get("until")); inputErr != nil {
+		return nil, inputErr
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		if n, err := strconv.Atoi(limit); err != nil || n <= 0 || n > MAX_ARCHIVED_AGREEMENT_PAGE_SIZE {
+			return nil, &APIUserInputError{Input: "limit", Error: fmt.Sprintf("must be between 1 and %v", MAX_ARCHIVED_AGREEMENT_PAGE_SIZE)}
+		} else {
+			query.Limit = n
+		}
+	}
+
+	if _, err := persistence.ParseArchivedAgreementCursor(query.Cursor); err != nil {
+		return nil, &APIUserInputError{Input: "cursor", Error: err.Error()}
+	}
+	return query, nil
+}
+
+const MAX_ARCHIVED_AGREEMENT_PAGE_SIZE = 1000
+
+// Return a page of the archived agreement history, newest first.
+func (a *API) archivedAgreements(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		query, inputErr := archivedAgreementQuery(r)
+		if inputErr != nil {
+			writeInputErr(w, http.StatusBadRequest, inputErr)
+			return
+		}
+
+		page, err := a.db.FindArchivedAgreements(*query)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding archived agreements, error: %v", err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		for ix := range page.Agreements {
+			if page.Agreements[ix].Proposal, err = abstractprotocol.ObscureProposalSecret(page.Agreements[ix].Proposal); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("failed to obscure secret details, error: %v", err)))
+			} else if page.Agreements[ix].Policy, err = policy.ObscureSecretDetails(page.Agreements[ix].Policy); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("failed to obscure secret details, error: %v", err)))
+			}
+		}
+
+		writeResponse(w, page, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Return the number of archived agreements terminated for each reason, for each policy.
+func (a *API) terminations(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		query, inputErr := archivedAgreementQuery(r)
+		if inputErr != nil {
+			writeInputErr(w, http.StatusBadRequest, inputErr)
+			return
+		}
+
+		counts, err := a.db.GetTerminationCounts(*query)
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error counting agreement terminations, error: %v", err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		for ix := range counts {
+			counts[ix].Description = basicprotocol.DecodeReasonCode(uint64(counts[ix].Reason))
+		}
+
+		writeResponse(w, counts, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns the exchange node search used for the deployment status.
+func (a *API) newDeploymentNodeSearch() (*deploymentNodeSearch, error) {
+	servedPatterns, err := exchange.GetHTTPAgbotServedPattern(a)()
+	if err != nil {
+		return nil, err
+	}
+	servedPolicies, err := exchange.GetHTTPAgbotServedDeploymentPolicy(a)()
+	if err != nil {
+		return nil, err
+	}
+	return &deploymentNodeSearch{
+		servedPatterns: servedPatterns,
+		servedPolicies: servedPolicies,
+		pattern:        exchange.GetHTTPExchangePatternHandler(a),
+		patternSearch:  exchange.GetHTTPAgbotPatternNodeSearchHandler(a),
+		policySearch:   exchange.GetHTTPAgbotPolicyNodeSearchHandler(a),
+		secondsStale:   a.Config.AgreementBot.ActiveDeviceTimeoutS,
+		pageSize:       a.Config.GetAgbotAgreementBatchSize(),
+		session:        fmt.Sprintf("status%v", time.Now().UnixNano()),
+	}, nil
+}
+
+// Returns a function that returns the last heartbeat of the node in an agreement. The heartbeats are read from the
+// exchange once for each pattern, or org for deployment policies, of the agreements.
+func (a *API) nodeHeartbeats(ags []persistence.Agreement) func(ag *persistence.Agreement) string {
+	nhm := NewNodeHealthManager()
+	nhm.SetNodeOrgs(ags, policy.BasicProtocol)
+	nhHandler := func(pattern string, org string, nodeOrgs []string, lastCallTime string) (*exchange.NodeHealthStatus, error) {
+		return exchange.GetNodeHealthStatus(a.GetHTTPFactory(), pattern, org, nodeOrgs, lastCallTime, a.GetExchangeURL(), a.GetExchangeId(), a.GetExchangeToken())
+	}
+	for ix := range ags {
+		if err := nhm.SetUpdatedStatus(ags[ix].Pattern, ags[ix].Org, nhHandler); err != nil {
+			glog.Warning(APIlogString(fmt.Sprintf("unable to get node heartbeats, error: %v", err)))
+		}
+	}
+	return func(ag *persistence.Agreement) string {
+		return nhm.GetLastHeartbeat(ag.Pattern, ag.Org, ag.DeviceId)
+	}
+}
+
+// Return the deployment status of all deployment policies and patterns, or the status of one of them with the
+// status of each node.
+func (a *API) deploymentstatus(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case "GET":
+		pathVars := mux.Vars(r)
+		depType := pathVars["type"]
+		name := fmt.Sprintf("%v/%v", pathVars["org"], pathVars["name"])
+
+		if depType != "" && depType != DEPLOYMENT_TYPE_POLICY && depType != DEPLOYMENT_TYPE_PATTERN {
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "type", Error: fmt.Sprintf("must be %v or %v", DEPLOYMENT_TYPE_POLICY, DEPLOYMENT_TYPE_PATTERN)})
+			return
+		}
+
+		// The agreements and workload usages of all the agbots sharing the database are included.
+		ags := make([]persistence.Agreement, 0)
+		for _, agp := range policy.AllAgreementProtocols() {
+			if protocolAgs, err := a.db.FindAgreementsInAllPartitions([]persistence.AFilter{}, agp); err != nil {
+				glog.Error(APIlogString(fmt.Sprintf("error finding agreements, error: %v", err)))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			} else {
+				ags = append(ags, protocolAgs...)
+			}
+		}
+
+		wus, err := a.db.FindWorkloadUsagesInAllPartitions([]persistence.WUFilter{})
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error finding workload usages, error: %v", err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if depType != "" {
+			ags, wus = filterDeployment(ags, wus, depType, name)
+		}
+
+		// Search the exchange for the nodes that match each deployment. If a search fails, the deployment's matched nodes
+		// are the nodes that the agbots have worked with.
+		nodeSearch, err := a.newDeploymentNodeSearch()
+		if err != nil {
+			glog.Error(APIlogString(fmt.Sprintf("error getting the served patterns and deployment policies, error: %v", err)))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		deployments := buildDeploymentStatuses(ags, wus, nil, a.cph, nil, nil)
+		if depType != "" {
+			deployments[deploymentKey(depType, name)] = &DeploymentStatus{Name: name, Type: depType}
+		}
+		matched := make(map[string][]string, len(deployments))
+		for key, dep := range deployments {
+			if nodeIds, err := nodeSearch.nodes(dep.Type, dep.Name); err != nil {
+				glog.Warning(APIlogString(fmt.Sprintf("unable to search the exchange for the nodes of %v %v, error: %v", dep.Type, dep.Name, err)))
+			} else {
+				matched[key] = nodeIds
+			}
+		}
+
+		if depType == "" {
+			writeResponse(w, deploymentStatusSummaries(buildDeploymentStatuses(ags, wus, matched, a.cph, nil, nil)), http.StatusOK)
+			return
+		}
+
+		// The node errors are read from the exchange for each executing node, so they are only read when asked for.
+		var surfaceErrors exchange.SurfaceErrorsHandler
+		if r.URL.Query().Get("errors") == "true" {
+			surfaceErrors = exchange.GetHTTPSurfaceErrorsHandler(a)
+		}
+
+		if status, ok := buildDeploymentStatuses(ags, wus, matched, a.cph, a.nodeHeartbeats(ags), surfaceErrors)[deploymentKey(depType, name)]; ok {
+			writeResponse(w, status, http.StatusOK)
+		} else {
+			writeResponse(w, &DeploymentStatus{Name: name, Type: depType, Nodes: []*NodeDeploymentStatus{}}, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) policy(w http.ResponseWriter, r *http.Request) {
 
 	serviceResolver := func(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
@@ -624,6 +883,61 @@ func (a *API) workloadusage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a *API) rollout(w http.ResponseWriter, r *http.Request) {
+
+	pathVars := mux.Vars(r)
+	org := pathVars["org"]
+	name := pathVars["name"]
+	action := pathVars["action"]
+
+	// Rollouts are kept by policy name, which is the org qualified deployment policy name.
+	policyName := fmt.Sprintf("%v/%v", org, name)
+
+	switch r.Method {
+	case "GET":
+		if org == "" {
+			writeResponse(w, a.rolloutMgr.GetRollouts(), http.StatusOK)
+		} else if rollout := a.rolloutMgr.GetRollout(policyName); rollout != nil {
+			writeResponse(w, rollout, http.StatusOK)
+		} else {
+			writeInputErr(w, http.StatusNotFound, &APIUserInputError{Input: "name", Error: fmt.Sprintf("there is no rollout for policy %v", policyName)})
+		}
+
+	case "POST":
+		glog.V(3).Infof(APIlogString(fmt.Sprintf("handling POST of rollout %v for policy %v", action, policyName)))
+
+		if !a.rolloutMgr.HasRollout(policyName) {
+			writeInputErr(w, http.StatusNotFound, &APIUserInputError{Input: "name", Error: fmt.Sprintf("there is no rollout for policy %v", policyName)})
+			return
+		}
+
+		var err error
+		switch action {
+		case "pause":
+			err = a.rolloutMgr.Pause(policyName)
+		case "resume":
+			err = a.rolloutMgr.Resume(policyName)
+		case "abort":
+			err = a.rolloutMgr.Abort(policyName)
+		default:
+			writeInputErr(w, http.StatusBadRequest, &APIUserInputError{Input: "action", Error: fmt.Sprintf("action %v is not supported, it must be pause, resume or abort", action)})
+			return
+		}
+
+		if err != nil {
+			writeInputErr(w, http.StatusConflict, &APIUserInputError{Input: "action", Error: err.Error()})
+		} else {
+			writeResponse(w, a.rolloutMgr.GetRollout(policyName), http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) status(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -638,7 +952,12 @@ func (a *API) status(w http.ResponseWriter, r *http.Request) {
 		}
 		info.LiveHealth = health
 
-		writeResponse(w, info, http.StatusOK)
+		status := &AgbotInfo{Info: info}
+		if status.Database, err = a.db.GetSchemaStatus(); err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("Unable to get DB schema status, error: %v", err)))
+		}
+
+		writeResponse(w, status, http.StatusOK)
 	case "OPTIONS":
 		w.Header().Set("Allow", "GET, OPTIONS")
 		w.WriteHeader(http.StatusOK)
@@ -647,6 +966,12 @@ func (a *API) status(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// The agbot status is the common status plus the state of the agbot's database schema.
+type AgbotInfo struct {
+	*apicommon.Info
+	Database *persistence.SchemaStatus `json:"database,omitempty"`
+}
+
 func (a *API) health(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -360,3 +360,18 @@ func (e ServedPolicyCommand) ShortString() string {
 func NewServedPolicyCommand() *ServedPolicyCommand {
 	return &ServedPolicyCommand{}
 }
+
+// ==============================================================================================================
+type WebhookEventCommand struct {
+	Event *WebhookEvent
+}
+
+func (e WebhookEventCommand) ShortString() string {
+	return fmt.Sprintf("WebhookEventCommand: %v", e.Event)
+}
+
+func NewWebhookEventCommand(ev *WebhookEvent) *WebhookEventCommand {
+	return &WebhookEventCommand{
+		Event: ev,
+	}
+}
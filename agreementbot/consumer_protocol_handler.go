@@ -17,11 +17,12 @@ import (
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/worker"
 	"net/http"
+	"reflect"
 	"time"
 )
 
-func CreateConsumerPH(name string, cfg *config.HorizonConfig, db persistence.AgbotDatabase, pm *policy.PolicyManager, msgq chan events.Message, mmsObjMgr *MMSObjectPolicyManager, secretsMgr secrets.AgbotSecrets) ConsumerProtocolHandler {
-	if handler := NewBasicProtocolHandler(name, cfg, db, pm, msgq, mmsObjMgr, secretsMgr); handler != nil {
+func CreateConsumerPH(name string, cfg *config.HorizonConfig, db persistence.AgbotDatabase, pm *policy.PolicyManager, msgq chan events.Message, mmsObjMgr *MMSObjectPolicyManager, secretsMgr secrets.AgbotSecrets, rolloutMgr *RolloutManager) ConsumerProtocolHandler {
+	if handler := NewBasicProtocolHandler(name, cfg, db, pm, msgq, mmsObjMgr, secretsMgr, rolloutMgr); handler != nil {
 		return handler
 	} // Add new consumer side protocol handlers here
 	return nil
@@ -92,6 +93,7 @@ type BaseConsumerProtocolHandler struct {
 	messages         chan events.Message
 	mmsObjMgr        *MMSObjectPolicyManager
 	secretsMgr       secrets.AgbotSecrets
+	rolloutMgr       *RolloutManager
 }
 
 func (b *BaseConsumerProtocolHandler) GetSendMessage() func(mt interface{}, pay []byte) error {
@@ -196,22 +198,14 @@ func (w *BaseConsumerProtocolHandler) sendMessage(mt interface{}, pay []byte) er
 		return errors.New(fmt.Sprintf("Unable to marshal exchange message, error %v for message %v", err, encryptedMsg))
 		// Send it to the device's message queue
 	} else {
-		pm := exchange.CreatePostMessage(msgBody, exchangeMessageTTL)
-		var resp interface{}
-		resp = new(exchange.PostDeviceResponse)
-		targetURL := w.config.AgreementBot.ExchangeURL + "orgs/" + exchange.GetOrg(messageTarget.ReceiverExchangeId) + "/nodes/" + exchange.GetId(messageTarget.ReceiverExchangeId) + "/msgs"
-		for {
-			if err, tpErr := exchange.InvokeExchange(w.httpClient, "POST", targetURL, w.agbotId, w.token, pm, &resp); err != nil {
-				return err
-			} else if tpErr != nil {
-				glog.Warningf(tpErr.Error())
-				time.Sleep(10 * time.Second)
-				continue
-			} else {
-				glog.V(5).Infof(BCPHlogstring(w.Name(), fmt.Sprintf("sent message for %v to exchange.", messageTarget.ReceiverExchangeId)))
-				return nil
-			}
+		t, err := exchange.GetAgbotMessageTransport(w.config, w.agbotId, w.token, exchange.RetryForeverHTTPFactory(w.GetHTTPFactory()))
+		if err != nil {
+			return err
+		} else if err := t.SendMessage(exchange.TRANSPORT_PARTY_NODE, messageTarget.ReceiverExchangeId, msgBody, exchangeMessageTTL); err != nil {
+			return errors.New(fmt.Sprintf("unable to send message to %v using the %v transport, error %v", messageTarget.ReceiverExchangeId, t.Name(), err))
 		}
+		glog.V(5).Infof(BCPHlogstring(w.Name(), fmt.Sprintf("sent message to %v.", messageTarget.ReceiverExchangeId)))
+		return nil
 	}
 
 }
@@ -285,9 +279,12 @@ func (b *BaseConsumerProtocolHandler) HandlePolicyChanged(cmd *PolicyChangedComm
 					continue
 				} else if err := b.pm.MatchesMine(cmd.Msg.Org(), pol); err != nil {
 					glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("agreement %v has a policy %v that has changed: %v", ag.CurrentAgreementId, pol.Header.Name, err)))
-					b.CancelAgreement(ag, TERM_REASON_POLICY_CHANGED, cph)
+					if !b.HoldWorkloadUpgrade(ag, pol, cmd.Msg.Org()) {
+						b.CancelAgreement(ag, TERM_REASON_POLICY_CHANGED, cph)
+					}
 				} else {
 					glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("for agreement %v, no policy content differences detected", ag.CurrentAgreementId)))
+					b.ReleaseHeldUpgrade(ag)
 				}
 
 			}
@@ -419,6 +416,127 @@ func (b *BaseConsumerProtocolHandler) CancelAgreement(ag persistence.Agreement,
 	}
 }
 
+// Check the upgrade policy of the workload that the device would be upgraded to because of a policy change. If the upgrade
+// policy does not allow the upgrade to happen now, the upgrade is held in the workload usage record and true is returned.
+// Only policy changes that change nothing but the version of the services are held, any other change needs a new agreement.
+func (b *BaseConsumerProtocolHandler) HoldWorkloadUpgrade(ag persistence.Agreement, agPol *policy.Policy, org string) bool {
+
+	newPol := b.pm.GetPolicy(org, agPol.Header.Name)
+	if newPol == nil {
+		return false
+	} else if !b.onlyWorkloadVersionChanged(org, agPol, newPol) {
+		glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("policy %v of agreement %v has changes other than the service version, the upgrade is not held", agPol.Header.Name, ag.CurrentAgreementId)))
+		return false
+	}
+
+	wlUsage, err := b.db.FindSingleWorkloadUsageByDeviceAndPolicyName(ag.DeviceId, ag.PolicyName)
+	if err != nil {
+		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error retreiving workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		return false
+	}
+
+	// Figure out which workload the device is running. When the policy has workload priorities, the workload usage
+	// record has the priority of the running workload.
+	current := agPol.NextHighestPriorityWorkload(0, 0, 0)
+	if wlUsage != nil && wlUsage.Priority != 0 {
+		for ix, wl := range agPol.Workloads {
+			if wl.Priority.PriorityValue == wlUsage.Priority {
+				current = &agPol.Workloads[ix]
+				break
+			}
+		}
+	}
+
+	next := newPol.NextHighestPriorityWorkload(0, 0, 0)
+	if current == nil || next == nil || current.WorkloadURL != next.WorkloadURL || current.Org != next.Org || current.Version == next.Version {
+		return false
+	}
+
+	now := time.Now()
+	upgrade := next.GetUpgrade()
+	held := &persistence.HeldUpgrade{
+		Version:   next.Version,
+		Lifecycle: upgrade.Lifecycle,
+		Time:      upgrade.Time,
+		HeldTime:  uint64(now.Unix()),
+	}
+
+	// The never and agreement lifecycles keep the running workload until the agreement ends, otherwise the upgrade waits
+	// for the upgrade time. An upgrade that can happen now waits for its turn in the rollout when the policy has one.
+	if !upgrade.IsPinned() {
+		if upgradeTime, err := upgrade.NextUpgradeTime(now); err != nil && newPol.Rollout == nil {
+			glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("unable to determine the upgrade time of %v for agreement %v, upgrading now, error: %v", next.ShortString(), ag.CurrentAgreementId, err)))
+			return false
+		} else if err == nil && upgradeTime.After(now) {
+			held.ScheduledTime = uint64(upgradeTime.Unix())
+		} else if newPol.Rollout == nil {
+			return false
+		} else {
+			held.Rollout = true
+		}
+	}
+
+	if wlUsage == nil {
+		err = b.db.NewHeldUpgradeWorkloadUsage(ag.DeviceId, ag.HAPartners, ag.PolicyName, ag.CurrentAgreementId, held)
+	} else {
+		_, err = b.db.UpdateHeldUpgrade(ag.DeviceId, ag.PolicyName, held)
+	}
+	if err != nil {
+		glog.Errorf(BCPHlogstring(b.Name(), fmt.Sprintf("unable to hold upgrade for %v using policy %v, upgrading now, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		return false
+	}
+
+	if held.Rollout {
+		b.rolloutMgr.AddDevice(org, ag.PolicyName, next.Version, *newPol.Rollout, ag.DeviceId, uint64(now.Unix()))
+	}
+
+	glog.V(3).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("holding upgrade of agreement %v to version %v, %v", ag.CurrentAgreementId, next.Version, held)))
+	return true
+}
+
+// Returns true when the only difference between the policy of an agreement and the changed policy is the version of its
+// workloads. The policy of the agreement is compared with the policy manager after its workload versions are set to the
+// new versions, so changes to constraints, properties, user input and the other parts of the policy are found. Secret
+// bindings are not checked by the policy manager, so they are compared here.
+func (b *BaseConsumerProtocolHandler) onlyWorkloadVersionChanged(org string, agPol *policy.Policy, newPol *policy.Policy) bool {
+	if len(agPol.Workloads) != len(newPol.Workloads) {
+		return false
+	}
+
+	samePol := agPol.DeepCopy()
+	samePol.Header = agPol.Header
+	for ix := range samePol.Workloads {
+		wl, newWl := &samePol.Workloads[ix], newPol.Workloads[ix]
+		if wl.WorkloadURL != newWl.WorkloadURL || wl.Org != newWl.Org || wl.Arch != newWl.Arch {
+			return false
+		}
+		wl.Version = newWl.Version
+	}
+
+	if err := b.pm.MatchesMine(org, samePol); err != nil {
+		return false
+	} else if len(agPol.SecretBinding) == 0 && len(newPol.SecretBinding) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(agPol.SecretBinding, newPol.SecretBinding)
+}
+
+// Remove a held upgrade from the workload usage record of an agreement whose policy no longer requires an upgrade. A
+// workload usage record that only exists to hold the upgrade is deleted.
+func (b *BaseConsumerProtocolHandler) ReleaseHeldUpgrade(ag persistence.Agreement) {
+	if wlUsage, err := b.db.FindSingleWorkloadUsageByDeviceAndPolicyName(ag.DeviceId, ag.PolicyName); err != nil {
+		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error retreiving workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+	} else if wlUsage == nil || wlUsage.HeldUpgrade == nil {
+		return
+	} else if wlUsage.Priority == 0 {
+		if err := b.db.DeleteWorkloadUsage(ag.DeviceId, ag.PolicyName); err != nil {
+			glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error deleting workload usage for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+		}
+	} else if _, err := b.db.UpdateHeldUpgrade(ag.DeviceId, ag.PolicyName, nil); err != nil {
+		glog.Warningf(BCPHlogstring(b.Name(), fmt.Sprintf("error clearing held upgrade for %v using policy %v, error: %v", ag.DeviceId, ag.PolicyName, err)))
+	}
+}
+
 func (b *BaseConsumerProtocolHandler) HandleWorkloadUpgrade(cmd *WorkloadUpgradeCommand, cph ConsumerProtocolHandler) {
 	glog.V(5).Infof(BCPHlogstring(b.Name(), fmt.Sprintf("received workload upgrade command.")))
 	upgradeWork := NewHandleWorkloadUpgrade(cmd.Msg.AgreementId, cmd.Msg.AgreementProtocol, cmd.Msg.DeviceId, cmd.Msg.PolicyName)
@@ -507,7 +625,7 @@ func (b *BaseConsumerProtocolHandler) RecordConsumerAgreementState(agreementId s
 
 func (b *BaseConsumerProtocolHandler) DeleteMessage(msgId int) error {
 
-	return DeleteMessage(msgId, b.agbotId, b.token, b.config.AgreementBot.ExchangeURL, b.httpClient)
+	return DeleteMessage(msgId, b.agbotId, b.token, b.config)
 
 }
 
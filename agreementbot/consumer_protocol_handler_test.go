@@ -0,0 +1,48 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+func Test_onlyWorkloadVersionChanged(t *testing.T) {
+
+	newPolicy := func(version string, constraints []string) *policy.Policy {
+		pol := policy.Policy_Factory("myorg/mypol")
+		pol.Workloads = []policy.Workload{policy.Workload{WorkloadURL: "http://mycompany.com/svc", Org: "myorg", Version: version, Arch: "amd64"}}
+		pol.Constraints = constraints
+		return pol
+	}
+
+	// The agreement was made with version 1.0.0 of the old policy.
+	agPol := newPolicy("1.0.0", []string{"zone == lab"})
+
+	// A secret binding is added along with the new version.
+	withSecret := newPolicy("2.0.0", []string{"zone == lab"})
+	withSecret.SecretBinding = []exchangecommon.SecretBinding{exchangecommon.SecretBinding{ServiceOrgid: "myorg", ServiceUrl: "http://mycompany.com/svc"}}
+
+	tests := []struct {
+		name     string
+		newPol   *policy.Policy
+		expected bool
+	}{
+		{"version only", newPolicy("2.0.0", []string{"zone == lab"}), true},
+		{"version and constraint", newPolicy("2.0.0", []string{"zone == prod"}), false},
+		{"constraint only", newPolicy("1.0.0", []string{"zone == prod"}), false},
+		{"version and secret binding", withSecret, false},
+	}
+
+	for _, test := range tests {
+		pm := policy.PolicyManager_Factory(false, false)
+		if err := pm.AddPolicy("myorg", test.newPol); err != nil {
+			t.Fatalf("unexpected error adding policy: %v", err)
+		}
+		b := &BaseConsumerProtocolHandler{name: "test", pm: pm}
+		if changed := b.onlyWorkloadVersionChanged("myorg", agPol, test.newPol); changed != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, changed)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package agreementbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/basicprotocol"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"sort"
+	"strings"
+)
+
+// The deployment status summarizes, for each deployment policy and pattern, the nodes that match it and how far each
+// of them got toward running the services. It is built from the exchange node search for the deployment, the agreements
+// and workload usages in all partitions of the database, the node heartbeats in the exchange and, when asked for, the
+// node errors in the exchange. A node is matched when the exchange node search finds it for the deployment, or when an
+// agbot has made an agreement attempt with it. Each matched node is in exactly one of the other states, or in none of
+// them if it has no agreement yet or its last agreement ended normally.
+
+const (
+	DEPLOYMENT_TYPE_POLICY  = "policy"
+	DEPLOYMENT_TYPE_PATTERN = "pattern"
+)
+
+const (
+	NODE_DEPLOYMENT_MATCHED   = "matched"   // The node has no agreement for the deployment right now
+	NODE_DEPLOYMENT_PROPOSED  = "proposed"  // An agreement was proposed to the node
+	NODE_DEPLOYMENT_AGREED    = "agreed"    // The node accepted the proposal
+	NODE_DEPLOYMENT_EXECUTING = "executing" // The agreement is finalized and the services are running on the node
+	NODE_DEPLOYMENT_FAILED    = "failed"    // The last agreement was terminated because of a problem, or the node reported errors
+)
+
+type NodeDeploymentStatus struct {
+	NodeId        string   `json:"node_id"`
+	State         string   `json:"state"`
+	AgreementId   string   `json:"agreement_id,omitempty"`
+	Services      []string `json:"services,omitempty"`
+	LastHeartbeat string   `json:"last_heartbeat,omitempty"`
+	Reasons       []string `json:"reasons,omitempty"`
+}
+
+type DeploymentStatus struct {
+	Name      string                  `json:"name"` // The org qualified name of the deployment policy or pattern
+	Type      string                  `json:"type"` // policy or pattern
+	Matched   int                     `json:"matched"`
+	Proposed  int                     `json:"proposed"`
+	Agreed    int                     `json:"agreed"`
+	Executing int                     `json:"executing"`
+	Failed    int                     `json:"failed"`
+	Nodes     []*NodeDeploymentStatus `json:"nodes,omitempty"`
+}
+
+func (d DeploymentStatus) String() string {
+	return fmt.Sprintf("Name: %v, Type: %v, Matched: %v, Proposed: %v, Agreed: %v, Executing: %v, Failed: %v", d.Name, d.Type, d.Matched, d.Proposed, d.Agreed, d.Executing, d.Failed)
+}
+
+// The termination reasons that are a normal part of the agreement lifecycle. A node whose last agreement ended for one
+// of these reasons is not failed, it is waiting for its next agreement.
+var normalTerminationReasons = map[uint]bool{
+	basicprotocol.CANCEL_POLICY_CHANGED:       true,
+	basicprotocol.CANCEL_USER_REQUESTED:       true,
+	basicprotocol.CANCEL_NODE_PATTERN_CHANGED: true,
+	basicprotocol.AB_CANCEL_POLICY_CHANGED:    true,
+	basicprotocol.AB_USER_REQUESTED:           true,
+	basicprotocol.AB_CANCEL_FORCED_UPGRADE:    true,
+}
+
+// Returns the type and name of the deployment that an agreement was made for.
+func agreementDeployment(ag *persistence.Agreement) (string, string) {
+	if ag.Pattern != "" {
+		return DEPLOYMENT_TYPE_PATTERN, ag.Pattern
+	}
+	return DEPLOYMENT_TYPE_POLICY, ag.PolicyName
+}
+
+func deploymentKey(depType string, name string) string {
+	return depType + ":" + name
+}
+
+// The state of a single node in a deployment.
+type nodeDeployment struct {
+	active *persistence.Agreement // The agreement that is not archived
+	last   *persistence.Agreement // The most recently terminated agreement
+}
+
+// Build the deployment status of every deployment policy and pattern found in the agreements and workload usages, and
+// in the matched nodes, which are the nodes found by the exchange node search keyed by deployment key. The cph is used to
+// tell whether a proposal has been accepted and the heartbeat function returns a node's last heartbeat, both can be nil.
+// If surfaceErrors is not nil, the node errors of executing nodes are read from the exchange.
+func buildDeploymentStatuses(ags []persistence.Agreement, wus []persistence.WorkloadUsage, matched map[string][]string, cph ConsumerProtocolHandler, heartbeat func(ag *persistence.Agreement) string, surfaceErrors exchange.SurfaceErrorsHandler) map[string]*DeploymentStatus {
+
+	// Group the agreements by deployment and then by node.
+	nodes := make(map[string]map[string]*nodeDeployment)
+	policyDeployment := make(map[string]string)
+	statuses := make(map[string]*DeploymentStatus)
+
+	getNode := func(depType string, name string, nodeId string) *nodeDeployment {
+		key := deploymentKey(depType, name)
+		if _, ok := statuses[key]; !ok {
+			statuses[key] = &DeploymentStatus{Name: name, Type: depType, Nodes: make([]*NodeDeploymentStatus, 0)}
+			nodes[key] = make(map[string]*nodeDeployment)
+		}
+		if _, ok := nodes[key][nodeId]; !ok {
+			nodes[key][nodeId] = &nodeDeployment{}
+		}
+		return nodes[key][nodeId]
+	}
+
+	for ix := range ags {
+		ag := &ags[ix]
+		depType, name := agreementDeployment(ag)
+		policyDeployment[ag.PolicyName] = deploymentKey(depType, name)
+		nd := getNode(depType, name, ag.DeviceId)
+		if !ag.Archived {
+			nd.active = ag
+		} else if nd.last == nil || ag.AgreementTimedout > nd.last.AgreementTimedout {
+			nd.last = ag
+		}
+	}
+
+	// Workload usages are kept by policy name. Pattern workload usages are found through the agreements made with the
+	// same policy name, otherwise the policy name is the name of a deployment policy.
+	for _, wu := range wus {
+		if key, ok := policyDeployment[wu.PolicyName]; ok {
+			pieces := strings.SplitN(key, ":", 2)
+			getNode(pieces[0], pieces[1], wu.DeviceId)
+		} else {
+			getNode(DEPLOYMENT_TYPE_POLICY, wu.PolicyName, wu.DeviceId)
+		}
+	}
+
+	for key, nodeIds := range matched {
+		pieces := strings.SplitN(key, ":", 2)
+		for _, nodeId := range nodeIds {
+			getNode(pieces[0], pieces[1], nodeId)
+		}
+	}
+
+	for key, status := range statuses {
+		for nodeId, nd := range nodes[key] {
+			ns := nodeDeploymentStatus(nodeId, nd, cph, heartbeat, surfaceErrors)
+			status.Nodes = append(status.Nodes, ns)
+			status.Matched += 1
+			switch ns.State {
+			case NODE_DEPLOYMENT_PROPOSED:
+				status.Proposed += 1
+			case NODE_DEPLOYMENT_AGREED:
+				status.Agreed += 1
+			case NODE_DEPLOYMENT_EXECUTING:
+				status.Executing += 1
+			case NODE_DEPLOYMENT_FAILED:
+				status.Failed += 1
+			}
+		}
+		sort.Slice(status.Nodes, func(i, j int) bool { return status.Nodes[i].NodeId < status.Nodes[j].NodeId })
+	}
+
+	return statuses
+}
+
+func nodeDeploymentStatus(nodeId string, nd *nodeDeployment, cph ConsumerProtocolHandler, heartbeat func(ag *persistence.Agreement) string, surfaceErrors exchange.SurfaceErrorsHandler) *NodeDeploymentStatus {
+	ns := &NodeDeploymentStatus{NodeId: nodeId, State: NODE_DEPLOYMENT_MATCHED, Reasons: make([]string, 0)}
+
+	ag := nd.active
+	if ag == nil {
+		ag = nd.last
+	}
+	if ag == nil {
+		return ns
+	}
+
+	ns.AgreementId = ag.CurrentAgreementId
+	ns.Services = ag.ServiceId
+	if heartbeat != nil {
+		ns.LastHeartbeat = heartbeat(ag)
+	}
+
+	// An agreement that is being terminated is treated like an archived agreement.
+	if ag.Archived && ag.TerminatedReason == 0 {
+		return ns
+	} else if ag.TerminatedReason != 0 {
+		if !normalTerminationReasons[ag.TerminatedReason] {
+			ns.State = NODE_DEPLOYMENT_FAILED
+		}
+		ns.Reasons = append(ns.Reasons, fmt.Sprintf("agreement terminated: %v", basicprotocol.DecodeReasonCode(uint64(ag.TerminatedReason))))
+		return ns
+	}
+
+	switch agreementState(ag, cph) {
+	case AGREEMENT_STATE_PROPOSED:
+		ns.State = NODE_DEPLOYMENT_PROPOSED
+	case AGREEMENT_STATE_AGREED:
+		ns.State = NODE_DEPLOYMENT_AGREED
+	case AGREEMENT_STATE_FINALIZED:
+		ns.State = NODE_DEPLOYMENT_EXECUTING
+	}
+
+	if ns.State == NODE_DEPLOYMENT_EXECUTING && surfaceErrors != nil {
+		if errs, err := surfaceErrors(nodeId); err != nil {
+			glog.Errorf(APIlogString(fmt.Sprintf("unable to get node errors for %v, error: %v", nodeId, err)))
+		} else if errs != nil {
+			for _, se := range errs.ErrorList {
+				if !se.Hidden && surfaceErrorInAgreement(se.Workload.URL, ag) {
+					ns.State = NODE_DEPLOYMENT_FAILED
+					ns.Reasons = append(ns.Reasons, se.Message)
+				}
+			}
+		}
+	}
+
+	return ns
+}
+
+// Node errors that are not about a service apply to all the agreements on the node.
+func surfaceErrorInAgreement(url string, ag *persistence.Agreement) bool {
+	if url == "" {
+		return true
+	}
+	prefix := cutil.FormExchangeIdWithSpecRef(url) + "_"
+	for _, sId := range ag.ServiceId {
+		if strings.HasPrefix(exchange.GetId(sId), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the deployment statuses ordered by type and name, without the node details.
+func deploymentStatusSummaries(statuses map[string]*DeploymentStatus) []DeploymentStatus {
+	res := make([]DeploymentStatus, 0, len(statuses))
+	for _, status := range statuses {
+		summary := *status
+		summary.Nodes = nil
+		res = append(res, summary)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Type != res[j].Type {
+			return res[i].Type < res[j].Type
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// Returns the agreements and workload usages of a single deployment.
+func filterDeployment(ags []persistence.Agreement, wus []persistence.WorkloadUsage, depType string, name string) ([]persistence.Agreement, []persistence.WorkloadUsage) {
+	depAgs := make([]persistence.Agreement, 0)
+	policyNames := make(map[string]bool)
+	if depType == DEPLOYMENT_TYPE_POLICY {
+		policyNames[name] = true
+	}
+
+	for _, ag := range ags {
+		if t, n := agreementDeployment(&ag); t == depType && n == name {
+			depAgs = append(depAgs, ag)
+			policyNames[ag.PolicyName] = true
+		}
+	}
+
+	depWus := make([]persistence.WorkloadUsage, 0)
+	for _, wu := range wus {
+		if policyNames[wu.PolicyName] {
+			depWus = append(depWus, wu)
+		}
+	}
+	return depAgs, depWus
+}
+
+// The exchange node search for the deployment status. The served patterns and deployment policies are read from the
+// exchange once for all the deployments in a status request.
+type deploymentNodeSearch struct {
+	servedPatterns map[string]exchange.ServedPattern
+	servedPolicies map[string]exchange.ServedBusinessPolicy
+	pattern        exchange.PatternHandler
+	patternSearch  exchange.AgbotPatternNodeSearchHandler
+	policySearch   exchange.AgbotPolicyNodeSearchHandler
+	secondsStale   int    // Nodes that have not heartbeated for this long are not found, the same as when making agreements
+	pageSize       uint64 // The number of nodes in each page of a deployment policy search
+	session        string // The search session of the deployment policy searches
+}
+
+// Returns the node orgs that the agbot serves a deployment policy or pattern to.
+func (s *deploymentNodeSearch) servedNodeOrgs(depType string, name string) []string {
+	org, id := exchange.GetOrg(name), exchange.GetId(name)
+	nodeOrgs := make([]string, 0)
+	if depType == DEPLOYMENT_TYPE_PATTERN {
+		for _, sp := range s.servedPatterns {
+			if sp.PatternOrg == org && (sp.Pattern == id || sp.Pattern == "*") && !stringSliceContains(nodeOrgs, sp.NodeOrg) {
+				nodeOrgs = append(nodeOrgs, sp.NodeOrg)
+			}
+		}
+	} else {
+		for _, sp := range s.servedPolicies {
+			if sp.BusinessPolOrg == org && (sp.BusinessPol == id || sp.BusinessPol == "*") && !stringSliceContains(nodeOrgs, sp.NodeOrg) {
+				nodeOrgs = append(nodeOrgs, sp.NodeOrg)
+			}
+		}
+	}
+	sort.Strings(nodeOrgs)
+	return nodeOrgs
+}
+
+// Returns the nodes that the exchange node search finds for a deployment policy or pattern, in the node orgs the agbot
+// serves it to. A pattern is searched once for each of its services. A deployment policy search is paged with its own
+// search session, so that it does not move the search sessions that the agbots use to make agreements. If the exchange
+// has another search session in progress for the policy, an error is returned.
+func (s *deploymentNodeSearch) nodes(depType string, name string) ([]string, error) {
+	found := make(map[string]bool)
+	nodeOrgs := s.servedNodeOrgs(depType, name)
+	if len(nodeOrgs) == 0 {
+		return []string{}, nil
+	}
+
+	if depType == DEPLOYMENT_TYPE_PATTERN {
+		pats, err := s.pattern(exchange.GetOrg(name), exchange.GetId(name))
+		if err != nil {
+			return nil, err
+		}
+		for _, pat := range pats {
+			for _, svc := range pat.Services {
+				ser := exchange.CreateSearchPatternRequest()
+				ser.SecondsStale = s.secondsStale
+				ser.NodeOrgIds = nodeOrgs
+				ser.ServiceURL = cutil.FormOrgSpecUrl(svc.ServiceURL, svc.ServiceOrg)
+				if svc.ServiceArch != "*" {
+					ser.Arch = svc.ServiceArch
+				}
+				devs, err := s.patternSearch(ser, exchange.GetOrg(name), name)
+				if err != nil {
+					return nil, err
+				}
+				for _, dev := range *devs {
+					found[dev.Id] = true
+				}
+			}
+		}
+	} else {
+		ser := &exchange.SearchExchBusinessPolRequest{NodeOrgIds: nodeOrgs, Session: s.session, NumEntries: s.pageSize}
+		for {
+			resp, err := s.policySearch(ser, exchange.GetOrg(name), exchange.GetId(name))
+			if err != nil {
+				return nil, err
+			} else if resp.Session != "" && resp.Session != s.session {
+				return nil, errors.New(fmt.Sprintf("the exchange has search session %v in progress for %v", resp.Session, name))
+			}
+			for _, dev := range resp.Devices {
+				found[dev.Id] = true
+			}
+			if uint64(len(resp.Devices)) != s.pageSize {
+				break
+			}
+		}
+	}
+
+	nodeIds := make([]string, 0, len(found))
+	for id := range found {
+		nodeIds = append(nodeIds, id)
+	}
+	sort.Strings(nodeIds)
+	return nodeIds, nil
+}
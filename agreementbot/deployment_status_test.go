@@ -0,0 +1,174 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/basicprotocol"
+	"github.com/open-horizon/anax/exchange"
+	anaxpersistence "github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+func Test_buildDeploymentStatuses(t *testing.T) {
+
+	svc := []string{"myorg/my.com.svc_1.0.0_amd64"}
+	ags := []persistence.Agreement{
+		// node1 has a proposal outstanding.
+		{CurrentAgreementId: "ag1", DeviceId: "myorg/node1", PolicyName: "myorg/pol", ServiceId: svc},
+		// node2 is executing and reports an error for the service, node3 is executing without errors.
+		{CurrentAgreementId: "ag2", DeviceId: "myorg/node2", PolicyName: "myorg/pol", ServiceId: svc, AgreementFinalizedTime: 10},
+		{CurrentAgreementId: "ag3", DeviceId: "myorg/node3", PolicyName: "myorg/pol", ServiceId: svc, AgreementFinalizedTime: 10},
+		// node4's last agreement was terminated because no data was received, an older agreement ended normally.
+		{CurrentAgreementId: "ag4a", DeviceId: "myorg/node4", PolicyName: "myorg/pol", ServiceId: svc, Archived: true, TerminatedReason: basicprotocol.AB_CANCEL_POLICY_CHANGED, AgreementTimedout: 5},
+		{CurrentAgreementId: "ag4b", DeviceId: "myorg/node4", PolicyName: "myorg/pol", ServiceId: svc, Archived: true, TerminatedReason: basicprotocol.AB_CANCEL_NO_DATA_RECEIVED, AgreementTimedout: 6},
+		// node5's agreement ended because the policy changed.
+		{CurrentAgreementId: "ag5", DeviceId: "myorg/node5", PolicyName: "myorg/pol", ServiceId: svc, Archived: true, TerminatedReason: basicprotocol.AB_CANCEL_POLICY_CHANGED},
+		// A pattern agreement, made with a generated policy name.
+		{CurrentAgreementId: "ag7", DeviceId: "myorg/node7", PolicyName: "myorg_pat_amd64", Pattern: "myorg/pat", ServiceId: svc, AgreementFinalizedTime: 10},
+	}
+	wus := []persistence.WorkloadUsage{
+		// node6 has no agreement yet.
+		{DeviceId: "myorg/node6", PolicyName: "myorg/pol"},
+		{DeviceId: "myorg/node7", PolicyName: "myorg_pat_amd64"},
+	}
+
+	surfaceErrors := func(deviceId string) (*exchange.ExchangeSurfaceError, error) {
+		errs := &exchange.ExchangeSurfaceError{ErrorList: []anaxpersistence.SurfaceError{
+			{Message: "hidden error", Hidden: true},
+			{Message: "other service error", Workload: anaxpersistence.WorkloadInfo{URL: "my.com.other"}},
+		}}
+		if deviceId == "myorg/node2" {
+			errs.ErrorList = append(errs.ErrorList, anaxpersistence.SurfaceError{Message: "image pull failed", Workload: anaxpersistence.WorkloadInfo{URL: "my.com.svc"}})
+		}
+		return errs, nil
+	}
+	heartbeat := func(ag *persistence.Agreement) string { return "now" }
+
+	// node8 is found by the exchange node search but the agbot has not worked with it yet.
+	matched := map[string][]string{deploymentKey(DEPLOYMENT_TYPE_POLICY, "myorg/pol"): {"myorg/node3", "myorg/node8"}}
+
+	statuses := buildDeploymentStatuses(ags, wus, matched, nil, heartbeat, surfaceErrors)
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 deployments, got %v", statuses)
+	}
+
+	pol := statuses[deploymentKey(DEPLOYMENT_TYPE_POLICY, "myorg/pol")]
+	if pol == nil {
+		t.Fatalf("expected a status for the deployment policy, got %v", statuses)
+	} else if pol.Matched != 7 || pol.Proposed != 1 || pol.Agreed != 0 || pol.Executing != 1 || pol.Failed != 2 {
+		t.Errorf("unexpected deployment policy status %v", pol)
+	}
+
+	expected := []struct {
+		nodeId  string
+		state   string
+		reasons int
+	}{
+		{"myorg/node1", NODE_DEPLOYMENT_PROPOSED, 0},
+		{"myorg/node2", NODE_DEPLOYMENT_FAILED, 1},
+		{"myorg/node3", NODE_DEPLOYMENT_EXECUTING, 0},
+		{"myorg/node4", NODE_DEPLOYMENT_FAILED, 1},
+		{"myorg/node5", NODE_DEPLOYMENT_MATCHED, 1},
+		{"myorg/node6", NODE_DEPLOYMENT_MATCHED, 0},
+		{"myorg/node8", NODE_DEPLOYMENT_MATCHED, 0},
+	}
+	if len(pol.Nodes) != len(expected) {
+		t.Fatalf("expected %v nodes, got %v", len(expected), len(pol.Nodes))
+	}
+	for ix, exp := range expected {
+		ns := pol.Nodes[ix]
+		if ns.NodeId != exp.nodeId || ns.State != exp.state || len(ns.Reasons) != exp.reasons {
+			t.Errorf("expected node %v to be %v with %v reasons, got %+v", exp.nodeId, exp.state, exp.reasons, ns)
+		}
+	}
+	if pol.Nodes[3].AgreementId != "ag4b" {
+		t.Errorf("expected the latest agreement for node4, got %v", pol.Nodes[3].AgreementId)
+	} else if pol.Nodes[1].LastHeartbeat != "now" {
+		t.Errorf("expected the heartbeat to be set, got %+v", pol.Nodes[1])
+	}
+
+	pat := statuses[deploymentKey(DEPLOYMENT_TYPE_PATTERN, "myorg/pat")]
+	if pat == nil {
+		t.Fatalf("expected a status for the pattern, got %v", statuses)
+	} else if pat.Matched != 1 || pat.Executing != 1 {
+		t.Errorf("unexpected pattern status %v", pat)
+	}
+
+	// Summaries are ordered and leave out the nodes.
+	summaries := deploymentStatusSummaries(statuses)
+	if len(summaries) != 2 || summaries[0].Type != DEPLOYMENT_TYPE_PATTERN || summaries[1].Name != "myorg/pol" || summaries[0].Nodes != nil {
+		t.Errorf("unexpected summaries %v", summaries)
+	}
+
+	// Filtering a deployment keeps only its agreements and workload usages.
+	if fAgs, fWus := filterDeployment(ags, wus, DEPLOYMENT_TYPE_PATTERN, "myorg/pat"); len(fAgs) != 1 || len(fWus) != 1 {
+		t.Errorf("unexpected pattern filter result %v %v", fAgs, fWus)
+	}
+}
+
+func Test_deploymentNodeSearch(t *testing.T) {
+
+	pages := 0
+
+	search := &deploymentNodeSearch{
+		servedPatterns: map[string]exchange.ServedPattern{
+			"myorg_pat_myorg": {PatternOrg: "myorg", Pattern: "pat", NodeOrg: "myorg"},
+			"myorg_*_other":   {PatternOrg: "myorg", Pattern: "*", NodeOrg: "other"},
+		},
+		servedPolicies: map[string]exchange.ServedBusinessPolicy{
+			"myorg_pol_myorg": {BusinessPolOrg: "myorg", BusinessPol: "pol", NodeOrg: "myorg"},
+		},
+		pattern: func(org string, pattern string) (map[string]exchange.Pattern, error) {
+			return map[string]exchange.Pattern{"myorg/pat": {Services: []exchange.ServiceReference{
+				{ServiceURL: "my.com.svc", ServiceOrg: "myorg", ServiceArch: "amd64"},
+				{ServiceURL: "my.com.svc", ServiceOrg: "myorg", ServiceArch: "arm64"},
+			}}}, nil
+		},
+		patternSearch: func(req *exchange.SearchExchangePatternRequest, policyOrg string, patternId string) (*[]exchange.SearchResultDevice, error) {
+			if len(req.NodeOrgIds) != 2 || req.ServiceURL != "myorg/my.com.svc" || req.SecondsStale != 60 {
+				t.Errorf("unexpected pattern search request %v", req)
+			}
+			devs := []exchange.SearchResultDevice{{Id: "myorg/node1"}, {Id: "other/node2_" + req.Arch}}
+			return &devs, nil
+		},
+		policySearch: func(req *exchange.SearchExchBusinessPolRequest, policyOrg string, policyName string) (*exchange.SearchExchBusinessPolResponse, error) {
+			if req.Session != "status1" || req.ChangedSince != 0 || policyName != "pol" {
+				t.Errorf("unexpected policy search request %v for %v", req, policyName)
+			}
+			// The first page is full, the second page is the last one.
+			if req.NumEntries != 2 {
+				return &exchange.SearchExchBusinessPolResponse{Session: "42"}, nil
+			} else if pages += 1; pages == 1 {
+				return &exchange.SearchExchBusinessPolResponse{Devices: []exchange.SearchResultDevice{{Id: "myorg/node3"}, {Id: "myorg/node4"}}}, nil
+			}
+			return &exchange.SearchExchBusinessPolResponse{Devices: []exchange.SearchResultDevice{{Id: "myorg/node3"}}}, nil
+		},
+		secondsStale: 60,
+		pageSize:     2,
+		session:      "status1",
+	}
+
+	if nodeIds, err := search.nodes(DEPLOYMENT_TYPE_PATTERN, "myorg/pat"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if len(nodeIds) != 3 || nodeIds[0] != "myorg/node1" || nodeIds[1] != "other/node2_amd64" {
+		t.Errorf("unexpected pattern nodes %v", nodeIds)
+	}
+
+	if nodeIds, err := search.nodes(DEPLOYMENT_TYPE_POLICY, "myorg/pol"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if len(nodeIds) != 2 || nodeIds[0] != "myorg/node3" || nodeIds[1] != "myorg/node4" || pages != 2 {
+		t.Errorf("unexpected policy nodes %v", nodeIds)
+	}
+
+	// The search session of the agbots is not taken over.
+	search.pageSize = 3
+	if _, err := search.nodes(DEPLOYMENT_TYPE_POLICY, "myorg/pol"); err == nil {
+		t.Errorf("expected an error when the exchange has another search session")
+	}
+
+	// A deployment that is not served has no nodes.
+	if nodeIds, err := search.nodes(DEPLOYMENT_TYPE_POLICY, "myorg/other"); err != nil || len(nodeIds) != 0 {
+		t.Errorf("unexpected nodes %v, error %v", nodeIds, err)
+	}
+}
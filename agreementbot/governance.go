@@ -96,6 +96,7 @@ func (w *AgreementBotWorker) GovernAgreements() int {
 					now := uint64(time.Now().Unix())
 					if ag.AgreementCreationTime+timeout < now {
 						w.nodeSearch.AddRetry(ag.PolicyName, ag.AgreementCreationTime-w.BaseWorker.Manager.Config.GetAgbotRetryLookBackWindow())
+						proposalCounter.Inc(agp, PROPOSAL_TIMED_OUT)
 						w.TerminateAgreement(&ag, protocolHandler.GetTerminationCode(TERM_REASON_NO_REPLY))
 					}
 				}
@@ -210,6 +211,12 @@ func (w *AgreementBotWorker) GovernAgreements() int {
 	// Govern the HA partners by examining workload usage records.
 	w.governHAPartners()
 
+	// Perform the workload upgrades that were held until their upgrade time.
+	w.governHeldUpgrades()
+
+	// Release the next batch of each staged rollout when it is time.
+	w.governRollouts()
+
 	// Dynamically adjust skips to account for long NH check rates.
 	if w.GovTiming.nhSkip == 0 {
 		w.GovTiming.nhSkip = calculateSkipTime(discoveredNHWaitTime, w.BaseWorker.Manager.Config.AgreementBot.ProcessGovernanceIntervalS)
@@ -334,6 +341,228 @@ func (w *AgreementBotWorker) governHAPartners() {
 	}
 }
 
+// Workload upgrades that are held back by the time in an upgrade policy are recorded in the workload usage record of the
+// device, along with the time when the upgrade is allowed. When that time arrives, the agreement is cancelled so that a new
+// agreement will be made with the newer workload. Upgrades that are held by the never or agreement lifecycles have no
+// scheduled time, they happen when the current agreement ends.
+func (w *AgreementBotWorker) governHeldUpgrades() {
+
+	glog.V(5).Infof(logString(fmt.Sprintf("checking for held workload upgrades that are due.")))
+
+	now := time.Now()
+
+	DueUpgradeWUFilter := func() persistence.WUFilter {
+		return func(a persistence.WorkloadUsage) bool {
+			return a.HeldUpgrade != nil && a.HeldUpgrade.ScheduledTime != 0 && a.HeldUpgrade.ScheduledTime <= uint64(now.Unix())
+		}
+	}
+
+	upgrades, err := w.db.FindWorkloadUsages([]persistence.WUFilter{DueUpgradeWUFilter()})
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("error searching for held workload upgrades, error: %v", err)))
+		return
+	}
+
+	for _, due := range upgrades {
+
+		// Read the record again, an HA partner upgraded earlier in this loop might have changed it.
+		wlu, err := w.db.FindSingleWorkloadUsageByDeviceAndPolicyName(due.DeviceId, due.PolicyName)
+		if err != nil {
+			glog.Errorf(logString(fmt.Sprintf("error obtaining workload usage record for device %v and policy %v, error: %v", due.DeviceId, due.PolicyName, err)))
+			continue
+		} else if wlu == nil || wlu.HeldUpgrade == nil {
+			continue
+		} else if len(wlu.HAPartners) != 0 && wlu.PendingUpgradeTime != 0 {
+			// HA partners pending an upgrade are upgraded one at a time by the HA partner governance.
+			continue
+		}
+
+		// The agbot might not have been running during the upgrade window, so make sure the upgrade is still allowed.
+		upgrade := policy.Workload_Upgrade_Factory(wlu.HeldUpgrade.Lifecycle, wlu.HeldUpgrade.Time)
+		if nextTime, err := upgrade.NextUpgradeTime(now); err != nil {
+			glog.Warningf(logString(fmt.Sprintf("unable to determine the upgrade time for device %v and policy %v, upgrading now, error: %v", wlu.DeviceId, wlu.PolicyName, err)))
+		} else if nextTime.After(now) {
+			wlu.HeldUpgrade.ScheduledTime = uint64(nextTime.Unix())
+			if _, err := w.db.UpdateHeldUpgrade(wlu.DeviceId, wlu.PolicyName, wlu.HeldUpgrade); err != nil {
+				glog.Errorf(logString(fmt.Sprintf("error rescheduling held upgrade for device %v and policy %v, error: %v", wlu.DeviceId, wlu.PolicyName, err)))
+			}
+			continue
+		}
+
+		// When the policy has a rollout strategy, the device waits for its turn in the rollout.
+		if pol := w.pm.GetPolicy(exchange.GetOrg(wlu.PolicyName), wlu.PolicyName); pol != nil && pol.Rollout != nil {
+			wlu.HeldUpgrade.ScheduledTime = 0
+			wlu.HeldUpgrade.Rollout = true
+			if _, err := w.db.UpdateHeldUpgrade(wlu.DeviceId, wlu.PolicyName, wlu.HeldUpgrade); err != nil {
+				glog.Errorf(logString(fmt.Sprintf("error adding held upgrade for device %v and policy %v to the rollout, error: %v", wlu.DeviceId, wlu.PolicyName, err)))
+			} else {
+				w.rolloutMgr.AddDevice(exchange.GetOrg(wlu.PolicyName), wlu.PolicyName, wlu.HeldUpgrade.Version, *pol.Rollout, wlu.DeviceId, uint64(now.Unix()))
+			}
+			continue
+		}
+
+		glog.V(3).Infof(logString(fmt.Sprintf("beginning held upgrade of %v to version %v using policy %v.", wlu.DeviceId, wlu.HeldUpgrade.Version, wlu.PolicyName)))
+		w.upgradeHeldWorkload(wlu)
+	}
+}
+
+// Start the upgrade of a device whose upgrade was held, by removing its workload usage record and cancelling its agreement.
+func (w *AgreementBotWorker) upgradeHeldWorkload(wlu *persistence.WorkloadUsage) {
+
+	// The other members of an HA group wait until this device has upgraded.
+	for _, partnerId := range wlu.HAPartners {
+		if _, err := w.db.UpdatePendingUpgrade(partnerId, wlu.PolicyName); err != nil {
+			glog.Warningf(logString(fmt.Sprintf("could not update pending workload upgrade for %v using policy %v, error: %v", partnerId, wlu.PolicyName, err)))
+		}
+	}
+
+	// Make sure the workload usage record is gone, this will allow the device to pick up the newest workload.
+	if err := w.db.DeleteWorkloadUsage(wlu.DeviceId, wlu.PolicyName); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("error deleting workload usage for %v using policy %v, error: %v", wlu.DeviceId, wlu.PolicyName, err)))
+	}
+
+	// Cancel the agreement if there is one
+	if ag, err := w.db.FindSingleAgreementByAgreementIdAllProtocols(wlu.CurrentAgreementId, policy.AllAgreementProtocols(), []persistence.AFilter{persistence.UnarchivedAFilter()}); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read agreement %v from database, error: %v", wlu.CurrentAgreementId, err)))
+	} else if ag == nil {
+		glog.V(5).Infof(logString(fmt.Sprintf("agreement for %v already terminated.", wlu.DeviceId)))
+	} else if ag.AgreementTimedout == 0 {
+		w.TerminateAgreement(ag, w.consumerPH.Get(ag.AgreementProtocol).GetTerminationCode(TERM_REASON_POLICY_CHANGED))
+	}
+}
+
+// Move the staged rollouts of workload upgrades forward. The devices released in the current batch are checked to see
+// if they made an agreement with the upgraded workload, and the next batch is released when the rollout allows it.
+func (w *AgreementBotWorker) governRollouts() {
+
+	glog.V(5).Infof(logString(fmt.Sprintf("checking staged rollouts of workload upgrades.")))
+
+	now := uint64(time.Now().Unix())
+
+	held, err := w.db.FindWorkloadUsages([]persistence.WUFilter{persistence.RolloutWUFilter()})
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("error searching for workload upgrades held for a rollout, error: %v", err)))
+		return
+	}
+
+	// Group the held upgrades by policy.
+	heldByPolicy := make(map[string][]persistence.WorkloadUsage)
+	for _, wlu := range held {
+		heldByPolicy[wlu.PolicyName] = append(heldByPolicy[wlu.PolicyName], wlu)
+	}
+
+	for policyName, wlus := range heldByPolicy {
+
+		// An aborted rollout leaves the devices that were not released on their current workload until the agreement ends.
+		if r := w.rolloutMgr.GetRollout(policyName); r != nil && r.State == ROLLOUT_STATE_ABORTED {
+			for _, wlu := range wlus {
+				wlu.HeldUpgrade.Rollout = false
+				wlu.HeldUpgrade.Lifecycle = policy.UPGRADE_LIFECYCLE_AGREEMENT
+				if _, err := w.db.UpdateHeldUpgrade(wlu.DeviceId, wlu.PolicyName, wlu.HeldUpgrade); err != nil {
+					glog.Errorf(logString(fmt.Sprintf("error holding aborted upgrade for device %v and policy %v, error: %v", wlu.DeviceId, wlu.PolicyName, err)))
+				}
+			}
+			continue
+		}
+
+		// If the rollout strategy was removed from the policy, the held devices are upgraded now.
+		pol := w.pm.GetPolicy(exchange.GetOrg(policyName), policyName)
+		if pol == nil || pol.Rollout == nil {
+			for ix := range wlus {
+				glog.V(3).Infof(logString(fmt.Sprintf("policy %v no longer has a rollout, beginning upgrade of %v.", policyName, wlus[ix].DeviceId)))
+				w.upgradeHeldWorkload(&wlus[ix])
+			}
+			continue
+		}
+
+		// Make sure the rollout knows about all the held devices, the rollout is rebuilt this way when the agbot restarts.
+		deviceIds := []string{}
+		for _, wlu := range wlus {
+			w.rolloutMgr.AddDevice(exchange.GetOrg(policyName), policyName, wlu.HeldUpgrade.Version, *pol.Rollout, wlu.DeviceId, now)
+			deviceIds = append(deviceIds, wlu.DeviceId)
+		}
+		w.rolloutMgr.SetPending(policyName, deviceIds)
+	}
+
+	for policyName, r := range w.rolloutMgr.GetRollouts() {
+
+		if r.IsFinished() {
+			continue
+		} else if _, ok := heldByPolicy[policyName]; !ok {
+			w.rolloutMgr.SetPending(policyName, []string{})
+		}
+
+		// Check the outcome of the upgrade of each released device.
+		for deviceId, releaseTime := range r.Upgrading {
+			if upgraded, done := w.checkRolloutUpgrade(deviceId, policyName, releaseTime, r.Strategy.GetFailureTimeoutS(), now); done {
+				w.rolloutMgr.SetResult(policyName, deviceId, upgraded, now)
+			}
+		}
+
+		for _, deviceId := range w.rolloutMgr.NextBatch(policyName, w.countPolicyDevices(policyName, &r), now) {
+			if wlu, err := w.db.FindSingleWorkloadUsageByDeviceAndPolicyName(deviceId, policyName); err != nil {
+				glog.Errorf(logString(fmt.Sprintf("error obtaining workload usage record for device %v and policy %v, error: %v", deviceId, policyName, err)))
+			} else if wlu != nil {
+				glog.V(3).Infof(logString(fmt.Sprintf("beginning rollout upgrade of %v to version %v using policy %v.", deviceId, r.Version, policyName)))
+				w.upgradeHeldWorkload(wlu)
+			}
+		}
+	}
+}
+
+// Count the devices matched by a policy, which are the devices using the policy and the devices released by its
+// rollout that have not made a new agreement yet. Only the devices this agbot has agreements with are counted.
+func (w *AgreementBotWorker) countPolicyDevices(policyName string, r *Rollout) int {
+
+	devices := make(map[string]bool)
+	if wlus, err := w.db.FindWorkloadUsages([]persistence.WUFilter{persistence.PWUFilter(policyName)}); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("error searching for workload usages of policy %v, error: %v", policyName, err)))
+	} else {
+		for _, wlu := range wlus {
+			devices[wlu.DeviceId] = true
+		}
+	}
+
+	for deviceId := range r.Upgrading {
+		devices[deviceId] = true
+	}
+	for _, list := range [][]string{r.Upgraded, r.Failed} {
+		for _, deviceId := range list {
+			devices[deviceId] = true
+		}
+	}
+	return len(devices)
+}
+
+// Determine whether a device released by a rollout has upgraded. The device has upgraded when it has made an agreement
+// since it was released. The upgrade has failed when an agreement attempt made since the release has ended, or when no
+// agreement was made within the failure timeout. The second return value is false while the outcome is not known.
+func (w *AgreementBotWorker) checkRolloutUpgrade(deviceId string, policyName string, releaseTime uint64, timeoutS int, now uint64) (bool, bool) {
+
+	attempted := false
+	for _, agp := range policy.AllAgreementProtocols() {
+		if ags, err := w.db.FindAgreements([]persistence.AFilter{persistence.DevPolAFilter(deviceId, policyName)}, agp); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to read agreements for device %v and policy %v from database, error: %v", deviceId, policyName, err)))
+			return false, false
+		} else {
+			for _, ag := range ags {
+				if ag.AgreementInceptionTime < releaseTime {
+					continue
+				} else if !ag.Archived && ag.AgreementTimedout == 0 && ag.AgreementCreationTime != 0 {
+					return true, true
+				} else if ag.Archived || ag.AgreementTimedout != 0 {
+					attempted = true
+				}
+			}
+		}
+	}
+
+	if attempted || now > releaseTime+uint64(timeoutS) {
+		return false, true
+	}
+	return false, false
+}
+
 // This function is used to determine if a device is actively trying to make an agreement. This is important to know because
 // a device in an HA group that is in the midst of making an agreement will prevent the agbot from upgrading other HA
 // partners. This function also considers the possibility that an HA partner has stopped heart beating (because it died), and
@@ -406,8 +635,14 @@ func (w *AgreementBotWorker) VerifyNodeHealth(ag *persistence.Agreement, cph Con
 	}
 
 	// If this agreement's node is out of policy, cancel the agreement and remove the node from the cache.
-	// If the agreement is missing, cancel it.
-	if w.NHManager.NodeOutOfPolicy(ag.Pattern, ag.Org, ag.DeviceId, ag.NHMissingHBInterval) {
+	// If the agreement is missing, cancel it. The heartbeat failure is only reported when the node stops
+	// heartbeating, not for each of its agreements on every pass.
+	nodeOutOfPolicy := w.NHManager.NodeOutOfPolicy(ag.Pattern, ag.Org, ag.DeviceId, ag.NHMissingHBInterval)
+	newFailure := w.NHManager.SetNodeHeartbeatFailed(ag.DeviceId, nodeOutOfPolicy) && nodeOutOfPolicy
+	if nodeOutOfPolicy {
+		if newFailure {
+			w.Messages() <- events.NewNodeHeartbeatStateChangeMessage(events.NODE_HEARTBEAT_FAILED, exchange.GetOrg(ag.DeviceId), exchange.GetId(ag.DeviceId))
+		}
 		w.TerminateAgreement(ag, cph.GetTerminationCode(TERM_REASON_NODE_HEARTBEAT))
 	} else if w.NHManager.AgreementOutOfPolicy(ag.Pattern, ag.Org, ag.DeviceId, ag.CurrentAgreementId, ag.AgreementFinalizedTime, ag.NHCheckAgreementStatus) {
 		w.TerminateAgreement(ag, cph.GetTerminationCode(TERM_REASON_AG_MISSING))
@@ -488,13 +723,18 @@ func (w *AgreementBotWorker) GovernArchivedAgreements() int {
 	for _, agp := range policy.AllAgreementProtocols() {
 		now := time.Now().Unix()
 		if agreements, err := w.db.FindAgreements([]persistence.AFilter{persistence.ArchivedAFilter(), agedOutFilter(now, ageLimit)}, agp); err == nil {
+			deleted := 0
 			for _, ag := range agreements {
 				if err := w.db.DeleteAgreement(ag.CurrentAgreementId, agp); err != nil {
 					glog.Error(logString(fmt.Sprintf("error deleting archived agreement %v, error: %v", ag.CurrentAgreementId, err)))
 				} else {
+					deleted += 1
 					glog.V(3).Infof(logString(fmt.Sprintf("archive purge deleted %v", ag.CurrentAgreementId)))
 				}
 			}
+			if deleted != 0 {
+				glog.Infof(logString(fmt.Sprintf("archive purge deleted %v %v agreement(s) archived more than %v hour(s) ago.", deleted, agp, ageLimit)))
+			}
 
 		} else {
 			glog.Errorf(logString(fmt.Sprintf("unable to read archived agreements from database for protocol %v, error: %v", agp, err)))
@@ -0,0 +1,160 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/agreementbot/secrets"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/metrics"
+	"sort"
+)
+
+// The metrics served by the agbot /metrics API. The counters are updated as the agbot runs, the rest of the metrics
+// are computed from the database and the agbot's internal state when they are scraped.
+var agbotMetrics = metrics.NewRegistry()
+
+// Agreement proposal events.
+const (
+	PROPOSAL_SENT      = "sent"
+	PROPOSAL_ACCEPTED  = "accepted"
+	PROPOSAL_REJECTED  = "rejected"
+	PROPOSAL_TIMED_OUT = "timed_out"
+)
+
+var proposalCounter = metrics.NewCounterVec("anax_agbot_proposals_total",
+	"The number of agreement proposals sent to nodes, and the number that were accepted, rejected or timed out.",
+	"protocol", "event")
+
+// The states of an agreement that has not been archived.
+const (
+	AGREEMENT_STATE_PROPOSED    = "proposed"
+	AGREEMENT_STATE_AGREED      = "agreed"
+	AGREEMENT_STATE_FINALIZED   = "finalized"
+	AGREEMENT_STATE_TERMINATING = "terminating"
+)
+
+func init() {
+	agbotMetrics.Register(proposalCounter, exchange.ExchangeRequestDuration, exchange.ExchangeRequestErrors)
+}
+
+// Register the metrics that are computed from the state of the agreement bot worker.
+func registerWorkerMetrics(db persistence.AgbotDatabase, consumerPH *ConsumerPHMgr, secretProvider secrets.AgbotSecrets) {
+
+	agbotMetrics.Register(
+		metrics.NewGaugeFunc("anax_agbot_agreements",
+			"The number of agreements that are not archived, by state and policy.",
+			[]string{"protocol", "state", "policy"},
+			func() []metrics.Sample { return agreementSamples(db, consumerPH) }),
+		metrics.NewGaugeFunc("anax_agbot_work_queue_depth",
+			"The number of requests buffered in the agreement work queue.",
+			[]string{"protocol", "priority"},
+			func() []metrics.Sample { return workQueueDepthSamples(consumerPH) }),
+		metrics.NewCounterFunc("anax_agbot_work_queue_requests_total",
+			"The number of requests that have entered (in) and left (out) the agreement work queue.",
+			[]string{"protocol", "priority", "direction"},
+			func() []metrics.Sample { return workQueueTotalSamples(consumerPH) }),
+		metrics.NewGaugeFunc("anax_agbot_partition_owner",
+			"The database partitions and the agbot that owns each of them, the value is always 1.",
+			[]string{"partition", "owner"},
+			func() []metrics.Sample { return partitionSamples(db) }),
+		metrics.NewGaugeFunc("anax_agbot_secrets_provider_ready",
+			"1 if the secrets provider is ready to handle requests, 0 otherwise.",
+			[]string{},
+			func() []metrics.Sample {
+				return []metrics.Sample{{Value: metrics.BoolToFloat(secretProvider != nil && secretProvider.IsReady())}}
+			}),
+		metrics.NewGaugeFunc("anax_agbot_secrets_provider_last_interaction_timestamp_seconds",
+			"The last time the agbot successfully interacted with the secrets provider, in seconds since the epoch.",
+			[]string{},
+			func() []metrics.Sample {
+				if secretProvider == nil {
+					return []metrics.Sample{}
+				}
+				return []metrics.Sample{{Value: float64(secretProvider.GetLastVaultStatus())}}
+			}),
+	)
+}
+
+// Returns the state of an agreement that has not been archived.
+func agreementState(ag *persistence.Agreement, cph ConsumerProtocolHandler) string {
+	if ag.TerminatedReason != 0 {
+		return AGREEMENT_STATE_TERMINATING
+	} else if ag.AgreementFinalizedTime != 0 {
+		return AGREEMENT_STATE_FINALIZED
+	} else if cph != nil && cph.AlreadyReceivedReply(ag) {
+		return AGREEMENT_STATE_AGREED
+	}
+	return AGREEMENT_STATE_PROPOSED
+}
+
+func agreementSamples(db persistence.AgbotDatabase, consumerPH *ConsumerPHMgr) []metrics.Sample {
+	samples := make([]metrics.Sample, 0)
+	protocols := consumerPH.GetAll()
+	sort.Strings(protocols)
+	for _, protocol := range protocols {
+		agreements, err := db.FindAgreements([]persistence.AFilter{persistence.UnarchivedAFilter()}, protocol)
+		if err != nil {
+			glog.Errorf(metricsLogString(fmt.Sprintf("unable to read %v agreements, error: %v", protocol, err)))
+			continue
+		}
+
+		cph := consumerPH.Get(protocol)
+		counts := make(map[[2]string]int)
+		for ix := range agreements {
+			counts[[2]string{agreementState(&agreements[ix], cph), agreements[ix].PolicyName}] += 1
+		}
+		for key, count := range counts {
+			samples = append(samples, metrics.Sample{LabelValues: []string{protocol, key[0], key[1]}, Value: float64(count)})
+		}
+	}
+	return samples
+}
+
+func workQueueDepthSamples(consumerPH *ConsumerPHMgr) []metrics.Sample {
+	samples := make([]metrics.Sample, 0)
+	for _, protocol := range consumerPH.GetAll() {
+		if cph := consumerPH.Get(protocol); cph != nil && cph.WorkQueue() != nil {
+			samples = append(samples,
+				metrics.Sample{LabelValues: []string{protocol, HIGH_PRIORITY}, Value: float64(cph.WorkQueue().HighPriorityBufferLen())},
+				metrics.Sample{LabelValues: []string{protocol, LOW_PRIORITY}, Value: float64(cph.WorkQueue().LowPriorityBufferLen())})
+		}
+	}
+	return samples
+}
+
+func workQueueTotalSamples(consumerPH *ConsumerPHMgr) []metrics.Sample {
+	samples := make([]metrics.Sample, 0)
+	for _, protocol := range consumerPH.GetAll() {
+		if cph := consumerPH.Get(protocol); cph != nil && cph.WorkQueue() != nil {
+			totals := cph.WorkQueue().Totals()
+			samples = append(samples,
+				metrics.Sample{LabelValues: []string{protocol, HIGH_PRIORITY, "in"}, Value: float64(totals.numInboundHigh)},
+				metrics.Sample{LabelValues: []string{protocol, HIGH_PRIORITY, "out"}, Value: float64(totals.numHighBuffered)},
+				metrics.Sample{LabelValues: []string{protocol, LOW_PRIORITY, "in"}, Value: float64(totals.numInboundLow)},
+				metrics.Sample{LabelValues: []string{protocol, LOW_PRIORITY, "out"}, Value: float64(totals.numLowBuffered)})
+		}
+	}
+	return samples
+}
+
+func partitionSamples(db persistence.AgbotDatabase) []metrics.Sample {
+	samples := make([]metrics.Sample, 0)
+	partitions, err := db.FindPartitions()
+	if err != nil {
+		glog.Errorf(metricsLogString(fmt.Sprintf("unable to find partitions, error: %v", err)))
+		return samples
+	}
+	for _, p := range partitions {
+		if owner, err := db.GetPartitionOwner(p); err != nil {
+			glog.Errorf(metricsLogString(fmt.Sprintf("unable to find partition %v owner, error: %v", p, err)))
+		} else {
+			samples = append(samples, metrics.Sample{LabelValues: []string{p, owner}, Value: 1})
+		}
+	}
+	return samples
+}
+
+var metricsLogString = func(v interface{}) string {
+	return fmt.Sprintf("AgreementBot Metrics: %v", v)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/open-horizon/anax/agreementbot/persistence"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
+	"sync"
 	"time"
 )
 
@@ -45,8 +46,10 @@ func NewNHPatternEntry() *NHPatternEntry {
 }
 
 type NodeHealthManager struct {
-	Patterns map[string]*NHPatternEntry // A map of patterns for which this agbot has agreements
-	NodeOrgs map[string][]string        // a map of node orgs for each pattern used by current active agreements
+	Patterns   map[string]*NHPatternEntry // A map of patterns for which this agbot has agreements
+	NodeOrgs   map[string][]string        // a map of node orgs for each pattern used by current active agreements
+	statusLock sync.RWMutex               // The cache is updated by governance and read by the API
+	hbFailed   map[string]bool            // The nodes whose heartbeat failure has been reported, keyed by device id
 }
 
 func (n *NodeHealthManager) String() string {
@@ -57,6 +60,7 @@ func (n *NodeHealthManager) String() string {
 func NewNodeHealthManager() *NodeHealthManager {
 	nh := &NodeHealthManager{
 		Patterns: make(map[string]*NHPatternEntry),
+		hbFailed: make(map[string]bool),
 	}
 	return nh
 }
@@ -128,6 +132,23 @@ func (m *NodeHealthManager) AgreementOutOfPolicy(pattern string, org string, dev
 	return false
 }
 
+// Record whether the input node's heartbeat has failed. Returns true when the state of the node changed, so that
+// a heartbeat failure is only reported once for a node, no matter how many agreements it has, until the node
+// is seen heartbeating again.
+func (m *NodeHealthManager) SetNodeHeartbeatFailed(deviceId string, failed bool) bool {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+
+	if m.hbFailed[deviceId] == failed {
+		return false
+	} else if failed {
+		m.hbFailed[deviceId] = true
+	} else {
+		delete(m.hbFailed, deviceId)
+	}
+	return true
+}
+
 // The manager has updated status if the pattern entry exists and has the Updated flag turned on.
 func (m *NodeHealthManager) hasUpdatedStatus(pattern string, org string) (string, bool) {
 
@@ -155,6 +176,9 @@ func (m *NodeHealthManager) getNewStatus(pattern string, org string, lastCall st
 // Update the manager with the new node status.
 func (m *NodeHealthManager) setNewStatus(pattern string, org string, lastCall string, nhs *exchange.NodeHealthStatus) {
 
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+
 	key := getKey(pattern, org)
 
 	// Create the pattern entry if needed
@@ -182,6 +206,22 @@ func (m *NodeHealthManager) setNewStatus(pattern string, org string, lastCall st
 
 }
 
+// Returns the last heartbeat of the node as seen by the exchange, or empty string if the node is not in the cache.
+// This function can be called from outside the governance thread.
+func (m *NodeHealthManager) GetLastHeartbeat(pattern string, org string, deviceId string) string {
+
+	m.statusLock.RLock()
+	defer m.statusLock.RUnlock()
+
+	if pe, ok := m.Patterns[getKey(pattern, org)]; !ok || pe.Nodes == nil {
+		return ""
+	} else if node, ok := pe.Nodes.Nodes[deviceId]; !ok {
+		return ""
+	} else {
+		return node.LastHeartbeat
+	}
+}
+
 // set the node orgs for patterns for current active agreements under the given agreement protocol
 func (m *NodeHealthManager) SetNodeOrgs(agreements []persistence.Agreement, agreementProtocol string) {
 
@@ -327,6 +327,24 @@ func Test_stringSliceContains(t *testing.T) {
 	}
 }
 
+func Test_SetNodeHeartbeatFailed(t *testing.T) {
+	nhm := NewNodeHealthManager()
+
+	if nhm.SetNodeHeartbeatFailed("myorg/node1", false) {
+		t.Errorf("a node that is heartbeating should not be a change")
+	} else if !nhm.SetNodeHeartbeatFailed("myorg/node1", true) {
+		t.Errorf("the first failure should be a change")
+	} else if nhm.SetNodeHeartbeatFailed("myorg/node1", true) {
+		t.Errorf("the failure should only be reported once")
+	} else if !nhm.SetNodeHeartbeatFailed("myorg/node2", true) {
+		t.Errorf("the failure of another node should be a change")
+	} else if !nhm.SetNodeHeartbeatFailed("myorg/node1", false) {
+		t.Errorf("the node heartbeating again should be a change")
+	} else if !nhm.SetNodeHeartbeatFailed("myorg/node1", true) {
+		t.Errorf("a new failure should be a change")
+	}
+}
+
 func getVariableStatusHandler(node string, agreementId string, nodeOrgs []string, lastHB string) func(pattern string, org string, nodeOrgs []string, lastCall string) (*exchange.NodeHealthStatus, error) {
 	return func(pattern string, org string, nodeOrgs []string, lastCall string) (*exchange.NodeHealthStatus, error) {
 		o := &exchange.NodeHealthStatus{
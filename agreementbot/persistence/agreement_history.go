@@ -0,0 +1,185 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The archived agreements are the history of the agreements made by the agbots. They are returned newest first, in
+// pages, ordered by the time the agreement was terminated and then by agreement id. Each page carries a cursor that
+// identifies the last agreement in the page, the next page starts with the agreement after it. Agreements archived
+// while the pages are being read do not shift the pages that follow, because they are newer than the cursor.
+
+const DEFAULT_ARCHIVED_AGREEMENT_PAGE_SIZE = 100
+
+// The query for archived agreements. Fields with zero values match all agreements.
+type ArchivedAgreementQuery struct {
+	NodeId     string // The id of the node the agreement was made with
+	PolicyName string // The name of the policy the agreement was made with
+	Pattern    string // The pattern the agreement was made with
+	ServiceURL string // The URL of one of the services in the agreement
+	Reason     uint   // The reason the agreement was terminated
+	Since      uint64 // Agreements terminated at or after this time, in seconds since the epoch
+	Until      uint64 // Agreements terminated before this time, in seconds since the epoch
+	Cursor     string // The cursor returned with the previous page
+	Limit      int    // The most agreements to return in a page
+}
+
+// A page of archived agreements. The NextCursor is empty when there are no more pages.
+type ArchivedAgreementPage struct {
+	Agreements []Agreement `json:"agreements"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// The number of archived agreements made with a policy that were terminated for the same reason.
+type TerminationCount struct {
+	PolicyName  string `json:"policy_name"`
+	Reason      uint   `json:"reason"`
+	Description string `json:"description,omitempty"`
+	Count       int64  `json:"count"`
+}
+
+// The position in the archived agreement history after which the next page starts.
+type ArchivedAgreementCursor struct {
+	Timedout    uint64
+	AgreementId string
+}
+
+// Cursors are opaque to the caller, so they are base64 encoded.
+func NewArchivedAgreementCursor(ag *Agreement) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%v/%v", ag.AgreementTimedout, ag.CurrentAgreementId)))
+}
+
+func ParseArchivedAgreementCursor(cursor string) (*ArchivedAgreementCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("cursor %v is not valid", cursor))
+	}
+
+	pieces := strings.SplitN(string(decoded), "/", 2)
+	if len(pieces) != 2 {
+		return nil, errors.New(fmt.Sprintf("cursor %v is not valid", cursor))
+	} else if timedout, err := strconv.ParseUint(pieces[0], 10, 64); err != nil {
+		return nil, errors.New(fmt.Sprintf("cursor %v is not valid", cursor))
+	} else {
+		return &ArchivedAgreementCursor{Timedout: timedout, AgreementId: pieces[1]}, nil
+	}
+}
+
+// Returns true if the agreement comes after the cursor in the history.
+func (c *ArchivedAgreementCursor) Before(ag *Agreement) bool {
+	return ag.AgreementTimedout < c.Timedout || (ag.AgreementTimedout == c.Timedout && ag.CurrentAgreementId < c.AgreementId)
+}
+
+// The page size, with the default applied.
+func (q ArchivedAgreementQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DEFAULT_ARCHIVED_AGREEMENT_PAGE_SIZE
+	}
+	return q.Limit
+}
+
+// Service ids in an agreement are exchange ids of the form org/url_version_arch, where the url has had some characters
+// replaced. Returns the url part of the service ids that match the query's service URL.
+func (q ArchivedAgreementQuery) ServiceIdPrefix() string {
+	if q.ServiceURL == "" {
+		return ""
+	}
+	return cutil.FormExchangeIdWithSpecRef(q.ServiceURL) + "_"
+}
+
+// Returns the filters that select the archived agreements matching the query, ignoring the cursor.
+func (q ArchivedAgreementQuery) Filters() []AFilter {
+	filters := []AFilter{ArchivedAFilter()}
+	if q.NodeId != "" {
+		filters = append(filters, func(a Agreement) bool { return a.DeviceId == q.NodeId })
+	}
+	if q.PolicyName != "" {
+		filters = append(filters, func(a Agreement) bool { return a.PolicyName == q.PolicyName })
+	}
+	if q.Pattern != "" {
+		filters = append(filters, func(a Agreement) bool { return a.Pattern == q.Pattern })
+	}
+	if prefix := q.ServiceIdPrefix(); prefix != "" {
+		filters = append(filters, func(a Agreement) bool {
+			for _, sId := range a.ServiceId {
+				if pieces := strings.SplitN(sId, "/", 2); len(pieces) == 2 && strings.HasPrefix(pieces[1], prefix) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	if q.Reason != 0 {
+		filters = append(filters, func(a Agreement) bool { return a.TerminatedReason == q.Reason })
+	}
+	if q.Since != 0 {
+		filters = append(filters, func(a Agreement) bool { return a.AgreementTimedout >= q.Since })
+	}
+	if q.Until != 0 {
+		filters = append(filters, func(a Agreement) bool { return a.AgreementTimedout < q.Until })
+	}
+	return filters
+}
+
+// Sort the agreements that match the query into history order and return the page after the query's cursor. This is
+// used by databases that cannot order and limit the agreements themselves.
+func PageArchivedAgreements(ags []Agreement, q ArchivedAgreementQuery) (*ArchivedAgreementPage, error) {
+	cursor, err := ParseArchivedAgreementCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ags, func(i, j int) bool {
+		if ags[i].AgreementTimedout != ags[j].AgreementTimedout {
+			return ags[i].AgreementTimedout > ags[j].AgreementTimedout
+		}
+		return ags[i].CurrentAgreementId > ags[j].CurrentAgreementId
+	})
+
+	page := &ArchivedAgreementPage{Agreements: make([]Agreement, 0)}
+	for ix := range ags {
+		if cursor != nil && !cursor.Before(&ags[ix]) {
+			continue
+		} else if len(page.Agreements) == q.PageSize() {
+			page.NextCursor = NewArchivedAgreementCursor(&page.Agreements[len(page.Agreements)-1])
+			break
+		}
+		page.Agreements = append(page.Agreements, ags[ix])
+	}
+	return page, nil
+}
+
+// Count the agreements by policy name and termination reason, ordered by policy name and then reason.
+func CountTerminations(ags []Agreement) []TerminationCount {
+	counts := make(map[string]map[uint]int64)
+	for _, ag := range ags {
+		if _, ok := counts[ag.PolicyName]; !ok {
+			counts[ag.PolicyName] = make(map[uint]int64)
+		}
+		counts[ag.PolicyName][ag.TerminatedReason] += 1
+	}
+
+	res := make([]TerminationCount, 0)
+	for pol, reasons := range counts {
+		for reason, count := range reasons {
+			res = append(res, TerminationCount{PolicyName: pol, Reason: reason, Count: count})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].PolicyName != res[j].PolicyName {
+			return res[i].PolicyName < res[j].PolicyName
+		}
+		return res[i].Reason < res[j].Reason
+	})
+	return res
+}
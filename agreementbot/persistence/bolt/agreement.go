@@ -43,6 +43,40 @@ func (db *AgbotBoltDB) GetAgreementCount(partition string) (int64, int64, error)
 	return activeNum, archivedNum, nil
 }
 
+// The bolt DB has no indexes, so the archived agreements in all protocols are read, sorted and paged in memory.
+func (db *AgbotBoltDB) findArchivedAgreements(query persistence.ArchivedAgreementQuery) ([]persistence.Agreement, error) {
+	agreements := make([]persistence.Agreement, 0)
+	for _, protocol := range policy.AllAgreementProtocols() {
+		if ags, err := db.FindAgreements(query.Filters(), protocol); err != nil {
+			return nil, err
+		} else {
+			agreements = append(agreements, ags...)
+		}
+	}
+	return agreements, nil
+}
+
+func (db *AgbotBoltDB) FindArchivedAgreements(query persistence.ArchivedAgreementQuery) (*persistence.ArchivedAgreementPage, error) {
+	if ags, err := db.findArchivedAgreements(query); err != nil {
+		return nil, err
+	} else {
+		return persistence.PageArchivedAgreements(ags, query)
+	}
+}
+
+func (db *AgbotBoltDB) GetTerminationCounts(query persistence.ArchivedAgreementQuery) ([]persistence.TerminationCount, error) {
+	if ags, err := db.findArchivedAgreements(query); err != nil {
+		return nil, err
+	} else {
+		return persistence.CountTerminations(ags), nil
+	}
+}
+
+// The bolt DB has a single partition.
+func (db *AgbotBoltDB) FindAgreementsInAllPartitions(filters []persistence.AFilter, protocol string) ([]persistence.Agreement, error) {
+	return db.FindAgreements(filters, protocol)
+}
+
 func (db *AgbotBoltDB) FindAgreements(filters []persistence.AFilter, protocol string) ([]persistence.Agreement, error) {
 	agreements := make([]persistence.Agreement, 0)
 
@@ -0,0 +1,98 @@
+// +build unit
+
+package bolt
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/policy"
+	"os"
+	"testing"
+)
+
+func Test_FindArchivedAgreements(t *testing.T) {
+	db, dir := newTestDB(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	// 10 archived agreements terminated at times 1001 to 1010, alternating between 2 policies and 2 reasons, with 2
+	// agreements terminated at the same time. There is also 1 active agreement.
+	for i := 1; i <= 10; i++ {
+		ag := &persistence.Agreement{
+			CurrentAgreementId: fmt.Sprintf("ag%02d", i),
+			DeviceId:           fmt.Sprintf("myorg/node%v", i%3),
+			PolicyName:         fmt.Sprintf("myorg/pol%v", i%2),
+			ServiceId:          []string{fmt.Sprintf("myorg/my.com.svc%v_1.0.0_amd64", i%2)},
+			Archived:           true,
+			TerminatedReason:   uint(200 + i%2),
+			AgreementTimedout:  uint64(1000 + i),
+		}
+		if i == 10 {
+			ag.AgreementTimedout = 1009
+		}
+		if _, err := db.ImportAgreement(ag, policy.BasicProtocol); err != nil {
+			t.Fatalf("unexpected error importing agreement: %v", err)
+		}
+	}
+	if _, err := db.ImportAgreement(&persistence.Agreement{CurrentAgreementId: "active", PolicyName: "myorg/pol1"}, policy.BasicProtocol); err != nil {
+		t.Fatalf("unexpected error importing agreement: %v", err)
+	}
+
+	// Read all the agreements in pages of 3, newest first.
+	ids := []string{}
+	query := persistence.ArchivedAgreementQuery{Limit: 3}
+	for pages := 1; ; pages++ {
+		page, err := db.FindArchivedAgreements(query)
+		if err != nil {
+			t.Fatalf("unexpected error finding archived agreements: %v", err)
+		}
+		for _, ag := range page.Agreements {
+			ids = append(ids, ag.CurrentAgreementId)
+		}
+		if page.NextCursor == "" {
+			if pages != 4 {
+				t.Errorf("expected 4 pages, got %v", pages)
+			}
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if fmt.Sprintf("%v", ids) != "[ag10 ag09 ag08 ag07 ag06 ag05 ag04 ag03 ag02 ag01]" {
+		t.Errorf("unexpected agreement order %v", ids)
+	}
+
+	// Filters.
+	for _, test := range []struct {
+		query    persistence.ArchivedAgreementQuery
+		expected string
+	}{
+		{persistence.ArchivedAgreementQuery{NodeId: "myorg/node0"}, "[ag09 ag06 ag03]"},
+		{persistence.ArchivedAgreementQuery{PolicyName: "myorg/pol1", Since: 1005}, "[ag09 ag07 ag05]"},
+		{persistence.ArchivedAgreementQuery{ServiceURL: "my.com.svc0", Until: 1005}, "[ag04 ag02]"},
+		{persistence.ArchivedAgreementQuery{Reason: 201, Since: 1001, Until: 1004}, "[ag03 ag01]"},
+		{persistence.ArchivedAgreementQuery{ServiceURL: "my.com.svc"}, "[]"},
+	} {
+		if page, err := db.FindArchivedAgreements(test.query); err != nil {
+			t.Errorf("unexpected error finding archived agreements: %v", err)
+		} else {
+			ids := []string{}
+			for _, ag := range page.Agreements {
+				ids = append(ids, ag.CurrentAgreementId)
+			}
+			if fmt.Sprintf("%v", ids) != test.expected || page.NextCursor != "" {
+				t.Errorf("query %+v expected %v, got %v, cursor %v", test.query, test.expected, ids, page.NextCursor)
+			}
+		}
+	}
+
+	if _, err := db.FindArchivedAgreements(persistence.ArchivedAgreementQuery{Cursor: "not a cursor"}); err == nil {
+		t.Errorf("expected an error for a bad cursor")
+	}
+
+	// Termination counts.
+	if counts, err := db.GetTerminationCounts(persistence.ArchivedAgreementQuery{Until: 1009}); err != nil {
+		t.Errorf("unexpected error counting terminations: %v", err)
+	} else if fmt.Sprintf("%v", counts) != "[{myorg/pol0 200  4} {myorg/pol1 201  4}]" {
+		t.Errorf("unexpected termination counts %v", counts)
+	}
+}
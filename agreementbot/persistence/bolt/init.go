@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/agreementbot/persistence"
 	"github.com/open-horizon/anax/config"
 	"os"
 	"path"
@@ -35,3 +36,8 @@ func (db *AgbotBoltDB) Initialize(cfg *config.HorizonConfig) error {
 	return nil
 
 }
+
+// The bolt DB does not version its schema.
+func (db *AgbotBoltDB) GetSchemaStatus() (*persistence.SchemaStatus, error) {
+	return &persistence.SchemaStatus{Database: "bolt"}, nil
+}
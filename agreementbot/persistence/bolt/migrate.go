@@ -0,0 +1,124 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/policy"
+	"strconv"
+)
+
+// Functions used to copy the agbot database to or from another database implementation. The bolt DB has only 1
+// partition, a single search session that is shared by all policies, and does not keep managed secrets.
+
+func (db *AgbotBoltDB) GetRecordCounts() (persistence.RecordCounts, error) {
+	counts := persistence.RecordCounts{}
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		for _, protocol := range policy.AllAgreementProtocols() {
+			if b := tx.Bucket([]byte(bucketName(protocol))); b != nil {
+				counts[persistence.TABLE_AGREEMENTS] += int64(b.Stats().KeyN)
+			}
+		}
+		if b := tx.Bucket([]byte(wuBucketName())); b != nil {
+			counts[persistence.TABLE_WORKLOAD_USAGES] = int64(b.Stats().KeyN)
+		}
+		if b := tx.Bucket([]byte(ssBucketName())); b != nil {
+			counts[persistence.TABLE_SEARCH_SESSIONS] = int64(b.Stats().KeyN)
+		}
+		return nil
+	})
+
+	return counts, readErr
+}
+
+// The agreement is written as is, replacing the agreement with the same id.
+func (db *AgbotBoltDB) ImportAgreement(ag *persistence.Agreement, protocol string) (persistence.ImportResult, error) {
+	res := persistence.IMPORT_INSERTED
+
+	writeErr := db.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(bucketName(protocol))); err != nil {
+			return err
+		} else if serialized, err := json.Marshal(ag); err != nil {
+			return fmt.Errorf("Failed to serialize agreement record: %v", ag)
+		} else {
+			if b.Get([]byte(ag.CurrentAgreementId)) != nil {
+				res = persistence.IMPORT_UPDATED
+			}
+			return b.Put([]byte(ag.CurrentAgreementId), serialized)
+		}
+	})
+
+	return res, writeErr
+}
+
+// The workload usage replaces the record for the same device and policy, keeping that record's key. A new record
+// gets a new key from this database's sequence.
+func (db *AgbotBoltDB) ImportWorkloadUsage(wu *persistence.WorkloadUsage) (persistence.ImportResult, error) {
+	existing, err := db.FindSingleWorkloadUsageByDeviceAndPolicyName(wu.DeviceId, wu.PolicyName)
+	if err != nil {
+		return persistence.IMPORT_SKIPPED, err
+	}
+
+	res := persistence.IMPORT_INSERTED
+	record := *wu
+
+	writeErr := db.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(wuBucketName()))
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			res = persistence.IMPORT_UPDATED
+			record.Id = existing.Id
+		} else if nextKey, err := b.NextSequence(); err != nil {
+			return fmt.Errorf("Unable to get sequence key for imported record %v. Error: %v", record.ShortString(), err)
+		} else {
+			record.Id = nextKey
+		}
+
+		if serialized, err := json.Marshal(record); err != nil {
+			return fmt.Errorf("Failed to serialize workload usage record: %v", record.ShortString())
+		} else {
+			return b.Put([]byte(strconv.FormatUint(record.Id, 10)), serialized)
+		}
+	})
+
+	return res, writeErr
+}
+
+func (db *AgbotBoltDB) FindSearchSessions() ([]persistence.SearchSession, error) {
+	if ss, err := db.findSearchSession(); err != nil {
+		return nil, err
+	} else {
+		return []persistence.SearchSession{{ChangedSince: ss.ChangedSince, SessionToken: ss.SessionToken, SessionEnded: ss.SessionEnded}}, nil
+	}
+}
+
+// There is only one search session, so it is always updated. When the imported sessions belong to different policies,
+// the oldest changedSince is kept so that the next search does not miss any nodes.
+func (db *AgbotBoltDB) ImportSearchSession(ss *persistence.SearchSession) (persistence.ImportResult, error) {
+	current, err := db.findSearchSession()
+	if err != nil {
+		return persistence.IMPORT_SKIPPED, err
+	}
+
+	if ss.PolicyName == "" || current.ChangedSince == 0 || ss.ChangedSince < current.ChangedSince {
+		current.ChangedSince = ss.ChangedSince
+	}
+	current.SessionEnded = true
+	glog.V(5).Infof("Importing search session %v as %v", ss, current)
+
+	return persistence.IMPORT_UPDATED, db.saveSearchSession(current)
+}
+
+func (db *AgbotBoltDB) FindManagedSecrets() ([]persistence.ManagedSecret, error) {
+	return []persistence.ManagedSecret{}, nil
+}
+
+func (db *AgbotBoltDB) ImportManagedSecret(ms *persistence.ManagedSecret) (persistence.ImportResult, error) {
+	return persistence.IMPORT_SKIPPED, nil
+}
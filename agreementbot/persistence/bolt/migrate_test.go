@@ -0,0 +1,90 @@
+// +build unit
+
+package bolt
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func newTestDB(t *testing.T) (*AgbotBoltDB, string) {
+	dir, err := ioutil.TempDir("", "agbotdb-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	db := new(AgbotBoltDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: dir}}); err != nil {
+		t.Fatalf("unexpected error initializing: %v", err)
+	}
+	return db, dir
+}
+
+func Test_MigrateDatabase(t *testing.T) {
+	source, sdir := newTestDB(t)
+	defer os.RemoveAll(sdir)
+	defer source.Close()
+	target, tdir := newTestDB(t)
+	defer os.RemoveAll(tdir)
+	defer target.Close()
+
+	for _, id := range []string{"ag1", "ag2", "ag3"} {
+		if err := source.AgreementAttempt(id, "myorg", "myorg/"+id, "device", "mypol", "", "", "", policy.BasicProtocol, "", []string{"svc"}, policy.NodeHealth{}, 60, 3600); err != nil {
+			t.Fatalf("unexpected error creating agreement: %v", err)
+		}
+		if err := source.NewWorkloadUsage("myorg/"+id, []string{}, "", "mypol", 1, 60, 60, false, id); err != nil {
+			t.Fatalf("unexpected error creating workload usage: %v", err)
+		}
+	}
+	if _, err := source.ArchiveAgreement("ag3", policy.BasicProtocol, 1, "cancelled"); err != nil {
+		t.Fatalf("unexpected error archiving agreement: %v", err)
+	}
+
+	migrations, err := persistence.MigrateDatabase(source, target)
+	if err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	} else if len(migrations) != 4 {
+		t.Fatalf("expected 4 tables, got %v", migrations)
+	}
+
+	expected := map[string]persistence.TableMigration{
+		persistence.TABLE_AGREEMENTS:      {Source: 3, Inserted: 3, TargetAfter: 3},
+		persistence.TABLE_WORKLOAD_USAGES: {Source: 3, Inserted: 3, TargetAfter: 3},
+		persistence.TABLE_SEARCH_SESSIONS: {Source: 1, Updated: 1, TargetBefore: 1, TargetAfter: 1},
+		persistence.TABLE_MANAGED_SECRETS: {},
+	}
+	for _, m := range migrations {
+		e := expected[m.Table]
+		e.Table = m.Table
+		if m != e {
+			t.Errorf("expected %v, got %v", e, m)
+		}
+	}
+
+	if ag, err := target.FindSingleAgreementByAgreementId("ag3", policy.BasicProtocol, []persistence.AFilter{}); err != nil {
+		t.Errorf("unexpected error finding agreement: %v", err)
+	} else if ag == nil || !ag.Archived || ag.DeviceId != "myorg/ag3" {
+		t.Errorf("expected archived agreement ag3, got %v", ag)
+	}
+
+	// Running the copy again replaces every record without adding any.
+	migrations, err = persistence.MigrateDatabase(source, target)
+	if err != nil {
+		t.Fatalf("unexpected error migrating again: %v", err)
+	}
+	for _, m := range migrations {
+		if m.Inserted != 0 || m.Updated != m.Source || m.TargetBefore != m.TargetAfter || !m.Verified() {
+			t.Errorf("expected only updates on the second copy, got %v", m)
+		}
+	}
+
+	if wu, err := target.FindSingleWorkloadUsageByDeviceAndPolicyName("myorg/ag2", "mypol"); err != nil {
+		t.Errorf("unexpected error finding workload usage: %v", err)
+	} else if wu == nil || wu.CurrentAgreementId != "ag2" {
+		t.Errorf("expected workload usage for ag2, got %v", wu)
+	}
+}
@@ -0,0 +1,40 @@
+package bolt
+
+import (
+	"github.com/boltdb/bolt"
+)
+
+const ROLLOUTS = "rollouts" // The bolt DB bucket name for the staged rollout objects.
+
+// Functions that are part of the database interface, which all agbot database implementations must support.
+
+// Save the rollout of a policy, replacing the rollout that was saved for the policy before.
+func (db *AgbotBoltDB) SaveRollout(policyName string, rollout string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if b, err := tx.CreateBucketIfNotExists([]byte(ROLLOUTS)); err != nil {
+			return err
+		} else {
+			return b.Put([]byte(policyName), []byte(rollout))
+		}
+	})
+}
+
+// Return all the saved rollouts, keyed by policy name.
+func (db *AgbotBoltDB) FindRollouts() (map[string]string, error) {
+	rollouts := make(map[string]string)
+
+	readErr := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(ROLLOUTS)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				rollouts[string(k)] = string(v)
+				return nil
+			})
+		}
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return rollouts, nil
+}
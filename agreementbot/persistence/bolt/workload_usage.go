@@ -25,6 +25,20 @@ func (db *AgbotBoltDB) NewWorkloadUsage(deviceId string, hapartners []string, po
 	}
 }
 
+func (db *AgbotBoltDB) NewHeldUpgradeWorkloadUsage(deviceId string, hapartners []string, policyName string, agid string, upgrade *persistence.HeldUpgrade) error {
+	if wlUsage, err := persistence.NewHeldUpgradeWorkloadUsage(deviceId, hapartners, policyName, agid, upgrade); err != nil {
+		return err
+	} else if existing, err := db.FindSingleWorkloadUsageByDeviceAndPolicyName(deviceId, policyName); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("Workload usage record for device %v and policy name %v already exists.", deviceId, policyName)
+	} else if err := db.WUPersistNew(wuBucketName(), wlUsage); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
 func (db *AgbotBoltDB) GetWorkloadUsagesCount(partition string) (int64, error) {
 	if wus, err := db.FindWorkloadUsages([]persistence.WUFilter{}); err != nil {
 		return 0, err
@@ -52,6 +66,10 @@ func (db *AgbotBoltDB) UpdatePendingUpgrade(deviceid string, policyName string)
 	return persistence.UpdatePendingUpgrade(db, deviceid, policyName)
 }
 
+func (db *AgbotBoltDB) UpdateHeldUpgrade(deviceid string, policyName string, upgrade *persistence.HeldUpgrade) (*persistence.WorkloadUsage, error) {
+	return persistence.UpdateHeldUpgrade(db, deviceid, policyName, upgrade)
+}
+
 func (db *AgbotBoltDB) UpdateRetryCount(deviceid string, policyName string, retryCount int, agid string) (*persistence.WorkloadUsage, error) {
 	return persistence.UpdateRetryCount(db, deviceid, policyName, retryCount, agid)
 }
@@ -151,6 +169,11 @@ func (db *AgbotBoltDB) DeleteWorkloadUsage(deviceid string, policyName string) e
 	}
 }
 
+// The bolt DB has a single partition.
+func (db *AgbotBoltDB) FindWorkloadUsagesInAllPartitions(filters []persistence.WUFilter) ([]persistence.WorkloadUsage, error) {
+	return db.FindWorkloadUsages(filters)
+}
+
 func (db *AgbotBoltDB) FindWorkloadUsages(filters []persistence.WUFilter) ([]persistence.WorkloadUsage, error) {
 	wlUsages := make([]persistence.WorkloadUsage, 0)
 
@@ -0,0 +1,41 @@
+// +build unit
+
+package bolt
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"os"
+	"testing"
+)
+
+func Test_UpdateHeldUpgrade(t *testing.T) {
+	db, dir := newTestDB(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	if err := db.NewWorkloadUsage("myorg/node1", []string{}, "", "myorg/pol1", 2, 600, 120, false, "ag1"); err != nil {
+		t.Fatalf("unexpected error creating workload usage: %v", err)
+	} else if _, err := db.UpdateRetryCount("myorg/node1", "myorg/pol1", 3, "ag1"); err != nil {
+		t.Fatalf("unexpected error updating retry count: %v", err)
+	}
+
+	// Holding the upgrade again keeps the time when it was first held.
+	if _, err := db.UpdateHeldUpgrade("myorg/node1", "myorg/pol1", &persistence.HeldUpgrade{Version: "2.0.0", HeldTime: 1000}); err != nil {
+		t.Fatalf("unexpected error holding upgrade: %v", err)
+	} else if wlu, err := db.UpdateHeldUpgrade("myorg/node1", "myorg/pol1", &persistence.HeldUpgrade{Version: "2.0.1", HeldTime: 2000}); err != nil {
+		t.Fatalf("unexpected error holding upgrade: %v", err)
+	} else if wlu.HeldUpgrade == nil || wlu.HeldUpgrade.Version != "2.0.1" || wlu.HeldUpgrade.HeldTime != 1000 {
+		t.Errorf("unexpected held upgrade: %v", wlu.HeldUpgrade)
+	}
+
+	// Clearing the held upgrade keeps the workload priority and retry state.
+	if _, err := db.UpdateHeldUpgrade("myorg/node1", "myorg/pol1", nil); err != nil {
+		t.Fatalf("unexpected error clearing held upgrade: %v", err)
+	} else if wlu, err := db.FindSingleWorkloadUsageByDeviceAndPolicyName("myorg/node1", "myorg/pol1"); err != nil {
+		t.Fatalf("unexpected error finding workload usage: %v", err)
+	} else if wlu == nil {
+		t.Errorf("the workload usage should not be deleted")
+	} else if wlu.HeldUpgrade != nil || wlu.Priority != 2 || wlu.RetryCount != 3 || wlu.RetryDurationS != 600 || wlu.VerifiedDurationS != 120 {
+		t.Errorf("unexpected workload usage: %v", wlu)
+	}
+}
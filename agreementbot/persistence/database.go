@@ -32,6 +32,13 @@ type AgbotDatabase interface {
 
 	GetAgreementCount(partition string) (int64, int64, error)
 
+	// The agreements in all partitions, including the partitions owned by other agbots.
+	FindAgreementsInAllPartitions(filters []AFilter, protocol string) ([]Agreement, error)
+
+	// The history of archived agreements, in all partitions.
+	FindArchivedAgreements(query ArchivedAgreementQuery) (*ArchivedAgreementPage, error)
+	GetTerminationCounts(query ArchivedAgreementQuery) ([]TerminationCount, error)
+
 	SingleAgreementUpdate(agreementid string, protocol string, fn func(Agreement) *Agreement) (*Agreement, error)
 
 	AgreementAttempt(agreementid string, org string, deviceid string, deviceType string, policyName string, bcType string, bcName string, bcOrg string, agreementProto string, pattern string, serviceId []string, nhPolicy policy.NodeHealth, protocolTimeout uint64, agreementTimeout uint64) error
@@ -54,14 +61,19 @@ type AgbotDatabase interface {
 
 	// Workoad usage related functions
 	NewWorkloadUsage(deviceId string, hapartners []string, policy string, policyName string, priority int, retryDurationS int, verifiedDurationS int, reqsNotMet bool, agid string) error
+	NewHeldUpgradeWorkloadUsage(deviceId string, hapartners []string, policyName string, agid string, upgrade *HeldUpgrade) error
 	FindSingleWorkloadUsageByDeviceAndPolicyName(deviceid string, policyName string) (*WorkloadUsage, error)
 	FindWorkloadUsages(filters []WUFilter) ([]WorkloadUsage, error)
 
 	GetWorkloadUsagesCount(partition string) (int64, error)
 
+	// The workload usages in all partitions, including the partitions owned by other agbots.
+	FindWorkloadUsagesInAllPartitions(filters []WUFilter) ([]WorkloadUsage, error)
+
 	SingleWorkloadUsageUpdate(deviceid string, policyName string, fn func(WorkloadUsage) *WorkloadUsage) (*WorkloadUsage, error)
 
 	UpdatePendingUpgrade(deviceid string, policyName string) (*WorkloadUsage, error)
+	UpdateHeldUpgrade(deviceid string, policyName string, upgrade *HeldUpgrade) (*WorkloadUsage, error)
 	UpdatePriority(deviceid string, policyName string, priority int, retryDurationS int, verifiedDurationS int, agid string) (*WorkloadUsage, error)
 	UpdateRetryCount(deviceid string, policyName string, retryCount int, agid string) (*WorkloadUsage, error)
 	UpdatePolicy(deviceid string, policyName string, pol string) (*WorkloadUsage, error)
@@ -91,4 +103,22 @@ type AgbotDatabase interface {
 	DeleteSecretsForPattern(polOrg, patternName string) error
 	DeletePolicySecret(secretOrg, secretName, policyOrg, policyName string) error
 	DeletePatternSecret(secretOrg, secretName, patternOrg, patternName string) error
+
+	// Functions related to persistence of staged rollouts. A rollout is saved as a JSON document, keyed by the name of
+	// the policy being rolled out.
+	SaveRollout(policyName string, rollout string) error
+	FindRollouts() (map[string]string, error)
+
+	// Schema version of the database, for databases that version their schema.
+	GetSchemaStatus() (*SchemaStatus, error)
+
+	// Functions used to copy a database into another database implementation. The import functions replace any record
+	// with the same key. The count and find functions below cover all partitions in the database.
+	GetRecordCounts() (RecordCounts, error)
+	ImportAgreement(ag *Agreement, protocol string) (ImportResult, error)
+	ImportWorkloadUsage(wu *WorkloadUsage) (ImportResult, error)
+	FindSearchSessions() ([]SearchSession, error)
+	ImportSearchSession(ss *SearchSession) (ImportResult, error)
+	FindManagedSecrets() ([]ManagedSecret, error)
+	ImportManagedSecret(ms *ManagedSecret) (ImportResult, error)
 }
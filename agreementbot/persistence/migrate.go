@@ -0,0 +1,193 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/policy"
+)
+
+// An agbot database can be copied into another database implementation, for example when an agbot using bolt DB is
+// moved to a postgresql deployment. The copy is done through the AgbotDatabase interface, one table at a time. Each
+// record is imported into the target database, replacing any record with the same key, so that a copy which fails part
+// way through can simply be run again.
+
+// The tables that are copied.
+const (
+	TABLE_AGREEMENTS      = "agreements"
+	TABLE_WORKLOAD_USAGES = "workload_usages"
+	TABLE_SEARCH_SESSIONS = "search_sessions"
+	TABLE_MANAGED_SECRETS = "managed_secrets"
+)
+
+// The outcome of importing a single record.
+type ImportResult int
+
+const (
+	IMPORT_INSERTED ImportResult = iota // The record did not exist in the target database
+	IMPORT_UPDATED                      // The record replaced a record with the same key
+	IMPORT_SKIPPED                      // The target database does not keep this kind of record
+)
+
+// A search session, as it is kept by any of the database implementations. Databases that have only one search session
+// for all policies return it with an empty policy name.
+type SearchSession struct {
+	PolicyName   string `json:"policy_name"`
+	ChangedSince uint64 `json:"changed_since"`
+	SessionToken uint64 `json:"session_token"`
+	SessionEnded bool   `json:"session_ended"`
+}
+
+func (s SearchSession) String() string {
+	return fmt.Sprintf("Policy: %v, ChangedSince: %v, SessionToken: %v, SessionEnded: %v", s.PolicyName, s.ChangedSince, s.SessionToken, s.SessionEnded)
+}
+
+// A secret used by a deployment policy or a pattern, which the agbot checks for updates.
+type ManagedSecret struct {
+	SecretOrg       string `json:"secret_org"`
+	SecretName      string `json:"secret_name"`
+	DeploymentOrg   string `json:"deployment_org"`  // The org of the policy or pattern
+	DeploymentName  string `json:"deployment_name"` // The name of the policy or pattern
+	IsPattern       bool   `json:"is_pattern"`
+	LastUpdateCheck int64  `json:"last_update_check"`
+}
+
+func (s ManagedSecret) String() string {
+	return fmt.Sprintf("Secret: %v/%v, Deployment: %v/%v, IsPattern: %v, LastUpdateCheck: %v", s.SecretOrg, s.SecretName, s.DeploymentOrg, s.DeploymentName, s.IsPattern, s.LastUpdateCheck)
+}
+
+// The number of records in each table, in all partitions of the database.
+type RecordCounts map[string]int64
+
+// The result of copying one table.
+type TableMigration struct {
+	Table        string `json:"table"`
+	Source       int64  `json:"source"`        // The number of records read from the source database
+	Inserted     int64  `json:"inserted"`      // The number of records added to the target database
+	Updated      int64  `json:"updated"`       // The number of records that replaced an existing record in the target database
+	Skipped      int64  `json:"skipped"`       // The number of records the target database does not keep
+	TargetBefore int64  `json:"target_before"` // The number of records in the target database before the copy
+	TargetAfter  int64  `json:"target_after"`  // The number of records in the target database after the copy
+}
+
+func (t TableMigration) String() string {
+	return fmt.Sprintf("Table: %v, Source: %v, Inserted: %v, Updated: %v, Skipped: %v, Target before: %v, Target after: %v", t.Table, t.Source, t.Inserted, t.Updated, t.Skipped, t.TargetBefore, t.TargetAfter)
+}
+
+// Every source record was handled, and the target database grew by exactly the number of inserted records.
+func (t TableMigration) Verified() bool {
+	return t.Inserted+t.Updated+t.Skipped == t.Source && t.TargetAfter == t.TargetBefore+t.Inserted
+}
+
+func (t *TableMigration) count(res ImportResult) {
+	switch res {
+	case IMPORT_INSERTED:
+		t.Inserted += 1
+	case IMPORT_UPDATED:
+		t.Updated += 1
+	case IMPORT_SKIPPED:
+		t.Skipped += 1
+	}
+}
+
+// Copy all the tables in the source database into the target database, then verify the record counts. Both databases
+// must already be initialized. An error is returned if a record cannot be copied or if the counts do not match, in
+// which case the migrations returned so far describe what happened.
+func MigrateDatabase(source AgbotDatabase, target AgbotDatabase) ([]TableMigration, error) {
+
+	before, err := target.GetRecordCounts()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to count records in the target database, error: %v", err))
+	}
+
+	migrations := make([]TableMigration, 0, 4)
+	for _, table := range []string{TABLE_AGREEMENTS, TABLE_WORKLOAD_USAGES, TABLE_SEARCH_SESSIONS, TABLE_MANAGED_SECRETS} {
+		m := TableMigration{Table: table, TargetBefore: before[table]}
+		if err := migrateTable(source, target, &m); err != nil {
+			migrations = append(migrations, m)
+			return migrations, errors.New(fmt.Sprintf("unable to copy %v, error: %v", table, err))
+		}
+		migrations = append(migrations, m)
+		glog.V(3).Infof("Copied %v", m)
+	}
+
+	after, err := target.GetRecordCounts()
+	if err != nil {
+		return migrations, errors.New(fmt.Sprintf("unable to count records in the target database, error: %v", err))
+	}
+
+	failed := make([]string, 0)
+	for ix := range migrations {
+		migrations[ix].TargetAfter = after[migrations[ix].Table]
+		if !migrations[ix].Verified() {
+			failed = append(failed, migrations[ix].Table)
+		}
+	}
+	if len(failed) != 0 {
+		return migrations, errors.New(fmt.Sprintf("the record counts of %v do not match after the copy", failed))
+	}
+	return migrations, nil
+}
+
+func migrateTable(source AgbotDatabase, target AgbotDatabase, m *TableMigration) error {
+	switch m.Table {
+	case TABLE_AGREEMENTS:
+		for _, protocol := range policy.AllAgreementProtocols() {
+			ags, err := source.FindAgreements([]AFilter{}, protocol)
+			if err != nil {
+				return err
+			}
+			for ix := range ags {
+				m.Source += 1
+				if res, err := target.ImportAgreement(&ags[ix], protocol); err != nil {
+					return errors.New(fmt.Sprintf("agreement %v, error: %v", ags[ix].CurrentAgreementId, err))
+				} else {
+					m.count(res)
+				}
+			}
+		}
+
+	case TABLE_WORKLOAD_USAGES:
+		wus, err := source.FindWorkloadUsages([]WUFilter{})
+		if err != nil {
+			return err
+		}
+		for ix := range wus {
+			m.Source += 1
+			if res, err := target.ImportWorkloadUsage(&wus[ix]); err != nil {
+				return errors.New(fmt.Sprintf("workload usage for %v and policy %v, error: %v", wus[ix].DeviceId, wus[ix].PolicyName, err))
+			} else {
+				m.count(res)
+			}
+		}
+
+	case TABLE_SEARCH_SESSIONS:
+		sessions, err := source.FindSearchSessions()
+		if err != nil {
+			return err
+		}
+		for ix := range sessions {
+			m.Source += 1
+			if res, err := target.ImportSearchSession(&sessions[ix]); err != nil {
+				return errors.New(fmt.Sprintf("search session %v, error: %v", sessions[ix], err))
+			} else {
+				m.count(res)
+			}
+		}
+
+	case TABLE_MANAGED_SECRETS:
+		secrets, err := source.FindManagedSecrets()
+		if err != nil {
+			return err
+		}
+		for ix := range secrets {
+			m.Source += 1
+			if res, err := target.ImportManagedSecret(&secrets[ix]); err != nil {
+				return errors.New(fmt.Sprintf("managed secret %v, error: %v", secrets[ix], err))
+			} else {
+				m.count(res)
+			}
+		}
+	}
+	return nil
+}
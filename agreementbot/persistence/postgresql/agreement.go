@@ -102,6 +102,7 @@ const AGREEMENT_PARTITION_FILLIN = `partition_name`
 
 const AGREEMENT_QUERY = `SELECT agreement FROM "agreements_ WHERE agreement_id = $1 AND protocol = $2;`
 const ALL_AGREEMENTS_QUERY = `SELECT agreement FROM "agreements_ WHERE protocol = $1;`
+const ALL_PARTITIONS_AGREEMENTS_QUERY = `SELECT agreement FROM agreements WHERE protocol = $1;`
 const AGREEMENT_PARTITION_EMPTY = `SELECT agreement_id FROM "agreements_;`
 
 const AGREEMENT_COUNT = `SELECT agreement FROM "agreements_;`
@@ -296,6 +297,37 @@ func (db *AgbotPostgresqlDB) FindAgreements(filters []persistence.AFilter, proto
 
 }
 
+// Find the agreements in all partitions by querying the main table, which includes the rows of every partition table.
+func (db *AgbotPostgresqlDB) FindAgreementsInAllPartitions(filters []persistence.AFilter, protocol string) ([]persistence.Agreement, error) {
+
+	rows, err := db.db.Query(ALL_PARTITIONS_AGREEMENTS_QUERY, protocol)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for agreements in all partitions, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+
+	ags := make([]persistence.Agreement, 0, 100)
+	for rows.Next() {
+		agBytes := make([]byte, 0, 2048)
+		ag := new(persistence.Agreement)
+		if err := rows.Scan(&agBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v", err))
+		} else if err := json.Unmarshal(agBytes, ag); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling row: %v, error: %v", string(agBytes), err))
+		} else if agPassed := persistence.RunFilters(ag, filters); agPassed != nil {
+			ags = append(ags, *ag)
+		}
+	}
+
+	// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error iterating: %v", err))
+	}
+	return ags, nil
+}
+
 // Find a specific agreement in the database. The input filters are ignored for this query. They are needed by the bolt implementation.
 func (db *AgbotPostgresqlDB) internalFindSingleAgreementByAgreementId(tx *sql.Tx, agreementId string, protocol string, filters []persistence.AFilter) (*persistence.Agreement, string, error) {
 
@@ -0,0 +1,117 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
+
+// Constants for the SQL statements that are used to query the archived agreement history. These queries use the main
+// agreements table so that they cover the archived agreements in every partition, not just the partitions owned by
+// this agbot. The filter parameters are:
+// $1: node id, $2: policy name, $3: pattern, $4: the url part of a service id, $5: termination reason,
+// $6: terminated at or after, $7: terminated before.
+// An empty string or zero matches all agreements. Agreements without a service_id array never match a service.
+
+const ARCHIVED_AGREEMENT_FILTER = `(agreement->>'archived')::boolean
+	AND ($1::text = '' OR agreement->>'device_id' = $1::text)
+	AND ($2::text = '' OR agreement->>'policy_name' = $2::text)
+	AND ($3::text = '' OR agreement->>'pattern' = $3::text)
+	AND ($4::text = '' OR EXISTS (
+		SELECT 1 FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(agreement->'service_id') = 'array' THEN agreement->'service_id' ELSE '[]'::jsonb END) AS s(id)
+		WHERE left(split_part(s.id, '/', 2), length($4::text)) = $4::text))
+	AND ($5::bigint = 0 OR (agreement->>'terminated_reason')::bigint = $5::bigint)
+	AND ($6::bigint = 0 OR (agreement->>'agreement_timeout')::bigint >= $6::bigint)
+	AND ($7::bigint = 0 OR (agreement->>'agreement_timeout')::bigint < $7::bigint)`
+
+// The cursor parameters are $8: the agreement id and $9: the termination time of the last agreement in the previous page.
+// The page size is $10.
+const ARCHIVED_AGREEMENT_PAGE_QUERY = `SELECT agreement FROM agreements WHERE ` + ARCHIVED_AGREEMENT_FILTER + `
+	AND ($8::text = '' OR (agreement->>'agreement_timeout')::bigint < $9::bigint
+		OR ((agreement->>'agreement_timeout')::bigint = $9::bigint AND agreement_id < $8::text))
+	ORDER BY (agreement->>'agreement_timeout')::bigint DESC, agreement_id DESC
+	LIMIT $10;`
+
+const ARCHIVED_AGREEMENT_TERMINATION_COUNTS = `SELECT COALESCE(agreement->>'policy_name', ''), COALESCE((agreement->>'terminated_reason')::bigint, 0), COUNT(*)
+	FROM agreements WHERE ` + ARCHIVED_AGREEMENT_FILTER + `
+	GROUP BY 1, 2
+	ORDER BY 1, 2;`
+
+func archivedAgreementFilterArgs(query *persistence.ArchivedAgreementQuery) []interface{} {
+	return []interface{}{query.NodeId, query.PolicyName, query.Pattern, query.ServiceIdPrefix(), int64(query.Reason), int64(query.Since), int64(query.Until)}
+}
+
+func (db *AgbotPostgresqlDB) FindArchivedAgreements(query persistence.ArchivedAgreementQuery) (*persistence.ArchivedAgreementPage, error) {
+
+	cursor, err := persistence.ParseArchivedAgreementCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	} else if cursor == nil {
+		cursor = &persistence.ArchivedAgreementCursor{}
+	}
+
+	// Ask for 1 more agreement than the page size to find out if there is another page.
+	args := append(archivedAgreementFilterArgs(&query), cursor.AgreementId, int64(cursor.Timedout), query.PageSize()+1)
+	glog.V(5).Infof("Find archived agreements using SQL: %v with %v", ARCHIVED_AGREEMENT_PAGE_QUERY, args)
+
+	rows, err := db.db.Query(ARCHIVED_AGREEMENT_PAGE_QUERY, args...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for archived agreements, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+
+	page := &persistence.ArchivedAgreementPage{Agreements: make([]persistence.Agreement, 0, query.PageSize())}
+	for rows.Next() {
+		agBytes := make([]byte, 0, 2048)
+		ag := persistence.Agreement{}
+		if err := rows.Scan(&agBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v", err))
+		} else if err := json.Unmarshal(agBytes, &ag); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling row: %v, error: %v", string(agBytes), err))
+		} else if len(page.Agreements) == query.PageSize() {
+			page.NextCursor = persistence.NewArchivedAgreementCursor(&page.Agreements[len(page.Agreements)-1])
+		} else {
+			page.Agreements = append(page.Agreements, ag)
+		}
+	}
+
+	// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error iterating: %v", err))
+	}
+
+	return page, nil
+}
+
+func (db *AgbotPostgresqlDB) GetTerminationCounts(query persistence.ArchivedAgreementQuery) ([]persistence.TerminationCount, error) {
+
+	rows, err := db.db.Query(ARCHIVED_AGREEMENT_TERMINATION_COUNTS, archivedAgreementFilterArgs(&query)...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for termination counts, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+
+	counts := make([]persistence.TerminationCount, 0)
+	for rows.Next() {
+		var reason int64
+		count := persistence.TerminationCount{}
+		if err := rows.Scan(&count.PolicyName, &reason, &count.Count); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v", err))
+		}
+		count.Reason = uint(reason)
+		counts = append(counts, count)
+	}
+
+	// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error iterating: %v", err))
+	}
+
+	return counts, nil
+}
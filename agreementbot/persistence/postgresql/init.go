@@ -47,6 +47,8 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 			return errors.New(fmt.Sprintf("unable to create version table, error: %v", err))
 		} else if _, err := db.db.Exec(VERSION_INSERT); err != nil {
 			return errors.New(fmt.Sprintf("unable to insert singleton version row, error: %v", err))
+		} else if _, err := db.db.Exec(VERSION_HISTORY_CREATE_TABLE); err != nil {
+			return errors.New(fmt.Sprintf("unable to create version history table, error: %v", err))
 		}
 
 		// Create the search session table if necessary, and initialize the stored procedure functions.
@@ -82,6 +84,11 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 			return errors.New(fmt.Sprintf("unable to create workload usage partition table index, error: %v", err))
 		}
 
+		// Create the rollouts table if necessary.
+		if _, err := db.db.Exec(ROLLOUT_CREATE_MAIN_TABLE); err != nil {
+			return errors.New(fmt.Sprintf("unable to create rollouts table, error: %v", err))
+		}
+
 		// Create the agreement table, partition and index if necessary.
 		if _, err := db.db.Exec(AGREEMENT_CREATE_MAIN_TABLE); err != nil {
 			return errors.New(fmt.Sprintf("unable to create agreements table, error: %v", err))
@@ -120,25 +127,16 @@ func (db *AgbotPostgresqlDB) Initialize(cfg *config.HorizonConfig) error {
 			glog.V(3).Infof("Postgresql database tables are at version %v, %v, as of %v.", dbVersion, description, timestamp)
 		}
 
+		// Several agbots may start at the same time, so the upgrade is done while holding the schema lock. An agbot
+		// that is older than the schema leaves it alone, newer versions only add to the schema.
 		if dbVersion < HIGHEST_DATABASE_VERSION {
 			glog.V(3).Infof("Postgresql database tables upgrading from version %v to %v.", dbVersion, HIGHEST_DATABASE_VERSION)
-
-			// Each new database version has it's own key in the migration SQL map.
-			for v := dbVersion + 1; v <= HIGHEST_DATABASE_VERSION; v++ {
-
-				// Run each SQL statement in the array of SQL statements for the current version.
-				for si := 0; si < len(migrationSQL[v].sql); si++ {
-					if _, err := db.db.Exec(migrationSQL[v].sql[si]); err != nil {
-						return errors.New(fmt.Sprintf("unable to run SQL migration statement version %v, index %v, statement %v, error: %v", v, si, migrationSQL[v].sql[si], err))
-					} else if _, err := db.db.Exec(VERSION_UPDATE, HIGHEST_DATABASE_VERSION, migrationSQL[v].description); err != nil {
-						return errors.New(fmt.Sprintf("unable to create version table, error: %v", err))
-					} else {
-						glog.V(3).Infof("Postgresql database tables upgraded to version %v, %v", v, migrationSQL[v].description)
-					}
-				}
+			if err := db.MigrateSchema(HIGHEST_DATABASE_VERSION, false); err != nil {
+				return err
 			}
-
 			glog.V(3).Infof("Postgresql database tables upgraded to version %v", HIGHEST_DATABASE_VERSION)
+		} else if dbVersion > HIGHEST_DATABASE_VERSION {
+			glog.Warningf("Postgresql database tables are at version %v, which is newer than version %v supported by this agbot.", dbVersion, HIGHEST_DATABASE_VERSION)
 		}
 
 		glog.V(3).Infof("Postgresql database tables initialized.")
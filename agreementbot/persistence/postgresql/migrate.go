@@ -0,0 +1,214 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"strings"
+)
+
+// Functions used to copy the agbot database to or from another database implementation. Queries against the main
+// tables (agreements, workload_usages, etc) include the rows of every partition table, so they are used to find
+// records imported by a previous copy, even if that copy used a different partition. New records are inserted into
+// the primary partition.
+
+const MIGRATE_COUNT_AGREEMENTS = `SELECT COUNT(*) FROM agreements;`
+const MIGRATE_COUNT_WORKLOAD_USAGES = `SELECT COUNT(*) FROM workload_usages;`
+const MIGRATE_COUNT_SEARCH_SESSIONS = `SELECT COUNT(*) FROM search_sessions;`
+const MIGRATE_COUNT_SECRETS = `SELECT (SELECT COUNT(*) FROM secrets_policy) + (SELECT COUNT(*) FROM secrets_pattern);`
+
+const MIGRATE_AGREEMENT_PARTITION = `SELECT partition FROM agreements WHERE agreement_id = $1 AND protocol = $2;`
+const MIGRATE_WORKLOAD_USAGE_PARTITION = `SELECT partition FROM workload_usages WHERE device_id = $1 AND policy_name = $2;`
+
+const MIGRATE_ALL_SEARCH_SESSIONS = `SELECT policyName, changedSince, sessionToken, sessionEnded FROM search_sessions;`
+const MIGRATE_SEARCH_SESSION_UPSERT = `INSERT INTO search_sessions (policyName, changedSince, sessionToken, sessionEnded, restartChangedSince, updatingAgbot, updated)
+	VALUES ($1, $2, $3, true, 0, $4, current_timestamp)
+	ON CONFLICT (policyName) DO UPDATE SET changedSince = $2, sessionToken = $3, sessionEnded = true, restartChangedSince = 0, updatingAgbot = $4, updated = current_timestamp
+	RETURNING (xmax = 0);`
+
+const MIGRATE_ALL_SECRETS_POLICY = `SELECT secret_org, secret_name, policy_org, policy_name, last_update_check FROM secrets_policy;`
+const MIGRATE_ALL_SECRETS_PATTERN = `SELECT secret_org, secret_name, pattern_org, pattern_name, last_update_check FROM secrets_pattern;`
+const MIGRATE_SECRET_UPDATE_POLICY = `UPDATE secrets_policy SET last_update_check = $5, updated = current_timestamp WHERE secret_org = $1 AND secret_name = $2 AND policy_org = $3 AND policy_name = $4;`
+const MIGRATE_SECRET_UPDATE_PATTERN = `UPDATE secrets_pattern SET last_update_check = $5, updated = current_timestamp WHERE secret_org = $1 AND secret_name = $2 AND pattern_org = $3 AND pattern_name = $4;`
+
+func (db *AgbotPostgresqlDB) GetRecordCounts() (persistence.RecordCounts, error) {
+	counts := persistence.RecordCounts{}
+	for table, query := range map[string]string{
+		persistence.TABLE_AGREEMENTS:      MIGRATE_COUNT_AGREEMENTS,
+		persistence.TABLE_WORKLOAD_USAGES: MIGRATE_COUNT_WORKLOAD_USAGES,
+		persistence.TABLE_SEARCH_SESSIONS: MIGRATE_COUNT_SEARCH_SESSIONS,
+		persistence.TABLE_MANAGED_SECRETS: MIGRATE_COUNT_SECRETS,
+	} {
+		var num int64
+		if err := db.db.QueryRow(query).Scan(&num); err != nil {
+			return nil, errors.New(fmt.Sprintf("error counting %v, error: %v", table, err))
+		}
+		counts[table] = num
+	}
+	return counts, nil
+}
+
+// Returns the partition holding a record, or empty string if there is no such record.
+func (db *AgbotPostgresqlDB) findRecordPartition(tx *sql.Tx, query string, args ...interface{}) (string, error) {
+	var partition string
+	if err := tx.QueryRow(query, args...).Scan(&partition); err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return partition, nil
+}
+
+// Run the import function in a transaction.
+func (db *AgbotPostgresqlDB) importInTransaction(fn func(tx *sql.Tx) (persistence.ImportResult, error)) (persistence.ImportResult, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return persistence.IMPORT_SKIPPED, err
+	}
+	if res, err := fn(tx); err != nil {
+		tx.Rollback()
+		return res, err
+	} else {
+		return res, tx.Commit()
+	}
+}
+
+// The agreement replaces the agreement with the same id in any partition, otherwise it is added to the primary partition.
+func (db *AgbotPostgresqlDB) ImportAgreement(ag *persistence.Agreement, protocol string) (persistence.ImportResult, error) {
+	return db.importInTransaction(func(tx *sql.Tx) (persistence.ImportResult, error) {
+		agm, err := json.Marshal(ag)
+		if err != nil {
+			return persistence.IMPORT_SKIPPED, err
+		}
+
+		if partition, err := db.findRecordPartition(tx, MIGRATE_AGREEMENT_PARTITION, ag.CurrentAgreementId, protocol); err != nil {
+			return persistence.IMPORT_SKIPPED, errors.New(fmt.Sprintf("error finding agreement %v, error: %v", ag.CurrentAgreementId, err))
+		} else if partition != "" {
+			sqlStr := strings.Replace(AGREEMENT_UPDATE, AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(partition), 1)
+			_, err := tx.Exec(sqlStr, ag.CurrentAgreementId, protocol, agm)
+			return persistence.IMPORT_UPDATED, err
+		}
+
+		sqlStr := strings.Replace(AGREEMENT_INSERT, AGREEMENT_TABLE_NAME_ROOT, db.GetAgreementPartitionTableName(db.PrimaryPartition()), 1)
+		_, err = tx.Exec(sqlStr, ag.CurrentAgreementId, protocol, db.PrimaryPartition(), agm)
+		return persistence.IMPORT_INSERTED, err
+	})
+}
+
+// The workload usage replaces the record for the same device and policy in any partition, otherwise it is added to
+// the primary partition.
+func (db *AgbotPostgresqlDB) ImportWorkloadUsage(wu *persistence.WorkloadUsage) (persistence.ImportResult, error) {
+	return db.importInTransaction(func(tx *sql.Tx) (persistence.ImportResult, error) {
+		wum, err := json.Marshal(wu)
+		if err != nil {
+			return persistence.IMPORT_SKIPPED, err
+		}
+
+		if partition, err := db.findRecordPartition(tx, MIGRATE_WORKLOAD_USAGE_PARTITION, wu.DeviceId, wu.PolicyName); err != nil {
+			return persistence.IMPORT_SKIPPED, errors.New(fmt.Sprintf("error finding workload usage for %v and policy %v, error: %v", wu.DeviceId, wu.PolicyName, err))
+		} else if partition != "" {
+			sqlStr := strings.Replace(WORKLOAD_USAGE_UPDATE, WORKLOAD_USAGE_TABLE_NAME_ROOT, db.GetWorkloadUsagePartitionTableName(partition), 1)
+			_, err := tx.Exec(sqlStr, wu.DeviceId, wu.PolicyName, wum)
+			return persistence.IMPORT_UPDATED, err
+		}
+
+		sqlStr := strings.Replace(WORKLOAD_USAGE_INSERT, WORKLOAD_USAGE_TABLE_NAME_ROOT, db.GetWorkloadUsagePartitionTableName(db.PrimaryPartition()), 1)
+		_, err = tx.Exec(sqlStr, wu.DeviceId, wu.PolicyName, db.PrimaryPartition(), wum)
+		return persistence.IMPORT_INSERTED, err
+	})
+}
+
+func (db *AgbotPostgresqlDB) FindSearchSessions() ([]persistence.SearchSession, error) {
+	rows, err := db.db.Query(MIGRATE_ALL_SEARCH_SESSIONS)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying search sessions, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+
+	sessions := make([]persistence.SearchSession, 0)
+	for rows.Next() {
+		ss := persistence.SearchSession{}
+		if err := rows.Scan(&ss.PolicyName, &ss.ChangedSince, &ss.SessionToken, &ss.SessionEnded); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning search session row, error: %v", err))
+		}
+		sessions = append(sessions, ss)
+	}
+	return sessions, rows.Err()
+}
+
+// Search sessions are kept per policy. A session that is shared by all policies, as in the bolt DB, is not imported
+// because the sessions are created as each policy is searched.
+func (db *AgbotPostgresqlDB) ImportSearchSession(ss *persistence.SearchSession) (persistence.ImportResult, error) {
+	if ss.PolicyName == "" {
+		glog.V(3).Infof("Skipping import of search session %v, it does not belong to a policy", ss)
+		return persistence.IMPORT_SKIPPED, nil
+	}
+
+	var inserted bool
+	if err := db.db.QueryRow(MIGRATE_SEARCH_SESSION_UPSERT, ss.PolicyName, ss.ChangedSince, ss.SessionToken, db.identity).Scan(&inserted); err != nil {
+		return persistence.IMPORT_SKIPPED, errors.New(fmt.Sprintf("error importing search session %v, error: %v", ss, err))
+	} else if inserted {
+		return persistence.IMPORT_INSERTED, nil
+	}
+	return persistence.IMPORT_UPDATED, nil
+}
+
+func (db *AgbotPostgresqlDB) FindManagedSecrets() ([]persistence.ManagedSecret, error) {
+	secrets := make([]persistence.ManagedSecret, 0)
+	for _, isPattern := range []bool{false, true} {
+		query := MIGRATE_ALL_SECRETS_POLICY
+		if isPattern {
+			query = MIGRATE_ALL_SECRETS_PATTERN
+		}
+
+		rows, err := db.db.Query(query)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error querying managed secrets, error: %v", err))
+		}
+
+		// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+		defer rows.Close()
+		for rows.Next() {
+			ms := persistence.ManagedSecret{IsPattern: isPattern}
+			if err := rows.Scan(&ms.SecretOrg, &ms.SecretName, &ms.DeploymentOrg, &ms.DeploymentName, &ms.LastUpdateCheck); err != nil {
+				return nil, errors.New(fmt.Sprintf("error scanning managed secret row, error: %v", err))
+			}
+			secrets = append(secrets, ms)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, errors.New(fmt.Sprintf("error iterating managed secrets, error: %v", err))
+		}
+	}
+	return secrets, nil
+}
+
+// The managed secret replaces the same secret for the same policy or pattern in any partition, otherwise it is added
+// to the primary partition.
+func (db *AgbotPostgresqlDB) ImportManagedSecret(ms *persistence.ManagedSecret) (persistence.ImportResult, error) {
+	update := MIGRATE_SECRET_UPDATE_POLICY
+	if ms.IsPattern {
+		update = MIGRATE_SECRET_UPDATE_PATTERN
+	}
+
+	if result, err := db.db.Exec(update, ms.SecretOrg, ms.SecretName, ms.DeploymentOrg, ms.DeploymentName, ms.LastUpdateCheck); err != nil {
+		return persistence.IMPORT_SKIPPED, errors.New(fmt.Sprintf("error importing managed secret %v, error: %v", ms, err))
+	} else if n, err := result.RowsAffected(); err == nil && n != 0 {
+		return persistence.IMPORT_UPDATED, nil
+	}
+
+	var err error
+	if ms.IsPattern {
+		err = db.AddManagedPatternSecret(ms.SecretOrg, ms.SecretName, ms.DeploymentOrg, ms.DeploymentName, ms.LastUpdateCheck)
+	} else {
+		err = db.AddManagedPolicySecret(ms.SecretOrg, ms.SecretName, ms.DeploymentOrg, ms.DeploymentName, ms.LastUpdateCheck)
+	}
+	if err != nil {
+		return persistence.IMPORT_SKIPPED, err
+	}
+	return persistence.IMPORT_INSERTED, nil
+}
@@ -0,0 +1,64 @@
+package postgresql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Constants for the SQL statements that are used to save staged rollouts. The devices in a rollout are the devices whose
+// workload usages are in the agbot's partition, so the rollouts are saved per partition too.
+//
+// rollouts schema:
+// policy_name: The fully qualified (org/policy-name) policy being rolled out.
+// partition:   The agbot partition that this rollout lives in.
+// rollout:     The rollout object which is a JSON blob. The blob schema is defined by the Rollout struct in the agreementbot package.
+// updated:     A timestamp to record last updated time.
+//
+
+const ROLLOUT_CREATE_MAIN_TABLE = `CREATE TABLE IF NOT EXISTS rollouts (
+	policy_name text NOT NULL,
+	partition text NOT NULL,
+	rollout jsonb NOT NULL,
+	updated timestamp with time zone DEFAULT current_timestamp,
+	PRIMARY KEY (policy_name, partition)
+);`
+
+const ROLLOUT_SAVE = `INSERT INTO rollouts (policy_name, partition, rollout) VALUES ($1, $2, $3)
+	ON CONFLICT (policy_name, partition) DO UPDATE SET rollout = EXCLUDED.rollout, updated = current_timestamp;`
+
+const ROLLOUT_QUERY = `SELECT policy_name, rollout FROM rollouts WHERE partition = $1;`
+
+// Functions related to the rollouts table.
+
+// Save the rollout of a policy, replacing the rollout that was saved for the policy before.
+func (db *AgbotPostgresqlDB) SaveRollout(policyName string, rollout string) error {
+	if _, err := db.db.Exec(ROLLOUT_SAVE, policyName, db.PrimaryPartition(), rollout); err != nil {
+		return errors.New(fmt.Sprintf("error saving rollout for %v, error: %v", policyName, err))
+	}
+	return nil
+}
+
+// Return the rollouts saved in the agbot's partition, keyed by policy name.
+func (db *AgbotPostgresqlDB) FindRollouts() (map[string]string, error) {
+	rows, err := db.db.Query(ROLLOUT_QUERY, db.PrimaryPartition())
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for rollouts, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+
+	rollouts := make(map[string]string)
+	for rows.Next() {
+		var policyName, rollout string
+		if err := rows.Scan(&policyName, &rollout); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row for rollout, error: %v", err))
+		}
+		rollouts[policyName] = rollout
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error iterating rollouts, error: %v", err))
+	}
+	return rollouts, nil
+}
@@ -1,6 +1,12 @@
 package postgresql
 
-import ()
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+)
 
 // Constants for the SQL statements that are used to work with the database version. The entire database schema has a single
 // version that is kept in the version table. Agbots automatically upgrade the database during initialization based on their version
@@ -19,6 +25,9 @@ const VERSION_CREATE_TABLE = `CREATE TABLE IF NOT EXISTS version (
 
 const VERSION_QUERY = `SELECT ver, description, updated FROM version WHERE id = 1;`
 
+// Used while holding the schema lock, to see the version left by another agbot that held the lock before us.
+const VERSION_QUERY_VERSION = `SELECT ver FROM version WHERE id = 1;`
+
 // There should only be 1 row in this table.
 const VERSION_INSERT = `DO $$
 BEGIN
@@ -29,12 +38,183 @@ END $$`
 
 const VERSION_UPDATE = `UPDATE version SET ver = $1, description = $2, updated = current_timestamp WHERE id = 1;`
 
+// version_history schema:
+// from_ver:    The version of the schema before the change.
+// to_ver:      The version of the schema after the change. It is less than from_ver when a change was undone.
+// description: The description of the schema change.
+// agbot_id:    The identity of the agbot that made the change.
+// applied:     A timestamp to record when the change was made.
+//
+const VERSION_HISTORY_CREATE_TABLE = `CREATE TABLE IF NOT EXISTS version_history (
+	id serial PRIMARY KEY,
+	from_ver int NOT NULL,
+	to_ver int NOT NULL,
+	description text NOT NULL,
+	agbot_id text NOT NULL,
+	applied timestamp with time zone DEFAULT current_timestamp
+);`
+
+const VERSION_HISTORY_INSERT = `INSERT INTO version_history (from_ver, to_ver, description, agbot_id) VALUES ($1, $2, $3, $4);`
+
+const VERSION_HISTORY_QUERY = `SELECT from_ver, to_ver, description, agbot_id, applied FROM version_history ORDER BY id DESC LIMIT 10;`
+
+// Agbots that start at the same time serialize their schema changes with a session level advisory lock. The key is an
+// arbitrary number that is only used for this lock.
+const SCHEMA_LOCK_KEY = 4206091701
+
+const VERSION_LOCK = `SELECT pg_advisory_lock($1);`
+const VERSION_UNLOCK = `SELECT pg_advisory_unlock($1);`
+
+// To change the schema, add the next version to the migrationSQL map and set HIGHEST_DATABASE_VERSION to it. For example,
+// adding a column to the agreements table:
+//
+//   const v2 = 1
+//
+//   v2: SchemaUpdate{
+//       sql:         []string{`ALTER TABLE agreements ADD COLUMN IF NOT EXISTS node_type text;`},
+//       down:        []string{`ALTER TABLE agreements DROP COLUMN IF EXISTS node_type;`},
+//       description: "add node type to agreements",
+//   },
+//
+// The statements of a version run in a single transaction along with the version update, so a version is either
+// applied completely or not at all. Changes to the main tables (agreements, workload_usages, etc) are inherited by all
+// the partition tables.
 const HIGHEST_DATABASE_VERSION = v1
 const v1 = 0
 
 type SchemaUpdate struct {
 	sql         []string // The SQL statements to run for an update to the schema.
+	down        []string // The SQL statements that undo the update. A version without them cannot be undone.
 	description string   // A description of the schema change.
 }
 
 var migrationSQL = map[int]SchemaUpdate{}
+
+// A single change of the schema version, either applying or undoing a SchemaUpdate.
+type schemaStep struct {
+	from        int
+	to          int
+	sql         []string
+	description string
+}
+
+// Returns the steps that move the schema from the current version to the target version.
+func schemaSteps(current int, target int, updates map[int]SchemaUpdate) ([]schemaStep, error) {
+	steps := make([]schemaStep, 0)
+
+	for v := current + 1; v <= target; v++ {
+		if update, ok := updates[v]; !ok {
+			return nil, errors.New(fmt.Sprintf("there is no schema update for version %v", v))
+		} else {
+			steps = append(steps, schemaStep{from: v - 1, to: v, sql: update.sql, description: update.description})
+		}
+	}
+
+	for v := current; v > target; v-- {
+		if update, ok := updates[v]; !ok {
+			return nil, errors.New(fmt.Sprintf("there is no schema update for version %v", v))
+		} else if len(update.down) == 0 {
+			return nil, errors.New(fmt.Sprintf("schema version %v, %v, cannot be undone", v, update.description))
+		} else {
+			description := "initial tables"
+			if previous, ok := updates[v-1]; ok {
+				description = previous.description
+			}
+			steps = append(steps, schemaStep{from: v, to: v - 1, sql: update.down, description: description})
+		}
+	}
+
+	return steps, nil
+}
+
+// Move the database schema to the target version. The advisory lock is held on a single connection for the entire
+// migration, so other agbots wait until it is done and then find the schema already at the version they need. Schema
+// versions newer than the target are only undone when undo is true, otherwise the schema is left as it is.
+func (db *AgbotPostgresqlDB) MigrateSchema(target int, undo bool) error {
+	if target < v1 || target > HIGHEST_DATABASE_VERSION {
+		return errors.New(fmt.Sprintf("schema version %v is not supported, the highest supported version is %v", target, HIGHEST_DATABASE_VERSION))
+	}
+
+	ctx := context.Background()
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to get a database connection for the schema migration, error: %v", err))
+	}
+	defer conn.Close()
+
+	glog.V(3).Infof("Postgresql database waiting for the schema lock.")
+	if _, err := conn.ExecContext(ctx, VERSION_LOCK, SCHEMA_LOCK_KEY); err != nil {
+		return errors.New(fmt.Sprintf("unable to obtain the schema lock, error: %v", err))
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, VERSION_UNLOCK, SCHEMA_LOCK_KEY); err != nil {
+			glog.Errorf("Unable to release the schema lock, error: %v", err)
+		}
+	}()
+
+	var current int
+	if err := conn.QueryRowContext(ctx, VERSION_QUERY_VERSION).Scan(&current); err != nil {
+		return errors.New(fmt.Sprintf("error scanning row for current version, error: %v", err))
+	} else if current > target && !undo {
+		glog.V(3).Infof("Postgresql database tables are already at version %v, newer than version %v.", current, target)
+		return nil
+	}
+
+	steps, err := schemaSteps(current, target, migrationSQL)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to move the schema from version %v to %v, error: %v", current, target, err))
+	}
+
+	for _, step := range steps {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.New(fmt.Sprintf("unable to start transaction for schema version %v, error: %v", step.to, err))
+		}
+
+		// Run each SQL statement in the array of SQL statements for the current version.
+		for si, stmt := range step.sql {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return errors.New(fmt.Sprintf("unable to run SQL migration statement version %v, index %v, statement %v, error: %v", step.to, si, stmt, err))
+			}
+		}
+
+		if _, err := tx.Exec(VERSION_UPDATE, step.to, step.description); err != nil {
+			tx.Rollback()
+			return errors.New(fmt.Sprintf("unable to update version to %v, error: %v", step.to, err))
+		} else if _, err := tx.Exec(VERSION_HISTORY_INSERT, step.from, step.to, step.description, db.identity); err != nil {
+			tx.Rollback()
+			return errors.New(fmt.Sprintf("unable to record version %v in the version history, error: %v", step.to, err))
+		} else if err := tx.Commit(); err != nil {
+			return errors.New(fmt.Sprintf("unable to commit schema version %v, error: %v", step.to, err))
+		}
+
+		glog.V(3).Infof("Postgresql database tables moved from version %v to %v, %v", step.from, step.to, step.description)
+	}
+
+	return nil
+}
+
+func (db *AgbotPostgresqlDB) GetSchemaStatus() (*persistence.SchemaStatus, error) {
+	status := &persistence.SchemaStatus{Database: "postgresql", HighestVersion: HIGHEST_DATABASE_VERSION, History: make([]persistence.SchemaChange, 0)}
+
+	if err := db.db.QueryRow(VERSION_QUERY).Scan(&status.Version, &status.Description, &status.Updated); err != nil {
+		return nil, errors.New(fmt.Sprintf("error scanning row for current version, error: %v", err))
+	}
+
+	rows, err := db.db.Query(VERSION_HISTORY_QUERY)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying version history, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+	for rows.Next() {
+		change := persistence.SchemaChange{}
+		if err := rows.Scan(&change.FromVersion, &change.ToVersion, &change.Description, &change.AgbotId, &change.Applied); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning version history row, error: %v", err))
+		}
+		status.History = append(status.History, change)
+	}
+	return status, rows.Err()
+}
@@ -0,0 +1,52 @@
+// +build unit
+
+package postgresql
+
+import (
+	"testing"
+)
+
+func Test_schemaSteps(t *testing.T) {
+	updates := map[int]SchemaUpdate{
+		1: SchemaUpdate{sql: []string{"up1"}, down: []string{"down1"}, description: "one"},
+		2: SchemaUpdate{sql: []string{"up2a", "up2b"}, down: []string{"down2"}, description: "two"},
+		3: SchemaUpdate{sql: []string{"up3"}, description: "three"},
+	}
+
+	// Upgrade from the initial tables.
+	if steps, err := schemaSteps(0, 3, updates); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(steps) != 3 {
+		t.Errorf("expected 3 steps, got %v", steps)
+	} else if steps[0].from != 0 || steps[0].to != 1 || steps[1].sql[1] != "up2b" || steps[2].to != 3 || steps[2].description != "three" {
+		t.Errorf("unexpected steps %v", steps)
+	}
+
+	// Nothing to do.
+	if steps, err := schemaSteps(2, 2, updates); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(steps) != 0 {
+		t.Errorf("expected no steps, got %v", steps)
+	}
+
+	// Undo back to the initial tables, the description is the one of the version that is left in place.
+	if steps, err := schemaSteps(2, 0, updates); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(steps) != 2 {
+		t.Errorf("expected 2 steps, got %v", steps)
+	} else if steps[0].from != 2 || steps[0].to != 1 || steps[0].sql[0] != "down2" || steps[0].description != "one" {
+		t.Errorf("unexpected first step %v", steps[0])
+	} else if steps[1].to != 0 || steps[1].sql[0] != "down1" || steps[1].description != "initial tables" {
+		t.Errorf("unexpected second step %v", steps[1])
+	}
+
+	// Version 3 has no down statements.
+	if _, err := schemaSteps(3, 2, updates); err == nil {
+		t.Errorf("expected an error undoing version 3")
+	}
+
+	// There is no version 4.
+	if _, err := schemaSteps(2, 4, updates); err == nil {
+		t.Errorf("expected an error upgrading to version 4")
+	}
+}
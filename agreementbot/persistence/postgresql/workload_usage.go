@@ -78,6 +78,7 @@ const WORKLOAD_USAGE_PARTITION_FILLIN = `partition_name`
 
 const WORKLOAD_USAGE_QUERY = `SELECT workload_usage FROM "workload_usages_ WHERE device_id = $1 AND policy_name = $2;`
 const ALL_WORKLOAD_USAGE_QUERY = `SELECT workload_usage FROM "workload_usages_;`
+const ALL_PARTITIONS_WORKLOAD_USAGE_QUERY = `SELECT workload_usage FROM workload_usages;`
 
 const WORKLOAD_USAGE_COUNT = `SELECT COUNT(*) FROM "workload_usages_;`
 
@@ -230,6 +231,44 @@ func (db *AgbotPostgresqlDB) FindWorkloadUsages(filters []persistence.WUFilter)
 	return wus, nil
 }
 
+// Find the workload usages in all partitions by querying the main table, which includes the rows of every partition table.
+func (db *AgbotPostgresqlDB) FindWorkloadUsagesInAllPartitions(filters []persistence.WUFilter) ([]persistence.WorkloadUsage, error) {
+
+	rows, err := db.db.Query(ALL_PARTITIONS_WORKLOAD_USAGE_QUERY)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error querying for workload usages in all partitions, error: %v", err))
+	}
+
+	// If the rows object doesnt get closed, memory and connections will grow and/or leak.
+	defer rows.Close()
+
+	wus := make([]persistence.WorkloadUsage, 0, 100)
+	for rows.Next() {
+		wuBytes := make([]byte, 0, 2048)
+		wu := new(persistence.WorkloadUsage)
+		if err := rows.Scan(&wuBytes); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v", err))
+		} else if err := json.Unmarshal(wuBytes, wu); err != nil {
+			return nil, errors.New(fmt.Sprintf("error demarshalling row: %v, error: %v", string(wuBytes), err))
+		}
+		exclude := false
+		for _, filterFn := range filters {
+			if !filterFn(*wu) {
+				exclude = true
+			}
+		}
+		if !exclude {
+			wus = append(wus, *wu)
+		}
+	}
+
+	// The rows.Next() function will exit with false when done or an error occurred. Get any error encountered during iteration.
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error iterating: %v", err))
+	}
+	return wus, nil
+}
+
 func (db *AgbotPostgresqlDB) NewWorkloadUsage(deviceId string, hapartners []string, policy string, policyName string, priority int, retryDurationS int, verifiedDurationS int, reqsNotMet bool, agid string) error {
 	if wlUsage, err := persistence.NewWorkloadUsage(deviceId, hapartners, policy, policyName, priority, retryDurationS, verifiedDurationS, reqsNotMet, agid); err != nil {
 		return err
@@ -244,10 +283,28 @@ func (db *AgbotPostgresqlDB) NewWorkloadUsage(deviceId string, hapartners []stri
 	}
 }
 
+func (db *AgbotPostgresqlDB) NewHeldUpgradeWorkloadUsage(deviceId string, hapartners []string, policyName string, agid string, upgrade *persistence.HeldUpgrade) error {
+	if wlUsage, err := persistence.NewHeldUpgradeWorkloadUsage(deviceId, hapartners, policyName, agid, upgrade); err != nil {
+		return err
+	} else if existing, partition, err := db.internalFindSingleWorkloadUsageByDeviceAndPolicyName(nil, deviceId, policyName); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("Workload usage record for device %v and policy name %v already exists in partition %v.", deviceId, policyName, partition)
+	} else if err := db.insertWorkloadUsage(nil, wlUsage); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
 func (db *AgbotPostgresqlDB) UpdatePendingUpgrade(deviceid string, policyName string) (*persistence.WorkloadUsage, error) {
 	return persistence.UpdatePendingUpgrade(db, deviceid, policyName)
 }
 
+func (db *AgbotPostgresqlDB) UpdateHeldUpgrade(deviceid string, policyName string, upgrade *persistence.HeldUpgrade) (*persistence.WorkloadUsage, error) {
+	return persistence.UpdateHeldUpgrade(db, deviceid, policyName, upgrade)
+}
+
 func (db *AgbotPostgresqlDB) UpdateRetryCount(deviceid string, policyName string, retryCount int, agid string) (*persistence.WorkloadUsage, error) {
 	return persistence.UpdateRetryCount(db, deviceid, policyName, retryCount, agid)
 }
@@ -0,0 +1,22 @@
+package persistence
+
+// Databases that version their schema report the current version and the most recent changes to it, so that the
+// state of a schema migration shared by several agbots can be seen from any of them.
+
+type SchemaStatus struct {
+	Database       string         `json:"database"`
+	Version        int            `json:"version"`         // The current version of the schema in the database
+	HighestVersion int            `json:"highest_version"` // The highest schema version known to this agbot
+	Description    string         `json:"description,omitempty"`
+	Updated        string         `json:"updated,omitempty"`
+	History        []SchemaChange `json:"history,omitempty"` // The most recent changes, newest first
+}
+
+// A schema change made by an agbot. The ToVersion is lower than the FromVersion when a change was undone.
+type SchemaChange struct {
+	FromVersion int    `json:"from_version"`
+	ToVersion   int    `json:"to_version"`
+	Description string `json:"description"`
+	AgbotId     string `json:"agbot_id"`
+	Applied     string `json:"applied"`
+}
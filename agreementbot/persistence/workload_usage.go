@@ -7,21 +7,43 @@ import (
 )
 
 type WorkloadUsage struct {
-	Id                 uint64   `json:"record_id"`            // unique primary key for records
-	DeviceId           string   `json:"device_id"`            // the device id we are working with, immutable after construction
-	HAPartners         []string `json:"ha_partners"`          // list of device id(s) which are partners to this device
-	PendingUpgradeTime uint64   `json:"pending_upgrade_time"` // time when this usage was marked for pending upgrade
-	Policy             string   `json:"policy"`               // the policy containing the workloads we're managing
-	PolicyName         string   `json:"policy_name"`          // the name of the policy containing the workloads we're managing
-	Priority           int      `json:"priority"`             // the workload priority that we're working with
-	RetryCount         int      `json:"retry_count"`          // The number of retries attempted so far
-	RetryDurationS     int      `json:"retry_durations"`      // The number of seconds in which the specified number of retries must occur in order for the next priority workload to be attempted.
-	CurrentAgreementId string   `json:"current_agreement_id"` // the agreement id currently in use
-	FirstTryTime       uint64   `json:"first_try_time"`       // time when first agrement attempt was made, used to count retries per time
-	LatestRetryTime    uint64   `json:"latest_retry_time"`    // time when the newest retry has occurred
-	DisableRetry       bool     `json:"disable_retry"`        // when true, retry and retry durations are disbled which effectively disables workload rollback
-	VerifiedDurationS  int      `json:"verified_durations"`   // the number of seconds for successful data verification before disabling workload rollback retries
-	ReqsNotMet         bool     `json:"requirements_not_met"` // this workload usage record is not at the highest priority because the device did not meet the API spec requirements at one of the higher priorities
+	Id                 uint64       `json:"record_id"`              // unique primary key for records
+	DeviceId           string       `json:"device_id"`              // the device id we are working with, immutable after construction
+	HAPartners         []string     `json:"ha_partners"`            // list of device id(s) which are partners to this device
+	PendingUpgradeTime uint64       `json:"pending_upgrade_time"`   // time when this usage was marked for pending upgrade
+	Policy             string       `json:"policy"`                 // the policy containing the workloads we're managing
+	PolicyName         string       `json:"policy_name"`            // the name of the policy containing the workloads we're managing
+	Priority           int          `json:"priority"`               // the workload priority that we're working with
+	RetryCount         int          `json:"retry_count"`            // The number of retries attempted so far
+	RetryDurationS     int          `json:"retry_durations"`        // The number of seconds in which the specified number of retries must occur in order for the next priority workload to be attempted.
+	CurrentAgreementId string       `json:"current_agreement_id"`   // the agreement id currently in use
+	FirstTryTime       uint64       `json:"first_try_time"`         // time when first agrement attempt was made, used to count retries per time
+	LatestRetryTime    uint64       `json:"latest_retry_time"`      // time when the newest retry has occurred
+	DisableRetry       bool         `json:"disable_retry"`          // when true, retry and retry durations are disbled which effectively disables workload rollback
+	VerifiedDurationS  int          `json:"verified_durations"`     // the number of seconds for successful data verification before disabling workload rollback retries
+	ReqsNotMet         bool         `json:"requirements_not_met"`   // this workload usage record is not at the highest priority because the device did not meet the API spec requirements at one of the higher priorities
+	HeldUpgrade        *HeldUpgrade `json:"held_upgrade,omitempty"` // an upgrade of the workload that is being held back by the upgrade policy of the newer workload
+}
+
+// A workload upgrade that the upgrade policy does not allow to happen yet. The upgrade is done by the agbot
+// when the scheduled time arrives. When there is no scheduled time, the upgrade happens when the current agreement ends.
+type HeldUpgrade struct {
+	Version       string `json:"version"`        // the version of the workload that the device will be upgraded to
+	Lifecycle     string `json:"lifecycle"`      // the lifecycle from the upgrade policy, immediate, never or agreement
+	Time          string `json:"time"`           // the time from the upgrade policy, an RFC3339 time or a daily UTC window
+	HeldTime      uint64 `json:"held_time"`      // time when the upgrade was first held
+	ScheduledTime uint64 `json:"scheduled_time"` // time when the upgrade will be done, 0 means when the current agreement ends or when the rollout releases it
+	Rollout       bool   `json:"rollout"`        // the upgrade is part of a staged rollout and is done when the rollout releases it
+}
+
+func (h HeldUpgrade) String() string {
+	return fmt.Sprintf("Version: %v, "+
+		"Lifecycle: %v, "+
+		"Time: %v, "+
+		"HeldTime: %v, "+
+		"ScheduledTime: %v, "+
+		"Rollout: %v",
+		h.Version, h.Lifecycle, h.Time, h.HeldTime, h.ScheduledTime, h.Rollout)
 }
 
 func (w WorkloadUsage) String() string {
@@ -39,9 +61,10 @@ func (w WorkloadUsage) String() string {
 		"DisableRetry: %v, "+
 		"VerifiedDurationS: %v, "+
 		"ReqsNotMet: %v, "+
+		"HeldUpgrade: %v, "+
 		"Policy: %v",
 		w.Id, w.DeviceId, w.HAPartners, w.PendingUpgradeTime, w.PolicyName, w.Priority, w.RetryCount,
-		w.RetryDurationS, w.CurrentAgreementId, w.FirstTryTime, w.LatestRetryTime, w.DisableRetry, w.VerifiedDurationS, w.ReqsNotMet, w.Policy)
+		w.RetryDurationS, w.CurrentAgreementId, w.FirstTryTime, w.LatestRetryTime, w.DisableRetry, w.VerifiedDurationS, w.ReqsNotMet, w.HeldUpgrade, w.Policy)
 }
 
 func (w WorkloadUsage) ShortString() string {
@@ -58,9 +81,10 @@ func (w WorkloadUsage) ShortString() string {
 		"LatestRetryTime: %v, "+
 		"DisableRetry: %v, "+
 		"VerifiedDurationS: %v, "+
-		"ReqsNotMet: %v",
+		"ReqsNotMet: %v, "+
+		"HeldUpgrade: %v",
 		w.Id, w.DeviceId, w.HAPartners, w.PendingUpgradeTime, w.PolicyName, w.Priority, w.RetryCount,
-		w.RetryDurationS, w.CurrentAgreementId, w.FirstTryTime, w.LatestRetryTime, w.DisableRetry, w.VerifiedDurationS, w.ReqsNotMet)
+		w.RetryDurationS, w.CurrentAgreementId, w.FirstTryTime, w.LatestRetryTime, w.DisableRetry, w.VerifiedDurationS, w.ReqsNotMet, w.HeldUpgrade)
 }
 
 // private factory method for workloadusage w/out persistence safety:
@@ -88,6 +112,27 @@ func NewWorkloadUsage(deviceId string, hapartners []string, policy string, polic
 	}
 }
 
+// Factory method for a workload usage that only exists to hold back a workload upgrade. It is used when the policy
+// does not specify workload priorities, so there is no workload rollback to track. Retries are disabled so that the
+// record does not affect the choice of workload for the next agreement.
+func NewHeldUpgradeWorkloadUsage(deviceId string, hapartners []string, policyName string, agid string, upgrade *HeldUpgrade) (*WorkloadUsage, error) {
+
+	if deviceId == "" || policyName == "" || agid == "" || upgrade == nil {
+		return nil, errors.New("Illegal input: one of deviceId, policyName, agreement id or held upgrade is empty")
+	} else {
+		return &WorkloadUsage{
+			DeviceId:           deviceId,
+			HAPartners:         hapartners,
+			PendingUpgradeTime: 0,
+			PolicyName:         policyName,
+			CurrentAgreementId: agid,
+			FirstTryTime:       uint64(time.Now().Unix()),
+			DisableRetry:       true,
+			HeldUpgrade:        upgrade,
+		}, nil
+	}
+}
+
 func UpdateRetryCount(db AgbotDatabase, deviceid string, policyName string, retryCount int, agid string) (*WorkloadUsage, error) {
 	if wlUsage, err := db.SingleWorkloadUsageUpdate(deviceid, policyName, func(w WorkloadUsage) *WorkloadUsage {
 		w.CurrentAgreementId = agid
@@ -138,6 +183,21 @@ func UpdatePendingUpgrade(db AgbotDatabase, deviceid string, policyName string)
 	}
 }
 
+func UpdateHeldUpgrade(db AgbotDatabase, deviceid string, policyName string, upgrade *HeldUpgrade) (*WorkloadUsage, error) {
+	if wlUsage, err := db.SingleWorkloadUsageUpdate(deviceid, policyName, func(w WorkloadUsage) *WorkloadUsage {
+		// Keep the time when the upgrade was first held if there was already a held upgrade.
+		if upgrade != nil && w.HeldUpgrade != nil && w.HeldUpgrade.HeldTime != 0 {
+			upgrade.HeldTime = w.HeldUpgrade.HeldTime
+		}
+		w.HeldUpgrade = upgrade
+		return &w
+	}); err != nil {
+		return nil, err
+	} else {
+		return wlUsage, nil
+	}
+}
+
 func UpdateWUAgreementId(db AgbotDatabase, deviceid string, policyName string, agid string) (*WorkloadUsage, error) {
 	if wlUsage, err := db.SingleWorkloadUsageUpdate(deviceid, policyName, func(w WorkloadUsage) *WorkloadUsage {
 		w.CurrentAgreementId = agid
@@ -204,6 +264,7 @@ func ValidateWUStateTransition(mod *WorkloadUsage, update *WorkloadUsage) {
 		mod.Policy = update.Policy
 	}
 	mod.VerifiedDurationS = update.VerifiedDurationS
+	mod.HeldUpgrade = update.HeldUpgrade // set when an upgrade is held and cleared when it is released
 }
 
 // Filters
@@ -219,4 +280,12 @@ func PWUFilter(policyName string) WUFilter {
 	return func(a WorkloadUsage) bool { return a.PolicyName == policyName }
 }
 
+func HeldUpgradeWUFilter() WUFilter {
+	return func(a WorkloadUsage) bool { return a.HeldUpgrade != nil }
+}
+
+func RolloutWUFilter() WUFilter {
+	return func(a WorkloadUsage) bool { return a.HeldUpgrade != nil && a.HeldUpgrade.Rollout }
+}
+
 type WUFilter func(WorkloadUsage) bool
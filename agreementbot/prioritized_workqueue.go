@@ -25,6 +25,7 @@ type PrioritizedWorkQueue struct {
 	bufferSize uint64 // The (rough) maximum queue depth that should not be exceeded without blocking. This is immutable once constructed.
 
 	queueHistory *PrioritizedWorkQueueHistory // Stats records from the recent past.
+	totals       PrioritizedWorkQueueStats    // Stats since the queue was created, protected by the buffer lock.
 }
 
 func NewPrioritizedWorkQueue(bufferSize uint64, statInterval int, maxRecords int) *PrioritizedWorkQueue {
@@ -121,12 +122,14 @@ func (n *PrioritizedWorkQueue) GetHighPriorityBufferHead() *AgreementWork {
 func (n *PrioritizedWorkQueue) RemoveHighPriorityBufferHead() {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
+	n.totals.consumedHighBuffered()
 	n.workQueueBufferHigh = n.workQueueBufferHigh[1:]
 }
 
 func (n *PrioritizedWorkQueue) AddToHighPriorityBuffer(w *AgreementWork) {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
+	n.totals.consumedInboundHigh()
 	n.workQueueBufferHigh = append(n.workQueueBufferHigh, w)
 }
 
@@ -145,15 +148,24 @@ func (n *PrioritizedWorkQueue) GetLowPriorityBufferHead() *AgreementWork {
 func (n *PrioritizedWorkQueue) RemoveLowPriorityBufferHead() {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
+	n.totals.consumedLowBuffered()
 	n.workQueueBufferLow = n.workQueueBufferLow[1:]
 }
 
 func (n *PrioritizedWorkQueue) AddToLowPriorityBuffer(w *AgreementWork) {
 	n.bufferLock.Lock()
 	defer n.bufferLock.Unlock()
+	n.totals.consumedInboundLow()
 	n.workQueueBufferLow = append(n.workQueueBufferLow, w)
 }
 
+// Returns the number of requests that have moved through each part of the queue since it was created.
+func (n *PrioritizedWorkQueue) Totals() PrioritizedWorkQueueStats {
+	n.bufferLock.Lock()
+	defer n.bufferLock.Unlock()
+	return n.totals
+}
+
 const HIGH_PRIORITY = "high"
 const LOW_PRIORITY = "low"
 const BOTH_PRIORITY = "both"
@@ -0,0 +1,376 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/persistence"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+	"sync"
+)
+
+// The Rollout manager's job is to keep track of staged rollouts of workload upgrades. When the highest priority
+// workload of a policy that has a rollout strategy changes, the devices running the previous workload are not
+// upgraded all at once. Instead, the upgrade of each device is held in its workload usage record and the device is
+// added to the rollout of the policy. The agbot's governance releases the held upgrades in batches, as directed by
+// the rollout manager.
+//
+// Each rollout is saved in the agbot database whenever it changes, so that the progress of the batches, and whether
+// the rollout was paused, halted or aborted, survives an agbot restart.
+//
+// The rollouts are kept in the database partition of the agbot, so each agbot runs its own rollout of a policy for the
+// devices it has agreements with. The batch size and the failure threshold of the strategy apply to each agbot, not to
+// all the devices that use the policy.
+
+const ROLLOUT_STATE_RUNNING = "running"
+const ROLLOUT_STATE_PAUSED = "paused"
+const ROLLOUT_STATE_HALTED = "halted"
+const ROLLOUT_STATE_ABORTED = "aborted"
+const ROLLOUT_STATE_COMPLETED = "completed"
+
+type Rollout struct {
+	PolicyOrg    string               `json:"policy_org"`     // The org of the policy being rolled out
+	PolicyName   string               `json:"policy_name"`    // The name of the policy being rolled out
+	Version      string               `json:"version"`        // The workload version that the devices are being upgraded to
+	Strategy     policy.RolloutPolicy `json:"strategy"`       // The rollout strategy from the policy
+	State        string               `json:"state"`          // The state of the rollout, running, paused, halted, aborted or completed
+	StartTime    uint64               `json:"start_time"`     // The time when the rollout started
+	BatchNumber  int                  `json:"batch_number"`   // The number of batches released so far
+	BatchEndTime uint64               `json:"batch_end_time"` // The time when the latest batch finished, either upgraded or failed
+	Pending      []string             `json:"pending"`        // The devices waiting to be upgraded
+	Upgrading    map[string]uint64    `json:"upgrading"`      // The devices being upgraded, and the time when each was released for upgrade
+	Upgraded     []string             `json:"upgraded"`       // The devices that made an agreement after they were released
+	Failed       []string             `json:"failed"`         // The devices that did not make an agreement after they were released
+}
+
+func (r Rollout) String() string {
+	return fmt.Sprintf("PolicyOrg: %v, "+
+		"PolicyName: %v, "+
+		"Version: %v, "+
+		"Strategy: %v, "+
+		"State: %v, "+
+		"StartTime: %v, "+
+		"BatchNumber: %v, "+
+		"BatchEndTime: %v, "+
+		"Pending: %v, "+
+		"Upgrading: %v, "+
+		"Upgraded: %v, "+
+		"Failed: %v",
+		r.PolicyOrg, r.PolicyName, r.Version, r.Strategy, r.State, r.StartTime, r.BatchNumber, r.BatchEndTime,
+		r.Pending, r.Upgrading, r.Upgraded, r.Failed)
+}
+
+func NewRollout(org string, policyName string, version string, strategy policy.RolloutPolicy, now uint64) *Rollout {
+	return &Rollout{
+		PolicyOrg:  org,
+		PolicyName: policyName,
+		Version:    version,
+		Strategy:   strategy,
+		State:      ROLLOUT_STATE_RUNNING,
+		StartTime:  now,
+		Pending:    []string{},
+		Upgrading:  make(map[string]uint64),
+		Upgraded:   []string{},
+		Failed:     []string{},
+	}
+}
+
+// The total number of devices in the rollout.
+func (r *Rollout) Total() int {
+	return len(r.Pending) + len(r.Upgrading) + len(r.Upgraded) + len(r.Failed)
+}
+
+// Returns true when the rollout will not release any more devices without user intervention.
+func (r *Rollout) IsFinished() bool {
+	return r.State == ROLLOUT_STATE_ABORTED || r.State == ROLLOUT_STATE_COMPLETED
+}
+
+func (r *Rollout) deepCopy() Rollout {
+	c := *r
+	c.Pending = append([]string{}, r.Pending...)
+	c.Upgraded = append([]string{}, r.Upgraded...)
+	c.Failed = append([]string{}, r.Failed...)
+	c.Upgrading = make(map[string]uint64, len(r.Upgrading))
+	for id, t := range r.Upgrading {
+		c.Upgrading[id] = t
+	}
+	return c
+}
+
+func (r *Rollout) hasDevice(deviceId string) bool {
+	if _, ok := r.Upgrading[deviceId]; ok {
+		return true
+	}
+	for _, list := range [][]string{r.Pending, r.Upgraded, r.Failed} {
+		for _, id := range list {
+			if id == deviceId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type RolloutManager struct {
+	rolloutsLock sync.Mutex
+	rollouts     map[string]*Rollout // The rollouts, keyed by policy name
+	db           persistence.AgbotDatabase
+}
+
+func (rm *RolloutManager) String() string {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+	return fmt.Sprintf("Rollouts: %v", rm.rollouts)
+}
+
+// Create the rollout manager with the rollouts saved in the database.
+func NewRolloutManager(db persistence.AgbotDatabase) *RolloutManager {
+	rm := &RolloutManager{
+		rollouts: make(map[string]*Rollout),
+		db:       db,
+	}
+
+	if saved, err := db.FindRollouts(); err != nil {
+		glog.Errorf(rmLogString(fmt.Sprintf("unable to read rollouts from the database, error: %v", err)))
+	} else {
+		for policyName, serial := range saved {
+			r := new(Rollout)
+			if err := json.Unmarshal([]byte(serial), r); err != nil {
+				glog.Errorf(rmLogString(fmt.Sprintf("unable to demarshal rollout for policy %v, error: %v", policyName, err)))
+				continue
+			}
+			if r.Upgrading == nil {
+				r.Upgrading = make(map[string]uint64)
+			}
+			rm.rollouts[policyName] = r
+		}
+		glog.V(3).Infof(rmLogString(fmt.Sprintf("loaded %v rollouts from the database", len(rm.rollouts))))
+	}
+	return rm
+}
+
+// Save a rollout in the database. The caller must hold the rollouts lock. An error is only logged, the rollout is
+// saved again the next time it changes.
+func (rm *RolloutManager) save(r *Rollout) {
+	if serial, err := json.Marshal(r); err != nil {
+		glog.Errorf(rmLogString(fmt.Sprintf("unable to marshal rollout for policy %v, error: %v", r.PolicyName, err)))
+	} else if err := rm.db.SaveRollout(r.PolicyName, string(serial)); err != nil {
+		glog.Errorf(rmLogString(fmt.Sprintf("unable to save rollout for policy %v, error: %v", r.PolicyName, err)))
+	}
+}
+
+// Add a device that is waiting to be upgraded to the rollout of a policy. A new rollout is started when there is no
+// unfinished rollout of the same workload version for the policy.
+func (rm *RolloutManager) AddDevice(org string, policyName string, version string, strategy policy.RolloutPolicy, deviceId string, now uint64) {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	r, ok := rm.rollouts[policyName]
+	if !ok || r.Version != version || r.IsFinished() {
+		r = NewRollout(org, policyName, version, strategy, now)
+		rm.rollouts[policyName] = r
+		glog.V(3).Infof(rmLogString(fmt.Sprintf("starting rollout of version %v for policy %v with %v", version, policyName, strategy)))
+	} else {
+		// Use the latest strategy in case the policy was changed.
+		r.Strategy = strategy
+	}
+
+	if !r.hasDevice(deviceId) {
+		r.Pending = append(r.Pending, deviceId)
+		sort.Strings(r.Pending)
+	}
+	rm.save(r)
+}
+
+// Returns true if there is a rollout for the policy.
+func (rm *RolloutManager) HasRollout(policyName string) bool {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+	_, ok := rm.rollouts[policyName]
+	return ok
+}
+
+// Returns a copy of the rollout of a policy, or nil if there is none.
+func (rm *RolloutManager) GetRollout(policyName string) *Rollout {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+	if r, ok := rm.rollouts[policyName]; ok {
+		c := r.deepCopy()
+		return &c
+	}
+	return nil
+}
+
+// Returns a copy of all the rollouts, keyed by policy name.
+func (rm *RolloutManager) GetRollouts() map[string]Rollout {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+	res := make(map[string]Rollout, len(rm.rollouts))
+	for name, r := range rm.rollouts {
+		res[name] = r.deepCopy()
+	}
+	return res
+}
+
+// Stop releasing batches of devices. The devices already released continue to upgrade.
+func (rm *RolloutManager) Pause(policyName string) error {
+	return rm.setState(policyName, ROLLOUT_STATE_PAUSED, []string{ROLLOUT_STATE_RUNNING})
+}
+
+// Continue a paused or halted rollout. The failures of a halted rollout are forgotten so that the rollout
+// can continue after the problem is fixed.
+func (rm *RolloutManager) Resume(policyName string) error {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	if r, ok := rm.rollouts[policyName]; !ok {
+		return errors.New(fmt.Sprintf("there is no rollout for policy %v", policyName))
+	} else if r.State != ROLLOUT_STATE_PAUSED && r.State != ROLLOUT_STATE_HALTED {
+		return errors.New(fmt.Sprintf("the rollout for policy %v cannot be resumed, it is %v", policyName, r.State))
+	} else {
+		if r.State == ROLLOUT_STATE_HALTED {
+			r.Failed = []string{}
+		}
+		r.State = ROLLOUT_STATE_RUNNING
+		rm.save(r)
+		glog.V(3).Infof(rmLogString(fmt.Sprintf("resumed rollout for policy %v", policyName)))
+	}
+	return nil
+}
+
+// Stop the rollout. The devices that have not been released stay on the workload they are running until
+// their agreement ends.
+func (rm *RolloutManager) Abort(policyName string) error {
+	return rm.setState(policyName, ROLLOUT_STATE_ABORTED, []string{ROLLOUT_STATE_RUNNING, ROLLOUT_STATE_PAUSED, ROLLOUT_STATE_HALTED})
+}
+
+func (rm *RolloutManager) setState(policyName string, state string, fromStates []string) error {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	r, ok := rm.rollouts[policyName]
+	if !ok {
+		return errors.New(fmt.Sprintf("there is no rollout for policy %v", policyName))
+	}
+	for _, s := range fromStates {
+		if r.State == s {
+			r.State = state
+			rm.save(r)
+			glog.V(3).Infof(rmLogString(fmt.Sprintf("rollout for policy %v is %v", policyName, state)))
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("the rollout for policy %v cannot be %v, it is %v", policyName, state, r.State))
+}
+
+// Record the outcome of the upgrade of a released device.
+func (rm *RolloutManager) SetResult(policyName string, deviceId string, upgraded bool, now uint64) {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	r, ok := rm.rollouts[policyName]
+	if !ok {
+		return
+	} else if _, ok := r.Upgrading[deviceId]; !ok {
+		return
+	}
+
+	delete(r.Upgrading, deviceId)
+	if upgraded {
+		r.Upgraded = append(r.Upgraded, deviceId)
+	} else {
+		r.Failed = append(r.Failed, deviceId)
+		glog.Warningf(rmLogString(fmt.Sprintf("device %v failed to upgrade to version %v for policy %v", deviceId, r.Version, policyName)))
+	}
+
+	if len(r.Upgrading) == 0 {
+		r.BatchEndTime = now
+	}
+
+	if r.State == ROLLOUT_STATE_RUNNING && r.Strategy.MaxFailures != 0 && len(r.Failed) >= r.Strategy.MaxFailures {
+		r.State = ROLLOUT_STATE_HALTED
+		glog.Warningf(rmLogString(fmt.Sprintf("halted rollout for policy %v, %v devices failed to upgrade", policyName, len(r.Failed))))
+	}
+	rm.save(r)
+}
+
+// Make the pending devices of a rollout match the devices that are still held for it. Devices leave the rollout
+// when their agreement ends, because their upgrade is no longer held.
+func (rm *RolloutManager) SetPending(policyName string, heldDevices []string) {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	r, ok := rm.rollouts[policyName]
+	if !ok {
+		return
+	}
+
+	held := make(map[string]bool, len(heldDevices))
+	for _, id := range heldDevices {
+		held[id] = true
+	}
+
+	pending := []string{}
+	for _, id := range r.Pending {
+		if held[id] {
+			pending = append(pending, id)
+		}
+	}
+	if len(pending) != len(r.Pending) {
+		r.Pending = pending
+		rm.save(r)
+	}
+}
+
+// Returns the devices that should be released for upgrade now. A new batch is released when the rollout is running,
+// the previous batch has finished and the pause between batches has passed. The rollout is completed when there
+// are no more devices to upgrade. The batch size is based on the number of devices matched by the policy, because the
+// devices are added to the rollout one at a time as their agreements notice the policy change, so the first batches
+// would be too small if they were based on the devices in the rollout.
+func (rm *RolloutManager) NextBatch(policyName string, matchedDevices int, now uint64) []string {
+	rm.rolloutsLock.Lock()
+	defer rm.rolloutsLock.Unlock()
+
+	r, ok := rm.rollouts[policyName]
+	if !ok || r.State != ROLLOUT_STATE_RUNNING || len(r.Upgrading) != 0 {
+		return []string{}
+	}
+
+	if len(r.Pending) == 0 {
+		r.State = ROLLOUT_STATE_COMPLETED
+		rm.save(r)
+		glog.V(3).Infof(rmLogString(fmt.Sprintf("completed rollout of version %v for policy %v, %v devices upgraded, %v failed", r.Version, policyName, len(r.Upgraded), len(r.Failed))))
+		return []string{}
+	}
+
+	if r.BatchNumber != 0 && r.BatchEndTime+uint64(r.Strategy.PauseS) > now {
+		return []string{}
+	}
+
+	total := r.Total()
+	if matchedDevices > total {
+		total = matchedDevices
+	}
+	size := r.Strategy.GetBatchSize(total)
+	if size > len(r.Pending) {
+		size = len(r.Pending)
+	}
+
+	batch := append([]string{}, r.Pending[:size]...)
+	r.Pending = r.Pending[size:]
+	for _, id := range batch {
+		r.Upgrading[id] = now
+	}
+	r.BatchNumber += 1
+	rm.save(r)
+
+	glog.V(3).Infof(rmLogString(fmt.Sprintf("releasing batch %v of rollout for policy %v: %v", r.BatchNumber, policyName, batch)))
+	return batch
+}
+
+// Logging function
+var rmLogString = func(v interface{}) string {
+	return fmt.Sprintf("Rollout Manager: %v", v)
+}
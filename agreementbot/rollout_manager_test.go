@@ -0,0 +1,229 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/agreementbot/persistence/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_RolloutManager_Batches(t *testing.T) {
+
+	db, dir := newRolloutTestDB(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	rm := NewRolloutManager(db)
+	strategy := *policy.RolloutPolicy_Factory(2, 0, 100, 2, 0)
+	pName := "myorg/mypolicy"
+
+	for _, id := range []string{"myorg/d3", "myorg/d1", "myorg/d2", "myorg/d4", "myorg/d1"} {
+		rm.AddDevice("myorg", pName, "1.0.1", strategy, id, 1000)
+	}
+
+	if r := rm.GetRollout(pName); r == nil {
+		t.Errorf("rollout should exist")
+	} else if r.Total() != 4 || r.State != ROLLOUT_STATE_RUNNING {
+		t.Errorf("rollout should be running with 4 devices: %v", r)
+	}
+
+	// The first batch is released right away.
+	if batch := rm.NextBatch(pName, 0, 1000); len(batch) != 2 || batch[0] != "myorg/d1" || batch[1] != "myorg/d2" {
+		t.Errorf("wrong first batch: %v", batch)
+	} else if batch := rm.NextBatch(pName, 0, 1001); len(batch) != 0 {
+		t.Errorf("no batch should be released while devices are upgrading: %v", batch)
+	}
+
+	rm.SetResult(pName, "myorg/d1", true, 1010)
+	rm.SetResult(pName, "myorg/d2", true, 1020)
+
+	// The next batch waits for the pause between batches.
+	if batch := rm.NextBatch(pName, 0, 1050); len(batch) != 0 {
+		t.Errorf("no batch should be released during the pause: %v", batch)
+	} else if batch := rm.NextBatch(pName, 0, 1120); len(batch) != 2 {
+		t.Errorf("wrong second batch: %v", batch)
+	}
+
+	rm.SetResult(pName, "myorg/d3", true, 1130)
+	rm.SetResult(pName, "myorg/d4", true, 1130)
+
+	if batch := rm.NextBatch(pName, 0, 1300); len(batch) != 0 {
+		t.Errorf("there should be no more batches: %v", batch)
+	} else if r := rm.GetRollout(pName); r.State != ROLLOUT_STATE_COMPLETED || len(r.Upgraded) != 4 || r.BatchNumber != 2 {
+		t.Errorf("rollout should be completed: %v", r)
+	}
+
+	// A device added after the rollout completed starts a new rollout.
+	rm.AddDevice("myorg", pName, "1.0.1", strategy, "myorg/d5", 2000)
+	if r := rm.GetRollout(pName); r.State != ROLLOUT_STATE_RUNNING || r.Total() != 1 {
+		t.Errorf("a new rollout should have started: %v", r)
+	}
+}
+
+func Test_RolloutManager_Halt(t *testing.T) {
+
+	db, dir := newRolloutTestDB(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	rm := NewRolloutManager(db)
+	strategy := *policy.RolloutPolicy_Factory(0, 50, 0, 1, 0)
+	pName := "myorg/mypolicy"
+
+	for _, id := range []string{"myorg/d1", "myorg/d2", "myorg/d3", "myorg/d4"} {
+		rm.AddDevice("myorg", pName, "1.0.1", strategy, id, 1000)
+	}
+
+	if batch := rm.NextBatch(pName, 0, 1000); len(batch) != 2 {
+		t.Errorf("wrong first batch: %v", batch)
+	}
+
+	rm.SetResult(pName, "myorg/d1", false, 1100)
+	rm.SetResult(pName, "myorg/d2", true, 1100)
+
+	if r := rm.GetRollout(pName); r.State != ROLLOUT_STATE_HALTED {
+		t.Errorf("rollout should be halted: %v", r)
+	} else if batch := rm.NextBatch(pName, 0, 1200); len(batch) != 0 {
+		t.Errorf("a halted rollout should not release a batch: %v", batch)
+	} else if err := rm.Pause(pName); err == nil {
+		t.Errorf("a halted rollout should not be paused")
+	} else if err := rm.Resume(pName); err != nil {
+		t.Errorf("a halted rollout should be resumed, error: %v", err)
+	} else if r := rm.GetRollout(pName); r.State != ROLLOUT_STATE_RUNNING || len(r.Failed) != 0 {
+		t.Errorf("resumed rollout should be running without failures: %v", r)
+	} else if batch := rm.NextBatch(pName, 0, 1200); len(batch) != 2 {
+		t.Errorf("wrong second batch: %v", batch)
+	}
+}
+
+func Test_RolloutManager_PauseAbort(t *testing.T) {
+
+	db, dir := newRolloutTestDB(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	rm := NewRolloutManager(db)
+	strategy := *policy.RolloutPolicy_Factory(1, 0, 0, 0, 0)
+	pName := "myorg/mypolicy"
+
+	if err := rm.Pause(pName); err == nil {
+		t.Errorf("pause should fail when there is no rollout")
+	}
+
+	rm.AddDevice("myorg", pName, "1.0.1", strategy, "myorg/d1", 1000)
+	rm.AddDevice("myorg", pName, "1.0.1", strategy, "myorg/d2", 1000)
+
+	if err := rm.Pause(pName); err != nil {
+		t.Errorf("pause should not fail, error: %v", err)
+	} else if batch := rm.NextBatch(pName, 0, 1000); len(batch) != 0 {
+		t.Errorf("a paused rollout should not release a batch: %v", batch)
+	} else if err := rm.Resume(pName); err != nil {
+		t.Errorf("resume should not fail, error: %v", err)
+	} else if batch := rm.NextBatch(pName, 0, 1000); len(batch) != 1 {
+		t.Errorf("wrong first batch: %v", batch)
+	}
+
+	// Devices whose upgrade is no longer held leave the rollout.
+	rm.SetPending(pName, []string{})
+	if r := rm.GetRollout(pName); len(r.Pending) != 0 || len(r.Upgrading) != 1 {
+		t.Errorf("rollout should have no pending devices: %v", r)
+	}
+
+	if err := rm.Abort(pName); err != nil {
+		t.Errorf("abort should not fail, error: %v", err)
+	} else if err := rm.Resume(pName); err == nil {
+		t.Errorf("an aborted rollout should not be resumed")
+	} else if r := rm.GetRollout(pName); !r.IsFinished() {
+		t.Errorf("an aborted rollout should be finished: %v", r)
+	}
+
+	// A different version starts a new rollout.
+	rm.AddDevice("myorg", pName, "1.0.2", strategy, "myorg/d2", 2000)
+	if r := rm.GetRollout(pName); r.Version != "1.0.2" || r.State != ROLLOUT_STATE_RUNNING {
+		t.Errorf("a new rollout should have started: %v", r)
+	}
+}
+
+func Test_RolloutManager_Restart(t *testing.T) {
+
+	db, dir := newRolloutTestDB(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	rm := NewRolloutManager(db)
+	strategy := *policy.RolloutPolicy_Factory(1, 0, 100, 0, 0)
+	pName := "myorg/mypolicy"
+
+	for _, id := range []string{"myorg/d1", "myorg/d2", "myorg/d3"} {
+		rm.AddDevice("myorg", pName, "1.0.1", strategy, id, 1000)
+	}
+	if batch := rm.NextBatch(pName, 0, 1000); len(batch) != 1 {
+		t.Errorf("wrong first batch: %v", batch)
+	}
+	rm.SetResult(pName, "myorg/d1", true, 1010)
+	if err := rm.Pause(pName); err != nil {
+		t.Errorf("pause should not fail, error: %v", err)
+	}
+
+	// The batches and the pause are not forgotten when the agbot restarts.
+	rm = NewRolloutManager(db)
+	if r := rm.GetRollout(pName); r == nil {
+		t.Errorf("rollout should have been loaded")
+	} else if r.State != ROLLOUT_STATE_PAUSED || r.BatchNumber != 1 || r.BatchEndTime != 1010 || len(r.Upgraded) != 1 || len(r.Pending) != 2 {
+		t.Errorf("rollout should be paused after the first batch: %v", r)
+	} else if batch := rm.NextBatch(pName, 0, 2000); len(batch) != 0 {
+		t.Errorf("a paused rollout should not release a batch: %v", batch)
+	} else if err := rm.Resume(pName); err != nil {
+		t.Errorf("resume should not fail, error: %v", err)
+	} else if batch := rm.NextBatch(pName, 0, 2000); len(batch) != 1 || batch[0] != "myorg/d2" {
+		t.Errorf("wrong second batch: %v", batch)
+	}
+}
+
+func Test_RolloutManager_BatchPercentage(t *testing.T) {
+
+	db, dir := newRolloutTestDB(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	rm := NewRolloutManager(db)
+	strategy := *policy.RolloutPolicy_Factory(0, 20, 0, 0, 0)
+	pName := "myorg/mypolicy"
+
+	for _, id := range []string{"myorg/d1", "myorg/d2", "myorg/d3", "myorg/d4", "myorg/d5"} {
+		rm.AddDevice("myorg", pName, "1.0.1", strategy, id, 1000)
+	}
+
+	// Only some of the devices matched by the policy are in the rollout yet, the batch is 20% of all of them.
+	if batch := rm.NextBatch(pName, 20, 1000); len(batch) != 4 {
+		t.Errorf("batch should be 20%% of the matched devices: %v", batch)
+	}
+	for _, id := range []string{"myorg/d1", "myorg/d2", "myorg/d3", "myorg/d4"} {
+		rm.SetResult(pName, id, true, 1010)
+	}
+
+	// Without a count of the matched devices, the batch is 20% of the devices in the rollout.
+	for _, id := range []string{"myorg/d6", "myorg/d7", "myorg/d8", "myorg/d9", "myorg/d10"} {
+		rm.AddDevice("myorg", pName, "1.0.1", strategy, id, 1020)
+	}
+	if batch := rm.NextBatch(pName, 0, 1020); len(batch) != 2 {
+		t.Errorf("batch should be 20%% of the devices in the rollout: %v", batch)
+	}
+}
+
+func newRolloutTestDB(t *testing.T) (*bolt.AgbotBoltDB, string) {
+	dir, err := ioutil.TempDir("", "rollout-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	db := new(bolt.AgbotBoltDB)
+	if err := db.Initialize(&config.HorizonConfig{AgreementBot: config.AGConfig{DBPath: dir}}); err != nil {
+		t.Fatalf("unexpected error initializing: %v", err)
+	}
+	return db, dir
+}
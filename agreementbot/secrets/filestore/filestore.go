@@ -0,0 +1,495 @@
+package filestore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/agreementbot/secrets"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// This function registers an uninitialized agbot secrets implementation with the secrets plugin registry. The plugin's Initialize
+// method is used to configure the object.
+func init() {
+	secrets.Register("file", new(AgbotFileSecrets))
+}
+
+// The bucket holding all the secrets. The key of each secret is "<org>/<path>", where the path of a user
+// secret is "user/<user>/<name>".
+const SECRETS_BUCKET = "secrets"
+
+// The length of the AES-256 key used to encrypt secrets.
+const SECRET_KEY_LENGTH = 32
+
+// A secret as it is stored in the database. The secret details are encrypted, the metadata is not so that
+// secrets can be listed and checked for updates without decrypting them.
+type StoredSecret struct {
+	Data         []byte `json:"data"`
+	CreationTime int64  `json:"created_time"`
+	UpdateTime   int64  `json:"updated_time"`
+}
+
+// The fields in this object are initialized in the Initialize and Login methods in this package.
+type AgbotFileSecrets struct {
+	lock            sync.RWMutex
+	db              *bolt.DB
+	key             []byte
+	httpClient      *http.Client // A cached http client to use for looking up users in the exchange
+	cfg             *config.HorizonConfig
+	lastInteraction uint64
+	getExchangeUser func(user, token string) (*exchange.UserDefinition, error)
+}
+
+func (fs *AgbotFileSecrets) String() string {
+	return fmt.Sprintf("DBPath: %v", fs.cfg.GetSecretStoreDBPath())
+}
+
+// Available to all users within the org
+func (fs *AgbotFileSecrets) ListOrgUserSecret(user, token, org, path string) error {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("list secret %v in org %v as user %v", path, org, user)))
+	return fs.listSecret(user, token, org, path)
+}
+
+// Available to only org admin users
+func (fs *AgbotFileSecrets) ListOrgSecret(user, token, org, path string) error {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("list secret %v in org %v", path, org)))
+	return fs.listSecret(user, token, org, path)
+}
+
+// Check that the secret at the specified path exists.
+func (fs *AgbotFileSecrets) listSecret(user, token, org, path string) error {
+	if err := fs.authorize(user, token, org, path, http.MethodGet, true); err != nil {
+		return err
+	}
+
+	secret, err := fs.getSecret(org, path)
+	if err != nil {
+		return err
+	} else if secret == nil {
+		return &secrets.NoSecretFound{SecretPath: secretKey(org, path)}
+	}
+	return nil
+}
+
+// List all org-level secrets at a specified path. The user secrets are not included.
+func (fs *AgbotFileSecrets) ListOrgSecrets(user, token, org, path string) ([]string, error) {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("list secrets in %v", org)))
+	secretList, err := fs.listSecrets(user, token, org, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// filter out the user secrets if listing the top level of the org
+	if path == "" {
+		orgSecrets := make([]string, 0)
+		for _, secret := range secretList {
+			if !strings.HasPrefix(secret, "user/") {
+				orgSecrets = append(orgSecrets, secret)
+			}
+		}
+		secretList = orgSecrets
+	}
+
+	if len(secretList) == 0 {
+		return nil, &secrets.NoSecretFound{SecretPath: secretKey(org, path)}
+	}
+	return secretList, nil
+}
+
+// List all user-level secrets at a specified path.
+func (fs *AgbotFileSecrets) ListOrgUserSecrets(user, token, org, path string) ([]string, error) {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("listing secrets for user %v in %v", user, org)))
+	secretList, err := fs.listSecrets(user, token, org, path)
+	if err != nil {
+		return nil, err
+	} else if len(secretList) == 0 {
+		return nil, &secrets.NoSecretFound{SecretPath: secretKey(org, path)}
+	}
+
+	// trim the user/<user> prefix from the names
+	for ix, secret := range secretList {
+		secretList[ix] = strings.TrimPrefix(secret, path+"/")
+	}
+	return secretList, nil
+}
+
+// Return the names of all the secrets in the directory named by path, including the secrets in sub directories.
+// The names are relative to the org.
+func (fs *AgbotFileSecrets) listSecrets(user, token, org, path string) ([]string, error) {
+	if err := fs.authorize(user, token, org, path, "LIST", true); err != nil {
+		return nil, err
+	}
+
+	// The prefix always ends with a / so that the secrets of an org whose name starts with this org's name are not listed.
+	prefix := org + "/"
+	if path != "" {
+		prefix += path + "/"
+	}
+
+	secretList := make([]string, 0)
+	err := fs.view(func(b *bolt.Bucket) error {
+		c := b.Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, _ = c.Next() {
+			secretList = append(secretList, strings.TrimPrefix(string(k), org+"/"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(secretList)
+	return secretList, nil
+}
+
+// Available to all users within the org
+func (fs *AgbotFileSecrets) CreateOrgUserSecret(user, token, org, path string, data secrets.SecretDetails) error {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("creating secret %s in org %s", path, org)))
+	return fs.createSecret(user, token, org, path, data)
+}
+
+// Available to only org admin users
+func (fs *AgbotFileSecrets) CreateOrgSecret(user, token, org, path string, data secrets.SecretDetails) error {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("creating secret %s in org %s", path, org)))
+	return fs.createSecret(user, token, org, path, data)
+}
+
+// Create or update a secret. The creation time of an existing secret is preserved.
+func (fs *AgbotFileSecrets) createSecret(user, token, org, path string, data secrets.SecretDetails) error {
+	if err := fs.authorize(user, token, org, path, http.MethodPost, false); err != nil {
+		return err
+	} else if path == "" || strings.HasSuffix(path, "/") {
+		return &secrets.BadRequest{ResponseCode: http.StatusBadRequest, HttpMethod: http.MethodPost, SecretPath: secretKey(org, path), RequestBody: &data}
+	}
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return &secrets.BadRequest{ResponseCode: http.StatusBadRequest, HttpMethod: http.MethodPost, SecretPath: secretKey(org, path), RequestBody: &data}
+	}
+	ciphertext, err := fs.encrypt(plaintext)
+	if err != nil {
+		return &secrets.SecretsProviderUnavailable{ProviderError: err}
+	}
+
+	now := time.Now().Unix()
+	key := []byte(secretKey(org, path))
+	err = fs.update(func(b *bolt.Bucket) error {
+		secret := StoredSecret{Data: ciphertext, CreationTime: now, UpdateTime: now}
+		if existing := b.Get(key); existing != nil {
+			var old StoredSecret
+			if err := json.Unmarshal(existing, &old); err == nil {
+				secret.CreationTime = old.CreationTime
+			}
+		}
+		if serial, err := json.Marshal(secret); err != nil {
+			return err
+		} else {
+			return b.Put(key, serial)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("done creating %s in the secret store.", path)))
+	return nil
+}
+
+// Available to all users within the org
+func (fs *AgbotFileSecrets) DeleteOrgUserSecret(user, token, org, path string) error {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("delete secret %s in org %s", path, org)))
+	return fs.deleteSecret(user, token, org, path)
+}
+
+// Available to only org admin users
+func (fs *AgbotFileSecrets) DeleteOrgSecret(user, token, org, path string) error {
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("delete secret %s in org %s", path, org)))
+	return fs.deleteSecret(user, token, org, path)
+}
+
+func (fs *AgbotFileSecrets) deleteSecret(user, token, org, path string) error {
+	if err := fs.authorize(user, token, org, path, http.MethodDelete, false); err != nil {
+		return err
+	}
+
+	key := []byte(secretKey(org, path))
+	err := fs.update(func(b *bolt.Bucket) error {
+		if b.Get(key) == nil {
+			return &secrets.NoSecretFound{SecretPath: string(key)}
+		}
+		return b.Delete(key)
+	})
+	if err != nil {
+		return err
+	}
+
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("done deleting %s in the secret store.", path)))
+	return nil
+}
+
+// Return the decrypted secret. When the user is the agbot itself, the secret can be read without checking the
+// user in the exchange.
+func (fs *AgbotFileSecrets) GetSecretDetails(user, token, org, secretUser, secretName string) (secrets.SecretDetails, error) {
+
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("extract secret details for %s in org %s as user %s", secretName, org, secretUser)))
+
+	res := secrets.SecretDetails{}
+	if org == "" {
+		return res, &secrets.BadRequest{Response: map[string][]string{"errors": {"Organization name must not be an empty string"}}}
+	} else if secretName == "" {
+		return res, &secrets.BadRequest{Response: map[string][]string{"errors": {"Secret name must not be an empty string"}}}
+	}
+
+	path := secretPath(secretUser, secretName)
+	if user != fs.cfg.AgreementBot.ExchangeId || token != fs.cfg.AgreementBot.ExchangeToken {
+		if err := fs.authorize(user, token, org, path, http.MethodGet, false); err != nil {
+			return res, err
+		}
+	}
+
+	secret, err := fs.getSecret(org, path)
+	if err != nil {
+		return res, err
+	} else if secret == nil {
+		return res, &secrets.NoSecretFound{SecretPath: secretKey(org, path)}
+	}
+
+	plaintext, err := fs.decrypt(secret.Data)
+	if err != nil {
+		return res, &secrets.InvalidResponse{ReadError: err, HttpMethod: http.MethodGet, SecretPath: secretKey(org, path)}
+	} else if err := json.Unmarshal(plaintext, &res); err != nil {
+		return res, &secrets.InvalidResponse{ParseError: err, HttpMethod: http.MethodGet, SecretPath: secretKey(org, path)}
+	}
+
+	glog.V(3).Infof(filePluginLogString("done extracting secret details"))
+	return res, nil
+}
+
+// Retrieve the metadata for a secret.
+func (fs *AgbotFileSecrets) GetSecretMetadata(secretOrg, secretUser, secretName string) (secrets.SecretMetadata, error) {
+
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("extract secret metadata for %s in org %s as user %s", secretName, secretOrg, secretUser)))
+
+	res := secrets.SecretMetadata{}
+	if secretOrg == "" {
+		return res, &secrets.BadRequest{Response: map[string][]string{"errors": {"Organization name must not be an empty string"}}, HttpMethod: http.MethodGet}
+	} else if secretName == "" {
+		return res, &secrets.BadRequest{Response: map[string][]string{"errors": {"Secret name must not be an empty string"}}, HttpMethod: http.MethodGet}
+	}
+
+	path := secretPath(secretUser, secretName)
+	secret, err := fs.getSecret(secretOrg, path)
+	if err != nil {
+		return res, err
+	} else if secret == nil {
+		return res, &secrets.NoSecretFound{SecretPath: secretKey(secretOrg, path)}
+	}
+
+	res.CreationTime = secret.CreationTime
+	res.UpdateTime = secret.UpdateTime
+
+	glog.V(5).Infof(filePluginLogString(fmt.Sprintf("Metadata: %v", res)))
+	return res, nil
+}
+
+// Check that the user is allowed to access the secret at the path. This applies the same rules as the vault:
+// users can only access secrets in their own org, org admins can access all the secrets in the org, and other
+// users can list the org secrets but can only read and change their own user secrets. listOnly is true when the
+// request only checks for the existence of secrets.
+func (fs *AgbotFileSecrets) authorize(user, token, org, secretPath, method string, listOnly bool) error {
+
+	path := secretKey(org, secretPath)
+	if exchange.GetOrg(user) != org {
+		return &secrets.PermissionDenied{HttpMethod: method, SecretPath: path, ExchangeUser: user}
+	}
+
+	exUser, err := fs.getExchangeUser(user, token)
+	if err != nil {
+		return &secrets.Unauthenticated{LoginError: err, ExchangeUser: user}
+	} else if exUser.Admin {
+		return nil
+	}
+
+	userPrefix := "user/" + exchange.GetId(user)
+	if secretPath == userPrefix || strings.HasPrefix(secretPath, userPrefix+"/") {
+		return nil
+	} else if listOnly && !strings.HasPrefix(secretPath, "user/") && secretPath != "user" {
+		return nil
+	}
+	return &secrets.PermissionDenied{HttpMethod: method, SecretPath: path, ExchangeUser: user}
+}
+
+// Look up the user in the exchange using the user's own credentials, which also authenticates the user.
+func (fs *AgbotFileSecrets) getExchangeUserFromExchange(user, token string) (*exchange.UserDefinition, error) {
+
+	if fs.httpClient == nil {
+		return nil, errors.New(fmt.Sprintf("no http client is available to look up user %v", user))
+	}
+
+	var resp interface{}
+	resp = new(exchange.GetUsersResponse)
+	targetURL := fmt.Sprintf("%vorgs/%v/users/%v", fs.cfg.AgreementBot.ExchangeURL, exchange.GetOrg(user), exchange.GetId(user))
+	if err, tpErr := exchange.InvokeExchange(fs.httpClient, "GET", targetURL, user, token, nil, &resp); err != nil {
+		return nil, err
+	} else if tpErr != nil {
+		return nil, tpErr
+	}
+
+	for key, u := range resp.(*exchange.GetUsersResponse).Users {
+		if key == user {
+			return &u, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("user %v not found in the exchange", user))
+}
+
+// Return the stored secret at the path, or nil if there is no secret there.
+func (fs *AgbotFileSecrets) getSecret(org, path string) (*StoredSecret, error) {
+	var secret *StoredSecret
+	err := fs.view(func(b *bolt.Bucket) error {
+		if v := b.Get([]byte(secretKey(org, path))); v != nil {
+			secret = new(StoredSecret)
+			if err := json.Unmarshal(v, secret); err != nil {
+				return &secrets.InvalidResponse{ParseError: err, Response: v, HttpMethod: http.MethodGet, SecretPath: secretKey(org, path)}
+			}
+		}
+		return nil
+	})
+	return secret, err
+}
+
+// Run a read only function against the secrets bucket.
+func (fs *AgbotFileSecrets) view(fn func(b *bolt.Bucket) error) error {
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+	if fs.db == nil {
+		return &secrets.SecretsProviderUnavailable{ProviderError: errors.New("the secret store is not open")}
+	}
+	atomic.StoreUint64(&fs.lastInteraction, uint64(time.Now().Unix()))
+	return wrapDBError(fs.db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket([]byte(SECRETS_BUCKET)))
+	}))
+}
+
+// Run an updating function against the secrets bucket.
+func (fs *AgbotFileSecrets) update(fn func(b *bolt.Bucket) error) error {
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+	if fs.db == nil {
+		return &secrets.SecretsProviderUnavailable{ProviderError: errors.New("the secret store is not open")}
+	}
+	atomic.StoreUint64(&fs.lastInteraction, uint64(time.Now().Unix()))
+	return wrapDBError(fs.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket([]byte(SECRETS_BUCKET)))
+	}))
+}
+
+// Errors from the database are reported as the secret store being unavailable, errors already reported by the
+// secrets plugin are returned as is.
+func wrapDBError(err error) error {
+	if err != nil && secrets.WrapSecretsError(err) == nil {
+		return &secrets.SecretsProviderUnavailable{ProviderError: err}
+	}
+	return err
+}
+
+// Encrypt with AES-GCM, the random nonce is prepended to the ciphertext.
+func (fs *AgbotFileSecrets) encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(fs.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to generate nonce, error: %v", err))
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (fs *AgbotFileSecrets) decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(fs.key)
+	if err != nil {
+		return nil, err
+	} else if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New(fmt.Sprintf("encrypted secret is too short"))
+	}
+	nonce, data := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to decrypt secret, error: %v", err))
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create cipher, error: %v", err))
+	}
+	return cipher.NewGCM(block)
+}
+
+// Read the base64 encoded encryption key from the key file. If the file does not exist, a new key is generated
+// and written to the file.
+func loadOrCreateKey(keyFile string) ([]byte, error) {
+	if encoded, err := ioutil.ReadFile(keyFile); err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to decode secret store key in %v, error: %v", keyFile, err))
+		} else if len(key) != SECRET_KEY_LENGTH {
+			return nil, errors.New(fmt.Sprintf("secret store key in %v must be %v bytes, is %v bytes", keyFile, SECRET_KEY_LENGTH, len(key)))
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, errors.New(fmt.Sprintf("unable to read secret store key %v, error: %v", keyFile, err))
+	}
+
+	glog.V(1).Infof(filePluginLogString(fmt.Sprintf("generating secret store key in %v", keyFile)))
+	key := make([]byte, SECRET_KEY_LENGTH)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to generate secret store key, error: %v", err))
+	} else if err := os.MkdirAll(path.Dir(keyFile), 0700); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create directory for secret store key %v, error: %v", keyFile, err))
+	} else if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to write secret store key %v, error: %v", keyFile, err))
+	}
+	return key, nil
+}
+
+// The path of a secret within an org.
+func secretPath(secretUser, secretName string) string {
+	if secretUser != "" {
+		return fmt.Sprintf("user/%s/%s", secretUser, secretName)
+	}
+	return secretName
+}
+
+// The database key of a secret.
+func secretKey(org, path string) string {
+	if path == "" {
+		return org
+	}
+	return org + "/" + path
+}
+
+// Log string prefix api
+var filePluginLogString = func(v interface{}) string {
+	return fmt.Sprintf("File Secrets Plugin: %v", v)
+}
@@ -0,0 +1,215 @@
+// +build unit
+
+package filestore
+
+import (
+	"errors"
+	"github.com/open-horizon/anax/agreementbot/secrets"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const (
+	adminUser   = "myorg/admin"
+	regularUser = "myorg/bob"
+	otherUser   = "otherorg/alice"
+	agbotUser   = "myorg/agbot"
+)
+
+func newTestStore(t *testing.T) (*AgbotFileSecrets, string) {
+	dir, err := ioutil.TempDir("", "secretstore-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	cfg := &config.HorizonConfig{AgreementBot: config.AGConfig{ExchangeId: agbotUser, ExchangeToken: "agbottoken", SecretStore: config.SecretStoreConfig{DBPath: dir}}}
+	fs := new(AgbotFileSecrets)
+	if err := fs.Initialize(cfg); err != nil {
+		t.Fatalf("unexpected error initializing: %v", err)
+	}
+	fs.getExchangeUser = func(user, token string) (*exchange.UserDefinition, error) {
+		if token != "pw" {
+			return nil, errors.New("bad password")
+		}
+		return &exchange.UserDefinition{Admin: exchange.GetId(user) == "admin"}, nil
+	}
+	if err := fs.Login(); err != nil {
+		t.Fatalf("unexpected error logging in: %v", err)
+	} else if !fs.IsReady() {
+		t.Fatalf("secret store should be ready")
+	}
+	return fs, dir
+}
+
+func Test_FileSecrets_CRUD(t *testing.T) {
+	fs, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+	defer fs.Close()
+
+	details := secrets.SecretDetails{Key: "password", Value: "s3cret"}
+	if err := fs.CreateOrgSecret(adminUser, "pw", "myorg", "dir/secret1", details); err != nil {
+		t.Errorf("unexpected error creating secret: %v", err)
+	} else if err := fs.CreateOrgSecret(adminUser, "pw", "myorg", "secret2", details); err != nil {
+		t.Errorf("unexpected error creating secret: %v", err)
+	} else if err := fs.CreateOrgUserSecret(regularUser, "pw", "myorg", "user/bob/secret3", details); err != nil {
+		t.Errorf("unexpected error creating user secret: %v", err)
+	}
+
+	// The value is encrypted in the database.
+	if secret, err := fs.getSecret("myorg", "secret2"); err != nil || secret == nil {
+		t.Errorf("secret should be stored, error: %v", err)
+	} else if string(secret.Data) == "s3cret" || secret.UpdateTime == 0 {
+		t.Errorf("unexpected stored secret %v", secret)
+	}
+
+	if names, err := fs.ListOrgSecrets(regularUser, "pw", "myorg", ""); err != nil {
+		t.Errorf("unexpected error listing secrets: %v", err)
+	} else if len(names) != 2 || names[0] != "dir/secret1" || names[1] != "secret2" {
+		t.Errorf("unexpected org secrets %v", names)
+	}
+	if names, err := fs.ListOrgUserSecrets(regularUser, "pw", "myorg", "user/bob"); err != nil {
+		t.Errorf("unexpected error listing user secrets: %v", err)
+	} else if len(names) != 1 || names[0] != "secret3" {
+		t.Errorf("unexpected user secrets %v", names)
+	}
+	if _, err := fs.ListOrgUserSecrets(adminUser, "pw", "myorg", "user/admin"); err == nil {
+		t.Errorf("expected no secrets found")
+	} else if _, ok := err.(*secrets.NoSecretFound); !ok {
+		t.Errorf("expected NoSecretFound, got %T", err)
+	}
+
+	if err := fs.ListOrgSecret(regularUser, "pw", "myorg", "dir/secret1"); err != nil {
+		t.Errorf("secret should exist: %v", err)
+	} else if err := fs.ListOrgSecret(regularUser, "pw", "myorg", "dir"); err == nil {
+		t.Errorf("a directory is not a secret")
+	}
+
+	// Both the owner and the agbot can read the secret details.
+	if d, err := fs.GetSecretDetails(regularUser, "pw", "myorg", "bob", "secret3"); err != nil {
+		t.Errorf("unexpected error reading secret: %v", err)
+	} else if d != details {
+		t.Errorf("unexpected secret details %v", d)
+	}
+	if d, err := fs.GetSecretDetails(agbotUser, "agbottoken", "myorg", "", "secret2"); err != nil {
+		t.Errorf("unexpected error reading secret: %v", err)
+	} else if d != details {
+		t.Errorf("unexpected secret details %v", d)
+	}
+
+	// Updating a secret keeps the creation time and the new value.
+	md1, err := fs.GetSecretMetadata("myorg", "", "secret2")
+	if err != nil {
+		t.Errorf("unexpected error reading metadata: %v", err)
+	}
+	newDetails := secrets.SecretDetails{Key: "password", Value: "n3w"}
+	if err := fs.CreateOrgSecret(adminUser, "pw", "myorg", "secret2", newDetails); err != nil {
+		t.Errorf("unexpected error updating secret: %v", err)
+	} else if md2, err := fs.GetSecretMetadata("myorg", "", "secret2"); err != nil {
+		t.Errorf("unexpected error reading metadata: %v", err)
+	} else if md2.CreationTime != md1.CreationTime || md2.UpdateTime < md1.UpdateTime {
+		t.Errorf("unexpected metadata %v after %v", md2, md1)
+	} else if d, _ := fs.GetSecretDetails(agbotUser, "agbottoken", "myorg", "", "secret2"); d != newDetails {
+		t.Errorf("unexpected secret details %v", d)
+	}
+
+	if err := fs.DeleteOrgSecret(adminUser, "pw", "myorg", "secret2"); err != nil {
+		t.Errorf("unexpected error deleting secret: %v", err)
+	} else if _, err := fs.GetSecretMetadata("myorg", "", "secret2"); err == nil {
+		t.Errorf("secret should be deleted")
+	} else if err := fs.DeleteOrgSecret(adminUser, "pw", "myorg", "secret2"); secrets.WrapSecretsError(err).ResponseCode != 404 {
+		t.Errorf("expected 404 deleting a missing secret, got %v", err)
+	}
+}
+
+func Test_FileSecrets_ListOrgs(t *testing.T) {
+	fs, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+	defer fs.Close()
+
+	// myorg2 starts with the name of myorg, its secrets must not be listed in myorg.
+	details := secrets.SecretDetails{Key: "password", Value: "s3cret"}
+	for _, org := range []string{"myorg", "myorg2"} {
+		if err := fs.CreateOrgSecret(org+"/admin", "pw", org, "secret-"+org, details); err != nil {
+			t.Errorf("unexpected error creating secret: %v", err)
+		} else if err := fs.CreateOrgUserSecret(org+"/carol", "pw", org, "user/carol/private-"+org, details); err != nil {
+			t.Errorf("unexpected error creating user secret: %v", err)
+		}
+	}
+
+	for _, org := range []string{"myorg", "myorg2"} {
+		if names, err := fs.ListOrgSecrets(org+"/admin", "pw", org, ""); err != nil {
+			t.Errorf("unexpected error listing secrets: %v", err)
+		} else if len(names) != 1 || names[0] != "secret-"+org {
+			t.Errorf("unexpected secrets %v listed in %v", names, org)
+		}
+		if names, err := fs.ListOrgSecrets(org+"/bob", "pw", org, ""); err != nil {
+			t.Errorf("unexpected error listing secrets: %v", err)
+		} else if len(names) != 1 || names[0] != "secret-"+org {
+			t.Errorf("unexpected secrets %v listed in %v", names, org)
+		}
+		if names, err := fs.ListOrgUserSecrets(org+"/carol", "pw", org, "user/carol"); err != nil {
+			t.Errorf("unexpected error listing user secrets: %v", err)
+		} else if len(names) != 1 || names[0] != "private-"+org {
+			t.Errorf("unexpected user secrets %v listed in %v", names, org)
+		}
+	}
+}
+
+func Test_FileSecrets_Authorization(t *testing.T) {
+	fs, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+	defer fs.Close()
+
+	details := secrets.SecretDetails{Key: "k", Value: "v"}
+	if err := fs.CreateOrgSecret(adminUser, "pw", "myorg", "secret1", details); err != nil {
+		t.Fatalf("unexpected error creating secret: %v", err)
+	} else if err := fs.CreateOrgUserSecret(adminUser, "pw", "myorg", "user/carol/secret2", details); err != nil {
+		t.Fatalf("admin should be able to create user secrets: %v", err)
+	}
+
+	checkDenied := func(err error, code int) {
+		if serr := secrets.WrapSecretsError(err); serr == nil || serr.ResponseCode != code {
+			t.Errorf("expected response code %v, got %v", code, err)
+		}
+	}
+
+	// Wrong password
+	_, err := fs.ListOrgSecrets(regularUser, "bad", "myorg", "")
+	checkDenied(err, 401)
+
+	// Users in another org
+	_, err = fs.ListOrgSecrets(otherUser, "pw", "myorg", "")
+	checkDenied(err, 403)
+
+	// Regular users can not change or read org secrets, or access other user's secrets
+	checkDenied(fs.CreateOrgSecret(regularUser, "pw", "myorg", "secret1", details), 403)
+	checkDenied(fs.DeleteOrgSecret(regularUser, "pw", "myorg", "secret1"), 403)
+	_, err = fs.GetSecretDetails(regularUser, "pw", "myorg", "", "secret1")
+	checkDenied(err, 403)
+	_, err = fs.ListOrgUserSecrets(regularUser, "pw", "myorg", "user/carol")
+	checkDenied(err, 403)
+	_, err = fs.GetSecretDetails(regularUser, "pw", "myorg", "carol", "secret2")
+	checkDenied(err, 403)
+
+	// The key is reused when the store is reopened.
+	fs.Close()
+	if fs.IsReady() {
+		t.Errorf("secret store should be closed")
+	} else if err := fs.Login(); err != nil {
+		t.Errorf("unexpected error reopening: %v", err)
+	} else if d, err := fs.GetSecretDetails(adminUser, "pw", "myorg", "", "secret1"); err != nil || d != details {
+		t.Errorf("unexpected secret details %v, error: %v", d, err)
+	}
+
+	// A bad key file is an error.
+	fs.Close()
+	if err := ioutil.WriteFile(path.Join(dir, "secretstore.key"), []byte("c2hvcnQ="), 0600); err != nil {
+		t.Fatalf("unable to write key file: %v", err)
+	} else if err := fs.Login(); err == nil {
+		t.Errorf("expected an error for a short key")
+	}
+}
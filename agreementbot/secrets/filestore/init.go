@@ -0,0 +1,104 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"os"
+	"path"
+	"sync/atomic"
+	"time"
+)
+
+// The name of the secret store database file, within the configured DB path.
+const SECRET_STORE_DB_FILE = "secretstore.db"
+
+// This function is called by the anax main to allow the plugin a chance to initialize itself.
+// This function is called every time the agbot starts. The database is opened by the Login function
+// which is called following this function.
+func (fs *AgbotFileSecrets) Initialize(cfg *config.HorizonConfig) (err error) {
+
+	glog.V(1).Infof(filePluginLogString("Initializing the file secret store as the secrets plugin."))
+
+	fs.cfg = cfg
+	if cfg.Collaborators.HTTPClientFactory != nil {
+		fs.httpClient = cfg.Collaborators.HTTPClientFactory.NewHTTPClient(nil)
+	}
+	fs.getExchangeUser = fs.getExchangeUserFromExchange
+
+	glog.V(1).Infof(filePluginLogString("Initialized the file secret store as the secrets plugin"))
+
+	return nil
+}
+
+// Open the secret store database and load the encryption key. A new key is generated the first time the
+// secret store is used.
+func (fs *AgbotFileSecrets) Login() error {
+
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if fs.db != nil {
+		return nil
+	}
+
+	dbPath := fs.cfg.GetSecretStoreDBPath()
+	glog.V(3).Infof(filePluginLogString(fmt.Sprintf("opening the secret store in %v", dbPath)))
+
+	key, err := loadOrCreateKey(fs.cfg.GetSecretStoreKeyFile())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dbPath, 0700); err != nil {
+		return errors.New(fmt.Sprintf("unable to create secret store directory %v, error: %v", dbPath, err))
+	}
+
+	db, err := bolt.Open(path.Join(dbPath, SECRET_STORE_DB_FILE), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to open the secret store database, error: %v", err))
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(SECRETS_BUCKET))
+		return err
+	}); err != nil {
+		db.Close()
+		return errors.New(fmt.Sprintf("unable to create the secrets bucket, error: %v", err))
+	}
+
+	fs.db = db
+	fs.key = key
+	atomic.StoreUint64(&fs.lastInteraction, uint64(time.Now().Unix()))
+
+	glog.V(3).Infof(filePluginLogString("opened the secret store."))
+
+	return nil
+}
+
+// There are no credentials to renew for a local secret store.
+func (fs *AgbotFileSecrets) Renew() error {
+	return nil
+}
+
+func (fs *AgbotFileSecrets) IsReady() bool {
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+	return fs.db != nil
+}
+
+func (fs *AgbotFileSecrets) Close() {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	if fs.db != nil {
+		fs.db.Close()
+		fs.db = nil
+	}
+	glog.V(2).Infof("Closed file secrets implementation")
+}
+
+func (fs *AgbotFileSecrets) GetLastVaultStatus() uint64 {
+	return atomic.LoadUint64(&fs.lastInteraction)
+}
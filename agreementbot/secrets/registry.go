@@ -21,14 +21,21 @@ func Register(name string, as AgbotSecrets) {
 }
 
 // Initialize the underlying Agbot Secrets implementation depending on what is configured. If vault is configured, it is used.
-// If nothing is configured, an error is returned.
+// Otherwise, if the file based secret store is configured, it is used. If nothing is configured, an error is returned.
 func InitSecrets(cfg *config.HorizonConfig) (AgbotSecrets, error) {
 
 	if cfg.IsVaultConfigured() {
 		secretsObj := SecretsProviders["vault"]
 		return secretsObj, secretsObj.Initialize(cfg)
 
+	} else if cfg.IsSecretStoreConfigured() {
+		secretsObj, ok := SecretsProviders["file"]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("The file secret store is configured but is not registered."))
+		}
+		return secretsObj, secretsObj.Initialize(cfg)
+
 	}
-	return nil, errors.New(fmt.Sprintf("Vault is not configured correctly."))
+	return nil, errors.New(fmt.Sprintf("Neither vault nor the file secret store is configured correctly."))
 
 }
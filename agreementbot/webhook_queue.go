@@ -0,0 +1,139 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A webhook event waiting to be delivered to one webhook. Each delivery is kept in its own file in the queue directory,
+// so that the deliveries survive an agbot restart. The file names sort in the order the deliveries were queued.
+type webhookDelivery struct {
+	Id          string          `json:"id"`
+	URL         string          `json:"url"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt int64           `json:"next_attempt"` // The earliest time (in seconds) to try the next delivery
+	file        string
+}
+
+func (d webhookDelivery) String() string {
+	return fmt.Sprintf("Id: %v, URL: %v, EventType: %v, Attempts: %v, NextAttempt: %v", d.Id, d.URL, d.EventType, d.Attempts, d.NextAttempt)
+}
+
+// The queue is only used by the webhook worker's go routine, so it is not locked.
+type webhookQueue struct {
+	dir        string
+	maxSize    int
+	seq        uint64
+	deliveries []*webhookDelivery // Oldest first
+}
+
+// Open the queue in the given directory and load the deliveries that were queued before the agbot was restarted.
+func newWebhookQueue(dir string, maxSize int) (*webhookQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create webhook queue directory %v, error: %v", dir, err))
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read webhook queue directory %v, error: %v", dir, err))
+	}
+
+	q := &webhookQueue{dir: dir, maxSize: maxSize, deliveries: make([]*webhookDelivery, 0)}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		d := new(webhookDelivery)
+		if bytes, err := ioutil.ReadFile(path.Join(dir, f.Name())); err != nil {
+			glog.Errorf(whlog(fmt.Sprintf("unable to read queued event %v, error: %v", f.Name(), err)))
+			continue
+		} else if err := json.Unmarshal(bytes, d); err != nil {
+			glog.Errorf(whlog(fmt.Sprintf("discarding queued event %v that cannot be read, error: %v", f.Name(), err)))
+			os.Remove(path.Join(dir, f.Name()))
+			continue
+		}
+		d.file = f.Name()
+		q.deliveries = append(q.deliveries, d)
+	}
+	sort.Slice(q.deliveries, func(i, j int) bool { return q.deliveries[i].file < q.deliveries[j].file })
+
+	return q, nil
+}
+
+func (q *webhookQueue) Len() int {
+	return len(q.deliveries)
+}
+
+// Add a delivery to the end of the queue. When the queue is full, the oldest delivery is dropped to make room.
+func (q *webhookQueue) Add(d *webhookDelivery) error {
+	for len(q.deliveries) >= q.maxSize && len(q.deliveries) > 0 {
+		glog.Warningf(whlog(fmt.Sprintf("queue is full, dropping event %v", q.deliveries[0])))
+		if err := q.Remove(q.deliveries[0]); err != nil {
+			return err
+		}
+	}
+
+	q.seq += 1
+	d.file = fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), q.seq%1000000)
+	if err := q.write(d); err != nil {
+		return err
+	}
+	q.deliveries = append(q.deliveries, d)
+	return nil
+}
+
+// Save the changes to a delivery that is already in the queue.
+func (q *webhookQueue) Update(d *webhookDelivery) error {
+	return q.write(d)
+}
+
+func (q *webhookQueue) Remove(d *webhookDelivery) error {
+	if err := os.Remove(path.Join(q.dir, d.file)); err != nil && !os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("unable to remove queued event %v, error: %v", d.file, err))
+	}
+	for ix, qd := range q.deliveries {
+		if qd == d {
+			q.deliveries = append(q.deliveries[:ix], q.deliveries[ix+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Returns the deliveries that should be attempted at the given time, oldest first.
+func (q *webhookQueue) Due(now int64) []*webhookDelivery {
+	due := make([]*webhookDelivery, 0)
+	for _, d := range q.deliveries {
+		if d.NextAttempt <= now {
+			due = append(due, d)
+		}
+	}
+	return due
+}
+
+// The delivery is written to a temporary file first, so that a crash never leaves a partial file in the queue.
+func (q *webhookQueue) write(d *webhookDelivery) error {
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal event %v, error: %v", d, err))
+	}
+
+	tmpFile := path.Join(q.dir, d.file+".tmp")
+	if err := ioutil.WriteFile(tmpFile, bytes, 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to write event %v to %v, error: %v", d, tmpFile, err))
+	} else if err := os.Rename(tmpFile, path.Join(q.dir, d.file)); err != nil {
+		os.Remove(tmpFile)
+		return errors.New(fmt.Sprintf("unable to save event %v, error: %v", d, err))
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+package agreementbot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/worker"
+	"github.com/satori/go.uuid"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The webhook worker POSTs agreement and node lifecycle events to the webhooks in the agbot config. It listens for the
+// events on the internal message bus, so the events are sent as soon as the agbot sees them. Each event is queued on
+// disk for each webhook that wants it, and removed when the webhook accepts it with a 2xx response.
+
+// The event types that can be sent to a webhook.
+const (
+	WEBHOOK_AGREEMENT_MADE        = "agreement_made"
+	WEBHOOK_AGREEMENT_CANCELLED   = "agreement_cancelled"
+	WEBHOOK_AGREEMENT_TIMEDOUT    = "agreement_timedout"
+	WEBHOOK_NODE_HEARTBEAT_FAILED = "node_heartbeat_failed"
+	WEBHOOK_SERVICE_ROLLBACK      = "service_rollback"
+)
+
+var webhookEventTypes = map[string]bool{
+	WEBHOOK_AGREEMENT_MADE:        true,
+	WEBHOOK_AGREEMENT_CANCELLED:   true,
+	WEBHOOK_AGREEMENT_TIMEDOUT:    true,
+	WEBHOOK_NODE_HEARTBEAT_FAILED: true,
+	WEBHOOK_SERVICE_ROLLBACK:      true,
+}
+
+// The HTTP headers sent with each event.
+const (
+	WEBHOOK_HEADER_EVENT     = "X-Horizon-Event"
+	WEBHOOK_HEADER_DELIVERY  = "X-Horizon-Delivery"
+	WEBHOOK_HEADER_SIGNATURE = "X-Horizon-Signature"
+	WEBHOOK_HEADER_TIMESTAMP = "X-Horizon-Timestamp"
+)
+
+// The body of the POST to a webhook. Only the fields that apply to the event type are set.
+type WebhookEvent struct {
+	Id                string   `json:"id"`
+	Type              string   `json:"type"`
+	Time              string   `json:"time"`
+	AgbotId           string   `json:"agbot_id"`
+	AgreementId       string   `json:"agreement_id,omitempty"`
+	AgreementProtocol string   `json:"agreement_protocol,omitempty"`
+	NodeId            string   `json:"node_id,omitempty"`
+	PolicyName        string   `json:"policy_name,omitempty"`
+	Pattern           string   `json:"pattern,omitempty"`
+	Services          []string `json:"services,omitempty"`
+	Reason            uint     `json:"reason,omitempty"`
+	ReasonDescription string   `json:"reason_description,omitempty"`
+	ServiceURL        string   `json:"service_url,omitempty"`
+	ServiceOrg        string   `json:"service_org,omitempty"`
+	ServiceVersion    string   `json:"service_version,omitempty"`
+	Priority          int      `json:"priority,omitempty"`
+	PreviousPriority  int      `json:"previous_priority,omitempty"`
+}
+
+func (e WebhookEvent) String() string {
+	return fmt.Sprintf("Id: %v, Type: %v, AgreementId: %v, NodeId: %v, PolicyName: %v", e.Id, e.Type, e.AgreementId, e.NodeId, e.PolicyName)
+}
+
+// Convert a message from the internal message bus into a webhook event. Returns nil for messages that are not sent to webhooks.
+func newWebhookEvent(msg events.Message, agbotId string) *WebhookEvent {
+	ev := &WebhookEvent{AgbotId: agbotId}
+
+	switch m := msg.(type) {
+	case *events.ABAgreementMessage:
+		switch m.Event().Id {
+		case events.AB_AGREEMENT_MADE:
+			ev.Type = WEBHOOK_AGREEMENT_MADE
+		case events.AB_AGREEMENT_CANCELLED:
+			ev.Type = WEBHOOK_AGREEMENT_CANCELLED
+		case events.AB_AGREEMENT_TIMEDOUT:
+			ev.Type = WEBHOOK_AGREEMENT_TIMEDOUT
+		default:
+			return nil
+		}
+		ev.AgreementId = m.AgreementId
+		ev.AgreementProtocol = m.AgreementProtocol
+		ev.NodeId = m.DeviceId
+		ev.PolicyName = m.PolicyName
+		ev.Pattern = m.Pattern
+		ev.Services = m.ServiceIds
+		ev.Reason = m.Reason
+		ev.ReasonDescription = m.ReasonDescription
+
+	case *events.NodeHeartbeatStateChangeMessage:
+		if m.Event().Id != events.NODE_HEARTBEAT_FAILED {
+			return nil
+		}
+		ev.Type = WEBHOOK_NODE_HEARTBEAT_FAILED
+		ev.NodeId = fmt.Sprintf("%v/%v", m.NodeOrg, m.NodeId)
+
+	case *events.ABServiceRollbackMessage:
+		ev.Type = WEBHOOK_SERVICE_ROLLBACK
+		ev.AgreementId = m.AgreementId
+		ev.NodeId = m.DeviceId
+		ev.PolicyName = m.PolicyName
+		ev.ServiceURL = m.ServiceURL
+		ev.ServiceOrg = m.ServiceOrg
+		ev.ServiceVersion = m.Version
+		ev.Priority = m.Priority
+		ev.PreviousPriority = m.PreviousPriority
+
+	default:
+		return nil
+	}
+
+	if id, err := uuid.NewV4(); err == nil {
+		ev.Id = id.String()
+	}
+	ev.Time = time.Now().UTC().Format(time.RFC3339)
+	return ev
+}
+
+// Returns true if the webhook wants events of the given type.
+func webhookSubscribed(ep *config.WebhookEndpoint, eventType string) bool {
+	if len(ep.Events) == 0 {
+		return true
+	}
+	for _, t := range ep.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// The signature is the hex encoded HMAC-SHA256 of the timestamp of the attempt, a '.' and the body, prefixed with the
+// name of the hash. The timestamp is signed so that the webhook can reject a captured request that is sent again later.
+func webhookSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// The number of seconds to wait before the next attempt, after the given number of failed attempts.
+func webhookRetryDelay(attempts int, intervalS int, maxIntervalS int) int64 {
+	delay := int64(intervalS)
+	for i := 1; i < attempts && delay < int64(maxIntervalS); i++ {
+		delay *= 2
+	}
+	if delay > int64(maxIntervalS) {
+		delay = int64(maxIntervalS)
+	}
+	return delay
+}
+
+type WebhookWorker struct {
+	worker.BaseWorker // embedded field
+	queue             *webhookQueue
+	httpClient        *http.Client
+	endpoints         map[string]*config.WebhookEndpoint // Keyed by URL
+}
+
+func NewWebhookWorker(name string, cfg *config.HorizonConfig) *WebhookWorker {
+
+	ec := worker.NewExchangeContext(cfg.AgreementBot.ExchangeId, cfg.AgreementBot.ExchangeToken, cfg.AgreementBot.ExchangeURL, cfg.AgreementBot.CSSURL, cfg.Collaborators.HTTPClientFactory)
+	timeout := uint(cfg.AgreementBot.Webhooks.TimeoutS)
+	worker := &WebhookWorker{
+		BaseWorker: worker.NewBaseWorker(name, cfg, ec),
+		httpClient: cfg.Collaborators.HTTPClientFactory.NewHTTPClient(&timeout),
+		endpoints:  make(map[string]*config.WebhookEndpoint),
+	}
+
+	glog.Info(whlog(fmt.Sprintf("Starting Webhook worker")))
+
+	worker.Start(worker, 1)
+	return worker
+}
+
+func (w *WebhookWorker) Messages() chan events.Message {
+	return w.BaseWorker.Manager.Messages
+}
+
+func (w *WebhookWorker) Initialize() bool {
+
+	for ix, ep := range w.Config.AgreementBot.Webhooks.Endpoints {
+		for _, t := range ep.Events {
+			if !webhookEventTypes[t] {
+				glog.Warningf(whlog(fmt.Sprintf("webhook %v is configured with unknown event type %v", ep.URL, t)))
+			}
+		}
+		w.endpoints[ep.URL] = &w.Config.AgreementBot.Webhooks.Endpoints[ix]
+	}
+
+	queuePath := w.Config.GetWebhookQueuePath()
+	if queuePath == "" {
+		glog.Errorf(whlog(fmt.Sprintf("webhooks are configured but there is no queue path, webhooks are turned off")))
+		return false
+	}
+
+	queue, err := newWebhookQueue(queuePath, w.Config.AgreementBot.Webhooks.MaxQueueSize)
+	if err != nil {
+		glog.Errorf(whlog(fmt.Sprintf("webhooks are turned off, %v", err)))
+		return false
+	}
+	w.queue = queue
+
+	glog.Info(whlog(fmt.Sprintf("%v events waiting to be delivered", w.queue.Len())))
+	return true
+}
+
+// Handle events that are propogated to this worker from the internal event bus.
+func (w *WebhookWorker) NewEvent(incoming events.Message) {
+
+	switch incoming.(type) {
+
+	case *events.NodeShutdownCompleteMessage:
+		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
+		switch msg.Event().Id {
+		case events.AGBOT_QUIESCE_COMPLETE:
+			w.Commands <- worker.NewTerminateCommand("shutdown")
+		}
+
+	default:
+		if ev := newWebhookEvent(incoming, w.GetExchangeId()); ev != nil {
+			w.Commands <- NewWebhookEventCommand(ev)
+		}
+
+	}
+
+	return
+}
+
+// Handle commands that are placed on the command queue.
+func (w *WebhookWorker) CommandHandler(command worker.Command) bool {
+
+	switch command.(type) {
+	case *WebhookEventCommand:
+		cmd, _ := command.(*WebhookEventCommand)
+		w.enqueue(cmd.Event)
+		w.deliver()
+
+	default:
+		return false
+	}
+
+	return true
+
+}
+
+// Retry the deliveries that are due.
+func (w *WebhookWorker) NoWorkHandler() {
+	w.deliver()
+}
+
+// Queue the event for each webhook that wants it.
+func (w *WebhookWorker) enqueue(ev *WebhookEvent) {
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		glog.Errorf(whlog(fmt.Sprintf("unable to marshal event %v, error: %v", ev, err)))
+		return
+	}
+
+	for _, ep := range w.Config.AgreementBot.Webhooks.Endpoints {
+		if !webhookSubscribed(&ep, ev.Type) {
+			continue
+		}
+		d := &webhookDelivery{Id: ev.Id, URL: ep.URL, EventType: ev.Type, Payload: payload, NextAttempt: time.Now().Unix()}
+		if err := w.queue.Add(d); err != nil {
+			glog.Errorf(whlog(fmt.Sprintf("unable to queue event %v for %v, error: %v", ev, ep.URL, err)))
+		}
+	}
+}
+
+// Try to deliver the events that are due. Once a webhook cannot be reached, the rest of its events wait for the next pass
+// so that a webhook that is down does not hold up the others.
+func (w *WebhookWorker) deliver() {
+
+	now := time.Now().Unix()
+	failed := make(map[string]bool)
+	cfg := w.Config.AgreementBot.Webhooks
+
+	for _, d := range w.queue.Due(now) {
+		ep, ok := w.endpoints[d.URL]
+		if !ok {
+			glog.Warningf(whlog(fmt.Sprintf("discarding event %v for a webhook that is no longer configured", d)))
+			w.remove(d)
+			continue
+		} else if failed[d.URL] {
+			continue
+		}
+
+		retry, err := w.post(ep, d)
+		if err == nil {
+			glog.V(5).Infof(whlog(fmt.Sprintf("delivered event %v", d)))
+			w.remove(d)
+			continue
+		}
+
+		d.Attempts += 1
+		if !retry || d.Attempts > cfg.MaxRetries {
+			glog.Errorf(whlog(fmt.Sprintf("discarding event %v after %v attempts, error: %v", d, d.Attempts, err)))
+			w.remove(d)
+			continue
+		}
+
+		failed[d.URL] = true
+		d.NextAttempt = now + webhookRetryDelay(d.Attempts, cfg.RetryIntervalS, cfg.MaxRetryIntervalS)
+		glog.Warningf(whlog(fmt.Sprintf("unable to deliver event %v, will retry, error: %v", d, err)))
+		if err := w.queue.Update(d); err != nil {
+			glog.Errorf(whlog(err.Error()))
+		}
+	}
+}
+
+func (w *WebhookWorker) remove(d *webhookDelivery) {
+	if err := w.queue.Remove(d); err != nil {
+		glog.Errorf(whlog(err.Error()))
+	}
+}
+
+// POST the event to the webhook. Returns an error if it was not accepted, and whether it is worth trying again.
+func (w *WebhookWorker) post(ep *config.WebhookEndpoint, d *webhookDelivery) (bool, error) {
+
+	req, err := http.NewRequest("POST", ep.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("unable to create request, error: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WEBHOOK_HEADER_EVENT, d.EventType)
+	req.Header.Set(WEBHOOK_HEADER_DELIVERY, d.Id)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(WEBHOOK_HEADER_TIMESTAMP, timestamp)
+	if ep.Secret != "" {
+		req.Header.Set(WEBHOOK_HEADER_SIGNATURE, webhookSignature(ep.Secret, timestamp, d.Payload))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	// Client errors other than timeouts and rate limiting will not go away by retrying.
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, errors.New(fmt.Sprintf("webhook responded with %v", resp.Status))
+}
+
+var whlog = func(v interface{}) string {
+	return fmt.Sprintf("Webhook Worker: %v", v)
+}
@@ -0,0 +1,170 @@
+// +build unit
+
+package agreementbot
+
+import (
+	"encoding/json"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/worker"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_webhookQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newWebhookQueue(dir, 2)
+	if err != nil {
+		t.Fatalf("unexpected error opening queue: %v", err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := q.Add(&webhookDelivery{Id: id, URL: "http://hook", Payload: json.RawMessage(`{}`), NextAttempt: 100}); err != nil {
+			t.Fatalf("unexpected error adding to queue: %v", err)
+		}
+	}
+
+	// The oldest delivery was dropped to make room.
+	if q.Len() != 2 || q.deliveries[0].Id != "2" {
+		t.Errorf("expected deliveries 2 and 3, got %v", q.deliveries)
+	}
+
+	q.deliveries[0].NextAttempt = 200
+	q.deliveries[0].Attempts = 1
+	if err := q.Update(q.deliveries[0]); err != nil {
+		t.Errorf("unexpected error updating delivery: %v", err)
+	}
+	if due := q.Due(150); len(due) != 1 || due[0].Id != "3" {
+		t.Errorf("expected delivery 3 to be due, got %v", due)
+	}
+
+	// The deliveries are reloaded in order, with their changes.
+	ioutil.WriteFile(dir+"/bad.json", []byte("not json"), 0600)
+	q, err = newWebhookQueue(dir, 2)
+	if err != nil {
+		t.Fatalf("unexpected error reopening queue: %v", err)
+	} else if q.Len() != 2 || q.deliveries[0].Id != "2" || q.deliveries[0].Attempts != 1 || q.deliveries[1].Id != "3" {
+		t.Errorf("unexpected deliveries after reopening %v", q.deliveries)
+	}
+
+	if err := q.Remove(q.deliveries[0]); err != nil {
+		t.Errorf("unexpected error removing delivery: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("expected 1 file in the queue, got %v", len(files))
+	}
+}
+
+func Test_webhookRetryDelay(t *testing.T) {
+	for _, test := range []struct {
+		attempts int
+		expected int64
+	}{{1, 5}, {2, 10}, {4, 40}, {8, 600}, {100, 600}} {
+		if delay := webhookRetryDelay(test.attempts, 5, 600); delay != test.expected {
+			t.Errorf("attempt %v expected delay %v, got %v", test.attempts, test.expected, delay)
+		}
+	}
+}
+
+func Test_newWebhookEvent(t *testing.T) {
+	msg := events.NewABAgreementMessage(events.AB_AGREEMENT_TIMEDOUT, "Basic", "ag1", "myorg/node1", "myorg/pol", "", []string{"myorg/svc_1.0.0_amd64"}, 203, "no data")
+	if ev := newWebhookEvent(msg, "myorg/agbot"); ev == nil {
+		t.Errorf("expected an event")
+	} else if ev.Type != WEBHOOK_AGREEMENT_TIMEDOUT || ev.NodeId != "myorg/node1" || ev.Reason != 203 || ev.AgbotId != "myorg/agbot" || ev.Id == "" || ev.Time == "" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+
+	hb := events.NewNodeHeartbeatStateChangeMessage(events.NODE_HEARTBEAT_FAILED, "myorg", "node1")
+	if ev := newWebhookEvent(hb, ""); ev == nil || ev.Type != WEBHOOK_NODE_HEARTBEAT_FAILED || ev.NodeId != "myorg/node1" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+
+	restored := events.NewNodeHeartbeatStateChangeMessage(events.NODE_HEARTBEAT_RESTORED, "myorg", "node1")
+	if ev := newWebhookEvent(restored, ""); ev != nil {
+		t.Errorf("expected no event, got %+v", ev)
+	}
+}
+
+func Test_WebhookWorker_deliver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The first webhook fails once and then accepts events, the second rejects them.
+	calls := 0
+	var signature, timestamp string
+	var body []byte
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls += 1
+		signature = r.Header.Get(WEBHOOK_HEADER_SIGNATURE)
+		timestamp = r.Header.Get(WEBHOOK_HEADER_TIMESTAMP)
+		body, _ = ioutil.ReadAll(r.Body)
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer bad.Close()
+
+	cfg := &config.HorizonConfig{
+		AgreementBot: config.AGConfig{
+			Webhooks: &config.WebhookConfig{
+				Endpoints: []config.WebhookEndpoint{
+					{URL: good.URL, Secret: "shh", Events: []string{WEBHOOK_AGREEMENT_MADE}},
+					{URL: bad.URL},
+				},
+				QueuePath:         dir,
+				MaxQueueSize:      10,
+				MaxRetries:        3,
+				RetryIntervalS:    1,
+				MaxRetryIntervalS: 1,
+			},
+		},
+	}
+	w := &WebhookWorker{
+		BaseWorker: worker.NewBaseWorker("webhooks", cfg, nil),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		endpoints:  make(map[string]*config.WebhookEndpoint),
+	}
+	if !w.Initialize() {
+		t.Fatalf("worker failed to initialize")
+	}
+
+	// The heartbeat event only goes to the second webhook, which rejects it for good.
+	w.enqueue(newWebhookEvent(events.NewNodeHeartbeatStateChangeMessage(events.NODE_HEARTBEAT_FAILED, "myorg", "node1"), ""))
+	w.enqueue(newWebhookEvent(events.NewABAgreementMessage(events.AB_AGREEMENT_MADE, "Basic", "ag1", "myorg/node1", "myorg/pol", "", nil, 0, ""), ""))
+	if w.queue.Len() != 3 {
+		t.Fatalf("expected 3 queued deliveries, got %v", w.queue.Len())
+	}
+
+	w.deliver()
+	if w.queue.Len() != 1 || w.queue.deliveries[0].URL != good.URL || w.queue.deliveries[0].Attempts != 1 {
+		t.Fatalf("expected the first webhook's delivery to be retried, got %v", w.queue.deliveries)
+	}
+
+	w.queue.deliveries[0].NextAttempt = 0
+	w.deliver()
+	if w.queue.Len() != 0 || calls != 2 {
+		t.Errorf("expected the delivery to succeed on the retry, %v calls, queue %v", calls, w.queue.deliveries)
+	} else if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || ts < time.Now().Unix()-60 {
+		t.Errorf("expected the time of the attempt, got %v", timestamp)
+	} else if signature != webhookSignature("shh", timestamp, body) {
+		t.Errorf("expected the signature of the timestamp and body, got %v", signature)
+	} else if signature == webhookSignature("shh", strconv.FormatInt(ts-1, 10), body) {
+		t.Errorf("the signature should change with the timestamp")
+	}
+}
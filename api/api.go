@@ -27,6 +27,7 @@ type API struct {
 	bcStateLock    sync.Mutex
 	shutdownError  string
 	EC             *worker.BaseExchangeContext
+	apiToken       string // the local bearer token that authorizes changes to the node
 }
 
 type BlockchainState struct {
@@ -62,6 +63,7 @@ func NewAPIListener(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *pol
 		listener.EC = worker.NewExchangeContext(fmt.Sprintf("%v/%v", pDevice.Org, pDevice.Id), pDevice.Token, cfg.Edge.ExchangeURL, cfg.GetCSSURL(), cfg.Collaborators.HTTPClientFactory)
 	}
 
+	listener.registerMetrics()
 	listener.listen(cfg)
 	return listener
 }
@@ -82,11 +84,16 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/service/config", a.serviceconfig).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/configstate", a.service_configstate).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/policy", a.servicepolicy).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/stats", a.servicestats).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/log", a.servicelog).Methods("GET", "OPTIONS")
 
 	// Connectivity and blockchain status info
 	router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
 	router.HandleFunc("/status/workers", a.workerstatus).Methods("GET", "OPTIONS")
 
+	// Runtime metrics in the Prometheus text format
+	router.HandleFunc("/metrics", a.metrics).Methods("GET", "OPTIONS")
+
 	// Used by the Registration UI to obtain a random token string
 	router.HandleFunc("/token/random", tokenRandom).Methods("GET", "OPTIONS")
 
@@ -95,12 +102,15 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/node/configstate", a.nodeconfigstate).Methods("GET", "HEAD", "PUT", "OPTIONS")
 	router.HandleFunc("/node/policy", a.nodepolicy).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
 	router.HandleFunc("/node/userinput", a.nodeuserinput).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
+	router.HandleFunc("/node/diagnostics", a.nodediagnostics).Methods("GET", "POST", "OPTIONS")
 
 	// Used to get the event logs on this node.
 	// get the eventlogs for current registration.
 	router.HandleFunc("/eventlog", a.eventlog).Methods("GET", "OPTIONS")
 	// get the eventlogs for all registrations.
 	router.HandleFunc("/eventlog/all", a.eventlog).Methods("GET", "OPTIONS")
+	// stream the eventlogs for current registration as they are saved.
+	router.HandleFunc("/eventlog/stream", a.eventlogstream).Methods("GET", "OPTIONS")
 	//get the active surface errors for this node
 	router.HandleFunc("/eventlog/surface", a.surface).Methods("GET", "OPTIONS")
 
@@ -134,14 +144,39 @@ func (a *API) listen(cfg *config.HorizonConfig) {
 		})
 	}
 
+	socketPath := cfg.GetAPISocketPath()
+	if socketPath != "" || !cfg.Edge.APISocket.AllowTCPWithoutToken {
+		if token, err := loadAPIToken(cfg.GetAPITokenFile()); err != nil {
+			glog.Errorf(apiLogString(fmt.Sprintf("Unable to load the local API token, requests with a bearer token will be rejected. Error %v", err)))
+		} else {
+			a.apiToken = token
+		}
+	}
+
 	// This routine does not need to be a subworker because there is no way to terminate it. It will terminate when
 	// the main anax process goes away.
 	go func() {
-		if err := http.ListenAndServe(cfg.Edge.APIListen, nocache(a.router(true))); err != nil {
+		if err := http.ListenAndServe(cfg.Edge.APIListen, nocache(a.authorize(a.router(true), false))); err != nil {
 			glog.Fatalf(apiLogString(fmt.Sprintf("Failed to start listener on %v, error %v", cfg.Edge.APIListen, err)))
 		}
 	}()
 
+	// The API is also served on a unix domain socket, where the credentials of the calling process are known. The
+	// agent keeps running without the socket if it can not be created, the API is still available on APIListen.
+	if socketPath != "" {
+		if listener, err := listenUnixSocket(socketPath); err != nil {
+			glog.Errorf(apiLogString(fmt.Sprintf("Failed to start listener on unix domain socket %v, error %v", socketPath, err)))
+		} else {
+			server := &http.Server{Handler: nocache(a.authorize(a.router(false), true)), ConnContext: savePeerCred}
+			go func() {
+				if err := server.Serve(listener); err != nil {
+					glog.Errorf(apiLogString(fmt.Sprintf("Listener on unix domain socket %v terminated, error %v", socketPath, err)))
+				}
+			}()
+			glog.Info(apiLogString(fmt.Sprintf("Serving the Anax API on unix domain socket %v", socketPath)))
+		}
+	}
+
 }
 
 // Worker framework functions
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/i18n"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The credentials of the process on the other end of a unix domain socket connection.
+type peerCred struct {
+	Pid int32
+	Uid uint32
+	Gid uint32
+}
+
+type peerCredKey struct{}
+
+// Used as the ConnContext of the http server on the unix domain socket, so that the handlers can find the
+// credentials of the process that made the request.
+func savePeerCred(ctx context.Context, c net.Conn) context.Context {
+	if cred, err := getPeerCred(c); err != nil {
+		glog.Warningf(apiLogString(fmt.Sprintf("Unable to get the peer credentials of a socket connection, error %v", err)))
+	} else {
+		ctx = context.WithValue(ctx, peerCredKey{}, cred)
+	}
+	return ctx
+}
+
+func getRequestPeerCred(r *http.Request) *peerCred {
+	if cred, ok := r.Context().Value(peerCredKey{}).(*peerCred); ok {
+		return cred
+	}
+	return nil
+}
+
+// Requests that only read the state of the node are always allowed.
+func isMutatingRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// The resources that return node details which may still hold secrets after redaction. They are only available to
+// root and to the holders of the local bearer token, whatever the request method.
+var sensitivePaths = map[string]bool{
+	"/node/diagnostics": true,
+}
+
+func isSensitiveRequest(r *http.Request) bool {
+	return sensitivePaths[strings.TrimSuffix(r.URL.Path, "/")]
+}
+
+// The users allowed to read the sensitive resources through the unix domain socket, root and the agent user.
+func peerIsRoot(cred *peerCred) bool {
+	return cred != nil && (cred.Uid == 0 || cred.Uid == uint32(os.Getuid()))
+}
+
+// The users allowed to change the node through the unix domain socket.
+func peerIsAllowed(cred *peerCred, cfg *config.APISocketConfig) bool {
+	if cred == nil {
+		return false
+	} else if cred.Uid == 0 || cred.Uid == uint32(os.Getuid()) {
+		return true
+	}
+	for _, uid := range cfg.AllowedUIDs {
+		if cred.Uid == uid {
+			return true
+		}
+	}
+	for _, gid := range cfg.AllowedGIDs {
+		if cred.Gid == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the bearer token in the Authorization header, or empty string if there is none.
+func getBearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// Wrap the API handlers so that requests which change the node are only allowed from authorized local processes.
+// On the unix domain socket, the local bearer token or the peer credentials of the process are checked. On the TCP
+// listener, changes need the token unless the configuration allows them without it, for existing clients that can not
+// send the token. The sensitive resources always need the token, or root peer credentials on the unix domain socket.
+func (a *API) authorize(h http.Handler, fromSocket bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sensitive := isSensitiveRequest(r)
+		if !sensitive && !isMutatingRequest(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		msgPrinter := i18n.GetMessagePrinterWithLocale(r.Header.Get("Accept-Language"))
+		socketCfg := &a.Config.Edge.APISocket
+		token := getBearerToken(r)
+		validToken := token != "" && a.apiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.apiToken)) == 1
+
+		if fromSocket {
+			if validToken {
+				h.ServeHTTP(w, r)
+			} else if token != "" {
+				writeInputErr(w, http.StatusUnauthorized, NewAPIUserInputError(msgPrinter.Sprintf("The bearer token is not valid."), "Authorization"))
+			} else if cred := getRequestPeerCred(r); sensitive && peerIsRoot(cred) || !sensitive && peerIsAllowed(cred, socketCfg) {
+				h.ServeHTTP(w, r)
+			} else if sensitive {
+				glog.Warningf(apiLogString(fmt.Sprintf("Rejected %v %v on the unix domain socket from %v", r.Method, r.URL.Path, cred)))
+				writeInputErr(w, http.StatusForbidden, NewAPIUserInputError(msgPrinter.Sprintf("Only root or a user with the local bearer token can use %v.", r.URL.Path), "Authorization"))
+			} else {
+				glog.Warningf(apiLogString(fmt.Sprintf("Rejected %v %v on the unix domain socket from %v", r.Method, r.URL.Path, cred)))
+				writeInputErr(w, http.StatusForbidden, NewAPIUserInputError(msgPrinter.Sprintf("The user is not allowed to change the node through the agent API."), "Authorization"))
+			}
+		} else if validToken || !sensitive && socketCfg.AllowTCPWithoutToken {
+			h.ServeHTTP(w, r)
+		} else if token != "" {
+			writeInputErr(w, http.StatusUnauthorized, NewAPIUserInputError(msgPrinter.Sprintf("The bearer token is not valid."), "Authorization"))
+		} else {
+			writeInputErr(w, http.StatusUnauthorized, NewAPIUserInputError(msgPrinter.Sprintf("The request must carry the local bearer token in the Authorization header."), "Authorization"))
+		}
+	})
+}
+
+// Read the local bearer token, creating the token file with a random token if it does not exist. The token file is
+// readable by the group of the file so that an administrator can give other users access to the token.
+func loadAPIToken(tokenFile string) (string, error) {
+	if content, err := ioutil.ReadFile(tokenFile); err == nil {
+		if token := strings.TrimSpace(string(content)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("unable to read token file %v, error %v", tokenFile, err)
+	}
+
+	token, err := cutil.SecureRandomString()
+	if err != nil {
+		return "", fmt.Errorf("unable to generate token, error %v", err)
+	} else if err := os.MkdirAll(filepath.Dir(tokenFile), 0755); err != nil {
+		return "", fmt.Errorf("unable to create directory for token file %v, error %v", tokenFile, err)
+	} else if err := ioutil.WriteFile(tokenFile, []byte(token), 0640); err != nil {
+		return "", fmt.Errorf("unable to write token file %v, error %v", tokenFile, err)
+	}
+	return token, nil
+}
+
+// Create the unix domain socket for the agent API, replacing a socket left over by a previous run of the agent. Any
+// local user can connect to the socket, the authorize handler decides which requests are allowed.
+func listenUnixSocket(socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create directory for socket %v, error %v", socketPath, err)
+	} else if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to remove old socket %v, error %v", socketPath, err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	} else if err := os.Chmod(socketPath, 0666); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("unable to set the permissions of socket %v, error %v", socketPath, err)
+	}
+	return listener, nil
+}
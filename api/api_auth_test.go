@@ -0,0 +1,172 @@
+// +build unit
+
+package api
+
+import (
+	"context"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/worker"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func newAuthTestAPI(socketCfg config.APISocketConfig, token string) *API {
+	return &API{Manager: worker.Manager{Config: &config.HorizonConfig{Edge: config.Config{APISocket: socketCfg}}}, apiToken: token}
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func doAuthRequest(t *testing.T, client *http.Client, method string, url string, token string) int {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("error creating request: %v", err)
+	}
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error running %v %v: %v", method, url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func Test_authorize_tcp(t *testing.T) {
+
+	// By default, only changes with the token are allowed on the TCP listener.
+	a := newAuthTestAPI(config.APISocketConfig{}, "secret")
+	server := httptest.NewServer(a.authorize(okHandler, false))
+	defer server.Close()
+
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, server.Client(), "GET", server.URL+"/node", ""))
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(t, server.Client(), "POST", server.URL+"/node", ""))
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(t, server.Client(), "POST", server.URL+"/node", "wrong"))
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, server.Client(), "POST", server.URL+"/node", "secret"))
+
+	// With AllowTCPWithoutToken, the TCP listener behaves as in earlier releases and ignores the token.
+	a = newAuthTestAPI(config.APISocketConfig{AllowTCPWithoutToken: true}, "secret")
+	server2 := httptest.NewServer(a.authorize(okHandler, false))
+	defer server2.Close()
+
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, server2.Client(), "GET", server2.URL+"/node", ""))
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, server2.Client(), "DELETE", server2.URL+"/node", ""))
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, server2.Client(), "DELETE", server2.URL+"/node", "wrong"))
+
+	// The sensitive resources always need the token, the redacted service logs can be read like the rest of the node.
+	for _, url := range []string{server.URL, server2.URL} {
+		assert.Equal(t, http.StatusOK, doAuthRequest(t, server.Client(), "GET", url+"/service/log?url=svc1", ""))
+		assert.Equal(t, http.StatusUnauthorized, doAuthRequest(t, server.Client(), "GET", url+"/node/diagnostics", ""))
+		assert.Equal(t, http.StatusUnauthorized, doAuthRequest(t, server.Client(), "GET", url+"/node/diagnostics", "wrong"))
+		assert.Equal(t, http.StatusOK, doAuthRequest(t, server.Client(), "GET", url+"/node/diagnostics", "secret"))
+	}
+}
+
+func Test_authorize_socket(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "apisocket-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := path.Join(dir, "anax.sock")
+	listener, err := listenUnixSocket(socketPath)
+	if err != nil {
+		t.Fatalf("unable to listen on socket: %v", err)
+	}
+
+	a := newAuthTestAPI(config.APISocketConfig{}, "secret")
+	server := &http.Server{Handler: a.authorize(okHandler, true), ConnContext: savePeerCred}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", socketPath)
+		},
+	}}
+
+	// The test runs as the same user as the "agent", so changes are allowed.
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, client, "GET", "http://localhost/node", ""))
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, client, "PATCH", "http://localhost/node", ""))
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(t, client, "PATCH", "http://localhost/node", "wrong"))
+	assert.Equal(t, http.StatusOK, doAuthRequest(t, client, "GET", "http://localhost/node/diagnostics", ""))
+
+	// A socket left over by a previous run is replaced.
+	server.Close()
+	if listener2, err := listenUnixSocket(socketPath); err != nil {
+		t.Errorf("unable to replace socket: %v", err)
+	} else {
+		listener2.Close()
+	}
+}
+
+func Test_peerIsAllowed(t *testing.T) {
+
+	cfg := &config.APISocketConfig{AllowedUIDs: []uint32{1001}, AllowedGIDs: []uint32{2001}}
+	other := uint32(os.Getuid()) + 12345
+
+	assert.False(t, peerIsAllowed(nil, cfg), "unknown peers are not allowed")
+	assert.True(t, peerIsAllowed(&peerCred{Uid: 0, Gid: 0}, cfg), "root is allowed")
+	assert.True(t, peerIsAllowed(&peerCred{Uid: uint32(os.Getuid()), Gid: other}, cfg), "the agent user is allowed")
+	if other != 1001 {
+		assert.False(t, peerIsAllowed(&peerCred{Uid: other, Gid: other}, cfg), "other users are not allowed")
+		assert.True(t, peerIsAllowed(&peerCred{Uid: other, Gid: 2001}, cfg), "users in an allowed group are allowed")
+	}
+	assert.True(t, peerIsAllowed(&peerCred{Uid: 1001, Gid: other}, cfg), "allowed users are allowed")
+}
+
+func Test_peerIsRoot(t *testing.T) {
+	other := uint32(os.Getuid()) + 12345
+
+	assert.False(t, peerIsRoot(nil), "unknown peers are not root")
+	assert.True(t, peerIsRoot(&peerCred{Uid: 0, Gid: other}), "root is root")
+	assert.True(t, peerIsRoot(&peerCred{Uid: uint32(os.Getuid()), Gid: other}), "the agent user is allowed")
+	assert.False(t, peerIsRoot(&peerCred{Uid: other, Gid: 0}), "other users are not root, even in the root group")
+}
+
+func Test_loadAPIToken(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "apitoken-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := path.Join(dir, "run", "anax-api.token")
+	token, err := loadAPIToken(tokenFile)
+	if err != nil {
+		t.Fatalf("unexpected error creating token: %v", err)
+	}
+	assert.NotEmpty(t, token)
+
+	// The token is reused.
+	if token2, err := loadAPIToken(tokenFile); err != nil {
+		t.Errorf("unexpected error loading token: %v", err)
+	} else {
+		assert.Equal(t, token, token2)
+	}
+
+	// A token provided by the administrator is used as is.
+	if err := ioutil.WriteFile(tokenFile, []byte(" mytoken\n"), 0600); err != nil {
+		t.Fatalf("unable to write token file: %v", err)
+	} else if token3, err := loadAPIToken(tokenFile); err != nil {
+		t.Errorf("unexpected error loading token: %v", err)
+	} else {
+		assert.Equal(t, "mytoken", token3)
+	}
+
+	req, _ := http.NewRequest("POST", "http://localhost/node", strings.NewReader(""))
+	req.Header.Add("Authorization", "bearer mytoken")
+	assert.Equal(t, "mytoken", getBearerToken(req))
+}
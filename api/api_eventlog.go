@@ -1,13 +1,19 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/i18n"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// How often a comment is sent on an idle event log stream, so that clients and proxies keep the connection open.
+const EVENTLOG_STREAM_KEEPALIVE = 15 * time.Second
+
 // get the eventlogs for current registration.
 func (a *API) eventlog(w http.ResponseWriter, r *http.Request) {
 
@@ -51,6 +57,97 @@ func (a *API) eventlog(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// Stream the eventlogs for the current registration as they are saved, filtered by the same selections as /eventlog.
+// By default the event logs are sent as server-sent events. If the wait parameter is set, this is a long-poll request
+// that returns the new event logs as soon as there are any, or an empty list after waiting for the given seconds.
+func (a *API) eventlogstream(w http.ResponseWriter, r *http.Request) {
+
+	resource := "eventlog/stream"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		// get message printer with the language passed in from the header
+		lan := r.Header.Get("Accept-Language")
+		if lan == "" {
+			lan = i18n.DEFAULT_LANGUAGE
+		}
+		msgPrinter := i18n.GetMessagePrinterWithLocale(lan)
+
+		if err := r.ParseForm(); err != nil {
+			errorHandler(NewAPIUserInputError(msgPrinter.Sprintf("Error parsing the selections %v. %v", r.Form, err), "selection"))
+			return
+		}
+
+		// A server-sent events client that reconnects sends the id of the last event it received.
+		if id := r.Header.Get("Last-Event-ID"); id != "" && r.Form.Get(EVENTLOG_STREAM_SINCE) == "" {
+			r.Form.Set(EVENTLOG_STREAM_SINCE, id)
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v. Language: %v", r.Method, resource, r.Form, lan)))
+
+		selectors, since, wait, err := GetEventLogStreamSelectors(a.db, r.Form, msgPrinter)
+		if err != nil {
+			errorHandler(NewAPIUserInputError(err.Error(), "selection"))
+			return
+		}
+
+		// Long-poll request
+		if r.Form.Get(EVENTLOG_STREAM_WAIT) != "" {
+			if out, err := WaitForEventLogs(r.Context(), a.db, since, selectors, time.Duration(wait)*time.Second, msgPrinter); err != nil {
+				errorHandler(NewSystemError(msgPrinter.Sprintf("Error getting %v for output, error %v", resource, err)))
+			} else {
+				writeResponse(w, out, http.StatusOK)
+			}
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errorHandler(NewSystemError(msgPrinter.Sprintf("Error streaming %v, the http server does not support streaming.", resource)))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		// Send the event logs until the client goes away.
+		for {
+			out, err := WaitForEventLogs(r.Context(), a.db, since, selectors, EVENTLOG_STREAM_KEEPALIVE, msgPrinter)
+			if err != nil {
+				glog.Errorf(apiLogString(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+				return
+			} else if r.Context().Err() != nil {
+				glog.V(5).Infof(apiLogString(fmt.Sprintf("Client closed the %v stream.", resource)))
+				return
+			}
+
+			if len(out) == 0 {
+				fmt.Fprint(w, ": keepalive\n\n")
+			}
+			for _, el := range out {
+				if data, err := json.Marshal(el); err != nil {
+					glog.Errorf(apiLogString(fmt.Sprintf("Error marshaling event log %v, error %v", el, err)))
+				} else {
+					fmt.Fprintf(w, "id: %v\nevent: eventlog\ndata: %s\n\n", el.Id, data)
+				}
+				if id, err := strconv.ParseUint(el.Id, 10, 64); err == nil && id > since {
+					since = id
+				}
+			}
+			flusher.Flush()
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (a *API) surface(w http.ResponseWriter, r *http.Request) {
 	resource := "eventlog/surface"
 	errorHandler := GetHTTPErrorHandler(w)
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/changes"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/imagefetch"
+	"github.com/open-horizon/anax/metrics"
+	"github.com/open-horizon/anax/persistence"
+	"net/http"
+)
+
+// The metrics served by the agent /metrics API.
+var agentMetrics = metrics.NewRegistry()
+
+func init() {
+	agentMetrics.Register(
+		exchange.ExchangeRequestDuration,
+		exchange.ExchangeRequestErrors,
+		changes.HeartbeatCounter,
+		changes.PollIntervalGauge,
+		imagefetch.ImageFetchDuration,
+		imagefetch.ImageFetchFailures,
+		persistence.EventLogCounter,
+	)
+}
+
+// Register the metrics that are computed from the agent's database when they are scraped.
+func (a *API) registerMetrics() {
+
+	// Errors are logged and the metric is left out of the response, so that one failure does not hide the other metrics.
+	collect := func(name string, f func() ([]metrics.Sample, error)) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			samples, err := f()
+			if err != nil {
+				glog.Errorf(apiLogString(fmt.Sprintf("unable to collect metric %v, error %v", name, err)))
+				return []metrics.Sample{}
+			}
+			return samples
+		}
+	}
+
+	agentMetrics.Register(
+		metrics.NewGaugeFunc("anax_agreements",
+			"The number of agreements that are not archived, by state.",
+			[]string{"state"},
+			collect("anax_agreements", func() ([]metrics.Sample, error) { return FindAgreementMetrics(a.db) })),
+		metrics.NewGaugeFunc("anax_service_containers",
+			"The number of containers of each active service, by container state.",
+			[]string{"service", "org", "version", "state"},
+			collect("anax_service_containers", func() ([]metrics.Sample, error) {
+				return FindServiceContainerMetrics(a.db, a.Config.Edge.DockerEndpoint)
+			})),
+		metrics.NewGaugeFunc("anax_service_restarts",
+			"The number of times each active service has been restarted in its current retry cycle.",
+			[]string{"service", "org", "version"},
+			collect("anax_service_restarts", func() ([]metrics.Sample, error) { return FindServiceRestartMetrics(a.db) })),
+	)
+}
+
+func (a *API) metrics(w http.ResponseWriter, r *http.Request) {
+	agentMetrics.ServeHTTP(w, r)
+}
@@ -13,8 +13,10 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangesync"
 	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/resource"
 	"github.com/open-horizon/anax/version"
 	"github.com/open-horizon/anax/worker"
 )
@@ -537,3 +539,89 @@ func (a *API) nodeuserinput(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+// The published diagnostic bundle, so that the org admin can find it in the management hub.
+type DiagnosticsObject struct {
+	Org        string `json:"org"`
+	ObjectType string `json:"objectType"`
+	ObjectID   string `json:"objectID"`
+	Size       int    `json:"size"`
+}
+
+func (a *API) nodediagnostics(w http.ResponseWriter, r *http.Request) {
+
+	diagResource := "node/diagnostics"
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET", "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, diagResource)))
+
+		// get message printer with the language passed in from the header
+		lan := r.Header.Get("Accept-Language")
+		if lan == "" {
+			lan = i18n.DEFAULT_LANGUAGE
+		}
+		msgPrinter := i18n.GetMessagePrinterWithLocale(lan)
+
+		if err := r.ParseForm(); err != nil {
+			errorHandler(NewAPIUserInputError(fmt.Sprintf("Error parsing the options %v. %v", r.Form, err), "options"))
+			return
+		}
+
+		logLines := int64(DIAGNOSTICS_LOG_LINES_DEFAULT)
+		if s := r.Form.Get(DIAGNOSTICS_LOG_LINES); s != "" {
+			if n, err := strconv.ParseInt(s, 10, 64); err != nil || n < 0 {
+				errorHandler(NewAPIUserInputError(fmt.Sprintf("%v must be a number of lines, it is %v", DIAGNOSTICS_LOG_LINES, s), DIAGNOSTICS_LOG_LINES))
+				return
+			} else {
+				logLines = n
+			}
+		}
+
+		// Publishing the bundle needs the node's org, so the node must be registered. The bundle itself can be
+		// collected from a node that is not registered yet, that is often when it is needed.
+		var pDevice *persistence.ExchangeDevice
+		if r.Method == "POST" {
+			var errWritten bool
+			if pDevice, errWritten = a.existingDeviceOrError(w); errWritten {
+				return
+			} else if !resource.IsESSRunning() {
+				errorHandler(NewAPIUserInputError("The diagnostic bundle cannot be published, the node's model management service is not running.", "publish"))
+				return
+			}
+		}
+
+		bundle, manifest, err := CollectNodeDiagnostics(r.Context(), a.db, a.pm, a.Config, logLines, msgPrinter)
+		if err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error creating %v, error %v", diagResource, err)))
+			return
+		}
+		name := diagnosticsDirName(manifest)
+
+		if r.Method == "GET" {
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%v.tar.gz", name))
+			w.Header().Set("Content-Length", strconv.Itoa(len(bundle)))
+			w.WriteHeader(http.StatusOK)
+			if _, err := w.Write(bundle); err != nil {
+				glog.Errorf(apiLogString(fmt.Sprintf("Error writing %v, error %v", diagResource, err)))
+			}
+			return
+		}
+
+		obj := DiagnosticsObject{Org: pDevice.Org, ObjectType: DIAGNOSTICS_OBJECT_TYPE, ObjectID: name, Size: len(bundle)}
+		description := fmt.Sprintf("Diagnostic bundle of node %v created %v", pDevice.Id, manifest.Created)
+		if err := resource.PublishObject(obj.Org, obj.ObjectType, obj.ObjectID, description, DIAGNOSTICS_OBJECT_EXPIRATION, bundle); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error publishing %v, error %v", diagResource, err)))
+			return
+		}
+		writeResponse(w, obj, http.StatusCreated)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
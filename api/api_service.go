@@ -12,6 +12,8 @@ import (
 	"github.com/open-horizon/anax/persistence"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (a *API) service(w http.ResponseWriter, r *http.Request) {
@@ -239,3 +241,120 @@ func (a *API) servicepolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// Returns the recent resource usage of the running service instances, sampled by the agent.
+func (a *API) servicestats(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/stats"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	_, errWritten := a.existingDeviceOrError(w)
+	if errWritten {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+
+		if err := r.ParseForm(); err != nil {
+			errorhandler(NewAPIUserInputError(fmt.Sprintf("Error parsing the selections %v. %v", r.Form, err), "selection"))
+			return
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v", r.Method, resource, r.Form)))
+
+		summaryOnly := false
+		if s := r.Form.Get(SERVICE_STATS_SUMMARY); s != "" {
+			if b, err := strconv.ParseBool(s); err != nil {
+				errorhandler(NewAPIUserInputError(fmt.Sprintf("%v must be true or false", SERVICE_STATS_SUMMARY), SERVICE_STATS_SUMMARY))
+				return
+			} else {
+				summaryOnly = b
+			}
+		}
+
+		if out, err := FindServiceStatsForOutput(a.db, r.Form.Get(SERVICE_STATS_URL), r.Form.Get(SERVICE_STATS_ORG), r.Form.Get(SERVICE_STATS_VERSION), summaryOnly); err != nil {
+			errorhandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+
+}
+
+// Returns the log of a container of a running service instance. On a device the log is read with the docker logs API,
+// so it works with any log driver that docker can read logs from. On a cluster the log is read from the operator's pod.
+// The secrets in the log are redacted.
+func (a *API) servicelog(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/log"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	pDevice, errWritten := a.existingDeviceOrError(w)
+	if errWritten {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+
+		if err := r.ParseForm(); err != nil {
+			errorhandler(NewAPIUserInputError(fmt.Sprintf("Error parsing the selections %v. %v", r.Form, err), "selection"))
+			return
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v with selection %v", r.Method, resource, r.Form)))
+
+		opts, err := GetServiceLogOptions(r.Form, time.Now())
+		if err != nil {
+			errorhandler(NewAPIUserInputError(err.Error(), "selection"))
+			return
+		}
+
+		nodeType := pDevice.GetNodeType()
+		errHandled, target := FindServiceLogTarget(errorhandler, a.db, nodeType, r.Form.Get(SERVICE_LOG_URL), r.Form.Get(SERVICE_LOG_ORG), r.Form.Get(SERVICE_LOG_VERSION), r.Form.Get(SERVICE_LOG_INSTANCE), r.Form.Get(SERVICE_LOG_CONTAINER))
+		if errHandled {
+			return
+		}
+
+		// The log is redacted like the logs in the node diagnostics, so it can be read by the same users as the rest of
+		// the node's state.
+		secretNames, err := serviceSecretNames(a.db)
+		if err != nil {
+			glog.Warningf(apiLogString(fmt.Sprintf("Unable to read the secret names of the services for %v, error %v", resource, err)))
+		}
+
+		fw := newFlushWriter(w)
+		rw := newRedactWriter(fw, secretNames)
+		if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+			err = WriteKubeServiceLog(r.Context(), target, opts, rw)
+		} else {
+			err = WriteDockerServiceLog(r.Context(), a.Config.Edge.DockerEndpoint, target, opts, rw)
+		}
+		if flushErr := rw.Flush(); err == nil {
+			err = flushErr
+		}
+
+		if err != nil && !fw.Written() {
+			errorhandler(NewSystemError(fmt.Sprintf("Error getting %v for %v, error %v", resource, target, err)))
+		} else if err != nil {
+			glog.Errorf(apiLogString(fmt.Sprintf("Error streaming %v for %v, error %v", resource, target, err)))
+		} else if !fw.Written() {
+			// The log is empty.
+			w.WriteHeader(http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+
+}
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"fmt"
+	"github.com/golang/glog"
 	"github.com/open-horizon/anax/apicommon"
+	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
 	"net/http"
 )
@@ -12,6 +15,19 @@ func (a *API) status(w http.ResponseWriter, r *http.Request) {
 
 		info := apicommon.NewInfo(a.GetHTTPFactory(), a.GetExchangeURL(), a.GetCSSURL(), a.GetExchangeId(), a.GetExchangeToken())
 
+		if gracePeriod := a.Config.Edge.OfflineGracePeriodS; gracePeriod > 0 {
+			info.Offline = &apicommon.OfflineStatus{GracePeriodS: gracePeriod}
+			if offline, err := persistence.FindOfflineState(a.db); err != nil {
+				glog.Errorf(apiLogString(fmt.Sprintf("unable to read offline state, error %v", err)))
+			} else if offline != nil {
+				info.Offline.Offline = offline.IsOffline()
+				info.Offline.OfflineSince = offline.OfflineSince
+				info.Offline.GraceExpired = offline.GraceExpired
+				info.Offline.StatusPending = offline.StatusPending
+				info.Offline.LastReconnect = offline.LastReconnect
+			}
+		}
+
 		writeResponse(w, info, http.StatusOK)
 	case "OPTIONS":
 		w.Header().Set("Allow", "GET, OPTIONS")
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
@@ -8,6 +9,8 @@ import (
 	"github.com/open-horizon/anax/persistence"
 	"golang.org/x/text/message"
 	"sort"
+	"strconv"
+	"time"
 )
 
 // This API returns the event logs saved on the db.
@@ -32,6 +35,82 @@ func FindEventLogsForOutput(db *bolt.DB, all_logs bool, selections map[string][]
 	}
 }
 
+// The query parameters of the /eventlog/stream API that are not event log selections.
+const (
+	EVENTLOG_STREAM_SINCE = "since" // only return event logs saved after the event log with this record id
+	EVENTLOG_STREAM_WAIT  = "wait"  // the number of seconds to wait for new event logs (long-poll)
+)
+
+// The longest time a long-poll request will wait for new event logs.
+const EVENTLOG_STREAM_MAX_WAIT = 300
+
+// Convert the selections of a streaming request into selectors. The stream specific query parameters are removed
+// from the selections and returned separately. If since is not in the selections, the record id of the most recent
+// event log is returned so that the stream starts with the event logs saved from now on.
+func GetEventLogStreamSelectors(db *bolt.DB, selections map[string][]string, msgPrinter *message.Printer) (map[string][]persistence.Selector, uint64, int, error) {
+
+	eventSelections := make(map[string][]string)
+	for k, v := range selections {
+		if k != EVENTLOG_STREAM_SINCE && k != EVENTLOG_STREAM_WAIT {
+			eventSelections[k] = v
+		}
+	}
+
+	s, err := persistence.ConvertToSelectors(eventSelections)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf(msgPrinter.Sprintf("Error converting the selections into Selectors: %v", err))
+	}
+
+	since := uint64(0)
+	if v, ok := selections[EVENTLOG_STREAM_SINCE]; ok && len(v) != 0 {
+		if since, err = strconv.ParseUint(v[0], 10, 64); err != nil {
+			return nil, 0, 0, fmt.Errorf(msgPrinter.Sprintf("The %v parameter must be an event log record id, error: %v", EVENTLOG_STREAM_SINCE, err))
+		}
+	} else if since, err = persistence.GetLastEventLogId(db); err != nil {
+		return nil, 0, 0, fmt.Errorf(msgPrinter.Sprintf("Unable to get the most recent event log record id, error: %v", err))
+	}
+
+	wait := 0
+	if v, ok := selections[EVENTLOG_STREAM_WAIT]; ok && len(v) != 0 {
+		if wait, err = strconv.Atoi(v[0]); err != nil || wait < 0 || wait > EVENTLOG_STREAM_MAX_WAIT {
+			return nil, 0, 0, fmt.Errorf(msgPrinter.Sprintf("The %v parameter must be a number of seconds between 0 and %v.", EVENTLOG_STREAM_WAIT, EVENTLOG_STREAM_MAX_WAIT))
+		}
+	}
+
+	return s, since, wait, nil
+}
+
+// This API waits for event logs that match the selectors and were saved after the event log with the given record id.
+// It returns as soon as there are matching event logs, or an empty list when the timeout expires or the context is
+// cancelled, for example because the client went away. The returned event logs are sorted by record id.
+func WaitForEventLogs(ctx context.Context, db *bolt.DB, after_id uint64, selectors map[string][]persistence.Selector, timeout time.Duration, msgPrinter *message.Printer) ([]persistence.EventLog, error) {
+
+	// Start watching before reading the db so that an event log saved in between is not missed.
+	watcher := persistence.WatchEventLogs()
+	defer persistence.UnwatchEventLogs(watcher)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		event_logs, err := eventlog.GetEventLogsAfter(db, false, after_id, selectors, msgPrinter)
+		if err != nil {
+			return nil, err
+		} else if len(event_logs) != 0 {
+			sort.Sort(EventLogByRecordId(event_logs))
+			return event_logs, nil
+		}
+
+		select {
+		case <-watcher:
+		case <-timer.C:
+			return event_logs, nil
+		case <-ctx.Done():
+			return event_logs, nil
+		}
+	}
+}
+
 func FindSurfaceLogsForOutput(db *bolt.DB, msgPrinter *message.Printer) ([]persistence.SurfaceError, error) {
 	outputLogs := make([]persistence.SurfaceError, 0)
 	surfaceLogs, err := persistence.FindSurfaceErrors(db)
@@ -3,13 +3,19 @@
 package api
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -115,3 +121,108 @@ func Test_FindEventLogsForOutput(t *testing.T) {
 	}
 
 }
+
+func Test_WaitForEventLogs(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	msgPrinter := i18n.GetMessagePrinterWithLocale("en")
+
+	logNodeEvent := func(severity string, message string) {
+		if err := eventlog.LogNodeEvent(db, severity, persistence.NewMessageMeta(message), persistence.EC_START_NODE_CONFIG_REG, "node1", "myorg", "", "configuring"); err != nil {
+			t.Errorf("error saving event log: %v", err)
+		}
+	}
+
+	logNodeEvent(persistence.SEVERITY_INFO, "event 1")
+	logNodeEvent(persistence.SEVERITY_ERROR, "event 2")
+
+	// Without since, the stream starts after the most recent event log.
+	selectors, since, wait, err := GetEventLogStreamSelectors(db, map[string][]string{"severity": {"error"}, "wait": {"1"}}, msgPrinter)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), since)
+	assert.Equal(t, 1, wait)
+	assert.Equal(t, 1, len(selectors), "the stream parameters are not selectors")
+
+	_, _, _, err = GetEventLogStreamSelectors(db, map[string][]string{"since": {"abc"}}, msgPrinter)
+	assert.NotNil(t, err)
+	_, _, _, err = GetEventLogStreamSelectors(db, map[string][]string{"wait": {"3600"}}, msgPrinter)
+	assert.NotNil(t, err)
+
+	// Nothing new, the wait times out.
+	if elogs, err := WaitForEventLogs(context.Background(), db, since, selectors, 100*time.Millisecond, msgPrinter); err != nil {
+		t.Errorf("error waiting for event logs: %v", err)
+	} else {
+		assert.Equal(t, 0, len(elogs))
+	}
+
+	// Only the new event log that matches the selectors is returned.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		logNodeEvent(persistence.SEVERITY_INFO, "event 3")
+		logNodeEvent(persistence.SEVERITY_ERROR, "event 4")
+	}()
+	if elogs, err := WaitForEventLogs(context.Background(), db, since, selectors, 10*time.Second, msgPrinter); err != nil {
+		t.Errorf("error waiting for event logs: %v", err)
+	} else if assert.Equal(t, 1, len(elogs)) {
+		assert.Equal(t, "4", elogs[0].Id)
+		assert.Equal(t, "event 4", elogs[0].Message)
+	}
+
+	// The older event logs are returned when since is set.
+	if elogs, err := WaitForEventLogs(context.Background(), db, 0, selectors, time.Second, msgPrinter); err != nil {
+		t.Errorf("error waiting for event logs: %v", err)
+	} else {
+		assert.Equal(t, 2, len(elogs))
+	}
+}
+
+func Test_eventlogstream(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	a := &API{db: db}
+	server := httptest.NewServer(http.HandlerFunc(a.eventlogstream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/eventlog/stream?message=~event")
+	if err != nil {
+		t.Fatalf("error opening event log stream: %v", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	eventlog.LogNodeEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("not selected"), persistence.EC_START_NODE_CONFIG_REG, "node1", "myorg", "", "configuring")
+	eventlog.LogNodeEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta("event 2"), persistence.EC_START_NODE_CONFIG_REG, "node1", "myorg", "", "configuring")
+
+	// Read the first event from the stream.
+	reader := bufio.NewReader(resp.Body)
+	lines := make([]string, 0)
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading event log stream: %v", err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	assert.Equal(t, "id: 2", lines[0])
+	assert.Equal(t, "event: eventlog", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "data: {") && strings.Contains(lines[2], "event 2"), "unexpected event data %v", lines[2])
+
+	// Selector errors are reported before the stream starts.
+	if resp, err := http.Get(server.URL + "/eventlog/stream?since=abc"); err != nil {
+		t.Errorf("error calling event log stream: %v", err)
+	} else {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
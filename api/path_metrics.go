@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/metrics"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+)
+
+// The states of an agreement that has not been archived.
+const (
+	AGREEMENT_STATE_PROPOSED    = "proposed"
+	AGREEMENT_STATE_ACCEPTED    = "accepted"
+	AGREEMENT_STATE_FINALIZED   = "finalized"
+	AGREEMENT_STATE_EXECUTING   = "executing"
+	AGREEMENT_STATE_TERMINATING = "terminating"
+)
+
+func agreementState(ag *persistence.EstablishedAgreement) string {
+	if ag.AgreementTerminatedTime != 0 {
+		return AGREEMENT_STATE_TERMINATING
+	} else if ag.AgreementExecutionStartTime != 0 {
+		return AGREEMENT_STATE_EXECUTING
+	} else if ag.AgreementFinalizedTime != 0 {
+		return AGREEMENT_STATE_FINALIZED
+	} else if ag.AgreementAcceptedTime != 0 {
+		return AGREEMENT_STATE_ACCEPTED
+	}
+	return AGREEMENT_STATE_PROPOSED
+}
+
+// Count the agreements that are not archived by state.
+func FindAgreementMetrics(db *bolt.DB) ([]metrics.Sample, error) {
+	agreements, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter()})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read agreements, error %v", err))
+	}
+
+	counts := make(map[string]int)
+	for ix := range agreements {
+		counts[agreementState(&agreements[ix])] += 1
+	}
+
+	samples := make([]metrics.Sample, 0, len(counts))
+	for state, count := range counts {
+		samples = append(samples, metrics.Sample{LabelValues: []string{state}, Value: float64(count)})
+	}
+	return samples, nil
+}
+
+// Count the containers of each active service by container state. Instances of the same service are added together.
+func FindServiceContainerMetrics(db *bolt.DB, dockerEndpoint string) ([]metrics.Sample, error) {
+	msinsts, err := persistence.GetAllMicroserviceInstances(db, false, true)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read service instances, error %v", err))
+	} else if len(msinsts) == 0 {
+		// Dont call docker when there are no services, the docker API might not be available yet.
+		return []metrics.Sample{}, nil
+	}
+
+	client, err := dockerclient.NewClient(dockerEndpoint)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create docker client from %v, error %v", dockerEndpoint, err))
+	}
+	containers, err := client.ListContainers(dockerclient.ListContainersOptions{All: true})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to list docker containers from %v, error %v", dockerEndpoint, err))
+	}
+
+	counts := make(map[[4]string]int)
+	for _, msinst := range msinsts {
+		if mi, ok := msinst.(*persistence.MicroserviceInstance); ok {
+			for _, c := range containers {
+				if agid, exists := c.Labels[container.LABEL_PREFIX+".agreement_id"]; exists && agid == mi.GetKey() {
+					counts[[4]string{mi.GetURL(), mi.GetOrg(), mi.GetVersion(), c.State}] += 1
+				}
+			}
+		}
+	}
+
+	samples := make([]metrics.Sample, 0, len(counts))
+	for key, count := range counts {
+		samples = append(samples, metrics.Sample{LabelValues: []string{key[0], key[1], key[2], key[3]}, Value: float64(count)})
+	}
+	return samples, nil
+}
+
+// Return the number of times each active service has been restarted in its current retry cycle. Instances of the
+// same service are added together.
+func FindServiceRestartMetrics(db *bolt.DB) ([]metrics.Sample, error) {
+	msinsts, err := persistence.GetAllMicroserviceInstances(db, false, true)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read service instances, error %v", err))
+	}
+
+	counts := make(map[[3]string]uint)
+	for _, msinst := range msinsts {
+		if mi, ok := msinst.(*persistence.MicroserviceInstance); ok {
+			// The original execution is counted as the first one.
+			restarts := uint(0)
+			if mi.GetCurrentRetryCount() > 1 {
+				restarts = mi.GetCurrentRetryCount() - 1
+			}
+			counts[[3]string{mi.GetURL(), mi.GetOrg(), mi.GetVersion()}] += restarts
+		}
+	}
+
+	samples := make([]metrics.Sample, 0, len(counts))
+	for key, count := range counts {
+		samples = append(samples, metrics.Sample{LabelValues: []string{key[0], key[1], key[2]}, Value: float64(count)})
+	}
+	return samples, nil
+}
@@ -0,0 +1,79 @@
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/metrics"
+	"github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+func sampleValues(samples []metrics.Sample) map[string]float64 {
+	values := make(map[string]float64)
+	for _, s := range samples {
+		key := ""
+		for _, l := range s.LabelValues {
+			key += l + "|"
+		}
+		values[key] = s.Value
+	}
+	return values
+}
+
+func Test_FindAgreementMetrics(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if samples, err := FindAgreementMetrics(db); err != nil {
+		t.Errorf("error finding agreement metrics: %v", err)
+	} else if len(samples) != 0 {
+		t.Errorf("expecting no samples, have %v", samples)
+	}
+
+	wi, _ := persistence.NewWorkloadInfo("url", "org", "version", "")
+	for _, id := range []string{"ag1", "ag2", "ag3", "ag4"} {
+		if _, err := persistence.NewEstablishedAgreement(db, "name1", id, "consumerId", "{}", "Basic", 1, []persistence.ServiceSpec{}, "signature", "address", "bcType", "bcName", "bcOrg", wi, 180); err != nil {
+			t.Errorf("error writing agreement %v: %v", id, err)
+		}
+	}
+	if _, err := persistence.AgreementStateAccepted(db, "ag2", "Basic"); err != nil {
+		t.Errorf("error accepting agreement: %v", err)
+	} else if _, err := persistence.AgreementStateExecutionStarted(db, "ag3", "Basic"); err != nil {
+		t.Errorf("error starting agreement: %v", err)
+	} else if _, err := persistence.ArchiveEstablishedAgreement(db, "ag4", "Basic"); err != nil {
+		t.Errorf("error archiving agreement: %v", err)
+	}
+
+	if samples, err := FindAgreementMetrics(db); err != nil {
+		t.Errorf("error finding agreement metrics: %v", err)
+	} else if values := sampleValues(samples); len(values) != 3 || values["proposed|"] != 1 || values["accepted|"] != 1 || values["executing|"] != 1 {
+		t.Errorf("unexpected agreement metrics %v", values)
+	}
+}
+
+func Test_FindServiceRestartMetrics(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if mi, err := persistence.NewMicroserviceInstance(db, "http://svc1", "myorg", "1.0.0", "msdef1", []persistence.ServiceInstancePathElement{}, false); err != nil {
+		t.Errorf("error creating service instance: %v", err)
+	} else if _, err := persistence.UpdateMSInstanceCurrentRetryCount(db, mi.GetKey(), 3); err != nil {
+		t.Errorf("error updating retry count: %v", err)
+	} else if _, err := persistence.NewMicroserviceInstance(db, "http://svc2", "myorg", "1.0.0", "msdef2", []persistence.ServiceInstancePathElement{}, false); err != nil {
+		t.Errorf("error creating service instance: %v", err)
+	}
+
+	if samples, err := FindServiceRestartMetrics(db); err != nil {
+		t.Errorf("error finding service metrics: %v", err)
+	} else if values := sampleValues(samples); len(values) != 2 || values["http://svc1|myorg|1.0.0|"] != 2 || values["http://svc2|myorg|1.0.0|"] != 0 {
+		t.Errorf("unexpected service restart metrics %v", values)
+	}
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/worker"
+	"golang.org/x/text/message"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The options for the node/diagnostics resource.
+const (
+	DIAGNOSTICS_LOG_LINES = "loglines" // The number of lines from the end of each service log to include
+)
+
+// The number of service log lines included in a diagnostic bundle when the request does not say.
+const DIAGNOSTICS_LOG_LINES_DEFAULT = 500
+
+// The MMS object type of the diagnostic bundles published by the agent.
+const DIAGNOSTICS_OBJECT_TYPE = "horizon_diagnostics"
+
+// How long a published diagnostic bundle is kept in the management hub.
+const DIAGNOSTICS_OBJECT_EXPIRATION = 7 * 24 * time.Hour
+
+// How long to wait for the log of one service container.
+const DIAGNOSTICS_LOG_TIMEOUT = 30 * time.Second
+
+// The value that replaces secrets in a diagnostic bundle.
+const REDACTED = "********"
+
+// The names of fields and environment variables whose values are redacted from a diagnostic bundle.
+var redactedNameRE = regexp.MustCompile(`(?i)(token|passw|secret|api[-_]?key|credential|auth|private|cert_key|signing_key)`)
+
+// An environment variable setting, NAME=value.
+var envSettingRE = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_.]*)=([^\s,;&"']*)`)
+
+// A setting in free text whose name looks like it holds a secret, NAME=value, name: value or "name": "value". The first
+// group is everything up to the value.
+var secretSettingRE = regexp.MustCompile(`(?i)([A-Za-z0-9_.-]*(?:token|passw|secret|api[-_]?key|credential|auth|private|cert_key|signing_key)[A-Za-z0-9_.-]*"?\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s,;&"']+)`)
+
+// A bearer token, as in an Authorization header.
+var bearerTokenRE = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+
+// One file in a diagnostic bundle.
+type diagnosticsFile struct {
+	Name    string
+	Content []byte
+}
+
+// The description of a diagnostic bundle, it is the first file in the bundle.
+type DiagnosticsManifest struct {
+	Created string   `json:"created"`
+	NodeId  string   `json:"node_id,omitempty"`
+	NodeOrg string   `json:"node_org,omitempty"`
+	Files   []string `json:"files"`
+	Errors  []string `json:"errors,omitempty"` // The parts of the node state that could not be collected
+}
+
+// Collect the state of the node into a gzipped tar file. Secrets and tokens are redacted. The parts of the node state
+// that cannot be collected are listed in the manifest, so that a partial bundle is still returned for a broken node.
+func CollectNodeDiagnostics(ctx context.Context, db *bolt.DB, pm *policy.PolicyManager, cfg *config.HorizonConfig, logLines int64, msgPrinter *message.Printer) ([]byte, *DiagnosticsManifest, error) {
+
+	manifest := &DiagnosticsManifest{Created: time.Now().UTC().Format(time.RFC3339), Files: []string{}}
+	files := make([]diagnosticsFile, 0)
+
+	secretNames, err := serviceSecretNames(db)
+	if err != nil {
+		manifest.Errors = append(manifest.Errors, fmt.Sprintf("unable to collect the service secrets: %v", err))
+	}
+
+	addJSON := func(name string, what string, obj interface{}, err error) {
+		if err != nil {
+			manifest.Errors = append(manifest.Errors, fmt.Sprintf("unable to collect %v: %v", what, err))
+		} else if content, err := redactedJSON(obj, secretNames); err != nil {
+			manifest.Errors = append(manifest.Errors, fmt.Sprintf("unable to serialize %v: %v", what, err))
+		} else {
+			files = append(files, diagnosticsFile{Name: name, Content: content})
+		}
+	}
+
+	device, err := FindHorizonDeviceForOutput(db)
+	addJSON("node.json", "the node", device, err)
+	nodeType := persistence.DEVICE_TYPE_DEVICE
+	if device != nil {
+		if device.Id != nil {
+			manifest.NodeId = *device.Id
+		}
+		if device.Org != nil {
+			manifest.NodeOrg = *device.Org
+		}
+		if device.NodeType != nil && *device.NodeType != "" {
+			nodeType = *device.NodeType
+		}
+	}
+
+	nodePolicy, err := FindNodePolicyForOutput(db)
+	addJSON("node_policy.json", "the node policy", nodePolicy, err)
+
+	userInput, err := FindNodeUserInputForOutput(db)
+	addJSON("node_userinput.json", "the node user input", userInput, err)
+
+	agreements, err := FindAgreementsForOutput(db)
+	addJSON("agreements.json", "the agreements", agreements, err)
+
+	services, err := FindServicesForOutput(pm, db, cfg)
+	addJSON("services.json", "the services", services, err)
+
+	eventLogs, err := FindEventLogsForOutput(db, false, map[string][]string{}, msgPrinter)
+	addJSON("eventlogs.json", "the event logs", eventLogs, err)
+
+	surfaceErrors, err := persistence.FindSurfaceErrors(db)
+	addJSON("surface_errors.json", "the surfaced errors", surfaceErrors, err)
+
+	addJSON("workers.json", "the worker status", worker.GetWorkerStatusManager(), nil)
+
+	// The containers and logs of the services.
+	if nodeType == persistence.DEVICE_TYPE_DEVICE && cfg.Edge.DockerEndpoint != "" {
+		cFiles, cErrs := collectContainerInspects(cfg.Edge.DockerEndpoint, secretNames)
+		files = append(files, cFiles...)
+		manifest.Errors = append(manifest.Errors, cErrs...)
+	}
+	lFiles, lErrs := collectServiceLogs(ctx, db, nodeType, cfg.Edge.DockerEndpoint, logLines, secretNames)
+	files = append(files, lFiles...)
+	manifest.Errors = append(manifest.Errors, lErrs...)
+
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, f.Name)
+	}
+	manifestFile := diagnosticsFile{Name: "manifest.json"}
+	if manifestFile.Content, err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("unable to serialize the diagnostics manifest, error %v", err))
+	}
+
+	bundle, err := makeDiagnosticsTarball(diagnosticsDirName(manifest), append([]diagnosticsFile{manifestFile}, files...))
+	return bundle, manifest, err
+}
+
+// The name of the top level directory in a bundle, it is also used to name the published object.
+func diagnosticsDirName(manifest *DiagnosticsManifest) string {
+	name := "horizon-diagnostics"
+	if manifest.NodeId != "" {
+		name += "-" + manifest.NodeId
+	}
+	if t, err := time.Parse(time.RFC3339, manifest.Created); err == nil {
+		name += "-" + t.Format("20060102T150405Z")
+	}
+	return name
+}
+
+// Get the docker inspect output of the containers started by the agent.
+func collectContainerInspects(dockerEndpoint string, secretNames map[string]bool) ([]diagnosticsFile, []string) {
+	files := make([]diagnosticsFile, 0)
+	errs := make([]string, 0)
+
+	client, err := docker.NewClient(dockerEndpoint)
+	if err != nil {
+		return files, append(errs, fmt.Sprintf("unable to create docker client: %v", err))
+	}
+
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return files, append(errs, fmt.Sprintf("unable to list containers: %v", err))
+	}
+
+	for _, c := range containers {
+		if !hasAgentLabel(c.Labels) {
+			continue
+		}
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if details, err := client.InspectContainerWithOptions(docker.InspectContainerOptions{ID: c.ID}); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to inspect container %v: %v", name, err))
+		} else if content, err := redactedJSON(details, secretNames); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to serialize container %v: %v", name, err))
+		} else {
+			files = append(files, diagnosticsFile{Name: "containers/" + name + ".json", Content: content})
+		}
+	}
+	return files, errs
+}
+
+func hasAgentLabel(labels map[string]string) bool {
+	for l := range labels {
+		if strings.HasPrefix(l, container.LABEL_PREFIX+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the end of the log of each container of each running service instance, with the secret settings replaced.
+func collectServiceLogs(ctx context.Context, db *bolt.DB, nodeType string, dockerEndpoint string, logLines int64, secretNames map[string]bool) ([]diagnosticsFile, []string) {
+	files := make([]diagnosticsFile, 0)
+	errs := make([]string, 0)
+
+	msdefs, err := persistence.FindMicroserviceDefs(db, []persistence.MSFilter{persistence.UnarchivedMSFilter()})
+	if err != nil {
+		return files, append(errs, fmt.Sprintf("unable to collect the service logs: %v", err))
+	}
+
+	opts := &ServiceLogOptions{Tail: logLines, Timestamps: true}
+	for _, msdef := range msdefs {
+		msinsts, err := persistence.GetAllMicroserviceInstancesWithDefId(db, msdef.Id, false, false)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("unable to collect the logs of service %v: %v", msdef.SpecRef, err))
+			continue
+		}
+
+		for _, msi := range msinsts {
+			if msi.GetCleanupStartTime() != 0 {
+				continue
+			}
+
+			targets := make([]*ServiceLogTarget, 0)
+			base := ServiceLogTarget{InstanceKey: msi.GetKey(), ServiceURL: msdef.SpecRef, Org: msdef.Org, Version: msdef.Version, ClusterDeployment: msdef.ClusterDeployment}
+			base.Deployment, _ = msdef.GetDeployment()
+			if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+				if base.ClusterDeployment != "" {
+					targets = append(targets, &base)
+				}
+			} else if deployment, err := containermessage.GetNativeDeployment(base.Deployment); err == nil && deployment != nil {
+				names := make([]string, 0)
+				for name := range deployment.Services {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					t := base
+					t.Container = name
+					targets = append(targets, &t)
+				}
+			}
+
+			for _, t := range targets {
+				buf := new(bytes.Buffer)
+				logCtx, cancel := context.WithTimeout(ctx, DIAGNOSTICS_LOG_TIMEOUT)
+				if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+					err = WriteKubeServiceLog(logCtx, t, opts, buf)
+				} else {
+					err = WriteDockerServiceLog(logCtx, dockerEndpoint, t, opts, buf)
+				}
+				cancel()
+
+				name := t.InstanceKey
+				if t.Container != "" {
+					name = serviceContainerName(t.InstanceKey, t.Container)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("unable to collect the log of %v: %v", name, err))
+				} else {
+					files = append(files, diagnosticsFile{Name: "logs/" + name + ".log", Content: []byte(redactText(buf.String(), secretNames))})
+				}
+			}
+		}
+	}
+	return files, errs
+}
+
+// Serialize an object to indented JSON with the values of the secret fields replaced.
+func redactedJSON(obj interface{}, secretNames map[string]bool) ([]byte, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redact(generic, secretNames), "", "  ")
+}
+
+// Replace the string values of fields whose names look like they hold secrets, the values of the name/value pairs of
+// secret inputs and the secret settings in every string.
+func redact(obj interface{}, secretNames map[string]bool) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		secretEntry := false
+		if isNameValueEntry(v) {
+			name := v["name"].(string)
+			secretEntry = redactedNameRE.MatchString(name) || secretNames[name]
+		}
+		for key, value := range v {
+			if _, ok := value.(string); ok && redactedNameRE.MatchString(key) {
+				v[key] = REDACTED
+			} else if secretEntry && (key == "value" || key == "defaultValue") {
+				v[key] = REDACTED
+			} else {
+				v[key] = redact(value, secretNames)
+			}
+		}
+		return v
+	case []interface{}:
+		for ix, value := range v {
+			v[ix] = redact(value, secretNames)
+		}
+		return v
+	case string:
+		return redactText(v, secretNames)
+	default:
+		return v
+	}
+}
+
+// Replace the values of the settings that look like secrets in free text, such as a log line, an environment variable
+// or a command line. Bearer tokens, NAME=value, name: value and "name": "value" settings are replaced when the name looks
+// like it holds a secret, and NAME=value settings are replaced when the name is a secret input.
+func redactText(text string, secretNames map[string]bool) string {
+	text = bearerTokenRE.ReplaceAllString(text, "${1}"+REDACTED)
+	text = secretSettingRE.ReplaceAllString(text, "${1}"+REDACTED)
+	if len(secretNames) == 0 {
+		return text
+	}
+	return envSettingRE.ReplaceAllStringFunc(text, func(setting string) string {
+		if m := envSettingRE.FindStringSubmatch(setting); secretNames[m[1]] {
+			return m[1] + "=" + REDACTED
+		}
+		return setting
+	})
+}
+
+// Returns true for the name/value pairs used by user input and properties, and for the user inputs of a service
+// definition.
+func isNameValueEntry(m map[string]interface{}) bool {
+	_, hasName := m["name"].(string)
+	_, hasValue := m["value"]
+	_, hasDefault := m["defaultValue"]
+	return hasName && (hasValue || hasDefault)
+}
+
+// The names of the secrets in the deployments of the services on the node. The user inputs and environment variables
+// with these names hold secret values, whatever their names look like.
+func serviceSecretNames(db *bolt.DB) (map[string]bool, error) {
+	names := make(map[string]bool)
+
+	msdefs, err := persistence.FindMicroserviceDefs(db, []persistence.MSFilter{persistence.UnarchivedMSFilter()})
+	if err != nil {
+		return names, err
+	}
+
+	for _, msdef := range msdefs {
+		depStr, _ := msdef.GetDeployment()
+		if deployment, err := containermessage.GetNativeDeployment(depStr); err == nil && deployment != nil {
+			for _, svc := range deployment.Services {
+				if svc == nil {
+					continue
+				}
+				for name := range svc.Secrets {
+					names[name] = true
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// Write the files into a gzipped tar file, under the given directory.
+func makeDiagnosticsTarball(dir string, files []diagnosticsFile) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{Name: dir + "/" + f.Name, Mode: 0600, Size: int64(len(f.Content)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to add %v to the diagnostic bundle, error %v", f.Name, err))
+		} else if _, err := tw.Write(f.Content); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to add %v to the diagnostic bundle, error %v", f.Name, err))
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to close the diagnostic bundle, error %v", err))
+	} else if err := gzw.Close(); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to compress the diagnostic bundle, error %v", err))
+	}
+
+	glog.V(3).Infof(apiLogString(fmt.Sprintf("created diagnostic bundle %v with %v files, %v bytes", dir, len(files), buf.Len())))
+	return buf.Bytes(), nil
+}
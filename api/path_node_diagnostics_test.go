@@ -0,0 +1,133 @@
+// +build unit
+
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/policy"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_redactedJSON(t *testing.T) {
+	obj := map[string]interface{}{
+		"id":    "node1",
+		"token": "abc123",
+		"config": map[string]interface{}{
+			"Env":            []string{"HZN_ORGID=myorg", "HZN_EXCHANGE_USER_AUTH=me:pw", "MY_PASSWORD=xyz", "PLAIN=value", "DB_CONN=conn1"},
+			"Cmd":            []string{"/bin/sh", "-c", "start --api-key=k1 DB_CONN=conn2"},
+			"ExchangeAPIKey": "key",
+		},
+		"inputs": []map[string]interface{}{
+			{"name": "db_password", "value": "s3cret"},
+			{"name": "port", "value": 8080},
+			{"name": "DB_CONN", "value": "conn3"},
+		},
+		"userInputs": []map[string]interface{}{
+			{"name": "DB_CONN", "label": "connection", "type": "string", "defaultValue": "conn4"},
+		},
+		"token_valid": true,
+	}
+
+	content, err := redactedJSON(obj, map[string]bool{"DB_CONN": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := string(content)
+
+	for _, secret := range []string{"abc123", "me:pw", "xyz", "s3cret", "\"key\"", "k1", "conn1", "conn2", "conn3", "conn4"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %v was not redacted from %v", secret, out)
+		}
+	}
+	for _, kept := range []string{"node1", "HZN_ORGID=myorg", "PLAIN=value", "HZN_EXCHANGE_USER_AUTH=" + REDACTED, "8080", "\"token_valid\": true"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %v in %v", kept, out)
+		}
+	}
+}
+
+func Test_redactText(t *testing.T) {
+	secretNames := map[string]bool{"DB_CONN": true}
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"2026-10-17T10:00:00Z connected to db", "2026-10-17T10:00:00Z connected to db"},
+		{"login with password=hunter2 ok", "login with password=" + REDACTED + " ok"},
+		{"config {\"apiToken\": \"abc\", \"port\": 80}", "config {\"apiToken\": " + REDACTED + ", \"port\": 80}"},
+		{"Authorization: Bearer abc.def", "Authorization: " + REDACTED + " " + REDACTED},
+		{"GET /data?access_token=abc&x=1", "GET /data?access_token=" + REDACTED + "&x=1"},
+		{"using DB_CONN=postgres://u@h and MODE=fast", "using DB_CONN=" + REDACTED + " and MODE=fast"},
+	}
+	for _, test := range tests {
+		if out := redactText(test.in, secretNames); out != test.out {
+			t.Errorf("expected %v, got %v", test.out, out)
+		}
+	}
+}
+
+func Test_CollectNodeDiagnostics(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanTestDir(dir)
+
+	cfg := getBasicConfig()
+	cfg.Edge.DockerEndpoint = ""
+
+	bundle, manifest, err := CollectNodeDiagnostics(context.Background(), db, policy.PolicyManager_Factory(true, true), cfg, 10, i18n.GetMessagePrinter())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Read the bundle back, the manifest comes first and lists the other files.
+	gzr, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("the bundle is not gzipped: %v", err)
+	}
+	tr := tar.NewReader(gzr)
+	names := make([]string, 0)
+	var readManifest DiagnosticsManifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("the bundle is not a tar file: %v", err)
+		}
+		names = append(names, hdr.Name)
+		if strings.HasSuffix(hdr.Name, "/manifest.json") {
+			if err := json.NewDecoder(tr).Decode(&readManifest); err != nil {
+				t.Errorf("unable to read the manifest: %v", err)
+			}
+		}
+	}
+
+	if len(names) != len(manifest.Files)+1 {
+		t.Errorf("expected %v files in the bundle, got %v", len(manifest.Files)+1, names)
+	} else if names[0] != diagnosticsDirName(manifest)+"/manifest.json" {
+		t.Errorf("expected the manifest first, got %v", names[0])
+	}
+	for _, f := range []string{"node.json", "agreements.json", "eventlogs.json", "workers.json"} {
+		found := false
+		for _, n := range names {
+			found = found || strings.HasSuffix(n, "/"+f)
+		}
+		if !found {
+			t.Errorf("expected %v in the bundle, got %v", f, names)
+		}
+	}
+	if readManifest.Created != manifest.Created || len(readManifest.Files) != len(manifest.Files) {
+		t.Errorf("the manifest in the bundle %v does not match %v", readManifest, manifest)
+	}
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/kube_operator"
+	"github.com/open-horizon/anax/persistence"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The selections and options for the service/log resource.
+const (
+	SERVICE_LOG_URL        = "url"
+	SERVICE_LOG_ORG        = "org"
+	SERVICE_LOG_VERSION    = "version"
+	SERVICE_LOG_INSTANCE   = "instance"
+	SERVICE_LOG_CONTAINER  = "container"
+	SERVICE_LOG_TAIL       = "tail"
+	SERVICE_LOG_SINCE      = "since"
+	SERVICE_LOG_FOLLOW     = "follow"
+	SERVICE_LOG_TIMESTAMPS = "timestamps"
+)
+
+// How to read the logs of a service container.
+type ServiceLogOptions struct {
+	Tail       int64 // The number of lines from the end of the log, -1 is all of them
+	Since      int64 // Only the lines written at or after this time (in seconds), 0 is all of them
+	Follow     bool  // Keep streaming new lines until the client goes away
+	Timestamps bool  // Prefix each line with the time it was written
+}
+
+// The service instance and container whose logs are read.
+type ServiceLogTarget struct {
+	InstanceKey       string
+	ServiceURL        string
+	Org               string
+	Version           string
+	Container         string // The name of the container in the service's deployment, empty for a cluster service with one container
+	Deployment        string
+	ClusterDeployment string
+}
+
+func (t ServiceLogTarget) String() string {
+	return fmt.Sprintf("InstanceKey: %v, ServiceURL: %v, Org: %v, Version: %v, Container: %v", t.InstanceKey, t.ServiceURL, t.Org, t.Version, t.Container)
+}
+
+// Parse the log options from the request. The since option is a unix time in seconds, an RFC3339 time, or a
+// duration (like 10m) before now.
+func GetServiceLogOptions(form url.Values, now time.Time) (*ServiceLogOptions, error) {
+	opts := &ServiceLogOptions{Tail: -1}
+
+	if tail := form.Get(SERVICE_LOG_TAIL); tail != "" && tail != "all" {
+		if n, err := strconv.ParseInt(tail, 10, 64); err != nil || n < 0 {
+			return nil, errors.New(fmt.Sprintf("%v must be a number of lines or all, it is %v", SERVICE_LOG_TAIL, tail))
+		} else {
+			opts.Tail = n
+		}
+	}
+
+	if since := form.Get(SERVICE_LOG_SINCE); since != "" {
+		if secs, err := strconv.ParseInt(since, 10, 64); err == nil && secs >= 0 {
+			opts.Since = secs
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			opts.Since = t.Unix()
+		} else if d, err := time.ParseDuration(since); err == nil && d >= 0 {
+			opts.Since = now.Add(-d).Unix()
+		} else {
+			return nil, errors.New(fmt.Sprintf("%v must be a unix time in seconds, an RFC3339 time or a duration, it is %v", SERVICE_LOG_SINCE, since))
+		}
+	}
+
+	for _, b := range []struct {
+		name  string
+		value *bool
+	}{{SERVICE_LOG_FOLLOW, &opts.Follow}, {SERVICE_LOG_TIMESTAMPS, &opts.Timestamps}} {
+		if s := form.Get(b.name); s != "" {
+			if v, err := strconv.ParseBool(s); err != nil {
+				return nil, errors.New(fmt.Sprintf("%v must be true or false, it is %v", b.name, s))
+			} else {
+				*b.value = v
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// Find the running service instance and the container whose logs are wanted. The service is selected by url, and
+// optionally org, version and instance key. When several instances match, top level services come first. The
+// container can be omitted when the service has only one.
+func FindServiceLogTarget(errorhandler ErrorHandler, db *bolt.DB, nodeType string, serviceURL string, org string, version string, instance string, container string) (bool, *ServiceLogTarget) {
+
+	if serviceURL == "" && instance == "" {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("either %v or %v must be specified", SERVICE_LOG_URL, SERVICE_LOG_INSTANCE), SERVICE_LOG_URL)), nil
+	}
+
+	msdefs, err := persistence.FindMicroserviceDefs(db, []persistence.MSFilter{persistence.UnarchivedMSFilter()})
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Error retrieving service definitions from the database, error %v", err))), nil
+	}
+
+	type candidate struct {
+		target   ServiceLogTarget
+		topLevel bool
+	}
+	candidates := make([]candidate, 0)
+	for _, msdef := range msdefs {
+		if (serviceURL != "" && msdef.SpecRef != serviceURL) || (org != "" && msdef.Org != org) || (version != "" && msdef.Version != version) {
+			continue
+		}
+
+		msinsts, err := persistence.GetAllMicroserviceInstancesWithDefId(db, msdef.Id, false, false)
+		if err != nil {
+			return errorhandler(NewSystemError(fmt.Sprintf("Error retrieving the instances of service %v from the database, error %v", msdef.SpecRef, err))), nil
+		}
+		for _, msi := range msinsts {
+			if msi.GetCleanupStartTime() != 0 || (instance != "" && msi.GetKey() != instance) {
+				continue
+			}
+			t := ServiceLogTarget{InstanceKey: msi.GetKey(), ServiceURL: msdef.SpecRef, Org: msdef.Org, Version: msdef.Version, ClusterDeployment: msdef.ClusterDeployment}
+			t.Deployment, _ = msdef.GetDeployment()
+			candidates = append(candidates, candidate{target: t, topLevel: msi.IsTopLevelService()})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return errorhandler(NewNotFoundError(fmt.Sprintf("service %v is not running on the node", serviceLogSelection(serviceURL, org, version, instance)), SERVICE_LOG_URL)), nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].topLevel && !candidates[j].topLevel })
+	target := candidates[0].target
+
+	if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+		if target.ClusterDeployment == "" {
+			return errorhandler(NewAPIUserInputError(fmt.Sprintf("service %v does not have a cluster deployment", target.ServiceURL), SERVICE_LOG_URL)), nil
+		}
+		target.Container = container
+		return false, &target
+	}
+
+	deployment, err := containermessage.GetNativeDeployment(target.Deployment)
+	if err != nil || deployment == nil || len(deployment.Services) == 0 {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("the logs of service %v cannot be displayed, it does not run any containers", target.ServiceURL), SERVICE_LOG_URL)), nil
+	}
+
+	names := make([]string, 0, len(deployment.Services))
+	for name := range deployment.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if container == "" && len(names) == 1 {
+		target.Container = names[0]
+	} else if container == "" {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("service %v has more than one container, specify one of %v", target.ServiceURL, strings.Join(names, ", ")), SERVICE_LOG_CONTAINER)), nil
+	} else if _, ok := deployment.Services[container]; !ok {
+		return errorhandler(NewNotFoundError(fmt.Sprintf("container %v is not part of service %v, the containers are %v", container, target.ServiceURL, strings.Join(names, ", ")), SERVICE_LOG_CONTAINER)), nil
+	} else {
+		target.Container = container
+	}
+	return false, &target
+}
+
+func serviceLogSelection(serviceURL string, org string, version string, instance string) string {
+	s := serviceURL
+	if org != "" {
+		s = org + "/" + s
+	}
+	if version != "" {
+		s += " version " + version
+	}
+	if instance != "" {
+		s += " instance " + instance
+	}
+	return strings.TrimSpace(s)
+}
+
+// The name of the docker container of a service instance, the same name that the container worker gives it.
+func serviceContainerName(instanceKey string, container string) string {
+	return instanceKey + "-" + container
+}
+
+// Write the docker logs of the target's container to the writer. The stdout and stderr lines are merged.
+func WriteDockerServiceLog(ctx context.Context, dockerEndpoint string, target *ServiceLogTarget, opts *ServiceLogOptions, w io.Writer) error {
+	client, err := docker.NewClient(dockerEndpoint)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to create docker client, error %v", err))
+	}
+	return WriteDockerClientServiceLog(ctx, client, target, opts, w)
+}
+
+// Write the docker logs of the target's container to the writer, using a docker client that the caller already has.
+func WriteDockerClientServiceLog(ctx context.Context, client *docker.Client, target *ServiceLogTarget, opts *ServiceLogOptions, w io.Writer) error {
+	name := serviceContainerName(target.InstanceKey, target.Container)
+	c, err := client.InspectContainerWithOptions(docker.InspectContainerOptions{ID: name, Context: ctx})
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to find container %v, error %v", name, err))
+	}
+
+	logsOpts := docker.LogsOptions{
+		Context:      ctx,
+		Container:    c.ID,
+		OutputStream: w,
+		ErrorStream:  w,
+		Stdout:       true,
+		Stderr:       true,
+		Since:        opts.Since,
+		Follow:       opts.Follow,
+		Timestamps:   opts.Timestamps,
+		RawTerminal:  c.Config != nil && c.Config.Tty,
+	}
+	if opts.Tail >= 0 {
+		logsOpts.Tail = strconv.FormatInt(opts.Tail, 10)
+	}
+
+	if err := client.Logs(logsOpts); err != nil && ctx.Err() == nil {
+		return errors.New(fmt.Sprintf("unable to get the logs of container %v, error %v", name, err))
+	}
+	return nil
+}
+
+// Write the pod logs of the target's cluster service to the writer.
+func WriteKubeServiceLog(ctx context.Context, target *ServiceLogTarget, opts *ServiceLogOptions, w io.Writer) error {
+	kd, err := persistence.GetKubeDeployment(target.ClusterDeployment)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read the cluster deployment of service %v, error %v", target.ServiceURL, err))
+	}
+
+	kc, err := kube_operator.NewKubeClient()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to create kubernetes client, error %v", err))
+	}
+
+	podOpts := kube_operator.NewPodLogOptions(target.Container, opts.Tail, opts.Since, opts.Follow, opts.Timestamps)
+	stream, err := kc.PodLogs(ctx, kd.OperatorYamlArchive, target.InstanceKey, podOpts)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to get the logs of service %v, error %v", target.ServiceURL, err))
+	}
+	defer stream.Close()
+
+	if _, err := io.Copy(w, stream); err != nil && ctx.Err() == nil {
+		return errors.New(fmt.Sprintf("unable to read the logs of service %v, error %v", target.ServiceURL, err))
+	}
+	return nil
+}
+
+// A writer that removes the secrets from the lines of a service log before they are written, the same way that the
+// logs in the node diagnostics are redacted. A line is held until its end is written, so call Flush to write the last
+// line of a log that does not end with a new line.
+type redactWriter struct {
+	lock        sync.Mutex
+	w           io.Writer
+	secretNames map[string]bool
+	partial     []byte
+}
+
+func newRedactWriter(w io.Writer, secretNames map[string]bool) *redactWriter {
+	return &redactWriter{w: w, secretNames: secretNames}
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	rw.partial = append(rw.partial, p...)
+	if ix := bytes.LastIndexByte(rw.partial, '\n'); ix >= 0 {
+		lines := string(rw.partial[:ix+1])
+		rw.partial = append([]byte{}, rw.partial[ix+1:]...)
+		if _, err := io.WriteString(rw.w, redactText(lines, rw.secretNames)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (rw *redactWriter) Flush() error {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	if len(rw.partial) == 0 {
+		return nil
+	}
+	line := string(rw.partial)
+	rw.partial = nil
+	_, err := io.WriteString(rw.w, redactText(line, rw.secretNames))
+	return err
+}
+
+// A writer that sends each write to the client right away, so that followed logs are not held in the server's buffer.
+// The response header is written with the first log line, so that an error found before then can still be returned
+// as an error response. The writes are serialized because stdout and stderr can be written from different go routines.
+type flushWriter struct {
+	lock    sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	written bool
+}
+
+func newFlushWriter(w http.ResponseWriter) *flushWriter {
+	fw := &flushWriter{w: w}
+	if f, ok := w.(http.Flusher); ok {
+		fw.flusher = f
+	}
+	return fw
+}
+
+// Returns true once the response has been started.
+func (fw *flushWriter) Written() bool {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	return fw.written
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	if !fw.written {
+		fw.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fw.w.WriteHeader(http.StatusOK)
+		fw.written = true
+	}
+	n, err := fw.w.Write(p)
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,123 @@
+// +build unit
+
+package api
+
+import (
+	"bytes"
+	"github.com/open-horizon/anax/persistence"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_GetServiceLogOptions(t *testing.T) {
+	now := time.Unix(10000, 0)
+
+	if opts, err := GetServiceLogOptions(url.Values{}, now); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if opts.Tail != -1 || opts.Since != 0 || opts.Follow || opts.Timestamps {
+		t.Errorf("unexpected default options %+v", opts)
+	}
+
+	form := url.Values{SERVICE_LOG_TAIL: {"20"}, SERVICE_LOG_SINCE: {"10m"}, SERVICE_LOG_FOLLOW: {"true"}, SERVICE_LOG_TIMESTAMPS: {"1"}}
+	if opts, err := GetServiceLogOptions(form, now); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if opts.Tail != 20 || opts.Since != 9400 || !opts.Follow || !opts.Timestamps {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	for _, since := range []string{"5000", "1970-01-01T01:23:20Z"} {
+		if opts, err := GetServiceLogOptions(url.Values{SERVICE_LOG_SINCE: {since}}, now); err != nil {
+			t.Errorf("unexpected error for since %v: %v", since, err)
+		} else if opts.Since != 5000 {
+			t.Errorf("expected since %v to be 5000, got %v", since, opts.Since)
+		}
+	}
+
+	for _, bad := range []url.Values{{SERVICE_LOG_TAIL: {"-1"}}, {SERVICE_LOG_SINCE: {"yesterday"}}, {SERVICE_LOG_FOLLOW: {"maybe"}}} {
+		if _, err := GetServiceLogOptions(bad, now); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+func Test_FindServiceLogTarget(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	webDef := &persistence.MicroserviceDefinition{SpecRef: "my.com.web", Org: "myorg", Version: "1.0.0", Deployment: `{"services":{"web":{"image":"web:1.0"}}}`}
+	dbDef := &persistence.MicroserviceDefinition{SpecRef: "my.com.db", Org: "myorg", Version: "1.0.0", Deployment: `{"services":{"db":{"image":"db:1.0"},"cache":{"image":"cache:1.0"}}}`}
+	for _, msdef := range []*persistence.MicroserviceDefinition{webDef, dbDef} {
+		if err := persistence.SaveOrUpdateMicroserviceDef(db, msdef); err != nil {
+			t.Fatalf("error saving service definition: %v", err)
+		}
+	}
+	web, err := persistence.NewMicroserviceInstance(db, "my.com.web", "myorg", "1.0.0", webDef.Id, []persistence.ServiceInstancePathElement{}, true)
+	if err != nil {
+		t.Fatalf("error creating service instance: %v", err)
+	} else if _, err := persistence.NewMicroserviceInstance(db, "my.com.db", "myorg", "1.0.0", dbDef.Id, []persistence.ServiceInstancePathElement{}, false); err != nil {
+		t.Fatalf("error creating service instance: %v", err)
+	}
+
+	var handledErr error
+	errorhandler := GetPassThroughErrorHandler(&handledErr)
+
+	// The only container of the service is used.
+	if errHandled, target := FindServiceLogTarget(errorhandler, db, persistence.DEVICE_TYPE_DEVICE, "my.com.web", "", "", "", ""); errHandled {
+		t.Errorf("unexpected error: %v", handledErr)
+	} else if target.InstanceKey != web.GetKey() || target.Container != "web" {
+		t.Errorf("unexpected target %v", target)
+	} else if serviceContainerName(target.InstanceKey, target.Container) != web.GetKey()+"-web" {
+		t.Errorf("unexpected container name %v", serviceContainerName(target.InstanceKey, target.Container))
+	}
+
+	// A service with several containers needs the container name.
+	if errHandled, _ := FindServiceLogTarget(errorhandler, db, persistence.DEVICE_TYPE_DEVICE, "my.com.db", "myorg", "", "", ""); !errHandled {
+		t.Errorf("expected an error for a service with 2 containers")
+	} else if _, ok := handledErr.(*APIUserInputError); !ok {
+		t.Errorf("expected an input error, got %v", handledErr)
+	}
+	if errHandled, target := FindServiceLogTarget(errorhandler, db, persistence.DEVICE_TYPE_DEVICE, "my.com.db", "myorg", "1.0.0", "", "cache"); errHandled {
+		t.Errorf("unexpected error: %v", handledErr)
+	} else if target.Container != "cache" {
+		t.Errorf("unexpected target %v", target)
+	}
+
+	// Services and containers that do not exist are not found.
+	for _, sel := range [][]string{{"my.com.other", ""}, {"my.com.db", "nosuch"}} {
+		if errHandled, _ := FindServiceLogTarget(errorhandler, db, persistence.DEVICE_TYPE_DEVICE, sel[0], "", "", "", sel[1]); !errHandled {
+			t.Errorf("expected an error for %v", sel)
+		} else if _, ok := handledErr.(*NotFoundError); !ok {
+			t.Errorf("expected a not found error for %v, got %v", sel, handledErr)
+		}
+	}
+
+	// A cluster node needs a cluster deployment.
+	if errHandled, _ := FindServiceLogTarget(errorhandler, db, persistence.DEVICE_TYPE_CLUSTER, "my.com.web", "", "", "", ""); !errHandled {
+		t.Errorf("expected an error for a service without a cluster deployment")
+	}
+}
+
+func Test_redactWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rw := newRedactWriter(buf, map[string]bool{"DB_CONN": true})
+
+	// A secret split across writes is redacted once its line is complete.
+	for _, chunk := range []string{"starting\nlogin with pass", "word=hunter2 ok\nusing DB_CONN=", "postgres://u@h"} {
+		if n, err := rw.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Errorf("unexpected write result %v %v", n, err)
+		}
+	}
+	if buf.String() != "starting\nlogin with password="+REDACTED+" ok\n" {
+		t.Errorf("unexpected log before flush %v", buf.String())
+	}
+	if err := rw.Flush(); err != nil {
+		t.Errorf("unexpected error flushing: %v", err)
+	} else if buf.String() != "starting\nlogin with password="+REDACTED+" ok\nusing DB_CONN="+REDACTED {
+		t.Errorf("unexpected log after flush %v", buf.String())
+	}
+}
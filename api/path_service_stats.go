@@ -0,0 +1,53 @@
+package api
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/persistence"
+	"sort"
+)
+
+// The selections for the service/stats resource.
+const (
+	SERVICE_STATS_URL     = "url"
+	SERVICE_STATS_ORG     = "org"
+	SERVICE_STATS_VERSION = "version"
+	SERVICE_STATS_SUMMARY = "summary" // When true, the samples are left out of the output
+)
+
+// The resource usage history of a service instance together with a summary of the history.
+type ServiceStatsOutput struct {
+	persistence.ServiceStats
+	Summary *persistence.ServiceStatsSummary `json:"summary"`
+}
+
+// Returns the resource usage history of the service instances that match the given url, org and version. Empty
+// selections match everything. The output is sorted by org, url and instance key.
+func FindServiceStatsForOutput(db *bolt.DB, url string, org string, version string, summaryOnly bool) ([]ServiceStatsOutput, error) {
+	out := make([]ServiceStatsOutput, 0)
+
+	allStats, err := persistence.FindServiceStats(db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stats := range allStats {
+		if (url != "" && stats.ServiceURL != url) || (org != "" && stats.Org != org) || (version != "" && stats.Version != version) {
+			continue
+		}
+		so := ServiceStatsOutput{ServiceStats: stats, Summary: stats.Summary()}
+		if summaryOnly {
+			so.Samples = nil
+		}
+		out = append(out, so)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Org != out[j].Org {
+			return out[i].Org < out[j].Org
+		} else if out[i].ServiceURL != out[j].ServiceURL {
+			return out[i].ServiceURL < out[j].ServiceURL
+		}
+		return out[i].InstanceKey < out[j].InstanceKey
+	})
+	return out, nil
+}
@@ -0,0 +1,42 @@
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+func Test_FindServiceStatsForOutput(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	sample := persistence.ServiceResourceSample{Time: 60, Containers: []persistence.ContainerResourceSample{{Name: "c1", CPUPercent: 12.5, MemoryUsage: 100}}}
+	for _, s := range []persistence.ServiceStats{
+		{InstanceKey: "ag2", ServiceURL: "my.com.svc", Org: "myorg", Version: "1.0.0"},
+		{InstanceKey: "ag1", ServiceURL: "my.com.svc", Org: "myorg", Version: "1.0.0"},
+		{InstanceKey: "dep1", ServiceURL: "my.com.dep", Org: "myorg", Version: "2.0.0"},
+	} {
+		if err := persistence.SaveServiceStatsSample(db, s, sample, 10); err != nil {
+			t.Errorf("error saving service stats: %v", err)
+		}
+	}
+
+	if out, err := FindServiceStatsForOutput(db, "", "", "", false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(out) != 3 || out[0].InstanceKey != "dep1" || out[1].InstanceKey != "ag1" {
+		t.Errorf("expected all the instances sorted by service, got %v", out)
+	} else if out[0].Summary == nil || out[0].Summary.CPUPercent != 12.5 || len(out[0].Samples) != 1 {
+		t.Errorf("expected a summary and the samples, got %+v", out[0])
+	}
+
+	if out, err := FindServiceStatsForOutput(db, "my.com.svc", "myorg", "1.0.0", true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(out) != 2 || out[0].Samples != nil || out[0].Summary == nil {
+		t.Errorf("expected only the summaries of my.com.svc, got %v", out)
+	}
+}
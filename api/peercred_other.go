@@ -0,0 +1,14 @@
+// +build !linux
+
+package api
+
+import (
+	"errors"
+	"net"
+)
+
+// Peer credentials are only supported on linux. Without them, only requests carrying the local bearer token can
+// change the node through the unix domain socket.
+func getPeerCred(c net.Conn) (*peerCred, error) {
+	return nil, errors.New("peer credentials are not supported on this platform")
+}
@@ -28,10 +28,21 @@ type HealthTimestamps struct {
 	LastVaultInteraction uint64 `json:"lastVaultInteraction"`
 }
 
+// Filled in by the agent API when the node is configured to keep running while it cannot reach the exchange.
+type OfflineStatus struct {
+	Offline       bool  `json:"offline"`
+	OfflineSince  int64 `json:"offlineSince,omitempty"`
+	GracePeriodS  int   `json:"gracePeriodS"`
+	GraceExpired  bool  `json:"graceExpired"`
+	StatusPending bool  `json:"statusPending"`
+	LastReconnect int64 `json:"lastReconnect,omitempty"`
+}
+
 type Info struct {
 	Configuration *Configuration    `json:"configuration"`
 	Connectivity  map[string]bool   `json:"connectivity,omitempty"`
 	LiveHealth    *HealthTimestamps `json:"liveHealth"`
+	Offline       *OfflineStatus    `json:"offlineMode,omitempty"`
 }
 
 func NewInfo(httpClientFactory *config.HTTPClientFactory, exchangeUrl string, mmsUrl string, id string, token string) *Info {
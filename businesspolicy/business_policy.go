@@ -22,10 +22,11 @@ type BusinessPolicy struct {
 	Constraints   externalpolicy.ConstraintExpression `json:"constraints,omitempty"`
 	UserInput     []policy.UserInput                  `json:"userInput,omitempty"`
 	SecretBinding []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"` // The secret binding from service secret names to secret manager secret names.
+	Rollout       *RolloutStrategy                    `json:"rollout,omitempty"`       // How the nodes are upgraded when the service version changes, all at once when not set.
 }
 
 func (w BusinessPolicy) String() string {
-	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Service: %v, Properties: %v, Constraints: %v, UserInput: %v, SecretBinding: %v",
+	return fmt.Sprintf("Owner: %v, Label: %v, Description: %v, Service: %v, Properties: %v, Constraints: %v, UserInput: %v, SecretBinding: %v, Rollout: %v",
 		w.Owner,
 		w.Label,
 		w.Description,
@@ -33,7 +34,8 @@ func (w BusinessPolicy) String() string {
 		w.Properties,
 		w.Constraints,
 		w.UserInput,
-		w.SecretBinding)
+		w.SecretBinding,
+		w.Rollout)
 }
 
 type ServiceRef struct {
@@ -92,6 +94,23 @@ func (w WorkloadChoice) String() string {
 		w.Upgrade)
 }
 
+type RolloutStrategy struct {
+	BatchSize       int `json:"batchSize,omitempty"`           // the number of nodes upgraded in each batch
+	BatchPercentage int `json:"batchPercentage,omitempty"`     // the percentage of the nodes upgraded in each batch, used when batchSize is not set
+	PauseS          int `json:"pauseBetweenBatches,omitempty"` // the number of seconds to wait after a batch completes before starting the next batch
+	MaxFailures     int `json:"maxFailures,omitempty"`         // the rollout is halted when this many nodes fail to upgrade, 0 means it is never halted
+	FailureTimeoutS int `json:"failureTimeout,omitempty"`      // the number of seconds a node has to make a new agreement before its upgrade is considered failed
+}
+
+func (w RolloutStrategy) String() string {
+	return fmt.Sprintf("BatchSize: %v, BatchPercentage: %v, PauseS: %v, MaxFailures: %v, FailureTimeoutS: %v",
+		w.BatchSize,
+		w.BatchPercentage,
+		w.PauseS,
+		w.MaxFailures,
+		w.FailureTimeoutS)
+}
+
 type NodeHealth struct {
 	MissingHBInterval    int `json:"missing_heartbeat_interval,omitempty"` // How long a heartbeat can be missing until it is considered missing (in seconds)
 	CheckAgreementStatus int `json:"check_agreement_status,omitempty"`     // How often to check that the node agreement entry still exists in the exchange (in seconds)
@@ -117,6 +136,20 @@ func (b *BusinessPolicy) Validate() error {
 		return fmt.Errorf(msgPrinter.Sprintf("The serviceVersions array is empty."))
 	}
 
+	// Validate the upgrade policy of each service version.
+	for _, wl := range b.Service.ServiceVersions {
+		if err := policy.Workload_Upgrade_Factory(wl.Upgrade.Lifecycle, wl.Upgrade.Time).Validate(); err != nil {
+			return fmt.Errorf(msgPrinter.Sprintf("The upgradePolicy of service version %v is not valid: %v", wl.Version, err))
+		}
+	}
+
+	// Validate the rollout strategy.
+	if b.Rollout != nil {
+		if err := ConvertRollout(b.Rollout).Validate(); err != nil {
+			return fmt.Errorf(msgPrinter.Sprintf("The rollout is not valid: %v", err))
+		}
+	}
+
 	// Validate the PropertyList.
 	if b != nil && len(b.Properties) != 0 {
 		if err := b.Properties.Validate(); err != nil {
@@ -195,6 +228,11 @@ func (b *BusinessPolicy) GenPolicyFromBusinessPolicy(policyName string) (*policy
 	// node health
 	ConvertNodeHealth(service.NodeH, pol)
 
+	// staged rollout
+	if b.Rollout != nil {
+		pol.Rollout = ConvertRollout(b.Rollout)
+	}
+
 	pol.MaxAgreements = DEFAULT_MAX_AGREEMENT
 
 	// add default agreement protocol
@@ -221,9 +259,16 @@ func (b *BusinessPolicy) GenPolicyFromBusinessPolicy(policyName string) (*policy
 func ConvertChoice(wl WorkloadChoice, url string, org string, arch string, pol *policy.Policy) {
 	newWL := policy.Workload_Factory(url, org, wl.Version, arch)
 	newWL.Priority = (*policy.Workload_Priority_Factory(wl.Priority.PriorityValue, wl.Priority.Retries, wl.Priority.RetryDurationS, wl.Priority.VerifiedDurationS))
+	if upgrade := policy.Workload_Upgrade_Factory(wl.Upgrade.Lifecycle, wl.Upgrade.Time); !upgrade.IsEmpty() {
+		newWL.Upgrade = upgrade
+	}
 	pol.Add_Workload(newWL)
 }
 
+func ConvertRollout(rollout *RolloutStrategy) *policy.RolloutPolicy {
+	return policy.RolloutPolicy_Factory(rollout.BatchSize, rollout.BatchPercentage, rollout.PauseS, rollout.MaxFailures, rollout.FailureTimeoutS)
+}
+
 func ConvertNodeHealth(nodeh NodeHealth, pol *policy.Policy) {
 	// Copy over the node health policy
 	nh := policy.NodeHealth_Factory(nodeh.MissingHBInterval, nodeh.CheckAgreementStatus)
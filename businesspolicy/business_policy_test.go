@@ -76,6 +76,81 @@ func Test_Validate_Failed2(t *testing.T) {
 	}
 }
 
+// invalid upgrade policy
+func Test_Validate_Failed_UpgradePolicy(t *testing.T) {
+
+	service := ServiceRef{
+		Name: "cpu",
+		Org:  "mycomp",
+		Arch: "amd64",
+		ServiceVersions: []WorkloadChoice{
+			{Version: "1.0.0", Upgrade: UpgradePolicy{Lifecycle: "sometimes"}},
+		},
+	}
+
+	bPolicy := BusinessPolicy{
+		Owner:   "me",
+		Label:   "my business policy",
+		Service: service,
+	}
+
+	if err := bPolicy.Validate(); err == nil {
+		t.Errorf("Validate should have returned error but not.")
+	} else if !strings.Contains(err.Error(), "The upgradePolicy of service version 1.0.0 is not valid") {
+		t.Errorf("Wrong error string: %v", err)
+	}
+
+	bPolicy.Service.ServiceVersions[0].Upgrade = UpgradePolicy{Lifecycle: policy.UPGRADE_LIFECYCLE_IMMEDIATE, Time: "02:00-04:00"}
+	if err := bPolicy.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	} else if pol, err := bPolicy.GenPolicyFromBusinessPolicy("mypolicy"); err != nil {
+		t.Errorf("GenPolicyFromBusinessPolicy should not have returned error: %v", err)
+	} else if pol.Workloads[0].GetUpgrade().Lifecycle != policy.UPGRADE_LIFECYCLE_IMMEDIATE || pol.Workloads[0].GetUpgrade().Time != "02:00-04:00" {
+		t.Errorf("The upgrade policy was not converted: %v", pol.Workloads[0].Upgrade)
+	}
+}
+
+func Test_Validate_Rollout(t *testing.T) {
+
+	service := ServiceRef{
+		Name: "cpu",
+		Org:  "mycomp",
+		Arch: "amd64",
+		ServiceVersions: []WorkloadChoice{
+			{Version: "1.0.0"},
+		},
+	}
+
+	bPolicy := BusinessPolicy{
+		Owner:   "me",
+		Label:   "my business policy",
+		Service: service,
+		Rollout: &RolloutStrategy{PauseS: 60},
+	}
+
+	if err := bPolicy.Validate(); err == nil {
+		t.Errorf("Validate should have returned error but not.")
+	} else if !strings.Contains(err.Error(), "The rollout is not valid") {
+		t.Errorf("Wrong error string: %v", err)
+	}
+
+	bPolicy.Rollout = &RolloutStrategy{BatchPercentage: 20, PauseS: 60, MaxFailures: 2}
+	if err := bPolicy.Validate(); err != nil {
+		t.Errorf("Validate should not have returned error: %v", err)
+	} else if pol, err := bPolicy.GenPolicyFromBusinessPolicy("mypolicy"); err != nil {
+		t.Errorf("GenPolicyFromBusinessPolicy should not have returned error: %v", err)
+	} else if pol.Rollout == nil || !pol.Rollout.IsSame(*policy.RolloutPolicy_Factory(0, 20, 60, 2, 0)) {
+		t.Errorf("The rollout was not converted: %v", pol.Rollout)
+	}
+
+	bPolicy.Rollout = nil
+	if pol, err := bPolicy.GenPolicyFromBusinessPolicy("mypolicy"); err != nil {
+		t.Errorf("GenPolicyFromBusinessPolicy should not have returned error: %v", err)
+	} else if pol.Rollout != nil {
+		t.Errorf("The policy should not have a rollout: %v", pol.Rollout)
+	}
+}
+
 // good one
 func Test_Validate_Succeeded1(t *testing.T) {
 
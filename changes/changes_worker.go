@@ -82,6 +82,8 @@ func NewChangesWorker(name string, cfg *config.HorizonConfig, db *bolt.DB) *Chan
 		glog.V(3).Info(chglog(fmt.Sprintf("restore exchange change state after restart: %v", chgState)))
 	}
 
+	PollIntervalGauge.Set(float64(worker.pollInterval))
+
 	glog.Info(chglog(fmt.Sprintf("Starting ExchangeChanges worker")))
 
 	// The initial poll interval is changed dynamically by the NoWorkHandler when it detects that it can increase
@@ -135,6 +137,15 @@ func (w *ChangesWorker) Initialize() bool {
 		w.updatePollingInterval(UPDATE_TYPE_RESET)
 	}
 
+	// If the node was offline when the agent stopped, treat the heartbeat as failed so that the node is reconciled with
+	// the exchange and the agbots once it reconnects.
+	if offline, err := persistence.FindOfflineState(w.db); err != nil {
+		glog.Errorf(chglog(fmt.Sprintf("error searching for offline state, error %v", err)))
+	} else if offline.IsOffline() {
+		glog.V(3).Infof(chglog(fmt.Sprintf("node has been offline since %v", time.Unix(offline.OfflineSince, 0))))
+		w.heartBeatFailed = true
+	}
+
 	return true
 }
 
@@ -355,6 +366,7 @@ func (w *ChangesWorker) postProcessChanges(changes *exchange.ExchangeChanges, in
 func (w *ChangesWorker) handleHeartbeatStateAndError(changes *exchange.ExchangeChanges, err error) bool {
 	if err != nil {
 		glog.Errorf(chglog(fmt.Sprintf("heartbeat and change retrieval failed, error %v", err)))
+		HeartbeatCounter.Inc(HEARTBEAT_FAILURE)
 
 		if strings.Contains(err.Error(), "status: 401") {
 			// If the heartbeat fails because the node entry is gone then initiate a full node quiesce.
@@ -379,11 +391,17 @@ func (w *ChangesWorker) handleHeartbeatStateAndError(changes *exchange.ExchangeC
 
 				w.Messages() <- events.NewNodeHeartbeatStateChangeMessage(events.NODE_HEARTBEAT_FAILED, exchange.GetOrg(w.GetExchangeId()), exchange.GetId(w.GetExchangeId()))
 			}
+
+			// In offline mode, keep track of how long the node has been offline.
+			if w.heartBeatFailed && w.Config.Edge.OfflineGracePeriodS > 0 {
+				w.updateOfflineState()
+			}
 		}
 		return true
 	} else {
 		// Record the last good heartbeat
 		w.lastHeartbeat = time.Now().Unix()
+		HeartbeatCounter.Inc(HEARTBEAT_SUCCESS)
 
 		if w.pollHBRestoredInterval != 0 {
 			w.updatePollingInterval(UPDATE_TYPE_HB_RESTORED)
@@ -400,6 +418,9 @@ func (w *ChangesWorker) handleHeartbeatStateAndError(changes *exchange.ExchangeC
 				persistence.NewMessageMeta(EL_AG_NODE_HB_RESTORED, exchange.GetOrg(w.GetExchangeId()), exchange.GetId(w.GetExchangeId())),
 				persistence.EC_NODE_HEARTBEAT_RESTORED, exchange.GetId(w.GetExchangeId()), exchange.GetOrg(w.GetExchangeId()), "", "")
 
+			// The offline state is cleared before the other workers are told, so that they see the node as online.
+			w.clearOfflineState()
+
 			w.Messages() <- events.NewNodeHeartbeatStateChangeMessage(events.NODE_HEARTBEAT_RESTORED, exchange.GetOrg(w.GetExchangeId()), exchange.GetId(w.GetExchangeId()))
 		}
 
@@ -420,6 +441,81 @@ func (w *ChangesWorker) handleHeartbeatStateAndError(changes *exchange.ExchangeC
 	return false
 }
 
+// Record when the node went offline, and log when it has been offline for longer than the grace period. Until then
+// the agreements on the node are not cancelled for timing out.
+func (w *ChangesWorker) updateOfflineState() {
+
+	gracePeriod := w.Config.Edge.OfflineGracePeriodS
+
+	state, err := persistence.FindOfflineState(w.db)
+	if err != nil {
+		glog.Errorf(chglog(fmt.Sprintf("error searching for offline state, error %v", err)))
+		return
+	}
+
+	wentOffline := !state.IsOffline()
+	graceExpired := !wentOffline && !state.GraceExpired && !state.InGracePeriod(gracePeriod)
+	if !wentOffline && !graceExpired {
+		return
+	}
+
+	state, err = persistence.UpdateOfflineState(w.db, func(s *persistence.OfflineState) {
+		if wentOffline {
+			// The node has been offline since its last good heartbeat, or since the agent started if there was none.
+			s.OfflineSince = w.lastHeartbeat
+			if s.OfflineSince == 0 {
+				s.OfflineSince = time.Now().Unix()
+			}
+			s.GraceExpired = false
+		} else {
+			s.GraceExpired = true
+		}
+	})
+	if err != nil {
+		glog.Errorf(chglog(fmt.Sprintf("unable to save offline state, error %v", err)))
+		return
+	}
+
+	if wentOffline {
+		glog.Warningf(chglog(fmt.Sprintf("node is offline, agreements will be kept for %v seconds", gracePeriod)))
+		eventlog.LogNodeEvent(w.db, persistence.SEVERITY_WARN,
+			persistence.NewMessageMeta(EL_AG_NODE_OFFLINE, exchange.GetOrg(w.GetExchangeId()), exchange.GetId(w.GetExchangeId()), gracePeriod),
+			persistence.EC_NODE_OFFLINE, exchange.GetId(w.GetExchangeId()), exchange.GetOrg(w.GetExchangeId()), "", "")
+	} else {
+		glog.Errorf(chglog(fmt.Sprintf("node has been offline since %v, longer than the grace period of %v seconds", time.Unix(state.OfflineSince, 0), gracePeriod)))
+		eventlog.LogNodeEvent(w.db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_AG_NODE_OFFLINE_GRACE_EXPIRED, exchange.GetOrg(w.GetExchangeId()), exchange.GetId(w.GetExchangeId()), gracePeriod),
+			persistence.EC_NODE_OFFLINE_GRACE_EXPIRED, exchange.GetId(w.GetExchangeId()), exchange.GetOrg(w.GetExchangeId()), "", "")
+	}
+}
+
+// Record that the node is back online. Agreement timeouts that started before now are counted from now.
+func (w *ChangesWorker) clearOfflineState() {
+
+	state, err := persistence.FindOfflineState(w.db)
+	if err != nil {
+		glog.Errorf(chglog(fmt.Sprintf("error searching for offline state, error %v", err)))
+		return
+	} else if !state.IsOffline() {
+		return
+	}
+
+	offlineS := time.Now().Unix() - state.OfflineSince
+	if _, err := persistence.UpdateOfflineState(w.db, func(s *persistence.OfflineState) {
+		s.OfflineSince = 0
+		s.GraceExpired = false
+		s.LastReconnect = time.Now().Unix()
+	}); err != nil {
+		glog.Errorf(chglog(fmt.Sprintf("unable to save offline state, error %v", err)))
+		return
+	}
+
+	glog.V(3).Infof(chglog(fmt.Sprintf("node reconnected after being offline for %v seconds", offlineS)))
+	eventlog.LogNodeEvent(w.db, persistence.SEVERITY_INFO,
+		persistence.NewMessageMeta(EL_AG_NODE_RECONNECTED, exchange.GetOrg(w.GetExchangeId()), exchange.GetId(w.GetExchangeId()), offlineS),
+		persistence.EC_NODE_RECONNECTED, exchange.GetId(w.GetExchangeId()), exchange.GetOrg(w.GetExchangeId()), "", "")
+}
+
 // Setting up the new polling interval according to the updateType:
 // 	 UPDATE_TYPE_RESET:  set the poll interval to min
 //	 UPDATE_TYPE_ALERT:  set the poll interval to (min + max)/POLL_INTERVAL_ALERT_LEVEL
@@ -428,6 +524,8 @@ func (w *ChangesWorker) handleHeartbeatStateAndError(changes *exchange.ExchangeC
 
 func (w *ChangesWorker) updatePollingInterval(updateType string) {
 
+	defer func() { PollIntervalGauge.Set(float64(w.pollInterval)) }()
+
 	if updateType == UPDATE_TYPE_RESET {
 		// set the polling interval to minial. This is the case where agreement negotiation started when the node needs to
 		// watch the upcoming messages more closely.
@@ -622,6 +720,10 @@ var chglog = func(v interface{}) string {
 const (
 	EL_AG_NODE_HB_FAILED   = "Node heartbeat failed for node %v/%v. Error: %v"
 	EL_AG_NODE_HB_RESTORED = "Node heartbeat restored for node %v/%v."
+
+	EL_AG_NODE_OFFLINE               = "Node %v/%v is offline. Its agreements and services will be kept running for %v seconds."
+	EL_AG_NODE_OFFLINE_GRACE_EXPIRED = "Node %v/%v has been offline for more than %v seconds. Agreements that time out will be cancelled."
+	EL_AG_NODE_RECONNECTED           = "Node %v/%v reconnected to the exchange after being offline for %v seconds. Its agreements will be verified with the agbots."
 )
 
 // This is does nothing useful at run time.
@@ -634,4 +736,7 @@ func MarkI18nMessages() {
 
 	msgPrinter.Sprintf(EL_AG_NODE_HB_FAILED)
 	msgPrinter.Sprintf(EL_AG_NODE_HB_RESTORED)
+	msgPrinter.Sprintf(EL_AG_NODE_OFFLINE)
+	msgPrinter.Sprintf(EL_AG_NODE_OFFLINE_GRACE_EXPIRED)
+	msgPrinter.Sprintf(EL_AG_NODE_RECONNECTED)
 }
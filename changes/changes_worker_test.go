@@ -0,0 +1,188 @@
+// +build unit
+
+package changes
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchange/fakeexchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/worker"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// The node goes offline in offline mode, stays offline past the grace period and then reconnects, with an exchange
+// that comes and goes.
+func Test_OfflineMode_reconnect(t *testing.T) {
+	dir, db := utsetup(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	srv := newTestExchange()
+
+	cfg := &config.HorizonConfig{Edge: config.Config{
+		ExchangeMessagePollInterval:    10,
+		ExchangeMessagePollMaxInterval: 60,
+		ExchangeMessagePollIncrement:   10,
+		OfflineGracePeriodS:            600,
+	}}
+	srv.Configure(cfg)
+
+	w := newTestWorker(cfg, db, srv.MaxChangeId())
+	w.findAndProcessChanges()
+	assert.Empty(t, heartbeatEvents(drain(w)), "the heartbeat should succeed")
+	state, _ := persistence.FindOfflineState(db)
+	assert.False(t, state.IsOffline())
+	lastHeartbeat := w.lastHeartbeat
+
+	// The exchange goes away.
+	srv.SetOffline(true)
+	w.findAndProcessChanges()
+	assert.Equal(t, []events.EventId{events.NODE_HEARTBEAT_FAILED}, heartbeatEvents(drain(w)))
+	state, _ = persistence.FindOfflineState(db)
+	if assert.True(t, state.IsOffline()) {
+		assert.Equal(t, lastHeartbeat, state.OfflineSince, "the node should be offline since its last heartbeat")
+		assert.True(t, state.InGracePeriod(cfg.Edge.OfflineGracePeriodS))
+	}
+	assert.Equal(t, []string{persistence.EC_NODE_HEARTBEAT_FAILED, persistence.EC_NODE_OFFLINE}, eventCodes(t, db))
+
+	// The node stays offline past the grace period, which is logged once.
+	persistence.UpdateOfflineState(db, func(s *persistence.OfflineState) { s.OfflineSince -= 700 })
+	w.findAndProcessChanges()
+	w.findAndProcessChanges()
+	assert.Empty(t, heartbeatEvents(drain(w)))
+	state, _ = persistence.FindOfflineState(db)
+	assert.True(t, state.GraceExpired)
+	assert.Equal(t, []string{persistence.EC_NODE_HEARTBEAT_FAILED, persistence.EC_NODE_OFFLINE, persistence.EC_NODE_OFFLINE_GRACE_EXPIRED}, eventCodes(t, db))
+
+	// The exchange comes back with a change made while the node was offline.
+	pol := externalpolicy.ExternalPolicy{Properties: externalpolicy.PropertyList{{Name: "color", Value: "red"}}}
+	if err := srv.Put("orgs/myorg/nodes/node1/policy", pol); err != nil {
+		t.Fatalf("unable to save node policy: %v", err)
+	}
+	srv.SetOffline(false)
+	w.findAndProcessChanges()
+
+	msgs := drain(w)
+	assert.Equal(t, []events.EventId{events.NODE_HEARTBEAT_RESTORED}, heartbeatEvents(msgs))
+	assert.True(t, hasChange(msgs, events.CHANGE_NODE_POLICY_TYPE), "the change made while offline should be picked up")
+	state, _ = persistence.FindOfflineState(db)
+	if assert.NotNil(t, state) {
+		assert.False(t, state.IsOffline())
+		assert.False(t, state.GraceExpired)
+		assert.True(t, state.LastReconnect >= lastHeartbeat)
+		assert.Equal(t, state.LastReconnect, state.TimeoutStart(lastHeartbeat), "timeouts should be counted from the reconnection")
+	}
+	codes := eventCodes(t, db)
+	assert.Equal(t, persistence.EC_NODE_RECONNECTED, codes[len(codes)-1])
+}
+
+// An agent that is restarted while the node is offline reconciles with the exchange when the node reconnects.
+func Test_OfflineMode_restart(t *testing.T) {
+	dir, db := utsetup(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	srv := newTestExchange()
+
+	cfg := &config.HorizonConfig{Edge: config.Config{ExchangeMessagePollInterval: 10, ExchangeMessagePollMaxInterval: 60, OfflineGracePeriodS: 600}}
+	srv.Configure(cfg)
+
+	persistence.UpdateOfflineState(db, func(s *persistence.OfflineState) { s.OfflineSince = time.Now().Unix() - 60 })
+
+	w := newTestWorker(cfg, db, srv.MaxChangeId())
+	w.Initialize()
+	assert.True(t, w.heartBeatFailed, "the heartbeat should be considered failed after a restart while offline")
+
+	w.findAndProcessChanges()
+	assert.Equal(t, []events.EventId{events.NODE_HEARTBEAT_RESTORED}, heartbeatEvents(drain(w)))
+	state, _ := persistence.FindOfflineState(db)
+	assert.False(t, state.IsOffline())
+}
+
+// The exchange always returns the heartbeat intervals of an org.
+func newTestExchange() *fakeexchange.Server {
+	srv := fakeexchange.NewServer()
+	srv.AddOrg("myorg", map[string]interface{}{"heartbeatIntervals": map[string]interface{}{"minInterval": 0, "maxInterval": 0, "intervalAdjustment": 0}})
+	srv.AddNode("myorg", "node1", "nodetoken", nil)
+	return srv
+}
+
+func utsetup(t *testing.T) (string, *bolt.DB) {
+	dir, err := ioutil.TempDir("", "changes-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	db, err := bolt.Open(path.Join(dir, "anax-ut.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unable to open db: %v", err)
+	}
+	return dir, db
+}
+
+// A worker that is not started, so the test drives it. The messages it sends are buffered.
+func newTestWorker(cfg *config.HorizonConfig, db *bolt.DB, changeId uint64) *ChangesWorker {
+	ec := worker.NewExchangeContext("myorg/node1", "nodetoken", cfg.Edge.ExchangeURL, cfg.GetCSSURL(), cfg.Collaborators.HTTPClientFactory)
+	w := &ChangesWorker{
+		BaseWorker:      worker.NewBaseWorker("changes", cfg, ec),
+		db:              db,
+		pollInterval:    cfg.Edge.ExchangeMessagePollInterval,
+		pollMinInterval: cfg.Edge.ExchangeMessagePollInterval,
+		pollMaxInterval: cfg.Edge.ExchangeMessagePollMaxInterval,
+		pollAdjustment:  cfg.Edge.ExchangeMessagePollIncrement,
+		changeID:        changeId,
+	}
+	w.Manager.Messages = make(chan events.Message, 50)
+	return w
+}
+
+func drain(w *ChangesWorker) []events.Message {
+	msgs := make([]events.Message, 0)
+	for {
+		select {
+		case m := <-w.Messages():
+			msgs = append(msgs, m)
+		default:
+			return msgs
+		}
+	}
+}
+
+// Returns the heartbeat state changes in the messages sent by the worker.
+func heartbeatEvents(msgs []events.Message) []events.EventId {
+	ids := make([]events.EventId, 0)
+	for _, m := range msgs {
+		if hb, ok := m.(*events.NodeHeartbeatStateChangeMessage); ok {
+			ids = append(ids, hb.Event().Id)
+		}
+	}
+	return ids
+}
+
+func hasChange(msgs []events.Message, id events.EventId) bool {
+	for _, m := range msgs {
+		if m.Event().Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func eventCodes(t *testing.T, db *bolt.DB) []string {
+	logs, err := persistence.FindAllEventLogs(db)
+	if err != nil {
+		t.Fatalf("unable to read event logs: %v", err)
+	}
+	codes := make([]string, 0, len(logs))
+	for _, l := range logs {
+		codes = append(codes, l.EventCode)
+	}
+	return codes
+}
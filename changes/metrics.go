@@ -0,0 +1,19 @@
+package changes
+
+import (
+	"github.com/open-horizon/anax/metrics"
+)
+
+// The node heartbeat metrics. The heartbeat is the call to the exchange /changes API, so each poll is counted
+// as a heartbeat.
+var HeartbeatCounter = metrics.NewCounterVec("anax_node_heartbeats_total",
+	"The number of node heartbeats to the exchange, by result (success or failure).",
+	"result")
+
+var PollIntervalGauge = metrics.NewGaugeVec("anax_exchange_message_poll_interval_seconds",
+	"The current interval between polls of the exchange for changes.")
+
+const (
+	HEARTBEAT_SUCCESS = "success"
+	HEARTBEAT_FAILURE = "failure"
+)
@@ -0,0 +1,65 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/agreementbot"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/i18n"
+	"os"
+)
+
+// Display the deployment status of the deployment policies and patterns this agbot is working with. When a name is
+// given, the status of each node is shown too. Unless long is set, only the failed nodes are shown.
+func DeploymentStatus(org string, name string, pattern bool, nodeErrors bool, long bool) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// set env to call agbot url
+	if err := os.Setenv("HORIZON_URL", cliutils.GetAgbotUrlBase()); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("unable to set env var 'HORIZON_URL', error %v", err))
+	}
+
+	var output interface{}
+	if name == "" {
+		statuses := make([]agreementbot.DeploymentStatus, 0)
+		cliutils.HorizonGet("deploymentstatus", []int{200}, &statuses, false)
+		output = statuses
+	} else {
+		var polName string
+		org, polName = cliutils.TrimOrg(org, name)
+		if org == "" {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("an organization must be specified with the name, as org/name, or with the -o flag or the HZN_ORG_ID environment variable."))
+		}
+
+		depType := agreementbot.DEPLOYMENT_TYPE_POLICY
+		if pattern {
+			depType = agreementbot.DEPLOYMENT_TYPE_PATTERN
+		}
+
+		url := fmt.Sprintf("deploymentstatus/%v/%v/%v", depType, org, polName)
+		if nodeErrors {
+			url += "?errors=true"
+		}
+
+		status := agreementbot.DeploymentStatus{}
+		cliutils.HorizonGet(url, []int{200}, &status, false)
+
+		if !long {
+			failed := make([]*agreementbot.NodeDeploymentStatus, 0)
+			for _, ns := range status.Nodes {
+				if ns.State == agreementbot.NODE_DEPLOYMENT_FAILED {
+					failed = append(failed, ns)
+				}
+			}
+			status.Nodes = failed
+		}
+		output = status
+	}
+
+	jsonBytes, err := json.MarshalIndent(output, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn agbot deploymentpol status' output: %v", err))
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
@@ -42,6 +42,12 @@ const (
 	JSON_INDENT         = "  "
 	MUST_REGISTER_FIRST = "this command can not be run before running 'hzn register'"
 
+	// The horizon-container script exports the local bearer token of the agent to this file on the host, under the
+	// file sync service directory of the container, like /var/tmp/horizon/horizon1/api/anax-api.token.
+	HZN_CONTAINER_TOKEN_FILE     = "api/" + config.HZN_API_TOKEN_FILE
+	HZN_CONTAINER_SHARE_BASE     = "/var/tmp/horizon"
+	HZN_CONTAINER_SHARE_BASE_MAC = "/private/var/tmp/horizon"
+
 	// Exit Codes
 	CLI_INPUT_ERROR    = 1 // we actually don't have control over the usage exit code that kingpin returns, so use the same code for input errors we catch ourselves
 	JSON_PARSING_ERROR = 3
@@ -95,7 +101,6 @@ type UserExchangeReq struct {
 }
 
 var dockerDriversWithTagSupport = []string{"syslog", "journald", "gelf", "fluentd", "awslogs", "splunk"}
-var dockerDriversWithLoggingSupport = []string{"syslog", "journald", "local", "json-file"}
 
 func Verbose(msg string, args ...interface{}) {
 	if Opts.Verbose == nil {
@@ -545,6 +550,80 @@ func GetHorizonUrlBase() string {
 	}
 }
 
+// GetHorizonSocket returns the path of the unix domain socket of the local Horizon agent API, or empty string if the
+// api should be reached at the horizon api url. The socket is preferred when HORIZON_URL is not set and the socket exists.
+func GetHorizonSocket() string {
+	if os.Getenv("HORIZON_URL") != "" {
+		return ""
+	}
+	socketPath := config.GetDefaultAPISocketPath()
+	if fi, err := os.Stat(socketPath); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		return socketPath
+	}
+	return ""
+}
+
+// GetHorizonHTTPClient returns an http client for the anax api, which connects to the unix domain socket of the agent
+// when it is in use.
+func GetHorizonHTTPClient(timeout int) *http.Client {
+	httpClient := GetHTTPClient(timeout)
+	if socketPath := GetHorizonSocket(); socketPath != "" {
+		if tr, ok := httpClient.Transport.(*http.Transport); ok {
+			Verbose(i18n.GetMessagePrinter().Sprintf("Connecting to the Horizon agent on unix domain socket %v", socketPath))
+			dial := tr.Dial
+			tr.Dial = func(network, addr string) (net.Conn, error) {
+				return dial("unix", socketPath)
+			}
+		}
+	}
+	return httpClient
+}
+
+// addHorizonAuthorization adds the local bearer token of the agent to a request for the anax api, when the token file
+// is readable by the user. The token is needed to change the node when the user is not allowed to do so by the agent's
+// socket configuration. It is only sent to the agent on this host, through the unix domain socket or a loopback
+// address, never to a remote HORIZON_URL.
+func addHorizonAuthorization(req *http.Request) {
+	if GetHorizonSocket() == "" && !isLoopbackHost(req.URL.Hostname()) {
+		return
+	}
+	if content, err := ioutil.ReadFile(getHorizonTokenFile()); err == nil {
+		if token := strings.TrimSpace(string(content)); token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// getHorizonTokenFile returns the local bearer token file of the agent. When the agent is reached on a loopback
+// HORIZON_URL and there is no token file at the default location, the agent is assumed to be a horizon container,
+// which exports its token to the host in the file sync service directory of the container.
+func getHorizonTokenFile() string {
+	tokenFile := config.GetDefaultAPITokenFile()
+	if os.Getenv(config.AnaxAPITokenFile) != "" || GetHorizonSocket() != "" {
+		return tokenFile
+	} else if _, err := os.Stat(tokenFile); err == nil {
+		return tokenFile
+	}
+	index, err := GetHorizonContainerIndex()
+	if err != nil {
+		return tokenFile
+	}
+	shareBase := HZN_CONTAINER_SHARE_BASE
+	if runtime.GOOS == "darwin" {
+		shareBase = HZN_CONTAINER_SHARE_BASE_MAC
+	}
+	return filepath.Join(shareBase, fmt.Sprintf("horizon%v", index), HZN_CONTAINER_TOKEN_FILE)
+}
+
+// isLoopbackHost returns true if the host name of a url is localhost or a loopback ip address.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // GetHorizonContainerIndex returns expected horizon container index based on the HORIZON_URL port binding
 // e.g. if horizon container is running on 8081 port it's index would be 1 and expected container name is horizon1
 func GetHorizonContainerIndex() (int, error) {
@@ -636,13 +715,29 @@ func printHorizonRestError(apiMethod string, err error) {
 // Only if the actual code matches the 1st element in goodHttpCodes, will it parse the body into the specified structure.
 // If quiet is true, then the error will be returned, the function returns back to the caller instead of exiting out.
 func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}, quiet bool) (httpCode int, retError error) {
+	return horizonGet(GetHorizonHTTPClient(0), urlSuffix, goodHttpCodes, structure, quiet)
+}
+
+// HorizonGetWait is HorizonGet for requests that the agent holds for up to waitS seconds before it responds, like
+// following the event log. The response headers only arrive when the agent responds, so there is no response header
+// timeout, and the request timeout is extended by the wait.
+func HorizonGetWait(urlSuffix string, waitS int, goodHttpCodes []int, structure interface{}, quiet bool) (httpCode int, retError error) {
+	httpClient := GetHorizonHTTPClient(0)
+	if tr, ok := httpClient.Transport.(*http.Transport); ok {
+		tr.ResponseHeaderTimeout = 0
+	}
+	if httpClient.Timeout != 0 {
+		httpClient.Timeout += time.Duration(waitS) * time.Second
+	}
+	return horizonGet(httpClient, urlSuffix, goodHttpCodes, structure, quiet)
+}
+
+func horizonGet(httpClient *http.Client, urlSuffix string, goodHttpCodes []int, structure interface{}, quiet bool) (httpCode int, retError error) {
 	retError = nil
 
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
-	httpClient := GetHTTPClient(0)
-
 	url := GetHorizonUrlBase() + "/" + urlSuffix
 	apiMsg := http.MethodGet + " " + url
 	Verbose(apiMsg)
@@ -653,6 +748,7 @@ func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}, qu
 	}
 	req.Close = true
 	req.Header.Add("Accept", "application/json")
+	addHorizonAuthorization(req)
 
 	// add the language request to the http header
 	localeTag, err := i18n.GetLocale()
@@ -722,6 +818,61 @@ func HorizonGet(urlSuffix string, goodHttpCodes []int, structure interface{}, qu
 	return
 }
 
+// HorizonGetStream runs a GET on the anax api and copies the response body to the writer as it arrives, so that it can
+// be used for responses that do not end until the user stops the command. If the http code is not the first element of
+// goodHttpCodes, the body is not copied. It exits with an error when the code is not one of goodHttpCodes, showing the
+// error returned by the agent.
+func HorizonGetStream(urlSuffix string, goodHttpCodes []int, out io.Writer) int {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	httpClient := GetHorizonHTTPClient(0)
+	httpClient.Timeout = 0
+
+	url := GetHorizonUrlBase() + "/" + urlSuffix
+	apiMsg := http.MethodGet + " " + url
+	Verbose(apiMsg)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		Fatal(HTTP_ERROR, msgPrinter.Sprintf("%s new request failed: %v", apiMsg, err))
+	}
+	req.Close = true
+	addHorizonAuthorization(req)
+
+	// add the language request to the http header
+	localeTag, err := i18n.GetLocale()
+	if err != nil {
+		localeTag = language.English
+	}
+	req.Header.Add("Accept-Language", localeTag.String())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		printHorizonRestError(apiMsg, err)
+	}
+	defer resp.Body.Close()
+
+	httpCode := resp.StatusCode
+	Verbose(msgPrinter.Sprintf("HTTP code: %d", httpCode))
+	if !isGoodCode(httpCode, goodHttpCodes) {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		apiErr := struct {
+			Error string `json:"error"`
+		}{}
+		if err := json.Unmarshal(bodyBytes, &apiErr); err == nil && apiErr.Error != "" {
+			Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, apiErr.Error))
+		}
+		Fatal(HTTP_ERROR, msgPrinter.Sprintf("bad HTTP code %d from %s: %s", httpCode, apiMsg, strings.TrimSpace(string(bodyBytes))))
+	}
+
+	if httpCode == goodHttpCodes[0] {
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			Fatal(HTTP_ERROR, msgPrinter.Sprintf("failed to read body response from %s: %v", apiMsg, err))
+		}
+	}
+	return httpCode
+}
+
 // HorizonDelete runs a DELETE on the anax api.
 // If the list of goodHttpCodes is not empty and none match the actual http code, it will exit with an error. Otherwise the actual code is returned.
 func HorizonDelete(urlSuffix string, goodHttpCodes []int, expectedHttpErrorCodes []int, quiet bool) (httpCode int, retError error) {
@@ -735,7 +886,7 @@ func HorizonDelete(urlSuffix string, goodHttpCodes []int, expectedHttpErrorCodes
 	if IsDryRun() {
 		return 204, nil
 	}
-	httpClient := GetHTTPClient(0)
+	httpClient := GetHorizonHTTPClient(0)
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		if quiet {
@@ -746,6 +897,7 @@ func HorizonDelete(urlSuffix string, goodHttpCodes []int, expectedHttpErrorCodes
 		}
 	}
 	req.Close = true
+	addHorizonAuthorization(req)
 
 	resp, err := httpClient.Do(req)
 	if resp != nil && resp.Body != nil {
@@ -797,7 +949,7 @@ func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body i
 	if IsDryRun() {
 		return 201, "", nil
 	}
-	httpClient := GetHTTPClient(0)
+	httpClient := GetHorizonHTTPClient(0)
 
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
@@ -834,6 +986,7 @@ func HorizonPutPost(method string, urlSuffix string, goodHttpCodes []int, body i
 	}
 	req.Close = true
 	req.Header.Add("Accept", "application/json")
+	addHorizonAuthorization(req)
 	if bodyIsBytes {
 		req.Header.Add("Content-Length", strconv.Itoa(len(jsonBytes)))
 	} else {
@@ -2134,24 +2287,6 @@ func LoggingDriverSupportsTagging(driverName string) bool {
 	return false
 }
 
-func ChekServiceLogPossibility(logDriver string) (nonDefaultDriverUsed bool, err error) {
-	if logDriver == "" || logDriver == "syslog" {
-		return false, nil
-	}
-
-	for i, _ := range dockerDriversWithLoggingSupport {
-		if logDriver == dockerDriversWithLoggingSupport[i] {
-			return true, nil
-		}
-	}
-
-	// get message printer
-	msgPrinter := i18n.GetMessagePrinter()
-
-	return true, fmt.Errorf(msgPrinter.Sprintf("Provided log-driver (%s) does not support logs viewing. "+
-		"Logs are only available on the following drivers: %v", logDriver, dockerDriversWithLoggingSupport))
-}
-
 // progressReader is an io.Reader wrapper with progress reporting function
 type progressReader struct {
 	io.Reader
@@ -1,8 +1,10 @@
 package dev
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/cli/plugin_registry"
 	"github.com/open-horizon/anax/common"
@@ -12,7 +14,6 @@ import (
 	"github.com/open-horizon/anax/semanticversion"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 )
 
@@ -336,8 +337,7 @@ func ServiceLog(homeDirectory string, serviceName string, containerName string,
 		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", SERVICE_COMMAND, SERVICE_LOG_COMMAND, cerr)
 	}
 
-	// find the log driver for the container (default syslog)
-	var logDriver string
+	// find the container whose log is displayed
 	if containerName == "" && len(dc.Services) > 1 {
 
 		// collect the container names
@@ -348,25 +348,14 @@ func ServiceLog(homeDirectory string, serviceName string, containerName string,
 		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' More than one container has been found for deployment: "+
 			strings.Join(containerNames, ", ")+". Please specify the service name by -c flag", SERVICE_COMMAND, SERVICE_LOG_COMMAND))
 	} else if containerName != "" {
-		found := false
-		for name, svc := range dc.Services {
-			if name == containerName {
-				found = true
-				logDriver = svc.LogDriver
-			}
-		}
-		if !found {
+		if _, found := dc.Services[containerName]; !found {
 			cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v': container %v not found in service %v", SERVICE_COMMAND, SERVICE_LOG_COMMAND, containerName, serviceName))
 		}
 	} else { // containerName == "" && len(dc.Services) == 1
-		for name, svc := range dc.Services {
+		for name, _ := range dc.Services {
 			containerName = name
-			logDriver = svc.LogDriver
 		}
 	}
-	if logDriver == "" {
-		logDriver = "syslog"
-	}
 
 	// Locate the dev container(s) and show logs
 	containers, err := findContainers(containerName, "", cw)
@@ -379,11 +368,6 @@ func ServiceLog(homeDirectory string, serviceName string, containerName string,
 		if _, isDevService := c.Labels[container.LABEL_PREFIX+".dev_service"]; isDevService {
 			msId := c.Labels[container.LABEL_PREFIX+".agreement_id"]
 
-			nonDefaultLogDriverUsed := false
-			if nonDefaultLogDriverUsed, err = cliutils.ChekServiceLogPossibility(logDriver); err != nil {
-				cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Unable to display log messages: %v", err))
-			}
-
 			msgPrinter.Printf("Displaying log messages for dev service %v with instance id prefix %v.", serviceName, msId)
 			msgPrinter.Println()
 			if tailing {
@@ -391,10 +375,13 @@ func ServiceLog(homeDirectory string, serviceName string, containerName string,
 				msgPrinter.Println()
 			}
 
-			if runtime.GOOS == "darwin" || nonDefaultLogDriverUsed {
-				cliutils.LogMac(msId+"_"+containerName, tailing)
-			} else {
-				cliutils.LogLinux(msId+"_"+containerName, tailing)
+			// The log is read through the docker API, the same way the agent reads the log of a service, so it does not
+			// depend on the log driver writing to the system log. The container worker's client is the one that started
+			// the dev service.
+			target := &api.ServiceLogTarget{InstanceKey: msId, ServiceURL: serviceName, Container: containerName}
+			opts := &api.ServiceLogOptions{Tail: -1, Follow: tailing}
+			if err := api.WriteDockerClientServiceLog(context.Background(), cw.GetClient(), target, opts, os.Stdout); err != nil {
+				cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Unable to display log messages: %v", err))
 			}
 			return
 		}
@@ -1,11 +1,14 @@
 package eventlog
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
+	"io"
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -63,91 +66,178 @@ func getSelectionString(selections []string) (string, error) {
 	return strings.Join(sels, "&"), nil
 }
 
-func List(all bool, detail bool, selections []string, tailing bool) {
+// The number of seconds each request for new event logs waits on the agent when following the event log.
+const FOLLOW_WAIT_SECONDS = 30
 
-	// format the eventlog api string
+// Returns the eventlog api string for the selections, and the selection string itself.
+func getEventLogUrl(all bool, selections []string) (string, string) {
 	url_s := "eventlog"
 	if all {
 		url_s = fmt.Sprintf("%v/all", url_s)
 	}
 
+	sel_s := ""
 	if len(selections) > 0 {
 		if s, err := getSelectionString(selections); err != nil {
 			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", err)
 		} else {
+			sel_s = s
 			url_s = fmt.Sprintf("%v?%v", url_s, s)
 		}
 	}
+	return url_s, sel_s
+}
+
+func List(all bool, detail bool, selections []string, tailing bool) {
+
+	// format the eventlog api string
+	url_s, sel_s := getEventLogUrl(all, selections)
+
+	// get the eventlog from anax
+	apiOutput := make([]persistence.EventLogRaw, 0)
+	cliutils.HorizonGet(url_s, []int{200}, &apiOutput, false)
+	printEventLogs(apiOutput, detail, tailing)
+
+	if !tailing {
+		return
+	}
 
+	// Follow the event log. Each request to the stream API returns as soon as there are new records after the last
+	// one displayed, or an empty list if nothing happened while waiting.
+	lastId := "0"
+	if len(apiOutput) > 0 {
+		lastId = apiOutput[len(apiOutput)-1].Id
+	}
 	for {
-		// get the eventlog from anax
-		apiOutput := make([]persistence.EventLogRaw, 0)
-		cliutils.HorizonGet(url_s, []int{200}, &apiOutput, false)
-
-		//output
-
-		if detail {
-			long_output := make([]EventLog, len(apiOutput))
-			for i, v := range apiOutput {
-				long_output[i].Id = v.Id
-				long_output[i].Timestamp = cliutils.ConvertTime(v.Timestamp)
-				long_output[i].Severity = v.Severity
-				long_output[i].Message = v.Message
-				long_output[i].EventCode = v.EventCode
-				long_output[i].SourceType = v.SourceType
-				long_output[i].Source = v.Source
-			}
+		stream_s := fmt.Sprintf("eventlog/stream?since=%v&wait=%v", lastId, FOLLOW_WAIT_SECONDS)
+		if sel_s != "" {
+			stream_s = fmt.Sprintf("%v&%v", stream_s, sel_s)
+		}
 
-			jsonBytes, err := cliutils.DisplayAsJson(long_output)
-			if err != nil {
-				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog list' output: %v", err))
-			}
-			if len(jsonBytes) > 3 {
-				fmt.Printf("%s", jsonBytes[2:len(jsonBytes)-2])
-			}
-		} else {
-			short_output := make([]string, len(apiOutput))
-			for i, v := range apiOutput {
-				t := time.Unix(int64(v.Timestamp), 0)
-				short_output[i] = fmt.Sprintf("%v:   %v", t.Format("2006-01-02 15:04:05"), v.Message)
-			}
-			jsonBytes, err := cliutils.DisplayAsJson(short_output)
-			if err != nil {
-				cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog list' output: %v", err))
-			}
+		apiOutput = make([]persistence.EventLogRaw, 0)
+		cliutils.HorizonGetWait(stream_s, FOLLOW_WAIT_SECONDS, []int{200}, &apiOutput, false)
+		printEventLogs(apiOutput, detail, tailing)
 
-			if len(jsonBytes) > 3 {
-				fmt.Printf("%s", jsonBytes[2:len(jsonBytes)-2])
-			}
+		if len(apiOutput) > 0 {
+			lastId = apiOutput[len(apiOutput)-1].Id
 		}
+	}
+}
+
+// Display the event logs, in detail or just the time and message of each one. When following the event log,
+// each batch of records ends with a new line so that the next batch starts on its own line.
+func printEventLogs(apiOutput []persistence.EventLogRaw, detail bool, tailing bool) {
 
+	var output interface{}
+	if detail {
+		long_output := make([]EventLog, len(apiOutput))
+		for i, v := range apiOutput {
+			long_output[i].Id = v.Id
+			long_output[i].Timestamp = cliutils.ConvertTime(v.Timestamp)
+			long_output[i].Severity = v.Severity
+			long_output[i].Message = v.Message
+			long_output[i].EventCode = v.EventCode
+			long_output[i].SourceType = v.SourceType
+			long_output[i].Source = v.Source
+		}
+		output = long_output
+	} else {
+		short_output := make([]string, len(apiOutput))
+		for i, v := range apiOutput {
+			t := time.Unix(int64(v.Timestamp), 0)
+			short_output[i] = fmt.Sprintf("%v:   %v", t.Format("2006-01-02 15:04:05"), v.Message)
+		}
+		output = short_output
+	}
+
+	jsonBytes, err := cliutils.DisplayAsJson(output)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn eventlog list' output: %v", err))
+	}
+	if len(jsonBytes) > 3 {
+		fmt.Printf("%s", jsonBytes[2:len(jsonBytes)-2])
 		if tailing {
-			// selection contraints for most recent records
-			var newselect []string
-
-			// update with inputted selection contraints
-			if len(selections) > 0 {
-				newselect = make([]string, len(selections))
-				copy(newselect, selections)
-			} else {
-				newselect = []string{}
-			}
+			fmt.Println()
+		}
+	}
+}
 
-			// select for most recent records if any
-			if len(apiOutput) > 0 {
-				newselect = append(newselect, fmt.Sprintf("record_id>%v", apiOutput[len(apiOutput)-1].Id))
-				if s, err := getSelectionString(newselect); err != nil {
-					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, "%v", err)
-				} else {
-					url_s = fmt.Sprintf("eventlog?%v", s)
-				}
-			}
-			time.Sleep(1 * time.Second)
+// The formats supported by the export command.
+const (
+	EXPORT_FORMAT_JSONL = "jsonl"
+	EXPORT_FORMAT_CSV   = "csv"
+)
+
+// Export the event logs to a file, or to stdout if the file name is empty, so that they can be attached to a support
+// ticket or processed by other tools. In the jsonl format each line is an event log in the same form as 'hzn eventlog
+// list -l'. In the csv format the event source is written as a json string.
+func Export(all bool, selections []string, format string, fileName string) {
+
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if format != EXPORT_FORMAT_JSONL && format != EXPORT_FORMAT_CSV {
+		cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("The export format %v is not supported, the format must be %v or %v.", format, EXPORT_FORMAT_JSONL, EXPORT_FORMAT_CSV))
+	}
+
+	url_s, _ := getEventLogUrl(all, selections)
+	apiOutput := make([]persistence.EventLogRaw, 0)
+	cliutils.HorizonGet(url_s, []int{200}, &apiOutput, false)
+
+	out := os.Stdout
+	if fileName != "" {
+		file, err := os.Create(fileName)
+		if err != nil {
+			cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("failed to create file %v. %v", fileName, err))
+		}
+		defer file.Close()
+		out = file
+	}
 
+	if err := writeEventLogs(out, apiOutput, format); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("failed to export the event logs. %v", err))
+	}
+
+	if fileName != "" {
+		msgPrinter.Printf("Exported %v event logs to file %v.", len(apiOutput), fileName)
+		msgPrinter.Println()
+	}
+}
+
+func writeEventLogs(w io.Writer, eventLogs []persistence.EventLogRaw, format string) error {
+
+	var csvWriter *csv.Writer
+	if format == EXPORT_FORMAT_CSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write([]string{"record_id", "timestamp", "severity", "message", "event_code", "source_type", "event_source"}); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range eventLogs {
+		source := ""
+		if v.Source != nil {
+			source = string(*v.Source)
+		}
+
+		if csvWriter != nil {
+			if err := csvWriter.Write([]string{v.Id, cliutils.ConvertTime(v.Timestamp), v.Severity, v.Message, v.EventCode, v.SourceType, source}); err != nil {
+				return err
+			}
 		} else {
-			break
+			el := EventLog{Id: v.Id, Timestamp: cliutils.ConvertTime(v.Timestamp), Severity: v.Severity, Message: v.Message, EventCode: v.EventCode, SourceType: v.SourceType, Source: v.Source}
+			if jsonBytes, err := json.Marshal(el); err != nil {
+				return err
+			} else if _, err := fmt.Fprintf(w, "%s\n", jsonBytes); err != nil {
+				return err
+			}
 		}
 	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return nil
 }
 
 func ListSurfaced(long bool) {
@@ -153,6 +153,13 @@ Environment Variables:
 	agbotCacheServedOrg := agbotCacheCmd.Command("servedorg | sorg", msgPrinter.Sprintf("List served pattern orgs and deployment policy orgs.")).Alias("sorg").Alias("servedorg")
 	agbotCacheServedOrgList := agbotCacheServedOrg.Command("list | ls", msgPrinter.Sprintf("Display served pattern orgs and deployment policy orgs.")).Alias("ls").Alias("list")
 
+	agbotDeployPolCmd := agbotCmd.Command("deploymentpol | dep", msgPrinter.Sprintf("Display the deployment status of the deployment policies and patterns this Horizon agreement bot is working with.")).Alias("dep").Alias("deploymentpol")
+	agbotDeployPolStatusCmd := agbotDeployPolCmd.Command("status", msgPrinter.Sprintf("Display the number of nodes matched, proposed, agreed, executing and failed for each deployment policy and pattern. When a name is given, also display the failed nodes and the reasons they failed."))
+	agbotDeployPolStatusName := agbotDeployPolStatusCmd.Arg("name", msgPrinter.Sprintf("The deployment policy, or the pattern with --pattern, in the form org/name.")).String()
+	agbotDeployPolStatusOrg := agbotDeployPolStatusCmd.Flag("org", msgPrinter.Sprintf("The organization of the deployment policy or pattern, if it is not part of the name. If this flag is not specified, the value is taken from the HZN_ORG_ID environment variable.")).Short('o').String()
+	agbotDeployPolStatusPattern := agbotDeployPolStatusCmd.Flag("pattern", msgPrinter.Sprintf("The name is a pattern instead of a deployment policy.")).Short('p').Bool()
+	agbotDeployPolStatusErrors := agbotDeployPolStatusCmd.Flag("errors", msgPrinter.Sprintf("Check the node errors in the Exchange of each executing node. Nodes that report errors for the deployment are failed.")).Short('e').Bool()
+	agbotDeployPolStatusLong := agbotDeployPolStatusCmd.Flag("long", msgPrinter.Sprintf("Display all the nodes, not just the failed nodes.")).Short('l').Bool()
 	agbotListCmd := agbotCmd.Command("list | ls", msgPrinter.Sprintf("Display general information about this Horizon agbot node.")).Alias("ls").Alias("list")
 	agbotPolicyCmd := agbotCmd.Command("policy | pol", msgPrinter.Sprintf("List the policies this Horizon agreement bot hosts.")).Alias("pol").Alias("policy")
 	agbotPolicyListCmd := agbotPolicyCmd.Command("list | ls", msgPrinter.Sprintf("List policies this Horizon agreement bot hosts.")).Alias("ls").Alias("list")
@@ -275,10 +282,15 @@ Environment Variables:
 
 	eventlogCmd := app.Command("eventlog | ev", msgPrinter.Sprintf("List the event logs for the current or all registrations.")).Alias("ev").Alias("eventlog")
 	eventlogListCmd := eventlogCmd.Command("list | ls", msgPrinter.Sprintf("List the event logs for the current or all registrations.")).Alias("ls").Alias("list")
-	listTail := eventlogListCmd.Flag("tail", msgPrinter.Sprintf("Continuously polls the event log to display the most recent records, similar to tail -F behavior.")).Short('f').Bool()
+	listTail := eventlogListCmd.Flag("tail", msgPrinter.Sprintf("Follows the event log and displays new records as soon as they are saved, similar to tail -f behavior.")).Short('f').Bool()
 	listAllEventlogs := eventlogListCmd.Flag("all", msgPrinter.Sprintf("List all the event logs including the previous registrations.")).Short('a').Bool()
 	listDetailedEventlogs := eventlogListCmd.Flag("long", msgPrinter.Sprintf("List event logs with details.")).Short('l').Bool()
 	listSelectedEventlogs := eventlogListCmd.Flag("select", msgPrinter.Sprintf("Selection string. This flag can be repeated which means 'AND'. Each flag should be in the format of attribute=value, attribute~value, \"attribute>value\" or \"attribute<value\", where '~' means contains. The common attribute names are timestamp, severity, message, event_code, source_type, agreement_id, service_url etc. Use the '-l' flag to see all the attribute names.")).Short('s').Strings()
+	eventlogExportCmd := eventlogCmd.Command("export", msgPrinter.Sprintf("Export the event logs for the current or all registrations to a file in JSON Lines or CSV format, for example to attach them to a support ticket."))
+	exportAllEventlogs := eventlogExportCmd.Flag("all", msgPrinter.Sprintf("Export all the event logs including the previous registrations.")).Short('a').Bool()
+	exportSelectedEventlogs := eventlogExportCmd.Flag("select", msgPrinter.Sprintf("Selection string. This flag can be repeated which means 'AND'. Each flag should be in the format of attribute=value, attribute~value, \"attribute>value\" or \"attribute<value\", where '~' means contains. The common attribute names are timestamp, severity, message, event_code, source_type, agreement_id, service_url etc.")).Short('s').Strings()
+	exportEventlogsFormat := eventlogExportCmd.Flag("format", msgPrinter.Sprintf("The format of the exported event logs, jsonl or csv.")).Default("jsonl").Enum("jsonl", "csv")
+	exportEventlogsFile := eventlogExportCmd.Flag("file", msgPrinter.Sprintf("The file to write the event logs to. If omitted, the event logs are written to stdout.")).Short('f').String()
 	surfaceErrorsEventlogs := eventlogCmd.Command("surface | sf", msgPrinter.Sprintf("List all the active errors that will be shared with the Exchange if the node is online.")).Alias("sf").Alias("surface")
 	surfaceErrorsEventlogsLong := surfaceErrorsEventlogs.Flag("long", msgPrinter.Sprintf("List the full event logs of the surface errors.")).Short('l').Bool()
 
@@ -592,6 +604,10 @@ Environment Variables:
 
 	nodeCmd := app.Command("node", msgPrinter.Sprintf("List and manage general information about this Horizon edge node."))
 	nodeListCmd := nodeCmd.Command("list | ls", msgPrinter.Sprintf("Display general information about this Horizon edge node.")).Alias("list").Alias("ls")
+	nodeDiagnoseCmd := nodeCmd.Command("diagnose", msgPrinter.Sprintf("Collect the state of this Horizon edge node, its agreements, services, event logs and service logs into a diagnostic bundle. Secrets and tokens are redacted."))
+	nodeDiagnoseFile := nodeDiagnoseCmd.Flag("file", msgPrinter.Sprintf("The name of the gzipped tar file to write the bundle to. The default is horizon-diagnostics-<time>.tar.gz in the current directory.")).Short('f').String()
+	nodeDiagnosePublish := nodeDiagnoseCmd.Flag("publish", msgPrinter.Sprintf("Publish the bundle as an object in the Horizon Model Management Service of the node's org instead of writing it to a file, so that the org admin can download it.")).Bool()
+	nodeDiagnoseLines := nodeDiagnoseCmd.Flag("lines", msgPrinter.Sprintf("The number of lines from the end of each service log to include. The default is 500.")).Short('n').Default("-1").Int()
 
 	policyCmd := app.Command("policy | pol", msgPrinter.Sprintf("List and manage policy for this Horizon edge node.")).Alias("pol").Alias("policy")
 	policyListCmd := policyCmd.Command("list | ls", msgPrinter.Sprintf("Display this edge node's policy.")).Alias("ls").Alias("list")
@@ -640,12 +656,20 @@ Environment Variables:
 	suspendServiceVersion := serviceConfigStateSuspendCmd.Arg("version", msgPrinter.Sprintf("The version of the service that should be suspended. If omitted, all the versions for this service will be suspended.")).String()
 	forceSuspendService := serviceConfigStateSuspendCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 	serviceLogCmd := serviceCmd.Command("log", msgPrinter.Sprintf("Show the container logs for a service."))
-	logServiceName := serviceLogCmd.Arg("service", msgPrinter.Sprintf("The name of the service whose log records should be displayed. The service name is the same as the url field of a service definition. Displays log records similar to tail behavior and returns .")).Required().String()
+	logServiceName := serviceLogCmd.Arg("service", msgPrinter.Sprintf("The name of the service whose log records should be displayed. The service name is the same as the url field of a service definition. The log records are read through the Horizon agent, so they can be displayed with any log driver that docker can read logs from.")).Required().String()
 	logServiceVersion := serviceLogCmd.Flag("version", msgPrinter.Sprintf("The version of the service.")).Short('V').String()
 	logServiceContainerName := serviceLogCmd.Flag("container", msgPrinter.Sprintf("The name of the container within the service whose log records should be displayed.")).Short('c').String()
 	logTail := serviceLogCmd.Flag("tail", msgPrinter.Sprintf("Continuously polls the service's logs to display the most recent records, similar to tail -F behavior.")).Short('f').Bool()
+	logLines := serviceLogCmd.Flag("lines", msgPrinter.Sprintf("The number of log records to display from the end of the log. The default is all of them.")).Short('n').Default("-1").Int()
+	logSince := serviceLogCmd.Flag("since", msgPrinter.Sprintf("Only display the log records written since this time. The time can be a unix time in seconds, an RFC3339 time, or a duration before now, such as 10m.")).String()
+	logTimestamps := serviceLogCmd.Flag("timestamps", msgPrinter.Sprintf("Show the time each log record was written.")).Short('t').Bool()
 	serviceListCmd := serviceCmd.Command("list | ls", msgPrinter.Sprintf("List the services variable configuration that has been done on this Horizon edge node.")).Alias("ls").Alias("list")
 	serviceRegisteredCmd := serviceCmd.Command("registered | reg", msgPrinter.Sprintf("List the services that are currently registered on this Horizon edge node.")).Alias("reg").Alias("registered")
+	serviceStatsCmd := serviceCmd.Command("stats", msgPrinter.Sprintf("Show the recent CPU, memory, network and disk usage of the services running on this Horizon edge node."))
+	statsServiceName := serviceStatsCmd.Arg("service", msgPrinter.Sprintf("The name of the service whose resource usage should be displayed. The service name is the same as the url field of a service definition. If omitted, all the services are displayed.")).String()
+	statsServiceOrg := serviceStatsCmd.Flag("org", msgPrinter.Sprintf("The organization of the service.")).Short('o').String()
+	statsServiceVersion := serviceStatsCmd.Flag("version", msgPrinter.Sprintf("The version of the service.")).Short('V').String()
+	statsLong := serviceStatsCmd.Flag("long", msgPrinter.Sprintf("Also show the resource usage samples that the summaries are computed from.")).Short('l').Bool()
 
 	statusCmd := app.Command("status", msgPrinter.Sprintf("Display the current horizon internal status for the node."))
 	statusLong := statusCmd.Flag("long", msgPrinter.Sprintf("Show detailed status")).Short('l').Bool()
@@ -1107,6 +1131,8 @@ Environment Variables:
 		key.Remove(*keyDelName)
 	case nodeListCmd.FullCommand():
 		node.List()
+	case nodeDiagnoseCmd.FullCommand():
+		node.Diagnose(*nodeDiagnoseFile, *nodeDiagnosePublish, *nodeDiagnoseLines)
 	case policyListCmd.FullCommand():
 		policy.List()
 	case policyNewCmd.FullCommand():
@@ -1146,9 +1172,11 @@ Environment Variables:
 	case serviceListCmd.FullCommand():
 		service.List()
 	case serviceLogCmd.FullCommand():
-		service.Log(*logServiceName, *logServiceVersion, *logServiceContainerName, *logTail)
+		service.Log(*logServiceName, *logServiceVersion, *logServiceContainerName, *logTail, *logLines, *logSince, *logTimestamps)
 	case serviceRegisteredCmd.FullCommand():
 		service.Registered()
+	case serviceStatsCmd.FullCommand():
+		service.Stats(*statsServiceName, *statsServiceOrg, *statsServiceVersion, *statsLong)
 	case serviceConfigStateListCmd.FullCommand():
 		service.ListConfigState()
 	case serviceConfigStateSuspendCmd.FullCommand():
@@ -1161,6 +1189,8 @@ Environment Variables:
 		status.DisplayStatus(*statusLong, false)
 	case eventlogListCmd.FullCommand():
 		eventlog.List(*listAllEventlogs, *listDetailedEventlogs, *listSelectedEventlogs, *listTail)
+	case eventlogExportCmd.FullCommand():
+		eventlog.Export(*exportAllEventlogs, *exportSelectedEventlogs, *exportEventlogsFormat, *exportEventlogsFile)
 	case surfaceErrorsEventlogs.FullCommand():
 		eventlog.ListSurfaced(*surfaceErrorsEventlogsLong)
 	case devServiceNewCmd.FullCommand():
@@ -1185,6 +1215,8 @@ Environment Variables:
 		agreementbot.AgreementCancel(*agbotCancelAgreementId, *agbotCancelAllAgreements)
 	case agbotListCmd.FullCommand():
 		agreementbot.List()
+	case agbotDeployPolStatusCmd.FullCommand():
+		agreementbot.DeploymentStatus(*cliutils.WithDefaultEnvVar(agbotDeployPolStatusOrg, "HZN_ORG_ID"), *agbotDeployPolStatusName, *agbotDeployPolStatusPattern, *agbotDeployPolStatusErrors, *agbotDeployPolStatusLong)
 	case agbotPolicyListCmd.FullCommand():
 		agreementbot.PolicyList(*agbotPolicyOrg, *agbotPolicyName)
 	case utilSignCmd.FullCommand():
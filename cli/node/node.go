@@ -1,6 +1,7 @@
 package node
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/open-horizon/anax/api"
@@ -9,7 +10,10 @@ import (
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/version"
+	"io/ioutil"
+	"net/http"
 	"strings"
+	"time"
 )
 
 type Configstate struct {
@@ -87,6 +91,41 @@ func List() {
 	fmt.Printf("%s\n", jsonBytes) //todo: is there a way to output with json syntax highlighting like jq does?
 }
 
+// Diagnose collects the diagnostic bundle of the node into a local file, or publishes it in the model management
+// service so that the org admin can download it.
+func Diagnose(fileName string, publish bool, logLines int) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	urlSuffix := "node/diagnostics"
+	if logLines >= 0 {
+		urlSuffix += fmt.Sprintf("?%v=%v", api.DIAGNOSTICS_LOG_LINES, logLines)
+	}
+
+	if publish {
+		_, respBody, _ := cliutils.HorizonPutPost(http.MethodPost, urlSuffix, []int{201}, "", true)
+		obj := api.DiagnosticsObject{}
+		if err := json.Unmarshal([]byte(respBody), &obj); err != nil {
+			cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to unmarshal the published diagnostic bundle: %v", err))
+		}
+		msgPrinter.Printf("Diagnostic bundle published as object type %v, object id %v in org %v (%v bytes). Use 'hzn mms object download -t %v -i %v' to get it.", obj.ObjectType, obj.ObjectID, obj.Org, obj.Size, obj.ObjectType, obj.ObjectID)
+		msgPrinter.Println()
+		return
+	}
+
+	if fileName == "" {
+		fileName = fmt.Sprintf("horizon-diagnostics-%v.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	// The bundle is read before the file is created, so that no empty file is left behind when the agent fails.
+	bundle := new(bytes.Buffer)
+	cliutils.HorizonGetStream(urlSuffix, []int{200}, bundle)
+	if err := ioutil.WriteFile(fileName, bundle.Bytes(), 0600); err != nil {
+		cliutils.Fatal(cliutils.FILE_IO_ERROR, msgPrinter.Sprintf("failed to write %v: %v", fileName, err))
+	}
+	msgPrinter.Printf("Diagnostic bundle written to %v", fileName)
+	msgPrinter.Println()
+}
+
 func Version() {
 	// Show hzn version
 	msgPrinter := i18n.GetMessagePrinter()
@@ -5,14 +5,15 @@ import (
 	"fmt"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/cli/cliutils"
-	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/semanticversion"
 	"net/http"
-	"runtime"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -50,7 +51,9 @@ func List() {
 	fmt.Printf("%s\n", jsonBytes)
 }
 
-func Log(serviceName string, serviceVersion, containerName string, tailing bool) {
+// Display the log of a container of a service running on the node. The log is read through the agent, which reads it
+// with the docker logs API, or from the service's pod on a cluster.
+func Log(serviceName string, serviceVersion, containerName string, tailing bool, tailLines int, since string, timestamps bool) {
 	msgPrinter := i18n.GetMessagePrinter()
 
 	// if node is not registered
@@ -67,12 +70,10 @@ func Log(serviceName string, serviceVersion, containerName string, tailing bool)
 	runningServices := api.AllServices{}
 
 	cliutils.HorizonGet("service", []int{200}, &runningServices, false)
-	// Search the list of services to find one that matches the input service name. The service's instance Id
-	// is what appears in the syslog, so we need to save that.
+	// Search the list of services to find one that matches the input service name.
 	serviceFound := false
 	var serviceInstanceFound *api.MicroserviceInstanceOutput
 	var instanceId string
-	var msdefId string
 	org, name := cutil.SplitOrgSpecUrl(refUrl)
 	for _, serviceInstance := range runningServices.Instances["active"] {
 		if (serviceVersion == "" || serviceVersion == serviceInstance.Version) {
@@ -88,7 +89,6 @@ func Log(serviceName string, serviceVersion, containerName string, tailing bool)
 	}
 	if serviceFound {
 		instanceId = serviceInstanceFound.InstanceId
-		msdefId = serviceInstanceFound.MicroserviceDefId
 		msgPrinter.Printf("Found service %v with service id %v.", serviceInstanceFound.SpecRef, instanceId)
 		msgPrinter.Println()
 	} else {
@@ -99,61 +99,39 @@ func Log(serviceName string, serviceVersion, containerName string, tailing bool)
 		}
 	}
 
-	// Check service's log-driver to read logs from correct place
-	containerFound := false
-	var nonDefaultLogDriverUsed bool
-	for _, def := range runningServices.Definitions["active"] {
-		if def.Id == msdefId {
-			if def.Deployment != "" {
-				deployment := &containermessage.DeploymentDescription{}
-				if err := json.Unmarshal([]byte(def.Deployment), deployment); err != nil {
-					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Deployment unmarshalling error: %v", err))
-				}
-
-				if len(deployment.Services) > 1 && containerName == "" {
-					cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Service definition %v consists of more than one container. Please specify the container name using the -c flag.", serviceName))
-				}
-				for deployedContainerName, service := range deployment.Services {
-					if containerName == deployedContainerName || (containerName == "" && len(deployment.Services) == 1) {
-						var err error
-						if nonDefaultLogDriverUsed, err = cliutils.ChekServiceLogPossibility(service.LogDriver); err != nil {
-							cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("Service logs are unavailable: %v", err))
-						}
-						containerName = deployedContainerName
-						containerFound = true
-						break
-					}
-				}
-			}
-			break
-		}
-	}
-	if !containerFound && containerName != "" {
-		if serviceVersion == "" {
-			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Container %v is not running as part of service %v.", containerName, serviceName))
-		} else {
-			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Container %v is not running as part of service %v version.", containerName, serviceName, serviceVersion))
-		}
-	} else if !containerFound {
-		if serviceVersion == "" {
-			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Could not find service %v running on the node.", serviceName))
-		} else {
-			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("Could not find service %v version %v running on the node.", serviceName, serviceVersion))
-		}
+	if containerName == "" {
+		msgPrinter.Printf("Displaying log messages for service %v with service id %v.", serviceInstanceFound.SpecRef, instanceId)
 	} else {
-		msgPrinter.Printf("Displaying log messages of container %v for service %v with service id %v.", containerName, name, instanceId)
+		msgPrinter.Printf("Displaying log messages of container %v for service %v with service id %v.", containerName, serviceInstanceFound.SpecRef, instanceId)
+	}
+	msgPrinter.Println()
+	if tailing {
+		msgPrinter.Printf("Use ctrl-C to terminate this command.")
 		msgPrinter.Println()
-		if tailing {
-			msgPrinter.Printf("Use ctrl-C to terminate this command.")
-			msgPrinter.Println()
-		}
 	}
 
-	if runtime.GOOS == "darwin" || nonDefaultLogDriverUsed {
-		cliutils.LogMac(instanceId, tailing)
-	} else {
-		cliutils.LogLinux(instanceId, tailing)
+	selections := url.Values{}
+	selections.Set(api.SERVICE_LOG_URL, serviceInstanceFound.SpecRef)
+	selections.Set(api.SERVICE_LOG_ORG, serviceInstanceFound.Org)
+	selections.Set(api.SERVICE_LOG_VERSION, serviceInstanceFound.Version)
+	selections.Set(api.SERVICE_LOG_INSTANCE, instanceId)
+	if containerName != "" {
+		selections.Set(api.SERVICE_LOG_CONTAINER, containerName)
+	}
+	if tailLines >= 0 {
+		selections.Set(api.SERVICE_LOG_TAIL, strconv.Itoa(tailLines))
+	}
+	if since != "" {
+		selections.Set(api.SERVICE_LOG_SINCE, since)
+	}
+	if tailing {
+		selections.Set(api.SERVICE_LOG_FOLLOW, "true")
 	}
+	if timestamps {
+		selections.Set(api.SERVICE_LOG_TIMESTAMPS, "true")
+	}
+
+	cliutils.HorizonGetStream("service/log?"+selections.Encode(), []int{200}, os.Stdout)
 }
 
 func Registered() {
@@ -175,6 +153,40 @@ func Registered() {
 	fmt.Printf("%s\n", jsonBytes)
 }
 
+// Display the recent resource usage of the service instances running on this node. Without the long flag, only the
+// summary of each service instance is displayed.
+func Stats(serviceName string, serviceOrg string, serviceVersion string, long bool) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	selections := url.Values{}
+	if serviceName != "" {
+		selections.Set(api.SERVICE_STATS_URL, serviceName)
+	}
+	if serviceOrg != "" {
+		selections.Set(api.SERVICE_STATS_ORG, serviceOrg)
+	}
+	if serviceVersion != "" {
+		selections.Set(api.SERVICE_STATS_VERSION, serviceVersion)
+	}
+	if !long {
+		selections.Set(api.SERVICE_STATS_SUMMARY, "true")
+	}
+
+	apiOutput := make([]api.ServiceStatsOutput, 0)
+	httpCode, _ := cliutils.HorizonGet("service/stats?"+selections.Encode(), []int{200, cliutils.ANAX_NOT_CONFIGURED_YET}, &apiOutput, false)
+	if httpCode == cliutils.ANAX_NOT_CONFIGURED_YET {
+		cliutils.Fatal(cliutils.HTTP_ERROR, msgPrinter.Sprintf(cliutils.MUST_REGISTER_FIRST))
+	}
+
+	// Convert to json and output
+	jsonBytes, err := json.MarshalIndent(apiOutput, "", cliutils.JSON_INDENT)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn service stats' output: %v", err))
+	}
+	fmt.Printf("%s\n", jsonBytes)
+}
+
 func ListConfigState() {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
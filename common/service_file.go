@@ -6,6 +6,7 @@ import (
 	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangecommon"
+	"github.com/open-horizon/anax/hardware"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/semanticversion"
 	"golang.org/x/text/message"
@@ -22,6 +23,7 @@ type AbstractServiceFile interface {
 	NeedsUserInput() bool
 	GetDeployment() interface{}
 	GetClusterDeployment() interface{}
+	GetMatchHardware() map[string]interface{}
 }
 
 // An implementation of AbstractServiceFile
@@ -90,6 +92,10 @@ func (sf *ServiceFile) GetClusterDeployment() interface{} {
 	return sf.ClusterDeployment
 }
 
+func (sf *ServiceFile) GetMatchHardware() map[string]interface{} {
+	return sf.MatchHardware
+}
+
 // Get the service type
 // Check for nil, "" and {} for deployment and cluster deployment.
 func (s *ServiceFile) GetServiceType() string {
@@ -185,12 +191,18 @@ func (sf *ServiceFile) SupportVersionRange() {
 // Varifies the existance of the dependent services.
 // Verifies consistence for the dependent service types
 // Make sure userinput and requiredServices are not supported for cluster services.
+// Verifies the matchHardware section can be evaluated.
 func ValidateService(serviceDefResolverHandler exchange.ServiceDefResolverHandler, svcFile AbstractServiceFile, msgPrinter *message.Printer) error {
 	// get default message printer if nil
 	if msgPrinter == nil {
 		msgPrinter = i18n.GetMessagePrinter()
 	}
 
+	// the known hardware requirements must have the correct format
+	if err := hardware.ValidateHardwareRequirement(svcFile.GetMatchHardware()); err != nil {
+		return fmt.Errorf(msgPrinter.Sprintf("Invalid matchHardware: %v", err))
+	}
+
 	// cluster type, userinput and requiredServices are not allowed
 	topSvcType := svcFile.GetServiceType()
 	requiredServices := svcFile.GetRequiredServices()
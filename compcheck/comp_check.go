@@ -138,13 +138,14 @@ func (p *CompCheck) UnmarshalJSON(b []byte) error {
 // The output format for the compatibility check
 type CompCheckOutput struct {
 	Compatible bool               `json:"compatible"`
-	Reason     map[string]string  `json:"reason"` // set when not compatible
+	Reason     map[string]string  `json:"reason"`        // set when not compatible
+	Geo        map[string]string  `json:"geo,omitempty"` // the results of the geo constraints, keyed by service id
 	Input      *CompCheckResource `json:"input,omitempty"`
 }
 
 func (p *CompCheckOutput) String() string {
-	return fmt.Sprintf("Compatible: %v, Reason: %v, Input: %v",
-		p.Compatible, p.Reason, p.Input)
+	return fmt.Sprintf("Compatible: %v, Reason: %v, Geo: %v, Input: %v",
+		p.Compatible, p.Reason, p.Geo, p.Input)
 
 }
 
@@ -518,6 +519,7 @@ func createCompCheckOutput(pcOutput *CompCheckOutput, privOutput *CompCheckOutpu
 	}
 
 	ccOutput.Reason = reason
+	ccOutput.Geo = pcOutput.Geo
 
 	// combine the input part
 	ccInput := CompCheckResource{}
@@ -0,0 +1,104 @@
+package compcheck
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/common"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/hardware"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"golang.org/x/text/message"
+	"sort"
+	"strings"
+)
+
+// Get the node hardware that can be known without being on the node. The built-in properties
+// of the node policy provide the number of CPUs and the memory size. The devices, CPU flags and
+// USB devices can only be verified by the agent when it receives a proposal.
+func GetNodeHardwareFromPolicy(nodePolicy *externalpolicy.ExternalPolicy) *hardware.HardwareInfo {
+	hw := new(hardware.HardwareInfo)
+	if nodePolicy == nil {
+		return hw
+	}
+
+	if nodePolicy.Properties.HasProperty(externalpolicy.PROP_NODE_MEMORY) {
+		if prop, err := nodePolicy.Properties.GetProperty(externalpolicy.PROP_NODE_MEMORY); err == nil {
+			if mem, ok := getNumericPropValue(prop.Value); ok && mem >= 0 {
+				memMB := uint64(mem)
+				hw.MemoryMB = &memMB
+			}
+		}
+	}
+
+	if nodePolicy.Properties.HasProperty(externalpolicy.PROP_NODE_CPU) {
+		if prop, err := nodePolicy.Properties.GetProperty(externalpolicy.PROP_NODE_CPU); err == nil {
+			if cpu, ok := getNumericPropValue(prop.Value); ok && cpu >= 0 {
+				cpus := int(cpu)
+				hw.CPUs = &cpus
+			}
+		}
+	}
+
+	return hw
+}
+
+// Check if the hardware requirements (matchHardware) of the given top level service and its dependent
+// services are satisfied by the node hardware. The hardware requirements only apply to device type nodes.
+// It returns false and the reason when not compatible.
+func CheckHardwareCompatibility(nodeType string, nodeHw *hardware.HardwareInfo, topSvc common.AbstractServiceFile, topSvcId string,
+	depServices map[string]exchange.ServiceDefinition, msgPrinter *message.Printer) (bool, string, error) {
+
+	if msgPrinter == nil {
+		msgPrinter = i18n.GetMessagePrinter()
+	}
+
+	if nodeType == persistence.DEVICE_TYPE_CLUSTER {
+		return true, "", nil
+	}
+
+	matcher := hardware.NewHardwareMatcher(nodeHw)
+
+	reasons := []string{}
+	if topSvc != nil {
+		if match, reason, err := matcher.Match(topSvc.GetMatchHardware()); err != nil {
+			return false, "", NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Failed to evaluate the matchHardware for service %v. %v", topSvcId, err)), COMPCHECK_VALIDATION_ERROR)
+		} else if !match {
+			reasons = append(reasons, msgPrinter.Sprintf("Service %v %v.", topSvcId, reason))
+		}
+	}
+
+	// sort the dependent service ids so that the reason is stable
+	depIds := make([]string, 0, len(depServices))
+	for sId, _ := range depServices {
+		depIds = append(depIds, sId)
+	}
+	sort.Strings(depIds)
+
+	for _, sId := range depIds {
+		sDef := depServices[sId]
+		if match, reason, err := matcher.Match(sDef.MatchHardware); err != nil {
+			return false, "", NewCompCheckError(fmt.Errorf(msgPrinter.Sprintf("Failed to evaluate the matchHardware for service %v. %v", sId, err)), COMPCHECK_VALIDATION_ERROR)
+		} else if !match {
+			reasons = append(reasons, msgPrinter.Sprintf("Dependent service %v %v.", sId, reason))
+		}
+	}
+
+	if len(reasons) != 0 {
+		return false, strings.Join(reasons, " "), nil
+	}
+	return true, "", nil
+}
+
+// The numeric property values are float64 when they come from the exchange or from a policy file.
+func getNumericPropValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,65 @@
+// +build unit
+
+package compcheck
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/hardware"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_GetNodeHardwareFromPolicy(t *testing.T) {
+	hw := GetNodeHardwareFromPolicy(nil)
+	assert.Nil(t, hw.MemoryMB)
+	assert.Nil(t, hw.CPUs)
+
+	propList := new(externalpolicy.PropertyList)
+	propList.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_NODE_MEMORY, float64(2048)), false)
+	propList.Add_Property(externalpolicy.Property_Factory(externalpolicy.PROP_NODE_CPU, float64(4)), false)
+	nodePol := &externalpolicy.ExternalPolicy{Properties: *propList}
+
+	hw = GetNodeHardwareFromPolicy(nodePol)
+	assert.Equal(t, uint64(2048), *hw.MemoryMB)
+	assert.Equal(t, 4, *hw.CPUs)
+	assert.Nil(t, hw.Devices)
+}
+
+func Test_CheckHardwareCompatibility(t *testing.T) {
+	nodeHw := hardware.NewHardwareInfo(1024, 2)
+
+	topSvc := createService("cpu", "mycomp", "1.0.0", "amd64", "{}", nil)
+	topSvc.MatchHardware = map[string]interface{}{hardware.HW_MIN_MEMORY: float64(512)}
+	depSvcs := map[string]exchange.ServiceDefinition{
+		"mycomp/gps_1.0.0_amd64": exchange.ServiceDefinition{URL: "gps", Version: "1.0.0", Arch: "amd64",
+			MatchHardware: exchange.HardwareRequirement{hardware.HW_MIN_CPUS: float64(2), hardware.HW_DEVICES: []interface{}{"/dev/ttyUSB0"}}},
+	}
+
+	// the devices are unknown so they are not checked
+	compatible, reason, err := CheckHardwareCompatibility(persistence.DEVICE_TYPE_DEVICE, nodeHw, &topSvc, "mycomp/cpu_1.0.0_amd64", depSvcs, nil)
+	assert.Nil(t, err)
+	assert.True(t, compatible)
+	assert.Equal(t, "", reason)
+
+	// not enough memory for the top level service and not enough cpus for the dependent
+	topSvc.MatchHardware[hardware.HW_MIN_MEMORY] = float64(4096)
+	depSvcs["mycomp/gps_1.0.0_amd64"].MatchHardware[hardware.HW_MIN_CPUS] = float64(8)
+	compatible, reason, err = CheckHardwareCompatibility(persistence.DEVICE_TYPE_DEVICE, nodeHw, &topSvc, "mycomp/cpu_1.0.0_amd64", depSvcs, nil)
+	assert.Nil(t, err)
+	assert.False(t, compatible)
+	assert.True(t, strings.Contains(reason, "mycomp/cpu_1.0.0_amd64 requires 4096 MB of memory"), reason)
+	assert.True(t, strings.Contains(reason, "mycomp/gps_1.0.0_amd64 requires 8 CPUs"), reason)
+
+	// hardware requirements do not apply to the cluster nodes
+	compatible, _, err = CheckHardwareCompatibility(persistence.DEVICE_TYPE_CLUSTER, nodeHw, &topSvc, "mycomp/cpu_1.0.0_amd64", depSvcs, nil)
+	assert.Nil(t, err)
+	assert.True(t, compatible)
+
+	// wrong format
+	topSvc.MatchHardware[hardware.HW_MIN_MEMORY] = "lots"
+	_, _, err = CheckHardwareCompatibility(persistence.DEVICE_TYPE_DEVICE, nodeHw, &topSvc, "mycomp/cpu_1.0.0_amd64", nil, nil)
+	assert.NotNil(t, err)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/semanticversion"
 	"golang.org/x/text/message"
+	"strings"
 )
 
 // The input format for the policy check
@@ -156,10 +157,15 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 	top_services := []common.AbstractServiceFile{}
 
 	msg_incompatible := msgPrinter.Sprintf("Policy Incompatible")
+	msg_hw_incompatible := msgPrinter.Sprintf("Hardware Incompatible")
 	msg_compatible := msgPrinter.Sprintf("Compatible")
 
+	// the node hardware known from the node built-in properties
+	nodeHw := GetNodeHardwareFromPolicy(resources.NodePolicy)
+
 	// go through all the workloads and check if compatible or not
 	messages := map[string]string{}
+	geoResults := map[string]string{}
 	overall_compatible := false
 	for _, workload := range bPolicy.Workloads {
 
@@ -214,9 +220,22 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 					}
 					if compatible {
 						// policy compatibility check
-						compatible, reason, _, _, err1 = CheckPolicyCompatiblility(nPolicy, bPolicy, mergedServicePol, resources.NodeArch, msgPrinter)
+						var producerPol, consumerPol *policy.Policy
+						compatible, reason, producerPol, consumerPol, err1 = CheckPolicyCompatiblility(nPolicy, bPolicy, mergedServicePol, resources.NodeArch, msgPrinter)
+						if err1 != nil {
+							return nil, err1
+						} else if geo := GeoConstraintResults(producerPol, consumerPol); geo != "" {
+							geoResults[sId] = geo
+						}
+					}
+					msg_reason := msg_incompatible
+					if compatible {
+						// hardware compatibility check
+						compatible, reason, err1 = CheckHardwareCompatibility(resources.NodeType, nodeHw, topSvcDef, sId, depSvcDefs, msgPrinter)
 						if err1 != nil {
 							return nil, err1
+						} else if !compatible {
+							msg_reason = msg_hw_incompatible
 						}
 					}
 					if compatible {
@@ -224,10 +243,10 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 						if checkAllSvcs {
 							messages[sId] = msg_compatible
 						} else {
-							return NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), nil
+							return setGeoResults(NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), geoResults), nil
 						}
 					} else {
-						messages[sId] = fmt.Sprintf("%v: %v", msg_incompatible, reason)
+						messages[sId] = fmt.Sprintf("%v: %v", msg_reason, reason)
 					}
 				}
 			} else {
@@ -259,9 +278,22 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 							}
 							if compatible {
 								// policy compatibility check
-								compatible, reason, _, _, err = CheckPolicyCompatiblility(nPolicy, bPolicy, mergedServicePol, resources.NodeArch, msgPrinter)
+								var producerPol, consumerPol *policy.Policy
+								compatible, reason, producerPol, consumerPol, err = CheckPolicyCompatiblility(nPolicy, bPolicy, mergedServicePol, resources.NodeArch, msgPrinter)
+								if err != nil {
+									return nil, err
+								} else if geo := GeoConstraintResults(producerPol, consumerPol); geo != "" {
+									geoResults[sId] = geo
+								}
+							}
+							msg_reason := msg_incompatible
+							if compatible {
+								// hardware compatibility check
+								compatible, reason, err = CheckHardwareCompatibility(resources.NodeType, nodeHw, topSvcDef, sId, depSvcDefs, msgPrinter)
 								if err != nil {
 									return nil, err
+								} else if !compatible {
+									msg_reason = msg_hw_incompatible
 								}
 							}
 							if compatible {
@@ -269,10 +301,10 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 								if checkAllSvcs {
 									messages[sId] = msg_compatible
 								} else {
-									return NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), nil
+									return setGeoResults(NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), geoResults), nil
 								}
 							} else {
-								messages[sId] = fmt.Sprintf("%v: %v", msg_incompatible, reason)
+								messages[sId] = fmt.Sprintf("%v: %v", msg_reason, reason)
 							}
 						}
 					}
@@ -311,21 +343,34 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 				}
 				if compatible {
 					// policy compatibility check
-					compatible, reason, _, _, err1 = CheckPolicyCompatiblility(nPolicy, bPolicy, mergedServicePol, resources.NodeArch, msgPrinter)
+					var producerPol, consumerPol *policy.Policy
+					compatible, reason, producerPol, consumerPol, err1 = CheckPolicyCompatiblility(nPolicy, bPolicy, mergedServicePol, resources.NodeArch, msgPrinter)
 					if err1 != nil {
 						return nil, err1
+					} else if geo := GeoConstraintResults(producerPol, consumerPol); geo != "" {
+						geoResults[sId] = geo
 					}
 				}
 			}
+			msg_reason := msg_incompatible
+			if compatible {
+				// hardware compatibility check
+				compatible, reason, err1 = CheckHardwareCompatibility(resources.NodeType, nodeHw, topSvcDef, sId, depSvcDefs, msgPrinter)
+				if err1 != nil {
+					return nil, err1
+				} else if !compatible {
+					msg_reason = msg_hw_incompatible
+				}
+			}
 			if compatible {
 				overall_compatible = true
 				if checkAllSvcs {
 					messages[sId] = msg_compatible
 				} else {
-					return NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), nil
+					return setGeoResults(NewCompCheckOutput(true, map[string]string{sId: msg_compatible}, resources), geoResults), nil
 				}
 			} else {
-				messages[sId] = fmt.Sprintf("%v: %v", msg_incompatible, reason)
+				messages[sId] = fmt.Sprintf("%v: %v", msg_reason, reason)
 			}
 		}
 	}
@@ -335,7 +380,7 @@ func policyCompatible(getDeviceHandler exchange.DeviceHandler,
 	resources.Service = top_services
 
 	if messages != nil && len(messages) != 0 {
-		return NewCompCheckOutput(overall_compatible, messages, resources), nil
+		return setGeoResults(NewCompCheckOutput(overall_compatible, messages, resources), geoResults), nil
 	} else {
 		// If we get here, it means that no workload is found in the bp that matches the required node arch.
 		if resources.NodeArch != "" {
@@ -393,6 +438,30 @@ func CheckPolicyCompatiblility(nodePolicy *policy.Policy, businessPolicy *policy
 	}
 }
 
+// Returns the results of the geo constraints of each policy for the properties of the other policy, for example how far
+// the node is from the center of a within constraint. The policies are the ones returned by CheckPolicyCompatiblility.
+func GeoConstraintResults(producerPol *policy.Policy, consumerPol *policy.Policy) string {
+	if producerPol == nil || consumerPol == nil {
+		return ""
+	}
+	results := consumerPol.Constraints.GeoDetails(producerPol.Properties)
+	results = append(results, producerPol.Constraints.GeoDetails(consumerPol.Properties)...)
+	return strings.Join(results, ", ")
+}
+
+// Add the geo constraint results of the services in the reason of the output.
+func setGeoResults(output *CompCheckOutput, geoResults map[string]string) *CompCheckOutput {
+	for sId, geo := range geoResults {
+		if _, ok := output.Reason[sId]; ok {
+			if output.Geo == nil {
+				output.Geo = map[string]string{}
+			}
+			output.Geo[sId] = geo
+		}
+	}
+	return output
+}
+
 // add node arch property to the node policy. node arch can be empty
 func addNodeArchToPolicy(nodePolicy *policy.Policy, nodeArch string, msgPrinter *message.Printer) (*policy.Policy, error) {
 	// get default message printer if nil
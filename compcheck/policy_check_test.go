@@ -425,6 +425,26 @@ func Test_policyCompatible_with_Pols(t *testing.T) {
 		t.Errorf("The reason for service %v shoud be %v, but got: %v", sId2, COMPATIBLE, compOutput.Reason[sId2])
 	}
 
+	// compatible, with the results of the geo constraints
+	geoInput := input0
+	geoInput.NodePolicy = createExternalPolicy(map[string]string{"prop3": "val3", "prop4": "some value", "location": "40.7357,-74.1724"}, []string{"prop1 == val1", "prop5 == val5"})
+	geoInput.BusinessPolicy = createBusinessPolicy(service, map[string]string{"prop1": "val1", "prop2": "val2"}, []string{"prop3 == val3", "prop4 == \"some value\"", "location within 50km of (40.7,-74.0)"})
+	geo_string := "location is 15.1km from (40.7,-74)"
+	if compOutput, err := policyCompatible(getDeviceHandler(""),
+		getNodePolicyHandler(map[string]string{}, []string{}),
+		getBusinessPolicyHandler(service, map[string]string{}, []string{}),
+		getServicePolicyHandler(map[string]string{}, []string{}),
+		getSelectedServicesHandler(nil), getServiceDefResolverHandler(),
+		&geoInput, true, msgPrinter); err != nil {
+		t.Errorf("policyCompatible should have returned nil error but got: %v", err)
+	} else if !compOutput.Compatible {
+		t.Errorf("policyCompatible should have returned compatible but got: %v", compOutput)
+	} else if len(compOutput.Geo) != 2 {
+		t.Errorf("policyCompatible should have returned 2 geo results but got : %v", compOutput.Geo)
+	} else if compOutput.Geo[sId1] != geo_string {
+		t.Errorf("The geo result for service %v shoud be %v, but got: %v", sId1, geo_string, compOutput.Geo[sId1])
+	}
+
 	// Incompatible, type mismatch
 	input0.NodeType = "cluster"
 	err_string := "Service does not have cluster deployment configuration for node type 'cluster'"
@@ -57,6 +57,10 @@ func (s *ServiceDefinition) GetClusterDeployment() interface{} {
 	return s.ClusterDeployment
 }
 
+func (s *ServiceDefinition) GetMatchHardware() map[string]interface{} {
+	return s.MatchHardware
+}
+
 type ServiceSpec struct {
 	ServiceOrgid        string `json:"serviceOrgid"`
 	ServiceUrl          string `json:"serviceUrl"`
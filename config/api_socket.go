@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Configuration for serving the agent API on a unix domain socket. Requests that change the node (POST, PUT, PATCH
+// and DELETE) on the socket are only allowed from processes running as root, as the same user as the agent, or as one
+// of the allowed users or groups, or when they carry the local bearer token in the Authorization header. On APIListen,
+// these requests must carry the local bearer token unless AllowTCPWithoutToken is set.
+type APISocketConfig struct {
+	Disable              bool     // Set to true to serve the agent API only on APIListen.
+	Path                 string   // The full path name of the unix domain socket. The default is in the code below.
+	AllowedUIDs          []uint32 // Additional users that are allowed to change the node through the socket.
+	AllowedGIDs          []uint32 // Additional groups whose members are allowed to change the node through the socket.
+	TokenFile            string   // The file holding the local bearer token. It is created with a random token when it does not exist.
+	AllowTCPWithoutToken bool     // When true, requests that change the node through APIListen do not need the local bearer token, as in earlier releases.
+}
+
+func (a *APISocketConfig) String() string {
+	return fmt.Sprintf("Disable: %v, Path: %v, AllowedUIDs: %v, AllowedGIDs: %v, TokenFile: %v, AllowTCPWithoutToken: %v", a.Disable, a.Path, a.AllowedUIDs, a.AllowedGIDs, a.TokenFile, a.AllowTCPWithoutToken)
+}
+
+// Return empty string if the agent API is not served on a unix domain socket.
+func (c *HorizonConfig) GetAPISocketPath() string {
+	if c.Edge.APISocket.Disable {
+		return ""
+	} else if filepath.IsAbs(c.Edge.APISocket.Path) {
+		return c.Edge.APISocket.Path
+	}
+	return GetDefaultAPISocketPath()
+}
+
+func (c *HorizonConfig) GetAPITokenFile() string {
+	if filepath.IsAbs(c.Edge.APISocket.TokenFile) {
+		return c.Edge.APISocket.TokenFile
+	}
+	return GetDefaultAPITokenFile()
+}
+
+// The default locations are also used by the hzn CLI, which prefers the socket when it exists.
+func GetDefaultAPISocketPath() string {
+	if p := os.Getenv(AnaxAPISocket); p != "" {
+		return p
+	}
+	return path.Join(getDefaultRunBase(), HZN_API_DOMAIN_SOCKET)
+}
+
+func GetDefaultAPITokenFile() string {
+	if p := os.Getenv(AnaxAPITokenFile); p != "" {
+		return p
+	}
+	return path.Join(getDefaultRunBase(), HZN_API_TOKEN_FILE)
+}
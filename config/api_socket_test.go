@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_default_APISocket(t *testing.T) {
+
+	os.Unsetenv(AnaxAPISocket)
+	os.Unsetenv(AnaxAPITokenFile)
+
+	testCfg := &HorizonConfig{
+		Edge: Config{
+			APISocket: APISocketConfig{},
+		},
+	}
+
+	if testCfg.GetAPISocketPath() != path.Join(getDefaultRunBase(), HZN_API_DOMAIN_SOCKET) {
+		t.Errorf("config API should return the default socket path, is %v", testCfg.GetAPISocketPath())
+	} else if testCfg.GetAPITokenFile() != path.Join(getDefaultRunBase(), HZN_API_TOKEN_FILE) {
+		t.Errorf("config API should return the default token file, is %v", testCfg.GetAPITokenFile())
+	}
+}
+
+func Test_config_APISocket(t *testing.T) {
+
+	testCfg := &HorizonConfig{
+		Edge: Config{
+			APISocket: APISocketConfig{Path: "/tmp/anax/api.sock", TokenFile: "/tmp/anax/token"},
+		},
+	}
+
+	if testCfg.GetAPISocketPath() != "/tmp/anax/api.sock" {
+		t.Errorf("config API should return the configured socket path, is %v", testCfg.GetAPISocketPath())
+	} else if testCfg.GetAPITokenFile() != "/tmp/anax/token" {
+		t.Errorf("config API should return the configured token file, is %v", testCfg.GetAPITokenFile())
+	}
+
+	testCfg.Edge.APISocket.Disable = true
+	if testCfg.GetAPISocketPath() != "" {
+		t.Errorf("config API should not return a socket path when the socket is disabled, is %v", testCfg.GetAPISocketPath())
+	}
+}
@@ -16,6 +16,8 @@ const ExchangeMessageNoDynamicPollEnvvarName = "HZN_NO_DYNAMIC_POLL"
 const OldMgmtHubCertPath = "HZN_ICP_CA_CERT_PATH"
 const ManagementHubCertPath = "HZN_MGMT_HUB_CERT_PATH"
 const AnaxAPIPort = "HZN_AGENT_PORT"
+const AnaxAPISocket = "HZN_AGENT_SOCKET"
+const AnaxAPITokenFile = "HZN_AGENT_TOKEN_FILE"
 
 type HorizonConfig struct {
 	Edge          Config
@@ -40,36 +42,52 @@ type Config struct {
 	ExchangeURL                      string
 	DefaultHTTPClientTimeoutS        uint
 	PolicyPath                       string
-	ExchangeHeartbeat                int       // Seconds between heartbeats
-	ExchangeVersionCheckIntervalM    int64     // Exchange version check interval in minutes. The default is 720. This is now deprecated with the usage of /changes API which returns exchange version on every call.
-	AgreementTimeoutS                uint64    // Number of seconds to wait before declaring agreement not finalized in blockchain
-	AgreementTimeoutScaleFactor      float64   // Time to wait before declaring an agreement did not finalize. Expressed as a scaling factor of the max heartbeat interval for this node
-	DVPrefix                         string    // When passing agreement ids into a workload container, add this prefix to the agreement id
-	RegistrationDelayS               uint64    // The number of seconds to wait after blockchain init before registering with the exchange. This is for testing initialization ONLY.
-	ExchangeMessageTTL               int       // The number of seconds the exchange will keep this message before automatically deleting it
-	ExchangeMessageDynamicPoll       bool      // Will the runtime dynamically increase the message poll interval? Default is true. Set to false to turn off dynamic message poll interval adjustments.
-	ExchangeMessagePollInterval      int       // The number of seconds the node will wait between polls to the exchange. This is the starting value, but at runtime this interval will increase if there is no message activity to reduce load on the exchange. If ExchangeMessageDynamicPoll is false, then the value of this field will never be changed by the runtime.
-	ExchangeMessagePollMaxInterval   int       // As the runtime increases the ExchangeMessagePollInterval, this value is the maximum that value can attain.
-	ExchangeMessagePollIncrement     int       // The number of seconds to increment the ExchangeMessagePollInterval when its time to increase the poll interval.
-	UserPublicKeyPath                string    // The location to store user keys uploaded through the REST API
-	ReportDeviceStatus               bool      // whether to report the device status to the exchange or not.
-	TrustCertUpdatesFromOrg          bool      // whether to trust the certs provided by the organization on the exchange or not.
-	TrustDockerAuthFromOrg           bool      // whether to turst the docker auths provided by the organization on the exchange or not.
-	ServiceUpgradeCheckIntervalS     int64     // service upgrade check interval in seconds. The default is 300 seconds.
-	MultipleAnaxInstances            bool      // multiple anax instances running on the same machine
-	DefaultServiceRetryCount         int       // the default service retry count if retries are not specified by the policy file. The default value is 2.
-	DefaultServiceRetryDuration      uint64    // the default retry duration in seconds. The next retry cycle occurs after the duration. The default value is 600
-	DefaultNodePolicyFile            string    // the default node policy file name.
-	NodeCheckIntervalS               int       // the node check interval. The default is 15 seconds.
-	NodePolicyCheckIntervalS         int       // the node policy check interval. The default is 15 seconds.
-	FileSyncService                  FSSConfig // The config for the embedded ESS sync service.
-	SurfaceErrorTimeoutS             int       // How long surfaced errors will remain active after they're created. Default is no timeout
-	SurfaceErrorCheckIntervalS       int       // Deprecated. Used to be how often the node will check for errors that are no longer active and update the exchange. Default is 15 seconds
-	SurfaceErrorAgreementPersistentS int       // How long an agreement needs to persist before it is considered persistent and the related errors are dismisse. Default is 90 seconds
-	InitialPollingBuffer             int       // the number of seconds to wait before increasing the polling interval while there is no agreement on the node.
-	MaxAgreementPrelaunchTimeM       int64     // The maximum numbers of minutes to wait for workload to start in an agreement
-	K8sCRInstallTimeoutS             int64     // The number of seconds to wait for the custom resouce to install successfully before it is considered a failure
-	SecretsManagerFilePath           string    // The filepath for the secrets manager to store secrets in the agent filesystem
+	ExchangeHeartbeat                int             // Seconds between heartbeats
+	ExchangeVersionCheckIntervalM    int64           // Exchange version check interval in minutes. The default is 720. This is now deprecated with the usage of /changes API which returns exchange version on every call.
+	AgreementTimeoutS                uint64          // Number of seconds to wait before declaring agreement not finalized in blockchain
+	AgreementTimeoutScaleFactor      float64         // Time to wait before declaring an agreement did not finalize. Expressed as a scaling factor of the max heartbeat interval for this node
+	DVPrefix                         string          // When passing agreement ids into a workload container, add this prefix to the agreement id
+	RegistrationDelayS               uint64          // The number of seconds to wait after blockchain init before registering with the exchange. This is for testing initialization ONLY.
+	ExchangeMessageTTL               int             // The number of seconds the exchange will keep this message before automatically deleting it
+	ExchangeMessageDynamicPoll       bool            // Will the runtime dynamically increase the message poll interval? Default is true. Set to false to turn off dynamic message poll interval adjustments.
+	ExchangeMessagePollInterval      int             // The number of seconds the node will wait between polls to the exchange. This is the starting value, but at runtime this interval will increase if there is no message activity to reduce load on the exchange. If ExchangeMessageDynamicPoll is false, then the value of this field will never be changed by the runtime.
+	ExchangeMessagePollMaxInterval   int             // As the runtime increases the ExchangeMessagePollInterval, this value is the maximum that value can attain.
+	ExchangeMessagePollIncrement     int             // The number of seconds to increment the ExchangeMessagePollInterval when its time to increase the poll interval.
+	UserPublicKeyPath                string          // The location to store user keys uploaded through the REST API
+	ReportDeviceStatus               bool            // whether to report the device status to the exchange or not.
+	TrustCertUpdatesFromOrg          bool            // whether to trust the certs provided by the organization on the exchange or not.
+	TrustDockerAuthFromOrg           bool            // whether to turst the docker auths provided by the organization on the exchange or not.
+	ServiceUpgradeCheckIntervalS     int64           // service upgrade check interval in seconds. The default is 300 seconds.
+	MultipleAnaxInstances            bool            // multiple anax instances running on the same machine
+	DefaultServiceRetryCount         int             // the default service retry count if retries are not specified by the policy file. The default value is 2.
+	DefaultServiceRetryDuration      uint64          // the default retry duration in seconds. The next retry cycle occurs after the duration. The default value is 600
+	DefaultNodePolicyFile            string          // the default node policy file name.
+	NodeCheckIntervalS               int             // the node check interval. The default is 15 seconds.
+	NodePolicyCheckIntervalS         int             // the node policy check interval. The default is 15 seconds.
+	FileSyncService                  FSSConfig       // The config for the embedded ESS sync service.
+	APISocket                        APISocketConfig // The config for serving the agent API on a unix domain socket.
+	SurfaceErrorTimeoutS             int             // How long surfaced errors will remain active after they're created. Default is no timeout
+	SurfaceErrorCheckIntervalS       int             // Deprecated. Used to be how often the node will check for errors that are no longer active and update the exchange. Default is 15 seconds
+	SurfaceErrorAgreementPersistentS int             // How long an agreement needs to persist before it is considered persistent and the related errors are dismisse. Default is 90 seconds
+	InitialPollingBuffer             int             // the number of seconds to wait before increasing the polling interval while there is no agreement on the node.
+	MaxAgreementPrelaunchTimeM       int64           // The maximum numbers of minutes to wait for workload to start in an agreement
+	K8sCRInstallTimeoutS             int64           // The number of seconds to wait for the custom resouce to install successfully before it is considered a failure
+	SecretsManagerFilePath           string          // The filepath for the secrets manager to store secrets in the agent filesystem
+	EventLogMaxAgeDays               int             // Event logs older than this number of days are pruned. The default is 0, event logs are not pruned by age.
+	EventLogMaxCount                 int             // The maximum number of event logs kept in the agent database, the oldest are pruned first. The default is 0, which means no limit.
+	EventLogPruneIntervalS           int             // The number of seconds between checks for event logs that should be pruned. The default is 3600.
+	ServiceStatsIntervalS            int             // The number of seconds between samples of the resources used by each service. The default is 0, which turns sampling off.
+	ServiceStatsHistorySize          int             // The number of resource usage samples kept for each service instance. The default is 60.
+	ReportServiceStats               bool            // Include a summary of the resources used by each service in the node status written to the exchange. The default is false.
+	HardwareRootPath                 string          // The directory where the host's /proc, /sys and /dev are mounted, used to check the matchHardware requirements of services when the agent runs in a container. The default is /.
+
+	// The transport used to send and receive agreement protocol messages. The default is the exchange mailbox.
+	MessageTransport MessageTransportConfig
+
+	// The number of seconds the node keeps its agreements and services running after it loses contact with the exchange.
+	// Agreement timeouts are suspended during this period, and the node status is held until the node reconnects. The
+	// default is 0, offline mode is off.
+	OfflineGracePeriodS int
 
 	// these Ids could be provided in config or discovered after startup by the system
 	BlockchainAccountId        string
@@ -81,51 +99,82 @@ type AGConfig struct {
 	TxLostDelayTolerationSeconds  int
 	AgreementWorkers              int
 	DBPath                        string
-	Postgresql                    PostgresqlConfig // The Postgresql config if it is being used
-	PartitionStale                uint64           // Number of seconds to wait before declaring a partition to be stale (i.e. the previous owner has unexpectedly terminated).
-	ProtocolTimeoutS              uint64           // Number of seconds to wait before declaring proposal response is lost
-	AgreementTimeoutS             uint64           // Number of seconds to wait before declaring agreement not finalized in blockchain
-	ProtocolTimeoutScaleFactor    float64          // Time to wait before declaring a proposal response is lost. Expressed as a scaling factor of the max heartbeat interval for a given node
-	AgreementTimeoutScaleFactor   float64          // Time to wait before declaring an agreement did not finalize. Expressed as a scaling factor of the max heartbeat interval for a given node
-	NoDataIntervalS               uint64           // default should be 15 mins == 15*60 == 900. Ignored if the policy has data verification disabled.
-	ActiveAgreementsURL           string           // This field is used when policy files indicate they want data verification but they dont specify a URL
-	ActiveAgreementsUser          string           // This is the userid the agbot uses to authenticate to the data verifivcation API
-	ActiveAgreementsPW            string           // This is the password for the ActiveAgreementsUser
-	PolicyPath                    string           // The directory where policy files are kept, default /etc/provider-tremor/policy/
-	NewContractIntervalS          uint64           // default should be 1
-	ProcessGovernanceIntervalS    uint64           // How long the gov sleeps before general gov checks (new payloads, interval payments, etc).
-	IgnoreContractWithAttribs     string           // A comma seperated list of contract attributes. If set, the contracts that contain one or more of the attributes will be ignored. The default is "ethereum_account".
-	ExchangeURL                   string           // The URL of the Horizon exchange. If not configured, the exchange will not be used.
-	ExchangeHeartbeat             int              // Seconds between heartbeats to the exchange
-	ExchangeId                    string           // The id of the agbot, not the userid of the exchange user. Must be org qualified.
-	ExchangeToken                 string           // The agbot's authentication token
-	DVPrefix                      string           // When looking for agreement ids in the data verification API response, look for agreement ids with this prefix.
-	ActiveDeviceTimeoutS          int              // The amount of time a device can go without heartbeating and still be considered active for the purposes of search
-	ExchangeMessageTTL            int              // The number of seconds the exchange will keep this message before automatically deleting it
-	ExchangeMessageTTLScaleFactor float64          // Scale factor for thee time the exchange will keep this ,essage before automatically deleting it. Scaled relativee to the max heeartbeat interval
-	MessageKeyPath                string           // The path to the location of messaging keys
-	MessageKeyCheck               int              // The interval (in seconds) indicating how often the agbot checks its own object in the exchange to ensure that the message key is still available.
-	DefaultWorkloadPW             string           // The default workload password if none is specified in the policy file
-	APIListen                     string           // Host and port for the API to listen on
-	SecureAPIListenHost           string           // The host for the secure API to listen on
-	SecureAPIListenPort           string           // The port for the secure API to listen on
-	SecureAPIServerCert           string           // The path to the certificate file for the secure api
-	SecureAPIServerKey            string           // The path to the server key file for the secure api
-	PurgeArchivedAgreementHours   int              // Number of hours to leave an archived agreement in the database before automatically deleting it
-	CheckUpdatedPolicyS           int              // The number of seconds to wait between checks for an updated policy file. Zero means auto checking is turned off.
-	CSSURL                        string           // The URL used to access the CSS.
-	CSSSSLCert                    string           // The path to the client side SSL certificate for the CSS.
-	MMSGarbageCollectionInterval  int64            // The amount of time to wait between MMS object cache garbage collection scans.
-	AgreementBatchSize            uint64           // The number of nodes that the agbot will process in a batch.
-	AgreementQueueSize            uint64           // The agreement bot work queue max size.
-	MessageQueueScale             float64          // Scaling factor applied to the AgreementQueueSize when determining how deep to keep the queues.
-	QueueHistorySize              int              // The number of statistics records to retain in the prioritized queue history.
-	FullRescanS                   uint64           // The number of seconds between policy scans when there have been no changes reported by the exchange.
-	MaxExchangeChanges            int              // The maximum number of exchange changes to request on a given call the exchange /changes API.
-	RetryLookBackWindow           uint64           // The time window (in seconds) used by the agbot to look backward in time for node changes when node agreements are retried.
-	PolicySearchOrder             bool             // When true, search policies from most recently changed to least recently changed.
-	Vault                         VaultConfig      // The hashicorp vault config to connect to and fetch secrets from.
-	SecretsUpdateCheck            int              // The number of seconds between checks for updated secrets.
+	Postgresql                    PostgresqlConfig  // The Postgresql config if it is being used
+	PartitionStale                uint64            // Number of seconds to wait before declaring a partition to be stale (i.e. the previous owner has unexpectedly terminated).
+	ProtocolTimeoutS              uint64            // Number of seconds to wait before declaring proposal response is lost
+	AgreementTimeoutS             uint64            // Number of seconds to wait before declaring agreement not finalized in blockchain
+	ProtocolTimeoutScaleFactor    float64           // Time to wait before declaring a proposal response is lost. Expressed as a scaling factor of the max heartbeat interval for a given node
+	AgreementTimeoutScaleFactor   float64           // Time to wait before declaring an agreement did not finalize. Expressed as a scaling factor of the max heartbeat interval for a given node
+	NoDataIntervalS               uint64            // default should be 15 mins == 15*60 == 900. Ignored if the policy has data verification disabled.
+	ActiveAgreementsURL           string            // This field is used when policy files indicate they want data verification but they dont specify a URL
+	ActiveAgreementsUser          string            // This is the userid the agbot uses to authenticate to the data verifivcation API
+	ActiveAgreementsPW            string            // This is the password for the ActiveAgreementsUser
+	PolicyPath                    string            // The directory where policy files are kept, default /etc/provider-tremor/policy/
+	NewContractIntervalS          uint64            // default should be 1
+	ProcessGovernanceIntervalS    uint64            // How long the gov sleeps before general gov checks (new payloads, interval payments, etc).
+	IgnoreContractWithAttribs     string            // A comma seperated list of contract attributes. If set, the contracts that contain one or more of the attributes will be ignored. The default is "ethereum_account".
+	ExchangeURL                   string            // The URL of the Horizon exchange. If not configured, the exchange will not be used.
+	ExchangeHeartbeat             int               // Seconds between heartbeats to the exchange
+	ExchangeId                    string            // The id of the agbot, not the userid of the exchange user. Must be org qualified.
+	ExchangeToken                 string            // The agbot's authentication token
+	DVPrefix                      string            // When looking for agreement ids in the data verification API response, look for agreement ids with this prefix.
+	ActiveDeviceTimeoutS          int               // The amount of time a device can go without heartbeating and still be considered active for the purposes of search
+	ExchangeMessageTTL            int               // The number of seconds the exchange will keep this message before automatically deleting it
+	ExchangeMessageTTLScaleFactor float64           // Scale factor for thee time the exchange will keep this ,essage before automatically deleting it. Scaled relativee to the max heeartbeat interval
+	MessageKeyPath                string            // The path to the location of messaging keys
+	MessageKeyCheck               int               // The interval (in seconds) indicating how often the agbot checks its own object in the exchange to ensure that the message key is still available.
+	DefaultWorkloadPW             string            // The default workload password if none is specified in the policy file
+	APIListen                     string            // Host and port for the API to listen on
+	SecureAPIListenHost           string            // The host for the secure API to listen on
+	SecureAPIListenPort           string            // The port for the secure API to listen on
+	SecureAPIServerCert           string            // The path to the certificate file for the secure api
+	SecureAPIServerKey            string            // The path to the server key file for the secure api
+	PurgeArchivedAgreementHours   int               // Number of hours to leave an archived agreement in the database before automatically deleting it
+	CheckUpdatedPolicyS           int               // The number of seconds to wait between checks for an updated policy file. Zero means auto checking is turned off.
+	CSSURL                        string            // The URL used to access the CSS.
+	CSSSSLCert                    string            // The path to the client side SSL certificate for the CSS.
+	MMSGarbageCollectionInterval  int64             // The amount of time to wait between MMS object cache garbage collection scans.
+	AgreementBatchSize            uint64            // The number of nodes that the agbot will process in a batch.
+	AgreementQueueSize            uint64            // The agreement bot work queue max size.
+	MessageQueueScale             float64           // Scaling factor applied to the AgreementQueueSize when determining how deep to keep the queues.
+	QueueHistorySize              int               // The number of statistics records to retain in the prioritized queue history.
+	FullRescanS                   uint64            // The number of seconds between policy scans when there have been no changes reported by the exchange.
+	MaxExchangeChanges            int               // The maximum number of exchange changes to request on a given call the exchange /changes API.
+	RetryLookBackWindow           uint64            // The time window (in seconds) used by the agbot to look backward in time for node changes when node agreements are retried.
+	PolicySearchOrder             bool              // When true, search policies from most recently changed to least recently changed.
+	Vault                         VaultConfig       // The hashicorp vault config to connect to and fetch secrets from.
+	SecretStore                   SecretStoreConfig // The encrypted file based secret store, used when there is no vault.
+	SecretsUpdateCheck            int               // The number of seconds between checks for updated secrets.
+	Webhooks                      *WebhookConfig    // The webhooks that are notified of agreement and node lifecycle events.
+
+	// The transport used to send and receive agreement protocol messages. The default is the exchange mailbox.
+	MessageTransport MessageTransportConfig
+}
+
+// Contains the configuration of the transport used by the agent and the agbot to send agreement protocol messages to
+// each other. The messages are encrypted the same way with every transport. The agent and the agbots it makes agreements
+// with must use the same transport.
+type MessageTransportConfig struct {
+	Name        string // The name of the transport, exchange or mqtt. The default is exchange.
+	BrokerURL   string // The URL of the MQTT broker, for example ssl://mqtt.example.com:8883.
+	Username    string // The user to connect to the broker as. The default is the exchange id of the node or agbot, with its exchange token as the password.
+	Password    string // The password of Username.
+	CACertPath  string // The path of a file containing PEM-encoded x509 certs that the broker's cert is trusted with.
+	TopicPrefix string // The first level of the topics that the mailboxes are on. The default is horizon.
+}
+
+func (c *MessageTransportConfig) GetName() string {
+	if c == nil || c.Name == "" {
+		return MESSAGE_TRANSPORT_DEFAULT
+	}
+	return c.Name
+}
+
+func (c *MessageTransportConfig) GetTopicPrefix() string {
+	if c.TopicPrefix == "" {
+		return MQTT_TOPIC_PREFIX_DEFAULT
+	}
+	return c.TopicPrefix
 }
 
 // Contains the hashicorp vault configuration used within AGConfig.
@@ -134,6 +183,13 @@ type VaultConfig struct {
 	SSLCertPath string // The SSL certificate for the vault.
 }
 
+// Contains the configuration of the encrypted file based secret store used within AGConfig. The secret store
+// keeps secrets in a local database, it is intended for development and air-gapped deployments with a single agbot.
+type SecretStoreConfig struct {
+	DBPath  string // The directory containing the secret store database.
+	KeyFile string // The file containing the key used to encrypt secrets. If the file does not exist, a key is generated.
+}
+
 func (c *HorizonConfig) GetSecretsMount() string {
 	return HZN_SECRETS_MOUNT
 }
@@ -170,6 +226,22 @@ func (c *HorizonConfig) IsVaultConfigured() bool {
 	return c.AgreementBot.Vault != VaultConfig{}
 }
 
+func (c *HorizonConfig) IsSecretStoreConfigured() bool {
+	return c.AgreementBot.SecretStore.DBPath != ""
+}
+
+func (c *HorizonConfig) GetSecretStoreDBPath() string {
+	return c.AgreementBot.SecretStore.DBPath
+}
+
+// The encryption key is kept with the secret store database if a key file is not configured.
+func (c *HorizonConfig) GetSecretStoreKeyFile() string {
+	if c.AgreementBot.SecretStore.KeyFile == "" {
+		return path.Join(c.AgreementBot.SecretStore.DBPath, "secretstore.key")
+	}
+	return c.AgreementBot.SecretStore.KeyFile
+}
+
 func (c *HorizonConfig) GetSecretsManagerFilePath() string {
 	secPath := c.Edge.SecretsManagerFilePath
 	if secPath == "" {
@@ -179,6 +251,56 @@ func (c *HorizonConfig) GetSecretsManagerFilePath() string {
 	return secPath
 }
 
+// Contains the webhook configuration used within AGConfig. Events that cannot be delivered right away are kept in a
+// queue on disk and retried, with an increasing interval between attempts, until they are delivered or the retries are used up.
+type WebhookConfig struct {
+	Endpoints         []WebhookEndpoint // The webhooks to notify. Webhooks are turned off when there are none.
+	QueuePath         string            // The directory holding the events waiting to be delivered. The default is a webhooks directory in the DBPath.
+	MaxQueueSize      int               // The most events kept in the queue, the oldest events are dropped when it is full.
+	MaxRetries        int               // The number of times to retry delivering an event before dropping it.
+	RetryIntervalS    int               // The number of seconds to wait before the first retry, the wait doubles with each retry.
+	MaxRetryIntervalS int               // The longest wait between retries, in seconds.
+	TimeoutS          int               // The number of seconds to wait for a webhook to respond.
+}
+
+// A webhook and the events that are sent to it.
+type WebhookEndpoint struct {
+	URL    string   // The URL the events are POSTed to.
+	Secret string   // If set, the events are signed with an HMAC-SHA256 of the body using this secret.
+	Events []string // The event types to send. All event types are sent when this is empty.
+}
+
+func (c *WebhookConfig) setDefaults() {
+	if c.MaxQueueSize <= 0 {
+		c.MaxQueueSize = AgbotWebhookMaxQueueSize_DEFAULT
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = AgbotWebhookMaxRetries_DEFAULT
+	}
+	if c.RetryIntervalS <= 0 {
+		c.RetryIntervalS = AgbotWebhookRetryIntervalS_DEFAULT
+	}
+	if c.MaxRetryIntervalS <= 0 {
+		c.MaxRetryIntervalS = AgbotWebhookMaxRetryIntervalS_DEFAULT
+	}
+	if c.TimeoutS <= 0 {
+		c.TimeoutS = AgbotWebhookTimeoutS_DEFAULT
+	}
+}
+
+func (c *HorizonConfig) IsWebhookConfigured() bool {
+	return c.AgreementBot.Webhooks != nil && len(c.AgreementBot.Webhooks.Endpoints) != 0
+}
+
+func (c *HorizonConfig) GetWebhookQueuePath() string {
+	if !c.IsWebhookConfigured() {
+		return ""
+	} else if c.AgreementBot.Webhooks.QueuePath == "" && c.AgreementBot.DBPath != "" {
+		return path.Join(c.AgreementBot.DBPath, "webhooks")
+	}
+	return c.AgreementBot.Webhooks.QueuePath
+}
+
 func (c *HorizonConfig) GetSecretsUpdateCheck() int {
 	return c.AgreementBot.SecretsUpdateCheck
 }
@@ -368,6 +490,8 @@ func Read(file string) (*HorizonConfig, error) {
 				ExchangeMessagePollIncrement:   ExchangeMessagePollIncrement_DEFAULT,
 				MaxAgreementPrelaunchTimeM:     EdgeMaxAgreementPrelaunchTimeM_DEFAULT,
 				K8sCRInstallTimeoutS:           K8sCRInstallTimeoutS_DEFAULT,
+				EventLogPruneIntervalS:         EventLogPruneIntervalS_DEFAULT,
+				ServiceStatsHistorySize:        ServiceStatsHistorySize_DEFAULT,
 			},
 			AgreementBot: AGConfig{
 				MessageKeyCheck:     AgbotMessageKeyCheck_DEFAULT,
@@ -430,6 +554,18 @@ func Read(file string) (*HorizonConfig, error) {
 			config.Edge.InitialPollingBuffer = 120
 		}
 
+		if config.Edge.EventLogPruneIntervalS <= 0 {
+			config.Edge.EventLogPruneIntervalS = EventLogPruneIntervalS_DEFAULT
+		}
+
+		if config.Edge.ServiceStatsHistorySize <= 0 {
+			config.Edge.ServiceStatsHistorySize = ServiceStatsHistorySize_DEFAULT
+		}
+
+		if config.AgreementBot.Webhooks != nil {
+			config.AgreementBot.Webhooks.setDefaults()
+		}
+
 		// add a slash at the back of the ExchangeUrl
 		if config.Edge.ExchangeURL != "" {
 			config.Edge.ExchangeURL = strings.TrimRight(config.Edge.ExchangeURL, "/") + "/"
@@ -506,7 +642,16 @@ func (con *Config) String() string {
 		", DefaultServiceRetryDuration: %v"+
 		", NodeCheckIntervalS: %v"+
 		", FileSyncService: {%v}"+
+		", APISocket: {%v}"+
 		", InitialPollingBuffer: {%v}"+
+		", EventLogMaxAgeDays: %v"+
+		", EventLogMaxCount: %v"+
+		", EventLogPruneIntervalS: %v"+
+		", ServiceStatsIntervalS: %v"+
+		", ServiceStatsHistorySize: %v"+
+		", ReportServiceStats: %v"+
+		", HardwareRootPath: %v"+
+		", OfflineGracePeriodS: %v"+
 		", BlockchainAccountId: %v"+
 		", BlockchainDirectoryAddress %v",
 		con.ServiceStorage, con.APIListen, con.DBPath, con.DockerEndpoint, con.DockerCredFilePath, con.DefaultCPUSet,
@@ -515,8 +660,9 @@ func (con *Config) String() string {
 		con.DVPrefix, con.RegistrationDelayS, con.ExchangeMessageTTL, con.ExchangeMessageDynamicPoll, con.ExchangeMessagePollInterval,
 		con.ExchangeMessagePollMaxInterval, con.ExchangeMessagePollIncrement, con.UserPublicKeyPath, con.ReportDeviceStatus,
 		con.TrustCertUpdatesFromOrg, con.TrustDockerAuthFromOrg, con.ServiceUpgradeCheckIntervalS, con.MultipleAnaxInstances,
-		con.DefaultServiceRetryCount, con.DefaultServiceRetryDuration, con.NodeCheckIntervalS, con.FileSyncService.String(),
-		con.InitialPollingBuffer, con.BlockchainAccountId, con.BlockchainDirectoryAddress)
+		con.DefaultServiceRetryCount, con.DefaultServiceRetryDuration, con.NodeCheckIntervalS, con.FileSyncService.String(), con.APISocket.String(),
+		con.InitialPollingBuffer, con.EventLogMaxAgeDays, con.EventLogMaxCount, con.EventLogPruneIntervalS,
+		con.ServiceStatsIntervalS, con.ServiceStatsHistorySize, con.ReportServiceStats, con.HardwareRootPath, con.OfflineGracePeriodS, con.BlockchainAccountId, con.BlockchainDirectoryAddress)
 }
 
 func (agc *AGConfig) String() string {
@@ -562,7 +708,9 @@ func (agc *AGConfig) String() string {
 		", MaxExchangeChanges: %v"+
 		", RetryLookBackWindow: %v"+
 		", PolicySearchOrder: %v"+
-		", Vault: {%v}",
+		", Vault: {%v}"+
+		", SecretStore: {%v}"+
+		", Webhooks: {%v}",
 		agc.TxLostDelayTolerationSeconds, agc.AgreementWorkers, agc.DBPath, agc.Postgresql.String(),
 		agc.PartitionStale, agc.ProtocolTimeoutS, agc.AgreementTimeoutS, agc.NoDataIntervalS, agc.ActiveAgreementsURL,
 		agc.ActiveAgreementsUser, mask, agc.PolicyPath, agc.NewContractIntervalS, agc.ProcessGovernanceIntervalS,
@@ -571,9 +719,25 @@ func (agc *AGConfig) String() string {
 		agc.SecureAPIListenHost, agc.SecureAPIListenPort, agc.SecureAPIServerCert, agc.SecureAPIServerKey,
 		agc.PurgeArchivedAgreementHours, agc.CheckUpdatedPolicyS, agc.CSSURL, agc.CSSSSLCert, agc.AgreementBatchSize,
 		agc.AgreementQueueSize, agc.MessageQueueScale, agc.QueueHistorySize, agc.FullRescanS, agc.MaxExchangeChanges,
-		agc.RetryLookBackWindow, agc.PolicySearchOrder, agc.Vault)
+		agc.RetryLookBackWindow, agc.PolicySearchOrder, agc.Vault, agc.SecretStore, agc.Webhooks)
 }
 
 func (c *VaultConfig) String() string {
 	return fmt.Sprintf("VaultURL: %v,", c.VaultURL)
 }
+
+func (c SecretStoreConfig) String() string {
+	return fmt.Sprintf("DBPath: %v, KeyFile: %v", c.DBPath, c.KeyFile)
+}
+
+// The webhook secrets are not logged.
+func (c *WebhookConfig) String() string {
+	if c == nil {
+		return ""
+	}
+	urls := make([]string, 0, len(c.Endpoints))
+	for _, ep := range c.Endpoints {
+		urls = append(urls, fmt.Sprintf("%v %v", ep.URL, ep.Events))
+	}
+	return fmt.Sprintf("Endpoints: %v, QueuePath: %v, MaxQueueSize: %v, MaxRetries: %v, RetryIntervalS: %v, MaxRetryIntervalS: %v, TimeoutS: %v", urls, c.QueuePath, c.MaxQueueSize, c.MaxRetries, c.RetryIntervalS, c.MaxRetryIntervalS, c.TimeoutS)
+}
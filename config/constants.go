@@ -29,6 +29,11 @@ const HZN_VAR_RUN_BASE_DEFAULT = "/var/run/horizon"
 const HZN_FSS_DOMAIN_SOCKET_PATH = "/var/run/horizon"
 const HZN_FSS_DOMAIN_SOCKET = "essapi.sock"
 
+// The default names of the unix domain socket for the agent API, and of the file holding the local bearer token for
+// the agent API. Both are in HZN_VAR_RUN_BASE_DEFAULT.
+const HZN_API_DOMAIN_SOCKET = "anax.sock"
+const HZN_API_TOKEN_FILE = "anax-api.token"
+
 // The default listen address for the FSS over https
 const HZN_FSS_API_LISTEN_DEFAULT = "localhost"
 const HZN_FSS_API_LISTEN_PORT_DEFAULT = 8443
@@ -116,3 +121,30 @@ const K8sCRInstallTimeoutS_DEFAULT = 180
 
 // Time between secret update checks
 const SecretsUpdateCheck_DEFAULT = 60
+
+// The most webhook events kept in the agbot's queue
+const AgbotWebhookMaxQueueSize_DEFAULT = 10000
+
+// The number of times to retry delivering a webhook event
+const AgbotWebhookMaxRetries_DEFAULT = 10
+
+// Time to wait before the first retry of a webhook event, doubled with each retry
+const AgbotWebhookRetryIntervalS_DEFAULT = 5
+
+// The longest time to wait between retries of a webhook event
+const AgbotWebhookMaxRetryIntervalS_DEFAULT = 600
+
+// Time to wait for a webhook to respond
+const AgbotWebhookTimeoutS_DEFAULT = 10
+
+// Time between checks for event logs that should be pruned
+const EventLogPruneIntervalS_DEFAULT = 3600
+
+// The number of resource usage samples kept for each service instance
+const ServiceStatsHistorySize_DEFAULT = 60
+
+// The transport used for agreement protocol messages when none is configured
+const MESSAGE_TRANSPORT_DEFAULT = "exchange"
+
+// The first level of the MQTT topics that the message mailboxes are on
+const MQTT_TOPIC_PREFIX_DEFAULT = "horizon"
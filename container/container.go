@@ -43,6 +43,13 @@ const (
 	LOG_DRIVER_JOURNALD    = "journald"
 )
 
+// The health of a container with a health check, as reported in the docker container status.
+const (
+	CONTAINER_HEALTH_STARTING  = "starting"
+	CONTAINER_HEALTH_HEALTHY   = "healthy"
+	CONTAINER_HEALTH_UNHEALTHY = "unhealthy"
+)
+
 // messages for event logs
 const (
 	EL_CONT_DEPLOYCONF_UNSUPPORT_CAP_FOR_WL   = "Deployment config %v contains unsupported capability for a workload"
@@ -329,6 +336,11 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			delete(logConfig.Config, "tag")
 		}
 
+		restartPolicy, err := service.GetRestartPolicy()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid restart for service %v: %v", serviceName, err)
+		}
+
 		serviceConfig := &persistence.ServiceConfig{
 			Config: docker.Config{
 				Image:        service.Image,
@@ -346,7 +358,7 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 				PublishAllPorts: false,
 				PortBindings:    map[docker.Port][]docker.PortBinding{},
 				Links:           nil, // do not allow any
-				RestartPolicy:   docker.AlwaysRestart(),
+				RestartPolicy:   restartPolicy,
 				Memory:          ramBytes,
 				MemorySwap:      0,
 				Devices:         []docker.Device{},
@@ -357,6 +369,14 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			},
 		}
 
+		// Set the health check if it is defined in the service config, otherwise the image's health check is used
+		if service.HealthCheck != nil {
+			if err := service.HealthCheck.Validate(); err != nil {
+				return nil, fmt.Errorf("Invalid healthcheck for service %v: %v", serviceName, err)
+			}
+			serviceConfig.Config.Healthcheck = service.HealthCheck.GetHealthConfig()
+		}
+
 		// Set CPU and memory limits if they are defined in the service config
 		if service.MaxMemoryMb != 0 {
 			serviceConfig.HostConfig.Memory = service.MaxMemoryMb * 1024 * 1024
@@ -1798,8 +1818,13 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 
 				for _, name := range serviceNames {
 					if container.Labels[LABEL_PREFIX+".service_name"] == name && container.State == "running" {
-						cMatches = append(cMatches, *container)
-						glog.V(4).Infof("Matching container instance for agreement %v: %v", agreementId, container)
+						// An unhealthy container is treated the same as one that is not running.
+						if ContainerHealth(*container) == CONTAINER_HEALTH_UNHEALTHY {
+							glog.Errorf("Container %v for agreement %v is unhealthy.", container.Names, agreementId)
+						} else {
+							cMatches = append(cMatches, *container)
+							glog.V(4).Infof("Matching container instance for agreement %v: %v", agreementId, container)
+						}
 					}
 				}
 				return nil
@@ -1872,6 +1897,8 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 					if container.Labels[LABEL_PREFIX+".service_name"] == name {
 						if container.State != "running" {
 							glog.Errorf("Service container for %v is not in the running state.", instance_key)
+						} else if ContainerHealth(*container) == CONTAINER_HEALTH_UNHEALTHY {
+							glog.Errorf("Service container for %v is unhealthy.", instance_key)
 						} else {
 							cMatches = append(cMatches, *container)
 							glog.V(4).Infof("Matching container instance for service instance %v: %v", instance_key, container)
@@ -2580,6 +2607,19 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 	return nil
 }
 
+// Returns the health of a container that has a health check, or an empty string if the container has no health check.
+// Docker reports the health at the end of the container status, e.g. "Up 2 minutes (unhealthy)".
+func ContainerHealth(container docker.APIContainers) string {
+	if strings.HasSuffix(container.Status, "("+CONTAINER_HEALTH_UNHEALTHY+")") {
+		return CONTAINER_HEALTH_UNHEALTHY
+	} else if strings.HasSuffix(container.Status, "("+CONTAINER_HEALTH_HEALTHY+")") {
+		return CONTAINER_HEALTH_HEALTHY
+	} else if strings.HasSuffix(container.Status, "(health: "+CONTAINER_HEALTH_STARTING+")") {
+		return CONTAINER_HEALTH_STARTING
+	}
+	return ""
+}
+
 func (b *ContainerWorker) ContainersMatchingAgreement(agreements []string, includeShared bool, fn func(*docker.APIContainers, string) error) error {
 	var processingErr error
 
@@ -271,3 +271,19 @@ func Test_CheckPermissions_0(t *testing.T) {
 	}
 
 }
+
+func Test_ContainerHealth(t *testing.T) {
+	statuses := map[string]string{
+		"Up 2 minutes":                    "",
+		"Up 2 minutes (healthy)":          CONTAINER_HEALTH_HEALTHY,
+		"Up 2 minutes (unhealthy)":        CONTAINER_HEALTH_UNHEALTHY,
+		"Up 5 seconds (health: starting)": CONTAINER_HEALTH_STARTING,
+		"Exited (1) 3 seconds ago":        "",
+	}
+
+	for status, expected := range statuses {
+		if health := ContainerHealth(docker.APIContainers{Status: status}); health != expected {
+			t.Errorf("ContainerHealth for status %v returned %v, expected %v", status, health, expected)
+		}
+	}
+}
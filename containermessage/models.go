@@ -7,7 +7,9 @@ import (
 	"fmt"
 	docker "github.com/fsouza/go-dockerclient"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 /*
@@ -171,6 +173,8 @@ type Service struct {
 	MaxCPUs          float32              `json:"max_cpus,omitempty"`
 	LogDriver        string               `json:"log_driver,omitempty"` // Docker's log-driver. Syslog will be used as default driver
 	Secrets          map[string]Secret    `json:"secrets"`
+	HealthCheck      *HealthCheck         `json:"healthcheck,omitempty"` // Docker's health check. The health check in the image is used if not set
+	Restart          string               `json:"restart,omitempty"`     // Docker's restart policy. Always restart is used if not set
 }
 
 func (s *Service) AddFilesystemBinding(bind string) {
@@ -247,6 +251,78 @@ func (s *Service) AddSpecificPortBinding(b docker.PortBinding) {
 	s.Ports = append(s.Ports, b)
 }
 
+// The restart policies that can be set in the restart field, the same as docker run --restart.
+const (
+	RESTART_NO             = "no"
+	RESTART_ALWAYS         = "always"
+	RESTART_ON_FAILURE     = "on-failure"
+	RESTART_UNLESS_STOPPED = "unless-stopped"
+)
+
+// Returns the docker restart policy for the service container. The restart field has the same format as the docker run
+// --restart flag, e.g. "on-failure:5".
+func (s *Service) GetRestartPolicy() (docker.RestartPolicy, error) {
+	policy := strings.Split(s.Restart, ":")
+	switch policy[0] {
+	case "", RESTART_ALWAYS:
+		if len(policy) == 1 {
+			return docker.AlwaysRestart(), nil
+		}
+	case RESTART_NO:
+		if len(policy) == 1 {
+			return docker.NeverRestart(), nil
+		}
+	case RESTART_UNLESS_STOPPED:
+		if len(policy) == 1 {
+			return docker.RestartUnlessStopped(), nil
+		}
+	case RESTART_ON_FAILURE:
+		if len(policy) == 1 {
+			return docker.RestartOnFailure(0), nil
+		} else if maxRetry, err := strconv.Atoi(policy[1]); err == nil && maxRetry >= 0 && len(policy) == 2 {
+			return docker.RestartOnFailure(maxRetry), nil
+		}
+	}
+	return docker.RestartPolicy{}, errors.New(fmt.Sprintf("restart %v is not valid, it must be one of %v, %v, %v[:max-retries] or %v", s.Restart, RESTART_NO, RESTART_ALWAYS, RESTART_ON_FAILURE, RESTART_UNLESS_STOPPED))
+}
+
+// A health check for a service container, the same as the HEALTHCHECK instruction in a dockerfile. The times are in seconds.
+type HealthCheck struct {
+	Test        []string `json:"test"`                   // The command to run, e.g. ["CMD-SHELL", "curl -f http://localhost/ || exit 1"]. A command without CMD or CMD-SHELL is run with CMD
+	Interval    int      `json:"interval,omitempty"`     // The time between checks
+	Timeout     int      `json:"timeout,omitempty"`      // The time a check can take before it is considered to have failed
+	Retries     int      `json:"retries,omitempty"`      // The number of consecutive failures before the container is unhealthy
+	StartPeriod int      `json:"start_period,omitempty"` // The time for the container to start, during which failures are not counted
+}
+
+func (h HealthCheck) String() string {
+	return fmt.Sprintf("Test: %v, Interval: %v, Timeout: %v, Retries: %v, StartPeriod: %v", h.Test, h.Interval, h.Timeout, h.Retries, h.StartPeriod)
+}
+
+func (h *HealthCheck) Validate() error {
+	if len(h.Test) == 0 {
+		return errors.New(fmt.Sprintf("healthcheck test must not be empty"))
+	} else if h.Interval < 0 || h.Timeout < 0 || h.Retries < 0 || h.StartPeriod < 0 {
+		return errors.New(fmt.Sprintf("healthcheck values must not be negative: %v", h))
+	}
+	return nil
+}
+
+// Returns the docker health check configuration for the service container.
+func (h *HealthCheck) GetHealthConfig() *docker.HealthConfig {
+	test := h.Test
+	if test[0] != "CMD" && test[0] != "CMD-SHELL" && test[0] != "NONE" {
+		test = append([]string{"CMD"}, test...)
+	}
+	return &docker.HealthConfig{
+		Test:        test,
+		Interval:    time.Duration(h.Interval) * time.Second,
+		Timeout:     time.Duration(h.Timeout) * time.Second,
+		Retries:     h.Retries,
+		StartPeriod: time.Duration(h.StartPeriod) * time.Second,
+	}
+}
+
 type Port struct {
 	LocalhostOnly   bool   `json:"localhost_only,omitempty"`
 	PortAndProtocol string `json:"port_and_protocol"`
@@ -178,3 +178,61 @@ func Test_AddSpecificPortBinding(t *testing.T) {
 		t.Errorf("Service should have 2 specific port bindings but not.")
 	}
 }
+
+func Test_GetRestartPolicy(t *testing.T) {
+	serv := Service{Image: "an image"}
+
+	valid := map[string]docker.RestartPolicy{
+		"":               docker.AlwaysRestart(),
+		"always":         docker.AlwaysRestart(),
+		"no":             docker.NeverRestart(),
+		"unless-stopped": docker.RestartUnlessStopped(),
+		"on-failure":     docker.RestartOnFailure(0),
+		"on-failure:5":   docker.RestartOnFailure(5),
+	}
+	for restart, expected := range valid {
+		serv.Restart = restart
+		if policy, err := serv.GetRestartPolicy(); err != nil {
+			t.Errorf("GetRestartPolicy for restart %v returned an error: %v", restart, err)
+		} else if policy != expected {
+			t.Errorf("GetRestartPolicy for restart %v returned %v, expected %v", restart, policy, expected)
+		}
+	}
+
+	for _, restart := range []string{"sometimes", "always:3", "on-failure:x", "on-failure:-1", "on-failure:1:2"} {
+		serv.Restart = restart
+		if _, err := serv.GetRestartPolicy(); err == nil {
+			t.Errorf("GetRestartPolicy for restart %v should have returned an error.", restart)
+		}
+	}
+}
+
+func Test_HealthCheck(t *testing.T) {
+	hc := HealthCheck{Test: []string{"curl", "-f", "http://localhost/"}, Interval: 30, Timeout: 5, Retries: 3, StartPeriod: 60}
+
+	if err := hc.Validate(); err != nil {
+		t.Errorf("Validate for health check %v returned an error: %v", hc, err)
+	}
+
+	config := hc.GetHealthConfig()
+	if len(config.Test) != 4 || config.Test[0] != "CMD" {
+		t.Errorf("GetHealthConfig should have added CMD to the test: %v", config.Test)
+	} else if config.Interval.Seconds() != 30 || config.Timeout.Seconds() != 5 || config.StartPeriod.Seconds() != 60 || config.Retries != 3 {
+		t.Errorf("GetHealthConfig returned the wrong times: %v", config)
+	}
+
+	hc.Test = []string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}
+	if config := hc.GetHealthConfig(); len(config.Test) != 2 || config.Test[0] != "CMD-SHELL" {
+		t.Errorf("GetHealthConfig should not have changed the test: %v", config.Test)
+	}
+
+	hc.Test = []string{}
+	if err := hc.Validate(); err == nil {
+		t.Errorf("Validate for health check without a test should have returned an error.")
+	}
+
+	hc = HealthCheck{Test: []string{"NONE"}, Retries: -1}
+	if err := hc.Validate(); err == nil {
+		t.Errorf("Validate for health check with negative retries should have returned an error.")
+	}
+}
@@ -91,6 +91,12 @@ func GetEventLogs(db *bolt.DB, all_logs bool, selectors map[string][]persistence
 	return persistence.FindEventLogsWithSelectors(db, all_logs, selectors, msgPrinter)
 }
 
+// Get the event logs from the db that were saved after the event log with the given record id.
+// The all_logs, selectors and msgPrinter parameters are the same as for GetEventLogs.
+func GetEventLogsAfter(db *bolt.DB, all_logs bool, after_id uint64, selectors map[string][]persistence.Selector, msgPrinter *message.Printer) ([]persistence.EventLog, error) {
+	return persistence.FindEventLogsWithSelectorsAfter(db, all_logs, after_id, selectors, msgPrinter)
+}
+
 type EventLogByTimestamp []persistence.EventLog
 
 func (s EventLogByTimestamp) Len() int {
@@ -78,6 +78,10 @@ const (
 	DEVICE_CONTAINERS_SYNCED EventId = "DEVICE_CONTAINERS_SYNCED"
 	WORKLOAD_UPGRADE         EventId = "WORKLOAD_UPGRADE"
 	PROPOSAL_ACCEPTED        EventId = "PROPOSAL_ACCEPTED"
+	AB_AGREEMENT_MADE        EventId = "AB_AGREEMENT_MADE"
+	AB_AGREEMENT_CANCELLED   EventId = "AB_AGREEMENT_CANCELLED"
+	AB_AGREEMENT_TIMEDOUT    EventId = "AB_AGREEMENT_TIMEDOUT"
+	AB_SERVICE_ROLLBACK      EventId = "AB_SERVICE_ROLLBACK"
 
 	// Node related
 	START_UNCONFIGURE            EventId = "UNCONFIGURE_NODE"
@@ -1129,6 +1133,88 @@ func NewABApiWorkloadUpgradeMessage(id EventId, protocol string, agreementId str
 	}
 }
 
+// Agreement lifecycle messages from the agbot. The reason is only set when the agreement is cancelled or timed out.
+type ABAgreementMessage struct {
+	event             Event
+	AgreementProtocol string
+	AgreementId       string
+	DeviceId          string
+	PolicyName        string
+	Pattern           string
+	ServiceIds        []string
+	Reason            uint
+	ReasonDescription string
+}
+
+func (m *ABAgreementMessage) Event() Event {
+	return m.event
+}
+
+func (m ABAgreementMessage) String() string {
+	return fmt.Sprintf("Event: %v, AgreementProtocol: %v, AgreementId: %v, DeviceId: %v, PolicyName: %v, Pattern: %v, ServiceIds: %v, Reason: %v, ReasonDescription: %v", m.event, m.AgreementProtocol, m.AgreementId, m.DeviceId, m.PolicyName, m.Pattern, m.ServiceIds, m.Reason, m.ReasonDescription)
+}
+
+func (m ABAgreementMessage) ShortString() string {
+	return m.String()
+}
+
+func NewABAgreementMessage(id EventId, protocol string, agreementId string, deviceId string, policyName string, pattern string, serviceIds []string, reason uint, reasonDescription string) *ABAgreementMessage {
+	return &ABAgreementMessage{
+		event: Event{
+			Id: id,
+		},
+		AgreementProtocol: protocol,
+		AgreementId:       agreementId,
+		DeviceId:          deviceId,
+		PolicyName:        policyName,
+		Pattern:           pattern,
+		ServiceIds:        serviceIds,
+		Reason:            reason,
+		ReasonDescription: reasonDescription,
+	}
+}
+
+// Sent by the agbot when an agreement is made with a lower priority version of a service than the node was running before.
+type ABServiceRollbackMessage struct {
+	event            Event
+	AgreementId      string
+	DeviceId         string
+	PolicyName       string
+	ServiceURL       string
+	ServiceOrg       string
+	Version          string
+	Priority         int
+	PreviousPriority int
+}
+
+func (m *ABServiceRollbackMessage) Event() Event {
+	return m.event
+}
+
+func (m ABServiceRollbackMessage) String() string {
+	return fmt.Sprintf("Event: %v, AgreementId: %v, DeviceId: %v, PolicyName: %v, ServiceURL: %v, ServiceOrg: %v, Version: %v, Priority: %v, PreviousPriority: %v", m.event, m.AgreementId, m.DeviceId, m.PolicyName, m.ServiceURL, m.ServiceOrg, m.Version, m.Priority, m.PreviousPriority)
+}
+
+func (m ABServiceRollbackMessage) ShortString() string {
+	return m.String()
+}
+
+func NewABServiceRollbackMessage(id EventId, agreementId string, deviceId string, policyName string, serviceURL string, serviceOrg string, version string, priority int, previousPriority int) *ABServiceRollbackMessage {
+	return &ABServiceRollbackMessage{
+		event: Event{
+			Id: id,
+		},
+		AgreementId:      agreementId,
+		DeviceId:         deviceId,
+		PolicyName:       policyName,
+		ServiceURL:       serviceURL,
+		ServiceOrg:       serviceOrg,
+		Version:          version,
+		Priority:         priority,
+		PreviousPriority: previousPriority,
+	}
+}
+
 // Initialization and restart messages
 type InitAgreementCancelationMessage struct {
 	event             Event
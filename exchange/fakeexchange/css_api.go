@@ -0,0 +1,294 @@
+package fakeexchange
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A model management object in the CSS. Only the metadata is kept, the fake does not store object data.
+type cssObject struct {
+	meta           map[string]interface{}
+	destinations   []map[string]interface{}
+	policyReceived bool
+	timestamp      int64
+}
+
+// The destination policy of the object in the form returned by the CSS, or nil if the object does not have one.
+func (o *cssObject) destinationPolicy() map[string]interface{} {
+	pol, ok := o.meta["destinationPolicy"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	pol["timestamp"] = o.timestamp
+	return map[string]interface{}{
+		"orgID":             o.meta["destinationOrgID"],
+		"objectType":        o.meta["objectType"],
+		"objectID":          o.meta["objectID"],
+		"destinationPolicy": pol,
+		"destinations":      o.destinations,
+	}
+}
+
+// Returns true if the object's destination policy refers to the service, which is in the form org/name.
+func (o *cssObject) usesService(service string) bool {
+	pol, ok := o.meta["destinationPolicy"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	services, _ := pol["services"].([]interface{})
+	for _, svc := range services {
+		if m, ok := svc.(map[string]interface{}); ok && fmt.Sprintf("%v/%v", m["orgID"], m["serviceName"]) == service {
+			return true
+		}
+	}
+	return false
+}
+
+// The model management APIs used by the agent and the agbot.
+func (s *Server) cssRoutes(r *mux.Router) {
+	r.HandleFunc("/api/v1/objects/{org}", s.auth(s.getObjectPolicies)).Methods("GET")
+	r.HandleFunc("/api/v1/objects/{org}/{type}/{id}", s.auth(s.getObject)).Methods("GET")
+	r.HandleFunc("/api/v1/objects/{org}/{type}/{id}", s.auth(s.putObject)).Methods("PUT")
+	r.HandleFunc("/api/v1/objects/{org}/{type}/{id}", s.auth(s.deleteObject)).Methods("DELETE")
+	r.HandleFunc("/api/v1/objects/{org}/{type}/{id}/destinations", s.auth(s.getObjectDestinations)).Methods("GET")
+	r.HandleFunc("/api/v1/objects/{org}/{type}/{id}/destinations", s.auth(s.postObjectDestinations)).Methods("POST")
+	r.HandleFunc("/api/v1/objects/{org}/{type}/{id}/policyreceived", s.auth(s.policyReceived)).Methods("PUT")
+}
+
+func objectKey(org string, objType string, id string) string {
+	return org + "/" + objType + "/" + id
+}
+
+// Add or replace an object. The metadata is any value that converts to the json of the CSS object metadata, such as
+// the MetaData struct of the sync service.
+func (s *Server) AddObject(meta interface{}) error {
+	m, err := toDoc(meta)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.putObjectMeta(m)
+	return nil
+}
+
+func (s *Server) putObjectMeta(meta map[string]interface{}) string {
+	key := objectKey(fmt.Sprintf("%v", meta["destinationOrgID"]), fmt.Sprintf("%v", meta["objectType"]), fmt.Sprintf("%v", meta["objectID"]))
+	obj, ok := s.objects[key]
+	if !ok {
+		obj = &cssObject{destinations: make([]map[string]interface{}, 0)}
+		s.objects[key] = obj
+	}
+	obj.meta = meta
+	obj.policyReceived = false
+
+	// Object policy timestamps always go up, even when objects are updated within the same nanosecond.
+	obj.timestamp = time.Now().UnixNano()
+	for _, o := range s.objects {
+		if o != obj && o.timestamp >= obj.timestamp {
+			obj.timestamp = o.timestamp + 1
+		}
+	}
+	return key
+}
+
+// Return the destination policies of the objects in an org. The service query parameter selects the objects that
+// use a service. Otherwise, the since parameter selects the policies changed after a timestamp and received=true
+// selects all the policies, including those that have already been received.
+func (s *Server) getObjectPolicies(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	org := mux.Vars(r)["org"]
+	service := query.Get("service")
+	since, _ := strconv.ParseInt(query.Get("since"), 10, 64)
+	received := query.Get("received") == "true"
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	policies := make([]map[string]interface{}, 0)
+	for _, key := range sortedObjectKeys(s.objects) {
+		obj := s.objects[key]
+		if !strings.HasPrefix(key, org+"/") {
+			continue
+		}
+		pol := obj.destinationPolicy()
+		if pol == nil {
+			continue
+		} else if service != "" && !obj.usesService(service) {
+			continue
+		} else if service == "" && since != 0 && obj.timestamp <= since {
+			continue
+		} else if service == "" && since == 0 && !received && obj.policyReceived {
+			continue
+		}
+		policies = append(policies, pol)
+	}
+	writeJSON(w, http.StatusOK, policies)
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if obj, ok := s.objects[objectKey(vars["org"], vars["type"], vars["id"])]; ok {
+		writeJSON(w, http.StatusOK, obj.meta)
+	} else {
+		writeResult(w, http.StatusNotFound, "object not found")
+	}
+}
+
+// The CSS takes the object metadata in the meta attribute of the body.
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request) {
+	body, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	meta, ok := body["meta"].(map[string]interface{})
+	if !ok {
+		writeResult(w, http.StatusBadRequest, "the object metadata is missing")
+		return
+	}
+	vars := mux.Vars(r)
+	meta["destinationOrgID"] = vars["org"]
+	meta["objectType"] = vars["type"]
+	meta["objectID"] = vars["id"]
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.putObjectMeta(meta)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	key := objectKey(vars["org"], vars["type"], vars["id"])
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.objects[key]; !ok {
+		writeResult(w, http.StatusNotFound, "object not found")
+		return
+	}
+	delete(s.objects, key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getObjectDestinations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if obj, ok := s.objects[objectKey(vars["org"], vars["type"], vars["id"])]; ok {
+		writeJSON(w, http.StatusOK, obj.destinations)
+	} else {
+		writeResult(w, http.StatusNotFound, "object not found")
+	}
+}
+
+// Add or remove destinations, each in the form <destinationType>:<destinationID>.
+func (s *Server) postObjectDestinations(w http.ResponseWriter, r *http.Request) {
+	body, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	vars := mux.Vars(r)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	obj, ok := s.objects[objectKey(vars["org"], vars["type"], vars["id"])]
+	if !ok {
+		writeResult(w, http.StatusNotFound, "object not found")
+		return
+	}
+
+	dests, _ := body["destinations"].([]interface{})
+	for _, d := range dests {
+		parts := strings.SplitN(fmt.Sprintf("%v", d), ":", 2)
+		if len(parts) != 2 {
+			writeResult(w, http.StatusBadRequest, fmt.Sprintf("invalid destination %v", d))
+			return
+		}
+
+		kept := make([]map[string]interface{}, 0, len(obj.destinations))
+		for _, existing := range obj.destinations {
+			if existing["destinationType"] != parts[0] || existing["destinationID"] != parts[1] {
+				kept = append(kept, existing)
+			}
+		}
+		if body["action"] == "add" {
+			kept = append(kept, map[string]interface{}{"destinationType": parts[0], "destinationID": parts[1], "status": "delivered", "message": ""})
+		}
+		obj.destinations = kept
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) policyReceived(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if obj, ok := s.objects[objectKey(vars["org"], vars["type"], vars["id"])]; ok {
+		obj.policyReceived = true
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		writeResult(w, http.StatusNotFound, "object not found")
+	}
+}
+
+func sortedObjectKeys(m map[string]*cssObject) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ----- Secrets -----
+
+// The parts of the agbot secure API that check whether secrets exist. Secret values are not kept.
+func (s *Server) secretsRoutes(r *mux.Router) {
+	r.HandleFunc(`/org/{org}/secrets/user/{user}/{secret:[\w\/\-]+}`, s.auth(s.secret)).Methods("LIST", "PUT", "POST", "DELETE")
+	r.HandleFunc(`/org/{org}/secrets/{secret:[\w\/\-]+}`, s.auth(s.secret)).Methods("LIST", "PUT", "POST", "DELETE")
+}
+
+func secretKey(org string, user string, name string) string {
+	if user != "" {
+		return fmt.Sprintf("%v/user/%v/%v", org, user, name)
+	}
+	return org + "/" + name
+}
+
+// Add a secret to the org, or to a user in the org when user is not empty.
+func (s *Server) AddSecret(org string, user string, name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.secrets[secretKey(org, user, name)] = true
+}
+
+func (s *Server) secret(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	key := secretKey(vars["org"], vars["user"], vars["secret"])
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch r.Method {
+	case "LIST":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"exists": s.secrets[key]})
+	case "PUT", "POST":
+		s.secrets[key] = true
+		writeResult(w, http.StatusCreated, "secret "+key+" added")
+	case "DELETE":
+		if !s.secrets[key] {
+			writeResult(w, http.StatusNotFound, "secret not found")
+			return
+		}
+		delete(s.secrets, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
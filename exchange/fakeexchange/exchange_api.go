@@ -0,0 +1,668 @@
+package fakeexchange
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/open-horizon/anax/cutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The exchange REST APIs used by the agent, the agbot and hzn.
+func (s *Server) exchangeRoutes(r *mux.Router) {
+	r.HandleFunc("/admin/version", s.version).Methods("GET")
+	r.HandleFunc("/changes/maxchangeid", s.auth(s.maxChangeId)).Methods("GET")
+	r.HandleFunc("/orgs/{org}/changes", s.auth(s.getChanges)).Methods("POST")
+
+	r.HandleFunc("/orgs/{org}", s.auth(s.getOne("orgs", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}", s.auth(s.put)).Methods("PUT", "POST")
+	r.HandleFunc("/orgs/{org}", s.auth(s.patch)).Methods("PATCH")
+	r.HandleFunc("/orgs/{org}", s.auth(s.delete)).Methods("DELETE")
+
+	r.HandleFunc("/orgs/{org}/users", s.auth(s.getList("users", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/users/{id}", s.auth(s.getOne("users", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/users/{id}", s.auth(s.putUser)).Methods("PUT", "POST")
+	r.HandleFunc("/orgs/{org}/users/{id}", s.auth(s.delete)).Methods("DELETE")
+
+	// Nodes
+	r.HandleFunc("/orgs/{org}/nodes", s.auth(s.getList("nodes", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/nodes/{id}", s.auth(s.getOne("nodes", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/nodes/{id}", s.auth(s.putNode)).Methods("PUT")
+	r.HandleFunc("/orgs/{org}/nodes/{id}", s.auth(s.patch)).Methods("PATCH")
+	r.HandleFunc("/orgs/{org}/nodes/{id}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/{sub:policy|status|errors}", s.auth(s.getDoc)).Methods("GET")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/{sub:policy|status|errors}", s.auth(s.put)).Methods("PUT")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/{sub:policy|status|errors}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/services_configstate", s.auth(s.put)).Methods("POST")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/heartbeat", s.auth(s.heartbeat)).Methods("POST")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/agreements", s.auth(s.getList("agreements", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/agreements", s.auth(s.deleteChildren)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/agreements/{agid}", s.auth(s.getOne("agreements", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/agreements/{agid}", s.auth(s.put)).Methods("PUT")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/agreements/{agid}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/msgs", s.auth(s.getMessages("agbotId", "agbotPubKey"))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/msgs", s.auth(s.postMessage("agbotId", "agbotPubKey", "agbots"))).Methods("POST")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/msgs/{msgid}", s.auth(s.getMessage)).Methods("GET")
+	r.HandleFunc("/orgs/{org}/nodes/{id}/msgs/{msgid}", s.auth(s.deleteMessage)).Methods("DELETE")
+
+	// Agbots
+	r.HandleFunc("/orgs/{org}/agbots", s.auth(s.getList("agbots", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}", s.auth(s.getOne("agbots", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}", s.auth(s.put)).Methods("PUT")
+	r.HandleFunc("/orgs/{org}/agbots/{id}", s.auth(s.patch)).Methods("PATCH")
+	r.HandleFunc("/orgs/{org}/agbots/{id}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/heartbeat", s.auth(s.heartbeat)).Methods("POST")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/patterns", s.auth(s.getList("patterns", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/patterns", s.auth(s.postServed("patternOrgid", "pattern"))).Methods("POST")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/patterns/{pid}", s.auth(s.getOne("patterns", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/patterns/{pid}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/businesspols", s.auth(s.getList("businessPols", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/businesspols", s.auth(s.postServed("businessPolOrgid", "businessPol"))).Methods("POST")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/businesspols/{pid}", s.auth(s.getOne("businessPols", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/businesspols/{pid}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/agreements", s.auth(s.getList("agreements", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/agreements/{agid}", s.auth(s.getOne("agreements", false))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/agreements/{agid}", s.auth(s.put)).Methods("PUT")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/agreements/{agid}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/msgs", s.auth(s.getMessages("nodeId", "nodePubKey"))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/msgs", s.auth(s.postMessage("nodeId", "nodePubKey", "nodes"))).Methods("POST")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/msgs/{msgid}", s.auth(s.getMessage)).Methods("GET")
+	r.HandleFunc("/orgs/{org}/agbots/{id}/msgs/{msgid}", s.auth(s.deleteMessage)).Methods("DELETE")
+
+	// Services
+	r.HandleFunc("/orgs/{org}/services", s.auth(s.searchServices)).Methods("GET")
+	r.HandleFunc("/orgs/{org}/services", s.auth(s.postService)).Methods("POST")
+	r.HandleFunc("/orgs/{org}/services/{id}", s.auth(s.getOne("services", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/services/{id}", s.auth(s.put)).Methods("PUT")
+	r.HandleFunc("/orgs/{org}/services/{id}", s.auth(s.patch)).Methods("PATCH")
+	r.HandleFunc("/orgs/{org}/services/{id}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/services/{id}/policy", s.auth(s.getDoc)).Methods("GET")
+	r.HandleFunc("/orgs/{org}/services/{id}/policy", s.auth(s.put)).Methods("PUT")
+	r.HandleFunc("/orgs/{org}/services/{id}/policy", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/services/{id}/{sub:keys|dockauths}", s.auth(s.emptyList)).Methods("GET")
+
+	// Patterns
+	r.HandleFunc("/orgs/{org}/patterns", s.auth(s.getList("patterns", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/patterns/{id}", s.auth(s.getOne("patterns", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/patterns/{id}", s.auth(s.put)).Methods("PUT", "POST")
+	r.HandleFunc("/orgs/{org}/patterns/{id}", s.auth(s.patch)).Methods("PATCH")
+	r.HandleFunc("/orgs/{org}/patterns/{id}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/patterns/{id}/keys", s.auth(s.emptyList)).Methods("GET")
+	r.HandleFunc("/orgs/{org}/patterns/{id}/search", s.auth(s.searchNodes(true))).Methods("POST")
+	r.HandleFunc("/orgs/{org}/patterns/{id}/nodehealth", s.auth(s.nodeHealth(true))).Methods("POST")
+
+	// Deployment policies
+	r.HandleFunc("/orgs/{org}/business/policies", s.auth(s.getList("businessPolicy", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/business/policies/{id}", s.auth(s.getOne("businessPolicy", true))).Methods("GET")
+	r.HandleFunc("/orgs/{org}/business/policies/{id}", s.auth(s.put)).Methods("PUT", "POST")
+	r.HandleFunc("/orgs/{org}/business/policies/{id}", s.auth(s.patch)).Methods("PATCH")
+	r.HandleFunc("/orgs/{org}/business/policies/{id}", s.auth(s.delete)).Methods("DELETE")
+	r.HandleFunc("/orgs/{org}/business/policies/{id}/search", s.auth(s.searchNodes(false))).Methods("POST")
+
+	r.HandleFunc("/orgs/{org}/search/nodehealth", s.auth(s.nodeHealth(false))).Methods("POST")
+}
+
+// Reject requests without valid credentials, like the exchange does.
+func (s *Server) auth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.authenticate(r); !ok {
+			writeResult(w, http.StatusUnauthorized, "invalid credentials")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// The path of the resource addressed by a request, without the /v1/ prefix.
+func docKey(r *http.Request) string {
+	return strings.Trim(strings.TrimPrefix(r.URL.Path, EXCHANGE_PATH), "/")
+}
+
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(EXCHANGE_VERSION))
+}
+
+func (s *Server) maxChangeId(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"maxChangeId": s.MaxChangeId()})
+}
+
+// Return the changes since the requested change id. A caller sees the changes in its own org, in the orgs it asks
+// for, and the changes to public resources.
+func (s *Server) getChanges(w http.ResponseWriter, r *http.Request) {
+	req, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	changeId := uint64(0)
+	if n, ok := req["changeId"]; ok {
+		if v, err := strconv.ParseUint(fmt.Sprintf("%v", n), 10, 64); err == nil {
+			changeId = v
+		}
+	}
+	maxRecords := 0
+	if n, ok := req["maxRecords"]; ok {
+		maxRecords, _ = strconv.Atoi(fmt.Sprintf("%v", n))
+	}
+	orgs := map[string]bool{mux.Vars(r)["org"]: true}
+	if list, ok := req["orgList"].([]interface{}); ok {
+		for _, o := range list {
+			orgs[fmt.Sprintf("%v", o)] = true
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	changes := make([]map[string]interface{}, 0)
+	mostRecent := uint64(len(s.changes))
+	for ix := int(changeId); ix <= len(s.changes); ix++ {
+		if ix == 0 {
+			continue
+		}
+		c := s.changes[ix-1]
+		if c.public || orgs["*"] || orgs[c.record["orgid"].(string)] {
+			changes = append(changes, c.record)
+			if maxRecords > 0 && len(changes) >= maxRecords {
+				mostRecent = uint64(ix)
+				break
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"changes":            changes,
+		"mostRecentChangeId": mostRecent,
+		"hitMaxRecords":      maxRecords > 0 && len(changes) >= maxRecords,
+		"exchangeVersion":    EXCHANGE_VERSION,
+	})
+}
+
+// Return a single resource in the same form as a list of resources. The key of the resource is its org/id when
+// orgKey is true, otherwise just its id.
+func (s *Server) getOne(listKey string, orgKey bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := docKey(r)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		doc, ok := s.docs[key]
+		if !ok {
+			writeResult(w, http.StatusNotFound, "not found")
+			return
+		}
+		id := key[strings.LastIndex(key, "/")+1:]
+		if orgKey && listKey != "orgs" {
+			id = mux.Vars(r)["org"] + "/" + id
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{listKey: map[string]interface{}{id: doc}, "lastIndex": 0})
+	}
+}
+
+func (s *Server) getList(listKey string, orgKey bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		list := make(map[string]interface{})
+		for id, doc := range s.children(docKey(r)) {
+			if orgKey {
+				id = mux.Vars(r)["org"] + "/" + id
+			}
+			list[id] = doc
+		}
+		if len(list) == 0 {
+			writeResult(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{listKey: list, "lastIndex": 0})
+	}
+}
+
+// Return a resource that is not wrapped in a list, such as a node policy.
+func (s *Server) getDoc(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if doc, ok := s.docs[docKey(r)]; ok {
+		writeJSON(w, http.StatusOK, doc)
+	} else {
+		writeResult(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) emptyList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, []interface{}{})
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request) {
+	doc, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	key := docKey(r)
+	org, resource, id := changeFor(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.putDoc(key, doc, org, resource, id, false)
+	writeResult(w, http.StatusCreated, key+" added or updated")
+}
+
+// Merge the attributes in the body into the resource.
+func (s *Server) patch(w http.ResponseWriter, r *http.Request) {
+	attrs, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	key := docKey(r)
+	org, resource, id := changeFor(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.docs[key]
+	if !ok {
+		writeResult(w, http.StatusNotFound, "not found")
+		return
+	}
+	for k, v := range attrs {
+		doc[k] = v
+	}
+	s.putDoc(key, doc, org, resource, id, false)
+	writeResult(w, http.StatusCreated, key+" updated")
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	key := docKey(r)
+	org, resource, id := changeFor(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.deleteDoc(key, org, resource, id) {
+		writeResult(w, http.StatusNotFound, "not found")
+		return
+	}
+	// Nodes, agbots and users can no longer authenticate once they are deleted.
+	if parts := strings.Split(key, "/"); len(parts) == 4 && (parts[2] == "nodes" || parts[2] == "agbots" || parts[2] == "users") {
+		delete(s.creds, parts[1]+"/"+parts[3])
+		delete(s.messages, key)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteChildren(w http.ResponseWriter, r *http.Request) {
+	key := docKey(r)
+	org, resource, id := changeFor(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	children := s.children(key)
+	if len(children) == 0 {
+		writeResult(w, http.StatusNotFound, "not found")
+		return
+	}
+	for child := range children {
+		delete(s.docs, key+"/"+child)
+	}
+	s.addChange(org, resource, id, CHANGE_OPERATION_DELETED, false)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) putUser(w http.ResponseWriter, r *http.Request) {
+	doc, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	vars := mux.Vars(r)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if pw, ok := doc["password"].(string); ok && pw != "" {
+		s.creds[vars["org"]+"/"+vars["id"]] = pw
+	}
+	doc["password"] = ""
+	s.docs[docKey(r)] = doc
+	writeResult(w, http.StatusCreated, "user added or updated")
+}
+
+// Register a node. The token in the body becomes the node's credentials.
+func (s *Server) putNode(w http.ResponseWriter, r *http.Request) {
+	doc, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	vars := mux.Vars(r)
+	caller, _ := s.authenticate(r)
+	key := docKey(r)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if token, ok := doc["token"].(string); ok && token != "" {
+		s.creds[vars["org"]+"/"+vars["id"]] = token
+	}
+	if existing, ok := s.docs[key]; ok {
+		doc["owner"] = existing["owner"]
+		doc["lastHeartbeat"] = existing["lastHeartbeat"]
+	} else if caller != vars["org"]+"/"+vars["id"] {
+		doc["owner"] = caller
+	}
+	s.putDoc(key, s.newNode(doc), vars["org"], RESOURCE_NODE, vars["id"], false)
+	writeResult(w, http.StatusCreated, "node added or updated")
+}
+
+func (s *Server) heartbeat(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSuffix(docKey(r), "/heartbeat")
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.docs[key]
+	if !ok {
+		writeResult(w, http.StatusNotFound, "not found")
+		return
+	}
+	doc["lastHeartbeat"] = now()
+	writeResult(w, http.StatusCreated, "heartbeat successful")
+}
+
+// Add a pattern or deployment policy to the list served by an agbot.
+func (s *Server) postServed(orgField string, nameField string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, err := readDoc(r)
+		if err != nil {
+			writeResult(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id := fmt.Sprintf("%v_%v_%v", doc[orgField], doc[nameField], doc["nodeOrgid"])
+		key := docKey(r) + "/" + id
+		org, resource, agbotId := changeFor(key)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.putDoc(key, doc, org, resource, agbotId, false)
+		writeResult(w, http.StatusCreated, id+" added")
+	}
+}
+
+// Create a service, the id of a service is formed from its url, version and arch.
+func (s *Server) postService(w http.ResponseWriter, r *http.Request) {
+	doc, err := readDoc(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id := cutil.FormExchangeIdForService(fmt.Sprintf("%v", doc["url"]), fmt.Sprintf("%v", doc["version"]), fmt.Sprintf("%v", doc["arch"]))
+	key := docKey(r) + "/" + id
+	caller, _ := s.authenticate(r)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.docs[key]; ok {
+		writeResult(w, http.StatusConflict, "service already exists")
+		return
+	}
+	doc["owner"] = caller
+	s.putDoc(key, doc, mux.Vars(r)["org"], RESOURCE_SERVICE, id, false)
+	writeResult(w, http.StatusCreated, "service "+id+" added")
+}
+
+// Return the services of an org that match the url, version and arch query parameters.
+func (s *Server) searchServices(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	org := mux.Vars(r)["org"]
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	services := make(map[string]interface{})
+	for id, doc := range s.children(docKey(r)) {
+		match := true
+		for _, attr := range []string{"url", "version", "arch"} {
+			if q := query.Get(attr); q != "" && fmt.Sprintf("%v", doc[attr]) != q {
+				match = false
+			}
+		}
+		if match {
+			services[org+"/"+id] = doc
+		}
+	}
+	if len(services) == 0 {
+		writeResult(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"services": services, "lastIndex": 0})
+}
+
+// The node orgs in a search request, defaulting to the org of the pattern or policy.
+func searchOrgs(req map[string]interface{}, org string) map[string]bool {
+	orgs := make(map[string]bool)
+	if list, ok := req["nodeOrgids"].([]interface{}); ok {
+		for _, o := range list {
+			orgs[fmt.Sprintf("%v", o)] = true
+		}
+	}
+	if len(orgs) == 0 {
+		orgs[org] = true
+	}
+	return orgs
+}
+
+// The registered nodes (those with a public key) in the orgs, that use the pattern or that use policy when the
+// pattern is empty.
+func (s *Server) registeredNodes(orgs map[string]bool, pattern string) []string {
+	nodes := make([]string, 0)
+	for key, doc := range s.docs {
+		parts := strings.Split(key, "/")
+		if len(parts) != 4 || parts[2] != "nodes" || !orgs[parts[1]] {
+			continue
+		} else if pk, _ := doc["publicKey"].(string); pk == "" {
+			continue
+		} else if p, _ := doc["pattern"].(string); p != pattern {
+			continue
+		}
+		nodes = append(nodes, parts[1]+"/"+parts[3])
+	}
+	return nodes
+}
+
+// Search for the nodes that a pattern or a deployment policy could be deployed to.
+func (s *Server) searchNodes(isPattern bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := readDoc(r)
+		if err != nil {
+			writeResult(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		vars := mux.Vars(r)
+		pattern := ""
+		if isPattern {
+			pattern = vars["org"] + "/" + vars["id"]
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if _, ok := s.docs[strings.TrimSuffix(docKey(r), "/search")]; !ok {
+			writeResult(w, http.StatusNotFound, "not found")
+			return
+		}
+
+		results := make([]map[string]interface{}, 0)
+		for _, node := range s.registeredNodes(searchOrgs(req, vars["org"]), pattern) {
+			doc := s.docs["orgs/"+strings.Replace(node, "/", "/nodes/", 1)]
+			// Nodes using policy are only found once they have a node policy.
+			if _, ok := s.docs["orgs/"+strings.Replace(node, "/", "/nodes/", 1)+"/policy"]; !isPattern && !ok {
+				continue
+			}
+			results = append(results, map[string]interface{}{"id": node, "nodeType": doc["nodeType"], "publicKey": doc["publicKey"]})
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"nodes": results, "lastIndex": 0})
+	}
+}
+
+// Return the last heartbeat and the agreements of the nodes in a pattern, or of all the nodes in the orgs.
+func (s *Server) nodeHealth(isPattern bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := readDoc(r)
+		if err != nil {
+			writeResult(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		vars := mux.Vars(r)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		nodes := make([]string, 0)
+		orgs := searchOrgs(req, vars["org"])
+		if isPattern {
+			nodes = s.registeredNodes(orgs, vars["org"]+"/"+vars["id"])
+		} else {
+			for org := range orgs {
+				for id := range s.children("orgs/" + org + "/nodes") {
+					nodes = append(nodes, org+"/"+id)
+				}
+			}
+		}
+
+		health := make(map[string]interface{})
+		for _, node := range nodes {
+			key := "orgs/" + strings.Replace(node, "/", "/nodes/", 1)
+			agreements := make(map[string]interface{})
+			for agId := range s.children(key + "/agreements") {
+				agreements[agId] = map[string]interface{}{}
+			}
+			health[node] = map[string]interface{}{"lastHeartbeat": s.docs[key]["lastHeartbeat"], "agreements": agreements}
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"nodes": health})
+	}
+}
+
+// ----- Messages -----
+
+// Messages are kept per receiver. A message records the sender and the sender's public key under the field names
+// used by the receiver's message API.
+func (s *Server) postMessage(senderField string, keyField string, senderKind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := readDoc(r)
+		if err != nil {
+			writeResult(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		receiver := strings.TrimSuffix(docKey(r), "/msgs")
+		sender, _ := s.authenticate(r)
+		ttl := 180
+		if n, ok := req["ttl"]; ok {
+			if v, err := strconv.Atoi(fmt.Sprintf("%v", n)); err == nil && v > 0 {
+				ttl = v
+			}
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if _, ok := s.docs[receiver]; !ok {
+			writeResult(w, http.StatusNotFound, "the message receiver does not exist")
+			return
+		}
+
+		pubKey := ""
+		if parts := strings.SplitN(sender, "/", 2); len(parts) == 2 {
+			if doc, ok := s.docs[fmt.Sprintf("orgs/%v/%v/%v", parts[0], senderKind, parts[1])]; ok {
+				pubKey, _ = doc["publicKey"].(string)
+			}
+		}
+
+		msgId := s.nextMsgId
+		s.nextMsgId++
+		s.messages[receiver] = append(s.messages[receiver], map[string]interface{}{
+			"msgId":       msgId,
+			senderField:   sender,
+			keyField:      pubKey,
+			"message":     req["message"],
+			"timeSent":    now(),
+			"timeExpires": time.Now().Add(time.Duration(ttl) * time.Second).UTC().Format(cutil.ExchangeTimeFormat),
+			"expires":     time.Now().Add(time.Duration(ttl) * time.Second),
+		})
+		org, resource, id := changeFor(docKey(r))
+		s.addChange(org, resource, id, CHANGE_OPERATION_CREATED, false)
+		writeResult(w, http.StatusCreated, fmt.Sprintf("message %v added", msgId))
+	}
+}
+
+func (s *Server) getMessages(senderField string, keyField string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		receiver := strings.TrimSuffix(docKey(r), "/msgs")
+		max, _ := strconv.Atoi(r.URL.Query().Get("maxmsgs"))
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		// Drop the expired messages.
+		kept := make([]map[string]interface{}, 0)
+		for _, m := range s.messages[receiver] {
+			if m["expires"].(time.Time).After(time.Now()) {
+				kept = append(kept, m)
+			}
+		}
+		s.messages[receiver] = kept
+
+		msgs := make([]map[string]interface{}, 0)
+		for _, m := range kept {
+			if max > 0 && len(msgs) >= max {
+				break
+			}
+			msg := make(map[string]interface{})
+			for k, v := range m {
+				if k != "expires" {
+					msg[k] = v
+				}
+			}
+			msgs = append(msgs, msg)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"messages": msgs, "lastIndex": 0})
+	}
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
+	key := docKey(r)
+	receiver := key[:strings.LastIndex(key, "/msgs/")]
+	msgId, _ := strconv.Atoi(mux.Vars(r)["msgid"])
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, m := range s.messages[receiver] {
+		if m["msgId"] == msgId && m["expires"].(time.Time).After(time.Now()) {
+			msg := make(map[string]interface{})
+			for k, v := range m {
+				if k != "expires" {
+					msg[k] = v
+				}
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"messages": []interface{}{msg}, "lastIndex": 0})
+			return
+		}
+	}
+	writeResult(w, http.StatusNotFound, "message not found")
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	key := docKey(r)
+	receiver := key[:strings.LastIndex(key, "/msgs/")]
+	msgId, _ := strconv.Atoi(mux.Vars(r)["msgid"])
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for ix, m := range s.messages[receiver] {
+		if m["msgId"] == msgId {
+			s.messages[receiver] = append(s.messages[receiver][:ix], s.messages[receiver][ix+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeResult(w, http.StatusNotFound, "message not found")
+}
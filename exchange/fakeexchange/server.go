@@ -0,0 +1,513 @@
+package fakeexchange
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/gorilla/mux"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This package is an in-memory stand-in for the parts of the Exchange, the CSS and the agbot secure (secrets) API that
+// anax uses. It is stateful, so a node registered through it can be read back, messages sent to a node are returned
+// when the node polls for them, and every change is recorded so that the /changes API works. It is meant for tests
+// and offline development, it does not implement the exchange's access control beyond checking credentials.
+//
+// The server is plugged into anax through the HTTPClientFactory in the config, so the agent and the agbot can use it
+// in the same process without a network. It can also listen on a local port so that hzn can be pointed at it.
+
+// The default host used in the URLs of the fake, requests are never sent to it when the in-process transport is used.
+const FAKE_HOST = "http://fake.exchange"
+
+// The version returned by the admin/version API.
+const EXCHANGE_VERSION = "2.90.0"
+
+// The path prefixes of the APIs served by the fake.
+const (
+	EXCHANGE_PATH = "/v1/"
+	CSS_PATH      = "/css"
+)
+
+type Server struct {
+	lock        sync.Mutex
+	baseURL     string
+	router      *mux.Router
+	listener    net.Listener
+	DisableAuth bool // Set to true to accept requests without checking their credentials.
+	offline     bool // The server cannot be reached, see SetOffline.
+
+	docs      map[string]map[string]interface{} // The exchange resources, keyed by their path without the /v1/ prefix
+	creds     map[string]string                 // The token or password of each node, agbot and user, keyed by org/id
+	messages  map[string][]map[string]interface{}
+	nextMsgId int
+	changes   []change
+	objects   map[string]*cssObject
+	secrets   map[string]bool
+	transport http.RoundTripper
+}
+
+// A change record, along with whether the changed resource is visible to all orgs.
+type change struct {
+	record map[string]interface{}
+	public bool
+}
+
+func NewServer() *Server {
+	s := &Server{
+		baseURL:   FAKE_HOST,
+		docs:      make(map[string]map[string]interface{}),
+		creds:     make(map[string]string),
+		messages:  make(map[string][]map[string]interface{}),
+		nextMsgId: 1,
+		changes:   make([]change, 0),
+		objects:   make(map[string]*cssObject),
+		secrets:   make(map[string]bool),
+	}
+	s.router = mux.NewRouter()
+	s.exchangeRoutes(s.router.PathPrefix(strings.TrimRight(EXCHANGE_PATH, "/")).Subrouter())
+	s.cssRoutes(s.router.PathPrefix(CSS_PATH).Subrouter())
+	s.secretsRoutes(s.router)
+	s.transport = &inProcessTransport{server: s}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	glog.V(5).Infof(fakeLogString(fmt.Sprintf("%v %v", r.Method, r.URL)))
+	if s.IsOffline() {
+		writeResult(w, http.StatusServiceUnavailable, "the exchange is offline")
+		return
+	}
+	s.router.ServeHTTP(w, r)
+}
+
+// Take the server offline or bring it back, to test how clients behave when the exchange comes and goes. While the
+// server is offline, requests through the in-process transport fail as if the exchange could not be reached, and
+// requests to the listening address get a 503. The state of the server is kept.
+func (s *Server) SetOffline(offline bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.offline = offline
+}
+
+func (s *Server) IsOffline() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.offline
+}
+
+// The exchange URL, with a trailing slash like anax expects.
+func (s *Server) ExchangeURL() string {
+	return s.getBaseURL() + EXCHANGE_PATH
+}
+
+func (s *Server) CSSURL() string {
+	return s.getBaseURL() + CSS_PATH
+}
+
+// The URL of the agbot secure API, which anax and hzn use for secrets.
+func (s *Server) AgbotURL() string {
+	return s.getBaseURL()
+}
+
+// The base URL changes when the server starts listening, which can happen while clients are using the server.
+func (s *Server) getBaseURL() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.baseURL
+}
+
+// An http.RoundTripper that hands requests directly to the server.
+type inProcessTransport struct {
+	server *Server
+}
+
+func (t *inProcessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.server.IsOffline() {
+		return nil, fmt.Errorf("dial tcp %v: connect: connection refused", req.URL.Host)
+	}
+	rec := httptest.NewRecorder()
+	t.server.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// Returns an HTTPClientFactory whose clients send all requests to the server, whatever the host in the URL.
+func (s *Server) HTTPClientFactory() *config.HTTPClientFactory {
+	return &config.HTTPClientFactory{
+		NewHTTPClient: func(overrideTimeoutS *uint) *http.Client {
+			return &http.Client{Transport: s.transport}
+		},
+		RetryCount:    1,
+		RetryInterval: 1,
+	}
+}
+
+// Point the exchange and CSS configuration of an agent or agbot at the server.
+func (s *Server) Configure(cfg *config.HorizonConfig) {
+	cfg.Edge.ExchangeURL = s.ExchangeURL()
+	cfg.Edge.FileSyncService.CSSURL = s.CSSURL()
+	cfg.AgreementBot.ExchangeURL = s.ExchangeURL()
+	cfg.AgreementBot.CSSURL = s.CSSURL()
+	cfg.Collaborators.HTTPClientFactory = s.HTTPClientFactory()
+}
+
+// Serve the fake on a local address, for clients in other processes such as hzn. Use an address like
+// "127.0.0.1:0" to pick a free port. The URLs returned by the server use the listening address from now on.
+func (s *Server) Listen(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	baseURL := "http://" + listener.Addr().String()
+	s.lock.Lock()
+	s.listener = listener
+	s.baseURL = baseURL
+	s.lock.Unlock()
+
+	go http.Serve(listener, s)
+	return baseURL, nil
+}
+
+func (s *Server) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+}
+
+// ----- Seeding the fake -----
+
+// Add an organization. The org document is optional.
+func (s *Server) AddOrg(org string, doc map[string]interface{}) {
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.putDoc("orgs/"+org, doc, org, RESOURCE_ORG, org, false)
+}
+
+// Add a user that can authenticate with the password.
+func (s *Server) AddUser(org string, user string, password string, admin bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.docs[fmt.Sprintf("orgs/%v/users/%v", org, user)] = map[string]interface{}{"admin": admin, "email": "", "password": ""}
+	s.creds[org+"/"+user] = password
+}
+
+// Add a node that can authenticate with the token. The node document is optional.
+func (s *Server) AddNode(org string, id string, token string, doc map[string]interface{}) {
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.creds[org+"/"+id] = token
+	s.putDoc(fmt.Sprintf("orgs/%v/nodes/%v", org, id), s.newNode(doc), org, RESOURCE_NODE, id, false)
+}
+
+// Add an agbot that can authenticate with the token.
+func (s *Server) AddAgbot(org string, id string, token string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.creds[org+"/"+id] = token
+	s.putDoc(fmt.Sprintf("orgs/%v/agbots/%v", org, id), map[string]interface{}{"name": id, "publicKey": ""}, org, RESOURCE_AGBOT, id, false)
+}
+
+// Add or replace any exchange resource, for example "orgs/myorg/services/<id>" or "orgs/myorg/nodes/node1/policy".
+// The document is converted to json and back so that any struct of the exchange package can be used.
+func (s *Server) Put(resourcePath string, doc interface{}) error {
+	m, err := toDoc(doc)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	org, resource, id := changeFor(resourcePath)
+	s.putDoc(strings.Trim(resourcePath, "/"), m, org, resource, id, false)
+	return nil
+}
+
+// Return a copy of an exchange resource, or nil if it does not exist.
+func (s *Server) Get(resourcePath string) map[string]interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if doc, ok := s.docs[strings.Trim(resourcePath, "/")]; ok {
+		m, _ := toDoc(doc)
+		return m
+	}
+	return nil
+}
+
+// Return the id of the most recent change.
+func (s *Server) MaxChangeId() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return uint64(len(s.changes))
+}
+
+// ----- Internal helpers, the caller holds the lock -----
+
+// The resource types recorded by the changes API.
+const (
+	RESOURCE_NODE_MSG                  = "nodemsgs"
+	RESOURCE_AGBOT_MSG                 = "agbotmsgs"
+	RESOURCE_NODE                      = "node"
+	RESOURCE_AGBOT                     = "agbot"
+	RESOURCE_NODE_POLICY               = "nodepolicies"
+	RESOURCE_NODE_AGREEMENTS           = "nodeagreements"
+	RESOURCE_NODE_STATUS               = "nodestatus"
+	RESOURCE_NODE_ERROR                = "nodeerrors"
+	RESOURCE_NODE_SERVICES_CONFIGSTATE = "services_configstate"
+	RESOURCE_SERVICE                   = "service"
+	RESOURCE_SERVICE_POLICY            = "servicepolicies"
+	RESOURCE_AGBOT_SERVED_POLICY       = "agbotbusinesspols"
+	RESOURCE_AGBOT_SERVED_PATTERN      = "agbotpatterns"
+	RESOURCE_AGBOT_AGREEMENTS          = "agbotagreements"
+	RESOURCE_PATTERN                   = "pattern"
+	RESOURCE_POLICY                    = "policy"
+	RESOURCE_ORG                       = "org"
+)
+
+const (
+	CHANGE_OPERATION_CREATED          = "created"
+	CHANGE_OPERATION_CREATED_MODIFIED = "created/modified"
+	CHANGE_OPERATION_MODIFIED         = "modified"
+	CHANGE_OPERATION_DELETED          = "deleted"
+)
+
+// Work out the change record for a resource path, as the exchange would record it.
+func changeFor(resourcePath string) (string, string, string) {
+	parts := strings.Split(strings.Trim(resourcePath, "/"), "/")
+	if len(parts) < 2 || parts[0] != "orgs" {
+		return "", "", ""
+	}
+	org := parts[1]
+	if len(parts) == 2 {
+		return org, RESOURCE_ORG, org
+	} else if len(parts) < 4 {
+		return org, "", ""
+	}
+
+	id := parts[3]
+	sub := ""
+	if len(parts) > 4 {
+		sub = parts[4]
+	}
+
+	switch parts[2] {
+	case "nodes":
+		switch sub {
+		case "":
+			return org, RESOURCE_NODE, id
+		case "policy":
+			return org, RESOURCE_NODE_POLICY, id
+		case "agreements":
+			return org, RESOURCE_NODE_AGREEMENTS, id
+		case "status":
+			return org, RESOURCE_NODE_STATUS, id
+		case "errors":
+			return org, RESOURCE_NODE_ERROR, id
+		case "services_configstate":
+			return org, RESOURCE_NODE_SERVICES_CONFIGSTATE, id
+		case "msgs":
+			return org, RESOURCE_NODE_MSG, id
+		}
+	case "agbots":
+		switch sub {
+		case "":
+			return org, RESOURCE_AGBOT, id
+		case "patterns":
+			return org, RESOURCE_AGBOT_SERVED_PATTERN, id
+		case "businesspols":
+			return org, RESOURCE_AGBOT_SERVED_POLICY, id
+		case "agreements":
+			return org, RESOURCE_AGBOT_AGREEMENTS, id
+		case "msgs":
+			return org, RESOURCE_AGBOT_MSG, id
+		}
+	case "services":
+		if sub == "policy" {
+			return org, RESOURCE_SERVICE_POLICY, id
+		}
+		return org, RESOURCE_SERVICE, id
+	case "patterns":
+		return org, RESOURCE_PATTERN, id
+	case "business":
+		if len(parts) > 4 {
+			return org, RESOURCE_POLICY, parts[4]
+		}
+	}
+	return org, "", ""
+}
+
+func (s *Server) addChange(org string, resource string, id string, operation string, public bool) {
+	if resource == "" {
+		return
+	}
+	changeId := uint64(len(s.changes) + 1)
+	s.changes = append(s.changes, change{
+		record: map[string]interface{}{
+			"orgid":           org,
+			"resource":        resource,
+			"id":              id,
+			"operation":       operation,
+			"resourceChanges": []map[string]interface{}{{"changeid": changeId}},
+		},
+		public: public,
+	})
+}
+
+func (s *Server) putDoc(key string, doc map[string]interface{}, org string, resource string, id string, public bool) {
+	op := CHANGE_OPERATION_CREATED
+	if _, ok := s.docs[key]; ok {
+		op = CHANGE_OPERATION_MODIFIED
+	}
+	doc["lastUpdated"] = now()
+	s.docs[key] = doc
+	if p, ok := doc["public"].(bool); ok && p {
+		public = true
+	}
+	s.addChange(org, resource, id, op, public)
+}
+
+// Delete a resource and everything under it.
+func (s *Server) deleteDoc(key string, org string, resource string, id string) bool {
+	if _, ok := s.docs[key]; !ok {
+		return false
+	}
+	for k := range s.docs {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			delete(s.docs, k)
+		}
+	}
+	s.addChange(org, resource, id, CHANGE_OPERATION_DELETED, false)
+	return true
+}
+
+// Return the resources directly under a path, keyed by their id.
+func (s *Server) children(prefix string) map[string]map[string]interface{} {
+	res := make(map[string]map[string]interface{})
+	prefix = strings.Trim(prefix, "/") + "/"
+	for k, v := range s.docs {
+		if strings.HasPrefix(k, prefix) && !strings.Contains(k[len(prefix):], "/") {
+			res[k[len(prefix):]] = v
+		}
+	}
+	return res
+}
+
+func (s *Server) newNode(doc map[string]interface{}) map[string]interface{} {
+	delete(doc, "token")
+	for _, f := range []string{"name", "owner", "nodeType", "pattern", "msgEndPoint", "publicKey", "arch", "lastHeartbeat"} {
+		if _, ok := doc[f]; !ok {
+			doc[f] = ""
+		}
+	}
+	if _, ok := doc["registeredServices"]; !ok {
+		doc["registeredServices"] = []interface{}{}
+	}
+	if _, ok := doc["softwareVersions"]; !ok {
+		doc["softwareVersions"] = map[string]interface{}{}
+	}
+	if _, ok := doc["userInput"]; !ok {
+		doc["userInput"] = []interface{}{}
+	}
+	return doc
+}
+
+// Returns the org/id of the caller, or empty string if the credentials are missing or not valid.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	user, pw, ok := r.BasicAuth()
+	if !ok {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Basic ") {
+			if decoded, err := base64.StdEncoding.DecodeString(auth[6:]); err == nil {
+				if ix := strings.LastIndex(string(decoded), ":"); ix > 0 {
+					user, pw, ok = string(decoded[:ix]), string(decoded[ix+1:]), true
+				}
+			}
+		}
+	}
+
+	if s.DisableAuth {
+		return user, true
+	} else if !ok {
+		return "", false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if token, found := s.creds[user]; found && token == pw {
+		return user, true
+	}
+	return "", false
+}
+
+// ----- Responses -----
+
+func writeJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if payload != nil {
+		if err := json.NewEncoder(w).Encode(payload); err != nil {
+			glog.Errorf(fakeLogString(fmt.Sprintf("unable to write response, error %v", err)))
+		}
+	}
+}
+
+// The exchange returns a code and message for the requests that change resources.
+func writeResult(w http.ResponseWriter, code int, msg string) {
+	result := "ok"
+	if code >= 300 {
+		result = http.StatusText(code)
+	}
+	writeJSON(w, code, map[string]interface{}{"code": result, "msg": msg})
+}
+
+func readDoc(r *http.Request) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+	if r.Body == nil {
+		return doc, nil
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Convert any value to a json object, through its json encoding.
+func toDoc(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func now() string {
+	return time.Now().UTC().Format(cutil.ExchangeTimeFormat)
+}
+
+var fakeLogString = func(v interface{}) string {
+	return fmt.Sprintf("Fake Exchange: %v", v)
+}
@@ -0,0 +1,314 @@
+// +build unit
+
+package fakeexchange
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/edge-sync-service/common"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestServer() *Server {
+	s := NewServer()
+	s.AddOrg("myorg", nil)
+	s.AddUser("myorg", "admin", "adminpw", true)
+	s.AddAgbot("myorg", "ag1", "agtoken")
+	return s
+}
+
+func Test_NodeRegistration(t *testing.T) {
+	s := newTestServer()
+	hf := s.HTTPClientFactory()
+
+	// The node is created by a user, then the node registers itself with its own token.
+	s.AddNode("myorg", "node1", "nodetoken", nil)
+	pdr := &exchange.PutDeviceRequest{Token: "nodetoken", Name: "node1", NodeType: "device", Pattern: "myorg/p1", PublicKey: []byte("key")}
+	if _, err := exchange.PutExchangeDevice(hf, "myorg/node1", "wrongtoken", s.ExchangeURL(), pdr); err == nil {
+		t.Errorf("expected an error using the wrong credentials")
+	}
+	if _, err := exchange.PutExchangeDevice(hf, "myorg/node1", "nodetoken", s.ExchangeURL(), pdr); err != nil {
+		t.Errorf("unexpected error registering node: %v", err)
+	}
+
+	if dev, err := exchange.GetExchangeDevice(hf, "myorg/node1", "myorg/node1", "nodetoken", s.ExchangeURL()); err != nil {
+		t.Errorf("unexpected error getting node: %v", err)
+	} else if assert.NotNil(t, dev) {
+		assert.Equal(t, "node1", dev.Name)
+		assert.Equal(t, "myorg/p1", dev.Pattern)
+	}
+
+	if err := exchange.Heartbeat(hf, s.ExchangeURL()+"orgs/myorg/nodes/node1/heartbeat", "myorg/node1", "nodetoken"); err != nil {
+		t.Errorf("unexpected error heartbeating: %v", err)
+	}
+	assert.NotEmpty(t, s.Get("orgs/myorg/nodes/node1")["lastHeartbeat"], "the heartbeat should be recorded")
+
+	if version, err := exchange.GetExchangeVersion(hf, s.ExchangeURL(), "myorg/node1", "nodetoken"); err != nil {
+		t.Errorf("unexpected error getting the exchange version: %v", err)
+	} else {
+		assert.Equal(t, EXCHANGE_VERSION, version)
+	}
+}
+
+func Test_Changes(t *testing.T) {
+	s := newTestServer()
+	s.AddNode("myorg", "node1", "nodetoken", map[string]interface{}{"publicKey": "key"})
+	s.AddNode("otherorg", "node2", "nodetoken", nil)
+	ec := exchange.NewCustomExchangeContext("myorg/node1", "nodetoken", s.ExchangeURL(), s.CSSURL(), s.HTTPClientFactory())
+
+	maxId, err := exchange.GetHTTPExchangeMaxChangeIDHandler(ec)()
+	if err != nil {
+		t.Fatalf("unexpected error getting the max change id: %v", err)
+	}
+	assert.Equal(t, s.MaxChangeId(), maxId.MaxChangeID)
+
+	pol := externalpolicy.ExternalPolicy{Properties: externalpolicy.PropertyList{{Name: "color", Value: "red"}}}
+	if err := s.Put("orgs/myorg/nodes/node1/policy", pol); err != nil {
+		t.Fatalf("unexpected error saving policy: %v", err)
+	}
+
+	if changes, err := exchange.GetExchangeChanges(ec, maxId.MaxChangeID+1, 100, nil); err != nil {
+		t.Errorf("unexpected error getting changes: %v", err)
+	} else if assert.Equal(t, 1, len(changes.Changes)) {
+		assert.True(t, changes.Changes[0].IsNodePolicy("myorg/node1"), "the change should be for the node policy")
+		assert.Equal(t, s.MaxChangeId(), changes.MostRecentChangeID)
+	}
+
+	// Changes in other orgs are not returned.
+	if changes, err := exchange.GetExchangeChanges(ec, 0, 100, nil); err != nil {
+		t.Errorf("unexpected error getting changes: %v", err)
+	} else {
+		for _, c := range changes.Changes {
+			assert.Equal(t, "myorg", c.OrgID)
+		}
+	}
+}
+
+func Test_Messages(t *testing.T) {
+	s := newTestServer()
+	s.AddNode("myorg", "node1", "nodetoken", nil)
+	hf := s.HTTPClientFactory()
+	msgURL := s.ExchangeURL() + "orgs/myorg/nodes/node1/msgs"
+
+	var resp interface{}
+	resp = new(exchange.PostDeviceResponse)
+	if err, tpErr := exchange.InvokeExchange(hf.NewHTTPClient(nil), "POST", msgURL, "myorg/ag1", "agtoken", exchange.CreatePostMessage([]byte("hello"), 60), &resp); err != nil || tpErr != nil {
+		t.Fatalf("unexpected error sending message: %v %v", err, tpErr)
+	}
+
+	resp = new(exchange.GetDeviceMessageResponse)
+	if err, tpErr := exchange.InvokeExchange(hf.NewHTTPClient(nil), "GET", msgURL, "myorg/node1", "nodetoken", nil, &resp); err != nil || tpErr != nil {
+		t.Fatalf("unexpected error getting messages: %v %v", err, tpErr)
+	}
+	msgs := resp.(*exchange.GetDeviceMessageResponse).Messages
+	if assert.Equal(t, 1, len(msgs)) {
+		assert.Equal(t, "myorg/ag1", msgs[0].AgbotId)
+		assert.Equal(t, []byte("hello"), msgs[0].Message)
+	}
+
+	resp = new(exchange.PostDeviceResponse)
+	if err, tpErr := exchange.InvokeExchange(hf.NewHTTPClient(nil), "DELETE", msgURL+"/1", "myorg/node1", "nodetoken", nil, &resp); err != nil || tpErr != nil {
+		t.Errorf("unexpected error deleting message: %v %v", err, tpErr)
+	}
+	assert.Equal(t, 0, len(s.messages["orgs/myorg/nodes/node1"]))
+}
+
+func Test_ExchangeMailboxTransport(t *testing.T) {
+	s := newTestServer()
+	s.AddNode("myorg", "node1", "nodetoken", nil)
+	cfg := &config.HorizonConfig{}
+	s.Configure(cfg)
+
+	agbot, err := exchange.GetAgbotMessageTransport(cfg, "myorg/ag1", "agtoken", cfg.Collaborators.HTTPClientFactory)
+	if err != nil {
+		t.Fatalf("unexpected error getting agbot transport: %v", err)
+	}
+	node, err := exchange.GetNodeMessageTransport(cfg, "myorg/node1", "nodetoken", cfg.Collaborators.HTTPClientFactory)
+	if err != nil {
+		t.Fatalf("unexpected error getting node transport: %v", err)
+	}
+	assert.Equal(t, exchange.EXCHANGE_MAILBOX_TRANSPORT, node.Name())
+
+	if err := agbot.SendMessage(exchange.TRANSPORT_PARTY_NODE, "myorg/node1", []byte("hello"), 60); err != nil {
+		t.Fatalf("unexpected error sending message: %v", err)
+	}
+	msgs, err := node.GetMessages(10)
+	if err != nil {
+		t.Fatalf("unexpected error getting messages: %v", err)
+	} else if !assert.Equal(t, 1, len(msgs)) {
+		return
+	}
+	assert.Equal(t, "myorg/ag1", msgs[0].SenderId)
+	assert.Equal(t, []byte("hello"), msgs[0].Message)
+
+	if has, err := node.HasMessage(msgs[0].MsgId); err != nil || !has {
+		t.Errorf("message %v should be in the mailbox, error %v", msgs[0].MsgId, err)
+	}
+	if err := node.DeleteMessage(msgs[0].MsgId); err != nil {
+		t.Errorf("unexpected error deleting message: %v", err)
+	}
+	if has, err := node.HasMessage(msgs[0].MsgId); err != nil || has {
+		t.Errorf("message %v should have been deleted, error %v", msgs[0].MsgId, err)
+	}
+
+	cfg.Edge.MessageTransport.Name = "carrier-pigeon"
+	if _, err := exchange.GetNodeMessageTransport(cfg, "myorg/node1", "nodetoken", cfg.Collaborators.HTTPClientFactory); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("an unknown transport should not be returned, error %v", err)
+	}
+}
+
+func Test_Services(t *testing.T) {
+	s := newTestServer()
+	s.AddNode("myorg", "node1", "nodetoken", nil)
+	ec := exchange.NewCustomExchangeContext("myorg/node1", "nodetoken", s.ExchangeURL(), s.CSSURL(), s.HTTPClientFactory())
+
+	for _, version := range []string{"1.0.0", "1.2.0"} {
+		svc := exchange.ServiceDefinition{URL: "svc1", Version: version, Arch: "amd64"}
+		if err := s.Put("orgs/myorg/services/svc1_"+version+"_amd64", svc); err != nil {
+			t.Fatalf("unexpected error saving service: %v", err)
+		}
+	}
+
+	if svc, id, err := exchange.GetService(ec, "svc1", "myorg", "[1.0.0,INFINITY)", "amd64"); err != nil {
+		t.Errorf("unexpected error getting service: %v", err)
+	} else if assert.NotNil(t, svc) {
+		assert.Equal(t, "1.2.0", svc.Version, "the highest version in the range should be returned")
+		assert.Equal(t, "myorg/svc1_1.2.0_amd64", id)
+	}
+
+	if _, _, err := exchange.GetService(ec, "svc2", "myorg", "1.0.0", "amd64"); err == nil {
+		t.Errorf("expected an error getting an unknown service")
+	}
+}
+
+func Test_CSSObjects(t *testing.T) {
+	s := newTestServer()
+	s.AddNode("myorg", "node1", "nodetoken", nil)
+	ec := exchange.NewCustomExchangeContext("myorg/ag1", "agtoken", s.ExchangeURL(), s.CSSURL(), s.HTTPClientFactory())
+
+	meta := common.MetaData{
+		DestOrgID:  "myorg",
+		ObjectType: "model",
+		ObjectID:   "obj1",
+		DestinationPolicy: &common.Policy{
+			Services: []common.ServiceID{{OrgID: "myorg", ServiceName: "svc1", Version: "1.0.0", Arch: "amd64"}},
+		},
+	}
+	if err := s.AddObject(meta); err != nil {
+		t.Fatalf("unexpected error adding object: %v", err)
+	}
+
+	if obj, err := exchange.GetObject(ec, "myorg", "obj1", "model"); err != nil {
+		t.Errorf("unexpected error getting object: %v", err)
+	} else if assert.NotNil(t, obj) {
+		assert.Equal(t, "obj1", obj.ObjectID)
+	}
+
+	if pols, err := exchange.GetObjectsByService(ec, "myorg", "myorg/svc1"); err != nil {
+		t.Errorf("unexpected error getting object policies: %v", err)
+	} else {
+		assert.Equal(t, 1, len(*pols))
+	}
+
+	req := &exchange.PostDestsRequest{Action: "add", Destinations: []string{"openhorizon.edgenode:node1"}}
+	if err := exchange.AddOrRemoveDestinations(ec, "myorg", "model", "obj1", req); err != nil {
+		t.Errorf("unexpected error adding destinations: %v", err)
+	}
+	if dests, err := exchange.GetObjectDestinations(ec, "myorg", "obj1", "model"); err != nil {
+		t.Errorf("unexpected error getting destinations: %v", err)
+	} else if assert.NotNil(t, dests) && assert.Equal(t, 1, len(*dests)) {
+		assert.Equal(t, "node1", (*dests)[0].DestID)
+	}
+
+	// Once received, a policy is only returned again when it changes.
+	pols, err := exchange.GetUpdatedObjects(ec, "myorg", 0)
+	if err != nil || len(*pols) != 1 {
+		t.Fatalf("expected 1 object policy, got %v, error: %v", pols, err)
+	}
+	if err := exchange.SetPolicyReceived(ec, &(*pols)[0]); err != nil {
+		t.Errorf("unexpected error setting policy received: %v", err)
+	}
+	if pols2, err := exchange.GetUpdatedObjects(ec, "myorg", (*pols)[0].DestinationPolicy.Timestamp); err != nil {
+		t.Errorf("unexpected error getting object policies: %v", err)
+	} else {
+		assert.Equal(t, 0, len(*pols2))
+	}
+}
+
+func Test_Listen_and_auth(t *testing.T) {
+	s := newTestServer()
+
+	// The URLs can be read while the server starts listening, run with -race to check.
+	done := make(chan bool)
+	go func() {
+		s.ExchangeURL()
+		s.CSSURL()
+		s.AgbotURL()
+		close(done)
+	}()
+	url, err := s.Listen("127.0.0.1:0")
+	<-done
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer s.Close()
+	assert.Equal(t, url+EXCHANGE_PATH, s.ExchangeURL())
+
+	req, _ := http.NewRequest("GET", s.ExchangeURL()+"orgs/myorg/nodes", nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "requests without credentials should be rejected")
+	}
+
+	s.AddSecret("myorg", "", "secret1")
+	req, _ = http.NewRequest("LIST", s.AgbotURL()+"/org/myorg/secrets/secret1", nil)
+	req.SetBasicAuth("myorg/admin", "adminpw")
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, `{"exists":true}`, string(body))
+	}
+}
+
+func Test_Offline(t *testing.T) {
+	s := newTestServer()
+	s.AddNode("myorg", "node1", "nodetoken", nil)
+	hf := s.HTTPClientFactory()
+	heartbeatURL := s.ExchangeURL() + "orgs/myorg/nodes/node1/heartbeat"
+
+	s.SetOffline(true)
+	if err := exchange.Heartbeat(hf, heartbeatURL, "myorg/node1", "nodetoken"); err == nil {
+		t.Errorf("the heartbeat should fail while the exchange is offline")
+	}
+
+	// The state of the exchange is kept while it is offline.
+	s.SetOffline(false)
+	if err := exchange.Heartbeat(hf, heartbeatURL, "myorg/node1", "nodetoken"); err != nil {
+		t.Errorf("unexpected error heartbeating: %v", err)
+	}
+
+	// Clients on the listening address get a 503.
+	if _, err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer s.Close()
+	s.SetOffline(true)
+	req, _ := http.NewRequest("GET", s.ExchangeURL()+"orgs/myorg/nodes/node1", nil)
+	req.SetBasicAuth("myorg/node1", "nodetoken")
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
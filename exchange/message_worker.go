@@ -10,14 +10,13 @@ import (
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
-	"strconv"
-	"time"
 )
 
 type ExchangeMessageWorker struct {
 	worker.BaseWorker // embedded field
 	db                *bolt.DB
 	config            *config.HorizonConfig
+	dispatched        *DispatchedMessages
 }
 
 func NewExchangeMessageWorker(name string, cfg *config.HorizonConfig, db *bolt.DB) *ExchangeMessageWorker {
@@ -31,8 +30,13 @@ func NewExchangeMessageWorker(name string, cfg *config.HorizonConfig, db *bolt.D
 		BaseWorker: worker.NewBaseWorker(name, cfg, ec),
 		db:         db,
 		config:     cfg,
+		dispatched: NewDispatchedMessages(),
 	}
 
+	// Transports other than the exchange mailbox push messages to the node, so the worker is told to handle messages
+	// when one arrives.
+	SetMessageNotify(TRANSPORT_PARTY_NODE, func() { worker.Commands <- NewMessageCommand() })
+
 	// If there are temporary errors trying to retrieve messages, the command handler will requeue the command to handle messages.
 	// Therefore the NoWorkHandler needs to wake up periodically so the worker framework can perform the requeue. Thus, even though
 	// this worker doesnt do anything in the NoWorkHandler, there is still a short interval set.
@@ -60,6 +64,11 @@ func (w *ExchangeMessageWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
 		w.EC = worker.NewExchangeContext(fmt.Sprintf("%v/%v", msg.Org(), msg.DeviceId()), msg.Token(), w.Config.Edge.ExchangeURL, w.Config.GetCSSURL(), newLimitedRetryHTTPFactory(w.Config.Collaborators.HTTPClientFactory))
 
+		// A transport other than the exchange mailbox has to be started to receive messages for the node.
+		if w.Config.Edge.MessageTransport.GetName() != EXCHANGE_MAILBOX_TRANSPORT {
+			w.Commands <- NewMessageCommand()
+		}
+
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
@@ -91,6 +100,9 @@ func (w *ExchangeMessageWorker) NewEvent(incoming events.Message) {
 }
 
 func (w *ExchangeMessageWorker) Initialize() bool {
+	if w.EC != nil && w.Config.Edge.MessageTransport.GetName() != EXCHANGE_MAILBOX_TRANSPORT {
+		w.Commands <- NewMessageCommand()
+	}
 	return true
 }
 
@@ -163,67 +175,47 @@ func (w *ExchangeMessageWorker) handleMessages() bool {
 
 }
 
+// Returns the transport that messages are received on.
+func (w *ExchangeMessageWorker) transport() (MessageTransport, error) {
+	return GetNodeMessageTransport(w.config, w.GetExchangeId(), w.GetExchangeToken(), w.GetHTTPFactory())
+}
+
 func (w *ExchangeMessageWorker) getMessages() ([]DeviceMessage, error) {
-	var resp interface{}
-	resp = new(GetDeviceMessageResponse)
-
-	retryCount := w.GetHTTPFactory().RetryCount
-	retryInterval := w.GetHTTPFactory().GetRetryInterval()
-
-	targetURL := w.GetExchangeURL() + "orgs/" + GetOrg(w.GetExchangeId()) + "/nodes/" + GetId(w.GetExchangeId()) + "/msgs"
-	for {
-		if err, tpErr := InvokeExchange(w.GetHTTPFactory().NewHTTPClient(nil), "GET", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
-			glog.Errorf(logString(err.Error()))
-			return nil, err
-		} else if tpErr != nil {
-			glog.Warningf(logString(tpErr.Error()))
-			if w.GetHTTPFactory().RetryCount == 0 {
-				time.Sleep(time.Duration(retryInterval) * time.Second)
-				continue
-			} else if retryCount == 0 {
-				return nil, fmt.Errorf("Exceeded %v retries for error: %v", w.GetHTTPFactory().RetryCount, tpErr)
-			} else {
-				retryCount--
-				time.Sleep(time.Duration(retryInterval) * time.Second)
-				continue
-			}
-		} else {
-			glog.V(3).Infof(logString(fmt.Sprintf("retrieved %v messages", len(resp.(*GetDeviceMessageResponse).Messages))))
-			msgs := resp.(*GetDeviceMessageResponse).Messages
-			return msgs, nil
-		}
+	t, err := w.transport()
+	if err != nil {
+		return nil, err
 	}
+
+	tMsgs, err := t.GetMessages(0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The worker is told about each message that arrives on transports other than the exchange mailbox, so the
+	// messages that have already been sent to the other workers are skipped until they are deleted.
+	if t.Name() != EXCHANGE_MAILBOX_TRANSPORT {
+		tMsgs = w.dispatched.Undispatched(t, tMsgs, 0)
+	}
+
+	msgs := make([]DeviceMessage, 0, len(tMsgs))
+	for _, m := range tMsgs {
+		msgs = append(msgs, m.DeviceMessage())
+	}
+	glog.V(3).Infof(logString(fmt.Sprintf("retrieved %v messages", len(msgs))))
+	return msgs, nil
 }
 
 func (w *ExchangeMessageWorker) deleteMessage(msg *DeviceMessage) error {
-	var resp interface{}
-	resp = new(PostDeviceResponse)
-
-	retryCount := w.GetHTTPFactory().RetryCount
-	retryInterval := w.GetHTTPFactory().GetRetryInterval()
-
-	targetURL := w.GetExchangeURL() + "orgs/" + GetOrg(w.GetExchangeId()) + "/nodes/" + GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msg.MsgId)
-	for {
-		if err, tpErr := InvokeExchange(w.GetHTTPFactory().NewHTTPClient(nil), "DELETE", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
-			glog.Errorf(logString(err.Error()))
-			return err
-		} else if tpErr != nil {
-			glog.Warningf(logString(tpErr.Error()))
-			if w.GetHTTPFactory().RetryCount == 0 {
-				time.Sleep(time.Duration(retryInterval) * time.Second)
-				continue
-			} else if retryCount == 0 {
-				return fmt.Errorf("Exceeded %v retries for error: %v", w.GetHTTPFactory().RetryCount, tpErr)
-			} else {
-				retryCount--
-				time.Sleep(time.Duration(retryInterval) * time.Second)
-				continue
-			}
-		} else {
-			glog.V(3).Infof(logString(fmt.Sprintf("deleted message %v because it was not usable.", msg.MsgId)))
-			return nil
-		}
+	t, err := w.transport()
+	if err == nil {
+		err = t.DeleteMessage(msg.MsgId)
+	}
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to delete message %v, error %v", msg.MsgId, err)))
+		return err
 	}
+	glog.V(3).Infof(logString(fmt.Sprintf("deleted message %v because it was not usable.", msg.MsgId)))
+	return nil
 }
 
 // Indicates that there is a message for this node.
@@ -0,0 +1,73 @@
+package exchange
+
+import (
+	"github.com/open-horizon/anax/metrics"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Exchange call metrics, shared by the agent and the agbot. Each of them registers these metrics with the registry
+// behind its /metrics API.
+var ExchangeRequestDuration = metrics.NewHistogramVec("anax_exchange_request_duration_seconds",
+	"The latency of calls to the exchange.", metrics.DefaultLatencyBuckets, "method", "resource")
+
+var ExchangeRequestErrors = metrics.NewCounterVec("anax_exchange_request_errors_total",
+	"The number of calls to the exchange that failed. The type is transport when the exchange could not be reached.",
+	"method", "resource", "type")
+
+const (
+	EXCHANGE_ERROR_TYPE_TRANSPORT = "transport"
+	EXCHANGE_ERROR_TYPE_REQUEST   = "request"
+)
+
+func recordExchangeCall(method string, urlPath string, elapsed time.Duration, err error, tpErr error) {
+	resource := ExchangeResource(urlPath)
+	ExchangeRequestDuration.Observe(elapsed.Seconds(), method, resource)
+	if tpErr != nil {
+		ExchangeRequestErrors.Inc(method, resource, EXCHANGE_ERROR_TYPE_TRANSPORT)
+	} else if err != nil {
+		ExchangeRequestErrors.Inc(method, resource, EXCHANGE_ERROR_TYPE_REQUEST)
+	}
+}
+
+// Returns the kind of exchange resource addressed by a URL, without any ids so that the number of label values
+// stays small. For example, .../orgs/myorg/nodes/node1/agreements returns "nodes/agreements".
+func ExchangeResource(urlPath string) string {
+	p := urlPath
+	if u, err := url.Parse(urlPath); err == nil {
+		p = u.Path
+	}
+
+	segments := make([]string, 0)
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+
+	// Find the orgs segment, the ids alternate with the resource names after it.
+	for ix, s := range segments {
+		if s == "orgs" {
+			names := make([]string, 0)
+			for j := ix + 2; j < len(segments); j += 2 {
+				// Business policies are the only resource with a 2 part name.
+				if segments[j] == "business" && j+1 < len(segments) {
+					names = append(names, segments[j]+"/"+segments[j+1])
+					j += 1
+				} else {
+					names = append(names, segments[j])
+				}
+			}
+			if len(names) == 0 {
+				return "orgs"
+			}
+			return strings.Join(names, "/")
+		}
+	}
+
+	if len(segments) == 0 {
+		return ""
+	}
+	return segments[len(segments)-1]
+}
@@ -0,0 +1,42 @@
+//go:build unit
+// +build unit
+
+package exchange
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_ExchangeResource(t *testing.T) {
+	tests := map[string]string{
+		"https://exchange/v1/orgs/myorg/nodes/node1/agreements/ag1":    "nodes/agreements",
+		"https://exchange/v1/orgs/myorg/business/policies/pol1":        "business/policies",
+		"https://exchange/v1/orgs/myorg/business/policies/pol1/search": "business/policies/search",
+		"https://exchange/v1/orgs/myorg/services?owner=me":             "services",
+		"https://exchange/v1/orgs/myorg":                               "orgs",
+		"https://exchange/v1/admin/version":                            "version",
+		"":                                                             "",
+	}
+	for url, expected := range tests {
+		if r := ExchangeResource(url); r != expected {
+			t.Errorf("expected %v for %v, got %v", expected, url, r)
+		}
+	}
+}
+
+func Test_recordExchangeCall(t *testing.T) {
+	url := "https://exchange/v1/orgs/myorg/patterns/p1"
+	before := ExchangeRequestErrors.Get("GET", "patterns", EXCHANGE_ERROR_TYPE_TRANSPORT)
+	count := ExchangeRequestDuration.Count("GET", "patterns")
+
+	recordExchangeCall("GET", url, time.Millisecond, nil, nil)
+	recordExchangeCall("GET", url, time.Millisecond, nil, errors.New("no route"))
+
+	if ExchangeRequestDuration.Count("GET", "patterns") != count+2 {
+		t.Errorf("expected 2 more observations")
+	} else if ExchangeRequestErrors.Get("GET", "patterns", EXCHANGE_ERROR_TYPE_TRANSPORT) != before+1 {
+		t.Errorf("expected 1 more transport error")
+	}
+}
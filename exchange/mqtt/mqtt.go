@@ -0,0 +1,427 @@
+package mqtt
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The purpose of this package is to deliver agreement protocol messages through an MQTT broker instead of the exchange
+// mailboxes. Each node and agbot subscribes to its own mailbox topic, so a message is pushed to the receiver as soon as
+// it is published. The messages are the same encrypted ExchangeMessages that are posted to the exchange mailboxes.
+//
+// The mailbox of a node or agbot is the topic <prefix>/<org>/<nodes|agbots>/<id>/msgs. A message is published to a
+// sub-topic of the receiver's mailbox that identifies the sender, <mailbox>/<nodes|agbots>/<sender org>/<sender id>.
+// The receiver takes the sender's id from the topic, so a broker that only allows each client to publish to topics
+// ending with its own exchange id prevents one party from sending messages as another. The sender also writes its
+// public key in the message, so a message is only handed out after the key has been checked against the key that the
+// sender registered in the exchange.
+//
+// Messages are published with QoS 1 on a persistent session, so the broker keeps the messages for a node or agbot
+// while it is disconnected. The broker is told that a message was received once it has been written to a file in the
+// state dir of the node or agbot, where it is kept until the worker that handles it deletes it. A message that was
+// received but not handled is still there after a restart.
+
+func init() {
+	exchange.RegisterMessageTransport(TRANSPORT_NAME, NewMQTTTransport)
+}
+
+const TRANSPORT_NAME = "mqtt"
+
+// Messages are delivered at least once.
+const MESSAGE_QOS = 1
+
+// How long to wait for the broker to respond to a connect, subscribe or publish.
+const BROKER_TIMEOUT = 30 * time.Second
+
+// The number of seconds a message is kept for the receiver when the sender does not say.
+const DEFAULT_MESSAGE_TTL = 180
+
+// The payload of an MQTT message. The exchange adds the sender's public key and the times to the messages in its
+// mailboxes, on the broker the sender adds them.
+type Envelope struct {
+	SenderPubKey []byte `json:"senderPubKey"`
+	Message      []byte `json:"message"`
+	TimeSent     string `json:"timeSent"`
+	TimeExpires  string `json:"timeExpires"`
+}
+
+// A received message and the type of party that sent it. Verified is set once the sender's public key in the message
+// has been found to be the key the sender registered in the exchange.
+type pendingMessage struct {
+	exchange.TransportMessage
+	SenderType string
+	Verified   bool
+}
+
+// The messages that have been received and not yet deleted, as they are saved in the state dir. The last id is saved
+// so that the ids of the messages are not used again.
+type savedMessages struct {
+	LastId   int              `json:"lastId"`
+	Messages []pendingMessage `json:"messages"`
+}
+
+type MQTTTransport struct {
+	ctx       exchange.TransportContext
+	prefix    string
+	client    paho.Client
+	lock      sync.Mutex
+	lastId    int
+	pending   []pendingMessage
+	notify    func()
+	stateFile string
+}
+
+// The file the messages of a node or agbot are saved in.
+func StateFile(dir string, partyType string) string {
+	return path.Join(dir, "mqtt-"+partyType+"-msgs.json")
+}
+
+// Returns the mailbox topic of a node or agbot.
+func MailboxTopic(prefix string, partyType string, id string) string {
+	return prefix + "/" + exchange.GetOrg(id) + "/" + partyType + "/" + exchange.GetId(id) + "/msgs"
+}
+
+// The org and id of an exchange id are topic levels, so they cannot contain the topic level separator or wildcards.
+func checkTopicId(id string) error {
+	if exchange.GetOrg(id) == "" || exchange.GetId(id) == "" || strings.ContainsAny(id, "+#") || strings.Count(id, "/") != 1 {
+		return errors.New(fmt.Sprintf("%v is not an exchange id that can be used in an MQTT topic", id))
+	}
+	return nil
+}
+
+// Create the transport and connect it to the broker. The transport subscribes to the mailbox of the node or agbot in
+// the context each time it connects.
+func NewMQTTTransport(ctx *exchange.TransportContext, cfg *config.MessageTransportConfig) (exchange.MessageTransport, error) {
+	if cfg.BrokerURL == "" {
+		return nil, errors.New("the MQTT broker URL is not configured")
+	} else if err := checkTopicId(ctx.Id); err != nil {
+		return nil, err
+	} else if cfg.Username == "" && !isEncrypted(cfg.BrokerURL) {
+		return nil, errors.New(fmt.Sprintf("the exchange token is not sent to MQTT broker %v over an unencrypted connection, use a TLS connection or configure a broker username", cfg.BrokerURL))
+	}
+
+	t := &MQTTTransport{
+		ctx:     *ctx,
+		prefix:  cfg.GetTopicPrefix(),
+		pending: make([]pendingMessage, 0, 10),
+	}
+
+	if ctx.StateDir == "" {
+		glog.Warningf(mqttLogString(fmt.Sprintf("there is no state dir for %v, received messages that have not been handled are lost when the transport is closed", ctx.Id)))
+	} else {
+		t.stateFile = StateFile(ctx.StateDir, ctx.PartyType)
+		if err := t.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := paho.NewClientOptions()
+	opts.AddBroker(cfg.BrokerURL)
+	opts.SetClientID(ctx.PartyType + "/" + ctx.Id)
+	opts.SetCleanSession(false)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectTimeout(BROKER_TIMEOUT)
+
+	if cfg.Username != "" {
+		opts.SetUsername(cfg.Username)
+		opts.SetPassword(cfg.Password)
+	} else {
+		opts.SetUsername(ctx.Id)
+		opts.SetPassword(ctx.Token)
+	}
+
+	if cfg.CACertPath != "" {
+		if certs, err := ioutil.ReadFile(cfg.CACertPath); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to read the MQTT broker CA certs from %v, error %v", cfg.CACertPath, err))
+		} else {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(certs) {
+				return nil, errors.New(fmt.Sprintf("no PEM encoded certs found in %v", cfg.CACertPath))
+			}
+			opts.SetTLSConfig(&tls.Config{RootCAs: pool})
+		}
+	}
+
+	mailbox := MailboxTopic(t.prefix, ctx.PartyType, ctx.Id)
+	opts.SetOnConnectHandler(func(c paho.Client) {
+		glog.V(3).Infof(mqttLogString(fmt.Sprintf("connected to %v, subscribing to %v", cfg.BrokerURL, mailbox)))
+		if token := c.Subscribe(mailbox+"/#", MESSAGE_QOS, t.receive); !token.WaitTimeout(BROKER_TIMEOUT) {
+			glog.Errorf(mqttLogString(fmt.Sprintf("timed out subscribing to %v", mailbox)))
+		} else if token.Error() != nil {
+			glog.Errorf(mqttLogString(fmt.Sprintf("unable to subscribe to %v, error %v", mailbox, token.Error())))
+		}
+	})
+	opts.SetConnectionLostHandler(func(c paho.Client, err error) {
+		glog.Warningf(mqttLogString(fmt.Sprintf("lost the connection to %v, error %v", cfg.BrokerURL, err)))
+	})
+
+	t.client = paho.NewClient(opts)
+	if token := t.client.Connect(); !token.WaitTimeout(BROKER_TIMEOUT) {
+		return nil, errors.New(fmt.Sprintf("timed out connecting to MQTT broker %v", cfg.BrokerURL))
+	} else if token.Error() != nil {
+		return nil, errors.New(fmt.Sprintf("unable to connect to MQTT broker %v, error %v", cfg.BrokerURL, token.Error()))
+	}
+	return t, nil
+}
+
+// The schemes that paho connects to a broker with over TLS.
+func isEncrypted(brokerURL string) bool {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "ssl", "tls", "tcps", "wss":
+		return true
+	}
+	return false
+}
+
+func (t *MQTTTransport) Name() string {
+	return TRANSPORT_NAME
+}
+
+func (t *MQTTTransport) SendMessage(receiverType string, receiverId string, msg []byte, ttl int) error {
+	if err := checkTopicId(receiverId); err != nil {
+		return err
+	} else if ttl <= 0 {
+		ttl = DEFAULT_MESSAGE_TTL
+	}
+
+	now := time.Now()
+	envelope := Envelope{
+		SenderPubKey: t.ctx.PubKey,
+		Message:      msg,
+		TimeSent:     now.UTC().Format(time.RFC3339),
+		TimeExpires:  now.Add(time.Duration(ttl) * time.Second).UTC().Format(time.RFC3339),
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal message envelope, error %v", err))
+	}
+
+	topic := MailboxTopic(t.prefix, receiverType, receiverId) + "/" + t.ctx.PartyType + "/" + t.ctx.Id
+	if token := t.client.Publish(topic, MESSAGE_QOS, false, payload); !token.WaitTimeout(BROKER_TIMEOUT) {
+		return errors.New(fmt.Sprintf("timed out publishing message to %v", topic))
+	} else if token.Error() != nil {
+		return errors.New(fmt.Sprintf("unable to publish message to %v, error %v", topic, token.Error()))
+	}
+	glog.V(5).Infof(mqttLogString(fmt.Sprintf("published message to %v", topic)))
+	return nil
+}
+
+// Called by the MQTT client for each message published to the mailbox. Messages that are not valid or that have
+// expired are dropped. The client tells the broker that the message was received when this returns, so the message
+// is saved first.
+func (t *MQTTTransport) receive(c paho.Client, m paho.Message) {
+	senderType, senderId, err := t.senderFromTopic(m.Topic())
+	if err != nil {
+		glog.Errorf(mqttLogString(fmt.Sprintf("dropping message, error %v", err)))
+		return
+	}
+
+	envelope := new(Envelope)
+	if err := json.Unmarshal(m.Payload(), envelope); err != nil {
+		glog.Errorf(mqttLogString(fmt.Sprintf("dropping message from %v, unable to unmarshal it, error %v", senderId, err)))
+		return
+	} else if len(envelope.Message) == 0 {
+		glog.Errorf(mqttLogString(fmt.Sprintf("dropping message from %v, it is empty", senderId)))
+		return
+	} else if expires, err := time.Parse(time.RFC3339, envelope.TimeExpires); err == nil && expires.Before(time.Now()) {
+		glog.Warningf(mqttLogString(fmt.Sprintf("dropping message from %v, it expired at %v", senderId, envelope.TimeExpires)))
+		return
+	}
+
+	t.lock.Lock()
+	t.lastId++
+	msg := exchange.TransportMessage{MsgId: t.lastId, SenderId: senderId, SenderPubKey: envelope.SenderPubKey, Message: envelope.Message, TimeSent: envelope.TimeSent}
+	t.pending = append(t.pending, pendingMessage{TransportMessage: msg, SenderType: senderType})
+	t.save()
+	notify := t.notify
+	t.lock.Unlock()
+
+	glog.V(3).Infof(mqttLogString(fmt.Sprintf("received message %v", msg)))
+	if notify != nil {
+		notify()
+	}
+}
+
+// The sender's party type and exchange id are the last 3 levels of the topic a message is published to.
+func (t *MQTTTransport) senderFromTopic(topic string) (string, string, error) {
+	mailbox := MailboxTopic(t.prefix, t.ctx.PartyType, t.ctx.Id) + "/"
+	sender := strings.Split(strings.TrimPrefix(topic, mailbox), "/")
+	if !strings.HasPrefix(topic, mailbox) || len(sender) != 3 {
+		return "", "", errors.New(fmt.Sprintf("topic %v does not identify the sender", topic))
+	} else if sender[0] != exchange.TRANSPORT_PARTY_NODE && sender[0] != exchange.TRANSPORT_PARTY_AGBOT {
+		return "", "", errors.New(fmt.Sprintf("topic %v has unknown sender type %v", topic, sender[0]))
+	}
+	return sender[0], sender[1] + "/" + sender[2], nil
+}
+
+// Only the messages whose sender's key has been verified are returned.
+func (t *MQTTTransport) GetMessages(max int) ([]exchange.TransportMessage, error) {
+	t.verifyPending()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	msgs := make([]exchange.TransportMessage, 0, len(t.pending))
+	for _, p := range t.pending {
+		if max > 0 && len(msgs) == max {
+			break
+		} else if p.Verified {
+			msgs = append(msgs, p.TransportMessage)
+		}
+	}
+	return msgs, nil
+}
+
+// Check the public key in each message that has not been verified yet against the key that the sender registered in
+// the exchange. A message with another key is dropped. A message whose sender's key cannot be read from the exchange
+// is kept and checked again the next time the messages are read.
+func (t *MQTTTransport) verifyPending() {
+	t.lock.Lock()
+	unverified := make([]pendingMessage, 0)
+	for _, p := range t.pending {
+		if !p.Verified {
+			unverified = append(unverified, p)
+		}
+	}
+	t.lock.Unlock()
+
+	for _, p := range unverified {
+		verified, err := t.verifySender(p)
+		if err != nil {
+			glog.Warningf(mqttLogString(fmt.Sprintf("unable to verify the sender of message %v, error %v", p.TransportMessage, err)))
+			continue
+		} else if !verified {
+			glog.Errorf(mqttLogString(fmt.Sprintf("dropping message %v, it does not have the public key that %v registered in the exchange", p.TransportMessage, p.SenderId)))
+		}
+
+		t.lock.Lock()
+		if ix := t.find(p.MsgId); ix != -1 {
+			if verified {
+				t.pending[ix].Verified = true
+			} else {
+				t.pending = append(t.pending[:ix], t.pending[ix+1:]...)
+			}
+			t.save()
+		}
+		t.lock.Unlock()
+	}
+}
+
+// The cached key is read again from the exchange when it does not match, in case the sender registered a new key.
+func (t *MQTTTransport) verifySender(p pendingMessage) (bool, error) {
+	for _, refresh := range []bool{false, true} {
+		if key, err := exchange.GetRegisteredPublicKey(&t.ctx, p.SenderType, p.SenderId, refresh); err != nil {
+			return false, err
+		} else if len(key) != 0 && bytes.Equal(key, p.SenderPubKey) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Return the index of a pending message, or -1 if it is not pending. The caller holds the lock.
+func (t *MQTTTransport) find(msgId int) int {
+	ix := sort.Search(len(t.pending), func(i int) bool { return t.pending[i].MsgId >= msgId })
+	if ix < len(t.pending) && t.pending[ix].MsgId == msgId {
+		return ix
+	}
+	return -1
+}
+
+func (t *MQTTTransport) HasMessage(msgId int) (bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.find(msgId) != -1, nil
+}
+
+// Deleting a message that is not in the mailbox is not an error, it was already handled.
+func (t *MQTTTransport) DeleteMessage(msgId int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if ix := t.find(msgId); ix != -1 {
+		t.pending = append(t.pending[:ix], t.pending[ix+1:]...)
+		t.save()
+	}
+	return nil
+}
+
+// Read the messages that were saved by an earlier transport for the same node or agbot.
+func (t *MQTTTransport) load() error {
+	if data, err := ioutil.ReadFile(t.stateFile); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New(fmt.Sprintf("unable to read saved messages from %v, error %v", t.stateFile, err))
+	} else {
+		saved := new(savedMessages)
+		if err := json.Unmarshal(data, saved); err != nil {
+			return errors.New(fmt.Sprintf("unable to unmarshal saved messages from %v, error %v", t.stateFile, err))
+		}
+		t.lastId = saved.LastId
+		t.pending = append(t.pending, saved.Messages...)
+		glog.V(3).Infof(mqttLogString(fmt.Sprintf("loaded %v saved messages from %v", len(t.pending), t.stateFile)))
+		return nil
+	}
+}
+
+// Save the messages that have not been deleted. The file is replaced in one step so that it is never partly written.
+// The caller holds the lock.
+func (t *MQTTTransport) save() {
+	if t.stateFile == "" {
+		return
+	}
+
+	data, err := json.Marshal(savedMessages{LastId: t.lastId, Messages: t.pending})
+	if err != nil {
+		glog.Errorf(mqttLogString(fmt.Sprintf("unable to marshal messages, error %v", err)))
+		return
+	}
+
+	tmpFile := t.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		glog.Errorf(mqttLogString(fmt.Sprintf("unable to save messages to %v, error %v", tmpFile, err)))
+	} else if err := os.Rename(tmpFile, t.stateFile); err != nil {
+		glog.Errorf(mqttLogString(fmt.Sprintf("unable to save messages to %v, error %v", t.stateFile, err)))
+	}
+}
+
+// The function is called once for each message that arrives. When messages arrived before it was set, it is also
+// called once on another goroutine, so that setting it never waits for the receiver.
+func (t *MQTTTransport) OnMessage(notify func()) {
+	t.lock.Lock()
+	t.notify = notify
+	waiting := len(t.pending) != 0
+	t.lock.Unlock()
+
+	if waiting && notify != nil {
+		go notify()
+	}
+}
+
+func (t *MQTTTransport) Close() {
+	t.client.Disconnect(250)
+}
+
+var mqttLogString = func(v interface{}) string {
+	return fmt.Sprintf("MQTT MessageTransport %v", v)
+}
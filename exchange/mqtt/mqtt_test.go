@@ -0,0 +1,364 @@
+// +build unit
+
+package mqtt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchange/fakeexchange"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A minimal MQTT broker that is just enough for the transport to connect, subscribe and publish with QoS 1. It does
+// not keep messages for disconnected clients.
+type testBroker struct {
+	listener net.Listener
+	lock     sync.Mutex
+	subs     map[*brokerConn][]string
+	msgId    uint16
+}
+
+type brokerConn struct {
+	conn net.Conn
+	lock sync.Mutex
+}
+
+func (c *brokerConn) write(p packets.ControlPacket) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	p.Write(c.conn)
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to start test broker, error %v", err)
+	}
+	b := &testBroker{listener: l, subs: map[*brokerConn][]string{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&brokerConn{conn: conn})
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testBroker) close() {
+	b.listener.Close()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for c := range b.subs {
+		c.conn.Close()
+	}
+}
+
+func (b *testBroker) serve(c *brokerConn) {
+	b.lock.Lock()
+	b.subs[c] = []string{}
+	b.lock.Unlock()
+	defer func() {
+		b.lock.Lock()
+		delete(b.subs, c)
+		b.lock.Unlock()
+		c.conn.Close()
+	}()
+
+	for {
+		cp, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return
+		}
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			c.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			b.lock.Lock()
+			b.subs[c] = append(b.subs[c], p.Topics...)
+			b.lock.Unlock()
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			c.write(ack)
+		case *packets.PublishPacket:
+			if p.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				c.write(ack)
+			}
+			b.publish(p.TopicName, p.Payload)
+		case *packets.PingreqPacket:
+			c.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// Wait for the transports to subscribe to their mailboxes, they subscribe after they connect.
+func (b *testBroker) waitForSubscriptions(t *testing.T, n int) {
+	for i := 0; i < 50; i++ {
+		b.lock.Lock()
+		count := 0
+		for _, filters := range b.subs {
+			count += len(filters)
+		}
+		b.lock.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %v subscriptions", n)
+}
+
+func (b *testBroker) publish(topic string, payload []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for c, filters := range b.subs {
+		for _, filter := range filters {
+			if filter == topic || (strings.HasSuffix(filter, "/#") && strings.HasPrefix(topic, strings.TrimSuffix(filter, "#"))) {
+				b.msgId++
+				p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+				p.Qos = 1
+				p.MessageID = b.msgId
+				p.TopicName = topic
+				p.Payload = payload
+				go c.write(p)
+				break
+			}
+		}
+	}
+}
+
+// Register the node or agbot and its public key in the exchange, and create its transport. The test broker does not use
+// TLS, so the transport connects with a broker username.
+func newTestTransport(t *testing.T, b *testBroker, srv *fakeexchange.Server, partyType string, id string, pubKey *rsa.PublicKey, stateDir string) exchange.MessageTransport {
+	key, err := exchange.MarshalPublicKey(pubKey)
+	if err != nil {
+		t.Fatalf("unable to marshal public key, error %v", err)
+	}
+	if partyType == exchange.TRANSPORT_PARTY_NODE {
+		srv.AddNode(exchange.GetOrg(id), exchange.GetId(id), "token", map[string]interface{}{"publicKey": key})
+	} else {
+		srv.AddAgbot(exchange.GetOrg(id), exchange.GetId(id), "token")
+		if err := srv.Put("orgs/"+exchange.GetOrg(id)+"/agbots/"+exchange.GetId(id), map[string]interface{}{"name": exchange.GetId(id), "publicKey": key}); err != nil {
+			t.Fatalf("unable to register agbot key, error %v", err)
+		}
+	}
+
+	ctx := &exchange.TransportContext{PartyType: partyType, Id: id, Token: "token", ExchangeURL: srv.ExchangeURL(), HTTPFactory: srv.HTTPClientFactory(), PubKey: key, StateDir: stateDir}
+	cfg := &config.MessageTransportConfig{Name: TRANSPORT_NAME, BrokerURL: b.url(), Username: "user", Password: "password"}
+	tr, err := NewMQTTTransport(ctx, cfg)
+	if err != nil {
+		t.Fatalf("unable to create transport for %v, error %v", id, err)
+	}
+	return tr
+}
+
+// Wait for the transport to have n messages.
+func waitForMessages(t *testing.T, tr exchange.MessageTransport, n int) []exchange.TransportMessage {
+	for i := 0; i < 50; i++ {
+		if msgs, err := tr.GetMessages(0); err != nil {
+			t.Fatalf("unable to get messages, error %v", err)
+		} else if len(msgs) >= n {
+			return msgs
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %v messages", n)
+	return nil
+}
+
+func Test_MQTTTransport_SendReceive(t *testing.T) {
+	b := newTestBroker(t)
+	defer b.close()
+
+	agbotPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate keys, error %v", err)
+	}
+	nodePriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate keys, error %v", err)
+	}
+
+	srv := fakeexchange.NewServer()
+	agbot := newTestTransport(t, b, srv, exchange.TRANSPORT_PARTY_AGBOT, "myorg/agbot1", &agbotPriv.PublicKey, "")
+	defer agbot.Close()
+	node := newTestTransport(t, b, srv, exchange.TRANSPORT_PARTY_NODE, "myorg/node1", &nodePriv.PublicKey, "")
+	defer node.Close()
+	b.waitForSubscriptions(t, 2)
+
+	notified := make(chan bool, 10)
+	node.OnMessage(func() { notified <- true })
+
+	// The agbot sends the node a message encrypted the same way as a message posted to the exchange.
+	encrypted, err := exchange.ConstructExchangeMessage([]byte(`{"type":"proposal"}`), &agbotPriv.PublicKey, agbotPriv, &nodePriv.PublicKey)
+	if err != nil {
+		t.Fatalf("unable to construct message, error %v", err)
+	}
+	msgBody, _ := json.Marshal(encrypted)
+	if err := agbot.SendMessage(exchange.TRANSPORT_PARTY_NODE, "myorg/node1", msgBody, 60); err != nil {
+		t.Fatalf("unable to send message, error %v", err)
+	}
+
+	msgs := waitForMessages(t, node, 1)
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Errorf("the node was not notified of the message")
+	}
+
+	msg := msgs[0]
+	if msg.SenderId != "myorg/agbot1" {
+		t.Errorf("the sender should be myorg/agbot1, was %v", msg.SenderId)
+	}
+	if plain, senderKey, err := exchange.DeconstructExchangeMessage(msg.Message, nodePriv); err != nil {
+		t.Errorf("unable to decrypt message, error %v", err)
+	} else if string(plain) != `{"type":"proposal"}` {
+		t.Errorf("unexpected message %v", string(plain))
+	} else if key, _ := exchange.MarshalPublicKey(senderKey); string(key) != string(msg.SenderPubKey) {
+		t.Errorf("the message was not signed with the sender's public key")
+	}
+
+	// The node replies, and then deletes the message it handled.
+	if err := node.SendMessage(exchange.TRANSPORT_PARTY_AGBOT, "myorg/agbot1", []byte(`reply`), 0); err != nil {
+		t.Fatalf("unable to send reply, error %v", err)
+	}
+	if reply := waitForMessages(t, agbot, 1); reply[0].SenderId != "myorg/node1" || string(reply[0].Message) != "reply" {
+		t.Errorf("unexpected reply %v", reply[0])
+	}
+
+	if has, _ := node.HasMessage(msg.MsgId); !has {
+		t.Errorf("message %v should be in the mailbox", msg.MsgId)
+	} else if err := node.DeleteMessage(msg.MsgId); err != nil {
+		t.Errorf("unable to delete message, error %v", err)
+	} else if has, _ := node.HasMessage(msg.MsgId); has {
+		t.Errorf("message %v should have been deleted", msg.MsgId)
+	} else if err := node.DeleteMessage(msg.MsgId); err != nil {
+		t.Errorf("deleting a deleted message should not fail, error %v", err)
+	}
+}
+
+func Test_MQTTTransport_Drop(t *testing.T) {
+	b := newTestBroker(t)
+	defer b.close()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate keys, error %v", err)
+	}
+	agbotPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate keys, error %v", err)
+	}
+	srv := fakeexchange.NewServer()
+	node := newTestTransport(t, b, srv, exchange.TRANSPORT_PARTY_NODE, "myorg/node1", &priv.PublicKey, "")
+	defer node.Close()
+	agbot := newTestTransport(t, b, srv, exchange.TRANSPORT_PARTY_AGBOT, "myorg/agbot1", &agbotPriv.PublicKey, "")
+	defer agbot.Close()
+	b.waitForSubscriptions(t, 2)
+
+	agbotKey, _ := exchange.MarshalPublicKey(&agbotPriv.PublicKey)
+	nodeKey, _ := exchange.MarshalPublicKey(&priv.PublicKey)
+	expires := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+
+	mailbox := MailboxTopic("horizon", exchange.TRANSPORT_PARTY_NODE, "myorg/node1")
+	expired, _ := json.Marshal(Envelope{SenderPubKey: agbotKey, Message: []byte("old"), TimeExpires: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)})
+	spoofed, _ := json.Marshal(Envelope{SenderPubKey: nodeKey, Message: []byte("spoofed"), TimeExpires: expires})
+	valid, _ := json.Marshal(Envelope{SenderPubKey: agbotKey, Message: []byte("new"), TimeExpires: expires})
+
+	// Messages that have expired, are not from a node or agbot, or are empty are dropped. So are messages that do not
+	// have the key the sender registered in the exchange, or that are from a sender that is not registered.
+	b.publish(mailbox+"/agbots/myorg/agbot1", expired)
+	b.publish(mailbox+"/users/myorg/user1", valid)
+	b.publish(mailbox+"/agbots/myorg", valid)
+	b.publish(mailbox+"/agbots/myorg/agbot1", []byte(`{}`))
+	b.publish(mailbox+"/agbots/myorg/agbot1", spoofed)
+	b.publish(mailbox+"/agbots/myorg/agbot2", valid)
+	b.publish(mailbox+"/agbots/myorg/agbot1", valid)
+
+	msgs := waitForMessages(t, node, 1)
+	time.Sleep(200 * time.Millisecond)
+	if msgs, _ = node.GetMessages(0); len(msgs) != 1 || string(msgs[0].Message) != "new" {
+		t.Errorf("only the valid message should be received, got %v", msgs)
+	}
+	if has, _ := node.HasMessage(msgs[0].MsgId - 1); has {
+		t.Errorf("the message from an unregistered sender should have been dropped")
+	}
+
+	if _, err := NewMQTTTransport(&exchange.TransportContext{PartyType: exchange.TRANSPORT_PARTY_NODE, Id: "myorg/node#"}, &config.MessageTransportConfig{BrokerURL: b.url()}); err == nil {
+		t.Errorf("an id with a wildcard should not be accepted")
+	}
+	if _, err := NewMQTTTransport(&exchange.TransportContext{PartyType: exchange.TRANSPORT_PARTY_NODE, Id: "myorg/node1"}, &config.MessageTransportConfig{}); err == nil {
+		t.Errorf("a transport without a broker should not be created")
+	}
+	if _, err := NewMQTTTransport(&exchange.TransportContext{PartyType: exchange.TRANSPORT_PARTY_NODE, Id: "myorg/node1", Token: "token"}, &config.MessageTransportConfig{BrokerURL: b.url()}); err == nil {
+		t.Errorf("the exchange token should not be sent to a broker over tcp")
+	}
+}
+
+// Messages that have been received and not deleted are still there when the transport is created again, for example
+// after a restart.
+func Test_MQTTTransport_Saved(t *testing.T) {
+	b := newTestBroker(t)
+	defer b.close()
+
+	dir, err := ioutil.TempDir("", "mqtt-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, error %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate keys, error %v", err)
+	}
+	srv := fakeexchange.NewServer()
+	agbot := newTestTransport(t, b, srv, exchange.TRANSPORT_PARTY_AGBOT, "myorg/agbot1", &priv.PublicKey, "")
+	defer agbot.Close()
+	node := newTestTransport(t, b, srv, exchange.TRANSPORT_PARTY_NODE, "myorg/node1", &priv.PublicKey, dir)
+	b.waitForSubscriptions(t, 2)
+
+	for _, m := range []string{"first", "second"} {
+		if err := agbot.SendMessage(exchange.TRANSPORT_PARTY_NODE, "myorg/node1", []byte(m), 60); err != nil {
+			t.Fatalf("unable to send message, error %v", err)
+		}
+	}
+	msgs := waitForMessages(t, node, 2)
+	node.DeleteMessage(msgs[0].MsgId)
+	node.Close()
+
+	node = newTestTransport(t, b, srv, exchange.TRANSPORT_PARTY_NODE, "myorg/node1", &priv.PublicKey, dir)
+	defer node.Close()
+	b.waitForSubscriptions(t, 2)
+	if saved, _ := node.GetMessages(0); len(saved) != 1 || saved[0].MsgId != msgs[1].MsgId || string(saved[0].Message) != "second" {
+		t.Errorf("the message that was not deleted should have been saved, got %v", saved)
+	}
+
+	// The ids of the saved messages are not used again.
+	if err := agbot.SendMessage(exchange.TRANSPORT_PARTY_NODE, "myorg/node1", []byte("third"), 60); err != nil {
+		t.Fatalf("unable to send message, error %v", err)
+	}
+	if all := waitForMessages(t, node, 2); all[1].MsgId <= msgs[1].MsgId {
+		t.Errorf("the new message should have a new id, got %v", all)
+	}
+}
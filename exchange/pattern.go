@@ -319,6 +319,9 @@ func ConvertToPolicies(patternId string, p *Pattern) ([]*policy.Policy, error) {
 func ConvertChoice(wl WorkloadChoice, url string, org string, arch string, pol *policy.Policy) {
 	newWL := policy.Workload_Factory(url, org, wl.Version, arch)
 	newWL.Priority = (*policy.Workload_Priority_Factory(wl.Priority.PriorityValue, wl.Priority.Retries, wl.Priority.RetryDurationS, wl.Priority.VerifiedDurationS))
+	if upgrade := policy.Workload_Upgrade_Factory(wl.Upgrade.Lifecycle, wl.Upgrade.Time); !upgrade.IsEmpty() {
+		newWL.Upgrade = upgrade
+	}
 	newWL.DeploymentOverrides = wl.DeploymentOverrides
 	newWL.DeploymentOverridesSignature = wl.DeploymentOverridesSignature
 	pol.Add_Workload(newWL)
@@ -669,6 +669,10 @@ func ConvertPropertyToExchangeFormat(prop *externalpolicy.Property) (*MSProp, er
 		pType = "string"
 		pValue = prop.Value.(string)
 		pCompare = "in"
+		if prop.Type == externalpolicy.DATETIME_TYPE {
+			pType = externalpolicy.DATETIME_TYPE
+			pCompare = "="
+		}
 	case int:
 		pType = "int"
 		pValue = strconv.Itoa(prop.Value.(int))
@@ -685,6 +689,15 @@ func ConvertPropertyToExchangeFormat(prop *externalpolicy.Property) (*MSProp, er
 		pType = "int"
 		pValue = strconv.Itoa(int(prop.Value.(float64)))
 		pCompare = ">="
+	case map[string]interface{}:
+		// A map is sent in its json form.
+		if mapBytes, err := json.Marshal(prop.Value); err != nil {
+			return nil, errors.New(fmt.Sprintf("Unable to marshal map property %v converting to exchange format, error %v", prop.Name, err))
+		} else {
+			pType = externalpolicy.MAP_TYPE
+			pValue = string(mapBytes)
+			pCompare = "="
+		}
 	default:
 		return nil, errors.New(fmt.Sprintf("Encountered unsupported property type: %v converting to exchange format.", reflect.TypeOf(prop.Value).String()))
 	}
@@ -962,7 +975,15 @@ func DeleteSurfaceErrors(ec ExchangeContext, deviceId string) error {
 
 // This function is used to invoke an exchange API
 // For GET, the given resp parameter will be untouched when http returns code 404.
+// The latency and the errors of each call are recorded in the exchange metrics.
 func InvokeExchange(httpClient *http.Client, method string, urlPath string, user string, pw string, params interface{}, resp *interface{}) (error, error) {
+	start := time.Now()
+	err, tpErr := invokeExchange(httpClient, method, urlPath, user, pw, params, resp)
+	recordExchangeCall(method, urlPath, time.Since(start), err, tpErr)
+	return err, tpErr
+}
+
+func invokeExchange(httpClient *http.Client, method string, urlPath string, user string, pw string, params interface{}, resp *interface{}) (error, error) {
 
 	if len(method) == 0 {
 		return errors.New(fmt.Sprintf("Error invoking exchange, method name must be specified")), nil
@@ -0,0 +1,38 @@
+// +build unit
+
+package exchange
+
+import (
+	"encoding/json"
+	"github.com/open-horizon/anax/externalpolicy"
+	"testing"
+)
+
+func Test_ConvertPropertyToExchangeFormat(t *testing.T) {
+	var camera map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"model":"x100","mount":{"height":2.5}}`), &camera); err != nil {
+		t.Fatalf("unable to unmarshal map value, error %v", err)
+	}
+
+	tests := []struct {
+		prop     externalpolicy.Property
+		expected MSProp
+	}{
+		{externalpolicy.Property{Name: "zone", Value: "lab"}, MSProp{Name: "zone", Value: "lab", PropType: "string", Op: "in"}},
+		{externalpolicy.Property{Name: "cpus", Value: float64(4)}, MSProp{Name: "cpus", Value: "4", PropType: "int", Op: ">="}},
+		{externalpolicy.Property{Name: "expires", Value: "2027-01-01", Type: externalpolicy.DATETIME_TYPE}, MSProp{Name: "expires", Value: "2027-01-01", PropType: "datetime", Op: "="}},
+		{externalpolicy.Property{Name: "camera", Value: camera, Type: externalpolicy.MAP_TYPE}, MSProp{Name: "camera", Value: `{"model":"x100","mount":{"height":2.5}}`, PropType: "map", Op: "="}},
+	}
+
+	for _, test := range tests {
+		if p, err := ConvertPropertyToExchangeFormat(&test.prop); err != nil {
+			t.Errorf("unable to convert %v, error %v", test.prop, err)
+		} else if *p != test.expected {
+			t.Errorf("%v should convert to %v, got %v", test.prop, test.expected, *p)
+		}
+	}
+
+	if _, err := ConvertPropertyToExchangeFormat(externalpolicy.Property_Factory("gpus", []interface{}{1, 2})); err == nil {
+		t.Errorf("a list of numbers should not be converted")
+	}
+}
@@ -0,0 +1,443 @@
+package exchange
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The purpose of this file is to abstract the way that agreement protocol messages are delivered between agents and
+// agbots. A message is always encrypted with ConstructExchangeMessage before it is handed to a transport, so the
+// transport only moves an opaque message from the sender to the mailbox of the receiver. The exchange mailbox is the
+// default transport. Other transports register themselves by name, and the one that is used is chosen by the
+// MessageTransport section of the agent or agbot config.
+
+const EXCHANGE_MAILBOX_TRANSPORT = "exchange"
+
+// The kinds of parties that send and receive messages. The names match the exchange resources that hold their mailboxes.
+const (
+	TRANSPORT_PARTY_NODE  = "nodes"
+	TRANSPORT_PARTY_AGBOT = "agbots"
+)
+
+// A message received by a transport. The sender's public key is the key that the sender signed the message with.
+type TransportMessage struct {
+	MsgId        int
+	SenderId     string
+	SenderPubKey []byte
+	Message      []byte
+	TimeSent     string
+}
+
+func (m TransportMessage) String() string {
+	return fmt.Sprintf("MsgId: %v, SenderId: %v, TimeSent: %v", m.MsgId, m.SenderId, m.TimeSent)
+}
+
+// Convert the message to the form of a message in a node's exchange mailbox.
+func (m TransportMessage) DeviceMessage() DeviceMessage {
+	return DeviceMessage{MsgId: m.MsgId, AgbotId: m.SenderId, AgbotPubKey: m.SenderPubKey, Message: m.Message, TimeSent: m.TimeSent}
+}
+
+// Convert the message to the form of a message in an agbot's exchange mailbox.
+func (m TransportMessage) AgbotMessage() AgbotMessage {
+	return AgbotMessage{MsgId: m.MsgId, DeviceId: m.SenderId, DevicePubKey: m.SenderPubKey, Message: m.Message, TimeSent: m.TimeSent}
+}
+
+// Each message transport implements this interface.
+type MessageTransport interface {
+	// The name the transport is registered with.
+	Name() string
+
+	// Send an encrypted message, the json form of an ExchangeMessage, to the mailbox of a node or agbot. The ttl is the
+	// number of seconds the message is kept for the receiver.
+	SendMessage(receiverType string, receiverId string, msg []byte, ttl int) error
+
+	// Return the messages in this party's mailbox, at most max of them when max is greater than zero.
+	GetMessages(max int) ([]TransportMessage, error)
+
+	// Return true if the message is still in this party's mailbox.
+	HasMessage(msgId int) (bool, error)
+
+	// Remove a message from this party's mailbox after it has been handled.
+	DeleteMessage(msgId int) error
+
+	// Set the function that is called when a message arrives. Transports that rely on the exchange changes API to
+	// find out about new messages never call it. The function is set once, when the transport is created.
+	OnMessage(notify func())
+
+	// Release the connections held by the transport.
+	Close()
+}
+
+// The party that a transport sends and receives messages for.
+type TransportContext struct {
+	PartyType   string // TRANSPORT_PARTY_NODE or TRANSPORT_PARTY_AGBOT
+	Id          string // The org qualified exchange id of the party
+	Token       string
+	ExchangeURL string
+	HTTPFactory *config.HTTPClientFactory
+	PubKey      []byte // The party's marshalled messaging public key
+	StateDir    string // The directory where a transport keeps the messages it received, so they survive a restart
+}
+
+func (c TransportContext) String() string {
+	return fmt.Sprintf("PartyType: %v, Id: %v, ExchangeURL: %v", c.PartyType, c.Id, c.ExchangeURL)
+}
+
+// Transports are created by a factory function.
+type MessageTransportFactory func(ctx *TransportContext, cfg *config.MessageTransportConfig) (MessageTransport, error)
+
+// Global message transport registry, and the transports that have been created. A transport other than the exchange
+// mailbox holds a connection, so there is one of them for each party.
+var transportLock sync.Mutex
+var transportFactories = map[string]MessageTransportFactory{}
+var transports = map[string]MessageTransport{}
+var transportContexts = map[string]TransportContext{}
+var transportNotify = map[string]func(){}
+
+// Transports call this function to register themselves in the global registry.
+func RegisterMessageTransport(name string, factory MessageTransportFactory) {
+	transportLock.Lock()
+	defer transportLock.Unlock()
+	transportFactories[name] = factory
+}
+
+// Return the transport configured for the party in the context. The exchange mailbox transport is returned when
+// there is no transport configured. Other transports are created the first time they are needed, and are created
+// again when the party's identity changes. A transport that is created again picks up the messages that the one it
+// replaces kept in the state dir.
+func GetMessageTransport(ctx *TransportContext, cfg *config.MessageTransportConfig) (MessageTransport, error) {
+	name := cfg.GetName()
+	if name == EXCHANGE_MAILBOX_TRANSPORT {
+		return NewExchangeMailboxTransport(ctx), nil
+	}
+
+	transportLock.Lock()
+	defer transportLock.Unlock()
+
+	key := name + "/" + ctx.PartyType
+	if t, ok := transports[key]; ok {
+		if prev := transportContexts[key]; prev.Id == ctx.Id && prev.Token == ctx.Token {
+			return t, nil
+		}
+		glog.V(3).Infof(transportLogString(fmt.Sprintf("recreating %v transport for %v", name, ctx.Id)))
+		t.Close()
+		delete(transports, key)
+	}
+
+	factory, ok := transportFactories[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("message transport %v is not supported", name))
+	}
+	t, err := factory(ctx, cfg)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to create %v message transport for %v, error %v", name, ctx.Id, err))
+	}
+	if notify, ok := transportNotify[ctx.PartyType]; ok {
+		t.OnMessage(notify)
+	}
+	transports[key] = t
+	transportContexts[key] = *ctx
+	return t, nil
+}
+
+// Set the function that the transports of a party type call when a message arrives, normally to queue a command for
+// the worker that reads the party's messages. It is set on the transport that already exists, and on each transport
+// that is created from now on.
+func SetMessageNotify(partyType string, notify func()) {
+	transportLock.Lock()
+	defer transportLock.Unlock()
+	transportNotify[partyType] = notify
+	for key, t := range transports {
+		if transportContexts[key].PartyType == partyType {
+			t.OnMessage(notify)
+		}
+	}
+}
+
+// Return the transport that the agent uses to send and receive messages as the node with the given id and token.
+func GetNodeMessageTransport(cfg *config.HorizonConfig, id string, token string, httpFactory *config.HTTPClientFactory) (MessageTransport, error) {
+	ctx := &TransportContext{PartyType: TRANSPORT_PARTY_NODE, Id: id, Token: token, ExchangeURL: cfg.Edge.ExchangeURL, HTTPFactory: httpFactory, StateDir: cfg.Edge.DBPath}
+	return getPartyMessageTransport(ctx, &cfg.Edge.MessageTransport, "")
+}
+
+// Return the transport that the agbot uses to send and receive messages as the agbot with the given id and token.
+func GetAgbotMessageTransport(cfg *config.HorizonConfig, id string, token string, httpFactory *config.HTTPClientFactory) (MessageTransport, error) {
+	ctx := &TransportContext{PartyType: TRANSPORT_PARTY_AGBOT, Id: id, Token: token, ExchangeURL: cfg.AgreementBot.ExchangeURL, HTTPFactory: httpFactory, StateDir: cfg.AgreementBot.DBPath}
+	return getPartyMessageTransport(ctx, &cfg.AgreementBot.MessageTransport, cfg.AgreementBot.MessageKeyPath)
+}
+
+// Returns an HTTP client factory that retries exchange transport errors every 10 seconds until the call succeeds.
+func RetryForeverHTTPFactory(base *config.HTTPClientFactory) *config.HTTPClientFactory {
+	return &config.HTTPClientFactory{
+		NewHTTPClient: base.NewHTTPClient,
+		RetryCount:    0,
+		RetryInterval: 10,
+	}
+}
+
+// The exchange adds the sender's public key to the messages in its mailboxes, other transports need the key to send it
+// with the message.
+func getPartyMessageTransport(ctx *TransportContext, cfg *config.MessageTransportConfig, keyPath string) (MessageTransport, error) {
+	if name := cfg.GetName(); name != EXCHANGE_MAILBOX_TRANSPORT {
+		transportLock.Lock()
+		_, ok := transportFactories[name]
+		transportLock.Unlock()
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("message transport %v is not supported", name))
+		} else if pubKey, _, err := GetKeys(keyPath); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to get messaging keys, error %v", err))
+		} else if mKey, err := MarshalPublicKey(pubKey); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to marshal messaging public key, error %v", err))
+		} else {
+			ctx.PubKey = mKey
+		}
+	}
+	return GetMessageTransport(ctx, cfg)
+}
+
+// Transports other than the exchange mailbox tell the worker that reads the messages about each message that arrives,
+// so the worker reads the mailbox again while messages it read before are still being handled. The worker uses this
+// to hand out each message once. The ids are only unique within one transport, so they are forgotten when the
+// transport changes.
+type DispatchedMessages struct {
+	lock      sync.Mutex
+	transport MessageTransport
+	ids       map[int]bool
+}
+
+func NewDispatchedMessages() *DispatchedMessages {
+	return &DispatchedMessages{ids: make(map[int]bool)}
+}
+
+// The number of messages that have been handed out and are still in the mailbox.
+func (d *DispatchedMessages) Count() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.ids)
+}
+
+// Return the messages read from the transport's mailbox that have not been handed out yet, at most max of them when
+// max is greater than zero, and remember them as handed out. Messages that are no longer in the mailbox are forgotten.
+func (d *DispatchedMessages) Undispatched(t MessageTransport, msgs []TransportMessage, max int) []TransportMessage {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.transport != t {
+		d.transport = t
+		d.ids = make(map[int]bool)
+	}
+
+	inMailbox := make(map[int]bool)
+	newMsgs := make([]TransportMessage, 0, len(msgs))
+	for _, m := range msgs {
+		if d.ids[m.MsgId] {
+			inMailbox[m.MsgId] = true
+		} else if max <= 0 || len(newMsgs) < max {
+			inMailbox[m.MsgId] = true
+			newMsgs = append(newMsgs, m)
+		}
+	}
+	d.ids = inMailbox
+	return newMsgs
+}
+
+// How long the public key that a node or agbot registered in the exchange is cached.
+const REGISTERED_KEY_CACHE_TIME = 5 * time.Minute
+
+type registeredKey struct {
+	key     []byte
+	fetched time.Time
+}
+
+var registeredKeyLock sync.Mutex
+var registeredKeys = map[string]registeredKey{}
+
+// Return the messaging public key that a node or agbot registered in the exchange. Transports other than the exchange
+// mailbox use it to check the key that the sender of a message says it signed the message with. The key is cached,
+// refresh reads it from the exchange again, for example when a party might have registered a new key. There is no key
+// for a party that is not registered. The exchange is called once, an error is returned so that the caller can try
+// again later.
+func GetRegisteredPublicKey(ctx *TransportContext, partyType string, id string, refresh bool) ([]byte, error) {
+	cacheKey := partyType + "/" + id
+	registeredKeyLock.Lock()
+	cached, ok := registeredKeys[cacheKey]
+	registeredKeyLock.Unlock()
+	if ok && !refresh && time.Since(cached.fetched) < REGISTERED_KEY_CACHE_TIME {
+		return cached.key, nil
+	}
+
+	targetURL := ctx.ExchangeURL + "orgs/" + GetOrg(id) + "/" + partyType + "/" + GetId(id)
+	var resp interface{}
+	if partyType == TRANSPORT_PARTY_AGBOT {
+		resp = new(GetAgbotsResponse)
+	} else {
+		resp = new(GetDevicesResponse)
+	}
+	if err, tpErr := InvokeExchange(ctx.HTTPFactory.NewHTTPClient(nil), "GET", targetURL, ctx.Id, ctx.Token, nil, &resp); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to get %v %v from the exchange, error %v", partyType, id, err))
+	} else if tpErr != nil {
+		return nil, errors.New(fmt.Sprintf("unable to get %v %v from the exchange, error %v", partyType, id, tpErr))
+	}
+
+	// The node key is returned base64 encoded.
+	var key []byte
+	if partyType == TRANSPORT_PARTY_AGBOT {
+		key = resp.(*GetAgbotsResponse).Agbots[id].PublicKey
+	} else if dev, ok := resp.(*GetDevicesResponse).Devices[id]; ok {
+		if decoded, err := base64.StdEncoding.DecodeString(dev.PublicKey); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to decode the public key of %v %v, error %v", partyType, id, err))
+		} else {
+			key = decoded
+		}
+	}
+
+	registeredKeyLock.Lock()
+	registeredKeys[cacheKey] = registeredKey{key: key, fetched: time.Now()}
+	registeredKeyLock.Unlock()
+	return key, nil
+}
+
+// Close all the transports that hold connections. This is called when the agent or agbot is shutting down.
+func CloseMessageTransports() {
+	transportLock.Lock()
+	defer transportLock.Unlock()
+	for key, t := range transports {
+		t.Close()
+		delete(transports, key)
+		delete(transportContexts, key)
+	}
+}
+
+// The exchange mailbox transport posts messages to the exchange, the receiver polls its mailbox for them.
+type ExchangeMailboxTransport struct {
+	ctx TransportContext
+}
+
+func NewExchangeMailboxTransport(ctx *TransportContext) *ExchangeMailboxTransport {
+	return &ExchangeMailboxTransport{ctx: *ctx}
+}
+
+func (t *ExchangeMailboxTransport) Name() string {
+	return EXCHANGE_MAILBOX_TRANSPORT
+}
+
+func (t *ExchangeMailboxTransport) mailboxURL(partyType string, id string) string {
+	return t.ctx.ExchangeURL + "orgs/" + GetOrg(id) + "/" + partyType + "/" + GetId(id) + "/msgs"
+}
+
+func (t *ExchangeMailboxTransport) SendMessage(receiverType string, receiverId string, msg []byte, ttl int) error {
+	var resp interface{}
+	resp = new(PostDeviceResponse)
+	return t.invoke("POST", t.mailboxURL(receiverType, receiverId), CreatePostMessage(msg, ttl), &resp)
+}
+
+func (t *ExchangeMailboxTransport) GetMessages(max int) ([]TransportMessage, error) {
+	targetURL := t.mailboxURL(t.ctx.PartyType, t.ctx.Id)
+	if max > 0 {
+		targetURL += "?maxmsgs=" + strconv.Itoa(max)
+	}
+
+	msgs := make([]TransportMessage, 0)
+	if t.ctx.PartyType == TRANSPORT_PARTY_AGBOT {
+		var resp interface{}
+		resp = new(GetAgbotMessageResponse)
+		if err := t.invoke("GET", targetURL, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, m := range resp.(*GetAgbotMessageResponse).Messages {
+			msgs = append(msgs, TransportMessage{MsgId: m.MsgId, SenderId: m.DeviceId, SenderPubKey: m.DevicePubKey, Message: m.Message, TimeSent: m.TimeSent})
+		}
+	} else {
+		var resp interface{}
+		resp = new(GetDeviceMessageResponse)
+		if err := t.invoke("GET", targetURL, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, m := range resp.(*GetDeviceMessageResponse).Messages {
+			msgs = append(msgs, TransportMessage{MsgId: m.MsgId, SenderId: m.AgbotId, SenderPubKey: m.AgbotPubKey, Message: m.Message, TimeSent: m.TimeSent})
+		}
+	}
+	return msgs, nil
+}
+
+func (t *ExchangeMailboxTransport) HasMessage(msgId int) (bool, error) {
+	targetURL := t.mailboxURL(t.ctx.PartyType, t.ctx.Id) + "/" + strconv.Itoa(msgId)
+
+	ids := make([]int, 0)
+	if t.ctx.PartyType == TRANSPORT_PARTY_AGBOT {
+		var resp interface{}
+		resp = new(GetAgbotMessageResponse)
+		if err := t.invoke("GET", targetURL, nil, &resp); err != nil {
+			return false, err
+		}
+		for _, msg := range resp.(*GetAgbotMessageResponse).Messages {
+			ids = append(ids, msg.MsgId)
+		}
+	} else {
+		var resp interface{}
+		resp = new(GetDeviceMessageResponse)
+		if err := t.invoke("GET", targetURL, nil, &resp); err != nil {
+			return false, err
+		}
+		for _, msg := range resp.(*GetDeviceMessageResponse).Messages {
+			ids = append(ids, msg.MsgId)
+		}
+	}
+
+	for _, id := range ids {
+		if id == msgId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t *ExchangeMailboxTransport) DeleteMessage(msgId int) error {
+	var resp interface{}
+	resp = new(PostDeviceResponse)
+	return t.invoke("DELETE", t.mailboxURL(t.ctx.PartyType, t.ctx.Id)+"/"+strconv.Itoa(msgId), nil, &resp)
+}
+
+// The exchange changes API tells the agent and agbot about new messages.
+func (t *ExchangeMailboxTransport) OnMessage(notify func()) {
+}
+
+func (t *ExchangeMailboxTransport) Close() {
+}
+
+// Call the exchange, retrying transport errors the way the context's HTTP client factory is set up to.
+func (t *ExchangeMailboxTransport) invoke(method string, targetURL string, params interface{}, resp *interface{}) error {
+	retryCount := t.ctx.HTTPFactory.RetryCount
+	retryInterval := t.ctx.HTTPFactory.GetRetryInterval()
+
+	for {
+		if err, tpErr := InvokeExchange(t.ctx.HTTPFactory.NewHTTPClient(nil), method, targetURL, t.ctx.Id, t.ctx.Token, params, resp); err != nil {
+			return err
+		} else if tpErr != nil {
+			glog.Warningf(transportLogString(tpErr.Error()))
+			if t.ctx.HTTPFactory.RetryCount == 0 {
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			} else if retryCount == 0 {
+				return errors.New(fmt.Sprintf("exceeded %v retries for error: %v", t.ctx.HTTPFactory.RetryCount, tpErr))
+			} else {
+				retryCount--
+				time.Sleep(time.Duration(retryInterval) * time.Second)
+				continue
+			}
+		} else {
+			glog.V(5).Infof(transportLogString(fmt.Sprintf("%v %v succeeded", method, targetURL)))
+			return nil
+		}
+	}
+}
+
+var transportLogString = func(v interface{}) string {
+	return fmt.Sprintf("MessageTransport %v", v)
+}
@@ -0,0 +1,79 @@
+// +build unit
+
+package exchange
+
+import (
+	"github.com/open-horizon/anax/config"
+	"testing"
+)
+
+// A transport that only records the notify function it is given.
+type testTransport struct {
+	ExchangeMailboxTransport
+	notify func()
+}
+
+func (t *testTransport) OnMessage(notify func()) {
+	t.notify = notify
+}
+
+func Test_SetMessageNotify(t *testing.T) {
+	RegisterMessageTransport("test", func(ctx *TransportContext, cfg *config.MessageTransportConfig) (MessageTransport, error) {
+		return &testTransport{}, nil
+	})
+	defer CloseMessageTransports()
+
+	cfg := &config.MessageTransportConfig{Name: "test"}
+	calls := 0
+	SetMessageNotify(TRANSPORT_PARTY_NODE, func() { calls++ })
+
+	// The function is set when the transport is created, and not again when the transport is returned.
+	tr, err := GetMessageTransport(&TransportContext{PartyType: TRANSPORT_PARTY_NODE, Id: "myorg/node1", Token: "token"}, cfg)
+	if err != nil {
+		t.Fatalf("unable to create transport, error %v", err)
+	}
+	tr.(*testTransport).notify()
+	tr.(*testTransport).notify = nil
+	if again, _ := GetMessageTransport(&TransportContext{PartyType: TRANSPORT_PARTY_NODE, Id: "myorg/node1", Token: "token"}, cfg); again != tr {
+		t.Errorf("the transport should have been reused")
+	} else if tr.(*testTransport).notify != nil {
+		t.Errorf("the notify function should only be set when the transport is created")
+	}
+
+	// Setting the function again sets it on the existing transport of the party type.
+	SetMessageNotify(TRANSPORT_PARTY_NODE, func() { calls += 10 })
+	tr.(*testTransport).notify()
+	if calls != 11 {
+		t.Errorf("both notify functions should have been called once, calls %v", calls)
+	}
+}
+
+func Test_DispatchedMessages(t *testing.T) {
+	d := NewDispatchedMessages()
+	tr := &ExchangeMailboxTransport{}
+	msgs := []TransportMessage{{MsgId: 1}, {MsgId: 2}, {MsgId: 3}}
+
+	if newMsgs := d.Undispatched(tr, msgs[:2], 0); len(newMsgs) != 2 {
+		t.Errorf("all the messages should be new, got %v", newMsgs)
+	}
+
+	// Message 1 has been deleted, message 2 is still being handled and message 3 is new.
+	if newMsgs := d.Undispatched(tr, msgs[1:], 0); len(newMsgs) != 1 || newMsgs[0].MsgId != 3 {
+		t.Errorf("only message 3 should be new, got %v", newMsgs)
+	} else if d.Count() != 2 {
+		t.Errorf("messages 2 and 3 should be dispatched, count %v", d.Count())
+	}
+
+	// At most max new messages are handed out, the others are handed out later.
+	msgs = append(msgs, TransportMessage{MsgId: 4}, TransportMessage{MsgId: 5})
+	if newMsgs := d.Undispatched(tr, msgs[1:], 1); len(newMsgs) != 1 || newMsgs[0].MsgId != 4 {
+		t.Errorf("only message 4 should be new, got %v", newMsgs)
+	} else if newMsgs := d.Undispatched(tr, msgs[1:], 1); len(newMsgs) != 1 || newMsgs[0].MsgId != 5 {
+		t.Errorf("only message 5 should be new, got %v", newMsgs)
+	}
+
+	// The ids of another transport are not the same messages.
+	if newMsgs := d.Undispatched(&ExchangeMailboxTransport{}, msgs, 0); len(newMsgs) != len(msgs) {
+		t.Errorf("all the messages of a new transport should be new, got %v", newMsgs)
+	}
+}
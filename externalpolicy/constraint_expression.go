@@ -107,6 +107,25 @@ func (self *ConstraintExpression) IsSatisfiedBy(props []Property) error {
 	}
 }
 
+// This function describes the result of each geo operator in the expression for the locations in the input set of
+// properties, how far a location is from the center of a within operator or whether it is inside the area of an
+// inside operator. It returns nil when there are no geo operators or the expression cannot be parsed.
+func (self *ConstraintExpression) GeoDetails(props []Property) []string {
+	if len(*self) == 0 {
+		return nil
+	}
+
+	rp, err := RequiredPropertyFromConstraint(self)
+	if err != nil || rp == nil || len(*rp) == 0 {
+		return nil
+	}
+	topMap := map[string]interface{}(*rp)
+	if details := geoDetails(&topMap, &props); len(details) != 0 {
+		return details
+	}
+	return nil
+}
+
 func (self *ConstraintExpression) GetStrings() []string {
 	return ([]string(*self))
 }
@@ -171,8 +190,7 @@ func parseConstraintExpression(constraint string, handler plugin_registry.Constr
 			return nil, constraint, err
 		}
 		if strings.TrimSpace(nextProp) != "" {
-			prop := strings.Split(nextProp, "\a")
-			andArray = append(andArray, *PropertyExpression_Factory(prop[0], strings.TrimSpace(prop[2]), strings.TrimSpace(prop[1])))
+			andArray = append(andArray, propertyExpressionFromString(nextProp))
 		}
 
 		ctrlOp, constraint, err = handler.GetNextOperator(constraint)
@@ -180,7 +198,24 @@ func parseConstraintExpression(constraint string, handler plugin_registry.Constr
 			return nil, constraint, err
 		}
 
-		if ctrlOp == "(" {
+		if ctrlOp == "NOT" {
+			if strings.TrimSpace(nextProp) != "" {
+				return nil, constraint, fmt.Errorf("NOT must be preceded by one of AND,&&,OR,|| in %v", nextProp)
+			}
+
+			// handle the negated expression, and then continue with the operator that follows it
+			subExpr, constraint, err = parseNegatedExpression(constraint, handler)
+			if err != nil {
+				return nil, constraint, err
+			}
+
+			andArray = append(andArray, subExpr)
+
+			ctrlOp, constraint, err = handler.GetNextOperator(constraint)
+			if err != nil {
+				return nil, constraint, err
+			}
+		} else if ctrlOp == "(" {
 			// handle a parenthetical expression as a seperate constraint expression
 			subExpr, constraint, err = parseConstraintExpression(constraint, handler)
 			if err != nil {
@@ -213,3 +248,42 @@ func parseConstraintExpression(constraint string, handler plugin_registry.Constr
 
 	return nil, constraint, err
 }
+
+// parse the property expression or parenthetical expression that follows a NOT, it is returned inside a not operator.
+func parseNegatedExpression(constraint string, handler plugin_registry.ConstraintLanguagePlugin) (map[string]interface{}, string, error) {
+	var operand interface{}
+
+	nextProp, constraint, err := handler.GetNextExpression(constraint)
+	if err != nil {
+		return nil, constraint, err
+	}
+
+	if strings.TrimSpace(nextProp) != "" {
+		operand = propertyExpressionFromString(nextProp)
+	} else {
+		var ctrlOp string
+		ctrlOp, constraint, err = handler.GetNextOperator(constraint)
+		if err != nil {
+			return nil, constraint, err
+		}
+
+		if ctrlOp == "(" {
+			operand, constraint, err = parseConstraintExpression(constraint, handler)
+		} else if ctrlOp == "NOT" {
+			operand, constraint, err = parseNegatedExpression(constraint, handler)
+		} else {
+			err = fmt.Errorf("NOT must be followed by a property expression or a parenthetical expression")
+		}
+		if err != nil {
+			return nil, constraint, err
+		}
+	}
+
+	return map[string]interface{}{OP_NOT: []interface{}{operand}}, constraint, nil
+}
+
+// convert a property expression returned by the language handler, name\aoperator\avalue, to a PropertyExpression.
+func propertyExpressionFromString(exp string) PropertyExpression {
+	prop := strings.Split(exp, "\a")
+	return *PropertyExpression_Factory(prop[0], strings.TrimSpace(prop[2]), strings.TrimSpace(prop[1]))
+}
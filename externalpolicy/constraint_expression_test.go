@@ -3,7 +3,9 @@
 package externalpolicy
 
 import (
+	"fmt"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
+	"strings"
 	"testing"
 )
 
@@ -298,6 +300,193 @@ func Test_fail_IsSatisfiedBy(t *testing.T) {
 	}
 }
 
+func Test_IsSatisfiedBy_NotExistsPatterns(t *testing.T) {
+	prop_list := `[{"name":"zone", "value":"lab"},{"name":"hostname", "value":"store-42"},{"name":"location", "value":"us-east-1"},{"name":"gpu", "value":false},{"name":"cpu", "value":4},{"name":"tags", "value":"edge,camera-front","type":"list of strings"},{"name":"version", "value":"2.1.5","type":"version"}]`
+	props := create_property_list(prop_list, t)
+
+	tests := []struct {
+		constraint []string
+		satisfied  bool
+	}{
+		{[]string{"NOT zone == lab"}, false},
+		{[]string{"NOT zone == prod"}, true},
+		{[]string{"NOT (zone == lab)"}, false},
+		{[]string{"NOT (zone == prod || zone == test)"}, true},
+		{[]string{"NOT (zone == prod || zone == lab)"}, false},
+		{[]string{"NOT NOT zone == lab"}, true},
+		{[]string{"cpu > 2 AND NOT zone == prod"}, true},
+		{[]string{"NOT zone == lab OR cpu > 2"}, true},
+		{[]string{"NOT zone == lab && cpu > 2"}, false},
+		{[]string{"(NOT zone == lab) || (NOT cpu > 2)"}, false},
+		{[]string{"NOT region == us"}, true},
+		{[]string{"gpu exists"}, true},
+		{[]string{"tpu exists"}, false},
+		{[]string{"NOT tpu exists", "gpu exists"}, true},
+		{[]string{"NOT gpu exists"}, false},
+		{[]string{"hostname ~= \"store-[0-9]+\""}, true},
+		{[]string{"hostname ~= \"^store-[0-9]$\""}, false},
+		{[]string{"hostname ~= store"}, true},
+		{[]string{"cpu ~= \"^[2-8]$\""}, true},
+		{[]string{"tags ~= \"^camera-\""}, true},
+		{[]string{"hostname like \"store-*\""}, true},
+		{[]string{"hostname like \"shop-*\""}, false},
+		{[]string{"hostname like store-4?"}, true},
+		{[]string{"tags like edge"}, true},
+		{[]string{"location ^= us-east"}, true},
+		{[]string{"location ^= \"us-west\""}, false},
+		{[]string{"version ^= 2.1"}, true},
+		{[]string{"region ^= us"}, false},
+		{[]string{"location ^= us && NOT location ^= us-west && hostname ~= \"-[0-9]+$\""}, true},
+		{[]string{"zone == lab && cpu == 4", "version in [2.0.0,3.0.0)"}, true},
+	}
+
+	for _, test := range tests {
+		ce := ConstraintExpression(test.constraint)
+		err := ce.IsSatisfiedBy(*props)
+		if test.satisfied && err != nil {
+			t.Errorf("Error: %v should be satisfied by %v, but got error: %v", test.constraint, prop_list, err)
+		} else if !test.satisfied && err == nil {
+			t.Errorf("Error: %v should not be satisfied by %v", test.constraint, prop_list)
+		}
+	}
+}
+
+func Test_IsSatisfiedBy_Geo(t *testing.T) {
+	// A store in Newark, with a region id.
+	prop_list := `[{"name":"location","value":"40.7357,-74.1724,nj-north","type":"geo"},{"name":"hq","value":"40.7128,-74.0060"},{"name":"zone","value":"lab"}]`
+	props := create_property_list(prop_list, t)
+
+	nycPolygon := `{"type":"Polygon","coordinates":[[[-74.3,40.5],[-73.7,40.5],[-73.7,40.95],[-74.3,40.95],[-74.3,40.5]]]}`
+	phlPolygon := `{"type":"Polygon","coordinates":[[[-75.3,39.85],[-75.0,39.85],[-75.0,40.1],[-75.3,40.1],[-75.3,39.85]]]}`
+
+	tests := []struct {
+		constraint string
+		satisfied  bool
+	}{
+		{"location within 50km of (40.7,-74.0)", true},
+		{"location within 10km of (40.7,-74.0)", false},
+		{"location within 20 mi of (40.7,-74.0)", true},
+		{"location within 500m of (40.7357,-74.1724)", true},
+		{"hq within 1km of (40.7128,-74.0060)", true},
+		{"location inside " + nycPolygon, true},
+		{"location inside " + phlPolygon, false},
+		{"location inside {\"type\":\"Feature\",\"properties\":{\"name\":\"nyc\",\"tags\":{\"metro\":{\"state\":\"ny\"}}},\"geometry\":" + nycPolygon + "} && zone == lab", true},
+		{"location inside nj-north", true},
+		{"location inside \"nj-south\"", false},
+		{"hq inside nj-north", false},
+		{"zone within 50km of (40.7,-74.0)", false},
+		{"nowhere within 50km of (40.7,-74.0)", false},
+		{"NOT location inside " + phlPolygon + " && location within 50km of (40.7,-74.0)", true},
+		{"zone == lab && (location inside " + phlPolygon + " || location inside nj-north)", true},
+	}
+
+	for _, test := range tests {
+		ce := ConstraintExpression([]string{test.constraint})
+		err := ce.IsSatisfiedBy(*props)
+		if test.satisfied && err != nil {
+			t.Errorf("Error: %v should be satisfied by %v, but got error: %v", test.constraint, prop_list, err)
+		} else if !test.satisfied && err == nil {
+			t.Errorf("Error: %v should not be satisfied by %v", test.constraint, prop_list)
+		}
+	}
+
+	// The reason shown by deploycheck includes the geo constraint as it was written.
+	ce := ConstraintExpression([]string{"location within 10km of (40.7,-74.0)"})
+	if err := ce.IsSatisfiedBy(*props); err == nil || !strings.Contains(err.Error(), "location within 10km of (40.7,-74.0)") || !strings.Contains(err.Error(), "location is 15.1km from (40.7,-74)") {
+		t.Errorf("Error: unexpected error for an unsatisfied geo constraint: %v", err)
+	}
+
+	// The geo details describe each geo operator, whether or not it is satisfied.
+	ce = ConstraintExpression([]string{"location within 50km of (40.7,-74.0) && (location inside " + phlPolygon + " || location inside nj-north) && zone == lab"})
+	details := ce.GeoDetails(*props)
+	expected := "[location is 15.1km from (40.7,-74) location (40.7357,-74.1724) is not inside the area location is in region nj-north]"
+	if fmt.Sprintf("%v", details) != expected {
+		t.Errorf("Error: expected geo details %v, got %v", expected, details)
+	}
+	ce = ConstraintExpression([]string{"zone == lab"})
+	if details := ce.GeoDetails(*props); details != nil {
+		t.Errorf("Error: expected no geo details, got %v", details)
+	}
+}
+
+func Test_IsSatisfiedBy_MapDateTime(t *testing.T) {
+	prop_list := `[{"name":"camera","value":{"model":"x100","resolution":1080,"ir":true,"lenses":["wide","zoom"],"mount":{"height":2.5}},"type":"map"},` +
+		`{"name":"cameras","value":[{"model":"x100"},{"model":"x200"}]},{"name":"site","value":{"location":"40.7357,-74.1724","opened":"2020-06-01"}},` +
+		`{"name":"expires","value":"2027-01-01","type":"datetime"},{"name":"installed","value":"2020-03-15T08:30:00-05:00","type":"datetime"},{"name":"zone","value":"lab"}]`
+	props := create_property_list(prop_list, t)
+
+	tests := []struct {
+		constraint string
+		satisfied  bool
+	}{
+		{"camera.model == x100", true},
+		{"camera.model == x200", false},
+		{"camera.resolution >= 1080 && camera.ir == true", true},
+		{"camera.mount.height < 2", false},
+		{"camera.lenses in \"zoom,tele\"", true},
+		{"camera.model ^= x1 && camera.mount exists", true},
+		{"camera.flash exists", false},
+		{"NOT camera.flash exists", true},
+		{"cameras.1.model == x200", true},
+		{"cameras.2.model == x200", false},
+		{"site.location within 50km of (40.7,-74.0)", true},
+		{"site.opened < 2021-01-01", true},
+		{"zone.name == lab", false},
+		{"expires > 2026-06-30", true},
+		{"expires > 2027-01-01", false},
+		{"expires >= 2027-01-01", true},
+		{"expires == 2027-01-01T00:00:00Z", true},
+		{"expires != 2027-01-01T01:00:00+01:00", false},
+		{"installed < 2020-03-15T13:31:00Z", true},
+		{"installed < 2020-03-15T13:30:00Z", false},
+		{"installed < now && expires > now-87600h", true},
+		{"installed > now-24h", false},
+		{"zone < 2027-01-01", false},
+	}
+
+	for _, test := range tests {
+		ce := ConstraintExpression([]string{test.constraint})
+		err := ce.IsSatisfiedBy(*props)
+		if test.satisfied && err != nil {
+			t.Errorf("Error: %v should be satisfied by %v, but got error: %v", test.constraint, prop_list, err)
+		} else if !test.satisfied && err == nil {
+			t.Errorf("Error: %v should not be satisfied by %v", test.constraint, prop_list)
+		}
+	}
+
+	// The time in a constraint is relative to the time the constraint is checked.
+	p := Property{Name: "expires", Value: "2027-01-01", Type: DATETIME_TYPE}
+	now, _ := ParseDateTime("2026-12-01")
+	if satisfied, ok := propertyDateTimeCompare(&p, PropertyExpression_Factory("expires", "now+720h", greaterthan), now); !ok || !satisfied {
+		t.Errorf("Error: %v should be more than 30 days after %v", p.Value, now)
+	} else if satisfied, ok := propertyDateTimeCompare(&p, PropertyExpression_Factory("expires", "now+768h", greaterthan), now); !ok || satisfied {
+		t.Errorf("Error: %v should be less than 32 days after %v", p.Value, now)
+	}
+}
+
+func Test_RequiredPropertyFromConstraint_Not(t *testing.T) {
+	ce := ConstraintExpression([]string{"NOT (zone == lab || gpu exists)"})
+	rp, err := RequiredPropertyFromConstraint(&ce)
+	if err != nil {
+		t.Fatalf("Error: unable to convert expression: %v", err)
+	} else if err := rp.IsValid(); err != nil {
+		t.Errorf("Error: converted expression %v is not valid: %v", rp, err)
+	}
+
+	// The not operator wraps the parenthetical expression.
+	topMap := map[string]interface{}(*rp)
+	if display := displayRequiredProperty(&topMap); display != "(NOT (zone==lab, gpu exists))" {
+		t.Errorf("Error: unexpected display of the converted expression: %v", display)
+	}
+
+	for _, bad := range []string{"zone == lab NOT gpu exists", "zone == lab && NOT"} {
+		ce = ConstraintExpression([]string{bad})
+		if _, err := RequiredPropertyFromConstraint(&ce); err == nil {
+			t.Errorf("Error: %v should not convert", bad)
+		}
+	}
+}
+
 func Test_MergeWith(t *testing.T) {
 	ce1 := new(ConstraintExpression)
 	ce2 := new(ConstraintExpression)
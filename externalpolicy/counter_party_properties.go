@@ -1,11 +1,16 @@
 package externalpolicy
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/open-horizon/anax/externalpolicy/geo"
 	"github.com/open-horizon/anax/semanticversion"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // The purpose this file is to evaluate the Constraints field in the Policy struct
@@ -23,9 +28,18 @@ import (
 // _control_operator_    = {"and", "or", "not"}
 // _expression_          = _control_operator_: [_expression_] || property
 // _property_            = "name": _property_name_, "value": _property_value, "op": _comparison_operator_
-// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!="}
+// _comparison_operator_ = {"<", "=", ">", "<=", ">=", "!=", "in", "~=", "like", "^=", "exists", "within", "inside"}
 // The "=" and "!=" comparison operators can be applied to strings and integers.
+// The "~=" (regular expression), "like" (glob) and "^=" (prefix) operators match the value of the property as a string.
+// The "exists" operator ignores the value, it is satisfied when the property is defined.
+// The "within" and "inside" operators are satisfied when the location in a geo property is within a distance of a
+// point, or inside a GeoJSON polygon or region.
+// The "<", ">", "<=", ">=", "=" and "!=" operators compare the time in a datetime property with an RFC3339 time, a
+// date, or now with an optional duration, for example now+720h.
+// A name with dots refers to a value inside a map property, for example camera.model is the value of the model key
+// in the map property camera. Lists inside a map are indexed by number, for example cameras.0.model.
 // If the "op" key is missing, then equal is assumed.
+// A "not" control operator is satisfied when none of the expressions in its array are satisfied.
 //
 // See the unit tests for examples of valid and invalid syntax
 //
@@ -65,6 +79,12 @@ const lessthaneq = "<="
 const greaterthaneq = ">="
 const notequalto = "!="
 const isin = "in"
+const matches = "~="
+const islike = "like"
+const hasprefix = "^="
+const isdefined = "exists"
+const within = "within"
+const inside = "inside"
 
 // This struct represents property value expressions to be satisfied
 type PropertyExpression struct {
@@ -132,7 +152,7 @@ func (self *RequiredProperty) satisfied(cop *map[string]interface{}, props *[]Pr
 		for _, p := range propArray {
 			if prop := isPropertyExpression(p); prop != nil {
 				if !propertyInArray(prop, props) {
-					return errors.New(fmt.Sprintf("The required property '%v %v %v' were not found in the available properties %v", prop.Name, prop.Op, prop.Value, displayProperties(props)))
+					return errors.New(fmt.Sprintf("The required property '%v %v %v' were not found in the available properties %v%v", prop.Name, prop.Op, prop.Value, displayProperties(props), displayGeoDetails([]string{geoDetail(prop, props)})))
 				}
 			} else if cop := isControlOp(p); cop != nil {
 				if err := self.satisfied(cop, props); err != nil {
@@ -162,9 +182,23 @@ func (self *RequiredProperty) satisfied(cop *map[string]interface{}, props *[]Pr
 				return errors.New(fmt.Sprintf("Control Operator contains an element that is neither a Property nor a control operator: %v.", p))
 			}
 		}
-		return errors.New(fmt.Sprintf("The required properties %v were not found in the available properties %v", displayRequiredProperty(cop), displayProperties(props)))
+		return errors.New(fmt.Sprintf("The required properties %v were not found in the available properties %v%v", displayRequiredProperty(cop), displayProperties(props), displayGeoDetails(geoDetails(cop, props))))
 	} else if controlOp == OP_NOT {
 
+		propArray := (*cop)[controlOp].([]interface{})
+		for _, p := range propArray {
+			if prop := isPropertyExpression(p); prop != nil {
+				if propertyInArray(prop, props) {
+					return errors.New(fmt.Sprintf("The property '%v' must not be satisfied by the available properties %v", displayPropertyExpression(prop), displayProperties(props)))
+				}
+			} else if cop := isControlOp(p); cop != nil {
+				if err := self.satisfied(cop, props); err == nil {
+					return errors.New(fmt.Sprintf("The properties %v must not be satisfied by the available properties %v", displayRequiredProperty(cop), displayProperties(props)))
+				}
+			} else {
+				return errors.New(fmt.Sprintf("Control Operator contains an element that is neither a Property nor a control operator: %v.", p))
+			}
+		}
 	}
 
 	return nil
@@ -274,15 +308,29 @@ func getControlOperator(m *map[string]interface{}) string {
 // Return a map of control operators so that it's easy to check if a string is equivalent to one
 // of the supported control operators.
 func controlOperators() map[string]int {
-	// return map[string]int {and:0, or:0, not:0}
-	return map[string]int{OP_AND: 0, OP_OR: 0}
+	return map[string]int{OP_AND: 0, OP_OR: 0, OP_NOT: 0}
 }
 
 // Return a map of comparison operators so that it's easy to check if a string is equivalent to one
 // of the supported comparison operators.
 func comparisonOperators() map[string]int {
 	// return map[string]int {and:0, or:0, not:0}
-	return map[string]int{lessthan: 0, greaterthan: 0, doubleequalto: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0, isin: 0}
+	return map[string]int{lessthan: 0, greaterthan: 0, doubleequalto: 0, equalto: 0, lessthaneq: 0, greaterthaneq: 0, notequalto: 0, isin: 0, matches: 0, islike: 0, hasprefix: 0, isdefined: 0, within: 0, inside: 0}
+}
+
+// Return a map of comparison operators that work on the location in a geo property.
+func geoOperators() map[string]int {
+	return map[string]int{within: 0, inside: 0}
+}
+
+// Return a map of comparison operators that match the property value, as a string, against a pattern.
+func patternOperators() map[string]int {
+	return map[string]int{matches: 0, islike: 0, hasprefix: 0}
+}
+
+// Return a map of comparison operators that order numbers and times.
+func orderingOperators() map[string]int {
+	return map[string]int{lessthan: 0, greaterthan: 0, lessthaneq: 0, greaterthaneq: 0}
 }
 
 // Return a map of comparison operators that only work on strings
@@ -414,10 +462,22 @@ func getKeys(m map[string]interface{}) []string {
 func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
 	for _, p := range *props {
 		if p.Name != propexp.Name {
+			// The name might refer to a value inside a map property.
+			if mp := mapPathProperty(&p, propexp.Name); mp != nil && propertyInArray(propexp, &[]Property{*mp}) {
+				return true
+			}
 			// These are not the droids we're looking for
 			continue
 		} else {
-			if isFloat64(p.Value) {
+			if propexp.Op == isdefined {
+				return true
+			} else if _, ok := patternOperators()[propexp.Op]; ok {
+				return propertyMatchesPattern(&p, propexp)
+			} else if _, ok := geoOperators()[propexp.Op]; ok {
+				return propertyInGeoArea(&p, propexp)
+			} else if satisfied, ok := propertyDateTimeCompare(&p, propexp, time.Now()); ok {
+				return satisfied
+			} else if isFloat64(p.Value) {
 				var propexpFloat float64
 				if isFloat64(propexp.Value) {
 					propexpFloat = propexp.Value.(float64)
@@ -489,6 +549,226 @@ func propertyInArray(propexp *PropertyExpression, props *[]Property) bool {
 	return false
 }
 
+// This function returns the value inside a map property that is named by a dotted path, like camera.model for the
+// model key of the map property camera, as a property with the full path as its name. Lists of strings are returned
+// as a list of strings property and numbers as float64, so that they are compared the same way as top level
+// properties. It returns nil if the property is not a map or the path is not in it.
+func mapPathProperty(p *Property, name string) *Property {
+	if !strings.HasPrefix(name, p.Name+".") {
+		return nil
+	}
+	value := p.Value
+	for _, key := range strings.Split(strings.TrimPrefix(name, p.Name+"."), ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			elem, ok := v[key]
+			if !ok {
+				return nil
+			}
+			value = elem
+		case []interface{}:
+			ix, err := strconv.Atoi(key)
+			if err != nil || ix < 0 || ix >= len(v) {
+				return nil
+			}
+			value = v[ix]
+		default:
+			return nil
+		}
+	}
+
+	mp := &Property{Name: name, Value: value}
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			mp.Value = f
+		}
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, elem := range v {
+			if s, ok := elem.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		if len(strs) == len(v) {
+			mp.Value = strings.Join(strs, ",")
+			mp.Type = LIST_TYPE
+		}
+	}
+	return mp
+}
+
+// This function compares the time in a datetime property with the time in a constraint, which is an RFC3339 time, a
+// date, or now with an optional duration added or subtracted, like now+720h or now-24h. The second return value is
+// false when the expression is not a time comparison. Properties without a declared type are compared as times by
+// the ordering operators when both values are times.
+func propertyDateTimeCompare(p *Property, propexp *PropertyExpression, now time.Time) (bool, bool) {
+	if !isString(p.Value) || !isString(propexp.Value) {
+		return false, false
+	} else if p.Type != DATETIME_TYPE {
+		if _, ok := orderingOperators()[propexp.Op]; !ok || p.Type != UNDECLARED_TYPE {
+			return false, false
+		}
+	}
+
+	pTime, err := ParseDateTime(p.Value.(string))
+	if err != nil {
+		return false, p.Type == DATETIME_TYPE
+	}
+	cTime, err := constraintDateTime(propexp.Value.(string), now)
+	if err != nil {
+		return false, p.Type == DATETIME_TYPE
+	}
+
+	switch propexp.Op {
+	case lessthan:
+		return pTime.Before(cTime), true
+	case greaterthan:
+		return pTime.After(cTime), true
+	case lessthaneq:
+		return !pTime.After(cTime), true
+	case greaterthaneq:
+		return !pTime.Before(cTime), true
+	case notequalto:
+		return !pTime.Equal(cTime), true
+	case equalto, doubleequalto, "":
+		return pTime.Equal(cTime), true
+	}
+	return false, true
+}
+
+// This function parses the time in a constraint, either a datetime or now with an optional duration.
+func constraintDateTime(value string, now time.Time) (time.Time, error) {
+	value = removeQuotes(removeSpaces(value))
+	if strings.HasPrefix(value, "now") {
+		if value == "now" {
+			return now, nil
+		} else if d, err := time.ParseDuration(strings.TrimPrefix(strings.TrimPrefix(value, "now"), "+")); err == nil {
+			return now.Add(d), nil
+		}
+	}
+	return ParseDateTime(value)
+}
+
+// This function matches the value of a property against the pattern of a pattern operator. Values that are not strings
+// are matched in their string form. A list of strings matches when one of its elements matches.
+func propertyMatchesPattern(p *Property, propexp *PropertyExpression) bool {
+	pattern := removeQuotes(removeSpaces(fmt.Sprintf("%v", propexp.Value)))
+
+	values := []string{fmt.Sprintf("%v", p.Value)}
+	if isString(p.Value) {
+		values = []string{removeSpaces(removeQuotes(p.Value.(string)))}
+		if p.Type == LIST_TYPE {
+			values = strings.Split(values[0], ",")
+		}
+	}
+
+	for _, value := range values {
+		value = removeQuotes(removeSpaces(value))
+		switch propexp.Op {
+		case matches:
+			if re, err := regexp.Compile(pattern); err != nil {
+				return false
+			} else if re.MatchString(value) {
+				return true
+			}
+		case islike:
+			if matched, err := path.Match(pattern, value); err != nil {
+				return false
+			} else if matched {
+				return true
+			}
+		case hasprefix:
+			if strings.HasPrefix(value, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// This function checks that the location in a geo property is within the circle or inside the area of a geo operator.
+// Properties that are not locations do not satisfy a geo operator.
+func propertyInGeoArea(p *Property, propexp *PropertyExpression) bool {
+	if !isString(p.Value) || !isString(propexp.Value) {
+		return false
+	}
+	point, err := geo.ParsePoint(p.Value.(string))
+	if err != nil {
+		return false
+	}
+
+	if propexp.Op == within {
+		if circle, err := geo.ParseCircle(propexp.Value.(string)); err == nil {
+			return circle.Contains(*point)
+		}
+	} else if area, err := geo.ParseArea(propexp.Value.(string)); err == nil {
+		return area.Contains(*point)
+	}
+	return false
+}
+
+// This function describes the result of a geo operator for the location in a geo property, how far the location is
+// from the center of a within operator or whether it is inside the area of an inside operator, so that the
+// compatibility of a node is easier to understand. It returns an empty string for other operators.
+func geoDetail(propexp *PropertyExpression, props *[]Property) string {
+	if _, ok := geoOperators()[propexp.Op]; !ok || !isString(propexp.Value) {
+		return ""
+	}
+	for _, p := range *props {
+		if p.Name != propexp.Name || !isString(p.Value) {
+			continue
+		}
+		point, err := geo.ParsePoint(p.Value.(string))
+		if err != nil {
+			return ""
+		}
+
+		if propexp.Op == within {
+			if circle, err := geo.ParseCircle(propexp.Value.(string)); err == nil {
+				return fmt.Sprintf("%v is %.1fkm from (%v,%v)", p.Name, geo.Distance(*point, circle.Center)/1000, circle.Center.Lat, circle.Center.Lon)
+			}
+		} else if area, err := geo.ParseArea(propexp.Value.(string)); err == nil {
+			inside := ""
+			if !area.Contains(*point) {
+				inside = "not "
+			}
+			if area.Region != "" {
+				return fmt.Sprintf("%v is %vin region %v", p.Name, inside, area.Region)
+			}
+			return fmt.Sprintf("%v (%v,%v) is %vinside the area", p.Name, point.Lat, point.Lon, inside)
+		}
+		return ""
+	}
+	return ""
+}
+
+// This function collects the details of all the geo operators in an expression.
+func geoDetails(cop *map[string]interface{}, props *[]Property) []string {
+	details := []string{}
+	for _, p := range (*cop)[getControlOperator(cop)].([]interface{}) {
+		if prop := isPropertyExpression(p); prop != nil {
+			if detail := geoDetail(prop, props); detail != "" {
+				details = append(details, detail)
+			}
+		} else if cop1 := isControlOp(p); cop1 != nil {
+			details = append(details, geoDetails(cop1, props)...)
+		}
+	}
+	return details
+}
+
+// This function formats geo details to be appended to an error message, empty details are skipped.
+func displayGeoDetails(details []string) string {
+	res := ""
+	for _, detail := range details {
+		if detail != "" {
+			res += fmt.Sprintf(" (%v)", detail)
+		}
+	}
+	return res
+}
+
 func removeSpaces(value string) string {
 	return strings.Trim(value, " ")
 }
@@ -539,24 +819,18 @@ func displayRequiredProperty(cop *map[string]interface{}) string {
 	op_display := ""
 	if controlOp == OP_AND {
 		op_display = " AND "
-	} else if controlOp == OP_OR {
+	} else if controlOp == OP_OR || controlOp == OP_NOT {
 		op_display = ", "
-	} else if controlOp == OP_NOT {
-		op_display = " NOT "
 	}
 
 	propArray := (*cop)[controlOp].([]interface{})
 	display_strings := []string{}
 	for _, p := range propArray {
 		if prop := isPropertyExpression(p); prop != nil {
-			if prop.Op == "" {
-				prop.Op = doubleequalto
-			}
-			s := fmt.Sprintf("%v%v%v", prop.Name, prop.Op, prop.Value)
-			display_strings = append(display_strings, s)
+			display_strings = append(display_strings, displayPropertyExpression(prop))
 		} else if cop1 := isControlOp(p); cop1 != nil {
 			s := displayRequiredProperty(cop1)
-			if controlOp == OP_OR {
+			if controlOp == OP_OR || getControlOperator(cop1) == OP_NOT || (controlOp == OP_NOT && len(propArray) > 1) {
 				display_strings = append(display_strings, fmt.Sprintf("%v", s))
 			} else {
 				display_strings = append(display_strings, fmt.Sprintf("(%v)", s))
@@ -567,9 +841,29 @@ func displayRequiredProperty(cop *map[string]interface{}) string {
 		}
 	}
 
+	if controlOp == OP_NOT {
+		if len(display_strings) == 1 {
+			return "NOT " + display_strings[0]
+		}
+		return fmt.Sprintf("NOT (%v)", strings.Join(display_strings, op_display))
+	}
 	return strings.Join(display_strings, op_display)
 }
 
+// This function displays a property expression in the form it is written in a constraint.
+func displayPropertyExpression(prop *PropertyExpression) string {
+	switch prop.Op {
+	case "":
+		return fmt.Sprintf("%v%v%v", prop.Name, doubleequalto, prop.Value)
+	case isdefined:
+		return fmt.Sprintf("%v %v", prop.Name, prop.Op)
+	case islike, matches, hasprefix, within, inside:
+		return fmt.Sprintf("%v %v %v", prop.Name, prop.Op, prop.Value)
+	default:
+		return fmt.Sprintf("%v%v%v", prop.Name, prop.Op, prop.Value)
+	}
+}
+
 // This fuction displays the a property list to "key1=value1, key1=value2..." format.
 func displayProperties(props *[]Property) string {
 	if props != nil && len(*props) > 0 {
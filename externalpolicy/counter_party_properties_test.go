@@ -136,6 +136,40 @@ func Test_satisfy_simple1(t *testing.T) {
 
 }
 
+// Test that a not expression is satisfied when none of its elements are satisfied.
+func Test_satisfy_not1(t *testing.T) {
+	var rp *RequiredProperty
+	var pa *[]Property
+
+	prop_list := `[{"name":"prop1", "value":"val1"},{"name":"prop2", "value":3}]`
+
+	tests := []struct {
+		rp        string
+		satisfied bool
+	}{
+		{`{"not":[{"name":"prop1", "value":"val2"}]}`, true},
+		{`{"not":[{"name":"prop1", "value":"val1"}]}`, false},
+		{`{"not":[{"name":"prop1", "value":"val2"},{"name":"prop2", "op":">", "value":2}]}`, false},
+		{`{"and":[{"name":"prop2", "value":3},{"not":[{"name":"prop3", "op":"exists", "value":""}]}]}`, true},
+		{`{"not":[{"or":[{"name":"prop1", "op":"^=", "value":"val"},{"name":"prop3", "value":true}]}]}`, false},
+		{`{"not":[{"name":"prop1", "op":"~=", "value":"^x"}]}`, true},
+	}
+
+	if pa = create_property_list(prop_list, t); pa != nil {
+		for _, test := range tests {
+			if rp = create_RP(test.rp, t); rp != nil {
+				if err := rp.IsValid(); err != nil {
+					t.Errorf("Error: %v is a valid RequiredProperty value, but got %v", test.rp, err)
+				} else if err := rp.IsSatisfiedBy(*pa); test.satisfied && err != nil {
+					t.Errorf("Error: %v should be satisfied by %v, but got %v", test.rp, prop_list, err)
+				} else if !test.satisfied && err == nil {
+					t.Errorf("Error: %v should not be satisfied by %v", test.rp, prop_list)
+				}
+			}
+		}
+	}
+}
+
 // Test that simple expressions satisfy a single property value.
 func Test_satisfy_simple2(t *testing.T) {
 	var rp *RequiredProperty
@@ -0,0 +1,221 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The purpose of this package is to parse and evaluate the locations, circles and areas used by geo properties and
+// the geo constraint operators. It is separate from the externalpolicy package so that the constraint language
+// plugins can validate geo values.
+
+// The mean radius of the earth in meters, used to compute the distance between 2 points.
+const EARTH_RADIUS_M = 6371008.8
+
+// The number of meters in each of the distance units that can be used in a circle.
+var distanceUnits = map[string]float64{"m": 1, "km": 1000, "mi": 1609.344}
+
+// A circle: a distance, a unit and the center point, for example 50km of (40.7,-74.0).
+var circleRE = regexp.MustCompile(`^\s*([0-9]+(?:\.[0-9]+)?)\s*(km|mi|m)\s+of\s+\(\s*(-?[0-9]+(?:\.[0-9]*)?)\s*,\s*(-?[0-9]+(?:\.[0-9]*)?)\s*\)\s*$`)
+
+// A location on the earth, in degrees. The region is the optional id of a region that the location is in.
+type Point struct {
+	Lat    float64
+	Lon    float64
+	Region string
+}
+
+func (p Point) String() string {
+	if p.Region != "" {
+		return fmt.Sprintf("%v,%v,%v", p.Lat, p.Lon, p.Region)
+	}
+	return fmt.Sprintf("%v,%v", p.Lat, p.Lon)
+}
+
+// Parse the value of a geo property, the latitude and longitude in degrees, separated by a comma, optionally followed
+// by a comma and a region id. For example 40.7128,-74.0060 or 40.7128,-74.0060,nyc-store-12.
+func ParsePoint(value string) (*Point, error) {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(value), "\""), ",")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, errors.New(fmt.Sprintf("%v is not a location, it should be latitude,longitude or latitude,longitude,region", value))
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, errors.New(fmt.Sprintf("the latitude %v of location %v is not a number from -90 to 90", parts[0], value))
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, errors.New(fmt.Sprintf("the longitude %v of location %v is not a number from -180 to 180", parts[1], value))
+	}
+
+	p := &Point{Lat: lat, Lon: lon}
+	if len(parts) == 3 {
+		if p.Region = strings.TrimSpace(parts[2]); p.Region == "" {
+			return nil, errors.New(fmt.Sprintf("the region of location %v is empty", value))
+		}
+	}
+	return p, nil
+}
+
+// Returns the great circle distance between 2 points in meters.
+func Distance(p1 Point, p2 Point) float64 {
+	toRad := func(d float64) float64 { return d * math.Pi / 180 }
+
+	dLat := toRad(p2.Lat - p1.Lat)
+	dLon := toRad(p2.Lon - p1.Lon)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(p1.Lat))*math.Cos(toRad(p2.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EARTH_RADIUS_M * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// The points within a distance of a center point.
+type Circle struct {
+	Center  Point
+	RadiusM float64 // The radius in meters
+}
+
+// Parse the value of the within operator, a distance with a unit of m, km or mi, followed by of and the center
+// point in parentheses. For example 50km of (40.7,-74.0).
+func ParseCircle(value string) (*Circle, error) {
+	m := circleRE.FindStringSubmatch(value)
+	if m == nil {
+		return nil, errors.New(fmt.Sprintf("%v is not a distance from a location, it should be like 50km of (40.7,-74.0)", strings.TrimSpace(value)))
+	}
+
+	radius, _ := strconv.ParseFloat(m[1], 64)
+	center, err := ParsePoint(m[3] + "," + m[4])
+	if err != nil {
+		return nil, err
+	}
+	return &Circle{Center: *center, RadiusM: radius * distanceUnits[m[2]]}, nil
+}
+
+func (c Circle) Contains(p Point) bool {
+	return Distance(c.Center, p) <= c.RadiusM
+}
+
+// An area is either a set of polygons from GeoJSON, or the id of a region. A polygon is a list of rings of points, the
+// first ring is the outside of the polygon and the other rings are holes in it.
+type Area struct {
+	Polygons [][][]Point
+	Region   string
+}
+
+// Parse the value of the inside operator, either a GeoJSON Polygon or MultiPolygon geometry, or a Feature with one of
+// those geometries, or a region id. GeoJSON positions are longitude first.
+func ParseArea(value string) (*Area, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "{") {
+		region := strings.Trim(value, "\"")
+		if region == "" {
+			return nil, errors.New("the region id is empty")
+		}
+		return &Area{Region: region}, nil
+	}
+
+	var geoJSON struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	}
+	if err := json.Unmarshal([]byte(value), &geoJSON); err != nil {
+		return nil, errors.New(fmt.Sprintf("%v is not valid GeoJSON: %v", value, err))
+	}
+
+	geoType, coordinates := geoJSON.Type, geoJSON.Coordinates
+	if geoType == "Feature" {
+		if geoJSON.Geometry == nil {
+			return nil, errors.New(fmt.Sprintf("the GeoJSON feature %v does not have a geometry", value))
+		}
+		geoType, coordinates = geoJSON.Geometry.Type, geoJSON.Geometry.Coordinates
+	}
+
+	var polygons [][][][]float64
+	switch geoType {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(coordinates, &polygon); err != nil {
+			return nil, errors.New(fmt.Sprintf("the coordinates of GeoJSON polygon %v are not valid: %v", value, err))
+		}
+		polygons = append(polygons, polygon)
+	case "MultiPolygon":
+		if err := json.Unmarshal(coordinates, &polygons); err != nil {
+			return nil, errors.New(fmt.Sprintf("the coordinates of GeoJSON multipolygon %v are not valid: %v", value, err))
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("the GeoJSON type %v is not supported, it should be Polygon, MultiPolygon or a Feature with one of them", geoType))
+	}
+
+	area := &Area{Polygons: make([][][]Point, 0, len(polygons))}
+	for _, polygon := range polygons {
+		rings := make([][]Point, 0, len(polygon))
+		for _, ring := range polygon {
+			if len(ring) < 4 {
+				return nil, errors.New(fmt.Sprintf("a ring of GeoJSON polygon %v has fewer than 4 positions", value))
+			}
+			points := make([]Point, 0, len(ring))
+			for _, position := range ring {
+				if len(position) < 2 {
+					return nil, errors.New(fmt.Sprintf("the GeoJSON position %v is not longitude,latitude", position))
+				}
+				p, err := ParsePoint(fmt.Sprintf("%v,%v", position[1], position[0]))
+				if err != nil {
+					return nil, err
+				}
+				points = append(points, *p)
+			}
+			rings = append(rings, points)
+		}
+		if len(rings) == 0 {
+			return nil, errors.New(fmt.Sprintf("the GeoJSON polygon %v does not have any positions", value))
+		}
+		area.Polygons = append(area.Polygons, rings)
+	}
+	return area, nil
+}
+
+// Returns true if the point is inside one of the polygons of the area and not in one of its holes, or if the point's
+// region is the area's region. The edges of the polygons are straight lines of latitude and longitude, which is close
+// enough for areas the size of a city or a region, but polygons that cross the 180th meridian are not supported.
+func (a Area) Contains(p Point) bool {
+	if a.Region != "" {
+		return p.Region == a.Region
+	}
+
+	for _, rings := range a.Polygons {
+		if !ringContains(rings[0], p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range rings[1:] {
+			if ringContains(hole, p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the point is inside the ring, counting the edges crossed by a line from the point.
+func ringContains(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) && p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,114 @@
+// +build unit
+
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_ParsePoint(t *testing.T) {
+	tests := []struct {
+		value string
+		point *Point
+	}{
+		{"40.7128,-74.0060", &Point{Lat: 40.7128, Lon: -74.006}},
+		{" 40.7128 , -74.0060 , nyc-store-12 ", &Point{Lat: 40.7128, Lon: -74.006, Region: "nyc-store-12"}},
+		{"-90,180", &Point{Lat: -90, Lon: 180}},
+		{"91,0", nil},
+		{"0,-181", nil},
+		{"40.7", nil},
+		{"north,west", nil},
+		{"40.7,-74.0,", nil},
+		{"1,2,3,4", nil},
+	}
+
+	for _, test := range tests {
+		p, err := ParsePoint(test.value)
+		if test.point == nil && err == nil {
+			t.Errorf("%v should not parse as a location, got %v", test.value, p)
+		} else if test.point != nil && err != nil {
+			t.Errorf("%v should parse as a location, got error %v", test.value, err)
+		} else if test.point != nil && *p != *test.point {
+			t.Errorf("%v should parse as %v, got %v", test.value, test.point, p)
+		}
+	}
+}
+
+func Test_Circle(t *testing.T) {
+	newYork := Point{Lat: 40.7128, Lon: -74.006}
+	newark := Point{Lat: 40.7357, Lon: -74.1724}
+	philadelphia := Point{Lat: 39.9526, Lon: -75.1652}
+
+	// New York to Philadelphia is about 130km.
+	if d := Distance(newYork, philadelphia); math.Abs(d-129600) > 1000 {
+		t.Errorf("unexpected distance from New York to Philadelphia %v", d)
+	}
+
+	tests := []struct {
+		value    string
+		point    Point
+		contains bool
+	}{
+		{"50km of (40.7128,-74.0060)", newark, true},
+		{"50km of (40.7128,-74.0060)", philadelphia, false},
+		{"100 mi of ( 40.7128 , -74.0060 )", philadelphia, true},
+		{"10m of (40.7128,-74.006)", newYork, true},
+		{"0.5km of (40.7357,-74.1724)", newYork, false},
+	}
+	for _, test := range tests {
+		if c, err := ParseCircle(test.value); err != nil {
+			t.Errorf("%v should parse as a circle, got error %v", test.value, err)
+		} else if c.Contains(test.point) != test.contains {
+			t.Errorf("%v contains %v should be %v", test.value, test.point, test.contains)
+		}
+	}
+
+	for _, bad := range []string{"50 of (40.7,-74.0)", "50km (40.7,-74.0)", "50km of (95,-74.0)", "-5km of (40.7,-74.0)", "50ft of (40.7,-74.0)"} {
+		if _, err := ParseCircle(bad); err == nil {
+			t.Errorf("%v should not parse as a circle", bad)
+		}
+	}
+}
+
+func Test_Area(t *testing.T) {
+	// A square around lower Manhattan with a hole around the Battery.
+	polygon := `{"type":"Polygon","coordinates":[[[-74.05,40.68],[-73.95,40.68],[-73.95,40.75],[-74.05,40.75],[-74.05,40.68]],[[-74.03,40.69],[-74.0,40.69],[-74.0,40.705],[-74.03,40.705],[-74.03,40.69]]]}`
+	feature := `{"type":"Feature","properties":{"name":"Philadelphia"},"geometry":{"type":"MultiPolygon","coordinates":[[[[-75.3,39.85],[-75.0,39.85],[-75.0,40.1],[-75.3,40.1],[-75.3,39.85]]]]}}`
+
+	tests := []struct {
+		area     string
+		point    Point
+		contains bool
+	}{
+		{polygon, Point{Lat: 40.7128, Lon: -74.006}, true},
+		{polygon, Point{Lat: 40.7, Lon: -74.015}, false},
+		{polygon, Point{Lat: 40.8, Lon: -74.0}, false},
+		{feature, Point{Lat: 39.9526, Lon: -75.1652}, true},
+		{feature, Point{Lat: 40.7128, Lon: -74.006}, false},
+		{"nyc-1", Point{Lat: 0, Lon: 0, Region: "nyc-1"}, true},
+		{"\"nyc-1\"", Point{Lat: 0, Lon: 0, Region: "nyc-1"}, true},
+		{"nyc-1", Point{Lat: 40.7128, Lon: -74.006}, false},
+	}
+	for _, test := range tests {
+		if a, err := ParseArea(test.area); err != nil {
+			t.Errorf("%v should parse as an area, got error %v", test.area, err)
+		} else if a.Contains(test.point) != test.contains {
+			t.Errorf("%v contains %v should be %v", test.area, test.point, test.contains)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"type":"Point","coordinates":[-74.0,40.7]}`,
+		`{"type":"Polygon","coordinates":[[[-74.0,40.7],[-73.9,40.7],[-74.0,40.7]]]}`,
+		`{"type":"Polygon","coordinates":[[[-74.0,95],[-73.9,40.7],[-73.9,40.8],[-74.0,95]]]}`,
+		`{"type":"Polygon","coordinates":"none"}`,
+		`{"type":"Feature"}`,
+		`{"type":`,
+		`""`,
+	} {
+		if _, err := ParseArea(bad); err == nil {
+			t.Errorf("%v should not parse as an area", bad)
+		}
+	}
+}
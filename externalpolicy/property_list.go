@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/open-horizon/anax/externalpolicy/geo"
 	"github.com/open-horizon/anax/i18n"
 	"strings"
+	"time"
 )
 
 // The purpose of this file is to abstract the Property type and its List type.
@@ -17,9 +19,15 @@ const (
 	INTEGER_TYPE    = "int"
 	FLOAT_TYPE      = "float"
 	LIST_TYPE       = "list of strings"
+	GEO_TYPE        = "geo"
+	MAP_TYPE        = "map"
+	DATETIME_TYPE   = "datetime"
 	UNDECLARED_TYPE = ""
 )
 
+// The date only form of a datetime property value. The other form is RFC3339.
+const DATE_FORMAT = "2006-01-02"
+
 // This struct represents property values advertised by the policy
 type PropertyList []Property
 
@@ -49,6 +57,12 @@ func (p Property) IsSame(compare Property) bool {
 		switch p.Value.(type) {
 		case string:
 			if _, ok := compare.Value.(string); ok {
+				if p.Type == GEO_TYPE || compare.Type == GEO_TYPE {
+					return isSameLocation(p.Value.(string), compare.Value.(string))
+				}
+				if p.Type == DATETIME_TYPE || compare.Type == DATETIME_TYPE {
+					return isSameDateTime(p.Value.(string), compare.Value.(string))
+				}
 				if p.Type == LIST_TYPE || compare.Type == LIST_TYPE {
 					return isSameList(strings.Split(p.Value.(string), ","), strings.Split(compare.Value.(string), ","))
 				}
@@ -64,6 +78,10 @@ func (p Property) IsSame(compare Property) bool {
 			if _, ok := compare.Value.(bool); ok {
 				return p.Value == compare.Value
 			}
+		case map[string]interface{}:
+			if _, ok := compare.Value.(map[string]interface{}); ok {
+				return isSameMap(p.Value, compare.Value)
+			}
 		}
 
 	}
@@ -98,6 +116,71 @@ func isSameList(list1 []string, list2 []string) bool {
 	return true
 }
 
+// This function will return true if both strings are the same location, even if they are written differently.
+func isSameLocation(loc1 string, loc2 string) bool {
+	p1, err1 := geo.ParsePoint(loc1)
+	p2, err2 := geo.ParsePoint(loc2)
+	if err1 != nil || err2 != nil {
+		return loc1 == loc2
+	}
+	return *p1 == *p2
+}
+
+// This function will return true if both strings are the same point in time, even if they are written differently.
+func isSameDateTime(dt1 string, dt2 string) bool {
+	t1, err1 := ParseDateTime(dt1)
+	t2, err2 := ParseDateTime(dt2)
+	if err1 != nil || err2 != nil {
+		return dt1 == dt2
+	}
+	return t1.Equal(t2)
+}
+
+// This function will return true if both maps have the same keys and values. The maps are compared in their json form
+// so that numbers parsed as float64 and as json.Number are the same.
+func isSameMap(map1 interface{}, map2 interface{}) bool {
+	b1, err1 := json.Marshal(map1)
+	b2, err2 := json.Marshal(map2)
+	return err1 == nil && err2 == nil && string(b1) == string(b2)
+}
+
+// Parse the value of a datetime property or a datetime in a constraint, either an RFC3339 time or a date. A date is
+// the start of that day in UTC.
+func ParseDateTime(value string) (time.Time, error) {
+	value = strings.Trim(strings.TrimSpace(value), "\"")
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	} else if t, err := time.Parse(DATE_FORMAT, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("%v is not a datetime, it should be an RFC3339 time like 2027-01-01T00:00:00Z or a date like 2027-01-01", value))
+}
+
+// This function checks the value of a map property. The keys cannot be empty or contain a dot because dots separate the
+// keys in the name of a map value in a constraint. The values can be strings, numbers, booleans, lists and other maps.
+func validateMapValue(value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			if key == "" || strings.Contains(key, ".") {
+				return errors.New(fmt.Sprintf("the key \"%v\" is not valid, keys cannot be empty or contain a dot", key))
+			} else if err := validateMapValue(elem); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			if err := validateMapValue(elem); err != nil {
+				return err
+			}
+		}
+	case string, bool, float64, json.Number:
+	default:
+		return errors.New(fmt.Sprintf("the value %v has unsupported type %T", value, value))
+	}
+	return nil
+}
+
 func (p PropertyList) ShortString() string {
 	psa := []string{}
 	for _, prop := range p {
@@ -211,7 +294,7 @@ func (self *PropertyList) Validate() error {
 		declaredType := property.Type
 
 		if !isValidPropertyType(declaredType) {
-			return fmt.Errorf(msgPrinter.Sprintf("Property %s has invalid property type %s. Allowed property types are: version, string, int, boolean, float, and list of strings.", property.Name, declaredType))
+			return fmt.Errorf(msgPrinter.Sprintf("Property %s has invalid property type %s. Allowed property types are: version, string, int, boolean, float, list of strings, geo, map, and datetime.", property.Name, declaredType))
 		}
 
 		switch actualType := property.Value.(type) {
@@ -242,9 +325,23 @@ func (self *PropertyList) Validate() error {
 				if !IsVersionString(stringVal) {
 					return fmt.Errorf(msgPrinter.Sprintf("Property %s with value %v is not a valid verion string", property.Name, property.Value))
 				}
+			} else if declaredType == GEO_TYPE {
+				if _, err := geo.ParsePoint(stringVal); err != nil {
+					return fmt.Errorf(msgPrinter.Sprintf("Property %s with value %v is not a valid location: %v", property.Name, property.Value, err))
+				}
+			} else if declaredType == DATETIME_TYPE {
+				if _, err := ParseDateTime(stringVal); err != nil {
+					return fmt.Errorf(msgPrinter.Sprintf("Property %s with value %v is not a valid datetime: %v", property.Name, property.Value, err))
+				}
 			} else if declaredType != STRING_TYPE && declaredType != UNDECLARED_TYPE && declaredType != LIST_TYPE {
 				return fmt.Errorf(msgPrinter.Sprintf("Property value is of type %T, expected type %s", actualType, declaredType))
 			}
+		case map[string]interface{}:
+			if declaredType != MAP_TYPE && declaredType != UNDECLARED_TYPE {
+				return fmt.Errorf(msgPrinter.Sprintf("Property value is of type %T, expected type %s", actualType, declaredType))
+			} else if err := validateMapValue(property.Value); err != nil {
+				return fmt.Errorf(msgPrinter.Sprintf("Property %s with value %v is not a valid map: %v", property.Name, property.Value, err))
+			}
 		default:
 			return fmt.Errorf(msgPrinter.Sprintf("Property %s has invalid value type %T", property.Name, actualType))
 		}
@@ -281,7 +378,7 @@ func IsVersionString(expr string) bool {
 }
 
 func isValidPropertyType(typeInput string) bool {
-	validTypes := []string{STRING_TYPE, VERSION_TYPE, BOOLEAN_TYPE, INTEGER_TYPE, FLOAT_TYPE, LIST_TYPE, UNDECLARED_TYPE}
+	validTypes := []string{STRING_TYPE, VERSION_TYPE, BOOLEAN_TYPE, INTEGER_TYPE, FLOAT_TYPE, LIST_TYPE, GEO_TYPE, MAP_TYPE, DATETIME_TYPE, UNDECLARED_TYPE}
 	for _, validType := range validTypes {
 		if validType == typeInput {
 			return true
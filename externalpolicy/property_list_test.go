@@ -95,6 +95,47 @@ func Test_PropertyList_compatible(t *testing.T) {
 }
 
 // Second, some tests where the lists are incompatible
+// Locations are compared by their coordinates, not by how they are written.
+func Test_PropertyList_compatible_geo(t *testing.T) {
+	p1 := `[{"name":"location","value":"40.7128,-74.0060","type":"geo"}]`
+	p2 := `[{"name":"location","value":" 40.71280, -74.006 ","type":"geo"}]`
+	p3 := `[{"name":"location","value":"40.7128,-74.0060,nyc-1","type":"geo"}]`
+	if pl1, pl2, pl3 := create_PropertyList(p1, t), create_PropertyList(p2, t), create_PropertyList(p3, t); pl1 != nil && pl2 != nil && pl3 != nil {
+		if err := pl1.Compatible_With(pl2, false); err != nil {
+			t.Errorf("Error: %v is compatible with %v, error was %v\n", p1, p2, err)
+		}
+		if err := pl1.Compatible_With(pl3, false); err == nil {
+			t.Errorf("Error: %v is not compatible with %v\n", p1, p3)
+		}
+	}
+}
+
+// Maps are compared by their keys and values, and datetimes by the time they represent.
+func Test_PropertyList_compatible_map_datetime(t *testing.T) {
+	p1 := `[{"name":"camera","value":{"model":"x100","mount":{"height":2.5}},"type":"map"},{"name":"expires","value":"2027-01-01","type":"datetime"}]`
+	p2 := `[{"name":"camera","value":{"mount":{"height":2.5},"model":"x100"}},{"name":"expires","value":"2027-01-01T00:00:00Z","type":"datetime"}]`
+	p3 := `[{"name":"camera","value":{"model":"x100","mount":{"height":3}},"type":"map"}]`
+	p4 := `[{"name":"expires","value":"2027-01-01T00:00:00+01:00","type":"datetime"}]`
+	if pl1, pl2, pl3, pl4 := create_PropertyList(p1, t), create_PropertyList(p2, t), create_PropertyList(p3, t), create_PropertyList(p4, t); pl1 != nil && pl2 != nil && pl3 != nil && pl4 != nil {
+		if err := pl1.Compatible_With(pl2, false); err != nil {
+			t.Errorf("Error: %v is compatible with %v, error was %v\n", p1, p2, err)
+		}
+		if err := pl1.Compatible_With(pl3, false); err == nil {
+			t.Errorf("Error: %v is not compatible with %v\n", p1, p3)
+		}
+		if err := pl1.Compatible_With(pl4, false); err == nil {
+			t.Errorf("Error: %v is not compatible with %v\n", p1, p4)
+		}
+	}
+
+	// A map parsed with json numbers is the same as one parsed with floats.
+	if pl1, pl2 := create_PropertyList_UseNumbers(p1, t), create_PropertyList(p1, t); pl1 != nil && pl2 != nil {
+		if err := pl1.Compatible_With(pl2, false); err != nil {
+			t.Errorf("Error: %v is compatible with itself, error was %v\n", p1, err)
+		}
+	}
+}
+
 func Test_PropertyList_incompatible(t *testing.T) {
 	var pl1 *PropertyList
 	var pl2 *PropertyList
@@ -182,6 +223,20 @@ func Test_Validate_valid(t *testing.T) {
 			t.Errorf("Error: %v has invalid properties but gave no error\n", p1)
 		}
 	}
+
+	p1 = `[{"name":"location","value":"40.7128,-74.0060","type":"geo"},{"name":"store","value":"-33.8688, 151.2093, syd-3","type":"geo"}]`
+	if pl1 := create_PropertyList(p1, t); pl1 != nil {
+		if err := pl1.Validate(); err != nil {
+			t.Errorf("Error: %v has only valid properties but gave error: %v\n", p1, err)
+		}
+	}
+
+	p1 = `[{"name":"camera","value":{"model":"x100","resolution":1080,"ir":true,"lenses":["wide","zoom"],"mount":{"height":2.5}},"type":"map"},{"name":"sensor","value":{"model":"t1"}},{"name":"expires","value":"2027-01-01","type":"datetime"},{"name":"installed","value":"2024-03-15T08:30:00-05:00","type":"datetime"}]`
+	if pl1 := create_PropertyList(p1, t); pl1 != nil {
+		if err := pl1.Validate(); err != nil {
+			t.Errorf("Error: %v has only valid properties but gave error: %v\n", p1, err)
+		}
+	}
 }
 
 //Test the property validation with invalid properties
@@ -238,6 +293,27 @@ func Test_Validate_invalid(t *testing.T) {
 			t.Errorf("Error: %v has invalid properties but gave no error\n", p1)
 		}
 	}
+	for _, p1 = range []string{
+		`[{"name":"location","value":"40.7128","type":"geo"}]`,
+		`[{"name":"location","value":"140.7128,-74.0060","type":"geo"}]`,
+		`[{"name":"location","value":"40.7128,-74.0060,","type":"geo"}]`,
+		`[{"name":"location","value":40.7128,"type":"geo"}]`,
+		`[{"name":"location","value":"new york","type":"geo"}]`,
+		`[{"name":"camera","value":"x100","type":"map"}]`,
+		`[{"name":"camera","value":{"model":"x100"},"type":"string"}]`,
+		`[{"name":"camera","value":{"lens.type":"wide"},"type":"map"}]`,
+		`[{"name":"camera","value":{"":"wide"},"type":"map"}]`,
+		`[{"name":"camera","value":{"model":null},"type":"map"}]`,
+		`[{"name":"expires","value":"01/01/2027","type":"datetime"}]`,
+		`[{"name":"expires","value":"2027-01-01T00:00:00","type":"datetime"}]`,
+		`[{"name":"expires","value":1798761600,"type":"datetime"}]`,
+	} {
+		if pl1 := create_PropertyList(p1, t); pl1 != nil {
+			if err := pl1.Validate(); err == nil {
+				t.Errorf("Error: %v has invalid properties but gave no error\n", p1)
+			}
+		}
+	}
 }
 
 func Test_add_property(t *testing.T) {
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"github.com/alecthomas/participle/lexer"
 	"github.com/alecthomas/participle/lexer/ebnf"
+	"github.com/open-horizon/anax/externalpolicy/geo"
 	"github.com/open-horizon/anax/externalpolicy/plugin_registry"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/semanticversion"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -61,7 +65,9 @@ func (p *TextConstraintLanguagePlugin) Validate(dconstraints interface{}) (bool,
 					return false, nil, fmt.Errorf("Error finding a control operator in %s. Error was: %v", fullConstr, err)
 				}
 
-				if ctrlOp == ")" {
+				if ctrlOp == "NOT" && exp != "" {
+					return false, nil, fmt.Errorf("Error finding a control operator in %s. Error was: NOT must be preceded by one of AND,&&,OR,||", fullConstr)
+				} else if ctrlOp == ")" {
 					parenCount--
 				} else if ctrlOp == "(" {
 					parenCount++
@@ -114,7 +120,12 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 		}
 
 		nextRune = nextToken.Type
-		if nextRune != def["OpEq"] && nextRune != def["OpComp"] && nextRune != def["OpIn"] {
+		if nextRune == def["OpExists"] {
+			// The existence test does not have a value.
+			op = nextToken.Value
+			return fmt.Sprintf("%v\a%v\a", name, strings.TrimSpace(op)), strings.Replace(expression, fmt.Sprintf("%v%v", name, op), "", 1), nil
+		}
+		if !isComparisonOperator(nextRune, def) {
 			if len(name) > 3 && name[len(name)-2:] == "in" {
 				op = "in"
 				opType = def["in"]
@@ -135,9 +146,21 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 			nextRune = nextToken.Type
 		}
 
-		if nextRune != def["Str"] && nextRune != def["InStr"] && nextRune != def["QuoteStr"] && nextRune != def["ListStr"] && nextRune != def["Vers"] && nextRune != def["VersRange"] && nextRune != def["Num"] {
+		// The words used as operators are valid string values.
+		if nextRune == def["NotOp"] {
+			nextToken.Value = strings.TrimRight(nextToken.Value, " \t\r\n")
+			nextRune = def["Str"]
+		}
+
+		if nextRune != def["DateTime"] && nextRune != def["GeoCircle"] && nextRune != def["GeoJSON"] && nextRune != def["PatternStr"] && nextRune != def["Str"] && nextRune != def["InStr"] && nextRune != def["QuoteStr"] && nextRune != def["ListStr"] && nextRune != def["Vers"] && nextRune != def["VersRange"] && nextRune != def["Num"] {
 			return "", expression, fmt.Errorf("Invalid property value. %v%v%v", name, op, nextToken.Value)
 		}
+		// The lexer only finds the opening brace of a GeoJSON object, the rest of the object is found by counting braces.
+		if nextRune == def["GeoJSON"] {
+			if nextToken.Value, err = geoJSONObject(expression[nextToken.Pos.Offset:]); err != nil {
+				return "", expression, err
+			}
+		}
 		if val == "" {
 			val = nextToken.Value
 			valType = nextRune
@@ -147,7 +170,7 @@ func (p *TextConstraintLanguagePlugin) GetNextExpression(expression string) (str
 		}
 		return fmt.Sprintf("%v\a%v\a%v", name, strings.TrimSpace(op), strings.TrimSpace(val)), strings.Replace(expression, fmt.Sprintf(("%v%v%v"), name, op, val), "", 1), nil
 	}
-	if nextRune == def["OpenParen"] || nextRune == def["CloseParen"] {
+	if nextRune == def["OpenParen"] || nextRune == def["CloseParen"] || nextRune == def["NotOp"] {
 		return "", expression, nil
 	}
 	return "", expression, fmt.Errorf("Next expression not found: %v", expression)
@@ -180,19 +203,40 @@ func (p *TextConstraintLanguagePlugin) GetNextOperator(expression string) (strin
 	// Append the next element to the correct array depending on the proceeding control operator
 	// For AND: append the next element to the andArray and continue
 
-	if nextRune == def["AndOp"] || nextRune == def["OrOp"] || nextRune == def["OpenParen"] {
+	if nextRune == def["AndOp"] || nextRune == def["OrOp"] || nextRune == def["OpenParen"] || nextRune == def["NotOp"] {
 		op := nextToken.Value
 		return strings.TrimSpace(op), strings.Replace(expression, op, "", 1), nil
 	}
 	return "", expression, fmt.Errorf("No control operator found. Expecting one of AND,&&,OR,||. Found: %v", expression)
 }
 
+// Returns true for the operators that are followed by a value.
+func isComparisonOperator(opType rune, lexMap map[string]rune) bool {
+	for _, op := range []string{"OpEq", "OpComp", "OpIn", "OpMatch", "OpLike", "OpPrefix", "OpWithin", "OpInside"} {
+		if lexMap[op] == opType {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true for the operators that match a string value against a pattern.
+func isPatternOperator(opType rune, lexMap map[string]rune) bool {
+	return opType == lexMap["OpMatch"] || opType == lexMap["OpLike"] || opType == lexMap["OpPrefix"]
+}
+
 // 1. == is supported for all types except list of strings, which would use 'in'.
 // 2. for numeric types, the operators ==, <, >, <=, >= are supported
 // 3. false and true are the only valid values for a boolean type
 // 4. for string types, a quoted string, inside which is a list of comma separated strings provide acceptable values
 // 5. string values that contain spaces must be quoted
 // 6. for the version type, supported values are a single version or a range of versions in the semantic version format (the same as used for service verions). The == operator implies that the value is a single version. The 'in' operator treats the value as a version range. As with service versions, the version 1.0.0 when treated as a version range is equivalent to the explicit range [1.0.0,INFINITY).
+// 7. the ~= (regular expression), like (glob) and ^= (prefix) operators match the value of any property type as a string. Patterns that contain characters other than those allowed in strings must be quoted.
+// 8. the exists operator does not have a value, it tests that the property is defined.
+// 9. NOT negates the property expression or parenthesized expression that follows it.
+// 10. the within and inside operators are used with geo properties. within takes a distance from a location, for example 50km of (40.7,-74.0). inside takes a GeoJSON polygon or a region id.
+// 11. for the datetime type, the operators ==, !=, <, >, <=, >= compare the property with an RFC3339 time, a date, or now with an optional duration, for example now+720h.
+// 12. a property name with dots refers to a value inside a map property, for example camera.model.
 
 // This function checks that the operator is valid for the specified value and validates version ranges with the semanticversion Factory function
 // Returns a property expression struct with numerical values as float64
@@ -208,11 +252,56 @@ func validOpValuePair(name string, op string, opType rune, val interface{}, valT
 			return fmt.Errorf("Property type list of strings can only use operator 'in'.")
 		}
 	}
-	if lexMap["OpComp"] == opType {
+	if lexMap["DateTime"] == valType {
+		if lexMap["OpEq"] != opType && lexMap["OpComp"] != opType {
+			return fmt.Errorf("The datetime %v can only be used with the operators ==, !=, <, >, <= and >=.", strings.TrimSpace(val.(string)))
+		} else if !isDateTime(val.(string)) {
+			return fmt.Errorf("%v is not a valid datetime.", strings.TrimSpace(val.(string)))
+		}
+		return nil
+	}
+	if lexMap["OpComp"] == opType && !isRelativeTime(val.(string)) {
 		if _, err := strconv.ParseFloat(val.(string), 64); err != nil {
 			return fmt.Errorf("Cannot use numerical comparison operator %s with value %v.", op, val)
 		}
 	}
+	if lexMap["OpWithin"] == opType {
+		if lexMap["GeoCircle"] != valType {
+			return fmt.Errorf("The 'within' operator must be followed by a distance from a location, for example 50km of (40.7,-74.0).")
+		} else if _, err := geo.ParseCircle(val.(string)); err != nil {
+			return err
+		}
+		return nil
+	}
+	if lexMap["OpInside"] == opType {
+		if lexMap["GeoJSON"] != valType && lexMap["Str"] != valType && lexMap["InStr"] != valType && lexMap["QuoteStr"] != valType {
+			return fmt.Errorf("The 'inside' operator must be followed by a GeoJSON polygon or a region id.")
+		} else if _, err := geo.ParseArea(val.(string)); err != nil {
+			return err
+		}
+		return nil
+	}
+	if lexMap["GeoCircle"] == valType || lexMap["GeoJSON"] == valType {
+		return fmt.Errorf("The value %v can only be used with the operators within and inside.", val)
+	}
+	if lexMap["PatternStr"] == valType && !isPatternOperator(opType, lexMap) {
+		return fmt.Errorf("The value %v can only be used with the operators ~=, like and ^=.", val)
+	}
+	if isPatternOperator(opType, lexMap) {
+		if lexMap["VersRange"] == valType || lexMap["ListStr"] == valType {
+			return fmt.Errorf("Cannot use pattern operator %s with value %v.", strings.TrimSpace(op), val)
+		}
+		pattern := strings.Trim(strings.TrimSpace(val.(string)), "\x22")
+		if lexMap["OpMatch"] == opType {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("Invalid regular expression %v: %v", pattern, err)
+			}
+		} else if lexMap["OpLike"] == opType {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("Invalid pattern %v: %v", pattern, err)
+			}
+		}
+	}
 	if lexMap["OpIn"] == opType {
 		if lexMap["ListStr"] != valType && lexMap["QuoteStr"] != valType && lexMap["VersRange"] != valType && lexMap["Vers"] != valType {
 			return fmt.Errorf("The 'in' operator can only be used for types version and list of strings")
@@ -229,6 +318,28 @@ func validOpValuePair(name string, op string, opType rune, val interface{}, valT
 	return nil
 }
 
+// Returns true if the value is an RFC3339 time or a date.
+func isDateTime(val string) bool {
+	val = strings.TrimSpace(val)
+	if _, err := time.Parse(time.RFC3339, val); err == nil {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", val)
+	return err == nil
+}
+
+// Returns true if the value is now, optionally followed by a duration to add or subtract, like now+720h or now-24h.
+func isRelativeTime(val string) bool {
+	val = strings.TrimSpace(val)
+	if val == "now" {
+		return true
+	} else if !strings.HasPrefix(val, "now+") && !strings.HasPrefix(val, "now-") {
+		return false
+	}
+	_, err := time.ParseDuration(strings.TrimPrefix(val[3:], "+"))
+	return err == nil
+}
+
 func getLexer() lexer.Definition {
 	return lexer.Must(ebnf.New(`
 	  alphanumeric = digit | alpha .
@@ -243,8 +354,20 @@ func getLexer() lexer.Definition {
 	  OrOp = whitespace {whitespace} ("OR" | "||") whitespace {whitespace} .
 
 		OpComp =  {whitespace} ( ["="] (">" | "<") ["="] ) {whitespace} .
+		OpInside = whitespace {whitespace} "inside" whitespace {whitespace} .
 		OpIn =  {whitespace} "in" {whitespace} .
 	  OpEq =  {whitespace}  ( "!=" | "="["="] )  {whitespace} .
+	  OpMatch = {whitespace} "~=" {whitespace} .
+	  OpPrefix = {whitespace} "^=" {whitespace} .
+	  OpLike = whitespace {whitespace} "like" whitespace {whitespace} .
+	  OpExists = whitespace {whitespace} "exists" .
+	  NotOp = {whitespace} "NOT" whitespace {whitespace} .
+	  OpWithin = whitespace {whitespace} "within" whitespace {whitespace} .
+
+	  DateTime = {whitespace} digit digit digit digit "-" digit digit "-" digit digit ["T" digit digit ":" digit digit ":" digit digit ["." digit {digit}] ("Z" | ("+" | "-") digit digit ":" digit digit)] .
+	  GeoCircle = {whitespace} digit {digit} ["." digit {digit}] {whitespace} ("km" | "m" ["i"]) whitespace {whitespace} "of" {whitespace} "(" {whitespace} coord {whitespace} "," {whitespace} coord {whitespace} ")" .
+	  coord = ["-"] digit {digit} ["." {digit}] .
+	  GeoJSON = {whitespace} "{" .
 
 	  VersRange = {whitespace}  ( "(" | "[" )  vers {whitespace}  "," {whitespace}  (vers | "INFINITY")  ("]" | ")").
 		Vers = {whitespace}  vers .
@@ -257,11 +380,42 @@ func getLexer() lexer.Definition {
 	  Str =  {whitespace} (alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | ".") {alphanumeric | "_" | "-" | "/" | "!" | "?" | "+" | "~" | "'" | "."} .
 	  QuoteStr = {whitespace} "\x22" (alphanumeric  | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | " " | "\t") {alphanumeric | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | " " | "\t" } "\x22" .
 		ListStr = {whitespace} "\x22" (alphanumeric  | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | "," | " " | "\t") {alphanumeric | "_" | "-" |  "/" | "!" | "?" | "+" | "~" | "." | "'" | "," | " " | "\t" } "\x22" .
+	  PatternStr = {whitespace} "\x22" patternchar {patternchar} "\x22" .
+	  patternchar = " "…"~"-"\x22" | "\t" .
 
 
 	  Unused = digit .`))
 }
 
+// This function returns the GeoJSON object at the start of the expression, including any leading whitespace. The end of
+// the object is the brace that closes the first one, braces inside JSON strings are not counted.
+func geoJSONObject(expression string) (string, error) {
+	depth := 0
+	inString := false
+	escaped := false
+	for ix, c := range expression {
+		if inString {
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		} else if c == '"' {
+			inString = true
+		} else if c == '{' {
+			depth++
+		} else if c == '}' {
+			depth--
+			if depth == 0 {
+				return expression[:ix+1], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("The GeoJSON object %v is not closed.", strings.TrimSpace(expression))
+}
+
 func isConstraintExpression(x interface{}) bool {
 	switch x.(type) {
 	case []string:
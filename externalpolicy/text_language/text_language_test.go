@@ -340,3 +340,102 @@ func Test_GetNextExpression_Succeed(t *testing.T) {
 	}
 
 }
+
+func Test_Validate_NotExistsPatterns(t *testing.T) {
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+
+	tests := []struct {
+		constraint string
+		valid      bool
+	}{
+		{"NOT zone == lab", true},
+		{"NOT (zone == lab)", true},
+		{"NOT (zone == lab || zone == test) && cpu > 2", true},
+		{"cpu > 2 AND NOT zone == lab", true},
+		{"NOT NOT zone == lab", true},
+		{"(NOT zone == lab)", true},
+		{"gpu exists", true},
+		{"gpu exists && NOT tpu exists", true},
+		{"hostname ~= \"store-[0-9]+\"", true},
+		{"hostname ~= store-1", true},
+		{"hostname ~= \"^store-(east|west)-\\d{2}$\" OR hostname == test", true},
+		{"hostname like \"store-*\"", true},
+		{"hostname like store-1?", true},
+		{"location ^= us-east", true},
+		{"location ^= \"us-east\" && NOT location == us-east-2", true},
+		{"zone == NOT AND cpu == 2", true},
+		{"zone == lab NOT cpu == 2", false},
+		{"hostname ~= \"store-[0-9\"", false},
+		{"hostname like \"store-[\"", false},
+		{"hostname == \"store-[0-9]+\"", false},
+		{"location ^= [1.0.0,2.0.0]", false},
+		{"gpu exists 3", false},
+		{"location within 50km of (40.7,-74.0)", true},
+		{"location within 2.5 mi of ( -33.87 , 151.21 ) && zone == lab", true},
+		{"location inside {\"type\":\"Polygon\",\"coordinates\":[[[-74.3,40.5],[-73.7,40.5],[-73.7,40.95],[-74.3,40.5]]]}", true},
+		{"location inside {\"type\":\"Feature\",\"properties\":{\"name\":\"nyc\"},\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[-74.3,40.5],[-73.7,40.5],[-73.7,40.95],[-74.3,40.5]]]}} OR zone == lab", true},
+		{"location inside {\"type\":\"Feature\",\"properties\":{\"name\":\"nyc {1}\",\"owner\":{\"org\":{\"id\":\"myorg\"}}},\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[-74.3,40.5],[-73.7,40.5],[-73.7,40.95],[-74.3,40.5]]]}} && zone == lab", true},
+		{"location inside {\"type\":\"Polygon\",\"coordinates\":[[[-74.3,40.5],[-73.7,40.5],[-73.7,40.95],[-74.3,40.5]]]", false},
+		{"location == {\"type\":\"Polygon\",\"coordinates\":[[[-74.3,40.5],[-73.7,40.5],[-73.7,40.95],[-74.3,40.5]]]}", false},
+		{"location inside nyc-1", true},
+		{"location inside \"nyc-1\"", true},
+		{"location within 50km of (140.7,-74.0)", false},
+		{"location within 50 of (40.7,-74.0)", false},
+		{"location within nyc-1", false},
+		{"location inside {\"type\":\"Point\",\"coordinates\":[-74.0,40.7]}", false},
+		{"location == 50km of (40.7,-74.0)", false},
+		{"expires > 2027-01-01", true},
+		{"expires <= 2027-01-01T00:00:00Z && installed >= 2024-03-15T08:30:00.5-05:00", true},
+		{"expires == 2027-01-01 || expires != 2028-01-01", true},
+		{"expires > now", true},
+		{"expires >= now+720h AND installed < now-24h", true},
+		{"camera.model == x100 && camera.lenses in \"wide,zoom\" && cameras.0.resolution >= 1080", true},
+		{"expires > 2027-13-01", false},
+		{"expires in 2027-01-01", false},
+		{"expires ^= 2027-01-01", false},
+		{"expires > now+1month", false},
+		{"expires > tomorrow", false},
+		{"cpu == 5 && mem == 5", true},
+	}
+
+	for _, test := range tests {
+		validated, _, err := textConstraintLanguagePlugin.Validate(interface{}([]string{test.constraint}))
+		if test.valid && (!validated || err != nil) {
+			t.Errorf("%v should validate successfully but not, err: %v", test.constraint, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%v should fail validation but did not", test.constraint)
+		}
+	}
+}
+
+func Test_GetNextExpression_Operators(t *testing.T) {
+	textConstraintLanguagePlugin := NewTextConstraintLanguagePlugin()
+
+	tests := []struct {
+		constraint string
+		expression string
+		remainder  string
+	}{
+		{"gpu exists && cpu > 2", "gpu\aexists\a", " && cpu > 2"},
+		{"hostname ~= \"store-[0-9]+\" || a == b", "hostname\a~=\a\"store-[0-9]+\"", " || a == b"},
+		{"hostname like \"store-*\"", "hostname\alike\a\"store-*\"", ""},
+		{"location ^= us-east", "location\a^=\aus-east", ""},
+		{"zone == NOT AND a == b", "zone\a==\aNOT", " AND a == b"},
+		{"NOT zone == lab", "", "NOT zone == lab"},
+	}
+
+	for _, test := range tests {
+		exp, rem, err := textConstraintLanguagePlugin.GetNextExpression(test.constraint)
+		if err != nil {
+			t.Errorf("Error parsing constraint expression %v with GetNextExpression: %v", test.constraint, err)
+		} else if exp != test.expression || rem != test.remainder {
+			t.Errorf("Expected expression %q and remainder %q from %v, got %q and %q", test.expression, test.remainder, test.constraint, exp, rem)
+		}
+	}
+
+	if op, rem, err := textConstraintLanguagePlugin.GetNextOperator("NOT (zone == lab)"); err != nil {
+		t.Errorf("Error parsing NOT with GetNextOperator: %v", err)
+	} else if op != "NOT" || rem != "(zone == lab)" {
+		t.Errorf("Expected operator NOT and remainder (zone == lab), got %q and %q", op, rem)
+	}
+}
@@ -0,0 +1,40 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	"time"
+)
+
+// Prune the event logs according to the retention configured for the node. The event logs that are referenced by
+// the active surfaced errors are kept, so that the errors can still be displayed in detail.
+func (w *GovernanceWorker) pruneEventLogs() int {
+
+	maxCount := w.BaseWorker.Manager.Config.Edge.EventLogMaxCount
+	cutoff := uint64(0)
+	if maxAge := w.BaseWorker.Manager.Config.Edge.EventLogMaxAgeDays; maxAge > 0 {
+		cutoff = uint64(time.Now().Add(-time.Duration(maxAge) * 24 * time.Hour).Unix())
+	}
+
+	if maxCount <= 0 && cutoff == 0 {
+		return 0
+	}
+
+	keep := make(map[string]bool)
+	if surfaceErrors, err := persistence.FindSurfaceErrors(w.db); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read the surfaced errors, event logs will not be pruned, error %v", err)))
+		return 0
+	} else {
+		for _, se := range surfaceErrors {
+			keep[se.Record_id] = true
+		}
+	}
+
+	if deleted, err := persistence.PruneEventLogs(w.db, maxCount, cutoff, keep); err != nil {
+		glog.Errorf(logString(err.Error()))
+	} else if deleted != 0 {
+		glog.V(3).Infof(logString(fmt.Sprintf("pruned %v event logs", deleted)))
+	}
+	return 0
+}
@@ -39,6 +39,8 @@ const MICROSERVICE_GOVERNOR = "MicroserviceGovernor"
 const BC_GOVERNOR = "BlockchainGovernor"
 const SURFACEERRORS = "SurfaceExchErrors"
 const NODESTATUS = "NodeStatus"
+const EVENTLOG_PRUNER = "EventLogPruner"
+const SERVICE_STATS = "ServiceStats"
 
 // Keys for the exchange errors cache in the worker
 const EXCHANGE_ERRORS = "ExchangeErrors"
@@ -57,6 +59,7 @@ type GovernanceWorker struct {
 	exchErrors        cache.Cache
 	noworkDispatch    int64 // The last time the NoWorkHandler was dispatched.
 	essCleanedUp      bool
+	lastStatsReport   int64 // The last time the node status was written to the exchange with service resource summaries.
 }
 
 func NewGovernanceWorker(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *GovernanceWorker {
@@ -399,6 +402,18 @@ func (w *GovernanceWorker) governAgreements() {
 
 	glog.V(3).Infof(logString(fmt.Sprintf("governing pending agreements")))
 
+	// In offline mode, agreements are not cancelled for timing out while the node cannot reach the exchange, because
+	// the node cannot finalize them or tell the agbot about it until it is back. After the node reconnects, the timeouts
+	// are counted from the time it reconnected.
+	offline, err := persistence.FindOfflineState(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to retrieve offline state from database. Error: %v", err)))
+	}
+	suspendTimeouts := offline.InGracePeriod(w.Config.Edge.OfflineGracePeriodS)
+	if suspendTimeouts {
+		glog.V(3).Infof(logString(fmt.Sprintf("node is offline, agreement timeouts are suspended")))
+	}
+
 	// Create a new filter for unfinalized agreements
 	notYetFinalFilter := func() persistence.EAFilter {
 		return func(a persistence.EstablishedAgreement) bool {
@@ -452,7 +467,7 @@ func (w *GovernanceWorker) governAgreements() {
 				if timeout == 0 {
 					timeout = ag.AgreementTimeout
 				}
-				if ag.AgreementCreationTime+timeout < now {
+				if !suspendTimeouts && uint64(offline.TimeoutStart(int64(ag.AgreementCreationTime)))+timeout < now {
 					// Start timing out the agreement
 					glog.V(3).Infof(logString(fmt.Sprintf("detected agreement %v timed out.", ag.CurrentAgreementId)))
 
@@ -473,7 +488,7 @@ func (w *GovernanceWorker) governAgreements() {
 				// For finalized agreements, make sure the workload has been started in time.
 				if ag.AgreementExecutionStartTime == 0 {
 					// workload not started yet and in an agreement ...
-					if (int64(ag.AgreementAcceptedTime) + (w.Config.Edge.MaxAgreementPrelaunchTimeM * 60)) < time.Now().Unix() {
+					if !suspendTimeouts && (offline.TimeoutStart(int64(ag.AgreementAcceptedTime))+(w.Config.Edge.MaxAgreementPrelaunchTimeM*60)) < time.Now().Unix() {
 						glog.Infof(logString(fmt.Sprintf("terminating agreement %v because it hasn't been launched in max allowed time. This could be because of a workload failure.", ag.CurrentAgreementId)))
 						reason := w.producerPH[ag.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_NOT_EXECUTED_TIMEOUT)
 						eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_INFO,
@@ -766,6 +781,14 @@ func (w *GovernanceWorker) Initialize() bool {
 	// Fire up the microservice governor
 	w.DispatchSubworker(MICROSERVICE_GOVERNOR, w.governMicroservices, 60, false)
 
+	// prune the event logs according to the configured retention
+	w.DispatchSubworker(EVENTLOG_PRUNER, w.pruneEventLogs, w.BaseWorker.Manager.Config.Edge.EventLogPruneIntervalS, false)
+
+	// sample the resources used by the services, when it is turned on
+	if w.BaseWorker.Manager.Config.Edge.ServiceStatsIntervalS > 0 {
+		w.DispatchSubworker(SERVICE_STATS, w.sampleServiceStats, w.BaseWorker.Manager.Config.Edge.ServiceStatsIntervalS, false)
+	}
+
 	// for the policy case update the exchange with the latest registeredServices
 	if w.devicePattern == "" {
 		w.UpdateRegisteredServicesWithAgreement()
@@ -1889,46 +1912,25 @@ func (w *GovernanceWorker) deleteProducerAgreement(url string, deviceId string,
 }
 
 func (w *GovernanceWorker) deleteMessage(msg *exchange.DeviceMessage) error {
-	var resp interface{}
-	resp = new(exchange.PostDeviceResponse)
-	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msg.MsgId)
-	for {
-		if err, tpErr := exchange.InvokeExchange(w.Config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), "DELETE", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
-			glog.Errorf(logString(err.Error()))
-			return err
-		} else if tpErr != nil {
-			glog.Warningf(logString(tpErr.Error()))
-			time.Sleep(10 * time.Second)
-			continue
-		} else {
-			glog.V(3).Infof(logString(fmt.Sprintf("deleted message %v", msg.MsgId)))
-			return nil
-		}
+	t, err := exchange.GetNodeMessageTransport(w.Config, w.GetExchangeId(), w.GetExchangeToken(), exchange.RetryForeverHTTPFactory(w.Config.Collaborators.HTTPClientFactory))
+	if err == nil {
+		err = t.DeleteMessage(msg.MsgId)
+	}
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to delete message %v, error %v", msg.MsgId, err)))
+		return err
 	}
+	glog.V(3).Infof(logString(fmt.Sprintf("deleted message %v", msg.MsgId)))
+	return nil
 }
 
 func (w *GovernanceWorker) messageInExchange(msgId int) (bool, error) {
-	var resp interface{}
-	resp = new(exchange.GetDeviceMessageResponse)
-	targetURL := w.GetExchangeURL() + "orgs/" + exchange.GetOrg(w.GetExchangeId()) + "/nodes/" + exchange.GetId(w.GetExchangeId()) + "/msgs/" + strconv.Itoa(msgId)
-	for {
-		if err, tpErr := exchange.InvokeExchange(w.Config.Collaborators.HTTPClientFactory.NewHTTPClient(nil), "GET", targetURL, w.GetExchangeId(), w.GetExchangeToken(), nil, &resp); err != nil {
-			glog.Errorf(logString(err.Error()))
-			return false, err
-		} else if tpErr != nil {
-			glog.Warningf(logString(tpErr.Error()))
-			time.Sleep(10 * time.Second)
-			continue
-		} else {
-			msgs := resp.(*exchange.GetDeviceMessageResponse).Messages
-			for _, msg := range msgs {
-				if msg.MsgId == msgId {
-					return true, nil
-				}
-			}
-			return false, nil
-		}
+	t, err := exchange.GetNodeMessageTransport(w.Config, w.GetExchangeId(), w.GetExchangeToken(), exchange.RetryForeverHTTPFactory(w.Config.Collaborators.HTTPClientFactory))
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to check for message %v, error %v", msgId, err)))
+		return false, err
 	}
+	return t.HasMessage(msgId)
 }
 
 var logString = func(v interface{}) string {
@@ -0,0 +1,247 @@
+package governance
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/kube_operator"
+	"github.com/open-horizon/anax/persistence"
+	"strings"
+	"time"
+)
+
+// How long to wait for docker to return the stats of one container.
+const CONTAINER_STATS_TIMEOUT = 10 * time.Second
+
+// How often the node status is written to the exchange just to refresh the service resource summaries, in seconds.
+const SERVICE_STATS_REPORT_INTERVAL = 300
+
+// Sample the resources used by each running service instance and add the samples to the rolling history in the local
+// db. The history of service instances that are no longer running is removed.
+func (w *GovernanceWorker) sampleServiceStats() int {
+
+	var client *docker.Client
+	containers := make([]docker.APIContainers, 0)
+	if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
+		if w.Config.Edge.DockerEndpoint == "" {
+			return 0
+		}
+		var err error
+		if client, err = docker.NewClient(w.Config.Edge.DockerEndpoint); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to sample service resource usage, failed to instantiate docker client: %v", err)))
+			return 0
+		} else if containers, err = client.ListContainers(docker.ListContainersOptions{}); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to sample service resource usage, failed to list containers: %v", err)))
+			return 0
+		}
+	}
+
+	msdefs, err := persistence.FindMicroserviceDefs(w.db, []persistence.MSFilter{persistence.UnarchivedMSFilter()})
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to sample service resource usage, error retrieving service definitions: %v", err)))
+		return 0
+	}
+
+	active := make(map[string]bool)
+	for _, msdef := range msdefs {
+		msinsts, err := persistence.GetAllMicroserviceInstancesWithDefId(w.db, msdef.Id, false, false)
+		if err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to sample service resource usage, error retrieving instances of %v: %v", msdef.SpecRef, err)))
+			return 0
+		}
+
+		for _, msi := range msinsts {
+			if msi.GetCleanupStartTime() != 0 {
+				continue
+			}
+
+			var samples []persistence.ContainerResourceSample
+			if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
+				deployment, _ := msdef.GetDeployment()
+				samples = sampleContainers(client, deployment, msi.GetKey(), !msi.IsTopLevelService(), containers)
+			} else if msdef.ClusterDeployment != "" {
+				samples = samplePods(msdef.ClusterDeployment, msi.GetKey())
+			}
+
+			if len(samples) == 0 {
+				continue
+			}
+
+			active[msi.GetKey()] = true
+			stats := persistence.ServiceStats{InstanceKey: msi.GetKey(), ServiceURL: msdef.SpecRef, Org: msdef.Org, Version: msdef.Version, Arch: msdef.Arch}
+			sample := persistence.ServiceResourceSample{Time: time.Now().Unix(), Containers: samples}
+			if err := persistence.SaveServiceStatsSample(w.db, stats, sample, w.Config.Edge.ServiceStatsHistorySize); err != nil {
+				glog.Errorf(logString(fmt.Sprintf("unable to save resource usage sample for %v: %v", msi.GetKey(), err)))
+			}
+		}
+	}
+
+	if deleted, err := persistence.PruneServiceStats(w.db, active); err != nil {
+		glog.Errorf(logString(err.Error()))
+	} else if deleted != 0 {
+		glog.V(3).Infof(logString(fmt.Sprintf("removed resource usage history of %v service instances that are no longer running", deleted)))
+	}
+	return 0
+}
+
+// Get the docker stats of the running containers of a service instance. The containers are found the same way as
+// they are for the node status.
+func sampleContainers(client *docker.Client, deployment string, key string, infrastructure bool, containers []docker.APIContainers) []persistence.ContainerResourceSample {
+	samples := make([]persistence.ContainerResourceSample, 0)
+
+	deploymentDesc, err := containermessage.GetNativeDeployment(deployment)
+	if err != nil || deploymentDesc == nil {
+		return samples
+	}
+
+	label := container.LABEL_PREFIX + ".agreement_id"
+	if infrastructure {
+		label = container.LABEL_PREFIX + ".infrastructure"
+	}
+
+	for serviceName := range deploymentDesc.Services {
+		for _, c := range containers {
+			if _, ok := c.Labels[label]; !ok || len(c.Names) == 0 || c.Names[0] != "/"+key+"-"+serviceName || c.State != "running" {
+				continue
+			}
+			if stats, err := containerStats(client, c.ID); err != nil {
+				glog.Warningf(logString(fmt.Sprintf("unable to get resource usage of container %v: %v", c.Names[0], err)))
+			} else if stats != nil {
+				samples = append(samples, containerResourceSample(c.Names[0], stats))
+			}
+			break
+		}
+	}
+	return samples
+}
+
+// Get a single stats object for a container. Docker fills in the previous CPU usage, so the CPU usage can be
+// computed without waiting for a second sample.
+func containerStats(client *docker.Client, id string) (*docker.Stats, error) {
+	statsChan := make(chan *docker.Stats, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- client.Stats(docker.StatsOptions{ID: id, Stats: statsChan, Stream: false, Timeout: CONTAINER_STATS_TIMEOUT})
+	}()
+
+	stats := <-statsChan
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Convert docker stats to a resource sample. The CPU percentage is computed the same way as the docker CLI computes it,
+// so it is a percent of one CPU. The memory usage leaves out the page cache.
+func containerResourceSample(name string, stats *docker.Stats) persistence.ContainerResourceSample {
+	sample := persistence.ContainerResourceSample{Name: name}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemCPUUsage) - float64(stats.PreCPUStats.SystemCPUUsage)
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		sample.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	sample.MemoryUsage = stats.MemoryStats.Usage
+	cache := stats.MemoryStats.Stats.TotalInactiveFile
+	if cache == 0 {
+		cache = stats.MemoryStats.Stats.InactiveFile
+	}
+	if cache == 0 {
+		cache = stats.MemoryStats.Stats.Cache
+	}
+	if cache < sample.MemoryUsage {
+		sample.MemoryUsage -= cache
+	}
+	sample.MemoryLimit = stats.MemoryStats.Limit
+
+	for _, network := range stats.Networks {
+		sample.NetworkRx += network.RxBytes
+		sample.NetworkTx += network.TxBytes
+	}
+
+	for _, entry := range stats.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			sample.BlockRead += entry.Value
+		case "write":
+			sample.BlockWrite += entry.Value
+		}
+	}
+	return sample
+}
+
+// Get the resources used by the operator pods of a cluster service from the cluster's metrics API. The metrics API
+// does not report network or block I/O, and a CPU percentage of 100 is one CPU (1000 millicores).
+func samplePods(deployment string, key string) []persistence.ContainerResourceSample {
+	samples := make([]persistence.ContainerResourceSample, 0)
+
+	kdc, err := persistence.GetKubeDeployment(deployment)
+	if err != nil {
+		return samples
+	}
+
+	kc, err := kube_operator.NewKubeClient()
+	if err != nil {
+		glog.Warningf(logString(fmt.Sprintf("unable to get resource usage of %v: %v", key, err)))
+		return samples
+	}
+
+	metrics, err := kc.PodMetrics(kdc.OperatorYamlArchive, key)
+	if err != nil {
+		glog.V(3).Infof(logString(fmt.Sprintf("unable to get resource usage of %v: %v", key, err)))
+		return samples
+	}
+
+	for _, m := range metrics {
+		sample := persistence.ContainerResourceSample{Name: m.Name, CPUPercent: float64(m.CPUMillicores) / 10}
+		if m.MemoryBytes > 0 {
+			sample.MemoryUsage = uint64(m.MemoryBytes)
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// Summarize the resource usage history of each service, by service org, url and version. The summaries of the
+// instances of the same service are added together.
+func serviceStatsSummaries(allStats []persistence.ServiceStats) map[string]*persistence.ServiceStatsSummary {
+	summaries := make(map[string]*persistence.ServiceStatsSummary)
+	for _, stats := range allStats {
+		summary := stats.Summary()
+		if summary == nil {
+			continue
+		}
+		key := serviceStatsKey(stats.Org, stats.ServiceURL, stats.Version)
+		if current, ok := summaries[key]; ok {
+			current.Add(summary)
+		} else {
+			summaries[key] = summary
+		}
+	}
+	return summaries
+}
+
+func serviceStatsKey(org string, url string, version string) string {
+	return fmt.Sprintf("%v/%v_%v", org, url, version)
+}
+
+// Add the resource summary of each service to its status.
+func (w *GovernanceWorker) addServiceStatsSummaries(services []WorkloadStatus) {
+	allStats, err := persistence.FindServiceStats(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read the service resource usage history: %v", err)))
+		return
+	}
+
+	summaries := serviceStatsSummaries(allStats)
+	for ix, svc := range services {
+		services[ix].Resources = summaries[serviceStatsKey(svc.Org, svc.ServiceURL, svc.Version)]
+	}
+}
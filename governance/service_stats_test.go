@@ -0,0 +1,58 @@
+// +build unit
+
+package governance
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_containerResourceSample(t *testing.T) {
+	stats := &docker.Stats{}
+	stats.CPUStats.CPUUsage.TotalUsage = 3000
+	stats.CPUStats.SystemCPUUsage = 20000
+	stats.CPUStats.OnlineCPUs = 2
+	stats.PreCPUStats.CPUUsage.TotalUsage = 1000
+	stats.PreCPUStats.SystemCPUUsage = 10000
+	stats.MemoryStats.Usage = 1000
+	stats.MemoryStats.Limit = 4000
+	stats.MemoryStats.Stats.Cache = 200
+	stats.Networks = map[string]docker.NetworkStats{"eth0": {RxBytes: 10, TxBytes: 20}, "eth1": {RxBytes: 1, TxBytes: 2}}
+	stats.BlkioStats.IOServiceBytesRecursive = []docker.BlkioStatsEntry{{Op: "Read", Value: 5}, {Op: "Write", Value: 7}, {Op: "Total", Value: 12}, {Op: "read", Value: 1}}
+
+	sample := containerResourceSample("/ag1-web", stats)
+	assert.Equal(t, "/ag1-web", sample.Name)
+	assert.Equal(t, 40.0, sample.CPUPercent, "The CPU should be the share of the system CPU delta times the number of CPUs.")
+	assert.Equal(t, uint64(800), sample.MemoryUsage, "The page cache should not be counted.")
+	assert.Equal(t, uint64(4000), sample.MemoryLimit)
+	assert.Equal(t, uint64(11), sample.NetworkRx)
+	assert.Equal(t, uint64(22), sample.NetworkTx)
+	assert.Equal(t, uint64(6), sample.BlockRead)
+	assert.Equal(t, uint64(7), sample.BlockWrite)
+
+	// The first stats of a container have no previous CPU usage.
+	stats.PreCPUStats.SystemCPUUsage = 30000
+	assert.Equal(t, 0.0, containerResourceSample("/ag1-web", stats).CPUPercent)
+}
+
+func Test_serviceStatsSummaries(t *testing.T) {
+	sample := func(cpu float64) []persistence.ServiceResourceSample {
+		return []persistence.ServiceResourceSample{{Time: 60, Containers: []persistence.ContainerResourceSample{{CPUPercent: cpu, MemoryUsage: 100}}}}
+	}
+	allStats := []persistence.ServiceStats{
+		{InstanceKey: "ag1", ServiceURL: "my.com.svc", Org: "myorg", Version: "1.0.0", Samples: sample(10)},
+		{InstanceKey: "ag2", ServiceURL: "my.com.svc", Org: "myorg", Version: "1.0.0", Samples: sample(20)},
+		{InstanceKey: "dep1", ServiceURL: "my.com.dep", Org: "myorg", Version: "2.0.0", Samples: sample(5)},
+		{InstanceKey: "dep2", ServiceURL: "my.com.empty", Org: "myorg", Version: "2.0.0"},
+	}
+
+	summaries := serviceStatsSummaries(allStats)
+	assert.Equal(t, 2, len(summaries), "Services without samples should have no summary.")
+	if svc := summaries[serviceStatsKey("myorg", "my.com.svc", "1.0.0")]; assert.NotNil(t, svc) {
+		assert.Equal(t, 30.0, svc.CPUPercent, "The instances of a service should be added together.")
+		assert.Equal(t, uint64(200), svc.MemoryUsage)
+		assert.Equal(t, 2, svc.Samples)
+	}
+}
@@ -150,6 +150,12 @@ func (w *GovernanceWorker) nodeShutdown(cmd *NodeShutdownCommand) {
 		return
 	}
 
+	// Delete the offline state from local db
+	if err := persistence.DeleteOfflineState(w.db); err != nil {
+		w.completedWithError(logString(err.Error()))
+		return
+	}
+
 	// remove the docker volumes that are created by anax if device type is "device"
 	if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
 		if err := container.DeleteLeftoverDockerVolumes(w.db, w.Config); err != nil {
@@ -22,25 +22,28 @@ type ContainerStatus struct {
 	Image   string `json:"image"`
 	Created int64  `json:"created"`
 	State   string `json:"state"`
+	Health  string `json:"health,omitempty"` // Only set for containers with a health check
 }
 
 func (w ContainerStatus) String() string {
 	return fmt.Sprintf("Name: %v, "+
 		"Image: %v, "+
 		"Created: %v, "+
-		"State: %v",
-		w.Name, w.Image, w.Created, w.State)
+		"State: %v, "+
+		"Health: %v",
+		w.Name, w.Image, w.Created, w.State, w.Health)
 }
 
 type WorkloadStatus struct {
-	AgreementId    string            `json:"agreementId"`
-	ServiceURL     string            `json:"serviceUrl,omitempty"`
-	Org            string            `json:"orgid,omitempty"`
-	Version        string            `json:"version,omitempty"`
-	Arch           string            `json:"arch,omitempty"`
-	Containers     []ContainerStatus `json:"containerStatus"`
-	OperatorStatus interface{}       `json:"operatorStatus,omitempty"`
-	ConfigState    string            `json:"configState,omitempty"`
+	AgreementId    string                           `json:"agreementId"`
+	ServiceURL     string                           `json:"serviceUrl,omitempty"`
+	Org            string                           `json:"orgid,omitempty"`
+	Version        string                           `json:"version,omitempty"`
+	Arch           string                           `json:"arch,omitempty"`
+	Containers     []ContainerStatus                `json:"containerStatus"`
+	OperatorStatus interface{}                      `json:"operatorStatus,omitempty"`
+	ConfigState    string                           `json:"configState,omitempty"`
+	Resources      *persistence.ServiceStatsSummary `json:"resources,omitempty"` // Only set when ReportServiceStats is configured
 }
 
 func (w WorkloadStatus) String() string {
@@ -51,8 +54,9 @@ func (w WorkloadStatus) String() string {
 		"Arch: %v, "+
 		"Containers: %v"+
 		"OperatorStatus: %v"+
-		"ConfigState: %v",
-		w.AgreementId, w.ServiceURL, w.Org, w.Version, w.Arch, w.Containers, w.OperatorStatus, w.ConfigState)
+		"ConfigState: %v"+
+		"Resources: %v",
+		w.AgreementId, w.ServiceURL, w.Org, w.Version, w.Arch, w.Containers, w.OperatorStatus, w.ConfigState, w.Resources)
 }
 
 type DeviceStatus struct {
@@ -176,11 +180,41 @@ func (w *GovernanceWorker) reportDeviceStatus(cfgStates []events.ServiceConfigSt
 
 	statusChanged = changeInWorkloadStatuses(device_status_new.Services, oldWlStatus)
 
-	if statusChanged {
+	// The resource summaries change all the time, so they do not count as a status change. Instead, the status is
+	// written periodically to keep the summaries in the exchange current.
+	if w.Config.Edge.ReportServiceStats {
+		w.addServiceStatsSummaries(device_status_new.Services)
+		if time.Now().Unix()-w.lastStatsReport >= SERVICE_STATS_REPORT_INTERVAL {
+			statusChanged = true
+		}
+	}
+
+	// In offline mode, a status that could not be written to the exchange is written once the node is back online,
+	// even if it has not changed since.
+	offlineMode := w.Config.Edge.OfflineGracePeriodS > 0
+	offline, err := persistence.FindOfflineState(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Failed to retrieve offline state from local database: %v", err)))
+	}
+
+	if statusChanged || offline.IsStatusPending() {
 		glog.V(5).Infof(logString(fmt.Sprintf("device status to report to the exchange: %v", device_status_new)))
 
-		if err := w.writeStatusToExchange(&device_status_new); err != nil {
+		if offlineMode && offline.IsOffline() {
+			glog.V(3).Infof(logString(fmt.Sprintf("node is offline, the device status will be reported when it reconnects")))
+			w.setStatusPending(true)
+		} else if err := w.writeStatusToExchange(&device_status_new); err != nil {
 			glog.Errorf(logString(err))
+			if offlineMode {
+				w.setStatusPending(true)
+			}
+		} else {
+			if offline.IsStatusPending() {
+				w.setStatusPending(false)
+			}
+			if w.Config.Edge.ReportServiceStats {
+				w.lastStatsReport = time.Now().Unix()
+			}
 		}
 		if err := persistence.SaveNodeStatus(w.db, convertToPersistenceType(device_status_new.Services)); err != nil {
 			glog.Errorf(logString(err))
@@ -191,6 +225,13 @@ func (w *GovernanceWorker) reportDeviceStatus(cfgStates []events.ServiceConfigSt
 	return 60
 }
 
+// Remember whether the device status still has to be written to the exchange.
+func (w *GovernanceWorker) setStatusPending(pending bool) {
+	if _, err := persistence.UpdateOfflineState(w.db, func(s *persistence.OfflineState) { s.StatusPending = pending }); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Failed to save offline state to local database: %v", err)))
+	}
+}
+
 // Update the services with configstate of the old suspended services.
 func updateWithOldSuspendedServices(updatedServices []WorkloadStatus, oldServices []persistence.WorkloadStatus) []WorkloadStatus {
 	newStatus := make([]WorkloadStatus, len(updatedServices))
@@ -294,14 +335,15 @@ func GetContainerStatus(deployment string, key string, infrastructure bool, cont
 			container_status.Name = serviceName
 			container_status.Image = s_details.Image
 			container_status.State = "not started"
-			for _, container := range containers {
-				if _, ok := container.Labels[label]; ok {
-					cname := container.Names[0]
+			for _, c := range containers {
+				if _, ok := c.Labels[label]; ok {
+					cname := c.Names[0]
 					if cname == "/"+key+"-"+serviceName {
-						container_status.Name = container.Names[0]
-						container_status.Image = container.Image
-						container_status.Created = container.Created
-						container_status.State = container.State
+						container_status.Name = c.Names[0]
+						container_status.Image = c.Image
+						container_status.Created = c.Created
+						container_status.State = c.State
+						container_status.Health = container.ContainerHealth(c)
 						break
 					}
 				}
@@ -463,7 +505,7 @@ func changeInContainerStatuses(newContainers []ContainerStatus, oldContainers []
 	for _, oldContainer := range oldContainers {
 		for _, newContainer := range newContainers {
 			if oldContainer.Name == newContainer.Name && oldContainer.Image == newContainer.Image && oldContainer.Created == newContainer.Created {
-				if oldContainer.State == newContainer.State {
+				if oldContainer.State == newContainer.State && oldContainer.Health == newContainer.Health {
 					matches++
 				} else {
 					return true
@@ -492,7 +534,7 @@ func convertToPersistenceType(workload []WorkloadStatus) []persistence.WorkloadS
 func converContainerStatusToPersistenceType(containers []ContainerStatus) []persistence.ContainerStatus {
 	persistentCStatuses := []persistence.ContainerStatus{}
 	for _, cStatus := range containers {
-		persistentCStatuses = append(persistentCStatuses, persistence.ContainerStatus{Name: cStatus.Name, Image: cStatus.Image, Created: cStatus.Created, State: cStatus.State})
+		persistentCStatuses = append(persistentCStatuses, persistence.ContainerStatus{Name: cStatus.Name, Image: cStatus.Image, Created: cStatus.Created, State: cStatus.State, Health: cStatus.Health})
 	}
 	return persistentCStatuses
 }
@@ -111,6 +111,41 @@ func Test_GetContainerStatus(t *testing.T) {
 	assert.True(t, statusArrayIsSame(exp_status, status), "The elements should be the same.")
 }
 
+func Test_GetContainerStatus_Health(t *testing.T) {
+	c1 := docker.APIContainers{
+		ID:      "93f4354c97",
+		Image:   "mycompany/x86/netspeed5:v2.5",
+		Created: 1507728202,
+		State:   "running",
+		Status:  "Up 2 minutes (unhealthy)",
+		Names:   []string{"/aaaa-netspeed5"},
+		Labels: map[string]string{
+			"openhorizon.anax.agreement_id": "aaaa",
+			"openhorizon.anax.service_name": "netspeed5"},
+	}
+	c2 := docker.APIContainers{
+		ID:      "73f4354c98",
+		Image:   "mycompany/x86/test:v1.0",
+		Created: 1507728356,
+		State:   "running",
+		Status:  "Up 10 seconds (healthy)",
+		Names:   []string{"/aaaa-test"},
+		Labels: map[string]string{
+			"openhorizon.anax.agreement_id": "aaaa",
+			"openhorizon.anax.service_name": "test"},
+	}
+	containers := []docker.APIContainers{c1, c2}
+
+	deployment := "{\"services\":{\"netspeed5\":{\"image\":\"mycompany/x86/netspeed5:v2.5\"}, \"test\":{\"image\":\"mycompany/x86/test:v1.0\"}}}"
+	exp_status := []ContainerStatus{ContainerStatus{Name: "/aaaa-netspeed5", Image: "mycompany/x86/netspeed5:v2.5", Created: 1507728202, State: "running", Health: "unhealthy"},
+		{Name: "/aaaa-test", Image: "mycompany/x86/test:v1.0", Created: 1507728356, State: "running", Health: "healthy"}}
+
+	status, err := GetContainerStatus(deployment, "aaaa", false, containers)
+
+	assert.Nil(t, err)
+	assert.True(t, statusArrayIsSame(exp_status, status), "The elements should be the same.")
+}
+
 // Compare 2 ContainerStatus array contents without considering the order
 func statusArrayIsSame(a1 []ContainerStatus, a2 []ContainerStatus) bool {
 	if len(a1) != len(a2) {
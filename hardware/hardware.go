@@ -0,0 +1,194 @@
+package hardware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// These are the keys within a service's matchHardware section that anax knows how to evaluate.
+// Any other key is ignored so that service definitions that were published before the hardware
+// requirements were enforced continue to work. This includes keys such as usbDeviceIds and devFiles
+// that existing services use to describe their hardware without requiring it.
+const (
+	HW_DEVICES     = "devices"    // A list of device node paths (glob patterns allowed) that must exist on the node, e.g. /dev/video*
+	HW_MIN_MEMORY  = "minMemory"  // The minimum amount of memory in MBs
+	HW_MIN_CPUS    = "minCPUs"    // The minimum number of CPUs
+	HW_CPU_FLAGS   = "cpuFlags"   // A list of CPU flags as found in /proc/cpuinfo, e.g. avx2
+	HW_USB_DEVICES = "usbDevices" // A list of USB devices in the vendor:product format, e.g. 046d:0825
+)
+
+// The hardware found on a node. A nil field means that the information is not known, which is the case
+// when the hardware is evaluated remotely (e.g. by hzn deploycheck) from the node's built-in properties.
+// Requirements on unknown hardware are not evaluated.
+type HardwareInfo struct {
+	MemoryMB     *uint64  `json:"memory,omitempty"`
+	CPUs         *int     `json:"cpus,omitempty"`
+	Devices      []string `json:"devices,omitempty"`
+	CPUFlags     []string `json:"cpuFlags,omitempty"`
+	USBDeviceIds []string `json:"usbDeviceIds,omitempty"`
+}
+
+func (h HardwareInfo) String() string {
+	mem := "unknown"
+	if h.MemoryMB != nil {
+		mem = fmt.Sprintf("%v", *h.MemoryMB)
+	}
+	cpus := "unknown"
+	if h.CPUs != nil {
+		cpus = fmt.Sprintf("%v", *h.CPUs)
+	}
+	return fmt.Sprintf("MemoryMB: %v, CPUs: %v, Devices: %v, CPUFlags: %v, USBDeviceIds: %v", mem, cpus, h.Devices, h.CPUFlags, h.USBDeviceIds)
+}
+
+// Create a HardwareInfo object that only knows about the number of CPUs and the memory size.
+func NewHardwareInfo(memoryMB uint64, cpus int) *HardwareInfo {
+	return &HardwareInfo{
+		MemoryMB: &memoryMB,
+		CPUs:     &cpus,
+	}
+}
+
+// Inspect the local node for the hardware that can be requested by a service. The rootDir is
+// the directory under which proc, sys and dev are found, an empty string means "/".
+func GetHardwareInfo(rootDir string) (*HardwareInfo, error) {
+	if rootDir == "" {
+		rootDir = "/"
+	}
+
+	hw := new(HardwareInfo)
+
+	cpuinfo := path.Join(rootDir, "proc", "cpuinfo")
+	if cpus, err := cutil.GetCPUCount(cpuinfo); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to get the cpu count from %v, error: %v", cpuinfo, err))
+	} else {
+		hw.CPUs = &cpus
+	}
+
+	if flags, err := getCPUFlags(cpuinfo); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to get the cpu flags from %v, error: %v", cpuinfo, err))
+	} else {
+		hw.CPUFlags = flags
+	}
+
+	meminfo := path.Join(rootDir, "proc", "meminfo")
+	if totalMem, _, err := cutil.GetMemInfo(meminfo); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to get the memory size from %v, error: %v", meminfo, err))
+	} else {
+		hw.MemoryMB = &totalMem
+	}
+
+	devDir := path.Join(rootDir, "dev")
+	if devices, err := getDevices(devDir); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to list the devices under %v, error: %v", devDir, err))
+	} else {
+		hw.Devices = devices
+	}
+
+	usbDir := path.Join(rootDir, "sys", "bus", "usb", "devices")
+	if usbIds, err := getUSBDeviceIds(usbDir); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to list the usb devices under %v, error: %v", usbDir, err))
+	} else {
+		hw.USBDeviceIds = usbIds
+	}
+
+	glog.V(5).Infof(hwlogString(fmt.Sprintf("node hardware: %v", hw)))
+
+	return hw, nil
+}
+
+// Returns the device node paths under the given dev directory. The paths are reported as they
+// would be seen from the node, i.e. /dev/...
+func getDevices(devDir string) ([]string, error) {
+	devices := make([]string, 0, 10)
+
+	if _, err := os.Stat(devDir); os.IsNotExist(err) {
+		return devices, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err := filepath.Walk(devDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// a device can go away while we are looking at it, skip it
+			return nil
+		} else if p == devDir || info.IsDir() {
+			return nil
+		}
+		if rel, err := filepath.Rel(devDir, p); err == nil {
+			devices = append(devices, path.Join("/dev", filepath.ToSlash(rel)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(devices)
+	return devices, nil
+}
+
+// Returns the cpu flags of the first processor in the given cpuinfo file.
+func getCPUFlags(cpuinfoFile string) ([]string, error) {
+	fh, err := os.Open(cpuinfoFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	// x86 uses 'flags', arm uses 'Features'
+	r := regexp.MustCompile(`^(flags|Features)[ \t]*:(.*)$`)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if match := r.FindStringSubmatch(scanner.Text()); match != nil {
+			return strings.Fields(match[2]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return []string{}, nil
+}
+
+// Returns the vendor:product ids of the usb devices found in the given sysfs usb devices directory.
+func getUSBDeviceIds(usbDir string) ([]string, error) {
+	ids := make([]string, 0, 5)
+
+	entries, err := ioutil.ReadDir(usbDir)
+	if os.IsNotExist(err) {
+		return ids, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	for _, entry := range entries {
+		vendor, err1 := ioutil.ReadFile(path.Join(usbDir, entry.Name(), "idVendor"))
+		product, err2 := ioutil.ReadFile(path.Join(usbDir, entry.Name(), "idProduct"))
+		if err1 != nil || err2 != nil {
+			// interfaces and hubs without ids
+			continue
+		}
+		id := strings.ToLower(fmt.Sprintf("%v:%v", strings.TrimSpace(string(vendor)), strings.TrimSpace(string(product))))
+		if !found[id] {
+			found[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+var hwlogString = func(v interface{}) string {
+	return fmt.Sprintf("Hardware: %v", v)
+}
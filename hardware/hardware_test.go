@@ -0,0 +1,180 @@
+// +build unit
+
+package hardware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_GetHardwareInfo(t *testing.T) {
+	root := createFakeRoot(t)
+	defer os.RemoveAll(root)
+
+	hw, err := GetHardwareInfo(root)
+	assert.Nil(t, err)
+	assert.NotNil(t, hw)
+
+	assert.Equal(t, 2, *hw.CPUs)
+	assert.Equal(t, uint64(3906), *hw.MemoryMB)
+	assert.Equal(t, []string{"fpu", "vme", "sse2", "avx2"}, hw.CPUFlags)
+	assert.Equal(t, []string{"/dev/bus/usb/001/002", "/dev/null", "/dev/video0"}, hw.Devices)
+	assert.Equal(t, []string{"046d:0825", "1d6b:0002"}, hw.USBDeviceIds)
+}
+
+func Test_GetHardwareInfo_NoDevices(t *testing.T) {
+	root := createFakeRoot(t)
+	defer os.RemoveAll(root)
+
+	os.RemoveAll(path.Join(root, "dev"))
+	os.RemoveAll(path.Join(root, "sys"))
+
+	hw, err := GetHardwareInfo(root)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, hw.Devices)
+	assert.Equal(t, []string{}, hw.USBDeviceIds)
+}
+
+func Test_ParseHardwareRequirement(t *testing.T) {
+	req, err := ParseHardwareRequirement(nil)
+	assert.Nil(t, err)
+	assert.True(t, req.IsEmpty())
+
+	req, err = ParseHardwareRequirement(map[string]interface{}{
+		HW_DEVICES:     []interface{}{"/dev/video*"},
+		HW_MIN_MEMORY:  float64(512),
+		HW_MIN_CPUS:    float64(2),
+		HW_CPU_FLAGS:   "avx2",
+		HW_USB_DEVICES: []interface{}{"046D:0825"},
+		"dev":          "/dev/dev1",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/dev/video*"}, req.Devices)
+	assert.Equal(t, uint64(512), req.MinMemoryMB)
+	assert.Equal(t, 2, req.MinCPUs)
+	assert.Equal(t, []string{"avx2"}, req.CPUFlags)
+	assert.Equal(t, []string{"046d:0825"}, req.USBDeviceIds)
+
+	invalid := []map[string]interface{}{
+		{HW_DEVICES: float64(1)},
+		{HW_DEVICES: []interface{}{"/dev/video0", true}},
+		{HW_MIN_MEMORY: "512"},
+		{HW_MIN_MEMORY: float64(-1)},
+		{HW_MIN_CPUS: float64(1.5)},
+		{HW_USB_DEVICES: []interface{}{"046d"}},
+		{HW_USB_DEVICES: []interface{}{"046d:08zz"}},
+	}
+	for _, mh := range invalid {
+		_, err := ParseHardwareRequirement(mh)
+		assert.NotNil(t, err, "should have failed for %v", mh)
+	}
+}
+
+func Test_HardwareMatcher(t *testing.T) {
+	hw := NewHardwareInfo(1024, 4)
+	hw.Devices = []string{"/dev/null", "/dev/video0"}
+	hw.CPUFlags = []string{"fpu", "avx2"}
+	hw.USBDeviceIds = []string{"046d:0825"}
+
+	matcher := NewHardwareMatcher(hw)
+
+	match, reason, err := matcher.Match(nil)
+	assert.Nil(t, err)
+	assert.True(t, match)
+	assert.Equal(t, "", reason)
+
+	match, _, err = matcher.Match(map[string]interface{}{
+		HW_DEVICES:     []interface{}{"/dev/video*", "/dev/null"},
+		HW_MIN_MEMORY:  float64(1024),
+		HW_MIN_CPUS:    float64(4),
+		HW_CPU_FLAGS:   []interface{}{"avx2"},
+		HW_USB_DEVICES: []interface{}{"046d:0825"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, match)
+
+	match, reason, err = matcher.Match(map[string]interface{}{
+		HW_DEVICES:    []interface{}{"/dev/ttyUSB*"},
+		HW_MIN_MEMORY: float64(2048),
+	})
+	assert.Nil(t, err)
+	assert.False(t, match)
+	assert.Contains(t, reason, "requires 2048 MB of memory, the node has 1024 MB")
+	assert.Contains(t, reason, "requires device /dev/ttyUSB*")
+
+	match, reason, err = matcher.Match(map[string]interface{}{
+		HW_MIN_CPUS:    float64(8),
+		HW_CPU_FLAGS:   []interface{}{"avx512f"},
+		HW_USB_DEVICES: []interface{}{"1234:5678"},
+	})
+	assert.Nil(t, err)
+	assert.False(t, match)
+	assert.Contains(t, reason, "requires 8 CPUs, the node has 4")
+	assert.Contains(t, reason, "requires CPU flag avx512f")
+	assert.Contains(t, reason, "requires USB device 1234:5678")
+
+	_, _, err = matcher.Match(map[string]interface{}{HW_MIN_CPUS: "many"})
+	assert.NotNil(t, err)
+
+	// The keys that existing services use to describe their hardware are not requirements.
+	match, _, err = matcher.Match(map[string]interface{}{
+		"usbDeviceIds": "1546:01a7",
+		"devFiles":     "/dev/ttyUSB*,/dev/ttyACM*",
+	})
+	assert.Nil(t, err)
+	assert.True(t, match)
+}
+
+func Test_HardwareMatcher_Unknown(t *testing.T) {
+	// only the memory is known, the other requirements cannot be evaluated
+	mem := uint64(512)
+	matcher := NewHardwareMatcher(&HardwareInfo{MemoryMB: &mem})
+
+	match, _, err := matcher.Match(map[string]interface{}{
+		HW_DEVICES:   []interface{}{"/dev/video0"},
+		HW_MIN_CPUS:  float64(8),
+		HW_CPU_FLAGS: []interface{}{"avx2"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, match)
+
+	match, _, err = matcher.Match(map[string]interface{}{HW_MIN_MEMORY: float64(1024)})
+	assert.Nil(t, err)
+	assert.False(t, match)
+}
+
+// Create a directory tree that looks like the proc, sys and dev file systems of a node.
+func createFakeRoot(t *testing.T) string {
+	root, err := ioutil.TempDir("", "hwtest")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	cpuinfo := "processor\t: 0\nflags\t\t: fpu vme sse2 avx2\n\nprocessor\t: 1\nflags\t\t: fpu vme sse2 avx2\n"
+	meminfo := "MemTotal:        4000000 kB\nMemAvailable:    2000000 kB\n"
+
+	files := map[string]string{
+		"proc/cpuinfo":                                cpuinfo,
+		"proc/meminfo":                                meminfo,
+		"dev/null":                                    "",
+		"dev/video0":                                  "",
+		"dev/bus/usb/001/002":                         "",
+		"sys/bus/usb/devices/1-1/idVendor":            "046d\n",
+		"sys/bus/usb/devices/1-1/idProduct":           "0825\n",
+		"sys/bus/usb/devices/usb1/idVendor":           "1d6b\n",
+		"sys/bus/usb/devices/usb1/idProduct":          "0002\n",
+		"sys/bus/usb/devices/1-1:1.0/bInterfaceClass": "0e\n",
+	}
+	for name, content := range files {
+		fn := path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(fn), 0755); err != nil {
+			t.Fatalf("unable to create dir for %v: %v", fn, err)
+		} else if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write %v: %v", fn, err)
+		}
+	}
+	return root
+}
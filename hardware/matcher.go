@@ -0,0 +1,226 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"path/filepath"
+	"strings"
+)
+
+// The parsed form of the known keys in a service's matchHardware section.
+type HardwareRequirement struct {
+	Devices      []string
+	MinMemoryMB  uint64
+	MinCPUs      int
+	CPUFlags     []string
+	USBDeviceIds []string
+}
+
+func (h HardwareRequirement) String() string {
+	return fmt.Sprintf("Devices: %v, MinMemoryMB: %v, MinCPUs: %v, CPUFlags: %v, USBDeviceIds: %v",
+		h.Devices, h.MinMemoryMB, h.MinCPUs, h.CPUFlags, h.USBDeviceIds)
+}
+
+// Returns true if there is nothing to check.
+func (h HardwareRequirement) IsEmpty() bool {
+	return len(h.Devices) == 0 && h.MinMemoryMB == 0 && h.MinCPUs == 0 && len(h.CPUFlags) == 0 && len(h.USBDeviceIds) == 0
+}
+
+// Convert the matchHardware section of a service definition into a HardwareRequirement object.
+// Unknown keys are ignored, known keys with a value of the wrong type are an error.
+func ParseHardwareRequirement(matchHardware map[string]interface{}) (*HardwareRequirement, error) {
+	req := new(HardwareRequirement)
+	if matchHardware == nil {
+		return req, nil
+	}
+
+	var err error
+	for key, val := range matchHardware {
+		switch key {
+		case HW_DEVICES:
+			req.Devices, err = toStringList(key, val)
+		case HW_CPU_FLAGS:
+			req.CPUFlags, err = toStringList(key, val)
+		case HW_USB_DEVICES:
+			var ids []string
+			if ids, err = toStringList(key, val); err == nil {
+				for _, id := range ids {
+					if !isUSBId(id) {
+						return nil, errors.New(fmt.Sprintf("the value %v in %v is not in the vendor:product format, e.g. 046d:0825", id, key))
+					}
+					req.USBDeviceIds = append(req.USBDeviceIds, strings.ToLower(id))
+				}
+			}
+		case HW_MIN_MEMORY:
+			var mem int64
+			if mem, err = toNonNegativeInt(key, val); err == nil {
+				req.MinMemoryMB = uint64(mem)
+			}
+		case HW_MIN_CPUS:
+			var cpus int64
+			if cpus, err = toNonNegativeInt(key, val); err == nil {
+				req.MinCPUs = int(cpus)
+			}
+		default:
+			glog.V(5).Infof(hwlogString(fmt.Sprintf("ignoring unknown matchHardware key %v", key)))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// Validate the matchHardware section of a service definition.
+func ValidateHardwareRequirement(matchHardware map[string]interface{}) error {
+	_, err := ParseHardwareRequirement(matchHardware)
+	return err
+}
+
+// HardwareMatcher evaluates service hardware requirements against the hardware of a node.
+type HardwareMatcher struct {
+	hw *HardwareInfo
+}
+
+func NewHardwareMatcher(hw *HardwareInfo) *HardwareMatcher {
+	if hw == nil {
+		hw = new(HardwareInfo)
+	}
+	return &HardwareMatcher{
+		hw: hw,
+	}
+}
+
+// Check the given matchHardware section against the node hardware. It returns false and the
+// reasons when the requirements are not met. Requirements on hardware that is unknown to the
+// matcher are considered to be met.
+func (m *HardwareMatcher) Match(matchHardware map[string]interface{}) (bool, string, error) {
+	req, err := ParseHardwareRequirement(matchHardware)
+	if err != nil {
+		return false, "", err
+	} else if req.IsEmpty() {
+		return true, "", nil
+	}
+
+	reasons := make([]string, 0, 5)
+
+	if req.MinMemoryMB > 0 && m.hw.MemoryMB != nil && *m.hw.MemoryMB < req.MinMemoryMB {
+		reasons = append(reasons, fmt.Sprintf("requires %v MB of memory, the node has %v MB", req.MinMemoryMB, *m.hw.MemoryMB))
+	}
+
+	if req.MinCPUs > 0 && m.hw.CPUs != nil && *m.hw.CPUs < req.MinCPUs {
+		reasons = append(reasons, fmt.Sprintf("requires %v CPUs, the node has %v", req.MinCPUs, *m.hw.CPUs))
+	}
+
+	if m.hw.Devices != nil {
+		for _, dev := range req.Devices {
+			if !matchAny(dev, m.hw.Devices) {
+				reasons = append(reasons, fmt.Sprintf("requires device %v which is not found on the node", dev))
+			}
+		}
+	}
+
+	if m.hw.CPUFlags != nil {
+		for _, flag := range req.CPUFlags {
+			if !containsString(flag, m.hw.CPUFlags) {
+				reasons = append(reasons, fmt.Sprintf("requires CPU flag %v which is not supported by the node", flag))
+			}
+		}
+	}
+
+	if m.hw.USBDeviceIds != nil {
+		for _, id := range req.USBDeviceIds {
+			if !containsString(id, m.hw.USBDeviceIds) {
+				reasons = append(reasons, fmt.Sprintf("requires USB device %v which is not attached to the node", id))
+			}
+		}
+	}
+
+	if len(reasons) != 0 {
+		return false, strings.Join(reasons, ", "), nil
+	}
+	return true, "", nil
+}
+
+// Returns true if the device pattern matches any of the given devices.
+func matchAny(pattern string, devices []string) bool {
+	for _, dev := range devices {
+		if dev == pattern {
+			return true
+		} else if matched, err := filepath.Match(pattern, dev); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(s string, list []string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isUSBId(id string) bool {
+	parts := strings.Split(id, ":")
+	if len(parts) != 2 {
+		return false
+	}
+	for _, p := range parts {
+		if len(p) != 4 {
+			return false
+		}
+		for _, c := range strings.ToLower(p) {
+			if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func toStringList(key string, val interface{}) ([]string, error) {
+	switch v := val.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); !ok {
+				return nil, errors.New(fmt.Sprintf("the value of %v must be a list of strings, found %v (%T)", key, item, item))
+			} else {
+				list = append(list, s)
+			}
+		}
+		return list, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("the value of %v must be a list of strings, found %v (%T)", key, val, val))
+	}
+}
+
+func toNonNegativeInt(key string, val interface{}) (int64, error) {
+	var n int64
+	switch v := val.(type) {
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, errors.New(fmt.Sprintf("the value of %v must be an integer, found %v", key, v))
+		}
+		n = int64(v)
+	case int:
+		n = int64(v)
+	case int64:
+		n = v
+	default:
+		return 0, errors.New(fmt.Sprintf("the value of %v must be an integer, found %v (%T)", key, val, val))
+	}
+	if n < 0 {
+		return 0, errors.New(fmt.Sprintf("the value of %v cannot be negative, found %v", key, n))
+	}
+	return n, nil
+}
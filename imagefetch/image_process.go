@@ -12,6 +12,7 @@ import (
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
 	"strings"
+	"time"
 )
 
 type ImageFetchWorker struct {
@@ -221,7 +222,14 @@ func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, d
 	// Note: we don't want to make this a fallback option, it's a potential security vector
 	glog.V(3).Infof("Using Docker pull mechanism to retrieve and load Docker images into local registry")
 
+	start := time.Now()
 	fetchErr := pullImageFromRepos(cfg.Edge, dockerAuthConfigurations, client, &skipCheckFn, deploymentDesc)
+	if fetchErr != nil {
+		ImageFetchFailures.Inc()
+		ImageFetchDuration.Observe(time.Since(start).Seconds(), "failure")
+	} else {
+		ImageFetchDuration.Observe(time.Since(start).Seconds(), "success")
+	}
 	return fetchErr
 }
 
@@ -0,0 +1,13 @@
+package imagefetch
+
+import (
+	"github.com/open-horizon/anax/metrics"
+)
+
+// Image fetch metrics, each fetch pulls all the images in a service's deployment description.
+var ImageFetchDuration = metrics.NewHistogramVec("anax_image_fetch_duration_seconds",
+	"The time taken to fetch the container images of a service, by result (success or failure).",
+	[]float64{1, 5, 10, 30, 60, 120, 300, 600, 1200}, "result")
+
+var ImageFetchFailures = metrics.NewCounterVec("anax_image_fetch_failures_total",
+	"The number of container image fetches that failed.")
@@ -13,9 +13,13 @@ import (
 	apiv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
 	apiv1beta1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamic "k8s.io/client-go/dynamic"
+	"strings"
 	"time"
 )
 
@@ -119,7 +123,114 @@ func sortAPIObjects(allObjects []APIObjects, customResource *unstructured.Unstru
 			} else {
 				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: custom resource definition object has unrecognized type %T: %v", obj.Object, obj.Object)))
 			}
-
+		case K8S_CLUSTER_ROLE_TYPE:
+			if typedClusterRole, ok := obj.Object.(*rbacv1.ClusterRole); ok {
+				newClusterRole := ClusterRoleRbacV1{ClusterRoleObject: typedClusterRole}
+				if newClusterRole.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes cluster role object %s.", newClusterRole.Name())))
+					objMap[K8S_CLUSTER_ROLE_TYPE] = append(objMap[K8S_CLUSTER_ROLE_TYPE], newClusterRole)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: cluster role object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: cluster role object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
+		case K8S_CLUSTER_ROLEBINDING_TYPE:
+			if typedClusterRoleBinding, ok := obj.Object.(*rbacv1.ClusterRoleBinding); ok {
+				newClusterRolebinding := ClusterRolebindingRbacV1{ClusterRolebindingObject: typedClusterRoleBinding}
+				if newClusterRolebinding.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes cluster rolebinding object %s.", newClusterRolebinding.Name())))
+					objMap[K8S_CLUSTER_ROLEBINDING_TYPE] = append(objMap[K8S_CLUSTER_ROLEBINDING_TYPE], newClusterRolebinding)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: cluster rolebinding object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: cluster rolebinding object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
+		case K8S_SERVICE_TYPE:
+			if typedService, ok := obj.Object.(*corev1.Service); ok {
+				newService := ServiceCoreV1{ServiceObject: typedService}
+				if newService.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes service object %s.", newService.Name())))
+					objMap[K8S_SERVICE_TYPE] = append(objMap[K8S_SERVICE_TYPE], newService)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: service object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: service object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
+		case K8S_CONFIGMAP_TYPE:
+			if typedConfigMap, ok := obj.Object.(*corev1.ConfigMap); ok {
+				newConfigMap := ConfigMapCoreV1{ConfigMapObject: typedConfigMap}
+				if newConfigMap.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes config map object %s.", newConfigMap.Name())))
+					objMap[K8S_CONFIGMAP_TYPE] = append(objMap[K8S_CONFIGMAP_TYPE], newConfigMap)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: config map object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: config map object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
+		case K8S_SECRET_TYPE:
+			if typedSecret, ok := obj.Object.(*corev1.Secret); ok {
+				newSecret := SecretCoreV1{SecretObject: typedSecret}
+				if newSecret.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes secret object %s.", newSecret.Name())))
+					objMap[K8S_SECRET_TYPE] = append(objMap[K8S_SECRET_TYPE], newSecret)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: secret object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: secret object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
+		case K8S_STATEFULSET_TYPE:
+			if typedStatefulSet, ok := obj.Object.(*appsv1.StatefulSet); ok {
+				var err error
+				if namespace, err = checkObjectNamespace(namespace, typedStatefulSet.ObjectMeta.Namespace); err != nil {
+					return objMap, namespace, err
+				}
+				newStatefulSet := StatefulSetAppsV1{StatefulSetObject: typedStatefulSet}
+				if newStatefulSet.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes statefulset object %s.", newStatefulSet.Name())))
+					objMap[K8S_STATEFULSET_TYPE] = append(objMap[K8S_STATEFULSET_TYPE], newStatefulSet)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: statefulset object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: statefulset object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
+		case K8S_DAEMONSET_TYPE:
+			if typedDaemonSet, ok := obj.Object.(*appsv1.DaemonSet); ok {
+				var err error
+				if namespace, err = checkObjectNamespace(namespace, typedDaemonSet.ObjectMeta.Namespace); err != nil {
+					return objMap, namespace, err
+				}
+				newDaemonSet := DaemonSetAppsV1{DaemonSetObject: typedDaemonSet}
+				if newDaemonSet.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes daemonset object %s.", newDaemonSet.Name())))
+					objMap[K8S_DAEMONSET_TYPE] = append(objMap[K8S_DAEMONSET_TYPE], newDaemonSet)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: daemonset object must have a name in its metadata section.")))
+				}
+			} else {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: daemonset object has unrecognized type %T: %v", obj.Object, obj.Object)))
+			}
+		default:
+			// Objects without a typed handler are installed with the dynamic client.
+			if typedObj, ok := obj.Object.(runtime.Object); !ok {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: %v object has unrecognized type %T: %v", obj.Type.Kind, obj.Object, obj.Object)))
+			} else if unstructObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typedObj); err != nil {
+				return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: unable to convert %v object to unstructured: %v", obj.Type.Kind, err)))
+			} else {
+				newObj := UnstructuredObject{Object: &unstructured.Unstructured{Object: unstructObj}}
+				newObj.Object.SetGroupVersionKind(*obj.Type)
+				if newObj.Name() != "" {
+					glog.V(4).Infof(kwlog(fmt.Sprintf("Found kubernetes %v object %s.", obj.Type.Kind, newObj.Name())))
+					objMap[K8S_UNSTRUCTURED_TYPE] = append(objMap[K8S_UNSTRUCTURED_TYPE], newObj)
+				} else {
+					return objMap, namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: %v object must have a name in its metadata section.", obj.Type.Kind)))
+				}
+			}
 		}
 
 	}
@@ -130,6 +241,16 @@ func sortAPIObjects(allObjects []APIObjects, customResource *unstructured.Unstru
 	return objMap, namespace, nil
 }
 
+// Returns the namespace for the operator after checking that an object's namespace matches the namespace of the other objects.
+func checkObjectNamespace(namespace string, objNamespace string) (string, error) {
+	if objNamespace == "" || objNamespace == namespace {
+		return namespace, nil
+	} else if namespace == "" {
+		return objNamespace, nil
+	}
+	return namespace, fmt.Errorf(kwlog(fmt.Sprintf("Error: multiple namespaces specified in operator: %s and %s", namespace, objNamespace)))
+}
+
 //----------------Namespace----------------
 
 type NamespaceCoreV1 struct {
@@ -339,6 +460,389 @@ func (d DeploymentAppsV1) Name() string {
 	return d.DeploymentObject.ObjectMeta.Name
 }
 
+//----------------ClusterRole----------------
+
+type ClusterRoleRbacV1 struct {
+	ClusterRoleObject *rbacv1.ClusterRole
+}
+
+// Create the cluster role, or update the one created before for the same namespace. A cluster role with the same name
+// that was not created by the agent for the namespace is left alone and the install fails.
+func (cr ClusterRoleRbacV1) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating cluster role %v", cr)))
+	obj := cr.ClusterRoleObject.DeepCopy()
+	setOwnerLabel(&obj.ObjectMeta, namespace)
+
+	_, err := c.Client.RbacV1().ClusterRoles().Create(obj)
+	if err != nil && errors.IsAlreadyExists(err) {
+		var existing *rbacv1.ClusterRole
+		if existing, err = c.Client.RbacV1().ClusterRoles().Get(cr.Name(), metav1.GetOptions{}); err == nil {
+			if !isOwnedBy(existing.ObjectMeta, namespace) {
+				return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the cluster role: cluster role %v already exists and was not created for namespace %v", cr.Name(), namespace)))
+			}
+			obj.ObjectMeta.ResourceVersion = existing.ObjectMeta.ResourceVersion
+			_, err = c.Client.RbacV1().ClusterRoles().Update(obj)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the cluster role: %v", err)))
+	}
+	return nil
+}
+
+// Delete the cluster role, if it was created by the agent for the namespace.
+func (cr ClusterRoleRbacV1) Uninstall(c KubeClient, namespace string) {
+	if existing, err := c.Client.RbacV1().ClusterRoles().Get(cr.Name(), metav1.GetOptions{}); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to get cluster role %s. Error: %v", cr.Name(), err)))
+		return
+	} else if !isOwnedBy(existing.ObjectMeta, namespace) {
+		glog.Warningf(kwlog(fmt.Sprintf("not deleting cluster role %s, it was not created for namespace %v", cr.Name(), namespace)))
+		return
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting cluster role %s", cr.Name())))
+	err := c.Client.RbacV1().ClusterRoles().Delete(cr.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete cluster role %s. Error: %v", cr.Name(), err)))
+	}
+}
+
+func (cr ClusterRoleRbacV1) Status(c KubeClient, namespace string) (interface{}, error) {
+	return nil, nil
+}
+
+func (cr ClusterRoleRbacV1) Name() string {
+	return cr.ClusterRoleObject.ObjectMeta.Name
+}
+
+//----------------ClusterRolebinding----------------
+
+type ClusterRolebindingRbacV1 struct {
+	ClusterRolebindingObject *rbacv1.ClusterRoleBinding
+}
+
+// Create the cluster role binding, or update the one created before for the same namespace. A cluster role binding with
+// the same name that was not created by the agent for the namespace is left alone and the install fails.
+func (crb ClusterRolebindingRbacV1) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating cluster rolebinding %v", crb)))
+	obj := crb.ClusterRolebindingObject.DeepCopy()
+	setOwnerLabel(&obj.ObjectMeta, namespace)
+
+	_, err := c.Client.RbacV1().ClusterRoleBindings().Create(obj)
+	if err != nil && errors.IsAlreadyExists(err) {
+		var existing *rbacv1.ClusterRoleBinding
+		if existing, err = c.Client.RbacV1().ClusterRoleBindings().Get(crb.Name(), metav1.GetOptions{}); err == nil {
+			if !isOwnedBy(existing.ObjectMeta, namespace) {
+				return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the cluster rolebinding: cluster rolebinding %v already exists and was not created for namespace %v", crb.Name(), namespace)))
+			}
+			obj.ObjectMeta.ResourceVersion = existing.ObjectMeta.ResourceVersion
+			_, err = c.Client.RbacV1().ClusterRoleBindings().Update(obj)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the cluster rolebinding: %v", err)))
+	}
+	return nil
+}
+
+// Delete the cluster role binding, if it was created by the agent for the namespace.
+func (crb ClusterRolebindingRbacV1) Uninstall(c KubeClient, namespace string) {
+	if existing, err := c.Client.RbacV1().ClusterRoleBindings().Get(crb.Name(), metav1.GetOptions{}); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to get cluster role binding %s. Error: %v", crb.Name(), err)))
+		return
+	} else if !isOwnedBy(existing.ObjectMeta, namespace) {
+		glog.Warningf(kwlog(fmt.Sprintf("not deleting cluster role binding %s, it was not created for namespace %v", crb.Name(), namespace)))
+		return
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting cluster role binding %s", crb.Name())))
+	err := c.Client.RbacV1().ClusterRoleBindings().Delete(crb.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete cluster role binding %s. Error: %v", crb.Name(), err)))
+	}
+}
+
+func (crb ClusterRolebindingRbacV1) Status(c KubeClient, namespace string) (interface{}, error) {
+	return nil, nil
+}
+
+func (crb ClusterRolebindingRbacV1) Name() string {
+	return crb.ClusterRolebindingObject.ObjectMeta.Name
+}
+
+// Mark a cluster scoped object as created by the agent for the namespace.
+func setOwnerLabel(meta *metav1.ObjectMeta, namespace string) {
+	if meta.Labels == nil {
+		meta.Labels = make(map[string]string)
+	}
+	meta.Labels[OWNER_NAMESPACE_LABEL] = namespace
+}
+
+func isOwnedBy(meta metav1.ObjectMeta, namespace string) bool {
+	return meta.Labels[OWNER_NAMESPACE_LABEL] == namespace
+}
+
+//----------------Service----------------
+
+type ServiceCoreV1 struct {
+	ServiceObject *corev1.Service
+}
+
+func (s ServiceCoreV1) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating service %v", s)))
+	_, err := c.Client.CoreV1().Services(namespace).Create(s.ServiceObject)
+	if err != nil && errors.IsAlreadyExists(err) {
+		s.Uninstall(c, namespace)
+		_, err = c.Client.CoreV1().Services(namespace).Create(s.ServiceObject)
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the service: %v", err)))
+	}
+	return nil
+}
+
+func (s ServiceCoreV1) Uninstall(c KubeClient, namespace string) {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting service %s", s.Name())))
+	err := c.Client.CoreV1().Services(namespace).Delete(s.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete service %s. Error: %v", s.Name(), err)))
+	}
+}
+
+// Status is the status of the service, which includes the load balancer ingress points
+func (s ServiceCoreV1) Status(c KubeClient, namespace string) (interface{}, error) {
+	svc, err := c.Client.CoreV1().Services(namespace).Get(s.Name(), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting service status: %v", err)))
+	}
+	return svc.Status, nil
+}
+
+func (s ServiceCoreV1) Name() string {
+	return s.ServiceObject.ObjectMeta.Name
+}
+
+//----------------ConfigMap----------------
+
+type ConfigMapCoreV1 struct {
+	ConfigMapObject *corev1.ConfigMap
+}
+
+func (cm ConfigMapCoreV1) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating config map %v", cm.Name())))
+	_, err := c.Client.CoreV1().ConfigMaps(namespace).Create(cm.ConfigMapObject)
+	if err != nil && errors.IsAlreadyExists(err) {
+		cm.Uninstall(c, namespace)
+		_, err = c.Client.CoreV1().ConfigMaps(namespace).Create(cm.ConfigMapObject)
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the config map: %v", err)))
+	}
+	return nil
+}
+
+func (cm ConfigMapCoreV1) Uninstall(c KubeClient, namespace string) {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting config map %s", cm.Name())))
+	err := c.Client.CoreV1().ConfigMaps(namespace).Delete(cm.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete config map %s. Error: %v", cm.Name(), err)))
+	}
+}
+
+func (cm ConfigMapCoreV1) Status(c KubeClient, namespace string) (interface{}, error) {
+	return nil, nil
+}
+
+func (cm ConfigMapCoreV1) Name() string {
+	return cm.ConfigMapObject.ObjectMeta.Name
+}
+
+//----------------Secret----------------
+// The secret data is not logged.
+
+type SecretCoreV1 struct {
+	SecretObject *corev1.Secret
+}
+
+func (s SecretCoreV1) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating secret %v", s.Name())))
+	_, err := c.Client.CoreV1().Secrets(namespace).Create(s.SecretObject)
+	if err != nil && errors.IsAlreadyExists(err) {
+		s.Uninstall(c, namespace)
+		_, err = c.Client.CoreV1().Secrets(namespace).Create(s.SecretObject)
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the secret %s: %v", s.Name(), err)))
+	}
+	return nil
+}
+
+func (s SecretCoreV1) Uninstall(c KubeClient, namespace string) {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting secret %s", s.Name())))
+	err := c.Client.CoreV1().Secrets(namespace).Delete(s.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete secret %s. Error: %v", s.Name(), err)))
+	}
+}
+
+func (s SecretCoreV1) Status(c KubeClient, namespace string) (interface{}, error) {
+	return nil, nil
+}
+
+func (s SecretCoreV1) Name() string {
+	return s.SecretObject.ObjectMeta.Name
+}
+
+//----------------StatefulSet----------------
+
+type StatefulSetAppsV1 struct {
+	StatefulSetObject *appsv1.StatefulSet
+}
+
+func (ss StatefulSetAppsV1) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating statefulset %v", ss)))
+	_, err := c.Client.AppsV1().StatefulSets(namespace).Create(ss.StatefulSetObject)
+	if err != nil && errors.IsAlreadyExists(err) {
+		ss.Uninstall(c, namespace)
+		_, err = c.Client.AppsV1().StatefulSets(namespace).Create(ss.StatefulSetObject)
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the statefulset: %v", err)))
+	}
+	return nil
+}
+
+func (ss StatefulSetAppsV1) Uninstall(c KubeClient, namespace string) {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting statefulset %s", ss.Name())))
+	err := c.Client.AppsV1().StatefulSets(namespace).Delete(ss.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete statefulset %s. Error: %v", ss.Name(), err)))
+	}
+}
+
+// Status is the status of the statefulset, which includes the number of ready replicas
+func (ss StatefulSetAppsV1) Status(c KubeClient, namespace string) (interface{}, error) {
+	statefulSet, err := c.Client.AppsV1().StatefulSets(namespace).Get(ss.Name(), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting statefulset status: %v", err)))
+	}
+	return statefulSet.Status, nil
+}
+
+func (ss StatefulSetAppsV1) Name() string {
+	return ss.StatefulSetObject.ObjectMeta.Name
+}
+
+//----------------DaemonSet----------------
+
+type DaemonSetAppsV1 struct {
+	DaemonSetObject *appsv1.DaemonSet
+}
+
+func (ds DaemonSetAppsV1) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating daemonset %v", ds)))
+	_, err := c.Client.AppsV1().DaemonSets(namespace).Create(ds.DaemonSetObject)
+	if err != nil && errors.IsAlreadyExists(err) {
+		ds.Uninstall(c, namespace)
+		_, err = c.Client.AppsV1().DaemonSets(namespace).Create(ds.DaemonSetObject)
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the daemonset: %v", err)))
+	}
+	return nil
+}
+
+func (ds DaemonSetAppsV1) Uninstall(c KubeClient, namespace string) {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting daemonset %s", ds.Name())))
+	err := c.Client.AppsV1().DaemonSets(namespace).Delete(ds.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete daemonset %s. Error: %v", ds.Name(), err)))
+	}
+}
+
+// Status is the status of the daemonset, which includes the number of nodes running the daemon pod
+func (ds DaemonSetAppsV1) Status(c KubeClient, namespace string) (interface{}, error) {
+	daemonSet, err := c.Client.AppsV1().DaemonSets(namespace).Get(ds.Name(), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting daemonset status: %v", err)))
+	}
+	return daemonSet.Status, nil
+}
+
+func (ds DaemonSetAppsV1) Name() string {
+	return ds.DaemonSetObject.ObjectMeta.Name
+}
+
+//----------------Unstructured----------------
+// Any kind of object without a typed handler is installed with the dynamic client. The resource name and scope of the
+// kind are looked up in the cluster.
+
+type UnstructuredObject struct {
+	Object *unstructured.Unstructured
+}
+
+func (u UnstructuredObject) Install(c KubeClient, namespace string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating %v %v", u.Object.GetKind(), u.Name())))
+	rc := u.resourceClient(c, namespace)
+	_, err := rc.Create(u.Object, metav1.CreateOptions{})
+	if err != nil && errors.IsAlreadyExists(err) {
+		u.Uninstall(c, namespace)
+		_, err = rc.Create(u.Object, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf(kwlog(fmt.Sprintf("Error creating the %v %v: %v", u.Object.GetKind(), u.Name(), err)))
+	}
+	return nil
+}
+
+func (u UnstructuredObject) Uninstall(c KubeClient, namespace string) {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting %v %s", u.Object.GetKind(), u.Name())))
+	err := u.resourceClient(c, namespace).Delete(u.Name(), &metav1.DeleteOptions{})
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete %v %s. Error: %v", u.Object.GetKind(), u.Name(), err)))
+	}
+}
+
+// Status is the status section of the object, if it has one
+func (u UnstructuredObject) Status(c KubeClient, namespace string) (interface{}, error) {
+	res, err := u.resourceClient(c, namespace).Get(u.Name(), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting %v status: %v", u.Object.GetKind(), err)))
+	}
+	return res.Object["status"], nil
+}
+
+func (u UnstructuredObject) Name() string {
+	return u.Object.GetName()
+}
+
+// Returns the dynamic client for the kind of object. Cluster scoped kinds are not created in the namespace.
+func (u UnstructuredObject) resourceClient(c KubeClient, namespace string) dynamic.ResourceInterface {
+	gvr, namespaced := u.gvr(c)
+	if namespaced {
+		return c.DynClient.Resource(gvr).Namespace(namespace)
+	}
+	return c.DynClient.Resource(gvr)
+}
+
+// gvr is the group version resource of the object's kind. If the cluster does not know the kind, the resource name is
+// guessed from the kind and the object is assumed to be namespaced.
+func (u UnstructuredObject) gvr(c KubeClient) (schema.GroupVersionResource, bool) {
+	gvk := u.Object.GroupVersionKind()
+	if resList, err := c.Client.Discovery().ServerResourcesForGroupVersion(gvk.GroupVersion().String()); err != nil {
+		glog.Warningf(kwlog(fmt.Sprintf("unable to discover the resources for %v, error: %v", gvk.GroupVersion(), err)))
+	} else {
+		for _, res := range resList.APIResources {
+			// Skip the subresources, e.g. deployments/status
+			if res.Kind == gvk.Kind && !strings.Contains(res.Name, "/") {
+				return gvk.GroupVersion().WithResource(res.Name), res.Namespaced
+			}
+		}
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	return gvr, true
+}
+
 //----------------CRD & CR----------------
 // A new version requires a new CRD client type and adding the version scheme in getK8sObjectFromYaml
 
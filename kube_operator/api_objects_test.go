@@ -0,0 +1,177 @@
+// +build unit
+
+package kube_operator
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func gvk(group, version, kind string) *schema.GroupVersionKind {
+	return &schema.GroupVersionKind{Group: group, Version: version, Kind: kind}
+}
+
+func getTestObjects() []APIObjects {
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name}
+	}
+	return []APIObjects{
+		{Type: gvk("", "v1", K8S_SERVICE_TYPE), Object: &corev1.Service{ObjectMeta: meta("svc")}},
+		{Type: gvk("", "v1", K8S_CONFIGMAP_TYPE), Object: &corev1.ConfigMap{ObjectMeta: meta("cm"), Data: map[string]string{"a": "b"}}},
+		{Type: gvk("", "v1", K8S_SECRET_TYPE), Object: &corev1.Secret{ObjectMeta: meta("secret")}},
+		{Type: gvk("rbac.authorization.k8s.io", "v1", K8S_CLUSTER_ROLE_TYPE), Object: &rbacv1.ClusterRole{ObjectMeta: meta("cr")}},
+		{Type: gvk("rbac.authorization.k8s.io", "v1", K8S_CLUSTER_ROLEBINDING_TYPE), Object: &rbacv1.ClusterRoleBinding{ObjectMeta: meta("crb")}},
+		{Type: gvk("apps", "v1", K8S_STATEFULSET_TYPE), Object: &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "ss", Namespace: "ns1"}}},
+		{Type: gvk("apps", "v1", K8S_DAEMONSET_TYPE), Object: &appsv1.DaemonSet{ObjectMeta: meta("ds")}},
+		{Type: gvk("networking.k8s.io", "v1", "NetworkPolicy"), Object: &networkingv1.NetworkPolicy{ObjectMeta: meta("np")}},
+	}
+}
+
+func Test_sortAPIObjects(t *testing.T) {
+	objMap, namespace, err := sortAPIObjects(getTestObjects(), nil, nil, "agid", 0)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if namespace != "ns1" {
+		t.Errorf("expected namespace ns1, got %v", namespace)
+	}
+
+	for _, kind := range []string{K8S_SERVICE_TYPE, K8S_CONFIGMAP_TYPE, K8S_SECRET_TYPE, K8S_CLUSTER_ROLE_TYPE, K8S_CLUSTER_ROLEBINDING_TYPE, K8S_STATEFULSET_TYPE, K8S_DAEMONSET_TYPE, K8S_UNSTRUCTURED_TYPE} {
+		if len(objMap[kind]) != 1 {
+			t.Errorf("expected 1 %v object, got %v", kind, objMap[kind])
+		}
+	}
+
+	if u, ok := objMap[K8S_UNSTRUCTURED_TYPE][0].(UnstructuredObject); !ok {
+		t.Errorf("expected an unstructured object, got %T", objMap[K8S_UNSTRUCTURED_TYPE][0])
+	} else if u.Name() != "np" || u.Object.GetKind() != "NetworkPolicy" || u.Object.GetAPIVersion() != "networking.k8s.io/v1" {
+		t.Errorf("unexpected unstructured object %v", u.Object)
+	}
+
+	// An object in a different namespace is an error.
+	objs := append(getTestObjects(), APIObjects{Type: gvk("apps", "v1", K8S_DAEMONSET_TYPE), Object: &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "ds2", Namespace: "ns2"}}})
+	if _, _, err := sortAPIObjects(objs, nil, nil, "agid", 0); err == nil {
+		t.Errorf("expected an error for multiple namespaces")
+	}
+
+	// Objects must be named.
+	objs = []APIObjects{{Type: gvk("", "v1", K8S_SECRET_TYPE), Object: &corev1.Secret{}}}
+	if _, _, err := sortAPIObjects(objs, nil, nil, "agid", 0); err == nil {
+		t.Errorf("expected an error for an unnamed secret")
+	}
+	objs = []APIObjects{{Type: gvk("networking.k8s.io", "v1", "NetworkPolicy"), Object: &networkingv1.NetworkPolicy{}}}
+	if _, _, err := sortAPIObjects(objs, nil, nil, "agid", 0); err == nil {
+		t.Errorf("expected an error for an unnamed network policy")
+	}
+}
+
+func Test_InstallUninstall(t *testing.T) {
+	c := KubeClient{Client: fake.NewSimpleClientset(), DynClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())}
+
+	objMap, namespace, err := sortAPIObjects(getTestObjects(), nil, nil, "agid", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, kind := range installOrder {
+		for _, obj := range objMap[kind] {
+			if err := obj.Install(c, namespace); err != nil {
+				t.Errorf("unexpected error installing %v %v: %v", kind, obj.Name(), err)
+			}
+			// Installing an object that already exists replaces it.
+			if err := obj.Install(c, namespace); err != nil {
+				t.Errorf("unexpected error reinstalling %v %v: %v", kind, obj.Name(), err)
+			}
+			if _, err := obj.Status(c, namespace); err != nil {
+				t.Errorf("unexpected error getting the status of %v %v: %v", kind, obj.Name(), err)
+			}
+		}
+	}
+
+	if _, err := c.Client.CoreV1().ConfigMaps(namespace).Get("cm", metav1.GetOptions{}); err != nil {
+		t.Errorf("config map was not created: %v", err)
+	}
+	if _, err := c.Client.RbacV1().ClusterRoles().Get("cr", metav1.GetOptions{}); err != nil {
+		t.Errorf("cluster role was not created: %v", err)
+	}
+	npGVR := schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}
+	if _, err := c.DynClient.Resource(npGVR).Namespace(namespace).Get("np", metav1.GetOptions{}); err != nil {
+		t.Errorf("network policy was not created: %v", err)
+	}
+
+	for _, kind := range installOrder {
+		for _, obj := range objMap[kind] {
+			obj.Uninstall(c, namespace)
+			if _, err := obj.Status(c, namespace); err == nil && (kind == K8S_SERVICE_TYPE || kind == K8S_STATEFULSET_TYPE || kind == K8S_DAEMONSET_TYPE || kind == K8S_UNSTRUCTURED_TYPE) {
+				t.Errorf("expected an error getting the status of uninstalled %v %v", kind, obj.Name())
+			}
+		}
+	}
+
+	if _, err := c.Client.CoreV1().Secrets(namespace).Get("secret", metav1.GetOptions{}); err == nil {
+		t.Errorf("secret was not deleted")
+	}
+	if _, err := c.DynClient.Resource(npGVR).Namespace(namespace).Get("np", metav1.GetOptions{}); err == nil {
+		t.Errorf("network policy was not deleted")
+	}
+}
+
+func Test_ClusterRole_ownership(t *testing.T) {
+	// A cluster role and binding that belong to the cluster, and a cluster role created for another namespace.
+	c := KubeClient{Client: fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "admin"}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "admin-binding"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{OWNER_NAMESPACE_LABEL: "otherns"}}},
+	)}
+
+	admin := ClusterRoleRbacV1{ClusterRoleObject: &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "admin"}}}
+	adminBinding := ClusterRolebindingRbacV1{ClusterRolebindingObject: &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "admin-binding"}}}
+	other := ClusterRoleRbacV1{ClusterRoleObject: &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "other"}}}
+	for _, obj := range []APIObjectInterface{admin, adminBinding, other} {
+		if err := obj.Install(c, "myns"); err == nil {
+			t.Errorf("expected an error installing %v over an object the agent does not own", obj.Name())
+		}
+		obj.Uninstall(c, "myns")
+	}
+
+	if cr, err := c.Client.RbacV1().ClusterRoles().Get("admin", metav1.GetOptions{}); err != nil {
+		t.Errorf("the cluster role of the cluster should not be deleted: %v", err)
+	} else if _, ok := cr.Labels[OWNER_NAMESPACE_LABEL]; ok {
+		t.Errorf("the cluster role of the cluster should not be changed: %v", cr.Labels)
+	}
+	if _, err := c.Client.RbacV1().ClusterRoleBindings().Get("admin-binding", metav1.GetOptions{}); err != nil {
+		t.Errorf("the cluster role binding of the cluster should not be deleted: %v", err)
+	}
+	if _, err := c.Client.RbacV1().ClusterRoles().Get("other", metav1.GetOptions{}); err != nil {
+		t.Errorf("the cluster role of the other namespace should not be deleted: %v", err)
+	}
+
+	// The cluster role the agent creates is labeled, updated when it is installed again, and deleted.
+	mine := ClusterRoleRbacV1{ClusterRoleObject: &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "mine"}}}
+	if err := mine.Install(c, "myns"); err != nil {
+		t.Errorf("unexpected error installing: %v", err)
+	}
+	mine.ClusterRoleObject.Rules = []rbacv1.PolicyRule{{Verbs: []string{"get"}, Resources: []string{"pods"}, APIGroups: []string{""}}}
+	if err := mine.Install(c, "myns"); err != nil {
+		t.Errorf("unexpected error reinstalling: %v", err)
+	}
+	if cr, err := c.Client.RbacV1().ClusterRoles().Get("mine", metav1.GetOptions{}); err != nil {
+		t.Errorf("the cluster role was not created: %v", err)
+	} else if cr.Labels[OWNER_NAMESPACE_LABEL] != "myns" || len(cr.Rules) != 1 {
+		t.Errorf("the cluster role should be labeled and updated: %+v", cr)
+	}
+	if mine.ClusterRoleObject.Labels != nil {
+		t.Errorf("the object from the deployment should not be changed: %v", mine.ClusterRoleObject.Labels)
+	}
+	mine.Uninstall(c, "myns")
+	if _, err := c.Client.RbacV1().ClusterRoles().Get("mine", metav1.GetOptions{}); err == nil {
+		t.Errorf("the cluster role was not deleted")
+	}
+}
@@ -3,6 +3,7 @@ package kube_operator
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/golang/glog"
@@ -14,6 +15,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1scheme "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	v1beta1scheme "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -33,14 +35,45 @@ const (
 	// Variable that contains the name of the config map
 	HZN_ENV_KEY = "HZN_ENV_VARS"
 
-	K8S_ROLE_TYPE           = "Role"
-	K8S_ROLEBINDING_TYPE    = "RoleBinding"
-	K8S_DEPLOYMENT_TYPE     = "Deployment"
-	K8S_SERVICEACCOUNT_TYPE = "ServiceAccount"
-	K8S_CRD_TYPE            = "CustomResourceDefinition"
-	K8S_NAMESPACE_TYPE      = "Namespace"
+	K8S_ROLE_TYPE                = "Role"
+	K8S_ROLEBINDING_TYPE         = "RoleBinding"
+	K8S_CLUSTER_ROLE_TYPE        = "ClusterRole"
+	K8S_CLUSTER_ROLEBINDING_TYPE = "ClusterRoleBinding"
+	K8S_DEPLOYMENT_TYPE          = "Deployment"
+	K8S_STATEFULSET_TYPE         = "StatefulSet"
+	K8S_DAEMONSET_TYPE           = "DaemonSet"
+	K8S_SERVICE_TYPE             = "Service"
+	K8S_CONFIGMAP_TYPE           = "ConfigMap"
+	K8S_SECRET_TYPE              = "Secret"
+	K8S_SERVICEACCOUNT_TYPE      = "ServiceAccount"
+	K8S_CRD_TYPE                 = "CustomResourceDefinition"
+	K8S_NAMESPACE_TYPE           = "Namespace"
+	K8S_UNSTRUCTURED_TYPE        = "Unstructured" // Any kind of object without a typed handler, it is installed with the dynamic client
 )
 
+// The label on the cluster scoped objects created by the agent. Its value is the namespace of the operator they were
+// created for. The agent only updates and deletes the cluster scoped objects with this label, so that it does not
+// change the cluster roles and bindings that belong to the cluster or to another operator.
+const OWNER_NAMESPACE_LABEL = "openhorizon.org/owner-namespace"
+
+// The order in which the kinds of objects are installed. They are uninstalled in the reverse order. The custom resource
+// definitions are installed last because creating the custom resource starts the operator.
+var installOrder = []string{
+	K8S_CLUSTER_ROLE_TYPE,
+	K8S_CLUSTER_ROLEBINDING_TYPE,
+	K8S_ROLE_TYPE,
+	K8S_ROLEBINDING_TYPE,
+	K8S_SERVICEACCOUNT_TYPE,
+	K8S_SECRET_TYPE,
+	K8S_CONFIGMAP_TYPE,
+	K8S_UNSTRUCTURED_TYPE,
+	K8S_SERVICE_TYPE,
+	K8S_DEPLOYMENT_TYPE,
+	K8S_STATEFULSET_TYPE,
+	K8S_DAEMONSET_TYPE,
+	K8S_CRD_TYPE,
+}
+
 // Intermediate state for the objects used for k8s api objects that haven't had their exact type asserted yet
 type APIObjects struct {
 	Type   *schema.GroupVersionKind
@@ -53,9 +86,10 @@ type YamlFile struct {
 	Body   string
 }
 
-// Client to interact with all standard k8s objects
+// Client to interact with all standard k8s objects. The dynamic client is used for objects without a typed client.
 type KubeClient struct {
-	Client *kubernetes.Clientset
+	Client    kubernetes.Interface
+	DynClient dynamic.Interface
 }
 
 // KubeStatus contains the status of operator pods and a user-defined status object
@@ -71,12 +105,26 @@ type ContainerStatus struct {
 	State       string
 }
 
+// The resources used by one container of the operator pod, as reported by the metrics API.
+type ContainerMetrics struct {
+	Name          string
+	CPUMillicores int64
+	MemoryBytes   int64
+}
+
+// The metrics API is served by the metrics server, when it is installed in the cluster.
+var podMetricsResource = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+
 func NewKubeClient() (*KubeClient, error) {
 	clientset, err := cutil.NewKubeClient()
 	if err != nil {
 		return nil, err
 	}
-	return &KubeClient{Client: clientset}, nil
+	dynClient, err := NewDynamicKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return &KubeClient{Client: clientset, DynClient: dynClient}, nil
 }
 
 // NewDynamicKubeClient returns a kube client that interacts with unstructured.Unstructured type objects
@@ -106,39 +154,12 @@ func (c KubeClient) Install(tar string, envVars map[string]string, agId string,
 		nsDef.Install(c, namespace)
 	}
 
-	// Create the role types in the cluster
-	for _, roleDef := range apiObjMap[K8S_ROLE_TYPE] {
-		err = roleDef.Install(c, namespace)
-		if err != nil {
-			return err
-		}
-	}
-	// Create the rolebinding types in the cluster
-	for _, roleBindingDef := range apiObjMap[K8S_ROLEBINDING_TYPE] {
-		err = roleBindingDef.Install(c, namespace)
-		if err != nil {
-			return err
-		}
-	}
-	// Create the serviceaccount types in the cluster
-	for _, svcAcctDef := range apiObjMap[K8S_SERVICEACCOUNT_TYPE] {
-		err = svcAcctDef.Install(c, namespace)
-		if err != nil {
-			return err
-		}
-	}
-	// Create the deployment types in the cluster
-	for _, dep := range apiObjMap[K8S_DEPLOYMENT_TYPE] {
-		err = dep.Install(c, namespace)
-		if err != nil {
-			return err
-		}
-	}
-
-	for _, crd := range apiObjMap[K8S_CRD_TYPE] {
-		err := crd.Install(c, namespace)
-		if err != nil {
-			return err
+	// Create the rest of the objects in the cluster
+	for _, kind := range installOrder {
+		for _, obj := range apiObjMap[kind] {
+			if err := obj.Install(c, namespace); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -154,26 +175,13 @@ func (c KubeClient) Uninstall(tar string, agId string) error {
 		return err
 	}
 
-	for _, crd := range apiObjMap[K8S_CRD_TYPE] {
-		crd.Uninstall(c, namespace)
+	// Delete the objects from the cluster in the reverse order they were created
+	for ix := len(installOrder) - 1; ix >= 0; ix-- {
+		for _, obj := range apiObjMap[installOrder[ix]] {
+			obj.Uninstall(c, namespace)
+		}
 	}
 
-	// Delete the deployment types in the cluster
-	for _, dep := range apiObjMap[K8S_DEPLOYMENT_TYPE] {
-		dep.Uninstall(c, namespace)
-	}
-	// Delete the serviceaccount types in the cluster
-	for _, svcAcctDef := range apiObjMap[K8S_SERVICEACCOUNT_TYPE] {
-		svcAcctDef.Uninstall(c, namespace)
-	}
-	// Delete the rolebinding types in the cluster
-	for _, roleBindingDef := range apiObjMap[K8S_ROLEBINDING_TYPE] {
-		roleBindingDef.Uninstall(c, namespace)
-	}
-	// Delete the role types in the cluster
-	for _, roleDef := range apiObjMap[K8S_ROLE_TYPE] {
-		roleDef.Uninstall(c, namespace)
-	}
 	for _, namespaceDef := range apiObjMap[K8S_NAMESPACE_TYPE] {
 		namespaceDef.Uninstall(c, namespace)
 	}
@@ -238,6 +246,95 @@ func (c KubeClient) Status(tar string, agId string) ([]ContainerStatus, error) {
 	}
 }
 
+// PodMetrics returns the resources used by the containers of the operator's pods. It returns an error when the cluster
+// does not have a metrics server.
+func (c KubeClient) PodMetrics(tar string, agId string) ([]ContainerMetrics, error) {
+	apiObjMap, _, err := processDeployment(tar, map[string]string{}, agId, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(apiObjMap[K8S_DEPLOYMENT_TYPE]) < 1 {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: failed to find operator deployment object.")))
+	}
+	opName := apiObjMap[K8S_DEPLOYMENT_TYPE][0].Name()
+
+	metricsList, err := c.DynClient.Resource(podMetricsResource).Namespace(ANAX_NAMESPACE).List(metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", "name", opName)})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting the pod metrics for %v: %v", opName, err)))
+	}
+
+	metrics := []ContainerMetrics{}
+	for _, item := range metricsList.Items {
+		metrics = append(metrics, containerMetricsFromPodMetrics(item.Object)...)
+	}
+	return metrics, nil
+}
+
+// NewPodLogOptions returns the options for reading the log of a container. A negative tail reads the whole log, and a
+// since of 0 reads the log from the beginning.
+func NewPodLogOptions(container string, tail int64, since int64, follow bool, timestamps bool) *corev1.PodLogOptions {
+	opts := &corev1.PodLogOptions{Container: container, Follow: follow, Timestamps: timestamps}
+	if tail >= 0 {
+		opts.TailLines = &tail
+	}
+	if since > 0 {
+		sinceTime := metav1.Unix(since, 0)
+		opts.SinceTime = &sinceTime
+	}
+	return opts
+}
+
+// PodLogs returns a stream of the logs of a container in the operator's pod. The container can be empty when the pod
+// has only one container. The stream is closed when the context is cancelled.
+func (c KubeClient) PodLogs(ctx context.Context, tar string, agId string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	apiObjMap, _, err := processDeployment(tar, map[string]string{}, agId, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(apiObjMap[K8S_DEPLOYMENT_TYPE]) < 1 {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: failed to find operator deployment object.")))
+	}
+	opName := apiObjMap[K8S_DEPLOYMENT_TYPE][0].Name()
+
+	podList, err := c.Client.CoreV1().Pods(ANAX_NAMESPACE).List(metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", "name", opName)})
+	if err != nil {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error getting the pods for %v: %v", opName, err)))
+	} else if len(podList.Items) < 1 {
+		return nil, fmt.Errorf(kwlog(fmt.Sprintf("Error: there are no pods for %v", opName)))
+	}
+
+	return c.Client.CoreV1().Pods(ANAX_NAMESPACE).GetLogs(podList.Items[0].Name, opts).Context(ctx).Stream()
+}
+
+// Convert the containers section of a PodMetrics object to container metrics. Containers with usage values that
+// cannot be parsed report zero for that value.
+func containerMetricsFromPodMetrics(podMetrics map[string]interface{}) []ContainerMetrics {
+	metrics := []ContainerMetrics{}
+	containers, _, _ := unstructured.NestedSlice(podMetrics, "containers")
+	for _, c := range containers {
+		cMap, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cm := ContainerMetrics{}
+		cm.Name, _, _ = unstructured.NestedString(cMap, "name")
+		if cpu, found, _ := unstructured.NestedString(cMap, "usage", "cpu"); found {
+			if q, err := resource.ParseQuantity(cpu); err == nil {
+				cm.CPUMillicores = q.MilliValue()
+			}
+		}
+		if mem, found, _ := unstructured.NestedString(cMap, "usage", "memory"); found {
+			if q, err := resource.ParseQuantity(mem); err == nil {
+				cm.MemoryBytes = q.Value()
+			}
+		}
+		metrics = append(metrics, cm)
+	}
+	return metrics
+}
+
 // processDeployment takes the deployment string and converts it to a map with the k8s objects, the namespace to be used, and an error if one occurs
 func processDeployment(tar string, envVars map[string]string, agId string, crInstallTimeout int64) (map[string][]APIObjectInterface, string, error) {
 	// Read the yaml files from the commpressed tar files
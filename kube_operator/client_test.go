@@ -0,0 +1,27 @@
+// +build unit
+
+package kube_operator
+
+import (
+	"testing"
+)
+
+func Test_containerMetricsFromPodMetrics(t *testing.T) {
+	podMetrics := map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "operator", "usage": map[string]interface{}{"cpu": "250m", "memory": "64Mi"}},
+			map[string]interface{}{"name": "sidecar", "usage": map[string]interface{}{"cpu": "1", "memory": "bad"}},
+		},
+	}
+
+	metrics := containerMetricsFromPodMetrics(podMetrics)
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 container metrics, got %v", metrics)
+	}
+	if metrics[0].Name != "operator" || metrics[0].CPUMillicores != 250 || metrics[0].MemoryBytes != 64*1024*1024 {
+		t.Errorf("unexpected metrics for the operator container %+v", metrics[0])
+	}
+	if metrics[1].CPUMillicores != 1000 || metrics[1].MemoryBytes != 0 {
+		t.Errorf("unexpected metrics for the sidecar container %+v", metrics[1])
+	}
+}
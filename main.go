@@ -11,12 +11,14 @@ import (
 	_ "github.com/open-horizon/anax/agreementbot/persistence/bolt"
 	_ "github.com/open-horizon/anax/agreementbot/persistence/postgresql"
 	agbotSecretsImpl "github.com/open-horizon/anax/agreementbot/secrets"
+	_ "github.com/open-horizon/anax/agreementbot/secrets/filestore"
 	_ "github.com/open-horizon/anax/agreementbot/secrets/vault"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/changes"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/exchange"
+	_ "github.com/open-horizon/anax/exchange/mqtt"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
 	"github.com/open-horizon/anax/governance"
 	"github.com/open-horizon/anax/i18n"
@@ -63,6 +65,12 @@ func main() {
 		panic(err)
 	}
 	glog.V(2).Infof("Using config: %v", cfg.String())
+
+	// Run a maintenance command instead of the runtime if there are arguments after the flags.
+	if flag.NArg() != 0 {
+		runCommandAndExit(cfg, flag.Args())
+	}
+
 	glog.V(2).Infof("GOMAXPROCS: %v", runtime.GOMAXPROCS(-1))
 
 	// initialize the message printer for globalization, the anax will produce English messages.
@@ -129,6 +137,7 @@ func main() {
 		if agbotDB != nil {
 			agbotDB.Close()
 		}
+		exchange.CloseMessageTransports()
 
 		os.Exit(0)
 	}()
@@ -140,6 +149,12 @@ func main() {
 		panic(err)
 	}
 
+	// Event logs saved by an older runtime might need to be moved to the current key layout. The agent can still run
+	// if this fails, the event logs that are not migrated are just out of order with the others.
+	if err := persistence.MigrateEventLogKeys(db); err != nil {
+		glog.Errorf("%v, continuing without migrating the event logs.", err)
+	}
+
 	// Get the device side policy manager started early so that all the workers can use it.
 	// Make sure the policy directory is in place.
 	var pm *policy.PolicyManager
@@ -164,9 +179,15 @@ func main() {
 	// start workers
 	workers := worker.NewMessageHandlerRegistry()
 
-	workers.Add(agreementbot.NewAgreementBotWorker("AgBot", cfg, agbotDB, agbotSecrets))
+	// The agbot worker and the agbot API share the rollouts of workload upgrades.
+	var rolloutMgr *agreementbot.RolloutManager
+	if agbotDB != nil {
+		rolloutMgr = agreementbot.NewRolloutManager(agbotDB)
+	}
+
+	workers.Add(agreementbot.NewAgreementBotWorker("AgBot", cfg, agbotDB, agbotSecrets, rolloutMgr))
 	if cfg.AgreementBot.APIListen != "" {
-		workers.Add(agreementbot.NewAPIListener("AgBot API", cfg, agbotDB, *configFile, agbotSecrets))
+		workers.Add(agreementbot.NewAPIListener("AgBot API", cfg, agbotDB, *configFile, agbotSecrets, rolloutMgr))
 	}
 	if cfg.AgreementBot.SecureAPIListenHost != "" {
 		workers.Add(agreementbot.NewSecureAPIListener("AgBot Secure API", cfg, agbotDB, agbotSecrets))
@@ -174,6 +195,9 @@ func main() {
 	if agbotDB != nil {
 		workers.Add(agreementbot.NewChangesWorker("AgBot ExchangeChanges", cfg))
 	}
+	if agbotDB != nil && cfg.IsWebhookConfigured() {
+		workers.Add(agreementbot.NewWebhookWorker("AgBot Webhooks", cfg))
+	}
 
 	if db != nil {
 		workers.Add(api.NewAPIListener("API", cfg, db, pm))
@@ -210,6 +234,7 @@ func main() {
 	if agbotDB != nil {
 		agbotDB.Close()
 	}
+	exchange.CloseMessageTransports()
 
 	glog.Info("Main process terminating")
 }
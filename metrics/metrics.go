@@ -0,0 +1,400 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// This package exposes anax runtime metrics in the Prometheus text exposition format, so that the agent and the
+// agbot can be scraped without pulling the Prometheus client libraries into the build. Only the metric types that
+// anax needs are implemented: counters, gauges, histograms, and metrics that are computed when they are scraped.
+
+const (
+	COUNTER   = "counter"
+	GAUGE     = "gauge"
+	HISTOGRAM = "histogram"
+)
+
+// The content type of the text exposition format.
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// The default buckets (in seconds) used for latency histograms.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// A single value of a metric, for one combination of label values.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// All metric types implement this interface so that they can be written by a registry.
+type Metric interface {
+	Name() string
+	Help() string
+	Type() string
+	write(w io.Writer) error
+}
+
+// The common parts of every metric.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) Help() string {
+	return d.help
+}
+
+// Format the labels of a sample, extra is appended as is and is used for the histogram "le" label.
+func (d *desc) formatLabels(labelValues []string, extra string) string {
+	pairs := make([]string, 0, len(d.labels)+1)
+	for ix, l := range d.labels {
+		v := ""
+		if ix < len(labelValues) {
+			v = labelValues[ix]
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", l, escapeLabelValue(v)))
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Each combination of label values is kept in a map keyed by the joined values.
+func labelKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+// ----- Counter and Gauge -----
+
+// A metric with a value for each combination of label values. Counters only go up, gauges can be set to any value.
+type valueVec struct {
+	desc
+	metricType string
+	lock       sync.Mutex
+	values     map[string]*Sample
+}
+
+func newValueVec(metricType string, name string, help string, labels ...string) *valueVec {
+	return &valueVec{
+		desc:       desc{name: name, help: help, labels: labels},
+		metricType: metricType,
+		values:     make(map[string]*Sample),
+	}
+}
+
+func (v *valueVec) Type() string {
+	return v.metricType
+}
+
+func (v *valueVec) sample(labelValues []string) *Sample {
+	key := labelKey(labelValues)
+	s, ok := v.values[key]
+	if !ok {
+		s = &Sample{LabelValues: append([]string{}, labelValues...)}
+		v.values[key] = s
+	}
+	return s
+}
+
+// Return the current value for the label values, used mainly by tests.
+func (v *valueVec) Get(labelValues ...string) float64 {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if s, ok := v.values[labelKey(labelValues)]; ok {
+		return s.Value
+	}
+	return 0
+}
+
+func (v *valueVec) write(w io.Writer) error {
+	v.lock.Lock()
+	samples := make([]Sample, 0, len(v.values))
+	for _, s := range v.values {
+		samples = append(samples, *s)
+	}
+	v.lock.Unlock()
+	return writeSamples(w, &v.desc, samples)
+}
+
+type CounterVec struct {
+	*valueVec
+}
+
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{newValueVec(COUNTER, name, help, labels...)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Negative values are ignored, a counter never goes down.
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sample(labelValues).Value += value
+}
+
+type GaugeVec struct {
+	*valueVec
+}
+
+func NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newValueVec(GAUGE, name, help, labels...)}
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.sample(labelValues).Value = value
+}
+
+func (g *GaugeVec) Add(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.sample(labelValues).Value += value
+}
+
+// ----- Gauge and counter functions -----
+
+// A metric whose samples are computed by a function each time the metric is scraped. This is used for values
+// that are already tracked elsewhere, such as the number of agreements in the database.
+type funcMetric struct {
+	desc
+	metricType string
+	collect    func() []Sample
+}
+
+func (f *funcMetric) Type() string {
+	return f.metricType
+}
+
+func (f *funcMetric) write(w io.Writer) error {
+	return writeSamples(w, &f.desc, f.collect())
+}
+
+func NewGaugeFunc(name string, help string, labels []string, collect func() []Sample) Metric {
+	return &funcMetric{desc: desc{name: name, help: help, labels: labels}, metricType: GAUGE, collect: collect}
+}
+
+// The function must return values that never go down, such as totals kept by another component.
+func NewCounterFunc(name string, help string, labels []string, collect func() []Sample) Metric {
+	return &funcMetric{desc: desc{name: name, help: help, labels: labels}, metricType: COUNTER, collect: collect}
+}
+
+// ----- Histogram -----
+
+type histogramSample struct {
+	labelValues []string
+	counts      []uint64 // cumulative counts are computed when written
+	count       uint64
+	sum         float64
+}
+
+type HistogramVec struct {
+	desc
+	buckets []float64
+	lock    sync.Mutex
+	values  map[string]*histogramSample
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	return &HistogramVec{
+		desc:    desc{name: name, help: help, labels: labels},
+		buckets: b,
+		values:  make(map[string]*histogramSample),
+	}
+}
+
+func (h *HistogramVec) Type() string {
+	return HISTOGRAM
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	key := labelKey(labelValues)
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSample{labelValues: append([]string{}, labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	for ix, upper := range h.buckets {
+		if value <= upper {
+			s.counts[ix] += 1
+			break
+		}
+	}
+	s.count += 1
+	s.sum += value
+}
+
+// Return the number of observations for the label values, used mainly by tests.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if s, ok := h.values[labelKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.values[k]
+		cumulative := uint64(0)
+		for ix, upper := range h.buckets {
+			cumulative += s.counts[ix]
+			le := fmt.Sprintf("le=\"%s\"", formatValue(upper))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.labelValues, le), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.labelValues, "le=\"+Inf\""), s.count); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.formatLabels(s.labelValues, ""), formatValue(s.sum)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.formatLabels(s.labelValues, ""), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ----- Registry -----
+
+// A registry is the set of metrics exposed by one http endpoint.
+type Registry struct {
+	lock    sync.Mutex
+	metrics []Metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make([]Metric, 0)}
+}
+
+// Add metrics to the registry. A metric replaces any registered metric with the same name, so that a component that
+// is restarted can register its metrics again.
+func (r *Registry) Register(metrics ...Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, m := range metrics {
+		found := false
+		for ix, existing := range r.metrics {
+			if existing.Name() == m.Name() {
+				r.metrics[ix] = m
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.metrics = append(r.metrics, m)
+		}
+	}
+}
+
+// Write all the registered metrics in the text exposition format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	metrics := append([]Metric{}, r.metrics...)
+	r.lock.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name() < metrics[j].Name() })
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.Name(), escapeHelp(m.Help()), m.Name(), m.Type()); err != nil {
+			return err
+		}
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The registry can be used directly as the http handler for a /metrics route.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		w.Header().Set("Content-Type", CONTENT_TYPE)
+		w.WriteHeader(http.StatusOK)
+		r.Write(w)
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// ----- Formatting -----
+
+func writeSamples(w io.Writer, d *desc, samples []Sample) error {
+	sort.Slice(samples, func(i, j int) bool {
+		return labelKey(samples[i].LabelValues) < labelKey(samples[j].LabelValues)
+	})
+	for _, s := range samples {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", d.name, d.formatLabels(s.LabelValues, ""), formatValue(s.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	} else if math.IsInf(v, -1) {
+		return "-Inf"
+	} else if math.IsNaN(v) {
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(v)
+}
+
+// Convert a boolean to the value of a gauge.
+func BoolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
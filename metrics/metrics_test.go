@@ -0,0 +1,95 @@
+//go:build unit
+// +build unit
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Registry_Write(t *testing.T) {
+	r := NewRegistry()
+
+	c := NewCounterVec("test_requests_total", "The number of requests.", "method")
+	c.Inc("GET")
+	c.Add(2, "GET")
+	c.Add(-1, "GET")
+	c.Inc("PUT")
+
+	g := NewGaugeVec("test_temperature", "A \"quoted\" gauge\nwith 2 lines.")
+	g.Set(5)
+	g.Add(-1.5)
+
+	h := NewHistogramVec("test_duration_seconds", "Durations.", []float64{1, 0.1}, "op")
+	h.Observe(0.05, "a")
+	h.Observe(0.5, "a")
+	h.Observe(5, "a")
+
+	f := NewGaugeFunc("test_items", "Computed items.", []string{"name"}, func() []Sample {
+		return []Sample{{LabelValues: []string{"b\"\\"}, Value: 2}, {LabelValues: []string{"a"}, Value: 1}}
+	})
+
+	r.Register(NewCounterVec("test_requests_total", "Replaced by the next registration."))
+	r.Register(h, c, g, f)
+
+	if c.Get("GET") != 3 {
+		t.Errorf("expected counter value 3, got %v", c.Get("GET"))
+	} else if h.Count("a") != 3 {
+		t.Errorf("expected 3 observations, got %v", h.Count("a"))
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="a",le="0.1"} 1
+test_duration_seconds_bucket{op="a",le="1"} 2
+test_duration_seconds_bucket{op="a",le="+Inf"} 3
+test_duration_seconds_sum{op="a"} 5.55
+test_duration_seconds_count{op="a"} 3
+# HELP test_items Computed items.
+# TYPE test_items gauge
+test_items{name="a"} 1
+test_items{name="b\"\\"} 2
+# HELP test_requests_total The number of requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET"} 3
+test_requests_total{method="PUT"} 1
+# HELP test_temperature A "quoted" gauge\nwith 2 lines.
+# TYPE test_temperature gauge
+test_temperature 3.5
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%v\nexpected:\n%v", buf.String(), expected)
+	}
+}
+
+func Test_Registry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounterVec("test_total", "A counter.")
+	c.Inc()
+	r.Register(c)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %v", rec.Code)
+	} else if rec.Header().Get("Content-Type") != CONTENT_TYPE {
+		t.Errorf("unexpected content type %v", rec.Header().Get("Content-Type"))
+	} else if !strings.Contains(rec.Body.String(), "test_total 1\n") {
+		t.Errorf("unexpected body %v", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %v", rec.Code)
+	}
+}
@@ -62,6 +62,11 @@ const (
 	EC_NODE_HEARTBEAT_FAILED   = "node_heartbeat_failed"
 	EC_NODE_HEARTBEAT_RESTORED = "node_heartbeat_restored"
 
+	// node offline mode
+	EC_NODE_OFFLINE               = "node_offline"
+	EC_NODE_OFFLINE_GRACE_EXPIRED = "node_offline_grace_expired"
+	EC_NODE_RECONNECTED           = "node_reconnected"
+
 	// service configuration
 	EC_START_SERVICE_CONFIG                = "start_service_configuration"
 	EC_SERVICE_CONFIG_COMPLETE             = "service_configuration_complete"
@@ -0,0 +1,139 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"strconv"
+)
+
+// The event logs are keyed by their record id, zero padded so that the keys sort in the order the event logs were
+// saved. The oldest event logs are always at the front of the bucket, so pruning removes a contiguous range of keys
+// and the freed db pages are reused by the new event logs instead of fragmenting the db file.
+const EVENT_LOG_KEY_LEN = 20
+
+func eventLogKey(id uint64) []byte {
+	return []byte(fmt.Sprintf("%0*d", EVENT_LOG_KEY_LEN, id))
+}
+
+// The number of event logs deleted in each db transaction when pruning, so that pruning a large backlog of event
+// logs does not block other db writers for a long time.
+const EVENT_LOG_PRUNE_BATCH = 500
+
+// Event logs saved by older versions of the agent are keyed by the record id without padding. Rewrite them with the
+// current key layout. This is done once when the agent starts.
+func MigrateEventLogKeys(db *bolt.DB) error {
+	migrated := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(EVENT_LOGS))
+		if b == nil {
+			return nil
+		}
+
+		oldKeys := make([][]byte, 0)
+		b.ForEach(func(k, v []byte) error {
+			if len(k) != EVENT_LOG_KEY_LEN {
+				oldKeys = append(oldKeys, append([]byte{}, k...))
+			}
+			return nil
+		})
+
+		for _, k := range oldKeys {
+			if id, err := strconv.ParseUint(string(k), 10, 64); err != nil {
+				glog.Errorf("Unable to migrate event log with key %v, the key is not a record id.", string(k))
+			} else if err := b.Put(eventLogKey(id), append([]byte{}, b.Get(k)...)); err != nil {
+				return err
+			} else if err := b.Delete(k); err != nil {
+				return err
+			} else {
+				migrated++
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("Unable to migrate the event log keys, error: %v", err)
+	} else if migrated != 0 {
+		glog.Infof("Migrated %v event logs to the new key layout.", migrated)
+	}
+	return nil
+}
+
+// Delete the oldest event logs so that there are no more than maxCount event logs, and none that were saved before
+// the cutoff time (in seconds since the epoch). A maxCount or cutoff of 0 turns off that kind of pruning. The event
+// logs with a record id in keep are never deleted, these are the event logs referenced by the active surfaced errors.
+// Returns the number of event logs that were deleted.
+func PruneEventLogs(db *bolt.DB, maxCount int, cutoff uint64, keep map[string]bool) (int, error) {
+	deleted := 0
+
+	// Counting the keys walks the whole bucket, so the event logs are counted once and the count is reduced as they
+	// are deleted. Event logs saved while pruning are left for the next time.
+	count := -1
+
+	// The key to continue from in the next batch, so that the kept event logs are only looked at once.
+	var next []byte
+
+	for {
+		batch := 0
+		done := false
+		err := db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte(EVENT_LOGS))
+			if b == nil {
+				done = true
+				return nil
+			}
+
+			if count < 0 {
+				count = b.Stats().KeyN
+			}
+			toDelete := make([][]byte, 0, EVENT_LOG_PRUNE_BATCH)
+
+			c := b.Cursor()
+			k, v := c.First()
+			if next != nil {
+				k, v = c.Seek(next)
+			}
+			for ; k != nil && len(toDelete) < EVENT_LOG_PRUNE_BATCH; k, v = c.Next() {
+				var el EventLogBase
+				if err := json.Unmarshal(v, &el); err != nil {
+					glog.Errorf("Unable to deserialize event log db record: %v. Error: %v", v, err)
+				}
+
+				tooMany := maxCount > 0 && count-len(toDelete) > maxCount
+				tooOld := cutoff != 0 && el.Timestamp < cutoff
+				if !tooMany && !tooOld {
+					done = true
+					break
+				} else if !keep[el.Id] {
+					toDelete = append(toDelete, append([]byte{}, k...))
+				}
+			}
+
+			if k == nil {
+				done = true
+			} else {
+				next = append([]byte{}, k...)
+			}
+
+			for _, k := range toDelete {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			batch = len(toDelete)
+			return nil
+		})
+
+		if err != nil {
+			return deleted, fmt.Errorf("Unable to prune the event logs, error: %v", err)
+		}
+
+		deleted += batch
+		count -= batch
+		if done {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// +build unit
+
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func saveTestEventLogs(t *testing.T, db *bolt.DB, timestamps ...uint64) {
+	source := NewAgreementEventSource("agreement id 1", WorkloadInfo{"http://top1.com", "mycomp", "1.0.0", "amd64"}, []ServiceSpec{}, "agbot1", "basic")
+	for ix, ts := range timestamps {
+		el := newEventLog1(SEVERITY_INFO, fmt.Sprintf("message %v", ix+1), nil, EC_START_NODE_CONFIG_REG, SRC_TYPE_AG, *source)
+		el.Timestamp = ts
+		if err := SaveEventLog(db, el); err != nil {
+			t.Errorf("Error saving eventlog into db. %v", err)
+		}
+	}
+}
+
+func Test_PruneEventLogs_count_and_age(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	saveTestEventLogs(t, db, 100, 200, 300, 400, 500, 600)
+
+	// Nothing to prune.
+	if n, err := PruneEventLogs(db, 0, 0, nil); err != nil {
+		t.Errorf("error pruning event logs: %v", err)
+	} else {
+		assert.Equal(t, 0, n, "Nothing should be pruned when pruning is turned off.")
+	}
+
+	// Prune by count, the oldest event logs are deleted.
+	if n, err := PruneEventLogs(db, 4, 0, nil); err != nil {
+		t.Errorf("error pruning event logs: %v", err)
+	} else {
+		assert.Equal(t, 2, n, "The 2 oldest event logs should be pruned.")
+	}
+
+	if elogs, err := FindAllEventLogs(db); err != nil {
+		t.Errorf("error getting event logs: %v", err)
+	} else if assert.Equal(t, 4, len(elogs), "There should be 4 event logs left.") {
+		assert.Equal(t, "3", elogs[0].Id, "The oldest remaining event log should be the third one.")
+	}
+
+	// Prune by age, the event log with record id 4 is kept.
+	if n, err := PruneEventLogs(db, 0, 550, map[string]bool{"4": true}); err != nil {
+		t.Errorf("error pruning event logs: %v", err)
+	} else {
+		assert.Equal(t, 2, n, "The event logs older than the cutoff should be pruned.")
+	}
+
+	if elogs, err := FindAllEventLogs(db); err != nil {
+		t.Errorf("error getting event logs: %v", err)
+	} else if assert.Equal(t, 2, len(elogs), "There should be 2 event logs left.") {
+		assert.Equal(t, "4", elogs[0].Id, "The kept event log should not be pruned.")
+		assert.Equal(t, "6", elogs[1].Id, "The newest event log should not be pruned.")
+	}
+
+	// New event logs continue with the next record id.
+	saveTestEventLogs(t, db, 700)
+	if el, err := FindEventLogWithKey(db, "7"); err != nil {
+		t.Errorf("error getting event log: %v", err)
+	} else {
+		assert.NotNil(t, el, "The new event log should be found by its record id.")
+	}
+}
+
+func Test_PruneEventLogs_batches(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	timestamps := make([]uint64, EVENT_LOG_PRUNE_BATCH*2+10)
+	for ix := range timestamps {
+		timestamps[ix] = uint64(ix + 1)
+	}
+	saveTestEventLogs(t, db, timestamps...)
+
+	if n, err := PruneEventLogs(db, 5, 0, nil); err != nil {
+		t.Errorf("error pruning event logs: %v", err)
+	} else {
+		assert.Equal(t, len(timestamps)-5, n, "All but 5 event logs should be pruned.")
+	}
+
+	if last, err := GetLastEventLogId(db); err != nil {
+		t.Errorf("error getting the last event log id: %v", err)
+	} else if elogs, err := FindEventLogsWithSelectorsAfter(db, true, last-5, map[string][]Selector{}, nil); err != nil {
+		t.Errorf("error getting event logs: %v", err)
+	} else {
+		assert.Equal(t, 5, len(elogs), "The 5 newest event logs should be left.")
+	}
+}
+
+func Test_PruneEventLogs_kept_batches(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	timestamps := make([]uint64, EVENT_LOG_PRUNE_BATCH*2+20)
+	for ix := range timestamps {
+		timestamps[ix] = uint64(ix + 1)
+	}
+	saveTestEventLogs(t, db, timestamps...)
+
+	// The kept event logs are the oldest, and there are more of them than the limit.
+	keep := make(map[string]bool)
+	for id := 1; id <= 10; id++ {
+		keep[fmt.Sprintf("%v", id)] = true
+	}
+
+	if n, err := PruneEventLogs(db, 5, 0, keep); err != nil {
+		t.Errorf("error pruning event logs: %v", err)
+	} else {
+		assert.Equal(t, len(timestamps)-10, n, "All but the kept event logs should be pruned.")
+	}
+
+	if elogs, err := FindAllEventLogs(db); err != nil {
+		t.Errorf("error getting event logs: %v", err)
+	} else if assert.Equal(t, 10, len(elogs), "Only the kept event logs should be left.") {
+		assert.Equal(t, "1", elogs[0].Id)
+		assert.Equal(t, "10", elogs[9].Id)
+	}
+}
+
+func Test_MigrateEventLogKeys(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// Save event logs with the old key layout. With unpadded keys, record 10 sorts before record 9.
+	source := NewAgreementEventSource("agreement id 1", WorkloadInfo{"http://top1.com", "mycomp", "1.0.0", "amd64"}, []ServiceSpec{}, "agbot1", "basic")
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(EVENT_LOGS))
+		if err != nil {
+			return err
+		}
+		for _, id := range []uint64{9, 10} {
+			el := newEventLog1(SEVERITY_INFO, fmt.Sprintf("message %v", id), nil, EC_START_NODE_CONFIG_REG, SRC_TYPE_AG, *source)
+			el.Id = fmt.Sprintf("%v", id)
+			el.Timestamp = id
+			if serial, err := json.Marshal(el); err != nil {
+				return err
+			} else if err := b.Put([]byte(el.Id), serial); err != nil {
+				return err
+			} else if err := b.SetSequence(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("error saving old event logs: %v", err)
+	}
+
+	// The old keys are still found by record id before the migration.
+	if el, err := FindEventLogWithKey(db, "9"); err != nil || el == nil {
+		t.Errorf("event log 9 should be found, error: %v", err)
+	}
+
+	// The event logs after a record are found before and after the migration.
+	after := func() {
+		if elogs, err := FindEventLogsWithSelectorsAfter(db, true, 9, map[string][]Selector{}, nil); err != nil {
+			t.Errorf("error getting event logs: %v", err)
+		} else if assert.Equal(t, 1, len(elogs), "Only the event log after record 9 should be found.") {
+			assert.Equal(t, "10", elogs[0].Id)
+		}
+	}
+	after()
+
+	if err := MigrateEventLogKeys(db); err != nil {
+		t.Errorf("error migrating event log keys: %v", err)
+	}
+	after()
+
+	if elogs, err := FindAllEventLogs(db); err != nil {
+		t.Errorf("error getting event logs: %v", err)
+	} else if assert.Equal(t, 2, len(elogs), "The migration should keep all the event logs.") {
+		assert.Equal(t, "9", elogs[0].Id, "The event logs should be in record id order.")
+		assert.Equal(t, "10", elogs[1].Id, "The event logs should be in record id order.")
+	}
+
+	// Pruning deletes the oldest record.
+	if n, err := PruneEventLogs(db, 1, 0, nil); err != nil || n != 1 {
+		t.Errorf("expected 1 event log to be pruned, got %v, error: %v", n, err)
+	} else if el, err := FindEventLogWithKey(db, "10"); err != nil || el == nil {
+		t.Errorf("event log 10 should not be pruned, error: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package persistence
+
+import (
+	"sync"
+)
+
+// Components that stream the event log, such as the /eventlog/stream API, register a watcher channel so that they
+// are told when new event logs are saved. The channel only signals that there is something new, the watcher reads
+// the new records from the db. Signals are coalesced, so a slow watcher never blocks the code saving event logs.
+var eventLogWatchers = struct {
+	lock     sync.Mutex
+	watchers map[chan bool]bool
+}{watchers: make(map[chan bool]bool)}
+
+// Returns a channel that receives a signal after one or more event logs are saved.
+func WatchEventLogs() chan bool {
+	c := make(chan bool, 1)
+	eventLogWatchers.lock.Lock()
+	defer eventLogWatchers.lock.Unlock()
+	eventLogWatchers.watchers[c] = true
+	return c
+}
+
+// Stop sending signals to a channel returned by WatchEventLogs.
+func UnwatchEventLogs(c chan bool) {
+	eventLogWatchers.lock.Lock()
+	defer eventLogWatchers.lock.Unlock()
+	delete(eventLogWatchers.watchers, c)
+}
+
+func notifyEventLogWatchers() {
+	eventLogWatchers.lock.Lock()
+	defer eventLogWatchers.lock.Unlock()
+	for c := range eventLogWatchers.watchers {
+		select {
+		case c <- true:
+		default:
+			// There is already a signal pending for this watcher.
+		}
+	}
+}
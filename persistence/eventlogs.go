@@ -6,6 +6,7 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/metrics"
 	"golang.org/x/text/message"
 	"reflect"
 	"strconv"
@@ -230,6 +231,11 @@ func GetLastUnregistrationTime(db *bolt.DB) (uint64, error) {
 	}
 }
 
+// The number of event logs saved since the agent started, by severity.
+var EventLogCounter = metrics.NewCounterVec("anax_eventlog_events_total",
+	"The number of event log entries recorded by the agent, by severity.",
+	"severity")
+
 // save the event log record into db.
 func SaveEventLog(db *bolt.DB, event_log *EventLog) error {
 	writeErr := db.Update(func(tx *bolt.Tx) error {
@@ -238,17 +244,21 @@ func SaveEventLog(db *bolt.DB, event_log *EventLog) error {
 		} else if nextKey, err := bucket.NextSequence(); err != nil {
 			return fmt.Errorf("Unable to get sequence key for new event log %v. Error: %v", event_log, err)
 		} else {
-			strKey := strconv.FormatUint(nextKey, 10)
-			event_log.Id = strKey
+			event_log.Id = strconv.FormatUint(nextKey, 10)
 
 			serial, err := json.Marshal(*event_log)
 			if err != nil {
 				return fmt.Errorf("Failed to serialize the event log: %v. Error: %v", *event_log, err)
 			}
-			return bucket.Put([]byte(strKey), serial)
+			return bucket.Put(eventLogKey(nextKey), serial)
 		}
 	})
 
+	if writeErr == nil {
+		EventLogCounter.Inc(event_log.Severity)
+		notifyEventLogWatchers()
+	}
+
 	NewErrorLog(db, *event_log)
 	return writeErr
 }
@@ -312,6 +322,9 @@ func FindEventLogWithKey(db *bolt.DB, key string) (*EventLog, error) {
 
 		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
 			v := b.Get([]byte(key))
+			if id, err := strconv.ParseUint(key, 10, 64); err == nil && v == nil {
+				v = b.Get(eventLogKey(id))
+			}
 
 			var el EventLogRaw
 
@@ -409,6 +422,12 @@ func FindEventLogs(db *bolt.DB, filters []EventLogFilter) ([]EventLog, error) {
 // find event logs from the db for the given given selectors.
 // If all_logs is false, only the event logs for the current registration is returned.
 func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string][]Selector, msgPrinter *message.Printer) ([]EventLog, error) {
+	return FindEventLogsWithSelectorsAfter(db, all_logs, 0, selectors, msgPrinter)
+}
+
+// find event logs from the db for the given selectors that were saved after the event log with the given record id.
+// The record ids are assigned in increasing order, so this is used to find the event logs that are new to a caller.
+func FindEventLogsWithSelectorsAfter(db *bolt.DB, all_logs bool, after_id uint64, selectors map[string][]Selector, msgPrinter *message.Printer) ([]EventLog, error) {
 	// separate base selectors from the source selectors
 	base_selectors, source_selectors := GroupSelectors(selectors)
 
@@ -431,7 +450,21 @@ func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string
 	readErr := db.View(func(tx *bolt.Tx) error {
 
 		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
-			b.ForEach(func(k, v []byte) error {
+
+			// The keys sort in record id order, so the older records are skipped by starting after them.
+			c := b.Cursor()
+			k, v := c.First()
+			if after_id != 0 {
+				k, v = c.Seek(eventLogKey(after_id + 1))
+			}
+			for ; k != nil; k, v = c.Next() {
+
+				// Records that could not be migrated to the current key layout sort after the others.
+				if after_id != 0 && len(k) != EVENT_LOG_KEY_LEN {
+					if id, err := strconv.ParseUint(string(k), 10, 64); err == nil && id <= after_id {
+						continue
+					}
+				}
 
 				var el EventLogRaw
 
@@ -456,8 +489,7 @@ func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string
 						}
 					}
 				}
-				return nil
-			})
+			}
 		}
 
 		return nil // end the transaction
@@ -470,6 +502,18 @@ func FindEventLogsWithSelectors(db *bolt.DB, all_logs bool, selectors map[string
 	}
 }
 
+// Returns the record id of the most recently saved event log, 0 if there are no event logs.
+func GetLastEventLogId(db *bolt.DB) (uint64, error) {
+	last_id := uint64(0)
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(EVENT_LOGS)); b != nil {
+			last_id = b.Sequence()
+		}
+		return nil
+	})
+	return last_id, readErr
+}
+
 // find all event logs from the db
 func FindAllEventLogs(db *bolt.DB) ([]EventLog, error) {
 	evlogs := make([]EventLog, 0)
@@ -0,0 +1,127 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"time"
+)
+
+// Constants used throughout the code.
+const OFFLINE_STATE = "offline-state" // The bucket name in the bolt DB.
+
+// The state of a node that is configured to keep running while it cannot reach the exchange. The changes worker
+// records when the node goes offline and when it reconnects, the governance worker records whether the node
+// status still has to be written to the exchange. The state is kept in the local DB so that it survives a restart
+// of the agent while the node is offline.
+type OfflineState struct {
+	OfflineSince  int64 `json:"offlineSince"`  // The time of the last successful heartbeat before the node went offline, 0 when the node is online.
+	LastReconnect int64 `json:"lastReconnect"` // The last time the node reconnected to the exchange after being offline.
+	GraceExpired  bool  `json:"graceExpired"`  // The node has been offline for longer than the grace period.
+	StatusPending bool  `json:"statusPending"` // The node status changed while the node was offline and has not been written to the exchange yet.
+}
+
+func (s OfflineState) String() string {
+	return fmt.Sprintf("Offline State offline since: %v, last reconnect: %v, grace expired: %v, status pending: %v",
+		s.OfflineSince, s.LastReconnect, s.GraceExpired, s.StatusPending)
+}
+
+// Returns true if the node is offline. A nil state means the node has never been offline.
+func (s *OfflineState) IsOffline() bool {
+	return s != nil && s.OfflineSince != 0
+}
+
+// Returns true if the node is offline and has not been for longer than the grace period.
+func (s *OfflineState) InGracePeriod(gracePeriodS int) bool {
+	return s.IsOffline() && gracePeriodS > 0 && time.Now().Unix()-s.OfflineSince <= int64(gracePeriodS)
+}
+
+// Returns true if the node status still has to be written to the exchange.
+func (s *OfflineState) IsStatusPending() bool {
+	return s != nil && s.StatusPending
+}
+
+// Returns the time to count a timeout from, given the time the timeout started. A timeout that started before the node
+// last reconnected is counted from the reconnection instead, so that it does not expire as soon as the node is back.
+func (s *OfflineState) TimeoutStart(start int64) int64 {
+	if s != nil && s.LastReconnect > start {
+		return s.LastReconnect
+	}
+	return start
+}
+
+// Retrieve the offline state object from the database. There should only ever be 1 object in the bucket, nil is
+// returned if there is none.
+func FindOfflineState(db *bolt.DB) (*OfflineState, error) {
+
+	var state *OfflineState
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(OFFLINE_STATE)); b != nil {
+			if v := b.Get([]byte(OFFLINE_STATE)); v != nil {
+				state = new(OfflineState)
+				if err := json.Unmarshal(v, state); err != nil {
+					return fmt.Errorf("Unable to deserialize offline state %v, error: %v", string(v), err)
+				}
+			}
+		}
+
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return state, nil
+}
+
+// Apply the update function to the offline state and save it, in a single transaction because the state is updated by
+// more than one worker. The update function is called with an empty state if there is none yet. The updated state is
+// returned.
+func UpdateOfflineState(db *bolt.DB, update func(*OfflineState)) (*OfflineState, error) {
+
+	state := new(OfflineState)
+
+	writeErr := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(OFFLINE_STATE))
+		if err != nil {
+			return err
+		}
+
+		if v := b.Get([]byte(OFFLINE_STATE)); v != nil {
+			if err := json.Unmarshal(v, state); err != nil {
+				return fmt.Errorf("Unable to deserialize offline state %v, error: %v", string(v), err)
+			}
+		}
+
+		update(state)
+
+		if serial, err := json.Marshal(state); err != nil {
+			return fmt.Errorf("Failed to serialize offline state %v, error: %v", state, err)
+		} else if err := b.Put([]byte(OFFLINE_STATE), serial); err != nil {
+			return fmt.Errorf("Failed to save offline state %v, error: %v", state, err)
+		} else {
+			glog.V(5).Infof("Successfully saved offline state: %v", state)
+			return nil
+		}
+	})
+
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	return state, nil
+}
+
+// Remove the offline state object from the local database.
+func DeleteOfflineState(db *bolt.DB) error {
+
+	return db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(OFFLINE_STATE)); b != nil {
+			if err := b.Delete([]byte(OFFLINE_STATE)); err != nil {
+				return fmt.Errorf("Unable to delete offline state, error: %v", err)
+			}
+		}
+		return nil
+	})
+}
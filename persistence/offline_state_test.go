@@ -0,0 +1,60 @@
+// +build unit
+
+package persistence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_OfflineState(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// There is no state until the node goes offline for the first time.
+	state, err := FindOfflineState(db)
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+	assert.False(t, state.IsOffline())
+	assert.False(t, state.InGracePeriod(60))
+	assert.Equal(t, int64(100), state.TimeoutStart(100))
+
+	now := time.Now().Unix()
+	state, err = UpdateOfflineState(db, func(s *OfflineState) { s.OfflineSince = now - 30 })
+	assert.Nil(t, err)
+	assert.True(t, state.IsOffline())
+	assert.True(t, state.InGracePeriod(60))
+	assert.False(t, state.InGracePeriod(10), "The grace period should have expired.")
+	assert.False(t, state.InGracePeriod(0), "Offline mode should be off.")
+
+	// The updates from different workers are kept.
+	_, err = UpdateOfflineState(db, func(s *OfflineState) { s.StatusPending = true })
+	assert.Nil(t, err)
+	state, err = FindOfflineState(db)
+	if assert.Nil(t, err) && assert.NotNil(t, state) {
+		assert.Equal(t, now-30, state.OfflineSince)
+		assert.True(t, state.IsStatusPending())
+	}
+
+	// After the node reconnects, timeouts that started before then are counted from the reconnection.
+	state, err = UpdateOfflineState(db, func(s *OfflineState) {
+		s.OfflineSince = 0
+		s.LastReconnect = now
+	})
+	assert.Nil(t, err)
+	assert.False(t, state.IsOffline())
+	assert.False(t, state.InGracePeriod(60))
+	assert.Equal(t, now, state.TimeoutStart(now-100))
+	assert.Equal(t, now+10, state.TimeoutStart(now+10))
+
+	assert.Nil(t, DeleteOfflineState(db))
+	state, err = FindOfflineState(db)
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+	assert.Nil(t, DeleteOfflineState(db), "Deleting the state twice should not fail.")
+}
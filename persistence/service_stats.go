@@ -0,0 +1,221 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+)
+
+const SERVICE_STATS = "service_stats"
+
+// The resources used by one container (or pod container) of a service instance at the time of a sample. The network and
+// block I/O counters are the totals since the container started. They are zero when the container runtime does not report them.
+type ContainerResourceSample struct {
+	Name        string  `json:"name"`
+	CPUPercent  float64 `json:"cpu_percent"`            // percent of one CPU, can be more than 100 when the container uses several CPUs
+	MemoryUsage uint64  `json:"memory_usage"`           // bytes
+	MemoryLimit uint64  `json:"memory_limit,omitempty"` // bytes
+	NetworkRx   uint64  `json:"network_rx_bytes"`
+	NetworkTx   uint64  `json:"network_tx_bytes"`
+	BlockRead   uint64  `json:"block_read_bytes"`
+	BlockWrite  uint64  `json:"block_write_bytes"`
+}
+
+func (c ContainerResourceSample) String() string {
+	return fmt.Sprintf("Name: %v, CPUPercent: %.2f, MemoryUsage: %v, MemoryLimit: %v, NetworkRx: %v, NetworkTx: %v, BlockRead: %v, BlockWrite: %v",
+		c.Name, c.CPUPercent, c.MemoryUsage, c.MemoryLimit, c.NetworkRx, c.NetworkTx, c.BlockRead, c.BlockWrite)
+}
+
+// The resources used by all the containers of a service instance at one point in time.
+type ServiceResourceSample struct {
+	Time       int64                     `json:"time"`
+	Containers []ContainerResourceSample `json:"containers"`
+}
+
+// The rolling history of resource usage samples for one service instance, oldest sample first.
+type ServiceStats struct {
+	InstanceKey string                  `json:"instance_key"`
+	ServiceURL  string                  `json:"service_url"`
+	Org         string                  `json:"org"`
+	Version     string                  `json:"version"`
+	Arch        string                  `json:"arch"`
+	Samples     []ServiceResourceSample `json:"samples"`
+}
+
+func (s ServiceStats) String() string {
+	return fmt.Sprintf("InstanceKey: %v, ServiceURL: %v, Org: %v, Version: %v, Arch: %v, Samples: %v",
+		s.InstanceKey, s.ServiceURL, s.Org, s.Version, s.Arch, len(s.Samples))
+}
+
+// A summary of the samples in the history of one or more service instances. The CPU figures are computed from the
+// total of all the containers in each sample, the memory and I/O figures come from the latest sample.
+type ServiceStatsSummary struct {
+	Samples        int     `json:"samples"`
+	From           int64   `json:"from,omitempty"`
+	To             int64   `json:"to,omitempty"`
+	CPUPercent     float64 `json:"cpu_percent"`
+	MaxCPUPercent  float64 `json:"max_cpu_percent"`
+	MemoryUsage    uint64  `json:"memory_usage"`
+	MaxMemoryUsage uint64  `json:"max_memory_usage"`
+	NetworkRx      uint64  `json:"network_rx_bytes"`
+	NetworkTx      uint64  `json:"network_tx_bytes"`
+	BlockRead      uint64  `json:"block_read_bytes"`
+	BlockWrite     uint64  `json:"block_write_bytes"`
+}
+
+func (s ServiceStatsSummary) String() string {
+	return fmt.Sprintf("Samples: %v, From: %v, To: %v, CPUPercent: %.2f, MaxCPUPercent: %.2f, MemoryUsage: %v, MaxMemoryUsage: %v, NetworkRx: %v, NetworkTx: %v, BlockRead: %v, BlockWrite: %v",
+		s.Samples, s.From, s.To, s.CPUPercent, s.MaxCPUPercent, s.MemoryUsage, s.MaxMemoryUsage, s.NetworkRx, s.NetworkTx, s.BlockRead, s.BlockWrite)
+}
+
+// Returns the totals of all the containers in the sample.
+func (s ServiceResourceSample) Total() ContainerResourceSample {
+	total := ContainerResourceSample{}
+	for _, c := range s.Containers {
+		total.CPUPercent += c.CPUPercent
+		total.MemoryUsage += c.MemoryUsage
+		total.MemoryLimit += c.MemoryLimit
+		total.NetworkRx += c.NetworkRx
+		total.NetworkTx += c.NetworkTx
+		total.BlockRead += c.BlockRead
+		total.BlockWrite += c.BlockWrite
+	}
+	return total
+}
+
+// Summarize the history of a service instance. Returns nil when there are no samples.
+func (s ServiceStats) Summary() *ServiceStatsSummary {
+	if len(s.Samples) == 0 {
+		return nil
+	}
+
+	summary := &ServiceStatsSummary{Samples: len(s.Samples), From: s.Samples[0].Time, To: s.Samples[len(s.Samples)-1].Time}
+	cpuTotal := 0.0
+	for _, sample := range s.Samples {
+		total := sample.Total()
+		cpuTotal += total.CPUPercent
+		if total.CPUPercent > summary.MaxCPUPercent {
+			summary.MaxCPUPercent = total.CPUPercent
+		}
+		if total.MemoryUsage > summary.MaxMemoryUsage {
+			summary.MaxMemoryUsage = total.MemoryUsage
+		}
+	}
+	summary.CPUPercent = cpuTotal / float64(len(s.Samples))
+
+	latest := s.Samples[len(s.Samples)-1].Total()
+	summary.MemoryUsage = latest.MemoryUsage
+	summary.NetworkRx = latest.NetworkRx
+	summary.NetworkTx = latest.NetworkTx
+	summary.BlockRead = latest.BlockRead
+	summary.BlockWrite = latest.BlockWrite
+	return summary
+}
+
+// Add the summary of another instance of the same service, so that the result describes all of the instances together.
+// The sample count and time range cover both summaries.
+func (s *ServiceStatsSummary) Add(other *ServiceStatsSummary) {
+	if other == nil {
+		return
+	}
+	if s.Samples == 0 || (other.From != 0 && other.From < s.From) {
+		s.From = other.From
+	}
+	if other.To > s.To {
+		s.To = other.To
+	}
+	s.Samples += other.Samples
+	s.CPUPercent += other.CPUPercent
+	s.MaxCPUPercent += other.MaxCPUPercent
+	s.MemoryUsage += other.MemoryUsage
+	s.MaxMemoryUsage += other.MaxMemoryUsage
+	s.NetworkRx += other.NetworkRx
+	s.NetworkTx += other.NetworkTx
+	s.BlockRead += other.BlockRead
+	s.BlockWrite += other.BlockWrite
+}
+
+// Add a sample to the history of a service instance, creating the history if needed. The oldest samples are dropped so
+// that no more than maxSamples are kept. The service attributes in the history are refreshed from the given stats object.
+func SaveServiceStatsSample(db *bolt.DB, stats ServiceStats, sample ServiceResourceSample, maxSamples int) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(SERVICE_STATS))
+		if err != nil {
+			return err
+		}
+
+		history := ServiceStats{}
+		if current := b.Get([]byte(stats.InstanceKey)); current != nil {
+			if err := json.Unmarshal(current, &history); err != nil {
+				return fmt.Errorf("Unable to deserialize service stats record %v: %v", stats.InstanceKey, err)
+			}
+		}
+
+		samples := append(history.Samples, sample)
+		if maxSamples > 0 && len(samples) > maxSamples {
+			samples = samples[len(samples)-maxSamples:]
+		}
+		stats.Samples = samples
+
+		if serial, err := json.Marshal(stats); err != nil {
+			return fmt.Errorf("Failed to serialize service stats: %v. Error: %v", stats, err)
+		} else {
+			return b.Put([]byte(stats.InstanceKey), serial)
+		}
+	})
+}
+
+// FindServiceStats returns the resource usage history of all the service instances in the local db.
+func FindServiceStats(db *bolt.DB) ([]ServiceStats, error) {
+	allStats := make([]ServiceStats, 0)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(SERVICE_STATS)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+				var stats ServiceStats
+				if err := json.Unmarshal(v, &stats); err != nil {
+					return fmt.Errorf("Unable to deserialize service stats record: %v", v)
+				}
+				allStats = append(allStats, stats)
+				return nil
+			})
+		}
+
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return allStats, nil
+}
+
+// Remove the history of the service instances that are not in the active list. Returns the number of histories removed.
+func PruneServiceStats(db *bolt.DB, active map[string]bool) (int, error) {
+	deleted := 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SERVICE_STATS))
+		if b == nil {
+			return nil
+		}
+
+		remove := make([][]byte, 0)
+		if err := b.ForEach(func(k, v []byte) error {
+			if !active[string(k)] {
+				remove = append(remove, append([]byte{}, k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range remove {
+			if err := b.Delete(k); err != nil {
+				return fmt.Errorf("Unable to delete service stats record %v: %v", string(k), err)
+			}
+			deleted++
+		}
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,75 @@
+// +build unit
+
+package persistence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_ServiceStats_history(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	svc := ServiceStats{InstanceKey: "ag1", ServiceURL: "my.com.svc", Org: "myorg", Version: "1.0.0", Arch: "amd64"}
+	for ix := 1; ix <= 4; ix++ {
+		sample := ServiceResourceSample{Time: int64(ix * 60), Containers: []ContainerResourceSample{
+			{Name: "/ag1-web", CPUPercent: float64(ix * 10), MemoryUsage: uint64(ix * 100), NetworkRx: uint64(ix * 1000)},
+			{Name: "/ag1-db", CPUPercent: 5, MemoryUsage: 50},
+		}}
+		if err := SaveServiceStatsSample(db, svc, sample, 3); err != nil {
+			t.Errorf("error saving service stats: %v", err)
+		}
+	}
+	if err := SaveServiceStatsSample(db, ServiceStats{InstanceKey: "dep1", ServiceURL: "my.com.dep", Org: "myorg"}, ServiceResourceSample{Time: 240}, 3); err != nil {
+		t.Errorf("error saving service stats: %v", err)
+	}
+
+	allStats, err := FindServiceStats(db)
+	if err != nil {
+		t.Fatalf("error getting service stats: %v", err)
+	} else if !assert.Equal(t, 2, len(allStats), "There should be a history for each instance.") {
+		return
+	}
+
+	// Only the latest 3 samples are kept.
+	history := allStats[0]
+	assert.Equal(t, "ag1", history.InstanceKey)
+	assert.Equal(t, "my.com.svc", history.ServiceURL)
+	if assert.Equal(t, 3, len(history.Samples), "The oldest sample should be dropped.") {
+		assert.Equal(t, int64(120), history.Samples[0].Time)
+	}
+
+	summary := history.Summary()
+	if assert.NotNil(t, summary) {
+		assert.Equal(t, 3, summary.Samples)
+		assert.Equal(t, int64(120), summary.From)
+		assert.Equal(t, int64(240), summary.To)
+		assert.Equal(t, 35.0, summary.CPUPercent, "The CPU should be the average of the container totals.")
+		assert.Equal(t, 45.0, summary.MaxCPUPercent)
+		assert.Equal(t, uint64(450), summary.MemoryUsage, "The memory should come from the latest sample.")
+		assert.Equal(t, uint64(4000), summary.NetworkRx)
+	}
+
+	// Summaries of several instances of a service add up.
+	summary.Add(allStats[1].Summary())
+	assert.Equal(t, 4, summary.Samples)
+	assert.Equal(t, int64(120), summary.From)
+	assert.Nil(t, ServiceStats{}.Summary(), "There is no summary without samples.")
+
+	// The history of instances that are gone is removed.
+	if n, err := PruneServiceStats(db, map[string]bool{"ag1": true}); err != nil {
+		t.Errorf("error pruning service stats: %v", err)
+	} else {
+		assert.Equal(t, 1, n, "The history of dep1 should be removed.")
+	}
+	if allStats, err := FindServiceStats(db); err != nil {
+		t.Errorf("error getting service stats: %v", err)
+	} else if assert.Equal(t, 1, len(allStats)) {
+		assert.Equal(t, "ag1", allStats[0].InstanceKey)
+	}
+}
@@ -72,6 +72,7 @@ type Policy struct {
 	UserInput          []UserInput                         `json:"userInput,omitempty"`
 	SecretBinding      []exchangecommon.SecretBinding      `json:"secretBinding,omitempty"` // This structure has the servive secret name to secret provider name mappings
 	SecretDetails      []exchangecommon.SecretBinding      `json:"secretDetails,omitempty"` // This structure has the service secret name to secret details mappings
+	Rollout            *RolloutPolicy                      `json:"rollout,omitempty"`       // How devices are upgraded when the workload changes, all at once when not set
 }
 
 // These functions are used to create Policy objects. You can create the base object
@@ -114,6 +115,10 @@ func (self *Policy) DeepCopy() *Policy {
 	copy(newPolicy.HAGroup.Partners, self.HAGroup.Partners)
 	newPolicy.NodeH = self.NodeH
 
+	if self.Rollout != nil {
+		newPolicy.Rollout = RolloutPolicy_Factory(self.Rollout.BatchSize, self.Rollout.BatchPercentage, self.Rollout.PauseS, self.Rollout.MaxFailures, self.Rollout.FailureTimeoutS)
+	}
+
 	for _, ui := range self.UserInput {
 		newUI := ui
 		newUI.Inputs = make([]Input, len(ui.Inputs))
@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The default number of seconds a device has to make an agreement with the upgraded workload before the upgrade
+// is considered to have failed.
+const DEFAULT_ROLLOUT_FAILURE_TIMEOUT = 600
+
+// A staged rollout of a workload upgrade. When the highest priority workload in a policy changes, the devices that are
+// running the previous workload are upgraded in batches instead of all at once.
+type RolloutPolicy struct {
+	BatchSize       int `json:"batchSize,omitempty"`           // The number of devices upgraded in each batch
+	BatchPercentage int `json:"batchPercentage,omitempty"`     // The percentage of the devices upgraded in each batch, used when batchSize is not set
+	PauseS          int `json:"pauseBetweenBatches,omitempty"` // The number of seconds to wait after a batch completes before starting the next batch
+	MaxFailures     int `json:"maxFailures,omitempty"`         // The rollout is halted when this many devices fail to upgrade, 0 means it is never halted
+	FailureTimeoutS int `json:"failureTimeout,omitempty"`      // The number of seconds a device has to make a new agreement before the upgrade is considered failed
+}
+
+func (r RolloutPolicy) String() string {
+	return fmt.Sprintf("BatchSize: %v, "+
+		"BatchPercentage: %v, "+
+		"PauseS: %v, "+
+		"MaxFailures: %v, "+
+		"FailureTimeoutS: %v",
+		r.BatchSize, r.BatchPercentage, r.PauseS, r.MaxFailures, r.FailureTimeoutS)
+}
+
+// This function creates rollout policy objects
+func RolloutPolicy_Factory(batchSize int, batchPercentage int, pauseS int, maxFailures int, failureTimeoutS int) *RolloutPolicy {
+	r := new(RolloutPolicy)
+	r.BatchSize = batchSize
+	r.BatchPercentage = batchPercentage
+	r.PauseS = pauseS
+	r.MaxFailures = maxFailures
+	r.FailureTimeoutS = failureTimeoutS
+	return r
+}
+
+func (r RolloutPolicy) IsSame(compare RolloutPolicy) bool {
+	return r.BatchSize == compare.BatchSize &&
+		r.BatchPercentage == compare.BatchPercentage &&
+		r.PauseS == compare.PauseS &&
+		r.MaxFailures == compare.MaxFailures &&
+		r.FailureTimeoutS == compare.FailureTimeoutS
+}
+
+func (r RolloutPolicy) Validate() error {
+	if r.BatchSize < 0 || r.BatchPercentage < 0 || r.PauseS < 0 || r.MaxFailures < 0 || r.FailureTimeoutS < 0 {
+		return errors.New(fmt.Sprintf("rollout values must not be negative: %v", r))
+	} else if r.BatchSize == 0 && r.BatchPercentage == 0 {
+		return errors.New(fmt.Sprintf("rollout must have a batchSize or a batchPercentage"))
+	} else if r.BatchPercentage > 100 {
+		return errors.New(fmt.Sprintf("rollout batchPercentage %v must not be greater than 100", r.BatchPercentage))
+	}
+	return nil
+}
+
+// Returns the number of devices to upgrade in each batch, given the total number of devices being upgraded.
+// There is always at least 1 device in a batch.
+func (r RolloutPolicy) GetBatchSize(total int) int {
+	size := r.BatchSize
+	if size == 0 {
+		// Round up so that a small percentage of a small fleet still makes progress.
+		size = (total*r.BatchPercentage + 99) / 100
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func (r RolloutPolicy) GetFailureTimeoutS() int {
+	if r.FailureTimeoutS == 0 {
+		return DEFAULT_ROLLOUT_FAILURE_TIMEOUT
+	}
+	return r.FailureTimeoutS
+}
@@ -0,0 +1,52 @@
+// +build unit
+
+package policy
+
+import (
+	"testing"
+)
+
+func Test_RolloutPolicy_Validate(t *testing.T) {
+
+	good := []*RolloutPolicy{
+		RolloutPolicy_Factory(1, 0, 0, 0, 0),
+		RolloutPolicy_Factory(0, 10, 300, 2, 120),
+		RolloutPolicy_Factory(5, 100, 0, 0, 0),
+	}
+	for _, r := range good {
+		if err := r.Validate(); err != nil {
+			t.Errorf("rollout %v should be valid, error: %v", r, err)
+		}
+	}
+
+	bad := []*RolloutPolicy{
+		RolloutPolicy_Factory(0, 0, 300, 0, 0),
+		RolloutPolicy_Factory(-1, 0, 0, 0, 0),
+		RolloutPolicy_Factory(1, 0, -5, 0, 0),
+		RolloutPolicy_Factory(0, 101, 0, 0, 0),
+	}
+	for _, r := range bad {
+		if err := r.Validate(); err == nil {
+			t.Errorf("rollout %v should not be valid", r)
+		}
+	}
+}
+
+func Test_RolloutPolicy_GetBatchSize(t *testing.T) {
+
+	if size := RolloutPolicy_Factory(3, 50, 0, 0, 0).GetBatchSize(100); size != 3 {
+		t.Errorf("batch size should be 3, was %v", size)
+	} else if size := RolloutPolicy_Factory(0, 25, 0, 0, 0).GetBatchSize(10); size != 3 {
+		t.Errorf("batch size should be 3, was %v", size)
+	} else if size := RolloutPolicy_Factory(0, 10, 0, 0, 0).GetBatchSize(2); size != 1 {
+		t.Errorf("batch size should be 1, was %v", size)
+	} else if size := RolloutPolicy_Factory(0, 10, 0, 0, 0).GetBatchSize(0); size != 1 {
+		t.Errorf("batch size should be 1, was %v", size)
+	}
+
+	if timeout := RolloutPolicy_Factory(1, 0, 0, 0, 0).GetFailureTimeoutS(); timeout != DEFAULT_ROLLOUT_FAILURE_TIMEOUT {
+		t.Errorf("failure timeout should be the default, was %v", timeout)
+	} else if timeout := RolloutPolicy_Factory(1, 0, 0, 0, 30).GetFailureTimeoutS(); timeout != 30 {
+		t.Errorf("failure timeout should be 30, was %v", timeout)
+	}
+}
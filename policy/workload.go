@@ -7,6 +7,8 @@ import (
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/rsapss-tool/verify"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
 )
 
 type WorkloadList []Workload
@@ -54,6 +56,108 @@ func (wp WorkloadPriority) IsSame(compare WorkloadPriority) bool {
 		wp.VerifiedDurationS == compare.VerifiedDurationS
 }
 
+// Valid values for the lifecycle of a workload upgrade policy.
+const UPGRADE_LIFECYCLE_IMMEDIATE = "immediate"
+const UPGRADE_LIFECYCLE_NEVER = "never"
+const UPGRADE_LIFECYCLE_AGREEMENT = "agreement"
+
+// The daily maintenance window format of the upgrade time, e.g. 02:00-04:00. The times are in UTC.
+const UPGRADE_WINDOW_TIME_FORMAT = "15:04"
+
+type WorkloadUpgrade struct {
+	Lifecycle string `json:"lifecycle,omitempty"` // When to upgrade to this workload: immediate (the default), never or agreement (when the current agreement ends).
+	Time      string `json:"time,omitempty"`      // When an immediate upgrade can be done, either an RFC3339 time or a daily UTC window such as 02:00-04:00.
+}
+
+func (wu WorkloadUpgrade) String() string {
+	return fmt.Sprintf("Lifecycle: %v, Time: %v", wu.Lifecycle, wu.Time)
+}
+
+// This function creates workload upgrade policy objects
+func Workload_Upgrade_Factory(lifecycle string, upgradeTime string) *WorkloadUpgrade {
+	wu := new(WorkloadUpgrade)
+	wu.Lifecycle = lifecycle
+	wu.Time = upgradeTime
+	return wu
+}
+
+func (wu WorkloadUpgrade) IsEmpty() bool {
+	return wu.Lifecycle == "" && wu.Time == ""
+}
+
+// Returns true if the running workload is kept until its agreement ends.
+func (wu WorkloadUpgrade) IsPinned() bool {
+	return wu.Lifecycle == UPGRADE_LIFECYCLE_NEVER || wu.Lifecycle == UPGRADE_LIFECYCLE_AGREEMENT
+}
+
+// The time is not validated because it was free form text before it was used. A time that cannot be parsed
+// does not delay the upgrade.
+func (wu WorkloadUpgrade) Validate() error {
+	if wu.Lifecycle != "" && wu.Lifecycle != UPGRADE_LIFECYCLE_IMMEDIATE && !wu.IsPinned() {
+		return errors.New(fmt.Sprintf("upgrade policy lifecycle %v is not valid, it must be one of %v, %v or %v", wu.Lifecycle, UPGRADE_LIFECYCLE_IMMEDIATE, UPGRADE_LIFECYCLE_NEVER, UPGRADE_LIFECYCLE_AGREEMENT))
+	}
+	return nil
+}
+
+// Returns the earliest time, at or after now, when an upgrade to this workload is allowed. The pinned lifecycles
+// are not considered, they are handled by the caller. If the time cannot be parsed, now is returned with the error.
+func (wu WorkloadUpgrade) NextUpgradeTime(now time.Time) (time.Time, error) {
+	if wu.Time == "" {
+		return now, nil
+	}
+
+	if start, end, err := wu.parseWindow(); err == nil {
+		now = now.UTC()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		sinceMidnight := now.Sub(midnight)
+
+		// The window can span midnight, e.g. 23:00-01:00.
+		inWindow := false
+		if start <= end {
+			inWindow = sinceMidnight >= start && sinceMidnight < end
+		} else {
+			inWindow = sinceMidnight >= start || sinceMidnight < end
+		}
+
+		if inWindow {
+			return now, nil
+		} else if sinceMidnight < start {
+			return midnight.Add(start), nil
+		} else {
+			return midnight.Add(24 * time.Hour).Add(start), nil
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, wu.Time); err != nil {
+		return now, errors.New(fmt.Sprintf("unable to parse upgrade policy time %v, error: %v", wu.Time, err))
+	} else if t.After(now) {
+		return t, nil
+	}
+	return now, nil
+}
+
+// Parse a daily window of the form HH:MM-HH:MM into offsets from midnight.
+func (wu WorkloadUpgrade) parseWindow() (time.Duration, time.Duration, error) {
+	times := strings.Split(wu.Time, "-")
+	if len(times) != 2 {
+		return 0, 0, errors.New(fmt.Sprintf("%v is not a time window", wu.Time))
+	}
+
+	offsets := make([]time.Duration, 2)
+	for ix, t := range times {
+		if pt, err := time.Parse(UPGRADE_WINDOW_TIME_FORMAT, strings.TrimSpace(t)); err != nil {
+			return 0, 0, err
+		} else {
+			offsets[ix] = time.Duration(pt.Hour())*time.Hour + time.Duration(pt.Minute())*time.Minute
+		}
+	}
+
+	if offsets[0] == offsets[1] {
+		return 0, 0, errors.New(fmt.Sprintf("time window %v is empty", wu.Time))
+	}
+	return offsets[0], offsets[1], nil
+}
+
 type Workload struct {
 	Deployment                   string           `json:"deployment,omitempty"`
 	DeploymentSignature          string           `json:"deployment_signature,omitempty"`
@@ -68,6 +172,7 @@ type Workload struct {
 	Arch                         string           `json:"arch,omitempty"`                           // Added with MS split, refers to the hardware architecture of the workload definition
 	DeploymentOverrides          string           `json:"deployment_overrides,omitempty"`           // Added with MS split, env var overrides for the workload
 	DeploymentOverridesSignature string           `json:"deployment_overrides_signature,omitempty"` // Added with MS split, signature of env var overrides
+	Upgrade                      *WorkloadUpgrade `json:"upgrade,omitempty"`                        // When a device running another workload from the same policy can be upgraded to this workload
 }
 
 func (w Workload) String() string {
@@ -83,10 +188,11 @@ func (w Workload) String() string {
 		"Version: %v, "+
 		"Arch: %v, "+
 		"Deployment Overrides: %v, "+
-		"Deployment Overrides Signature: %v",
+		"Deployment Overrides Signature: %v, "+
+		"Upgrade: %v",
 		w.Priority, w.Deployment, w.DeploymentSignature, w.DeploymentUserInfo, w.WorkloadPassword,
 		w.ClusterDeployment, w.ClusterDeploymentSignature,
-		w.WorkloadURL, w.Org, w.Version, w.Arch, w.DeploymentOverrides, w.DeploymentOverridesSignature)
+		w.WorkloadURL, w.Org, w.Version, w.Arch, w.DeploymentOverrides, w.DeploymentOverridesSignature, w.GetUpgrade())
 }
 
 func (w Workload) ShortString() string {
@@ -110,6 +216,14 @@ func Workload_Factory(url string, org string, version string, arch string) *Work
 	return w
 }
 
+// Returns the upgrade policy of the workload. Workloads without an upgrade policy are upgraded immediately.
+func (w Workload) GetUpgrade() WorkloadUpgrade {
+	if w.Upgrade == nil {
+		return WorkloadUpgrade{}
+	}
+	return *w.Upgrade
+}
+
 // This function compares 2 workload objects for sameness. This is slightly complicated because 2 workloads can be
 // semantically the same without having identical state. For example, a workload entry that has the WorkloadURL set
 // might also have the other workloads details that can be found at the other end of he URL. In this case, we can
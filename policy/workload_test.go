@@ -387,6 +387,66 @@ func Test_WorkloadPriority_Factory(t *testing.T) {
 	}
 }
 
+func Test_WorkloadUpgrade_Validate(t *testing.T) {
+
+	valid := []WorkloadUpgrade{
+		{},
+		{Lifecycle: UPGRADE_LIFECYCLE_IMMEDIATE},
+		{Lifecycle: UPGRADE_LIFECYCLE_NEVER},
+		{Lifecycle: UPGRADE_LIFECYCLE_AGREEMENT},
+		{Time: "02:00-04:00"},
+		{Lifecycle: UPGRADE_LIFECYCLE_IMMEDIATE, Time: "2021-06-01T02:00:00Z"},
+		{Lifecycle: UPGRADE_LIFECYCLE_IMMEDIATE, Time: "01.00AM"},
+	}
+	for _, wu := range valid {
+		if err := wu.Validate(); err != nil {
+			t.Errorf("Upgrade policy %v should be valid, error: %v", wu, err)
+		}
+	}
+
+	if err := Workload_Upgrade_Factory("later", "").Validate(); err == nil {
+		t.Errorf("Upgrade policy lifecycle later should not be valid")
+	}
+}
+
+func Test_WorkloadUpgrade_NextUpgradeTime(t *testing.T) {
+
+	now := time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		upgradeTime string
+		expected    time.Time
+	}{
+		{"", now},
+		{"12:00-13:00", now},
+		{"14:00-16:00", time.Date(2021, 6, 1, 14, 0, 0, 0, time.UTC)},
+		{"02:00-04:00", time.Date(2021, 6, 2, 2, 0, 0, 0, time.UTC)},
+		{"23:00-13:00", now},
+		{"23:00-01:00", time.Date(2021, 6, 1, 23, 0, 0, 0, time.UTC)},
+		{"2021-06-03T02:00:00Z", time.Date(2021, 6, 3, 2, 0, 0, 0, time.UTC)},
+		{"2021-05-01T02:00:00Z", now},
+	}
+
+	for _, test := range tests {
+		wu := Workload_Upgrade_Factory(UPGRADE_LIFECYCLE_IMMEDIATE, test.upgradeTime)
+		if next, err := wu.NextUpgradeTime(now); err != nil {
+			t.Errorf("Unexpected error for %v: %v", wu, err)
+		} else if !next.Equal(test.expected) {
+			t.Errorf("Upgrade time for %v should be %v but was %v", wu, test.expected, next)
+		}
+	}
+
+	// times that cannot be parsed do not delay the upgrade
+	for _, upgradeTime := range []string{"02:00", "02:00-02:00", "25:00-04:00", "01.00AM"} {
+		wu := Workload_Upgrade_Factory(UPGRADE_LIFECYCLE_IMMEDIATE, upgradeTime)
+		if next, err := wu.NextUpgradeTime(now); err == nil {
+			t.Errorf("Upgrade time %v should not be parsed", upgradeTime)
+		} else if !next.Equal(now) {
+			t.Errorf("Upgrade time for %v should be %v but was %v", wu, now, next)
+		}
+	}
+}
+
 // Create a Workload section from a JSON serialization. The JSON serialization
 // does not have to be a valid Workload serialization, just has to be a valid
 // JSON serialization.
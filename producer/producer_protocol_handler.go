@@ -8,10 +8,12 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/api"
+	"github.com/open-horizon/anax/compcheck"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/hardware"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
@@ -145,35 +147,14 @@ func (w *BaseProducerProtocolHandler) sendMessage(mt interface{}, pay []byte) er
 		return errors.New(fmt.Sprintf("Unable to marshal exchange message %v, error %v", encryptedMsg, err))
 		// Send it to the device's message queue
 	} else {
-		pm := exchange.CreatePostMessage(msgBody, w.config.Edge.ExchangeMessageTTL)
-		var resp interface{}
-		resp = new(exchange.PostDeviceResponse)
-		targetURL := w.config.Edge.ExchangeURL + "orgs/" + exchange.GetOrg(messageTarget.ReceiverExchangeId) + "/agbots/" + exchange.GetId(messageTarget.ReceiverExchangeId) + "/msgs"
-
-		httpClientFactory := w.ec.GetHTTPFactory()
-		retryCount := httpClientFactory.RetryCount
-		retryInterval := httpClientFactory.GetRetryInterval()
-
-		for {
-			if err, tpErr := exchange.InvokeExchange(httpClientFactory.NewHTTPClient(nil), "POST", targetURL, w.ec.GetExchangeId(), w.ec.GetExchangeToken(), pm, &resp); err != nil {
-				return err
-			} else if tpErr != nil {
-				glog.Warningf(tpErr.Error())
-				if httpClientFactory.RetryCount == 0 {
-					time.Sleep(time.Duration(retryInterval) * time.Second)
-					continue
-				} else if retryCount == 0 {
-					return errors.New(fmt.Sprintf("exceeded %v retries trying to retrieve agbot for %v", httpClientFactory.RetryCount, tpErr))
-				} else {
-					retryCount--
-					time.Sleep(time.Duration(retryInterval) * time.Second)
-					continue
-				}
-			} else {
-				glog.V(5).Infof(BPPHlogString(w.Name(), fmt.Sprintf("Sent message for %v to exchange.", messageTarget.ReceiverExchangeId)))
-				return nil
-			}
+		t, err := exchange.GetNodeMessageTransport(w.config, w.ec.GetExchangeId(), w.ec.GetExchangeToken(), w.ec.GetHTTPFactory())
+		if err != nil {
+			return err
+		} else if err := t.SendMessage(exchange.TRANSPORT_PARTY_AGBOT, messageTarget.ReceiverExchangeId, msgBody, w.config.Edge.ExchangeMessageTTL); err != nil {
+			return errors.New(fmt.Sprintf("unable to send message to %v using the %v transport, error %v", messageTarget.ReceiverExchangeId, t.Name(), err))
 		}
+		glog.V(5).Infof(BPPHlogString(w.Name(), fmt.Sprintf("Sent message to %v.", messageTarget.ReceiverExchangeId)))
+		return nil
 	}
 }
 
@@ -190,6 +171,40 @@ func (w *BaseProducerProtocolHandler) serviceResolver(wURL string, wOrg string,
 	return asl, err
 }
 
+// A service resolver that keeps the definitions of the first workload it resolves and of the services it depends on,
+// so that the hardware requirements of the services in a proposal are checked without getting the definitions from
+// the exchange again.
+type proposalServices struct {
+	ec      exchange.ExchangeContext
+	topOrg  string
+	topId   string
+	topDef  *exchange.ServiceDefinition
+	depDefs map[string]exchange.ServiceDefinition
+}
+
+func (p *proposalServices) resolve(wURL string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, error) {
+	asl, depDefs, topDef, topId, err := exchange.GetHTTPServiceDefResolverHandler(p.ec)(wURL, wOrg, wVersion, wArch)
+	if err != nil {
+		return nil, err
+	} else if p.topDef == nil {
+		p.topOrg, p.topId, p.topDef, p.depDefs = wOrg, topId, topDef, depDefs
+	}
+	return asl, nil
+}
+
+// Returns true if any of the resolved services has hardware requirements.
+func (p *proposalServices) hasHardwareRequirements() bool {
+	if p.topDef != nil && len(p.topDef.MatchHardware) != 0 {
+		return true
+	}
+	for _, sDef := range p.depDefs {
+		if len(sDef.MatchHardware) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.ProtocolHandler, proposal abstractprotocol.Proposal, protocolMsg string, runningBCs []map[string]string, exchangeMsg *exchange.DeviceMessage) (bool, abstractprotocol.ProposalReply, *policy.Policy) {
 
 	handled := false
@@ -243,6 +258,9 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 		// Keep track of any signing keys we download so we can delete them when done
 		signingKeys := make([]string, 0)
 
+		// The service definitions resolved while checking the proposal, reused to check the hardware requirements
+		svcs := &proposalServices{ec: w.ec}
+
 		if dev, err := persistence.FindExchangeDevice(w.db); err != nil {
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("device is not configured to accept agreement yet.")))
 			err_log_event = fmt.Sprintf("Device is not configured to accept agreement yet.")
@@ -292,10 +310,18 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("received error getting pem key files: %v", err)))
 			err_log_event = fmt.Sprintf("Received error getting pem key files: %v", err)
 			handled = true
-		} else if err := tcPolicy.Is_Self_Consistent(pemFiles, w.GetServiceResolver()); err != nil {
+		} else if err := tcPolicy.Is_Self_Consistent(pemFiles, svcs.resolve); err != nil {
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("received error checking self consistency of TsAndCs, %v", err)))
 			err_log_event = fmt.Sprintf("Received error checking self consistency of TsAndCs: %v", err)
 			handled = true
+		} else if hmatch, reason, err := w.MatchHardware(tcPolicy, dev, svcs); err != nil {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("received error checking hardware requirements, %v", err)))
+			err_log_event = fmt.Sprintf("Received error checking hardware requirements, %v", err)
+			handled = true
+		} else if !hmatch {
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("hardware requirements are not met, ignoring proposal. %v", reason)))
+			err_log_event = fmt.Sprintf("Hardware requirements are not met, ignoring proposal. %v", reason)
+			handled = true
 		} else if messageTarget, err := exchange.CreateMessageTarget(exchangeMsg.AgbotId, nil, exchangeMsg.AgbotPubKey, ""); err != nil {
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("error creating message target: %v", err)))
 			err_log_event = fmt.Sprintf("Error creating message target: %v", err)
@@ -473,6 +499,46 @@ func (w *BaseProducerProtocolHandler) MatchPattern(tcPolicy *policy.Policy, dev
 	}
 }
 
+// check if the node hardware satisfies the matchHardware requirements of the proposed service and its dependent services,
+// using the service definitions that were resolved when the proposal was checked. It returns false and the reason if
+// the requirements are not met. The node hardware is only inspected when one of the services has requirements.
+func (w *BaseProducerProtocolHandler) MatchHardware(tcPolicy *policy.Policy, dev *persistence.ExchangeDevice, svcs *proposalServices) (bool, string, error) {
+	if dev == nil {
+		return false, "", fmt.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("device is not configured to accept agreement yet.")))
+	} else if tcPolicy.Workloads == nil || len(tcPolicy.Workloads) == 0 {
+		return false, "", fmt.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("no workload is supplied in the proposal.")))
+	} else if dev.GetNodeType() == persistence.DEVICE_TYPE_CLUSTER {
+		// hardware requirements only apply to the device type nodes
+		return true, "", nil
+	}
+
+	// The self consistency check does not resolve a workload that carries its deployment, so resolve it here. The service
+	// definitions are cached, so this usually does not go to the exchange.
+	if svcs.topDef == nil {
+		workload := tcPolicy.Workloads[0]
+		if _, err := svcs.resolve(workload.WorkloadURL, workload.Org, workload.Version, workload.Arch); err != nil {
+			return false, "", fmt.Errorf("unable to get the service definitions for %v/%v %v %v from the exchange, error %v", workload.Org, workload.WorkloadURL, workload.Version, workload.Arch, err)
+		}
+	}
+
+	if !svcs.hasHardwareRequirements() {
+		return true, "", nil
+	}
+
+	nodeHw, err := hardware.GetHardwareInfo(w.config.Edge.HardwareRootPath)
+	if err != nil {
+		return false, "", fmt.Errorf("unable to inspect the node hardware, error %v", err)
+	}
+
+	topSvc := &compcheck.ServiceDefinition{Org: svcs.topOrg, ServiceDefinition: *svcs.topDef}
+	if match, reason, err := compcheck.CheckHardwareCompatibility(dev.GetNodeType(), nodeHw, topSvc, svcs.topId, svcs.depDefs, nil); err != nil {
+		return false, "", err
+	} else {
+		glog.V(5).Infof(BPPHlogString(w.Name(), fmt.Sprintf("hardware match for %v is %v. %v", svcs.topId, match, reason)))
+		return match, reason, nil
+	}
+}
+
 // Check if there are current unarchived agreements that have the same workload.
 func (w *BaseProducerProtocolHandler) FindAgreementWithSameWorkload(ph abstractprotocol.ProtocolHandler, tcpol_name string) (*persistence.EstablishedAgreement, bool, error) {
 
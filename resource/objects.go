@@ -0,0 +1,51 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/edge-sync-service/common"
+	"github.com/open-horizon/edge-sync-service/core/base"
+	"sync/atomic"
+	"time"
+)
+
+// Set while the embedded ESS is running, the objects published by the agent are stored in it.
+var essRunning int32
+
+func setESSRunning(running bool) {
+	if running {
+		atomic.StoreInt32(&essRunning, 1)
+	} else {
+		atomic.StoreInt32(&essRunning, 0)
+	}
+}
+
+// IsESSRunning returns true when the embedded ESS is running, so objects can be published to the management hub.
+func IsESSRunning() bool {
+	return atomic.LoadInt32(&essRunning) == 1
+}
+
+// PublishObject stores an object in the embedded ESS, which sends it to the CSS of the management hub. From there it can
+// be downloaded by the org admin. The object expires after the given duration, or never when it is 0.
+func PublishObject(org string, objectType string, objectID string, description string, expiresAfter time.Duration, data []byte) error {
+	if !IsESSRunning() {
+		return errors.New("the embedded ESS is not running, the node must be registered to publish objects")
+	}
+
+	metaData := common.MetaData{
+		ObjectID:    objectID,
+		ObjectType:  objectType,
+		DestOrgID:   org,
+		Description: description,
+	}
+	if expiresAfter > 0 {
+		metaData.Expiration = time.Now().Add(expiresAfter).UTC().Format(time.RFC3339)
+	}
+
+	glog.V(3).Infof(rmLogString(fmt.Sprintf("publishing object %v/%v/%v of %v bytes", org, objectType, objectID, len(data))))
+	if err := base.UpdateObject(org, objectType, objectID, metaData, data); err != nil {
+		return errors.New(fmt.Sprintf("unable to publish object %v/%v/%v, error: %v", org, objectType, objectID, err))
+	}
+	return nil
+}
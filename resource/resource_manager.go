@@ -177,6 +177,7 @@ func (r ResourceManager) StartFileSyncServiceAndSecretsAPI(am *AuthenticationMan
 		os.Exit(98)
 	}
 
+	setESSRunning(true)
 	glog.V(3).Infof(rmLogString(fmt.Sprintf("ESS and Secrets API Started")))
 	return nil
 
@@ -212,6 +213,8 @@ func (r ResourceManager) StopFileSyncService() {
 		stopChan := make(chan bool)
 		done := false
 
+		setESSRunning(false)
+
 		// Initiate the ESS stop in a go routine in case it hangs.
 		go func() {
 			base.Stop(0, true)